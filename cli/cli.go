@@ -13,7 +13,8 @@ import (
 )
 
 var rootArgs struct {
-	configPath string
+	configPath    string
+	configLenient bool
 }
 
 var rootCmd = &cobra.Command{
@@ -23,6 +24,7 @@ var rootCmd = &cobra.Command{
 
 func init() {
 	rootCmd.PersistentFlags().StringVar(&rootArgs.configPath, "config", "", "config file path")
+	rootCmd.PersistentFlags().BoolVar(&rootArgs.configLenient, "config-lenient", false, "ignore unknown keys in the config file (warn instead of failing)")
 }
 
 var genCompletionCmd = &cobra.Command{
@@ -96,6 +98,23 @@ func (s *Subcommand) Config() *config.Config {
 	return s.config
 }
 
+// ReparseConfig parses the config file again, e.g. after it has been modified.
+// The config returned by Config is not affected.
+func (s *Subcommand) ReparseConfig() (*config.Config, error) {
+	return parseConfig()
+}
+
+func parseConfig() (*config.Config, error) {
+	if !rootArgs.configLenient {
+		return config.ParseConfig(rootArgs.configPath)
+	}
+	c, unknownKeys, err := config.ParseConfigLenient(rootArgs.configPath)
+	for _, k := range unknownKeys {
+		fmt.Fprintf(os.Stderr, "warning: ignoring config key: %s\n", k)
+	}
+	return c, err
+}
+
 func (s *Subcommand) run(cmd *cobra.Command, args []string) {
 	s.tryParseConfig()
 	ctx := context.Background()
@@ -110,7 +129,7 @@ func (s *Subcommand) run(cmd *cobra.Command, args []string) {
 }
 
 func (s *Subcommand) tryParseConfig() {
-	config, err := config.ParseConfig(rootArgs.configPath)
+	config, err := parseConfig()
 	s.configErr = err
 	if err != nil {
 		if s.NoRequireConfig {
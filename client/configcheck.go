@@ -13,6 +13,7 @@ import (
 
 	"github.com/zrepl/zrepl/cli"
 	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon"
 	"github.com/zrepl/zrepl/daemon/job"
 	"github.com/zrepl/zrepl/daemon/logging"
 	"github.com/zrepl/zrepl/logger"
@@ -69,6 +70,13 @@ var ConfigcheckCmd = &cli.Subcommand{
 			}
 		}
 
+		for _, j := range confJobs {
+			if dj, ok := j.(*job.DisabledJob); ok {
+				fmt.Fprintf(os.Stderr, "job %q will be disabled: %s\n", dj.Name(), dj.Err())
+				hadErr = true
+			}
+		}
+
 		// further: try to build logging outlets
 		outlets, err := logging.OutletsFromConfig(*subcommand.Config().Global.Logging)
 		if err != nil {
@@ -82,6 +90,12 @@ var ConfigcheckCmd = &cli.Subcommand{
 			}
 		}
 
+		// further: check zfs binary paths and privilege escalation
+		if err := daemon.ConfigureZFSCmdFromConfig(subcommand.Config().Global.ZFS); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", errors.Wrap(err, "cannot configure zfs commands"))
+			hadErr = true
+		}
+
 		whatMap := map[string]func(){
 			"all": func() {
 				o := struct {
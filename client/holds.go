@@ -0,0 +1,164 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+var HoldsCmd = &cli.Subcommand{
+	Use:   "holds",
+	Short: "list and release ZFS holds created by zrepl",
+	SetupSubcommands: func() []*cli.Subcommand {
+		return []*cli.Subcommand{
+			holdsCmdList,
+			holdsCmdRelease,
+		}
+	},
+}
+
+var holdsListFlags struct {
+	json bool
+}
+
+var holdsCmdList = &cli.Subcommand{
+	Use:             "list DATASET",
+	Short:           "list the zrepl holds on the snapshots of DATASET and its children",
+	NoRequireConfig: true,
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.BoolVar(&holdsListFlags.json, "json", false, "emit JSON")
+	},
+	Run: runHoldsListCmd,
+}
+
+var holdsReleaseFlags struct {
+	tag    string
+	dryRun bool
+	yes    bool
+}
+
+var holdsCmdRelease = &cli.Subcommand{
+	Use:             "release --tag TAG (SNAPSHOT|DATASET)",
+	Short:           "release the zrepl hold TAG on SNAPSHOT or on all snapshots of DATASET and its children",
+	NoRequireConfig: true,
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.StringVar(&holdsReleaseFlags.tag, "tag", "", "tag of the hold to release (must be a zrepl hold tag)")
+		f.BoolVar(&holdsReleaseFlags.dryRun, "dry-run", false, "only print the holds that would be released")
+		f.BoolVar(&holdsReleaseFlags.yes, "yes", false, "do not ask for confirmation when releasing more than one hold")
+	},
+	Run: runHoldsReleaseCmd,
+}
+
+// listZreplHolds lists the zrepl holds on the snapshot or the snapshots of the dataset subtree arg.
+// Foreign holds are never returned.
+func listZreplHolds(ctx context.Context, arg string) ([]zfs.Hold, error) {
+	var holds []zfs.Hold
+	if strings.Contains(arg, "@") {
+		if _, _, _, err := zfs.DecomposeVersionString(arg); err != nil {
+			return nil, errors.Wrap(err, "invalid snapshot")
+		}
+		var err error
+		if holds, err = zfs.ZFSListHoldsOfSnapshots(ctx, arg); err != nil {
+			return nil, err
+		}
+	} else {
+		fs, err := zfs.NewDatasetPath(arg)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid dataset")
+		}
+		if fs.Length() == 0 {
+			return nil, errors.New("dataset must not be empty")
+		}
+		if holds, err = zfs.ZFSListHolds(ctx, fs, true); err != nil {
+			return nil, err
+		}
+	}
+	zreplHolds := holds[:0]
+	for _, h := range holds {
+		if endpoint.IsZreplHoldTag(h.Tag) {
+			zreplHolds = append(zreplHolds, h)
+		}
+	}
+	return zreplHolds, nil
+}
+
+func runHoldsListCmd(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+	if len(args) != 1 {
+		return errors.New("must specify exactly one positional argument: the dataset")
+	}
+	holds, err := listZreplHolds(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	if holdsListFlags.json {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(holds)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SNAPSHOT\tTAG\tCREATED")
+	for _, h := range holds {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", h.Snapshot, h.Tag, h.Created.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+func runHoldsReleaseCmd(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+	if len(args) != 1 {
+		return errors.New("must specify exactly one positional argument: the snapshot or dataset")
+	}
+	tag := holdsReleaseFlags.tag
+	if tag == "" {
+		return errors.New("--tag must be specified")
+	}
+	if !endpoint.IsZreplHoldTag(tag) {
+		return fmt.Errorf("refusing to release hold %q: not a zrepl hold (tag must start with %q)", tag, endpoint.HoldTagPrefix)
+	}
+
+	holds, err := listZreplHolds(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	var snaps []string
+	for _, h := range holds {
+		if h.Tag == tag {
+			snaps = append(snaps, h.Snapshot)
+		}
+	}
+	if len(snaps) == 0 {
+		return fmt.Errorf("no hold with tag %q on %q", tag, args[0])
+	}
+
+	for _, s := range snaps {
+		fmt.Printf("would release hold %q on %s\n", tag, s)
+	}
+	if holdsReleaseFlags.dryRun {
+		return nil
+	}
+	if len(snaps) > 1 && !holdsReleaseFlags.yes {
+		fmt.Printf("release %d holds? [y/N] ", len(snaps))
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if a := strings.TrimSpace(strings.ToLower(answer)); a != "y" && a != "yes" {
+			return errors.New("aborted, no holds released")
+		}
+	}
+
+	if err := zfs.ZFSRelease(ctx, tag, snaps...); err != nil {
+		return err
+	}
+	fmt.Printf("released %d holds\n", len(snaps))
+	return nil
+}
@@ -42,6 +42,8 @@ var migrations = []*cli.Subcommand{
 			f.BoolVar(&migrateReplicationCursorArgs.dryRun, "dry-run", false, "dry run")
 		},
 	},
+	migratePlaceholderCmd,
+	migrateSnapshotPrefixCmd,
 }
 
 var migratePlaceholder0_1Args struct {
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+var migratePlaceholderArgs struct {
+	dryRun bool
+}
+
+var migratePlaceholderCmd = &cli.Subcommand{
+	Use:             "placeholder [--dry-run] DATASET",
+	Short:           "migrate the placeholder property of DATASET and its children to the current format",
+	NoRequireConfig: true,
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.BoolVar(&migratePlaceholderArgs.dryRun, "dry-run", false, "only print what would be migrated")
+	},
+	Run: doMigratePlaceholder,
+}
+
+type migratePlaceholderOutcome int
+
+const (
+	migratePlaceholderMigrated migratePlaceholderOutcome = iota
+	migratePlaceholderCurrent
+	migratePlaceholderSkipped // not a placeholder
+	migratePlaceholderError
+)
+
+func (o migratePlaceholderOutcome) String(dryRun bool) string {
+	switch o {
+	case migratePlaceholderMigrated:
+		if dryRun {
+			return "would migrate"
+		}
+		return "migrated"
+	case migratePlaceholderCurrent:
+		return "already current"
+	case migratePlaceholderSkipped:
+		return "skipped (not a placeholder)"
+	case migratePlaceholderError:
+		return "error"
+	default:
+		return fmt.Sprintf("migratePlaceholderOutcome(%d)", int(o))
+	}
+}
+
+func migratePlaceholderOutcomeOf(r *zfs.MigrateHashBasedPlaceholderReport, err error) (o migratePlaceholderOutcome, detail string) {
+	switch {
+	case err != nil:
+		return migratePlaceholderError, err.Error()
+	case r.NeedsModification:
+		return migratePlaceholderMigrated, fmt.Sprintf("old value = %q", r.OriginalState.RawLocalPropertyValue)
+	case !r.OriginalState.IsPlaceholder:
+		return migratePlaceholderSkipped, ""
+	default:
+		return migratePlaceholderCurrent, ""
+	}
+}
+
+func doMigratePlaceholder(ctx context.Context, sc *cli.Subcommand, args []string) error {
+	if len(args) != 1 {
+		return errors.New("must specify exactly one positional argument: the dataset")
+	}
+	root, err := zfs.NewDatasetPath(args[0])
+	if err != nil {
+		return errors.Wrap(err, "invalid dataset")
+	}
+	if root.Length() == 0 {
+		return errors.New("dataset must not be empty")
+	}
+	subtree, err := filters.DatasetMapFilterFromConfig(map[string]bool{root.ToString() + "<": true})
+	if err != nil {
+		return err
+	}
+	fss, err := zfs.ZFSListMapping(ctx, subtree)
+	if err != nil {
+		return errors.Wrap(err, "cannot list filesystems")
+	}
+	if len(fss) == 0 {
+		return fmt.Errorf("no filesystems below %q", root.ToString())
+	}
+
+	dryRun := migratePlaceholderArgs.dryRun
+	counts := make(map[migratePlaceholderOutcome]int)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DATASET\tRESULT\tDETAIL")
+	for _, fs := range fss {
+		// errors (e.g. missing permissions) only affect this dataset, continue with the others
+		o, detail := migratePlaceholderOutcomeOf(zfs.ZFSMigrateHashBasedPlaceholderToCurrent(ctx, fs, dryRun))
+		counts[o]++
+		fmt.Fprintf(w, "%s\t%s\t%s\n", fs.ToString(), o.String(dryRun), detail)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Printf("\n%d %s, %d already current, %d skipped (not a placeholder), %d errors\n",
+		counts[migratePlaceholderMigrated], migratePlaceholderMigrated.String(dryRun),
+		counts[migratePlaceholderCurrent], counts[migratePlaceholderSkipped], counts[migratePlaceholderError])
+	if counts[migratePlaceholderError] > 0 {
+		return fmt.Errorf("%d datasets could not be migrated", counts[migratePlaceholderError])
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+var migrateSnapshotPrefixArgs struct {
+	dryRun   bool
+	from, to string
+}
+
+var migrateSnapshotPrefixCmd = &cli.Subcommand{
+	Use:             "snapshot-prefix [--dry-run] --from OLD --to NEW DATASET",
+	Short:           "rename the snapshots of DATASET and its children from prefix OLD to prefix NEW",
+	NoRequireConfig: true,
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.BoolVar(&migrateSnapshotPrefixArgs.dryRun, "dry-run", false, "only print what would be renamed")
+		f.StringVar(&migrateSnapshotPrefixArgs.from, "from", "", "the old prefix")
+		f.StringVar(&migrateSnapshotPrefixArgs.to, "to", "", "the new prefix")
+	},
+	Run: doMigrateSnapshotPrefix,
+}
+
+type snapshotPrefixRename struct {
+	fs       *zfs.DatasetPath
+	from, to string // snapshot names without filesystem
+}
+
+// planSnapshotPrefixRenames returns the renames of the snapshots of fs (names without filesystem)
+// that start with prefix from to prefix to. If to starts with from, snapshots that start with to
+// are considered migrated already and are not renamed again. The remainder of the name
+// (e.g., the timestamp of snapshots created by zrepl) is preserved.
+// Renames whose new name exists already or is invalid are returned as conflicts.
+func planSnapshotPrefixRenames(fs *zfs.DatasetPath, snapshots []string, from, to string) (renames []snapshotPrefixRename, conflicts []string) {
+	exists := make(map[string]bool, len(snapshots))
+	for _, s := range snapshots {
+		exists[s] = true
+	}
+	// if to starts with from, snapshots with prefix to also have prefix from
+	toExtendsFrom := strings.HasPrefix(to, from)
+	for _, s := range snapshots {
+		if !strings.HasPrefix(s, from) || (toExtendsFrom && strings.HasPrefix(s, to)) {
+			continue
+		}
+		newName := to + strings.TrimPrefix(s, from)
+		fullPath := fmt.Sprintf("%s@%s", fs.ToString(), newName)
+		if exists[newName] {
+			conflicts = append(conflicts, fmt.Sprintf("%s@%s: %s exists already", fs.ToString(), s, fullPath))
+			continue
+		}
+		if err := zfs.EntityNamecheck(fullPath, zfs.EntityTypeSnapshot); err != nil {
+			conflicts = append(conflicts, fmt.Sprintf("%s@%s: invalid new name %s: %s", fs.ToString(), s, fullPath, err))
+			continue
+		}
+		renames = append(renames, snapshotPrefixRename{fs, s, newName})
+	}
+	return renames, conflicts
+}
+
+func doMigrateSnapshotPrefix(ctx context.Context, sc *cli.Subcommand, args []string) error {
+	if len(args) != 1 {
+		return errors.New("must specify exactly one positional argument: the dataset")
+	}
+	from, to := migrateSnapshotPrefixArgs.from, migrateSnapshotPrefixArgs.to
+	if from == "" || to == "" {
+		return errors.New("must specify both --from and --to")
+	}
+	if from == to {
+		return errors.New("--from and --to must differ")
+	}
+	root, err := zfs.NewDatasetPath(args[0])
+	if err != nil {
+		return errors.Wrap(err, "invalid dataset")
+	}
+	if root.Length() == 0 {
+		return errors.New("dataset must not be empty")
+	}
+	subtree, err := filters.DatasetMapFilterFromConfig(map[string]bool{root.ToString() + "<": true})
+	if err != nil {
+		return err
+	}
+	fss, err := zfs.ZFSListMapping(ctx, subtree)
+	if err != nil {
+		return errors.Wrap(err, "cannot list filesystems")
+	}
+	if len(fss) == 0 {
+		return fmt.Errorf("no filesystems below %q", root.ToString())
+	}
+
+	// plan everything first so that conflicts are detected before anything is renamed
+	var renames []snapshotPrefixRename
+	var conflicts []string
+	for _, fs := range fss {
+		versions, err := zfs.ZFSListFilesystemVersions(ctx, fs, zfs.ListFilesystemVersionsOptions{Types: zfs.Snapshots})
+		if err != nil {
+			return errors.Wrapf(err, "cannot list snapshots of %q", fs.ToString())
+		}
+		names := make([]string, len(versions))
+		for i, v := range versions {
+			names[i] = v.Name
+		}
+		r, c := planSnapshotPrefixRenames(fs, names, from, to)
+		renames = append(renames, r...)
+		conflicts = append(conflicts, c...)
+	}
+	if len(conflicts) > 0 {
+		for _, c := range conflicts {
+			fmt.Fprintf(os.Stderr, "conflict: %s\n", c)
+		}
+		return fmt.Errorf("refusing to rename any snapshots: %d conflicts", len(conflicts))
+	}
+
+	dryRun := migrateSnapshotPrefixArgs.dryRun
+	renamed, failed := 0, 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DATASET\tOLD\tNEW\tRESULT")
+	for _, r := range renames {
+		result := "would rename"
+		if !dryRun {
+			result = "renamed"
+			// errors only affect this snapshot, continue with the others
+			if err := zfs.ZFSRenameSnapshot(ctx, r.fs, r.from, r.to); err != nil {
+				result = fmt.Sprintf("error: %s", strings.Join(strings.Fields(err.Error()), " "))
+				failed++
+			} else {
+				renamed++
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.fs.ToString(), r.from, r.to, result)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if dryRun {
+		fmt.Printf("\n%d snapshots would be renamed\n", len(renames))
+		return nil
+	}
+	fmt.Printf("\n%d snapshots renamed, %d errors\n", renamed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d snapshots could not be renamed", failed)
+	}
+	return nil
+}
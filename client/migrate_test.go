@@ -1,6 +1,13 @@
 package client
 
-import "testing"
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zrepl/zrepl/zfs"
+)
 
 func TestMigrationsUnambiguousNames(t *testing.T) {
 	names := make(map[string]bool)
@@ -14,3 +21,70 @@ func TestMigrationsUnambiguousNames(t *testing.T) {
 		}
 	}
 }
+
+func TestMigratePlaceholderOutcomeOf(t *testing.T) {
+	report := func(isPlaceholder, needsModification bool, raw string) *zfs.MigrateHashBasedPlaceholderReport {
+		return &zfs.MigrateHashBasedPlaceholderReport{
+			OriginalState: zfs.FilesystemPlaceholderState{
+				FSExists:              true,
+				IsPlaceholder:         isPlaceholder,
+				RawLocalPropertyValue: raw,
+			},
+			NeedsModification: needsModification,
+		}
+	}
+
+	o, detail := migratePlaceholderOutcomeOf(report(true, true, "abcdef"), nil)
+	assert.Equal(t, migratePlaceholderMigrated, o)
+	assert.Equal(t, `old value = "abcdef"`, detail)
+	assert.Equal(t, "would migrate", o.String(true))
+	assert.Equal(t, "migrated", o.String(false))
+
+	o, _ = migratePlaceholderOutcomeOf(report(true, false, "on"), nil)
+	assert.Equal(t, migratePlaceholderCurrent, o)
+
+	o, _ = migratePlaceholderOutcomeOf(report(false, false, ""), nil)
+	assert.Equal(t, migratePlaceholderSkipped, o)
+
+	o, detail = migratePlaceholderOutcomeOf(nil, errors.New("permission denied"))
+	assert.Equal(t, migratePlaceholderError, o)
+	assert.Equal(t, "permission denied", detail)
+}
+
+func TestPlanSnapshotPrefixRenames(t *testing.T) {
+	fs, err := zfs.NewDatasetPath("pool/fs")
+	assert.NoError(t, err)
+	names := func(renames []snapshotPrefixRename) (res [][2]string) {
+		for _, r := range renames {
+			res = append(res, [2]string{r.from, r.to})
+		}
+		return res
+	}
+
+	snaps := []string{"zrepl_20200101_000000_000", "zrepl_20200102_000000_000", "manual", "auto-20200103_000000_000"}
+	renames, conflicts := planSnapshotPrefixRenames(fs, snaps, "zrepl_", "auto-")
+	assert.Empty(t, conflicts)
+	assert.Equal(t, [][2]string{
+		{"zrepl_20200101_000000_000", "auto-20200101_000000_000"},
+		{"zrepl_20200102_000000_000", "auto-20200102_000000_000"},
+	}, names(renames))
+
+	// the new name exists already
+	snaps = append(snaps, "auto-20200101_000000_000")
+	_, conflicts = planSnapshotPrefixRenames(fs, snaps, "zrepl_", "auto-")
+	assert.Len(t, conflicts, 1)
+
+	// the new prefix extends the old one: snapshots with the new prefix are not renamed again
+	renames, conflicts = planSnapshotPrefixRenames(fs, []string{"zrepl_1", "zrepl_hourly_2"}, "zrepl_", "zrepl_hourly_")
+	assert.Empty(t, conflicts)
+	assert.Equal(t, [][2]string{{"zrepl_1", "zrepl_hourly_1"}}, names(renames))
+
+	// the new prefix is a prefix of the old one: all snapshots with the old prefix are renamed
+	renames, conflicts = planSnapshotPrefixRenames(fs, []string{"zrepl_hourly_1", "zrepl_hourly_2", "zrepl_3"}, "zrepl_hourly_", "zrepl_")
+	assert.Empty(t, conflicts)
+	assert.Equal(t, [][2]string{{"zrepl_hourly_1", "zrepl_1"}, {"zrepl_hourly_2", "zrepl_2"}}, names(renames))
+
+	// invalid new names
+	_, conflicts = planSnapshotPrefixRenames(fs, []string{"zrepl_1"}, "zrepl_", "a/b")
+	assert.Len(t, conflicts, 1)
+}
@@ -0,0 +1,201 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+var recvArgs struct {
+	force       bool
+	resumable   bool
+	fromTeeFile string
+}
+
+var RecvCmd = &cli.Subcommand{
+	Use:             "recv [--force] [--resumable] [--from-tee-file FILE] TARGET[@SNAPSHOT]",
+	Short:           "receive a send stream from stdin or a tee file into filesystem TARGET, bypassing configured jobs",
+	NoRequireConfig: true,
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.BoolVar(&recvArgs.force, "force", false, "for full streams: destroy all snapshots of an existing TARGET and overwrite it (zfs recv -F)")
+		f.BoolVar(&recvArgs.resumable, "resumable", false, "save the state of an interrupted receive so that it can be resumed (zfs recv -s)")
+		f.StringVar(&recvArgs.fromTeeFile, "from-tee-file", "", "read the stream from FILE, written by the tee send option, instead of stdin (decompressed and checked against its manifest before receiving)")
+	},
+	Run: runRecvCmd,
+}
+
+// recvTarget is the state of the receiving filesystem that determines whether a stream can be received.
+type recvTarget struct {
+	exists      bool
+	snapshots   []zfs.FilesystemVersion // sorted by createtxg
+	resumeToken *zfs.ResumeToken        // nil if there is no resumable receive state
+}
+
+// check returns opts for receiving the stream described by h into t,
+// or an error that explains why the stream is incompatible with t.
+func (t *recvTarget) check(h *zfs.SendStreamHeader, force, resumable bool) (opts zfs.RecvOptions, err error) {
+	opts.SavePartialRecvState = resumable
+
+	if t.resumeToken != nil {
+		if t.resumeToken.HasToGUID && t.resumeToken.ToGUID == h.ToGUID {
+			// the stream is either a resumed stream (zfs send -t) or zfs recv rejects it
+			opts.SavePartialRecvState = true
+			return opts, nil
+		}
+		return opts, fmt.Errorf("target has resumable receive state for %q, resume it (zfs send -t) or discard it (zrepl recv-abort)", t.resumeToken.ToName)
+	}
+
+	for _, s := range t.snapshots {
+		if s.Guid == h.ToGUID {
+			return opts, fmt.Errorf("stream has already been received: target snapshot %q has the stream's GUID", s.Name)
+		}
+	}
+
+	if !h.IsIncremental() {
+		if t.exists && !force {
+			return opts, errors.New("target exists, but the stream is a full stream (use --force to destroy all snapshots of the target and overwrite it)")
+		}
+		opts.RollbackAndForceRecv = force
+		return opts, nil
+	}
+
+	if force {
+		return opts, errors.New("--force is only supported for full streams")
+	}
+	if !t.exists {
+		return opts, errors.New("stream is incremental, but target does not exist")
+	}
+	for i, s := range t.snapshots {
+		if s.Guid != h.FromGUID {
+			continue
+		}
+		if newer := t.snapshots[i+1:]; len(newer) > 0 {
+			names := make([]string, len(newer))
+			for j := range newer {
+				names[j] = newer[j].Name
+			}
+			return opts, fmt.Errorf("target has snapshots newer than the stream's incremental source %q: %s", s.Name, strings.Join(names, ", "))
+		}
+		return opts, nil
+	}
+	return opts, fmt.Errorf("the stream's incremental source (guid %d) is not a snapshot of the target", h.FromGUID)
+}
+
+func getRecvTarget(ctx context.Context, fs *zfs.DatasetPath) (*recvTarget, error) {
+	ph, err := zfs.ZFSGetFilesystemPlaceholderState(ctx, fs)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get target state")
+	}
+	t := &recvTarget{exists: ph.FSExists}
+	if !t.exists {
+		if fs.Length() > 1 {
+			parent := fs.Parent()
+			pph, err := zfs.ZFSGetFilesystemPlaceholderState(ctx, parent)
+			if err != nil {
+				return nil, errors.Wrap(err, "cannot get state of target's parent")
+			}
+			if !pph.FSExists {
+				return nil, fmt.Errorf("parent filesystem %q of target does not exist", parent.ToString())
+			}
+		}
+		return t, nil
+	}
+
+	t.snapshots, err = zfs.ZFSListFilesystemVersions(ctx, fs, zfs.ListFilesystemVersionsOptions{Types: zfs.Snapshots})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list target snapshots")
+	}
+	sort.Slice(t.snapshots, func(i, j int) bool { return t.snapshots[i].CreateTXG < t.snapshots[j].CreateTXG })
+
+	token, err := zfs.ZFSGetReceiveResumeTokenOrEmptyStringIfNotSupported(ctx, fs)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot get receive resume token of target")
+	}
+	if token != "" {
+		if t.resumeToken, err = zfs.ParseResumeToken(ctx, token); err != nil {
+			return nil, errors.Wrap(err, "cannot decode receive resume token of target")
+		}
+	}
+	return t, nil
+}
+
+func runRecvCmd(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+	if len(args) != 1 {
+		return errors.New("must specify exactly one positional argument: the target filesystem")
+	}
+	targetStr, snapName := args[0], ""
+	if i := strings.Index(targetStr, "@"); i >= 0 {
+		targetStr, snapName = targetStr[:i], targetStr[i+1:]
+		if snapName == "" {
+			return errors.New("snapshot name must not be empty")
+		}
+	}
+	target, err := zfs.NewDatasetPath(targetStr)
+	if err != nil {
+		return errors.Wrap(err, "invalid target")
+	}
+	if target.Length() < 2 {
+		return errors.New("target must be a filesystem below the pool's root filesystem")
+	}
+
+	var input io.ReadCloser = ioutil.NopCloser(os.Stdin)
+	if recvArgs.fromTeeFile != "" {
+		// verify first: zfs recv might complete before the checksum is checked at the end of the stream
+		manifest, err := endpoint.VerifySendTeeFile(recvArgs.fromTeeFile)
+		if err != nil {
+			return errors.Wrap(err, "cannot verify tee file")
+		}
+		fmt.Fprintf(os.Stderr, "verified tee file of %s%s (codec %s, %d bytes, sha256 %s)\n",
+			manifest.Filesystem, manifest.To, manifest.Codec, manifest.Size, manifest.SHA256)
+		if input, _, err = endpoint.OpenSendTeeFile(recvArgs.fromTeeFile); err != nil {
+			return errors.Wrap(err, "cannot open tee file")
+		}
+		defer input.Close()
+	}
+
+	stream := bufio.NewReaderSize(input, 1<<20)
+	header, err := zfs.PeekSendStreamHeader(stream)
+	if err != nil {
+		return err
+	}
+	if snapName == "" {
+		if snapName, err = header.ToSnapshotName(); err != nil {
+			return err
+		}
+	}
+	to := &zfs.ZFSSendArgVersion{RelName: "@" + snapName, GUID: header.ToGUID}
+	if err := to.ValidateInMemory(target.ToString()); err != nil {
+		return errors.Wrap(err, "invalid snapshot name")
+	}
+
+	t, err := getRecvTarget(ctx, target)
+	if err != nil {
+		return err
+	}
+	opts, err := t.check(header, recvArgs.force, recvArgs.resumable)
+	if err != nil {
+		return errors.Wrapf(err, "cannot receive stream of %q into %q", header.ToName, target.ToString())
+	}
+
+	fmt.Fprintf(os.Stderr, "receiving stream of %q into %s\n", header.ToName, to.FullPath(target.ToString()))
+	err = zfs.ZFSRecv(ctx, target.ToString(), to, ioutil.NopCloser(stream), opts)
+	if rtErr, ok := err.(*zfs.RecvFailedWithResumeTokenErr); ok {
+		return fmt.Errorf("receive interrupted, resume by piping `zfs send -t %s` into zrepl recv: %s", rtErr.ResumeTokenRaw, err)
+	} else if err != nil {
+		return errors.Wrap(err, "zfs recv failed")
+	}
+	fmt.Fprintln(os.Stderr, "done")
+	return nil
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestRecvTargetCheck(t *testing.T) {
+	snap := func(name string, guid uint64) zfs.FilesystemVersion {
+		return zfs.FilesystemVersion{Type: zfs.Snapshot, Name: name, Guid: guid}
+	}
+	existing := &recvTarget{exists: true, snapshots: []zfs.FilesystemVersion{snap("a", 1), snap("b", 2)}}
+	full := &zfs.SendStreamHeader{ToName: "pool/fs@c", ToGUID: 3}
+	incremental := func(from uint64) *zfs.SendStreamHeader {
+		return &zfs.SendStreamHeader{ToName: "pool/fs@c", ToGUID: 3, FromGUID: from}
+	}
+
+	t.Run("full stream into new target", func(t *testing.T) {
+		opts, err := (&recvTarget{}).check(full, false, true)
+		require.NoError(t, err)
+		assert.Equal(t, zfs.RecvOptions{SavePartialRecvState: true}, opts)
+	})
+
+	t.Run("full stream into existing target", func(t *testing.T) {
+		_, err := existing.check(full, false, false)
+		assert.Error(t, err)
+		opts, err := existing.check(full, true, false)
+		require.NoError(t, err)
+		assert.True(t, opts.RollbackAndForceRecv)
+	})
+
+	t.Run("incremental stream", func(t *testing.T) {
+		opts, err := existing.check(incremental(2), false, false)
+		require.NoError(t, err)
+		assert.Equal(t, zfs.RecvOptions{}, opts)
+
+		_, err = existing.check(incremental(2), true, false)
+		assert.Error(t, err, "force is only supported for full streams")
+	})
+
+	t.Run("incremental stream with newer snapshots on target", func(t *testing.T) {
+		_, err := existing.check(incremental(1), false, false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "b")
+	})
+
+	t.Run("incremental source missing", func(t *testing.T) {
+		_, err := existing.check(incremental(4), false, false)
+		assert.Error(t, err)
+		_, err = (&recvTarget{}).check(incremental(2), false, false)
+		assert.Error(t, err)
+	})
+
+	t.Run("already received", func(t *testing.T) {
+		_, err := existing.check(&zfs.SendStreamHeader{ToName: "pool/fs@b", ToGUID: 2, FromGUID: 1}, false, false)
+		assert.Error(t, err)
+	})
+
+	t.Run("resume token", func(t *testing.T) {
+		resuming := &recvTarget{
+			exists:      true,
+			snapshots:   existing.snapshots,
+			resumeToken: &zfs.ResumeToken{HasToGUID: true, ToGUID: 3, ToName: "pool/fs@c"},
+		}
+		opts, err := resuming.check(incremental(2), false, false)
+		require.NoError(t, err)
+		assert.True(t, opts.SavePartialRecvState)
+
+		_, err = resuming.check(&zfs.SendStreamHeader{ToName: "pool/fs@d", ToGUID: 4, FromGUID: 2}, false, false)
+		assert.Error(t, err)
+	})
+}
+
+func TestRecvCmdRefusesCorruptTeeFile(t *testing.T) {
+	dir, cleanup := withFakeZFS(t, `echo "$@" >> "$FAKEZFS_DIR/log"; exit 1`)
+	defer cleanup()
+
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+
+	file := filepath.Join(dir, "pool_fs__@a.zfsstream")
+	require.NoError(t, ioutil.WriteFile(file, []byte("stream"), 0600))
+	manifest := `{"Filesystem":"pool/fs","To":"@a","Codec":"none","Size":6,"SHA256":"0000"}`
+	require.NoError(t, ioutil.WriteFile(file+endpoint.SendTeeManifestSuffix, []byte(manifest), 0600))
+
+	defer func(orig string) { recvArgs.fromTeeFile = orig }(recvArgs.fromTeeFile)
+	recvArgs.fromTeeFile = file
+	err := runRecvCmd(ctx, RecvCmd, []string{"pool/fs"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "checksum")
+	_, err = os.Stat(filepath.Join(dir, "log"))
+	assert.True(t, os.IsNotExist(err), "must not invoke zfs")
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+var recvAbortArgs struct {
+	dryRun bool
+}
+
+var RecvAbortCmd = &cli.Subcommand{
+	Use:             "recv-abort DATASET",
+	Short:           "abort an interrupted receive into DATASET and discard its resumable receive state (zfs recv -A)",
+	NoRequireConfig: true,
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.BoolVar(&recvAbortArgs.dryRun, "dry-run", false, "only check for resumable receive state, do not abort")
+	},
+	Run: runRecvAbortCmd,
+}
+
+func recvAbortDataset(args []string) (*zfs.DatasetPath, error) {
+	if len(args) != 1 {
+		return nil, errors.New("must specify exactly one positional argument: the dataset")
+	}
+	fs, err := zfs.NewDatasetPath(args[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid dataset")
+	}
+	if fs.Length() == 0 {
+		return nil, errors.New("dataset must not be empty")
+	}
+	if err := zfs.EntityNamecheck(fs.ToString(), zfs.EntityTypeFilesystem); err != nil {
+		return nil, errors.Wrap(err, "invalid dataset")
+	}
+	return fs, nil
+}
+
+func runRecvAbortCmd(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+	fs, err := recvAbortDataset(args)
+	if err != nil {
+		return err
+	}
+
+	token, err := zfs.ZFSGetReceiveResumeTokenOrEmptyStringIfNotSupported(ctx, fs)
+	if err != nil {
+		return errors.Wrap(err, "cannot get receive resume token")
+	}
+	if token == "" {
+		return fmt.Errorf("dataset %q has no resumable receive state (or ZFS does not support resumable receive)", fs.ToString())
+	}
+	fmt.Printf("dataset %q has resumable receive state\n", fs.ToString())
+	if decoded, err := zfs.ParseResumeToken(ctx, token); err == nil {
+		fmt.Printf("resume token points to %q\n", decoded.ToName)
+	}
+
+	if recvAbortArgs.dryRun {
+		fmt.Println("dry run, not aborting")
+		return nil
+	}
+
+	if err := zfs.ZFSRecvAbort(ctx, fs); err != nil {
+		return errors.Wrap(err, "cannot abort receive")
+	}
+	fmt.Println("aborted receive, resumable receive state discarded")
+	return nil
+}
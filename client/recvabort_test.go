@@ -0,0 +1,25 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecvAbortDataset(t *testing.T) {
+	fs, err := recvAbortDataset([]string{"pool/sink/fs"})
+	require.NoError(t, err)
+	assert.Equal(t, "pool/sink/fs", fs.ToString())
+
+	for _, args := range [][]string{
+		nil,
+		{"pool/a", "pool/b"},
+		{""},
+		{"pool/fs@snap"}, // receives are aborted on filesystems, not snapshots
+		{"pool/fs#book"},
+	} {
+		_, err := recvAbortDataset(args)
+		assert.Error(t, err, "%q", args)
+	}
+}
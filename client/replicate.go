@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+var replicateArgs struct {
+	source, target string
+	from, to       string
+	encrypted      bool
+	dryRun         bool
+}
+
+var ReplicateCmd = &cli.Subcommand{
+	Use:             "replicate --source FS [--from SNAP] --to SNAP --target FS",
+	Short:           "one-off local replication of a filesystem between two named snapshots, bypassing configured jobs",
+	NoRequireConfig: true,
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.StringVar(&replicateArgs.source, "source", "", "the sending filesystem")
+		f.StringVar(&replicateArgs.from, "from", "", "the incremental source snapshot or bookmark (full send if omitted)")
+		f.StringVar(&replicateArgs.to, "to", "", "the snapshot to send")
+		f.StringVar(&replicateArgs.target, "target", "", "the receiving filesystem")
+		f.BoolVar(&replicateArgs.encrypted, "encrypted", false, "do an encrypted (raw) send")
+		f.BoolVar(&replicateArgs.dryRun, "dry-run", false, "validate the arguments and print the size estimate, but do not send")
+	},
+	Run: runReplicateCmd,
+}
+
+// replicateVersion resolves a snapshot or bookmark name given on the command line
+// to the version of that name in filesystem fs, validating that it exists.
+// Names without '@' or '#' prefix are interpreted as snapshot names.
+func replicateVersion(ctx context.Context, fs, name string) (*zfs.ZFSSendArgVersion, zfs.FilesystemVersion, error) {
+	if !strings.HasPrefix(name, "@") && !strings.HasPrefix(name, "#") {
+		name = "@" + name
+	}
+	v, err := zfs.ZFSGetFilesystemVersion(ctx, fs+name)
+	if err != nil {
+		return nil, v, errors.Wrapf(err, "cannot get version %q", fs+name)
+	}
+	return &zfs.ZFSSendArgVersion{RelName: v.RelName(), GUID: v.Guid}, v, nil
+}
+
+func runReplicateCmd(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+	if len(args) > 0 {
+		return errors.New("this subcommand takes no positional arguments")
+	}
+	if replicateArgs.source == "" || replicateArgs.to == "" || replicateArgs.target == "" {
+		return errors.New("must specify --source, --to and --target")
+	}
+	if replicateArgs.source == replicateArgs.target {
+		return errors.New("--source and --target must be different filesystems")
+	}
+	if _, err := zfs.NewDatasetPath(replicateArgs.target); err != nil {
+		return errors.Wrap(err, "invalid --target")
+	}
+
+	sendArgs := zfs.ZFSSendArgsUnvalidated{
+		FS:        replicateArgs.source,
+		Encrypted: &zfs.NilBool{B: replicateArgs.encrypted},
+	}
+	var err error
+	if replicateArgs.from != "" {
+		var from zfs.FilesystemVersion
+		sendArgs.From, from, err = replicateVersion(ctx, replicateArgs.source, replicateArgs.from)
+		if err != nil {
+			return errors.Wrap(err, "invalid --from")
+		}
+		// the incremental source must be present on the target, otherwise recv will fail after the send has started
+		_, targetFrom, err := replicateVersion(ctx, replicateArgs.target, "@"+from.Name)
+		if err != nil {
+			return errors.Wrap(err, "--from snapshot must exist on --target")
+		}
+		if targetFrom.Guid != from.Guid {
+			return fmt.Errorf("--from snapshot %q on --target has different GUID than on --source", targetFrom.Name)
+		}
+	}
+	sendArgs.To, _, err = replicateVersion(ctx, replicateArgs.source, replicateArgs.to)
+	if err != nil {
+		return errors.Wrap(err, "invalid --to")
+	}
+	if !sendArgs.To.IsSnapshot() {
+		return errors.New("--to must be a snapshot")
+	}
+
+	validated, err := sendArgs.Validate(ctx)
+	if err != nil {
+		return errors.Wrap(err, "validate send arguments")
+	}
+
+	si, err := zfs.ZFSSendDry(ctx, validated)
+	if err != nil {
+		return errors.Wrap(err, "zfs send dry failed")
+	}
+	fmt.Printf("replicating %s%s => %s (estimated size: %d bytes)\n",
+		validated.FS, validated.ToVersion.RelName(), replicateArgs.target, si.SizeEstimate)
+	if replicateArgs.dryRun {
+		return nil
+	}
+
+	stream, err := zfs.ZFSSend(ctx, validated)
+	if err != nil {
+		return errors.Wrap(err, "zfs send failed")
+	}
+	defer stream.Close()
+
+	err = zfs.ZFSRecv(ctx, replicateArgs.target, sendArgs.To, stream, zfs.RecvOptions{})
+	if err != nil {
+		return errors.Wrap(err, "zfs recv failed")
+	}
+	fmt.Println("done")
+	return nil
+}
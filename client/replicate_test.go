@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+)
+
+func TestReplicateCmdArgs(t *testing.T) {
+	// snapshot @a has GUID 1 on pool/src and pool/dst, but GUID 2 on pool/other
+	dir, cleanup := withFakeZFS(t, `
+echo "$@" >> "$FAKEZFS_DIR/log"
+if [ "$1" != "get" ]; then
+	exit 1
+fi
+case "$6" in
+pool/other@a) guid=2 ;;
+pool/src@a|pool/src@b|pool/dst@a) guid=1 ;;
+*) echo "cannot open '$6': dataset does not exist" >&2; exit 1 ;;
+esac
+printf 'createtxg\t1\t-\nguid\t%s\t-\ncreation\t1600000000\t-\nuserrefs\t0\t-\n' "$guid"
+`)
+	defer cleanup()
+
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+
+	defer func(orig struct {
+		source, target string
+		from, to       string
+		encrypted      bool
+		dryRun         bool
+	}) {
+		replicateArgs = orig
+	}(replicateArgs)
+
+	log := filepath.Join(dir, "log")
+	run := func(source, from, to, target string) (string, error) {
+		os.Remove(log)
+		replicateArgs.source, replicateArgs.from, replicateArgs.to, replicateArgs.target = source, from, to, target
+		err := runReplicateCmd(ctx, ReplicateCmd, nil)
+		invocations, _ := ioutil.ReadFile(log)
+		return string(invocations), err
+	}
+
+	t.Run("missing --to", func(t *testing.T) {
+		invocations, err := run("pool/src", "a", "", "pool/dst")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "--to")
+		assert.Empty(t, invocations)
+	})
+
+	t.Run("source equals target", func(t *testing.T) {
+		invocations, err := run("pool/src", "a", "b", "pool/src")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must be different")
+		assert.Empty(t, invocations)
+	})
+
+	t.Run("--from GUID mismatch on target", func(t *testing.T) {
+		invocations, err := run("pool/src", "a", "b", "pool/other")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "different GUID")
+		assert.Equal(t, "get -Hp -o property,value,source createtxg,guid,creation,userrefs pool/src@a\n"+
+			"get -Hp -o property,value,source createtxg,guid,creation,userrefs pool/other@a\n", invocations,
+			"must not send")
+	})
+
+	t.Run("--from missing on target", func(t *testing.T) {
+		_, err := run("pool/src", "c", "b", "pool/dst")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid --from")
+		_, err = run("pool/src", "a", "b", "pool/missing")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "must exist on --target")
+	})
+}
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mattn/go-isatty"
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+var sendArgs struct {
+	source        string
+	from, to      string
+	raw           bool
+	options       zfs.SendOptions
+	intermediates bool
+	dryRun        bool
+}
+
+var SendCmd = &cli.Subcommand{
+	Use:             "send --source FS [--from SNAP [-I]] --to SNAP",
+	Short:           "write the send stream of a filesystem between two named snapshots to stdout, bypassing configured jobs",
+	NoRequireConfig: true,
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.StringVar(&sendArgs.source, "source", "", "the sending filesystem")
+		f.StringVar(&sendArgs.from, "from", "", "the incremental source snapshot or bookmark (full send if omitted)")
+		f.StringVar(&sendArgs.to, "to", "", "the snapshot to send")
+		f.BoolVar(&sendArgs.raw, "raw", false, "do an encrypted (raw) send")
+		f.BoolVar(&sendArgs.options.Compressed, "compressed", false, "send compressed blocks as they are on disk (implied by --raw)")
+		f.BoolVar(&sendArgs.options.LargeBlocks, "large-blocks", false, "allow blocks larger than 128KiB (implied by --raw)")
+		f.BoolVar(&sendArgs.options.EmbeddedData, "embedded-data", false, "send embedded_data blocks as such (implied by --raw)")
+		f.BoolVarP(&sendArgs.intermediates, "intermediates", "I", false, "include all snapshots between --from and --to in the stream (--from must be a snapshot)")
+		f.BoolVar(&sendArgs.dryRun, "dry-run", false, "validate the arguments and print the size estimate instead of the stream")
+	},
+	Run: runSendCmd,
+}
+
+// All output except for the stream goes to stderr, stdout is reserved for the stream.
+func runSendCmd(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+	if len(args) > 0 {
+		return errors.New("this subcommand takes no positional arguments")
+	}
+	if sendArgs.source == "" || sendArgs.to == "" {
+		return errors.New("must specify --source and --to")
+	}
+	if !sendArgs.dryRun && isatty.IsTerminal(os.Stdout.Fd()) {
+		return errors.New("refusing to write the send stream to a terminal, redirect stdout")
+	}
+
+	unvalidated := zfs.ZFSSendArgsUnvalidated{
+		FS:            sendArgs.source,
+		Encrypted:     &zfs.NilBool{B: sendArgs.raw},
+		Options:       sendArgs.options,
+		Intermediates: sendArgs.intermediates,
+	}
+	if sendArgs.intermediates && sendArgs.from == "" {
+		return errors.New("--intermediates requires --from")
+	}
+	var err error
+	if sendArgs.from != "" {
+		unvalidated.From, _, err = replicateVersion(ctx, sendArgs.source, sendArgs.from)
+		if err != nil {
+			return errors.Wrap(err, "invalid --from")
+		}
+	}
+	unvalidated.To, _, err = replicateVersion(ctx, sendArgs.source, sendArgs.to)
+	if err != nil {
+		return errors.Wrap(err, "invalid --to")
+	}
+	if !unvalidated.To.IsSnapshot() {
+		return errors.New("--to must be a snapshot")
+	}
+
+	validated, err := unvalidated.Validate(ctx)
+	if err != nil {
+		return errors.Wrap(err, "validate send arguments")
+	}
+
+	if sendArgs.dryRun {
+		si, err := zfs.ZFSSendDry(ctx, validated)
+		if err != nil {
+			return errors.Wrap(err, "zfs send dry failed")
+		}
+		fmt.Printf("type: %s\n", si.Type)
+		fmt.Printf("from: %s\n", si.From)
+		fmt.Printf("to: %s\n", si.To)
+		fmt.Printf("size_estimate: %d\n", si.SizeEstimate)
+		return nil
+	}
+
+	stream, err := zfs.ZFSSend(ctx, validated)
+	if err != nil {
+		return errors.Wrap(err, "zfs send failed")
+	}
+	defer stream.Close()
+
+	// the stream returns the zfs send error if zfs send exits non-zero
+	n, err := io.Copy(os.Stdout, stream)
+	if err != nil {
+		return errors.Wrapf(err, "send failed after %d bytes", n)
+	}
+	fmt.Fprintf(os.Stderr, "sent %s%s (%d bytes)\n", validated.FS, validated.ToVersion.RelName(), n)
+	return nil
+}
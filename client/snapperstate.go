@@ -0,0 +1,33 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/daemon"
+)
+
+var SnapperStateCmd = &cli.Subcommand{
+	Use:   "snapper-state JOB",
+	Short: "dump the internal state of JOB's snapper (for bug reports)",
+	Run: func(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+		if len(args) != 1 {
+			return errors.Errorf("Expected 1 argument: JOB")
+		}
+
+		httpc, err := controlHttpClient(subcommand.Config().Global.Control.SockPath)
+		if err != nil {
+			return err
+		}
+
+		var dump string
+		if err := jsonRequestResponse(httpc, daemon.ControlJobEndpointSnapperDumpState, args[0], &dump); err != nil {
+			return err
+		}
+		fmt.Print(dump)
+		return nil
+	},
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/zrepl/zrepl/daemon/job"
 	"github.com/zrepl/zrepl/daemon/pruner"
 	"github.com/zrepl/zrepl/daemon/snapper"
+	"github.com/zrepl/zrepl/endpoint"
 	"github.com/zrepl/zrepl/replication/report"
 )
 
@@ -327,23 +328,22 @@ func (t *tui) draw() {
 					continue
 				}
 
-				t.printf("Replication:")
-				t.newline()
-				t.addIndent(1)
-				t.renderReplicationReport(activeStatus.Replication, t.getReplicationProgressHistory(k))
-				t.addIndent(-1)
-
-				t.printf("Pruning Sender:")
-				t.newline()
-				t.addIndent(1)
-				t.renderPrunerReport(activeStatus.PruningSender)
-				t.addIndent(-1)
-
-				t.printf("Pruning Receiver:")
-				t.newline()
-				t.addIndent(1)
-				t.renderPrunerReport(activeStatus.PruningReceiver)
-				t.addIndent(-1)
+				if len(activeStatus.Targets) > 0 {
+					targets := make([]string, 0, len(activeStatus.Targets))
+					for target := range activeStatus.Targets {
+						targets = append(targets, target)
+					}
+					sort.Strings(targets)
+					for _, target := range targets {
+						t.printf("Target: %s", target)
+						t.newline()
+						t.addIndent(1)
+						t.renderActiveSideTasks(activeStatus.Targets[target], k+"/"+target)
+						t.addIndent(-1)
+					}
+				} else {
+					t.renderActiveSideTasks(activeStatus, k)
+				}
 
 				if v.Type == job.TypePush {
 					t.printf("Snapshotting:")
@@ -370,9 +370,26 @@ func (t *tui) draw() {
 				t.addIndent(1)
 				t.renderSnapperReport(snapStatus.Snapshotting)
 				t.addIndent(-1)
+			} else if v.Type == job.TypeDisabled {
+				st, ok := v.JobSpecific.(*job.DisabledJobStatus)
+				if !ok || st == nil {
+					t.printf("DisabledJobStatus is null")
+					t.newline()
+					continue
+				}
+				if st.ConfiguredType != "" {
+					t.printf("Configured Type: %s", st.ConfiguredType)
+					t.newline()
+				}
+				t.printfDrawIndentedAndWrappedIfMultiline("Disabled: %s", st.Err)
+				t.newline()
 			} else if v.Type == job.TypeSource {
 
 				st := v.JobSpecific.(*job.PassiveStatus)
+				t.printf("Sends:\n")
+				t.addIndent(1)
+				t.renderSendsProgress(st.Sends)
+				t.addIndent(-1)
 				t.printf("Snapshotting:\n")
 				t.addIndent(1)
 				t.renderSnapperReport(st.Snapper)
@@ -396,6 +413,38 @@ func (t *tui) draw() {
 	termbox.Flush()
 }
 
+// historyKey identifies the replication progress history of the job (or push target)
+func (t *tui) renderActiveSideTasks(activeStatus *job.ActiveSideStatus, historyKey string) {
+	if activeStatus == nil {
+		t.printf("ActiveSideStatus is null")
+		t.newline()
+		return
+	}
+
+	if activeStatus.TimedOutAfter > 0 {
+		t.printf("TIMED OUT: the invocation was cancelled after max_run_duration (%s)", activeStatus.TimedOutAfter)
+		t.newline()
+	}
+
+	t.printf("Replication:")
+	t.newline()
+	t.addIndent(1)
+	t.renderReplicationReport(activeStatus.Replication, t.getReplicationProgressHistory(historyKey))
+	t.addIndent(-1)
+
+	t.printf("Pruning Sender:")
+	t.newline()
+	t.addIndent(1)
+	t.renderPrunerReport(activeStatus.PruningSender)
+	t.addIndent(-1)
+
+	t.printf("Pruning Receiver:")
+	t.newline()
+	t.addIndent(1)
+	t.renderPrunerReport(activeStatus.PruningReceiver)
+	t.addIndent(-1)
+}
+
 func (t *tui) renderReplicationReport(rep *report.Report, history *bytesProgressHistory) {
 	if rep == nil {
 		t.printf("...\n")
@@ -479,6 +528,12 @@ func (t *tui) renderReplicationReport(rep *report.Report, history *bytesProgress
 			t.write("NOTE: not all steps could be size-estimated, total estimate is likely imprecise!")
 			t.newline()
 		}
+		if rpc := rep.RPC; rpc != nil {
+			t.printf("RPC: %d requests (%d failed), %s waiting for responses, %s waiting for stream data, streams sent %s / received %s",
+				rpc.Requests, rpc.Errors, rpc.RequestTime.Round(time.Millisecond), rpc.StreamReadTime.Round(time.Millisecond),
+				ByteCountBinary(rpc.StreamBytesSent), ByteCountBinary(rpc.StreamBytesReceived))
+			t.newline()
+		}
 
 		var maxFSLen int
 		for _, fs := range latest.Filesystems {
@@ -494,6 +549,22 @@ func (t *tui) renderReplicationReport(rep *report.Report, history *bytesProgress
 
 }
 
+func (t *tui) renderSendsProgress(sends []endpoint.SendProgress) {
+	if len(sends) == 0 {
+		t.printf("no sends in progress")
+		t.newline()
+		return
+	}
+	for _, s := range sends {
+		expected := "?"
+		if s.ExpectedSize > 0 {
+			expected = ByteCountBinary(s.ExpectedSize)
+		}
+		t.printf("%s%s %s/%s", s.Filesystem, s.To, ByteCountBinary(s.BytesSent), expected)
+		t.newline()
+	}
+}
+
 func (t *tui) renderPrunerReport(r *pruner.Report) {
 	if r == nil {
 		t.printf("...\n")
@@ -543,6 +614,8 @@ func (t *tui) renderPrunerReport(r *pruner.Report) {
 		totalDestroyCount += len(fs.DestroyList)
 		if fs.completed {
 			completedDestroyCount += len(fs.DestroyList)
+		} else {
+			completedDestroyCount += fs.Destroyed
 		}
 		if maxFSname < len(fs.Filesystem) {
 			maxFSname = len(fs.Filesystem)
@@ -592,7 +665,9 @@ func (t *tui) renderPrunerReport(r *pruner.Report) {
 		}
 
 		t.write("Pending    ") // whitespace is padding 10
-		if len(fs.DestroyList) == 1 {
+		if fs.Destroyed > 0 {
+			t.printf("(destroyed %d of %d snapshots)", fs.Destroyed, len(fs.DestroyList))
+		} else if len(fs.DestroyList) == 1 {
 			t.write(fs.DestroyList[0].Name)
 		} else {
 			t.write(pruneRuleActionStr)
@@ -655,6 +730,15 @@ func (t *tui) renderSnapperReport(r *snapper.Report) {
 		case snapper.SnapError:
 			r.duration = dur(fs.DoneAt.Sub(fs.StartAt))
 			r.remainder = fmt.Sprintf("snap name: %q", fs.SnapName)
+			if fs.Error != "" {
+				r.remainder += fmt.Sprintf(" error: %s", fs.Error)
+			}
+		case snapper.SnapIncomplete:
+			r.duration = "-"
+			r.remainder = "not snapshotted: round exceeded max_cycle_duration"
+		case snapper.SnapSkipped:
+			r.duration = "-"
+			r.remainder = "not snapshotted: unchanged since latest snapshot"
 		}
 		rows[i] = r
 		if len(r.path) > widths.path {
@@ -728,10 +812,23 @@ func (t *tui) printFilesystemStatus(rep *report.FilesystemReport, active bool, m
 		sizeEstimationImpreciseNotice = " (step lacks size estimation)"
 	}
 
-	status := fmt.Sprintf("%s (step %d/%d, %s/%s)%s",
+	lag := ""
+	if l := rep.Info.ReplicationLag; l != nil {
+		switch {
+		case *l == report.ReplicationLagNeverReplicated:
+			lag = ", never replicated"
+		case *l < time.Second:
+			lag = ", caught up"
+		default:
+			lag = fmt.Sprintf(", lag %s", humanizeDuration(*l))
+		}
+	}
+
+	status := fmt.Sprintf("%s (step %d/%d, %s/%s%s)%s",
 		strings.ToUpper(string(rep.State)),
 		rep.CurrentStep, len(rep.Steps),
 		ByteCountBinary(replicated), ByteCountBinary(expected),
+		lag,
 		sizeEstimationImpreciseNotice,
 	)
 
@@ -745,12 +842,16 @@ func (t *tui) printFilesystemStatus(rep *report.FilesystemReport, active bool, m
 		status)
 
 	next := ""
-	if err := rep.Error(); err != nil {
+	if summary := rep.StepFailureSummary(); summary != "" {
+		next = summary
+	} else if err := rep.Error(); err != nil {
 		next = err.Err
 	} else if rep.State != report.FilesystemDone {
 		if nextStep := rep.NextStep(); nextStep != nil {
 			if nextStep.IsIncremental() {
 				next = fmt.Sprintf("next: %s => %s", nextStep.Info.From, nextStep.Info.To)
+			} else if nextStep.Info.FromOrigin != "" {
+				next = fmt.Sprintf("next: clone %s => %s", nextStep.Info.FromOrigin, nextStep.Info.To)
 			} else {
 				next = fmt.Sprintf("next: full send %s", nextStep.Info.To)
 			}
@@ -759,6 +860,9 @@ func (t *tui) printFilesystemStatus(rep *report.FilesystemReport, active bool, m
 			if nextStep.Info.Resumed {
 				attribs = append(attribs, "resumed")
 			}
+			if nextStep.Info.FromBookmark {
+				attribs = append(attribs, "from bookmark")
+			}
 
 			attribs = append(attribs, fmt.Sprintf("encrypted=%s", nextStep.Info.Encrypted))
 
@@ -18,7 +18,7 @@ import (
 var TestCmd = &cli.Subcommand{
 	Use: "test",
 	SetupSubcommands: func() []*cli.Subcommand {
-		return []*cli.Subcommand{testFilter, testPlaceholder, testDecodeResumeToken}
+		return []*cli.Subcommand{testFilter, testPlaceholder, testDecodeResumeToken, testConnectivity, testTemplate}
 	},
 }
 
@@ -51,22 +51,23 @@ func runTestFilterCmd(ctx context.Context, subcommand *cli.Subcommand, args []st
 	conf := subcommand.Config()
 
 	var confFilter config.FilesystemsFilter
+	var confPropFilter *config.FilesystemPropertyFilter
 	job, err := conf.Job(testFilterArgs.job)
 	if err != nil {
 		return err
 	}
 	switch j := job.Ret.(type) {
 	case *config.SourceJob:
-		confFilter = j.Filesystems
+		confFilter, confPropFilter = j.Filesystems, j.FilesystemProperty
 	case *config.PushJob:
-		confFilter = j.Filesystems
+		confFilter, confPropFilter = j.Filesystems, j.FilesystemProperty
 	case *config.SnapJob:
-		confFilter = j.Filesystems
+		confFilter, confPropFilter = j.Filesystems, j.FilesystemProperty
 	default:
 		return fmt.Errorf("job type %T does not have filesystems filter", j)
 	}
 
-	f, err := filters.DatasetMapFilterFromConfig(confFilter)
+	f, err := filters.FilesystemsFilterFromConfig(confFilter, confPropFilter)
 	if err != nil {
 		return fmt.Errorf("filter invalid: %s", err)
 	}
@@ -94,11 +95,34 @@ func runTestFilterCmd(ctx context.Context, subcommand *cli.Subcommand, args []st
 		fspaths[i] = path
 	}
 
+	filter := f.Filter
+	if pf, ok := f.(zfs.DatasetPropertyFilter); ok {
+		var datasets []string // all
+		if testFilterArgs.input != "" {
+			datasets = fsnames
+		}
+		vals, err := zfs.ZFSGetPropertyValues(ctx, pf.FilterProperties(), datasets...)
+		if err != nil {
+			return fmt.Errorf("could not get ZFS properties for filter: %s", err)
+		}
+		filter = func(p *zfs.DatasetPath) (bool, error) {
+			return pf.FilterWithProperties(p, vals[p.ToString()])
+		}
+	}
+
+	// the pattern that decided about each filesystem, independent of filesystem_property
+	patterns := make(map[string]string, len(fspaths))
+	if names, ok := filters.NameFilter(f).(*filters.DatasetMapFilter); ok {
+		for _, d := range names.Explain(fspaths) {
+			patterns[d.Path.ToString()] = d.Pattern
+		}
+	}
+
 	hadFilterErr := false
 	for _, in := range fspaths {
 		var res string
 		var errStr string
-		pass, err := f.Filter(in)
+		pass, err := filter(in)
 		if err != nil {
 			res = "ERROR"
 			errStr = err.Error()
@@ -108,7 +132,11 @@ func runTestFilterCmd(ctx context.Context, subcommand *cli.Subcommand, args []st
 		} else {
 			res = "REJECT"
 		}
-		fmt.Printf("%s\t%s\t%s\n", res, in.ToString(), errStr)
+		pattern := "no matching pattern"
+		if p := patterns[in.ToString()]; p != "" {
+			pattern = fmt.Sprintf("pattern %q", p)
+		}
+		fmt.Printf("%s\t%s\t%s\t%s\n", res, in.ToString(), pattern, errStr)
 	}
 
 	if hadFilterErr {
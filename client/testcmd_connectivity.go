@@ -0,0 +1,161 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/logging"
+	"github.com/zrepl/zrepl/logger"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+	"github.com/zrepl/zrepl/rpc"
+	"github.com/zrepl/zrepl/rpc/versionhandshake"
+	"github.com/zrepl/zrepl/transport"
+	"github.com/zrepl/zrepl/transport/fromconfig"
+)
+
+var testConnectivityArgs struct {
+	job     string
+	timeout time.Duration
+	verbose bool
+}
+
+var testConnectivity = &cli.Subcommand{
+	Use:   "connectivity --job JOB",
+	Short: "test the connection of a push or pull job to its passive side",
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.StringVar(&testConnectivityArgs.job, "job", "", "the name of the push or pull job")
+		f.DurationVar(&testConnectivityArgs.timeout, "timeout", 30*time.Second, "timeout for each step of the test")
+		f.BoolVar(&testConnectivityArgs.verbose, "verbose", false, "log rpc debug messages to stderr")
+	},
+	Run: runTestConnectivityCmd,
+}
+
+func runTestConnectivityCmd(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+
+	if testConnectivityArgs.job == "" {
+		return fmt.Errorf("must specify --job flag")
+	}
+	if testConnectivityArgs.timeout <= 0 {
+		return fmt.Errorf("--timeout must be positive")
+	}
+
+	conf := subcommand.Config()
+	job, err := conf.Job(testConnectivityArgs.job)
+	if err != nil {
+		return err
+	}
+	var connect config.ConnectEnum
+	switch j := job.Ret.(type) {
+	case *config.PushJob:
+		connect = j.Connect
+	case *config.PullJob:
+		connect = j.Connect
+	default:
+		return fmt.Errorf("job type %T does not connect to a passive side", j)
+	}
+
+	// The local transport's listener lives inside the daemon process,
+	// so the only thing we can check from here is the wiring in the config.
+	if lc, ok := connect.Ret.(*config.LocalConnect); ok {
+		return testLocalConnectWiring(conf, testConnectivityArgs.job, lc)
+	}
+
+	connecter, err := fromconfig.ConnecterFromConfig(conf.Global, connect)
+	if err != nil {
+		return errors.Wrap(err, "cannot build connecter")
+	}
+
+	var l logger.Logger = logger.NewNullLogger()
+	if testConnectivityArgs.verbose {
+		l = logger.NewStderrDebugLogger()
+	}
+	ctx = logging.WithLoggers(ctx, logging.SubsystemLoggersWithUniversalLogger(l))
+
+	// connect + version handshake, done separately from the rpc client
+	// because the latter only logs (and retries) connection errors
+	begin := time.Now()
+	err = testConnectivityConnect(ctx, connecter)
+	if err != nil {
+		fmt.Printf("FAIL\tconnect\t%s\n", err)
+		return fmt.Errorf("connectivity test failed")
+	}
+	fmt.Printf("OK\tconnect\t%s\n", time.Since(begin))
+
+	client := rpc.NewClient(connecter, rpc.GetLoggersOrPanic(ctx))
+	defer client.Close()
+
+	pingCtx, cancel := context.WithTimeout(ctx, testConnectivityArgs.timeout)
+	defer cancel()
+	begin = time.Now()
+	if err := client.WaitForConnectivity(pingCtx); err != nil {
+		if pingCtx.Err() != nil {
+			err = errors.Wrap(pingCtx.Err(), "control or data connection did not respond")
+		}
+		fmt.Printf("FAIL\tping\t%s\n", err)
+		return fmt.Errorf("connectivity test failed")
+	}
+	fmt.Printf("OK\tping\t%s\n", time.Since(begin))
+
+	listCtx, cancel := context.WithTimeout(ctx, testConnectivityArgs.timeout)
+	defer cancel()
+	begin = time.Now()
+	res, err := client.ListFilesystems(listCtx, &pdu.ListFilesystemReq{})
+	if err != nil {
+		fmt.Printf("FAIL\tlist-filesystems\t%s\n", err)
+		return fmt.Errorf("connectivity test failed")
+	}
+	fmt.Printf("OK\tlist-filesystems\t%s\t(%d filesystems)\n", time.Since(begin), len(res.GetFilesystems()))
+
+	return nil
+}
+
+func testConnectivityConnect(ctx context.Context, connecter transport.Connecter) error {
+	ctx, cancel := context.WithTimeout(ctx, testConnectivityArgs.timeout)
+	defer cancel()
+	wire, err := versionhandshake.Connecter(connecter, testConnectivityArgs.timeout).Connect(ctx)
+	if err != nil {
+		return err
+	}
+	return wire.Close()
+}
+
+func testLocalConnectWiring(conf *config.Config, jobName string, lc *config.LocalConnect) error {
+	if lc.ClientIdentity == "" {
+		return fmt.Errorf("job %q: connect.client_identity must not be empty", jobName)
+	}
+	var servers []string
+	for _, j := range conf.Jobs {
+		var serve config.ServeEnum
+		switch v := j.Ret.(type) {
+		case *config.SinkJob:
+			serve = v.Serve
+		case *config.SourceJob:
+			serve = v.Serve
+		default:
+			continue
+		}
+		ls, ok := serve.Ret.(*config.LocalServe)
+		if !ok || ls.ListenerName != lc.ListenerName {
+			continue
+		}
+		servers = append(servers, j.Name())
+	}
+	switch len(servers) {
+	case 0:
+		fmt.Printf("FAIL\tlocal-wiring\tno sink or source job serves local listener %q\n", lc.ListenerName)
+		return fmt.Errorf("connectivity test failed")
+	case 1:
+		fmt.Printf("OK\tlocal-wiring\tlistener %q is served by job %q\n", lc.ListenerName, servers[0])
+		fmt.Printf("the local transport only works within the daemon, the connection itself has not been tested\n")
+		return nil
+	default:
+		fmt.Printf("FAIL\tlocal-wiring\tlistener %q is served by multiple jobs: %v\n", lc.ListenerName, servers)
+		return fmt.Errorf("connectivity test failed")
+	}
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+
+	"github.com/zrepl/zrepl/cli"
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/snapper"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+var testTemplateArgs struct {
+	job        string
+	filesystem string
+	time       string
+}
+
+var testTemplate = &cli.Subcommand{
+	Use:   "template --job JOB [--filesystem FS] [--time TIME]",
+	Short: "preview the snapshot names produced by the snapshotting config of a job",
+	Example: `
+	template --job backup
+	template --job backup --filesystem pool/some/long/dataset/name --time 2020-12-31T23:59:59Z`,
+	SetupFlags: func(f *pflag.FlagSet) {
+		f.StringVar(&testTemplateArgs.job, "job", "", "the name of the job with periodic or cron snapshotting")
+		f.StringVar(&testTemplateArgs.filesystem, "filesystem", "pool/dataset", "filesystem for the full-path example (not required to exist)")
+		f.StringVar(&testTemplateArgs.time, "time", "", "snapshot time in RFC3339 format (default: now)")
+	},
+	Run: runTestTemplateCmd,
+}
+
+func runTestTemplateCmd(ctx context.Context, subcommand *cli.Subcommand, args []string) error {
+
+	if testTemplateArgs.job == "" {
+		return fmt.Errorf("must specify --job flag")
+	}
+	at := time.Now()
+	if testTemplateArgs.time != "" {
+		var err error
+		at, err = time.Parse(time.RFC3339, testTemplateArgs.time)
+		if err != nil {
+			return errors.Wrap(err, "invalid --time")
+		}
+	}
+
+	conf := subcommand.Config()
+	job, err := conf.Job(testTemplateArgs.job)
+	if err != nil {
+		return err
+	}
+	var snapshotting config.SnapshottingEnum
+	switch j := job.Ret.(type) {
+	case *config.PushJob:
+		snapshotting = j.Snapshotting
+	case *config.SourceJob:
+		snapshotting = j.Snapshotting
+	case *config.SnapJob:
+		snapshotting = j.Snapshotting
+	default:
+		return fmt.Errorf("job type %T does not create snapshots", j)
+	}
+	naming, ok := snapshotting.Naming()
+	if !ok {
+		return fmt.Errorf("job %q uses manual snapshotting, zrepl does not name its snapshots", testTemplateArgs.job)
+	}
+
+	res, err := renderSnapshotNameTemplate(naming, testTemplateArgs.filesystem, at)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("snapshot name:\t%s\n", res.name)
+	fmt.Printf("full path:\t%s\n", res.fullPath)
+	for _, p := range res.problems {
+		fmt.Printf("INVALID:\t%s\n", p)
+	}
+	if len(res.problems) > 0 {
+		return fmt.Errorf("snapshot name template produces invalid names")
+	}
+	return nil
+}
+
+type renderedSnapshotName struct {
+	name     string // without filesystem
+	fullPath string
+	problems []string
+}
+
+// renderSnapshotNameTemplate renders the snapshot name that the snapshotting config in produces
+// for a snapshot of fs taken at t, and checks it against ZFS naming rules (including length limits).
+// An error is returned if the config itself is invalid, problems of the rendered name are reported in the result.
+func renderSnapshotNameTemplate(in config.SnapshotNaming, fs string, t time.Time) (*renderedSnapshotName, error) {
+	if in.Prefix == "" {
+		return nil, errors.New("invalid snapshotting config: prefix must not be empty")
+	}
+	format, err := snapper.TimestampFormatFromConfig(in)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid snapshotting config")
+	}
+	res := &renderedSnapshotName{name: format.SnapshotName(in.Prefix, t)}
+	res.fullPath = fmt.Sprintf("%s@%s", fs, res.name)
+
+	if err := zfs.EntityNamecheck(fs, zfs.EntityTypeFilesystem); err != nil {
+		res.problems = append(res.problems, fmt.Sprintf("filesystem: %s", err))
+	}
+	if err := zfs.EntityNamecheck(res.fullPath, zfs.EntityTypeSnapshot); err != nil {
+		res.problems = append(res.problems, fmt.Sprintf("full path: %s", err))
+	}
+	if !format.IsSnapshotName(in.Prefix, res.name) {
+		res.problems = append(res.problems, "the snapshotter cannot parse the timestamp of the name, it would not recognize its own snapshots")
+	}
+	return res, nil
+}
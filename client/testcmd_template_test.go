@@ -0,0 +1,36 @@
+package client
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+)
+
+func TestRenderSnapshotNameTemplate(t *testing.T) {
+	at := time.Date(2020, time.December, 31, 23, 59, 58, 0, time.UTC)
+	periodic := func(prefix, format string) config.SnapshotNaming {
+		return (&config.SnapshottingPeriodic{Prefix: prefix, TimestampFormat: format, TimestampLocation: "UTC"}).Naming()
+	}
+
+	res, err := renderSnapshotNameTemplate(periodic("zrepl_", "2006-01-02_15:04:05"), "pool/fs", at)
+	require.NoError(t, err)
+	assert.Equal(t, "zrepl_2020-12-31_23:59:58", res.name)
+	assert.Equal(t, "pool/fs@zrepl_2020-12-31_23:59:58", res.fullPath)
+	assert.Empty(t, res.problems)
+
+	// the name is valid on its own, but the full path exceeds the length limit
+	res, err = renderSnapshotNameTemplate(periodic("zrepl_", "2006-01-02_15:04:05"), "pool/"+strings.Repeat("a", 240), at)
+	require.NoError(t, err)
+	require.Len(t, res.problems, 1)
+	assert.Contains(t, res.problems[0], "too long")
+
+	_, err = renderSnapshotNameTemplate(periodic("zrepl_", "static"), "pool/fs", at)
+	assert.Error(t, err)
+	_, err = renderSnapshotNameTemplate(periodic("", "2006"), "pool/fs", at)
+	assert.Error(t, err)
+}
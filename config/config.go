@@ -54,9 +54,11 @@ func (j JobEnum) Name() string {
 type ActiveJob struct {
 	Type    string                `yaml:"type"`
 	Name    string                `yaml:"name"`
-	Connect ConnectEnum           `yaml:"connect"`
+	Connect ConnectEnum           `yaml:"connect,optional"` // required unless a push job specifies targets
 	Pruning PruningSenderReceiver `yaml:"pruning"`
 	Debug   JobDebugSettings      `yaml:"debug,optional"`
+	// Upper bound for the duration of an invocation (replication and pruning). 0 means unlimited.
+	MaxRunDuration time.Duration `yaml:"max_run_duration,optional,zeropositive"`
 }
 
 type PassiveJob struct {
@@ -73,15 +75,47 @@ type SnapJob struct {
 	Debug        JobDebugSettings  `yaml:"debug,optional"`
 	Snapshotting SnapshottingEnum  `yaml:"snapshotting"`
 	Filesystems  FilesystemsFilter `yaml:"filesystems"`
+
+	FilesystemProperty *FilesystemPropertyFilter `yaml:"filesystem_property,optional"`
 }
 
 type SendOptions struct {
 	Encrypted bool                 `yaml:"encrypted"`
 	StepHolds SendOptionsStepHolds `yaml:"step_holds,optional"`
+	Tee       *SendOptionsTee      `yaml:"tee,optional"`
+	// Only for unencrypted sends, encrypted sends are raw sends which imply them.
+	Compressed   bool `yaml:"compressed,optional,default=false"`
+	LargeBlocks  bool `yaml:"large_blocks,optional,default=false"`
+	EmbeddedData bool `yaml:"embedded_data,optional,default=false"`
+	// Limit the bandwidth of send streams depending on the IO of the sending pool.
+	PoolIOThrottle *SendOptionsPoolIOThrottle `yaml:"pool_io_throttle,optional"`
+	// Record the time and snapshot of the last replication in the zrepl:last_replicated property.
+	LastReplicatedProperty bool `yaml:"last_replicated_property,optional,default=false"`
+}
+
+// Rates and thresholds are in bytes per second.
+type SendOptionsPoolIOThrottle struct {
+	MaxRate       int64         `yaml:"max_rate"`
+	MinRate       int64         `yaml:"min_rate"`
+	IdleThreshold int64         `yaml:"idle_threshold"`
+	BusyThreshold int64         `yaml:"busy_threshold"`
+	Interval      time.Duration `yaml:"interval,optional,positive,default=10s"`
+}
+
+type SendOptionsTee struct {
+	Directory   string                     `yaml:"directory"`
+	Compression *SendOptionsTeeCompression `yaml:"compression,optional"`
+}
+
+type SendOptionsTeeCompression struct {
+	Codec string `yaml:"codec"`
+	// 0 means the codec's default level
+	Level int `yaml:"level,optional"`
 }
 
 type SendOptionsStepHolds struct {
-	DisableIncremental bool `yaml:"disable_incremental,optional"`
+	DisableIncremental    bool `yaml:"disable_incremental,optional"`
+	ReleaseStaleOnStartup bool `yaml:"release_stale_on_startup,optional"`
 }
 
 var _ yaml.Defaulter = (*SendOptions)(nil)
@@ -96,6 +130,37 @@ type RecvOptions struct {
 
 	// Future:
 	// Reencrypt bool `yaml:"reencrypt"`
+
+	StaleResumeState *RecvOptionsStaleResumeState `yaml:"stale_resume_state,optional"`
+
+	// permit root_fs to overlap with filesystems sent by other jobs on this host
+	AllowSourceOverlap bool `yaml:"allow_source_overlap,optional,default=false"`
+
+	EncryptionRoot *RecvOptionsEncryptionRoot `yaml:"encryption_root,optional"`
+
+	Properties *RecvOptionsProperties `yaml:"properties,optional"`
+
+	// Maps patterns of received filesystem names to their names below the receiver's root,
+	// with the syntax of DatasetMapFilter mappings.
+	Rename map[string]string `yaml:"rename,optional"`
+}
+
+type RecvOptionsProperties struct {
+	// Passed to zfs recv as -o key=value.
+	Override map[string]string `yaml:"override,optional"`
+	// Passed to zfs recv as -x key.
+	Exclude []string `yaml:"exclude,optional"`
+}
+
+type RecvOptionsEncryptionRoot struct {
+	Action      string `yaml:"action"`
+	KeyLocation string `yaml:"keylocation,optional"`
+	KeyFormat   string `yaml:"keyformat,optional"`
+}
+
+type RecvOptionsStaleResumeState struct {
+	Action    string        `yaml:"action"`
+	OlderThan time.Duration `yaml:"older_than,optional,zeropositive,default=24h"`
 }
 
 var _ yaml.Defaulter = (*RecvOptions)(nil)
@@ -109,6 +174,17 @@ type PushJob struct {
 	Snapshotting SnapshottingEnum  `yaml:"snapshotting"`
 	Filesystems  FilesystemsFilter `yaml:"filesystems"`
 	Send         *SendOptions      `yaml:"send,fromdefaults,optional"`
+
+	FilesystemProperty *FilesystemPropertyFilter `yaml:"filesystem_property,optional"`
+	// mutually exclusive with Connect
+	Targets []*PushTarget `yaml:"targets,optional"`
+}
+
+type PushTarget struct {
+	Name    string      `yaml:"name"`
+	Connect ConnectEnum `yaml:"connect"`
+	// nil means replication after every snapshotting round
+	Interval *PositiveDurationOrManual `yaml:"interval,optional"`
 }
 
 type PullJob struct {
@@ -116,6 +192,13 @@ type PullJob struct {
 	RootFS    string                   `yaml:"root_fs"`
 	Interval  PositiveDurationOrManual `yaml:"interval"`
 	Recv      *RecvOptions             `yaml:"recv,fromdefaults,optional"`
+
+	TopologySync *TopologySync `yaml:"topology_sync,optional"`
+}
+
+type TopologySync struct {
+	DestroyOrphaned bool `yaml:"destroy_orphaned,optional,default=false"`
+	DryRun          bool `yaml:"dry_run,optional,default=false"`
 }
 
 type PositiveDurationOrManual struct {
@@ -160,10 +243,22 @@ type SourceJob struct {
 	Snapshotting SnapshottingEnum  `yaml:"snapshotting"`
 	Filesystems  FilesystemsFilter `yaml:"filesystems"`
 	Send         *SendOptions      `yaml:"send,optional,fromdefaults"`
+
+	FilesystemProperty *FilesystemPropertyFilter `yaml:"filesystem_property,optional"`
 }
 
 type FilesystemsFilter map[string]bool
 
+// Restricts the filesystems matched by a FilesystemsFilter to those with a ZFS property value.
+// Exactly one of Value and Regex must be set.
+type FilesystemPropertyFilter struct {
+	Name  string `yaml:"name"`
+	Value string `yaml:"value,optional"`
+	Regex string `yaml:"regex,optional"`
+	// If false, only values set locally on the filesystem match.
+	Inherit bool `yaml:"inherit,optional,default=true"`
+}
+
 type SnapshottingEnum struct {
 	Ret interface{}
 }
@@ -173,6 +268,84 @@ type SnapshottingPeriodic struct {
 	Prefix   string        `yaml:"prefix"`
 	Interval time.Duration `yaml:"interval,positive"`
 	Hooks    HookList      `yaml:"hooks,optional"`
+	// The part of the snapshot name after Prefix, a Go time layout, formatted in TimestampLocation
+	// ("UTC", "Local" or an IANA time zone name).
+	TimestampFormat   string `yaml:"timestamp_format,optional,default=20060102_150405_000"`
+	TimestampLocation string `yaml:"timestamp_location,optional,default=UTC"`
+	// If not empty, only these datasets are snapshotted instead of all datasets matched by the job's filesystems filter.
+	Datasets []string `yaml:"datasets,optional"`
+	Verify   bool     `yaml:"verify,optional,default=false"`
+	// Align snapshotting rounds to wall-clock multiples of Interval instead of the time of the previous snapshot.
+	AlignToWallclock bool `yaml:"align_to_wallclock,optional,default=false"`
+
+	// The first override whose filesystems filter matches a dataset determines its interval instead of Interval.
+	IntervalOverrides []SnapshottingIntervalOverride `yaml:"interval_overrides,optional"`
+
+	AdaptiveInterval *SnapshottingAdaptiveInterval `yaml:"adaptive_interval,optional"`
+
+	// Upper bound for the duration of a snapshotting round. 0 means unlimited.
+	MaxCycleDuration time.Duration `yaml:"max_cycle_duration,optional"`
+
+	// If > 0, each filesystem is snapshotted at a random offset in [0, Jitter) after the start of a round.
+	Jitter time.Duration `yaml:"jitter,optional"`
+
+	// Do not snapshot filesystems that have not been written to since their latest snapshot.
+	SkipUnchanged bool `yaml:"skip_unchanged,optional,default=false"`
+
+	// Snapshot new filesystems without a snapshot with Prefix while waiting for the next round.
+	InitialSnapshot bool `yaml:"initial_snapshot,optional,default=false"`
+
+	// Datasets with this property set to "off" are not snapshotted. Empty disables the check.
+	SnapshotProperty        string `yaml:"snapshot_property,optional,default=zrepl:snapshot"`
+	SnapshotPropertyInherit bool   `yaml:"snapshot_property_inherit,optional,default=false"`
+
+	// Snapshot subtrees whose datasets are all snapshotted in a round atomically using `zfs snapshot -r`.
+	Recursive bool `yaml:"recursive,optional,default=false"`
+
+	// "any", "mounted" or "unmounted": only snapshot filesystems with a matching `mounted` property.
+	Mounted string `yaml:"mounted,optional,default=any"`
+
+	// The maximum number of filesystems that are snapshotted (including their hooks) concurrently.
+	Concurrency int `yaml:"concurrency,optional,default=1"`
+	// If Concurrency > 1, still snapshot the filesystems of a pool one after another.
+	SerializePerPool bool `yaml:"serialize_per_pool,optional,default=true"`
+}
+
+// SnapshottingCron is like SnapshottingPeriodic, but snapshots are taken at the fire times of a cron expression.
+type SnapshottingCron struct {
+	Type   string `yaml:"type"`
+	Prefix string `yaml:"prefix"`
+	// minute hour day-of-month month day-of-week, evaluated in CronLocation ("UTC", "Local" or an IANA time zone name)
+	Cron         string   `yaml:"cron"`
+	CronLocation string   `yaml:"cron_location,optional,default=Local"`
+	Hooks        HookList `yaml:"hooks,optional"`
+
+	TimestampFormat   string   `yaml:"timestamp_format,optional,default=20060102_150405_000"`
+	TimestampLocation string   `yaml:"timestamp_location,optional,default=UTC"`
+	Datasets          []string `yaml:"datasets,optional"`
+	Verify            bool     `yaml:"verify,optional,default=false"`
+
+	MaxCycleDuration time.Duration `yaml:"max_cycle_duration,optional"`
+	SkipUnchanged    bool          `yaml:"skip_unchanged,optional,default=false"`
+	InitialSnapshot  bool          `yaml:"initial_snapshot,optional,default=false"`
+
+	SnapshotProperty        string `yaml:"snapshot_property,optional,default=zrepl:snapshot"`
+	SnapshotPropertyInherit bool   `yaml:"snapshot_property_inherit,optional,default=false"`
+
+	Mounted string `yaml:"mounted,optional,default=any"`
+
+	Concurrency      int  `yaml:"concurrency,optional,default=1"`
+	SerializePerPool bool `yaml:"serialize_per_pool,optional,default=true"`
+}
+
+type SnapshottingIntervalOverride struct {
+	Filesystems FilesystemsFilter `yaml:"filesystems"`
+	Interval    time.Duration     `yaml:"interval,positive"`
+}
+
+type SnapshottingAdaptiveInterval struct {
+	GrowthFactor float64       `yaml:"growth_factor,optional,default=2"`
+	MaxInterval  time.Duration `yaml:"max_interval,positive"`
 }
 
 type SnapshottingManual struct {
@@ -180,12 +353,26 @@ type SnapshottingManual struct {
 }
 
 type PruningSenderReceiver struct {
-	KeepSender   []PruningEnum `yaml:"keep_sender"`
-	KeepReceiver []PruningEnum `yaml:"keep_receiver"`
+	KeepSender   []PruningEnum   `yaml:"keep_sender"`
+	KeepReceiver []PruningEnum   `yaml:"keep_receiver"`
+	GracePeriod  time.Duration   `yaml:"grace_period,optional,zeropositive"`
+	Destroy      *PruningDestroy `yaml:"destroy,optional,fromdefaults"`
 }
 
 type PruningLocal struct {
-	Keep []PruningEnum `yaml:"keep"`
+	Keep        []PruningEnum   `yaml:"keep"`
+	GracePeriod time.Duration   `yaml:"grace_period,optional,zeropositive"`
+	Destroy     *PruningDestroy `yaml:"destroy,optional,fromdefaults"`
+}
+
+// PruningDestroy limits the load that destroying snapshots puts on the pools.
+type PruningDestroy struct {
+	// Number of filesystems whose snapshots are destroyed concurrently.
+	Concurrency int `yaml:"concurrency,optional,default=1"`
+	// Maximum number of snapshots destroyed by a single request (i.e., `zfs destroy` invocation), 0 means unlimited.
+	BatchSize int `yaml:"batch_size,optional,default=0"`
+	// Minimum pause between two destroy requests of the same worker.
+	Pause time.Duration `yaml:"pause,optional,zeropositive"`
 }
 
 type LoggingOutletEnumList []LoggingOutletEnum
@@ -212,6 +399,9 @@ type Global struct {
 	Monitoring []MonitoringEnum       `yaml:"monitoring,optional"`
 	Control    *GlobalControl         `yaml:"control,optional,fromdefaults"`
 	Serve      *GlobalServe           `yaml:"serve,optional,fromdefaults"`
+	ZFS        *GlobalZFS             `yaml:"zfs,optional,fromdefaults"`
+	// "fail" or "disable_job"
+	OnJobInitError string `yaml:"on_job_init_error,optional,default=fail"`
 }
 
 func Default(i interface{}) {
@@ -232,6 +422,8 @@ type ConnectEnum struct {
 
 type ConnectCommon struct {
 	Type string `yaml:"type"`
+	// Limits the time to establish the transport connection, including dial_timeout. Zero means no limit.
+	ConnectTimeout time.Duration `yaml:"connect_timeout,optional,zeropositive"`
 }
 
 type TCPConnect struct {
@@ -330,9 +522,10 @@ type LoggingOutletEnum struct {
 }
 
 type LoggingOutletCommon struct {
-	Type   string `yaml:"type"`
-	Level  string `yaml:"level"`
-	Format string `yaml:"format"`
+	Type        string        `yaml:"type"`
+	Level       string        `yaml:"level"`
+	Format      string        `yaml:"format"`
+	DedupWindow time.Duration `yaml:"dedup_window,optional"` // 0 disables deduplication
 }
 
 type StdoutLoggingOutlet struct {
@@ -387,6 +580,16 @@ type GlobalServe struct {
 	StdinServer *GlobalStdinServer `yaml:"stdinserver,optional,fromdefaults"`
 }
 
+type GlobalZFS struct {
+	// empty means PATH lookup
+	ZFSBinary   string `yaml:"zfs_binary,optional"`
+	ZPoolBinary string `yaml:"zpool_binary,optional"`
+	// argv prepended to zfs and zpool invocations, e.g. ["sudo", "-n"]
+	PrivilegeEscalation []string `yaml:"privilege_escalation,optional"`
+	// How long the list of datasets is shared between jobs before `zfs list` is invoked again. 0 disables the cache.
+	ListCacheTTL time.Duration `yaml:"list_cache_ttl,optional,zeropositive"`
+}
+
 type GlobalStdinServer struct {
 	SockDir string `yaml:"sockdir,default=/var/run/zrepl/stdinserver"`
 }
@@ -408,7 +611,11 @@ type HookEnum struct {
 type HookCommand struct {
 	Path               string            `yaml:"path"`
 	Timeout            time.Duration     `yaml:"timeout,optional,positive,default=30s"`
+	GraceTimeout       time.Duration     `yaml:"grace_timeout,optional,zeropositive,default=0s"`
 	Filesystems        FilesystemsFilter `yaml:"filesystems,optional,default={'<': true}"`
+	Output             string            `yaml:"output,optional,default=lines"`
+	RunOnErr           bool              `yaml:"run_on_err,optional,default=false"`
+	StdinJSON          bool              `yaml:"stdin_json,optional,default=false"`
 	HookSettingsCommon `yaml:",inline"`
 }
 
@@ -429,6 +636,8 @@ type HookMySQLLockTables struct {
 type HookSettingsCommon struct {
 	Type       string `yaml:"type"`
 	ErrIsFatal bool   `yaml:"err_is_fatal,optional,default=false"`
+	// One of "like_error", "fatal", "continue"; see hooks.TimeoutPolicy
+	OnTimeout string `yaml:"on_timeout,optional,default=like_error"`
 }
 
 func enumUnmarshal(u func(interface{}, bool) error, types map[string]interface{}) (interface{}, error) {
@@ -493,9 +702,39 @@ func (t *PruningEnum) UnmarshalYAML(u func(interface{}, bool) error) (err error)
 	return
 }
 
+// SnapshotNaming determines the names of the snapshots that a snapshotting config creates:
+// Prefix followed by the snapshot time, formatted with TimestampFormat in TimestampLocation.
+type SnapshotNaming struct {
+	Prefix            string
+	TimestampFormat   string
+	TimestampLocation string
+}
+
+func (s *SnapshottingPeriodic) Naming() SnapshotNaming {
+	return SnapshotNaming{s.Prefix, s.TimestampFormat, s.TimestampLocation}
+}
+
+func (s *SnapshottingCron) Naming() SnapshotNaming {
+	return SnapshotNaming{s.Prefix, s.TimestampFormat, s.TimestampLocation}
+}
+
+// Naming returns the naming of the snapshots that t creates.
+// ok is false if t does not create snapshots, i.e., for manual snapshotting.
+func (t SnapshottingEnum) Naming() (n SnapshotNaming, ok bool) {
+	switch s := t.Ret.(type) {
+	case *SnapshottingPeriodic:
+		return s.Naming(), true
+	case *SnapshottingCron:
+		return s.Naming(), true
+	default:
+		return n, false
+	}
+}
+
 func (t *SnapshottingEnum) UnmarshalYAML(u func(interface{}, bool) error) (err error) {
 	t.Ret, err = enumUnmarshal(u, map[string]interface{}{
 		"periodic": &SnapshottingPeriodic{},
+		"cron":     &SnapshottingCron{},
 		"manual":   &SnapshottingManual{},
 	})
 	return
@@ -585,7 +824,26 @@ var ConfigFileDefaultLocations = []string{
 	"/usr/local/etc/zrepl/zrepl.yml",
 }
 
-func ParseConfig(path string) (i *Config, err error) {
+// ParseConfig parses the config file at path, or, if path is empty, at the first of ConfigFileDefaultLocations that exists.
+// Keys that do not correspond to any config field are rejected, see ParseConfigBytes.
+func ParseConfig(path string) (*Config, error) {
+	bytes, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfigBytes(bytes)
+}
+
+// ParseConfigLenient is like ParseConfig, but ignores unknown keys, see ParseConfigBytesLenient.
+func ParseConfigLenient(path string) (*Config, []UnknownKey, error) {
+	bytes, err := readConfigFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ParseConfigBytesLenient(bytes)
+}
+
+func readConfigFile(path string) ([]byte, error) {
 
 	if path == "" {
 		// Try default locations
@@ -595,24 +853,47 @@ func ParseConfig(path string) (i *Config, err error) {
 				continue
 			}
 			if !stat.Mode().IsRegular() {
-				err = errors.Errorf("file at default location is not a regular file: %s", l)
-				return
+				return nil, errors.Errorf("file at default location is not a regular file: %s", l)
 			}
 			path = l
 			break
 		}
 	}
 
-	var bytes []byte
+	return ioutil.ReadFile(path)
+}
 
-	if bytes, err = ioutil.ReadFile(path); err != nil {
-		return
+// ParseConfigBytes parses and validates a config.
+//
+// Keys that do not correspond to any config field (e.g. typos) are rejected
+// with an *UnknownKeysError that lists them.
+func ParseConfigBytes(bytes []byte) (*Config, error) {
+	c, unknownKeys, err := parseConfigBytes(bytes)
+	if len(unknownKeys) > 0 {
+		return nil, &UnknownKeysError{Keys: unknownKeys, Other: err}
 	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
 
-	return ParseConfigBytes(bytes)
+// ParseConfigBytesLenient is like ParseConfigBytes, but ignores unknown keys and returns them instead.
+// It is meant for forward-compatibility, e.g., when downgrading zrepl without removing
+// config keys that were introduced by the newer version.
+// All other validation errors are still fatal.
+func ParseConfigBytesLenient(bytes []byte) (*Config, []UnknownKey, error) {
+	c, unknownKeys, err := parseConfigBytes(bytes)
+	if err != nil {
+		if len(unknownKeys) > 0 {
+			return nil, nil, &UnknownKeysError{Keys: unknownKeys, Other: err}
+		}
+		return nil, nil, err
+	}
+	return c, unknownKeys, nil
 }
 
-func ParseConfigBytes(bytes []byte) (*Config, error) {
+func parseConfigBytesStrict(bytes []byte) (*Config, error) {
 	var c *Config
 	if err := yaml.UnmarshalStrict(bytes, &c); err != nil {
 		return nil, err
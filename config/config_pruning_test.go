@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPruningDestroy(t *testing.T) {
+	tmpl := `
+jobs:
+- name: foo
+  type: snap
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep:
+    - type: last_n
+      count: 10
+%s
+`
+	c := testValidConfig(t, fmt.Sprintf(tmpl, ""))
+	d := c.Jobs[0].Ret.(*SnapJob).Pruning.Destroy
+	assert.Equal(t, &PruningDestroy{Concurrency: 1}, d)
+
+	c = testValidConfig(t, fmt.Sprintf(tmpl, `
+    destroy:
+      concurrency: 2
+      batch_size: 50
+      pause: 1s
+`))
+	d = c.Jobs[0].Ret.(*SnapJob).Pruning.Destroy
+	assert.Equal(t, &PruningDestroy{Concurrency: 2, BatchSize: 50, Pause: time.Second}, d)
+}
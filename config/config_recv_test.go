@@ -0,0 +1,57 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecvOptionsProperties(t *testing.T) {
+	c := testValidConfig(t, `
+jobs:
+- type: sink
+  name: "sink"
+  root_fs: "pool2/backup"
+  serve:
+    type: local
+    listener_name: sink
+  recv:
+    properties:
+      override:
+        recordsize: 1M
+        compression: zstd
+      exclude:
+      - mountpoint
+`)
+	recv := c.Jobs[0].Ret.(*SinkJob).Recv
+	assert.Equal(t, map[string]string{"recordsize": "1M", "compression": "zstd"}, recv.Properties.Override)
+	assert.Equal(t, []string{"mountpoint"}, recv.Properties.Exclude)
+
+	c = testValidConfig(t, `
+jobs:
+- type: sink
+  name: "sink"
+  root_fs: "pool2/backup"
+  serve:
+    type: local
+    listener_name: sink
+`)
+	assert.Nil(t, c.Jobs[0].Ret.(*SinkJob).Recv.Properties)
+}
+
+func TestRecvOptionsRename(t *testing.T) {
+	c := testValidConfig(t, `
+jobs:
+- type: sink
+  name: "sink"
+  root_fs: "pool2/backup"
+  serve:
+    type: local
+    listener_name: sink
+  recv:
+    rename:
+      "tank/prod<": "archive/prod-<"
+`)
+	recv := c.Jobs[0].Ret.(*SinkJob).Recv
+	assert.Equal(t, map[string]string{"tank/prod<": "archive/prod-<"}, recv.Rename)
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -45,6 +46,33 @@ jobs:
 	send_not_specified := `
 `
 
+	tee := `
+  send:
+    encrypted: false
+    tee:
+      directory: /var/tmp/zrepl-tee
+`
+
+	tee_compression := `
+  send:
+    encrypted: false
+    tee:
+      directory: /var/tmp/zrepl-tee
+      compression:
+        codec: gzip
+        level: 9
+`
+
+	pool_io_throttle := `
+  send:
+    encrypted: false
+    pool_io_throttle:
+      max_rate: 104857600
+      min_rate: 10485760
+      idle_threshold: 20971520
+      busy_threshold: 52428800
+`
+
 	fill := func(s string) string { return fmt.Sprintf(tmpl, s) }
 	var c *Config
 
@@ -69,6 +97,33 @@ jobs:
 		c, err := testConfig(t, fill(send_not_specified))
 		assert.NoError(t, err)
 		assert.NotNil(t, c)
+		assert.Nil(t, c.Jobs[0].Ret.(*PushJob).Send.Tee)
+	})
+
+	t.Run("tee", func(t *testing.T) {
+		c = testValidConfig(t, fill(tee))
+		tee := c.Jobs[0].Ret.(*PushJob).Send.Tee
+		assert.NotNil(t, tee)
+		assert.Equal(t, "/var/tmp/zrepl-tee", tee.Directory)
+		assert.Nil(t, tee.Compression)
+	})
+
+	t.Run("tee_compression", func(t *testing.T) {
+		c = testValidConfig(t, fill(tee_compression))
+		tee := c.Jobs[0].Ret.(*PushJob).Send.Tee
+		assert.NotNil(t, tee)
+		assert.Equal(t, &SendOptionsTeeCompression{Codec: "gzip", Level: 9}, tee.Compression)
+	})
+
+	t.Run("pool_io_throttle", func(t *testing.T) {
+		c = testValidConfig(t, fill(pool_io_throttle))
+		assert.Equal(t, &SendOptionsPoolIOThrottle{
+			MaxRate:       104857600,
+			MinRate:       10485760,
+			IdleThreshold: 20971520,
+			BusyThreshold: 52428800,
+			Interval:      10 * time.Second,
+		}, c.Jobs[0].Ret.(*PushJob).Send.PoolIOThrottle)
 	})
 
 }
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSnapshotting(t *testing.T) {
@@ -37,6 +38,12 @@ jobs:
     prefix: zrepl_
     interval: 10m
 `
+	cron := `
+  snapshotting:
+    type: cron
+    prefix: zrepl_
+    cron: "0 2 * * *"
+`
 
 	hooks := `
   snapshotting:
@@ -68,6 +75,8 @@ jobs:
 		c = testValidConfig(t, fillSnapshotting(manual))
 		snm := c.Jobs[0].Ret.(*PushJob).Snapshotting.Ret.(*SnapshottingManual)
 		assert.Equal(t, "manual", snm.Type)
+		_, ok := c.Jobs[0].Ret.(*PushJob).Snapshotting.Naming()
+		assert.False(t, ok)
 	})
 
 	t.Run("periodic", func(t *testing.T) {
@@ -78,6 +87,18 @@ jobs:
 		assert.Equal(t, "zrepl_", snp.Prefix)
 	})
 
+	t.Run("cron", func(t *testing.T) {
+		c = testValidConfig(t, fillSnapshotting(cron))
+		snc := c.Jobs[0].Ret.(*PushJob).Snapshotting.Ret.(*SnapshottingCron)
+		assert.Equal(t, "cron", snc.Type)
+		assert.Equal(t, "0 2 * * *", snc.Cron)
+		assert.Equal(t, "Local", snc.CronLocation)
+		assert.Equal(t, "zrepl_", snc.Prefix)
+		naming, ok := c.Jobs[0].Ret.(*PushJob).Snapshotting.Naming()
+		require.True(t, ok)
+		assert.Equal(t, SnapshotNaming{"zrepl_", "20060102_150405_000", "UTC"}, naming)
+	})
+
 	t.Run("hooks", func(t *testing.T) {
 		c = testValidConfig(t, fillSnapshotting(hooks))
 		hs := c.Jobs[0].Ret.(*PushJob).Snapshotting.Ret.(*SnapshottingPeriodic).Hooks
@@ -86,3 +86,44 @@ func TestTrimSpaceEachLineAndPad(t *testing.T) {
 	`
 	assert.Equal(t, "  \n  foo\n  bar baz\n  \n", trimSpaceEachLineAndPad(foo, "  "))
 }
+
+func TestUnknownKeys(t *testing.T) {
+	in := `
+jobs:
+- name: foo
+  type: snap
+  filesystems: {"<": true}
+  snapshotting:
+    type: periodic
+    prefx: zrepl_
+    prefix: zrepl_
+    interval: 10m
+  pruning:
+    keep:
+    - type: last_n
+      count: 10
+      regx: foo
+`
+	_, err := ParseConfigBytes([]byte(in))
+	require.Error(t, err)
+	ukErr, ok := err.(*UnknownKeysError)
+	require.True(t, ok, "%T %s", err, err)
+	require.NoError(t, ukErr.Other)
+	require.Len(t, ukErr.Keys, 2)
+	assert.Equal(t, UnknownKey{Line: 8, Key: "prefx", In: "config.SnapshottingPeriodic"}, ukErr.Keys[0])
+	assert.Equal(t, 15, ukErr.Keys[1].Line)
+	assert.Equal(t, "regx", ukErr.Keys[1].Key)
+
+	c, keys, err := ParseConfigBytesLenient([]byte(in))
+	require.NoError(t, err)
+	assert.Equal(t, ukErr.Keys, keys)
+	snap := c.Jobs[0].Ret.(*SnapJob).Snapshotting.Ret.(*SnapshottingPeriodic)
+	assert.Equal(t, "zrepl_", snap.Prefix)
+
+	// lenient mode only tolerates unknown keys
+	_, _, err = ParseConfigBytesLenient([]byte(strings.Replace(in, "    prefix: zrepl_\n", "", 1)))
+	require.Error(t, err)
+	ukErr, ok = err.(*UnknownKeysError)
+	require.True(t, ok)
+	assert.Error(t, ukErr.Other)
+}
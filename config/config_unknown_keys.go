@@ -0,0 +1,119 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/zrepl/yaml-config"
+)
+
+// UnknownKey is a key in the config file that does not correspond to any config field.
+type UnknownKey struct {
+	Line int    // 1-based line number of the key in the config file
+	Key  string // the key as written in the config file
+	In   string // the (Go) type of the config section that contains the key
+}
+
+func (k UnknownKey) String() string {
+	return fmt.Sprintf("line %d: unknown key %q in %s", k.Line, k.Key, k.In)
+}
+
+type UnknownKeysError struct {
+	Keys []UnknownKey
+	// The errors in the config apart from the unknown keys, may be nil.
+	Other error
+}
+
+func (e *UnknownKeysError) Error() string {
+	msgs := make([]string, len(e.Keys))
+	for i, k := range e.Keys {
+		msgs[i] = k.String()
+	}
+	msg := fmt.Sprintf("config contains unknown keys:\n  %s", strings.Join(msgs, "\n  "))
+	if e.Other != nil {
+		msg += "\n" + e.Other.Error()
+	}
+	return msg
+}
+
+// parseConfigBytes parses the config in strict mode.
+// Unknown keys are returned in unknownKeys, err reports all other errors.
+//
+// The yaml library does not distinguish unknown keys from other errors,
+// and an unknown key within a section that is decoded by an Unmarshaler
+// causes spurious follow-up errors (e.g. 'field ... is required but has zero value').
+// Thus, we blank out the unknown keys and parse the config again until there are none left.
+// The line numbers stay the same because only the contents of the lines are removed.
+func parseConfigBytes(bytes []byte) (c *Config, unknownKeys []UnknownKey, err error) {
+	for {
+		c, err = parseConfigBytesStrict(bytes)
+		if err == nil {
+			return c, unknownKeys, nil
+		}
+		keys := unknownKeysFromError(err)
+		if len(keys) == 0 {
+			return nil, unknownKeys, err
+		}
+		unknownKeys = append(unknownKeys, keys...)
+		var ok bool
+		if bytes, ok = blankOutKeys(bytes, keys); !ok {
+			return nil, unknownKeys, err
+		}
+	}
+}
+
+// must match the error message of the yaml library for unknown struct fields in strict mode
+var unknownKeyErrorRE = regexp.MustCompile(`^line (\d+): field (.*) not found in type (.*)$`)
+
+func unknownKeysFromError(err error) (keys []UnknownKey) {
+	terr, ok := err.(*yaml.TypeError)
+	if !ok {
+		return nil
+	}
+	for _, msg := range terr.Errors {
+		m := unknownKeyErrorRE.FindStringSubmatch(msg)
+		if m == nil {
+			continue
+		}
+		line, err := strconv.Atoi(m[1])
+		if err != nil {
+			continue
+		}
+		keys = append(keys, UnknownKey{Line: line, Key: m[2], In: m[3]})
+	}
+	return keys
+}
+
+var blockMappingKeyRE = regexp.MustCompile(`^(\s*)(-\s+)?("[^"]*"|'[^']*'|[^\s"'{}\[\],:#-][^:#]*?)\s*:(\s|$)`)
+
+// blankOutKeys removes the given keys and their values from a block-style YAML document.
+// Returns false if a key cannot be removed, e.g., because it is part of a flow mapping.
+func blankOutKeys(doc []byte, keys []UnknownKey) ([]byte, bool) {
+	lines := strings.Split(string(doc), "\n")
+	for _, k := range keys {
+		i := k.Line - 1
+		if i < 0 || i >= len(lines) {
+			return nil, false
+		}
+		m := blockMappingKeyRE.FindStringSubmatch(lines[i])
+		if m == nil || strings.Trim(m[3], `"'`) != k.Key {
+			return nil, false
+		}
+		keyCol := len(m[1]) + len(m[2])
+		lines[i] = m[1] + strings.TrimSpace(m[2]) // keep the dash if the key starts a sequence item
+		// the key's value continues on all following lines that are indented deeper than the key
+		for j := i + 1; j < len(lines); j++ {
+			trimmed := strings.TrimSpace(lines[j])
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				continue
+			}
+			if len(lines[j])-len(strings.TrimLeft(lines[j], " ")) <= keyCol {
+				break
+			}
+			lines[j] = ""
+		}
+	}
+	return []byte(strings.Join(lines, "\n")), true
+}
@@ -11,8 +11,13 @@ post_snapshot() {
     $DRYRUN date
 }
 
+# only invoked if the hook is configured with run_on_err: true
+err_snapshot() {
+    $DRYRUN printf 'snapshot of %s failed: %s\n' "$ZREPL_FS" "$ZREPL_ERROR"
+}
+
 case "$ZREPL_HOOKTYPE" in
-    pre_snapshot|post_snapshot)
+    pre_snapshot|post_snapshot|err_snapshot)
         "$ZREPL_HOOKTYPE"
         ;;
     *)
@@ -77,6 +77,8 @@ const (
 	ControlJobEndpointVersion string = "/version"
 	ControlJobEndpointStatus  string = "/status"
 	ControlJobEndpointSignal  string = "/signal"
+
+	ControlJobEndpointSnapperDumpState string = "/debug/snapper-state"
 )
 
 func (j *controlJob) Run(ctx context.Context) {
@@ -147,12 +149,23 @@ func (j *controlJob) Run(ctx context.Context) {
 				err = j.jobs.wakeup(req.Name)
 			case "reset":
 				err = j.jobs.reset(req.Name)
+			case "snapshot":
+				err = j.jobs.triggerSnapshot(req.Name)
 			default:
 				err = fmt.Errorf("operation %q is invalid", req.Op)
 			}
 
 			return struct{}{}, err
 		}}})
+	mux.Handle(ControlJobEndpointSnapperDumpState,
+		requestLogger{log: log, handler: jsonRequestResponder{log, func(decoder jsonDecoder) (interface{}, error) {
+			var jobName string
+			if decoder(&jobName) != nil {
+				return nil, errors.Errorf("decode failed")
+			}
+			return j.jobs.snapperDumpState(jobName)
+		}}})
+
 	server := http.Server{
 		Handler: mux,
 		// control socket is local, 1s timeout should be more than sufficient, even on a loaded system
@@ -21,12 +21,15 @@ import (
 	"github.com/zrepl/zrepl/daemon/job/reset"
 	"github.com/zrepl/zrepl/daemon/job/wakeup"
 	"github.com/zrepl/zrepl/daemon/logging"
+	"github.com/zrepl/zrepl/daemon/snapper"
 	"github.com/zrepl/zrepl/logger"
 	"github.com/zrepl/zrepl/version"
+	"github.com/zrepl/zrepl/zfs"
 	"github.com/zrepl/zrepl/zfs/zfscmd"
 )
 
-func Run(ctx context.Context, conf *config.Config) error {
+// reparseConfig is invoked on SIGHUP to obtain the new config, see reloader.
+func Run(ctx context.Context, conf *config.Config, reparseConfig func() (*config.Config, error)) error {
 	ctx, cancel := context.WithCancel(ctx)
 
 	defer cancel()
@@ -43,6 +46,11 @@ func Run(ctx context.Context, conf *config.Config) error {
 	}
 	outlets.Add(newPrometheusLogOutlet(), logger.Debug)
 
+	if err := ConfigureZFSCmdFromConfig(conf.Global.ZFS); err != nil {
+		return errors.Wrap(err, "cannot configure zfs commands")
+	}
+	zfs.SetDatasetListCacheTTL(conf.Global.ZFS.ListCacheTTL)
+
 	confJobs, err := job.JobsFromConfig(conf)
 	if err != nil {
 		return errors.Wrap(err, "cannot build jobs from config")
@@ -107,6 +115,25 @@ func Run(ctx context.Context, conf *config.Config) error {
 		jobs.start(ctx, j, false)
 	}
 
+	reloader := newReloader(jobs, conf, reparseConfig)
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	usr1Chan := make(chan os.Signal, 1)
+	signal.Notify(usr1Chan, syscall.SIGUSR1)
+	go func() {
+		for {
+			select {
+			case <-hupChan:
+				reloader.reload(ctx)
+			case <-usr1Chan:
+				log.Info("received SIGUSR1, triggering snapshotting of all jobs")
+				jobs.triggerAllSnapshots(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
 	select {
 	case <-jobs.wait():
 		log.Info("all jobs finished")
@@ -123,17 +150,37 @@ type jobs struct {
 	wg sync.WaitGroup
 
 	// m protects all fields below it
-	m       sync.RWMutex
-	wakeups map[string]wakeup.Func // by Job.Name
-	resets  map[string]reset.Func  // by Job.Name
-	jobs    map[string]job.Job
+	m           sync.RWMutex
+	wakeups     map[string]wakeup.Func // by Job.Name
+	resets      map[string]reset.Func  // by Job.Name
+	jobs        map[string]job.Job
+	cancels     map[string]context.CancelFunc // by Job.Name
+	done        map[string]chan struct{}      // by Job.Name, closed when the job's Run returns
+	registerers map[string]*jobRegisterer     // by Job.Name
+}
+
+// ConfigureZFSCmdFromConfig makes zfscmd use the zfs and zpool binaries
+// and the privilege escalation command configured in in.
+// Binaries without a configured path are looked up in PATH.
+func ConfigureZFSCmdFromConfig(in *config.GlobalZFS) error {
+	err := zfscmd.SetBinaryPaths(map[string]string{
+		"zfs":   in.ZFSBinary,
+		"zpool": in.ZPoolBinary,
+	})
+	if err != nil {
+		return err
+	}
+	return zfscmd.SetPrivilegeWrapper(in.PrivilegeEscalation)
 }
 
 func newJobs() *jobs {
 	return &jobs{
-		wakeups: make(map[string]wakeup.Func),
-		resets:  make(map[string]reset.Func),
-		jobs:    make(map[string]job.Job),
+		wakeups:     make(map[string]wakeup.Func),
+		resets:      make(map[string]reset.Func),
+		jobs:        make(map[string]job.Job),
+		cancels:     make(map[string]context.CancelFunc),
+		done:        make(map[string]chan struct{}),
+		registerers: make(map[string]*jobRegisterer),
 	}
 }
 
@@ -204,6 +251,61 @@ func (s *jobs) reset(job string) error {
 	return wu()
 }
 
+func (s *jobs) triggerSnapshot(jobName string) error {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	j, ok := s.jobs[jobName]
+	if !ok {
+		return errors.Errorf("Job %s does not exist", jobName)
+	}
+	t, ok := j.(job.SnapshotTriggerer)
+	if !ok {
+		return errors.Errorf("Job %s does not take snapshots", jobName)
+	}
+	return t.TriggerSnapshot()
+}
+
+// triggerAllSnapshots triggers the snapper of every job that takes snapshots and is waiting.
+func (s *jobs) triggerAllSnapshots(ctx context.Context) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	for name, j := range s.jobs {
+		t, ok := j.(job.SnapshotTriggerer)
+		if !ok {
+			continue
+		}
+		log := job.GetLogger(ctx).WithField(logging.JobField, name)
+		switch err := t.TriggerSnapshot(); err {
+		case nil:
+			log.Info("triggered snapshotting")
+		case job.ErrJobDoesNotSnapshot, snapper.ErrManualSnapshotting:
+		default:
+			log.WithError(err).Warn("cannot trigger snapshotting")
+		}
+	}
+}
+
+func (s *jobs) snapperDumpState(jobName string) (string, error) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	j, ok := s.jobs[jobName]
+	if !ok {
+		return "", errors.Errorf("Job %s does not exist", jobName)
+	}
+	d, ok := j.(job.SnapperStateDumper)
+	if !ok {
+		return "", errors.Errorf("Job %s does not take snapshots", jobName)
+	}
+	dump, ok := d.SnapperDumpState()
+	if !ok {
+		return "", errors.Errorf("Job %s does not take snapshots", jobName)
+	}
+	return dump, nil
+}
+
 const (
 	jobNamePrometheus = "_prometheus"
 	jobNameControl    = "_control"
@@ -230,20 +332,88 @@ func (s *jobs) start(ctx context.Context, j job.Job, internal bool) {
 		panic(fmt.Sprintf("duplicate job name %s", jobName))
 	}
 
-	j.RegisterMetrics(prometheus.DefaultRegisterer)
+	registerer := &jobRegisterer{Registerer: prometheus.DefaultRegisterer}
+	j.RegisterMetrics(registerer)
+	s.registerers[jobName] = registerer
 
 	s.jobs[jobName] = j
 	ctx = zfscmd.WithJobID(ctx, j.Name())
 	ctx, wakeup := wakeup.Context(ctx)
 	ctx, resetFunc := reset.Context(ctx)
+	ctx, cancel := context.WithCancel(ctx)
 	s.wakeups[jobName] = wakeup
 	s.resets[jobName] = resetFunc
+	s.cancels[jobName] = cancel
+	done := make(chan struct{})
+	s.done[jobName] = done
 
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
+		defer close(done)
 		job.GetLogger(ctx).Info("starting job")
 		defer job.GetLogger(ctx).Info("job exited")
 		j.Run(ctx)
 	}()
 }
+
+// stop cancels the context of the job with the given name, waits for its Run method to return,
+// and removes all traces of it, including its metrics.
+func (s *jobs) stop(jobName string) error {
+	s.m.Lock()
+	cancel, ok := s.cancels[jobName]
+	done := s.done[jobName]
+	s.m.Unlock()
+	if !ok {
+		return errors.Errorf("job %s does not exist", jobName)
+	}
+
+	cancel()
+	<-done
+
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.registerers[jobName].unregisterAll()
+	delete(s.registerers, jobName)
+	delete(s.jobs, jobName)
+	delete(s.wakeups, jobName)
+	delete(s.resets, jobName)
+	delete(s.cancels, jobName)
+	delete(s.done, jobName)
+	return nil
+}
+
+// jobRegisterer records the collectors registered by a job so that they can be unregistered when the job is stopped.
+type jobRegisterer struct {
+	prometheus.Registerer
+
+	mtx        sync.Mutex
+	collectors []prometheus.Collector
+}
+
+func (r *jobRegisterer) Register(c prometheus.Collector) error {
+	if err := r.Registerer.Register(c); err != nil {
+		return err
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.collectors = append(r.collectors, c)
+	return nil
+}
+
+func (r *jobRegisterer) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (r *jobRegisterer) unregisterAll() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for _, c := range r.collectors {
+		r.Registerer.Unregister(c)
+	}
+	r.collectors = nil
+}
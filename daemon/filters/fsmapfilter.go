@@ -24,6 +24,7 @@ type datasetMapFilterEntry struct {
 	// we have to convert it to the desired rep dynamically
 	mapping      string
 	subtreeMatch bool
+	leafRename   *leafRenameTemplate // nil unless mapping is a leaf rename template
 }
 
 func NewDatasetMapFilter(capacity int, filterMode bool) *DatasetMapFilter {
@@ -42,7 +43,6 @@ func (m *DatasetMapFilter) Add(pathPattern, mapping string) (err error) {
 	}
 
 	// assert path glob adheres to spec
-	const SUBTREE_PATTERN string = "<"
 	patternCount := strings.Count(pathPattern, SUBTREE_PATTERN)
 	switch {
 	case patternCount > 1:
@@ -56,17 +56,35 @@ func (m *DatasetMapFilter) Add(pathPattern, mapping string) (err error) {
 	if err != nil {
 		return fmt.Errorf("pattern is not a dataset path: %s", err)
 	}
+	if err := path.ValidateSnapshotHeadroom(); err != nil {
+		return fmt.Errorf("pattern: %s", err)
+	}
 
 	entry := datasetMapFilterEntry{
 		path:         path,
 		mapping:      mapping,
 		subtreeMatch: patternCount > 0,
 	}
+	if !m.filterMode {
+		if isLeafRenameTemplate(mapping) {
+			if !entry.subtreeMatch {
+				return fmt.Errorf("leaf rename template %q requires a subtree pattern", mapping)
+			}
+			if entry.leafRename, err = parseLeafRenameTemplate(mapping); err != nil {
+				return err
+			}
+		}
+		if err = m.checkUnambiguous(entry); err != nil {
+			return err
+		}
+	}
 	m.entries = append(m.entries, entry)
 	return
 
 }
 
+const SUBTREE_PATTERN string = "<"
+
 // find the most specific prefix mapping we have
 //
 // longer prefix wins over shorter prefix, direct wins over glob
@@ -116,6 +134,11 @@ func (m DatasetMapFilter) Map(source *zfs.DatasetPath) (target *zfs.DatasetPath,
 	}
 	me := m.entries[mi]
 
+	if me.leafRename != nil {
+		rel, _ := source.Relative(me.path)
+		return me.leafRename.Map(rel)
+	}
+
 	if me.mapping == "" {
 		// Special case treatment: 'foo/bar<' => ''
 		if !me.subtreeMatch {
@@ -136,13 +159,55 @@ func (m DatasetMapFilter) Map(source *zfs.DatasetPath) (target *zfs.DatasetPath,
 	}
 	if me.subtreeMatch {
 		// strip common prefix ('<' wildcards are no special case here)
-		extendComps := source.Copy()
-		extendComps.TrimPrefix(me.path)
+		extendComps, _ := source.Relative(me.path)
 		target.Extend(extendComps)
 	}
 	return
 }
 
+// Unmap inverts Map: it returns the source that m maps to target, or nil if there is none.
+//
+// Add ensures that no two mapping entries produce the same target, thus the source is unique.
+func (m DatasetMapFilter) Unmap(target *zfs.DatasetPath) (source *zfs.DatasetPath, err error) {
+
+	if m.filterMode {
+		err = fmt.Errorf("using a filter for mapping simply does not work")
+		return
+	}
+
+	for _, e := range m.entries {
+		if strings.HasPrefix(e.mapping, MapFilterResultOmit) {
+			continue
+		}
+		var candidate *zfs.DatasetPath
+		if e.leafRename != nil {
+			if rel := e.leafRename.Unmap(target); rel != nil {
+				candidate = e.path.Extended(rel)
+			}
+		} else {
+			mapping, err := zfs.NewDatasetPath(e.mapping)
+			if err != nil {
+				return nil, fmt.Errorf("mapping target is not a dataset path: %s", err)
+			}
+			if rel, ok := target.Relative(mapping); ok && (e.subtreeMatch || rel.Empty()) {
+				candidate = e.path.Extended(rel)
+			}
+		}
+		if candidate == nil {
+			continue
+		}
+		// a more specific entry may take precedence for candidate
+		mapped, err := m.Map(candidate)
+		if err != nil {
+			return nil, err
+		}
+		if mapped != nil && mapped.Equal(target) {
+			return candidate, nil
+		}
+	}
+	return nil, nil
+}
+
 func (m DatasetMapFilter) Filter(p *zfs.DatasetPath) (pass bool, err error) {
 
 	if !m.filterMode {
@@ -160,6 +225,44 @@ func (m DatasetMapFilter) Filter(p *zfs.DatasetPath) (pass bool, err error) {
 	return
 }
 
+// MayPassWithin returns true if the filter passes root or any of the filesystems below root.
+//
+// It is conservative in that it considers every filter entry below root that passes its own path,
+// regardless of whether such a filesystem actually exists.
+func (m DatasetMapFilter) MayPassWithin(root *zfs.DatasetPath) (pass bool, err error) {
+	if pass, err = m.Filter(root); err != nil || pass {
+		return pass, err
+	}
+	// children of root that are not matched by a more specific entry
+	lcp, lcpIdx := -1, -1
+	for i, e := range m.entries {
+		if e.subtreeMatch && root.HasPrefix(e.path) && e.path.Length() > lcp {
+			lcp, lcpIdx = e.path.Length(), i
+		}
+	}
+	if lcpIdx >= 0 {
+		if pass, err = m.parseDatasetFilterResult(m.entries[lcpIdx].mapping); err != nil || pass {
+			return pass, err
+		}
+	}
+	// entries at or below root
+	for _, e := range m.entries {
+		if !e.path.HasPrefix(root) {
+			continue
+		}
+		if pass, err = m.Filter(e.path); err != nil || pass {
+			return pass, err
+		}
+		// the entry's path might be omitted by a more specific entry, but its children are not
+		if e.subtreeMatch {
+			if pass, err = m.parseDatasetFilterResult(e.mapping); err != nil || pass {
+				return pass, err
+			}
+		}
+	}
+	return false, nil
+}
+
 // Construct a new filter-only DatasetMapFilter from a mapping
 // The new filter allows exactly those paths that were not forbidden by the mapping.
 func (m DatasetMapFilter) InvertedFilter() (inv *DatasetMapFilter, err error) {
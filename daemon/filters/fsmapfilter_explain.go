@@ -0,0 +1,50 @@
+package filters
+
+import (
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// FilterDecision explains how a DatasetMapFilter treats a dataset, see DatasetMapFilter.Explain.
+type FilterDecision struct {
+	Path *zfs.DatasetPath
+	Pass bool
+	// The pattern of the entry that decided about Path, e.g. `pool/home<`.
+	// Empty if no entry matches, in which case Path does not pass.
+	Pattern string
+	// The right-hand side of the matching entry, e.g. `ok` or a mapping target.
+	Mapping string
+	// For mappings: the target Path is mapped to, nil if Path does not pass.
+	// Always nil for filters.
+	Target *zfs.DatasetPath
+	// Set if the matching entry cannot be applied to Path. Pass is false in that case.
+	Err error
+}
+
+// Explain returns the decision of m for each dataset in all, in the order of all.
+//
+// all is typically the output of `zfs list`, which allows validating a configuration before deploying it.
+func (m DatasetMapFilter) Explain(all []*zfs.DatasetPath) []FilterDecision {
+	res := make([]FilterDecision, len(all))
+	for i, p := range all {
+		d := FilterDecision{Path: p}
+		if mi, found := m.mostSpecificPrefixMapping(p); found {
+			e := m.entries[mi]
+			d.Pattern = e.path.ToString()
+			if e.subtreeMatch {
+				d.Pattern += SUBTREE_PATTERN
+			}
+			d.Mapping = e.mapping
+			if m.filterMode {
+				d.Pass, d.Err = m.parseDatasetFilterResult(e.mapping)
+			} else {
+				d.Target, d.Err = m.Map(p)
+				d.Pass = d.Err == nil && d.Target != nil
+			}
+			if d.Err != nil {
+				d.Pass, d.Target = false, nil
+			}
+		}
+		res[i] = d
+	}
+	return res
+}
@@ -0,0 +1,181 @@
+package filters
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// A leaf rename template is a mapping whose last component contains the subtree wildcard '<',
+// e.g. `tank/prod<` => `backup/archive/prod-<`.
+// Each child of the pattern's path is mapped below the template's parent,
+// with '<' in the template's last component replaced by the child's name,
+// and the child's subtree follows unchanged:
+//
+//	tank/prod/db      => backup/archive/prod-db
+//	tank/prod/db/logs => backup/archive/prod-db/logs
+//	tank/prod         => no mapping (there is no leaf to rename)
+type leafRenameTemplate struct {
+	parent         *zfs.DatasetPath
+	prefix, suffix string // of the renamed leaf
+}
+
+func isLeafRenameTemplate(mapping string) bool {
+	return strings.Contains(mapping, SUBTREE_PATTERN)
+}
+
+func parseLeafRenameTemplate(mapping string) (*leafRenameTemplate, error) {
+	if strings.Count(mapping, SUBTREE_PATTERN) != 1 {
+		return nil, fmt.Errorf("leaf rename template must contain exactly one '<'")
+	}
+	parentStr, leaf := "", mapping
+	if i := strings.LastIndex(mapping, "/"); i >= 0 {
+		parentStr, leaf = mapping[:i], mapping[i+1:]
+	}
+	if !strings.Contains(leaf, SUBTREE_PATTERN) {
+		return nil, fmt.Errorf("'<' is only allowed in the last component of a leaf rename template")
+	}
+	if leaf == SUBTREE_PATTERN {
+		return nil, fmt.Errorf("leaf rename template must not be just '<', use a subtree mapping to %q instead", parentStr)
+	}
+	parent, err := zfs.NewDatasetPath(parentStr)
+	if err != nil {
+		return nil, fmt.Errorf("leaf rename template parent is not a dataset path: %s", err)
+	}
+	if parent.Length() == 0 {
+		return nil, fmt.Errorf("leaf rename template must not rename pools")
+	}
+	t := &leafRenameTemplate{
+		parent: parent,
+		prefix: leaf[:strings.Index(leaf, SUBTREE_PATTERN)],
+		suffix: leaf[strings.Index(leaf, SUBTREE_PATTERN)+1:],
+	}
+	// validate the characters of prefix and suffix
+	if _, err := zfs.NewDatasetPath(t.rename("x")); err != nil {
+		return nil, fmt.Errorf("leaf rename template produces invalid dataset names: %s", err)
+	}
+	return t, nil
+}
+
+func (t *leafRenameTemplate) rename(leaf string) string {
+	return t.prefix + leaf + t.suffix
+}
+
+// Map returns nil if rel, the path of the source relative to the pattern's path, is empty.
+func (t *leafRenameTemplate) Map(rel *zfs.DatasetPath) (*zfs.DatasetPath, error) {
+	if rel.Length() == 0 {
+		return nil, nil
+	}
+	comps := strings.Split(rel.ToString(), "/")
+	comps[0] = t.rename(comps[0])
+	renamed, err := zfs.NewDatasetPath(strings.Join(comps, "/"))
+	if err != nil {
+		return nil, err
+	}
+	return t.parent.Extended(renamed), nil
+}
+
+// Unmap inverts Map: it returns the path relative to the pattern's path that Map maps to target,
+// or nil if t does not produce target.
+func (t *leafRenameTemplate) Unmap(target *zfs.DatasetPath) *zfs.DatasetPath {
+	if !t.mayProduce(target) {
+		return nil
+	}
+	rel, _ := target.Relative(t.parent)
+	comps := strings.Split(rel.ToString(), "/")
+	comps[0] = strings.TrimSuffix(strings.TrimPrefix(comps[0], t.prefix), t.suffix)
+	orig, err := zfs.NewDatasetPath(strings.Join(comps, "/"))
+	if err != nil {
+		return nil
+	}
+	return orig
+}
+
+// matchesLeaf returns true if t can produce leaf for some non-empty source leaf name.
+func (t *leafRenameTemplate) matchesLeaf(leaf string) bool {
+	return len(leaf) > len(t.prefix)+len(t.suffix) &&
+		strings.HasPrefix(leaf, t.prefix) && strings.HasSuffix(leaf, t.suffix)
+}
+
+// mayProduce returns true if t could map a source dataset to p or to an ancestor of p.
+func (t *leafRenameTemplate) mayProduce(p *zfs.DatasetPath) bool {
+	if !p.HasPrefix(t.parent) || p.Length() == t.parent.Length() {
+		return false
+	}
+	rel, _ := p.Relative(t.parent)
+	return t.matchesLeaf(strings.Split(rel.ToString(), "/")[0])
+}
+
+// overlaps returns true if t and o could map two different source datasets to the same target.
+func (t *leafRenameTemplate) overlaps(o *leafRenameTemplate) bool {
+	switch {
+	case t.parent.Equal(o.parent):
+		// there are leafs x, y with t.prefix+x+t.suffix == o.prefix+y+o.suffix
+		// iff one prefix is a prefix of the other and one suffix is a suffix of the other
+		prefixes := strings.HasPrefix(t.prefix, o.prefix) || strings.HasPrefix(o.prefix, t.prefix)
+		suffixes := strings.HasSuffix(t.suffix, o.suffix) || strings.HasSuffix(o.suffix, t.suffix)
+		return prefixes && suffixes
+	case o.parent.HasPrefix(t.parent):
+		return t.mayProduce(o.parent)
+	case t.parent.HasPrefix(o.parent):
+		return o.mayProduce(t.parent)
+	default:
+		return false
+	}
+}
+
+// checkUnambiguous returns an error if the mapping of the new entry e
+// could produce the same target as one of the existing mapping entries.
+func (m *DatasetMapFilter) checkUnambiguous(e datasetMapFilterEntry) error {
+	if strings.HasPrefix(e.mapping, MapFilterResultOmit) {
+		return nil
+	}
+	for _, o := range m.entries {
+		if o.path.Equal(e.path) {
+			continue // direct and subtree entry for the same path
+		}
+		if strings.HasPrefix(o.mapping, MapFilterResultOmit) {
+			continue
+		}
+		var ambiguous bool
+		switch {
+		case e.leafRename != nil && o.leafRename != nil:
+			ambiguous = e.leafRename.overlaps(o.leafRename)
+		case e.leafRename != nil:
+			ambiguous = mappingTargetOverlaps(e.leafRename, o.mapping)
+		case o.leafRename != nil:
+			ambiguous = mappingTargetOverlaps(o.leafRename, e.mapping)
+		default:
+			ambiguous = e.mapping == o.mapping
+		}
+		if ambiguous {
+			return fmt.Errorf("mapping target %q is ambiguous with mapping target %q of pattern %q",
+				e.mapping, o.mapping, o.path.ToString())
+		}
+	}
+	return nil
+}
+
+func mappingTargetOverlaps(t *leafRenameTemplate, mapping string) bool {
+	p, err := zfs.NewDatasetPath(mapping)
+	if err != nil {
+		return false // reported by Map
+	}
+	return t.mayProduce(p)
+}
+
+// DatasetRenameMapFromConfig builds a mapping from the `rename` option of a receiving job.
+// Rejecting entries are not allowed because a receiver receives all filesystems that the sender sends.
+func DatasetRenameMapFromConfig(in map[string]string) (*DatasetMapFilter, error) {
+	m := NewDatasetMapFilter(len(in), false)
+	for pathPattern, mapping := range in {
+		if strings.HasPrefix(mapping, MapFilterResultOmit) {
+			return nil, fmt.Errorf("invalid rename entry ['%s':'%s']: rename targets must not reject filesystems", pathPattern, mapping)
+		}
+		if err := m.Add(pathPattern, mapping); err != nil {
+			return nil, fmt.Errorf("invalid rename entry ['%s':'%s']: %s", pathPattern, mapping, err)
+		}
+	}
+	return m, nil
+}
@@ -1,8 +1,12 @@
 package filters
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
 	"github.com/zrepl/zrepl/zfs"
 )
 
@@ -87,3 +91,318 @@ func TestDatasetMapFilter(t *testing.T) {
 	}
 
 }
+
+func TestDatasetMapFilter_MayPassWithin(t *testing.T) {
+
+	filter := map[string]string{
+		"tank/data<":     "ok",
+		"tank/data/tmp":  "!",
+		"tank/home/bob":  "ok",
+		"tank/home/bob<": "!",
+		"tank/vms<":      "!",
+		"tank/vms/web":   "ok",
+		"zroot/tmp<":     "!",
+	}
+	f := NewDatasetMapFilter(len(filter), true)
+	for p, a := range filter {
+		if err := f.Add(p, a); err != nil {
+			t.Fatalf("incorrect filter spec: %s", err)
+		}
+	}
+
+	checkPass := map[string]bool{
+		"tank":              true,
+		"tank/data":         true,
+		"tank/data/tmp":     true, // children of tank/data/tmp pass
+		"tank/data/backups": true,
+		"tank/home":         true,
+		"tank/home/bob/x":   false,
+		"tank/vms":          true,
+		"tank/vms/db":       false,
+		"tank/other":        false,
+		"zroot":             false,
+		"zroot/tmp":         false,
+	}
+	for p, expect := range checkPass {
+		zp, err := zfs.NewDatasetPath(p)
+		if err != nil {
+			t.Fatalf("incorrect path spec: %s", err)
+		}
+		pass, err := f.MayPassWithin(zp)
+		if err != nil {
+			t.Fatalf("unexpected filter error: %s", err)
+		}
+		if pass != expect {
+			t.Errorf("%q: expected %v, got %v", p, expect, pass)
+		}
+	}
+}
+
+func TestDatasetMapFilter_MaxLengthNames(t *testing.T) {
+	long := "tank/" + strings.Repeat("a", zfs.MaxDatasetNameLen-len("tank/"))
+
+	f := NewDatasetMapFilter(1, true)
+	assert.Error(t, f.Add(long, "ok"), "a configured path must leave room for snapshot names")
+	require.NoError(t, f.Add("tank<", "ok"))
+
+	// but existing datasets may have names of the maximum length
+	p, err := zfs.NewDatasetPath(long)
+	require.NoError(t, err)
+	pass, err := f.Filter(p)
+	require.NoError(t, err)
+	assert.True(t, pass)
+}
+
+func TestDatasetMapFilter_LeafRename(t *testing.T) {
+
+	type testCase struct {
+		name     string
+		mapping  map[string]string
+		checkMap map[string]string // source => expected target, "" if not mapped
+	}
+
+	tcs := []testCase{
+		{
+			"rename_leaf_of_direct_child",
+			map[string]string{
+				"tank/prod<": "backup/archive/prod-<",
+			},
+			map[string]string{
+				"tank/prod":            "",
+				"tank/prod/db":         "backup/archive/prod-db",
+				"tank/prod/db/logs":    "backup/archive/prod-db/logs",
+				"tank/prod/web/static": "backup/archive/prod-web/static",
+				"tank/dev/db":          "",
+			},
+		},
+		{
+			"reparent_plus_rename",
+			map[string]string{
+				"tank<":         "backup/tank",
+				"tank/prod<":    "backup/archive/<-prod",
+				"tank/prod/tmp": "!",
+			},
+			map[string]string{
+				"tank":              "backup/tank",
+				"tank/dev/db":       "backup/tank/dev/db",
+				"tank/prod":         "",
+				"tank/prod/db":      "backup/archive/db-prod",
+				"tank/prod/db/logs": "backup/archive/db-prod/logs",
+				"tank/prod/tmp":     "",
+			},
+		},
+		{
+			"explicit_rename_of_single_dataset",
+			map[string]string{
+				"tank/prod/db<": "backup/archive/prod-db",
+				"tank/prod<":    "backup/prod",
+			},
+			map[string]string{
+				"tank/prod/db":   "backup/archive/prod-db",
+				"tank/prod/db/x": "backup/archive/prod-db/x",
+				"tank/prod/web":  "backup/prod/web",
+			},
+		},
+		{
+			"disjoint_templates_in_same_parent",
+			map[string]string{
+				"tank/prod<": "backup/prod-<",
+				"tank/dev<":  "backup/dev-<",
+				"zroot<":     "backup/zroot-<",
+			},
+			map[string]string{
+				"tank/prod/db": "backup/prod-db",
+				"tank/dev/db":  "backup/dev-db",
+				"zroot/db":     "backup/zroot-db",
+			},
+		},
+	}
+
+	for tc := range tcs {
+		t.Run(tcs[tc].name, func(t *testing.T) {
+			c := tcs[tc]
+			m := NewDatasetMapFilter(len(c.mapping), false)
+			for p, mapping := range c.mapping {
+				require.NoError(t, m.Add(p, mapping), "%q => %q", p, mapping)
+			}
+			for src, expect := range c.checkMap {
+				target, err := m.Map(path(t, src))
+				require.NoError(t, err)
+				if expect == "" {
+					assert.Nil(t, target, "%q", src)
+				} else if assert.NotNil(t, target, "%q", src) {
+					assert.Equal(t, expect, target.ToString(), "%q", src)
+					source, err := m.Unmap(target)
+					require.NoError(t, err)
+					if assert.NotNil(t, source, "%q", expect) {
+						assert.Equal(t, src, source.ToString(), "%q", expect)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDatasetMapFilter_LeafRenameValidation(t *testing.T) {
+
+	invalid := []map[string]string{
+		{"tank/prod": "backup/prod-<"},                 // requires subtree pattern
+		{"tank/prod<": "backup/<"},                     // plain subtree mapping to backup
+		{"tank/prod<": "backup/<-<"},                   // more than one '<'
+		{"tank/prod<": "backup/prod-</x"},              // '<' not in last component
+		{"tank/prod<": "prod-<"},                       // would rename pools
+		{"tank/prod<": "backup/prod@<"},                // invalid character
+		{"tank/a<": "backup/x", "tank/b<": "backup/x"}, // same target
+		// overlapping templates
+		{"tank/a<": "backup/a-<", "tank/b<": "backup/a-<"},
+		{"tank/a<": "backup/a-<", "tank/b<": "backup/<" + "-x"},
+		{"tank/a<": "backup/a<", "tank/b<": "backup/ab<"},
+		{"tank/a<": "backup/<-x", "tank/b<": "backup/<-y-x"},
+		{"tank/a<": "backup/a-<", "tank/b<": "backup/<.b"}, // tank/a/x.b and tank/b/a-x => backup/a-x.b
+		{"tank/a<": "backup/a-<", "tank/b<": "backup/a-1/b-<"},
+		// template that may produce another entry's target
+		{"tank/a<": "backup/a-<", "tank/b": "backup/a-b"},
+		{"tank/a<": "backup/a-<", "tank/b<": "backup/a-b/c"},
+	}
+	for _, mapping := range invalid {
+		var err error
+		m := NewDatasetMapFilter(len(mapping), false)
+		for p, target := range mapping {
+			if err = m.Add(p, target); err != nil {
+				break
+			}
+		}
+		assert.Error(t, err, "%v", mapping)
+	}
+
+	valid := []map[string]string{
+		{"tank/a<": "backup/a-<", "tank/b<": "backup/b-<"},
+		{"tank/a<": "backup/<-a", "tank/b<": "backup/<-b"},
+		{"tank/a<": "backup/a-<", "tank/b<": "backup/a-"},    // template only produces non-empty leafs
+		{"tank/a<": "backup/a-<", "tank/b<": "backup/b/a-x"}, // different parent
+		{"tank/a<": "backup/a-<", "tank/a": "backup/a"},      // direct entry for the same path
+		{"tank/a<": "backup/a-<", "tank/a/tmp<": "!"},        // rejections never collide
+	}
+	for _, mapping := range valid {
+		m := NewDatasetMapFilter(len(mapping), false)
+		for p, target := range mapping {
+			assert.NoError(t, m.Add(p, target), "%v", mapping)
+		}
+	}
+}
+
+func TestDatasetMapFilter_Unmap(t *testing.T) {
+	m := NewDatasetMapFilter(3, false)
+	require.NoError(t, m.Add("tank<", "backup/tank"))
+	require.NoError(t, m.Add("tank/prod<", "archive/prod-<"))
+	require.NoError(t, m.Add("tank/web", "archive/web"))
+
+	unmap := func(target string) string {
+		source, err := m.Unmap(path(t, target))
+		require.NoError(t, err)
+		if source == nil {
+			return ""
+		}
+		return source.ToString()
+	}
+	assert.Equal(t, "tank/prod/db/logs", unmap("archive/prod-db/logs"))
+	assert.Equal(t, "tank/dev", unmap("backup/tank/dev"))
+	assert.Equal(t, "tank/web", unmap("archive/web"))
+	assert.Equal(t, "", unmap("archive"), "no entry produces the parent of the targets")
+	assert.Equal(t, "", unmap("archive/web/x"), "direct entries do not produce children")
+	assert.Equal(t, "", unmap("archive/other"))
+	assert.Equal(t, "", unmap("backup/tank/prod/db"), "tank/prod/db is renamed by a more specific entry")
+	assert.Equal(t, "", unmap("backup/tank/web"), "tank/web is mapped by a more specific entry")
+}
+
+func TestDatasetRenameMapFromConfig(t *testing.T) {
+	m, err := DatasetRenameMapFromConfig(map[string]string{"tank/prod<": "archive/prod-<"})
+	require.NoError(t, err)
+	target, err := m.Map(path(t, "tank/prod/db"))
+	require.NoError(t, err)
+	assert.Equal(t, "archive/prod-db", target.ToString())
+
+	_, err = DatasetRenameMapFromConfig(map[string]string{"tank/prod<": "!"})
+	assert.Error(t, err)
+	_, err = DatasetRenameMapFromConfig(map[string]string{"tank/a<": "x", "tank/b<": "x"})
+	assert.Error(t, err)
+}
+
+func path(t *testing.T, p string) *zfs.DatasetPath {
+	zp, err := zfs.NewDatasetPath(p)
+	require.NoError(t, err)
+	return zp
+}
+
+func TestDatasetMapFilter_Explain(t *testing.T) {
+
+	paths := func(ps ...string) []*zfs.DatasetPath {
+		res := make([]*zfs.DatasetPath, len(ps))
+		for i, p := range ps {
+			var err error
+			res[i], err = zfs.NewDatasetPath(p)
+			require.NoError(t, err)
+		}
+		return res
+	}
+
+	t.Run("filter", func(t *testing.T) {
+		f, err := DatasetMapFilterFromConfig(map[string]bool{
+			"tank<":         true,
+			"tank/tmp<":     false,
+			"tank/home/bob": true,
+		})
+		require.NoError(t, err)
+		ds := f.Explain(paths("zroot", "tank/home", "tank/tmp/foo", "tank/home/bob"))
+		require.Len(t, ds, 4)
+
+		assert.Equal(t, "zroot", ds[0].Path.ToString())
+		assert.False(t, ds[0].Pass)
+		assert.Equal(t, "", ds[0].Pattern)
+
+		assert.True(t, ds[1].Pass)
+		assert.Equal(t, "tank<", ds[1].Pattern)
+		assert.Equal(t, MapFilterResultOk, ds[1].Mapping)
+
+		assert.False(t, ds[2].Pass)
+		assert.Equal(t, "tank/tmp<", ds[2].Pattern)
+		assert.Equal(t, MapFilterResultOmit, ds[2].Mapping)
+
+		assert.True(t, ds[3].Pass)
+		assert.Equal(t, "tank/home/bob", ds[3].Pattern)
+
+		for _, d := range ds {
+			assert.Nil(t, d.Target)
+			assert.NoError(t, d.Err)
+		}
+	})
+
+	t.Run("mapping", func(t *testing.T) {
+		m := NewDatasetMapFilter(3, false)
+		require.NoError(t, m.Add("tank<", "backup/tank"))
+		require.NoError(t, m.Add("tank/prod<", "backup/archive/<-prod"))
+		require.NoError(t, m.Add("tank/prod/tmp", "!"))
+		ds := m.Explain(paths("tank/dev", "tank/prod", "tank/prod/db", "tank/prod/tmp", "zroot"))
+		require.Len(t, ds, 5)
+
+		assert.True(t, ds[0].Pass)
+		assert.Equal(t, "tank<", ds[0].Pattern)
+		assert.Equal(t, "backup/tank/dev", ds[0].Target.ToString())
+
+		assert.False(t, ds[1].Pass, "leaf rename template does not map its own path")
+		assert.Equal(t, "tank/prod<", ds[1].Pattern)
+		assert.Nil(t, ds[1].Target)
+
+		assert.True(t, ds[2].Pass)
+		assert.Equal(t, "tank/prod<", ds[2].Pattern)
+		assert.Equal(t, "backup/archive/<-prod", ds[2].Mapping)
+		assert.Equal(t, "backup/archive/db-prod", ds[2].Target.ToString())
+
+		assert.False(t, ds[3].Pass)
+		assert.Equal(t, "tank/prod/tmp", ds[3].Pattern)
+		assert.Nil(t, ds[3].Target)
+
+		assert.False(t, ds[4].Pass)
+		assert.Equal(t, "", ds[4].Pattern)
+	})
+}
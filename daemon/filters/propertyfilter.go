@@ -0,0 +1,106 @@
+package filters
+
+import (
+	"regexp"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// PropertyFilter restricts the datasets passed by a name filter to those
+// whose ZFS property has a matching value.
+//
+// The property values are only known while listing datasets using zfs.ZFSListMapping,
+// see zfs.DatasetPropertyFilter. Filter, which only gets the dataset name,
+// returns the result of the most recent listing for that dataset, or false if it was not part of any listing.
+type PropertyFilter struct {
+	names    zfs.DatasetFilter
+	property string
+	match    func(value string) bool
+	inherit  bool // if false, only locally set values match
+
+	mtx        sync.Mutex
+	lastResult map[string]bool // keyed by dataset name
+}
+
+var _ zfs.DatasetPropertyFilter = (*PropertyFilter)(nil)
+
+func NewPropertyFilter(names zfs.DatasetFilter, property string, match func(value string) bool, inherit bool) *PropertyFilter {
+	return &PropertyFilter{
+		names:      names,
+		property:   property,
+		match:      match,
+		inherit:    inherit,
+		lastResult: make(map[string]bool),
+	}
+}
+
+// FilesystemsFilterFromConfig returns the *DatasetMapFilter for fss if prop is nil,
+// and a *PropertyFilter that restricts it otherwise.
+func FilesystemsFilterFromConfig(fss config.FilesystemsFilter, prop *config.FilesystemPropertyFilter) (zfs.DatasetFilter, error) {
+	names, err := DatasetMapFilterFromConfig(fss)
+	if err != nil {
+		return nil, err
+	}
+	if prop == nil {
+		return names, nil
+	}
+	if prop.Name == "" {
+		return nil, errors.New("filesystem_property: name must not be empty")
+	}
+	var match func(string) bool
+	switch {
+	case prop.Value != "" && prop.Regex != "":
+		return nil, errors.New("filesystem_property: value and regex are mutually exclusive")
+	case prop.Value != "":
+		match = func(v string) bool { return v == prop.Value }
+	case prop.Regex != "":
+		re, err := regexp.Compile(prop.Regex)
+		if err != nil {
+			return nil, errors.Wrap(err, "filesystem_property: invalid regex")
+		}
+		match = re.MatchString
+	default:
+		return nil, errors.New("filesystem_property: one of value or regex must be set")
+	}
+	return NewPropertyFilter(names, prop.Name, match, prop.Inherit), nil
+}
+
+// NameFilter returns the filter that f restricts if f is a *PropertyFilter, and f otherwise.
+// Use it where only dataset names are available, e.g. for validation of the configuration.
+func NameFilter(f zfs.DatasetFilter) zfs.DatasetFilter {
+	if pf, ok := f.(*PropertyFilter); ok {
+		return pf.names
+	}
+	return f
+}
+
+func (f *PropertyFilter) Filter(p *zfs.DatasetPath) (pass bool, err error) {
+	pass, err = f.names.Filter(p)
+	if err != nil || !pass {
+		return pass, err
+	}
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.lastResult[p.ToString()], nil
+}
+
+func (f *PropertyFilter) FilterProperties() []string { return []string{f.property} }
+
+func (f *PropertyFilter) FilterWithProperties(p *zfs.DatasetPath, props map[string]zfs.PropertyValue) (pass bool, err error) {
+	pass, err = f.names.Filter(p)
+	if err != nil {
+		return false, err
+	}
+	if pass {
+		v, ok := props[f.property]
+		pass = ok && f.match(v.Value) && (f.inherit || v.IsLocal())
+	}
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.lastResult[p.ToString()] = pass
+	return pass, nil
+}
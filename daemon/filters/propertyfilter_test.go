@@ -0,0 +1,72 @@
+package filters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestFilesystemsFilterFromConfig(t *testing.T) {
+	fss := config.FilesystemsFilter{"pool<": true}
+
+	f, err := FilesystemsFilterFromConfig(fss, nil)
+	require.NoError(t, err)
+	assert.IsType(t, &DatasetMapFilter{}, f)
+
+	for _, invalid := range []config.FilesystemPropertyFilter{
+		{Name: "", Value: "gold"},
+		{Name: "backup:policy"},
+		{Name: "backup:policy", Value: "gold", Regex: "gold"},
+		{Name: "backup:policy", Regex: "("},
+	} {
+		invalid := invalid
+		_, err := FilesystemsFilterFromConfig(fss, &invalid)
+		assert.Error(t, err, "%#v", invalid)
+	}
+
+	f, err = FilesystemsFilterFromConfig(fss, &config.FilesystemPropertyFilter{Name: "backup:policy", Regex: "^(gold|silver)$", Inherit: true})
+	require.NoError(t, err)
+	assert.IsType(t, &PropertyFilter{}, f)
+	assert.IsType(t, &DatasetMapFilter{}, NameFilter(f))
+}
+
+func TestPropertyFilter(t *testing.T) {
+	names, err := DatasetMapFilterFromConfig(map[string]bool{"pool<": true, "pool/tmp": false})
+	require.NoError(t, err)
+
+	path := func(s string) *zfs.DatasetPath {
+		p, err := zfs.NewDatasetPath(s)
+		require.NoError(t, err)
+		return p
+	}
+	props := func(value, source string) map[string]zfs.PropertyValue {
+		return map[string]zfs.PropertyValue{"backup:policy": {Value: value, Source: source}}
+	}
+	match := func(v string) bool { return v == "gold" }
+
+	for _, inherit := range []bool{true, false} {
+		f := NewPropertyFilter(names, "backup:policy", match, inherit)
+
+		check := func(p string, props map[string]zfs.PropertyValue) bool {
+			pass, err := f.FilterWithProperties(path(p), props)
+			require.NoError(t, err)
+			return pass
+		}
+		assert.True(t, check("pool/a", props("gold", "local")))
+		assert.Equal(t, inherit, check("pool/a/b", props("gold", "inherited from pool/a")))
+		assert.False(t, check("pool/c", props("silver", "local")))
+		assert.False(t, check("pool/d", nil), "properties unknown")
+		assert.False(t, check("pool/tmp", props("gold", "local")), "rejected by name filter")
+
+		// Filter returns the result of the most recent listing
+		for p, expect := range map[string]bool{"pool/a": true, "pool/a/b": inherit, "pool/c": false, "pool/never/listed": false} {
+			pass, err := f.Filter(path(p))
+			require.NoError(t, err)
+			assert.Equal(t, expect, pass, "%s inherit=%v", p, inherit)
+		}
+	}
+}
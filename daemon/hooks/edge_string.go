@@ -11,11 +11,13 @@ func _() {
 	_ = x[Pre-1]
 	_ = x[Callback-2]
 	_ = x[Post-4]
+	_ = x[Err-8]
 }
 
 const (
 	_Edge_name_0 = "PreCallback"
 	_Edge_name_1 = "Post"
+	_Edge_name_2 = "Err"
 )
 
 var (
@@ -29,6 +31,8 @@ func (i Edge) String() string {
 		return _Edge_name_0[_Edge_index_0[i]:_Edge_index_0[i+1]]
 	case i == 4:
 		return _Edge_name_1
+	case i == 8:
+		return _Edge_name_2
 	default:
 		return "Edge(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
@@ -0,0 +1,37 @@
+package hooks
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// checkCommandPath returns a descriptive error if command cannot be executed by the current user.
+// The errors returned by exec.Cmd.Start for these cases (e.g. `permission denied`) do not say what is wrong.
+func checkCommandPath(command string) error {
+	path := command
+	if !strings.Contains(command, "/") {
+		// same resolution as exec.Command
+		resolved, err := exec.LookPath(command)
+		if err != nil {
+			return fmt.Errorf("hook command %q not found in $PATH", command)
+		}
+		path = resolved
+	}
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("hook path %q does not exist", path)
+	} else if err != nil {
+		return fmt.Errorf("cannot stat hook path: %s", err)
+	}
+	if fi.IsDir() {
+		return fmt.Errorf("hook path %q is a directory", path)
+	}
+	if err := unix.Access(path, unix.X_OK); err != nil {
+		return fmt.Errorf("hook %q not executable by current user (mode %s): %s", path, fi.Mode(), err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package hooks
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckCommandPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zrepl-hook-path")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	executable := filepath.Join(dir, "hook.sh")
+	require.NoError(t, ioutil.WriteFile(executable, []byte("#!/bin/sh\n"), 0755))
+	notExecutable := filepath.Join(dir, "hook.txt")
+	require.NoError(t, ioutil.WriteFile(notExecutable, []byte("#!/bin/sh\n"), 0644))
+
+	assert.NoError(t, checkCommandPath(executable))
+
+	for path, expect := range map[string]string{
+		dir:                           "is a directory",
+		notExecutable:                 "not executable by current user",
+		filepath.Join(dir, "missing"): "does not exist",
+		"zrepl-no-such-hook-command":  "not found in $PATH",
+	} {
+		err := checkCommandPath(path)
+		if assert.Error(t, err, path) {
+			assert.Contains(t, err.Error(), expect)
+		}
+	}
+}
@@ -9,6 +9,48 @@ import (
 
 type List []Hook
 
+// TimeoutPolicy determines whether a timed-out Pre edge invocation is fatal (see Hook.TimeoutIsFatal).
+type TimeoutPolicy string
+
+const (
+	// A timeout is handled like any other error, i.e., it is fatal iff the hook's ErrIsFatal() == true.
+	TimeoutLikeError TimeoutPolicy = "like_error"
+	TimeoutFatal     TimeoutPolicy = "fatal"
+	TimeoutContinue  TimeoutPolicy = "continue"
+)
+
+func timeoutIsFatalFromConfig(in config.HookSettingsCommon) (bool, error) {
+	switch TimeoutPolicy(in.OnTimeout) {
+	case TimeoutLikeError:
+		return in.ErrIsFatal, nil
+	case TimeoutFatal:
+		return true, nil
+	case TimeoutContinue:
+		return false, nil
+	default:
+		return false, fmt.Errorf("invalid `on_timeout` value %q", in.OnTimeout)
+	}
+}
+
+// OutputMode determines how the output of a command hook is logged.
+type OutputMode string
+
+const (
+	// Every line of output is logged as soon as the hook has written it.
+	OutputLines OutputMode = "lines"
+	// The output is logged as a single block per stream once the hook has exited.
+	OutputGrouped OutputMode = "grouped"
+)
+
+func outputModeFromConfig(in string) (OutputMode, error) {
+	switch OutputMode(in) {
+	case OutputLines, OutputGrouped:
+		return OutputMode(in), nil
+	default:
+		return "", fmt.Errorf("invalid `output` value %q", in)
+	}
+}
+
 func HookFromConfig(in config.HookEnum) (Hook, error) {
 	switch v := in.Ret.(type) {
 	case *config.HookCommand:
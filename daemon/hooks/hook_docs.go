@@ -23,7 +23,7 @@
 //
 // Then create a CallbackHook using NewCallbackHookForFilesystem().
 //
-// Pass all of the above to NewPlan() which provides a Report() and Run() method:
+// Pass all of the above to NewPlan(), along with the job's Metrics, which provides a Report() and Run() method:
 //
 // Plan.Run(ctx context.Context,dryRun bool) executes the plan and take a context as argument that should contain a logger added using hooks.WithLogger()).
 // The value of dryRun is passed through to the hooks' Run() method.
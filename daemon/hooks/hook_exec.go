@@ -21,6 +21,12 @@ type Hook interface {
 	// If true and the Pre edge invocation of Run fails, Post edge will not run and other Pre edges will not run.
 	ErrIsFatal() bool
 
+	// Like ErrIsFatal, but for Pre edge invocations of Run that fail with HookReport.HadTimeout() == true.
+	TimeoutIsFatal() bool
+
+	// If true, Run is also invoked for the Err edge if a Pre edge invocation or the callback fails.
+	RunOnErr() bool
+
 	// Run is invoked by HookPlan for a Pre edge.
 	// If HookReport.HadError() == false, the Post edge will be invoked, too.
 	Run(ctx context.Context, edge Edge, phase Phase, dryRun bool, extra Env, state map[interface{}]interface{}) HookReport
@@ -46,6 +52,7 @@ const (
 	Pre = Edge(1 << iota)
 	Callback
 	Post
+	Err
 )
 
 func (e Edge) StringForPhase(phase Phase) string {
@@ -62,11 +69,16 @@ const (
 	StepErr
 	StepSkippedDueToFatalErr
 	StepSkippedDueToPreErr
+	StepSkippedNoErr // Err edge if neither Pre edges nor the callback failed
 )
 
 type HookReport interface {
 	String() string
 	HadError() bool
+	// HadTimeout() == true implies HadError() == true.
+	// A timed-out hook did not necessarily finish what it was doing,
+	// e.g., it might have quiesced an application but not resumed it.
+	HadTimeout() bool
 	Error() string
 }
 
@@ -101,14 +113,17 @@ type Plan struct {
 	pre   []*Step // protected by mtx
 	cb    *Step
 	post  []*Step // not reversed, i.e. entry at index i corresponds to pre-edge in pre[i]
+	err   []*Step // only for hooks with RunOnErr() == true, in configuration order
 
-	phase Phase
-	env   Env
+	phase   Phase
+	env     Env
+	metrics *Metrics // may be nil
 }
 
-func NewPlan(hooks *List, phase Phase, cb *CallbackHook, extra Env) (*Plan, error) {
+// metrics may be nil
+func NewPlan(hooks *List, phase Phase, cb *CallbackHook, extra Env, metrics *Metrics) (*Plan, error) {
 
-	var pre, post []*Step
+	var pre, post, errE []*Step
 	// TODO sanity check unique name of hook?
 	for _, hook := range *hooks {
 		state := make(map[interface{}]interface{})
@@ -126,6 +141,14 @@ func NewPlan(hooks *List, phase Phase, cb *CallbackHook, extra Env) (*Plan, erro
 			state:  state,
 		}
 		post = append(post, postE)
+		if hook.RunOnErr() {
+			errE = append(errE, &Step{
+				Hook:   hook,
+				Edge:   Err,
+				Status: StepPending,
+				state:  state,
+			})
+		}
 	}
 
 	cbE := &Step{
@@ -134,20 +157,23 @@ func NewPlan(hooks *List, phase Phase, cb *CallbackHook, extra Env) (*Plan, erro
 		Status: StepPending,
 	}
 
-	steps := make([]*Step, 0, len(pre)+len(post)+1)
+	steps := make([]*Step, 0, len(pre)+len(post)+1+len(errE))
 	steps = append(steps, pre...)
 	steps = append(steps, cbE)
 	for i := len(post) - 1; i >= 0; i-- {
 		steps = append(steps, post[i])
 	}
+	steps = append(steps, errE...)
 
 	plan := &Plan{
-		phase: phase,
-		env:   extra,
-		steps: steps,
-		pre:   pre,
-		post:  post,
-		cb:    cbE,
+		phase:   phase,
+		env:     extra,
+		steps:   steps,
+		pre:     pre,
+		post:    post,
+		err:     errE,
+		cb:      cbE,
+		metrics: metrics,
 	}
 
 	return plan, nil
@@ -201,11 +227,12 @@ func (p *Plan) Run(ctx context.Context, dryRun bool) {
 		defer p.mtx.Unlock()
 		f()
 	}
-	runHook := func(s *Step, ctx context.Context, edge Edge) HookReport {
+	runHook := func(s *Step, ctx context.Context, edge Edge, env Env) HookReport {
 		w(func() { s.Status = StepExec })
 		begin := time.Now()
-		r := s.Hook.Run(ctx, edge, p.phase, dryRun, p.env, s.state)
+		r := s.Hook.Run(ctx, edge, p.phase, dryRun, env, s.state)
 		end := time.Now()
+		p.metrics.observe(edge, r, end.Sub(begin))
 		w(func() {
 			s.Report = r
 			s.Status = StepOk
@@ -219,6 +246,67 @@ func (p *Plan) Run(ctx context.Context, dryRun bool) {
 
 	l := getLogger(ctx)
 
+	// cause is the first failed Pre edge invocation or callback, nil if there was none
+	runErrEdges := func(cause HookReport) {
+		if cause == nil {
+			w(func() {
+				for _, e := range p.err {
+					e.Status = StepSkippedNoErr
+				}
+			})
+			return
+		}
+		if len(p.err) == 0 {
+			return
+		}
+		l.Info("run err-edges in configuration order")
+		env := make(Env, len(p.env)+1)
+		for k, v := range p.env {
+			env[k] = v
+		}
+		env[EnvError] = cause.Error()
+		for _, e := range p.err {
+			// failing err-edges are reported, but the cause remains the first failed step of the plan
+			if r := runHook(e, ctx, Err, env); r.HadError() {
+				l.WithField("hook", e.Hook).WithError(r).Error("hook invocation failed for err-edge")
+			}
+		}
+	}
+
+	// runs the post-edges of the pre-edges up to and including index last in reverse order,
+	// i.e., unwinds the stack of the pre-edges that ran
+	runPostEdges := func(last int) {
+		l.Info("run post-edges for successful pre-edges in reverse configuration order")
+
+		// the constructor produces pre and post entries
+		// post is NOT reversed
+		for i := last; i >= 0; i-- {
+			e := p.post[i]
+			l := l.WithField("hook", e.Hook)
+
+			if p.pre[i].Status != StepOk {
+				if p.pre[i].Status != StepErr {
+					panic(fmt.Sprintf("expecting a pre-edge hook report to be either Ok or Err, got %s", p.pre[i].Status))
+				}
+				l.Info("skip post-edge because pre-edge failed")
+				w(func() {
+					e.Status = StepSkippedDueToPreErr
+				})
+				continue
+			}
+
+			report := runHook(e, ctx, Post, p.env)
+
+			if report.HadError() {
+				l.WithError(report).Error("hook invocation failed for post-edge")
+				l.Error("subsequent post-edges run regardless of this post-edge failure")
+			}
+
+			// ErrIsFatal is only relevant for Pre
+		}
+	}
+	var cause HookReport
+
 	// it's a stack, execute until we reach the end of the list (last item in)
 	// or fail inbetween
 	l.Info("run pre-edges in configuration order")
@@ -226,10 +314,19 @@ func (p *Plan) Run(ctx context.Context, dryRun bool) {
 	for ; next < len(p.pre); next++ {
 		e := p.pre[next]
 		l := l.WithField("hook", e.Hook)
-		r := runHook(e, ctx, Pre)
+		r := runHook(e, ctx, Pre, p.env)
 		if r.HadError() {
-			l.WithError(r).Error("hook invocation failed for pre-edge")
-			if e.Hook.ErrIsFatal() {
+			if cause == nil {
+				cause = r
+			}
+			fatal := e.Hook.ErrIsFatal()
+			if r.HadTimeout() {
+				l.WithError(r).Error("hook invocation timed out for pre-edge")
+				fatal = e.Hook.TimeoutIsFatal()
+			} else {
+				l.WithError(r).Error("hook invocation failed for pre-edge")
+			}
+			if fatal {
 				l.Error("the hook run was aborted due to a fatal error in this hook")
 				break
 			}
@@ -249,44 +346,20 @@ func (p *Plan) Run(ctx context.Context, dryRun bool) {
 			}
 			p.cb.Status = StepSkippedDueToFatalErr
 		})
+		runPostEdges(next - 1)
+		runErrEdges(cause)
 		return
 	}
 
 	l.Info("running callback")
-	cbR := runHook(p.cb, ctx, Callback)
+	cbR := runHook(p.cb, ctx, Callback, p.env)
 	if cbR.HadError() {
 		l.WithError(cbR).Error("callback failed")
-	}
-
-	l.Info("run post-edges for successful pre-edges in reverse configuration order")
-
-	// the constructor produces pre and post entries
-	// post is NOT reversed
-	next-- // now at index of last executed pre-edge
-	for ; next >= 0; next-- {
-		e := p.post[next]
-		l := l.WithField("hook", e.Hook)
-
-		if p.pre[next].Status != StepOk {
-			if p.pre[next].Status != StepErr {
-				panic(fmt.Sprintf("expecting a pre-edge hook report to be either Ok or Err, got %s", p.pre[next].Status))
-			}
-			l.Info("skip post-edge because pre-edge failed")
-			w(func() {
-				e.Status = StepSkippedDueToPreErr
-			})
-			continue
+		if cause == nil {
+			cause = cbR
 		}
-
-		report := runHook(e, ctx, Post)
-
-		if report.HadError() {
-			l.WithError(report).Error("hook invocation failed for post-edge")
-			l.Error("subsequent post-edges run regardless of this post-edge failure")
-		}
-
-		// ErrIsFatal is only relevant for Pre
-
 	}
 
+	runPostEdges(next - 1)
+	runErrEdges(cause)
 }
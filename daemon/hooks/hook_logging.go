@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"strings"
 	"sync"
 
 	"github.com/zrepl/zrepl/daemon/logging"
@@ -37,6 +38,7 @@ type logWriter struct {
 	logger  Logger
 	level   logger.Level
 	field   string
+	group   *outputGroup // if non-nil, lines are collected in group instead of being logged
 }
 
 func NewLogWriter(mtx *sync.Mutex, logger Logger, level logger.Level, field string) *logWriter {
@@ -50,6 +52,10 @@ func NewLogWriter(mtx *sync.Mutex, logger Logger, level logger.Level, field stri
 }
 
 func (w *logWriter) log(line string) {
+	if w.group != nil {
+		w.group.add(w.level, w.field, line)
+		return
+	}
 	w.logger.WithField(w.field, line).Log(w.level, "hook output")
 }
 
@@ -89,3 +95,48 @@ func (w *logWriter) Close() (err error) {
 
 	return w.logUnreadBytes()
 }
+
+// outputGroup collects the lines written by a hook to its logWriters
+// so that they can be logged as a single block per stream after the hook has exited.
+// Access is synchronized by the mutex shared among the logWriters.
+type outputGroup struct {
+	streams []*outputGroupStream // in order of first output
+	size    int
+}
+
+type outputGroupStream struct {
+	level     logger.Level
+	field     string
+	lines     []string
+	truncated bool
+}
+
+func (g *outputGroup) add(level logger.Level, field string, line string) {
+	var s *outputGroupStream
+	for _, c := range g.streams {
+		if c.field == field {
+			s = c
+			break
+		}
+	}
+	if s == nil {
+		s = &outputGroupStream{level: level, field: field}
+		g.streams = append(g.streams, s)
+	}
+	if g.size+len(line) > envconst.Int("ZREPL_MAX_HOOK_LOG_SIZE", MAX_HOOK_LOG_SIZE_DEFAULT) {
+		s.truncated = true
+		return
+	}
+	g.size += len(line)
+	s.lines = append(s.lines, line)
+}
+
+func (g *outputGroup) Log(l Logger) {
+	for _, s := range g.streams {
+		out := strings.Join(s.lines, "\n")
+		if s.truncated {
+			out += "\n[output truncated]"
+		}
+		l.WithField(s.field, out).Log(s.level, "hook output")
+	}
+}
@@ -0,0 +1,76 @@
+package hooks
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics counts the hook invocations of a job.
+//
+// Label cardinality is bounded by the number of edges: the callback is not a hook
+// from the user's perspective and not counted, individual hooks are not distinguished.
+type Metrics struct {
+	runs     *prometheus.CounterVec   // labels: edge
+	failures *prometheus.CounterVec   // labels: edge
+	timeouts *prometheus.CounterVec   // labels: edge
+	runTime  *prometheus.HistogramVec // labels: edge
+}
+
+func NewMetrics(jobName string) *Metrics {
+	constLabels := prometheus.Labels{"zrepl_job": jobName}
+	return &Metrics{
+		runs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "zrepl",
+			Subsystem:   "hooks",
+			Name:        "runs",
+			Help:        "number of hook invocations",
+			ConstLabels: constLabels,
+		}, []string{"edge"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "zrepl",
+			Subsystem:   "hooks",
+			Name:        "failures",
+			Help:        "number of hook invocations that failed, including timeouts",
+			ConstLabels: constLabels,
+		}, []string{"edge"}),
+		timeouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   "zrepl",
+			Subsystem:   "hooks",
+			Name:        "timeouts",
+			Help:        "number of hook invocations that timed out",
+			ConstLabels: constLabels,
+		}, []string{"edge"}),
+		runTime: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   "zrepl",
+			Subsystem:   "hooks",
+			Name:        "run_time",
+			Help:        "seconds spent per hook invocation",
+			ConstLabels: constLabels,
+		}, []string{"edge"}),
+	}
+}
+
+func (m *Metrics) Register(registerer prometheus.Registerer) {
+	registerer.MustRegister(m.runs)
+	registerer.MustRegister(m.failures)
+	registerer.MustRegister(m.timeouts)
+	registerer.MustRegister(m.runTime)
+}
+
+// m may be nil
+func (m *Metrics) observe(edge Edge, r HookReport, d time.Duration) {
+	if m == nil || edge == Callback {
+		return
+	}
+	e := strings.ToLower(edge.String())
+	m.runs.WithLabelValues(e).Inc()
+	if r.HadError() {
+		m.failures.WithLabelValues(e).Inc()
+	}
+	if r.HadTimeout() {
+		m.timeouts.WithLabelValues(e).Inc()
+	}
+	m.runTime.WithLabelValues(e).Observe(d.Seconds())
+}
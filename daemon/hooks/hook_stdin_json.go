@@ -0,0 +1,54 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+type contextKey int
+
+const contextKeyPlanFilesystems contextKey = 1 + iota
+
+// WithPlanFilesystems attaches the names of all filesystems that are processed
+// together with the hook's filesystem, e.g., in the same snapshotting round,
+// to the hook context written to command hooks with stdin_json.
+func WithPlanFilesystems(ctx context.Context, fss []string) context.Context {
+	return context.WithValue(ctx, contextKeyPlanFilesystems, fss)
+}
+
+func getPlanFilesystems(ctx context.Context) []string {
+	fss, _ := ctx.Value(contextKeyPlanFilesystems).([]string)
+	return fss
+}
+
+// CommandHookStdinJSON is the hook context written to the stdin of command hooks with stdin_json.
+// It is a superset of the hook's environment variables.
+type CommandHookStdinJSON struct {
+	Type        string    `json:"type"` // ZREPL_HOOKTYPE, e.g. pre_snapshot
+	Edge        string    `json:"edge"` // pre, post or err
+	Phase       string    `json:"phase"`
+	DryRun      bool      `json:"dry_run"`
+	Filesystem  string    `json:"filesystem"`
+	Snapshot    string    `json:"snapshot"`
+	Error       string    `json:"error,omitempty"`       // only for the err edge
+	Filesystems []string  `json:"filesystems,omitempty"` // see WithPlanFilesystems
+	Time        time.Time `json:"time"`                  // start of the invocation
+	Timeout     float64   `json:"timeout_seconds"`
+}
+
+func newCommandHookStdinJSON(ctx context.Context, edge Edge, phase Phase, dryRun bool, timeout time.Duration, env Env, now time.Time) ([]byte, error) {
+	return json.Marshal(CommandHookStdinJSON{
+		Type:        env[EnvType],
+		Edge:        strings.ToLower(edge.String()),
+		Phase:       phase.String(),
+		DryRun:      dryRun,
+		Filesystem:  env[EnvFS],
+		Snapshot:    env[EnvSnapshot],
+		Error:       env[EnvError],
+		Filesystems: getPlanFilesystems(ctx),
+		Time:        now,
+		Timeout:     timeout.Seconds(),
+	})
+}
@@ -37,6 +37,14 @@ func (h *CallbackHook) ErrIsFatal() bool {
 	return false // callback is by definition
 }
 
+func (h *CallbackHook) TimeoutIsFatal() bool {
+	return false // callback has no timeout
+}
+
+func (h *CallbackHook) RunOnErr() bool {
+	return false
+}
+
 func (h *CallbackHook) String() string {
 	return h.displayString
 }
@@ -53,7 +61,8 @@ func (r *CallbackHookReport) String() string {
 	return r.Name
 }
 
-func (r *CallbackHookReport) HadError() bool { return r.Err != nil }
+func (r *CallbackHookReport) HadError() bool   { return r.Err != nil }
+func (r *CallbackHookReport) HadTimeout() bool { return false }
 
 func (r *CallbackHookReport) Error() string {
 	return fmt.Sprintf("%s error: %s", r.Name, r.Err)
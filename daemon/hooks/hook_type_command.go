@@ -1,6 +1,7 @@
 package hooks
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -10,6 +11,7 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/zrepl/zrepl/config"
@@ -27,6 +29,8 @@ const (
 	EnvFS       HookEnvVar = "ZREPL_FS"
 	EnvSnapshot HookEnvVar = "ZREPL_SNAPNAME"
 	EnvTimeout  HookEnvVar = "ZREPL_TIMEOUT"
+	// only set for the Err edge
+	EnvError HookEnvVar = "ZREPL_ERROR"
 )
 
 type Env map[HookEnvVar]string
@@ -55,18 +59,24 @@ func NewHookEnv(edge Edge, phase Phase, dryRun bool, timeout time.Duration, extr
 }
 
 type CommandHook struct {
-	edge       Edge
-	filter     Filter
-	errIsFatal bool
-	command    string
-	timeout    time.Duration
+	edge           Edge
+	filter         Filter
+	errIsFatal     bool
+	timeoutIsFatal bool
+	command        string
+	timeout        time.Duration
+	graceTimeout   time.Duration // 0 means SIGKILL without prior SIGTERM
+	output         OutputMode
+	stdinJSON      bool
 }
 
 type CommandHookReport struct {
-	Command                      string
-	Args                         []string // currently always empty
-	Env                          Env
-	Err                          error
+	Command string
+	Args    []string // currently always empty
+	Env     Env
+	Err     error
+	// true if the command was killed because it exceeded its timeout, implies Err != nil
+	TimedOut                     bool
 	CapturedStdoutStderrCombined []byte
 }
 
@@ -106,11 +116,17 @@ func (r *CommandHookReport) HadError() bool {
 	return r.Err != nil
 }
 
+func (r *CommandHookReport) HadTimeout() bool {
+	return r.TimedOut
+}
+
 func NewCommandHook(in *config.HookCommand) (r *CommandHook, err error) {
 	r = &CommandHook{
-		errIsFatal: in.ErrIsFatal,
-		command:    in.Path,
-		timeout:    in.Timeout,
+		errIsFatal:   in.ErrIsFatal,
+		command:      in.Path,
+		timeout:      in.Timeout,
+		graceTimeout: in.GraceTimeout,
+		stdinJSON:    in.StdinJSON,
 	}
 
 	r.filter, err = filters.DatasetMapFilterFromConfig(in.Filesystems)
@@ -118,7 +134,20 @@ func NewCommandHook(in *config.HookCommand) (r *CommandHook, err error) {
 		return nil, fmt.Errorf("cannot parse filesystem filter: %s", err)
 	}
 
+	r.timeoutIsFatal, err = timeoutIsFatalFromConfig(in.HookSettingsCommon)
+	if err != nil {
+		return nil, err
+	}
+
+	r.output, err = outputModeFromConfig(in.Output)
+	if err != nil {
+		return nil, err
+	}
+
 	r.edge = Pre | Post
+	if in.RunOnErr {
+		r.edge |= Err
+	}
 
 	return r, nil
 }
@@ -131,6 +160,14 @@ func (h *CommandHook) ErrIsFatal() bool {
 	return h.errIsFatal
 }
 
+func (h *CommandHook) TimeoutIsFatal() bool {
+	return h.timeoutIsFatal
+}
+
+func (h *CommandHook) RunOnErr() bool {
+	return h.edge&Err != 0
+}
+
 func (h *CommandHook) String() string {
 	return h.command
 }
@@ -141,7 +178,8 @@ func (h *CommandHook) Run(ctx context.Context, edge Edge, phase Phase, dryRun bo
 	cmdCtx, cancel := context.WithTimeout(ctx, h.timeout)
 	defer cancel()
 
-	cmdExec := exec.CommandContext(cmdCtx, h.command)
+	// not exec.CommandContext, which kills the process without a grace period, see terminateOnDone
+	cmdExec := exec.Command(h.command)
 
 	hookEnv := NewHookEnv(edge, phase, dryRun, h.timeout, extra)
 	cmdEnv := os.Environ()
@@ -150,6 +188,15 @@ func (h *CommandHook) Run(ctx context.Context, edge Edge, phase Phase, dryRun bo
 	}
 	cmdExec.Env = cmdEnv
 
+	if h.stdinJSON {
+		stdin, err := newCommandHookStdinJSON(ctx, edge, phase, dryRun, h.timeout, hookEnv, time.Now())
+		if err != nil {
+			return &CommandHookReport{Err: err}
+		}
+		// package exec closes the command's stdin after writing it
+		cmdExec.Stdin = bytes.NewReader(stdin)
+	}
+
 	var scanMutex sync.Mutex
 	combinedOutput, err := circlog.NewCircularLog(envconst.Int("ZREPL_MAX_HOOK_LOG_SIZE", MAX_HOOK_LOG_SIZE_DEFAULT))
 	if err != nil {
@@ -157,6 +204,13 @@ func (h *CommandHook) Run(ctx context.Context, edge Edge, phase Phase, dryRun bo
 	}
 	logErrWriter := NewLogWriter(&scanMutex, l, logger.Warn, "stderr")
 	logOutWriter := NewLogWriter(&scanMutex, l, logger.Info, "stdout")
+	if h.output == OutputGrouped {
+		// deferred calls run in reverse order: the writers flush their last lines into the group first
+		var group outputGroup
+		logErrWriter.group = &group
+		logOutWriter.group = &group
+		defer group.Log(l)
+	}
 	defer logErrWriter.Close()
 	defer logOutWriter.Close()
 
@@ -169,24 +223,70 @@ func (h *CommandHook) Run(ctx context.Context, edge Edge, phase Phase, dryRun bo
 		// no report.Args
 	}
 
+	if err := checkCommandPath(h.command); err != nil {
+		report.Err = err
+		return report
+	}
+
 	err = cmdExec.Start()
 	if err != nil {
 		report.Err = err
 		return report
 	}
 
+	exited := make(chan struct{})
+	terminated := make(chan struct{})
+	go func() {
+		defer close(terminated)
+		h.terminateOnDone(cmdCtx, l, cmdExec.Process, exited)
+	}()
 	err = cmdExec.Wait()
+	close(exited)
+	<-terminated
 	combinedOutputBytes := combinedOutput.Bytes()
 	report.CapturedStdoutStderrCombined = make([]byte, len(combinedOutputBytes))
 	copy(report.CapturedStdoutStderrCombined, combinedOutputBytes)
-	if err != nil {
-		if cmdCtx.Err() == context.DeadlineExceeded {
-			report.Err = fmt.Errorf("timed out after %s: %s", h.timeout, err)
-			return report
+	if cmdCtx.Err() == context.DeadlineExceeded {
+		// also if the hook exited successfully after SIGTERM
+		if err == nil {
+			err = fmt.Errorf("exited after SIGTERM")
 		}
+		report.Err = fmt.Errorf("timed out after %s: %s", h.timeout, err)
+		report.TimedOut = true
+		return report
+	}
+	if err != nil {
 		report.Err = err
 		return report
 	}
 
 	return report
 }
+
+// terminateOnDone stops p once ctx is done, unless p has exited before.
+// If the hook has a grace timeout, p receives SIGTERM and is only killed
+// if it does not exit within the grace timeout.
+func (h *CommandHook) terminateOnDone(ctx context.Context, l Logger, p *os.Process, exited <-chan struct{}) {
+	select {
+	case <-exited:
+		return
+	case <-ctx.Done():
+	}
+	if h.graceTimeout > 0 {
+		l.WithField("grace_timeout", h.graceTimeout).Warn("sending SIGTERM to hook")
+		if err := p.Signal(syscall.SIGTERM); err != nil {
+			l.WithError(err).Warn("cannot send SIGTERM to hook")
+		} else {
+			select {
+			case <-exited:
+				return
+			case <-time.After(h.graceTimeout):
+			}
+			l.Warn("hook did not exit within grace timeout")
+		}
+	}
+	l.Warn("killing hook")
+	if err := p.Kill(); err != nil {
+		l.WithError(err).Warn("cannot kill hook")
+	}
+}
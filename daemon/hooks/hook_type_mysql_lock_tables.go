@@ -31,9 +31,10 @@ import (
 //
 //	Similar snapshot capabilities may be available in other file systems, such as LVM or ZFS.
 type MySQLLockTables struct {
-	errIsFatal  bool
-	connector   sqldriver.Connector
-	filesystems Filter
+	errIsFatal     bool
+	timeoutIsFatal bool
+	connector      sqldriver.Connector
+	filesystems    Filter
 }
 
 type myLockTablesStateKey int
@@ -57,16 +58,24 @@ func MyLockTablesFromConfig(in *config.HookMySQLLockTables) (*MySQLLockTables, e
 		return nil, errors.Wrap(err, "`filesystems` invalid")
 	}
 
+	timeoutIsFatal, err := timeoutIsFatalFromConfig(in.HookSettingsCommon)
+	if err != nil {
+		return nil, err
+	}
+
 	return &MySQLLockTables{
 		in.ErrIsFatal,
+		timeoutIsFatal,
 		cn,
 		filesystems,
 	}, nil
 }
 
-func (h *MySQLLockTables) ErrIsFatal() bool    { return h.errIsFatal }
-func (h *MySQLLockTables) Filesystems() Filter { return h.filesystems }
-func (h *MySQLLockTables) String() string      { return "MySQL FLUSH TABLES WITH READ LOCK" }
+func (h *MySQLLockTables) ErrIsFatal() bool     { return h.errIsFatal }
+func (h *MySQLLockTables) TimeoutIsFatal() bool { return h.timeoutIsFatal }
+func (h *MySQLLockTables) RunOnErr() bool       { return false }
+func (h *MySQLLockTables) Filesystems() Filter  { return h.filesystems }
+func (h *MySQLLockTables) String() string       { return "MySQL FLUSH TABLES WITH READ LOCK" }
 
 type MyLockTablesReport struct {
 	What string
@@ -74,7 +83,10 @@ type MyLockTablesReport struct {
 }
 
 func (r *MyLockTablesReport) HadError() bool { return r.Err != nil }
-func (r *MyLockTablesReport) Error() string  { return r.String() }
+func (r *MyLockTablesReport) HadTimeout() bool {
+	return errors.Cause(r.Err) == context.DeadlineExceeded
+}
+func (r *MyLockTablesReport) Error() string { return r.String() }
 func (r *MyLockTablesReport) String() string {
 	var s strings.Builder
 	s.WriteString(r.What)
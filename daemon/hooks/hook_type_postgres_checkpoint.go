@@ -16,9 +16,10 @@ import (
 )
 
 type PgChkptHook struct {
-	errIsFatal  bool
-	connector   *pq.Connector
-	filesystems Filter
+	errIsFatal     bool
+	timeoutIsFatal bool
+	connector      *pq.Connector
+	filesystems    Filter
 }
 
 func PgChkptHookFromConfig(in *config.HookPostgresCheckpoint) (*PgChkptHook, error) {
@@ -30,22 +31,32 @@ func PgChkptHookFromConfig(in *config.HookPostgresCheckpoint) (*PgChkptHook, err
 	if err != nil {
 		return nil, errors.Wrap(err, "`dsn` invalid")
 	}
+	timeoutIsFatal, err := timeoutIsFatalFromConfig(in.HookSettingsCommon)
+	if err != nil {
+		return nil, err
+	}
 
 	return &PgChkptHook{
 		in.ErrIsFatal,
+		timeoutIsFatal,
 		cn,
 		filesystems,
 	}, nil
 }
 
-func (h *PgChkptHook) ErrIsFatal() bool    { return h.errIsFatal }
-func (h *PgChkptHook) Filesystems() Filter { return h.filesystems }
-func (h *PgChkptHook) String() string      { return "postgres checkpoint" }
+func (h *PgChkptHook) ErrIsFatal() bool     { return h.errIsFatal }
+func (h *PgChkptHook) TimeoutIsFatal() bool { return h.timeoutIsFatal }
+func (h *PgChkptHook) RunOnErr() bool       { return false }
+func (h *PgChkptHook) Filesystems() Filter  { return h.filesystems }
+func (h *PgChkptHook) String() string       { return "postgres checkpoint" }
 
 type PgChkptHookReport struct{ Err error }
 
 func (r *PgChkptHookReport) HadError() bool { return r.Err != nil }
-func (r *PgChkptHookReport) Error() string  { return r.Err.Error() }
+func (r *PgChkptHookReport) HadTimeout() bool {
+	return errors.Cause(r.Err) == context.DeadlineExceeded
+}
+func (r *PgChkptHookReport) Error() string { return r.Err.Error() }
 func (r *PgChkptHookReport) String() string {
 	if r.Err != nil {
 		return fmt.Sprintf("postgres CHECKPOINT failed: %s", r.Err)
@@ -3,12 +3,16 @@ package hooks_test
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"regexp"
+	"sync"
 	"testing"
 	"text/template"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
 	"github.com/zrepl/zrepl/daemon/logging/trace"
 
@@ -131,6 +135,44 @@ jobs:
 				},
 			},
 		},
+		testCase{
+			Name:                  "timeout_fatal_but_error_not",
+			IsSlow:                true,
+			ExpectCallbackSkipped: true,
+			ExpectHadFatalErr:     true,
+			ExpectHadError:        true,
+			Config:                []string{`{type: command, path: {{.WorkDir}}/test/test-timeout.sh, timeout: 2s, on_timeout: fatal}`},
+			ExpectStepReports: []expectStep{
+				expectStep{
+					ExpectedEdge: hooks.Pre,
+					ExpectStatus: hooks.StepErr,
+					ErrorTest:    regexpTest(`timed out after 2(.\d+)?s`),
+				},
+				expectStep{ExpectedEdge: hooks.Callback, ExpectStatus: hooks.StepSkippedDueToFatalErr},
+				expectStep{
+					ExpectedEdge: hooks.Post,
+					ExpectStatus: hooks.StepSkippedDueToFatalErr,
+				},
+			},
+		},
+		testCase{
+			Name:           "timeout_continues_but_error_fatal",
+			IsSlow:         true,
+			ExpectHadError: true,
+			Config:         []string{`{type: command, path: {{.WorkDir}}/test/test-timeout.sh, timeout: 2s, err_is_fatal: true, on_timeout: continue}`},
+			ExpectStepReports: []expectStep{
+				expectStep{
+					ExpectedEdge: hooks.Pre,
+					ExpectStatus: hooks.StepErr,
+					ErrorTest:    regexpTest(`timed out after 2(.\d+)?s`),
+				},
+				expectStep{ExpectedEdge: hooks.Callback, ExpectStatus: hooks.StepOk},
+				expectStep{
+					ExpectedEdge: hooks.Post,
+					ExpectStatus: hooks.StepSkippedDueToPreErr,
+				},
+			},
+		},
 		testCase{
 			Name:           "check_env",
 			Config:         []string{`{type: command, path: {{.WorkDir}}/test/test-report-env.sh}`},
@@ -202,6 +244,31 @@ jobs:
 			},
 		},
 
+		testCase{
+			Name:                  "pre_error_fatal_runs_post_edges_of_previous_successful_pre_edges",
+			ExpectCallbackSkipped: true,
+			ExpectHadFatalErr:     true,
+			ExpectHadError:        true,
+			Config: []string{
+				`{type: command, path: {{.WorkDir}}/test/test-report-env.sh}`,
+				`{type: command, path: {{.WorkDir}}/test/test-error.sh, err_is_fatal: true}`,
+				`{type: command, path: {{.WorkDir}}/test/test-report-env.sh}`,
+			},
+			ExpectStepReports: []expectStep{
+				expectStep{ExpectedEdge: hooks.Pre, ExpectStatus: hooks.StepOk},
+				expectStep{ExpectedEdge: hooks.Pre, ExpectStatus: hooks.StepErr},
+				expectStep{ExpectedEdge: hooks.Pre, ExpectStatus: hooks.StepSkippedDueToFatalErr},
+				expectStep{ExpectedEdge: hooks.Callback, ExpectStatus: hooks.StepSkippedDueToFatalErr},
+				expectStep{ExpectedEdge: hooks.Post, ExpectStatus: hooks.StepSkippedDueToFatalErr},
+				expectStep{ExpectedEdge: hooks.Post, ExpectStatus: hooks.StepSkippedDueToFatalErr},
+				expectStep{
+					ExpectedEdge: hooks.Post,
+					ExpectStatus: hooks.StepOk,
+					OutputTest:   containsTest(fmt.Sprintf("TEST post_testing %s@%s", testFSName, testSnapshotName)),
+				},
+			},
+		},
+
 		testCase{
 			Name:              "post_error_fails_are_ignored_even_if_fatal",
 			ExpectHadFatalErr: false, // only occurs during Post, so it's not a fatal error
@@ -333,6 +400,75 @@ jobs:
 			},
 		},
 
+		testCase{
+			Name:           "err_edge_runs_after_pre_error",
+			ExpectHadError: true,
+			Config: []string{
+				`{type: command, path: {{.WorkDir}}/test/test-error.sh}`,
+				`{type: command, path: {{.WorkDir}}/test/test-report-err.sh, run_on_err: true}`,
+			},
+			ExpectStepReports: []expectStep{
+				expectStep{
+					ExpectedEdge: hooks.Pre,
+					ExpectStatus: hooks.StepErr,
+					ErrorTest:    regexpTest("^command hook invocation.*exit status 1$"),
+				},
+				expectStep{
+					ExpectedEdge: hooks.Pre,
+					ExpectStatus: hooks.StepOk,
+					OutputTest:   containsTest(fmt.Sprintf("TEST pre_testing %s@%s ZREPL_ERROR=\n", testFSName, testSnapshotName)),
+				},
+				expectStep{ExpectedEdge: hooks.Callback, ExpectStatus: hooks.StepOk},
+				expectStep{
+					ExpectedEdge: hooks.Post,
+					ExpectStatus: hooks.StepOk,
+				},
+				expectStep{
+					ExpectedEdge: hooks.Post,
+					ExpectStatus: hooks.StepSkippedDueToPreErr,
+				},
+				expectStep{
+					ExpectedEdge: hooks.Err,
+					ExpectStatus: hooks.StepOk,
+					OutputTest:   regexpTest(fmt.Sprintf("TEST err_testing %s@%s ZREPL_ERROR=command hook invocation.*test-error.sh.*exit status 1", testFSName, testSnapshotName)),
+				},
+			},
+		},
+
+		testCase{
+			Name:                  "err_edge_runs_after_fatal_pre_error",
+			ExpectCallbackSkipped: true,
+			ExpectHadFatalErr:     true,
+			ExpectHadError:        true,
+			Config: []string{
+				`{type: command, path: {{.WorkDir}}/test/test-error.sh, err_is_fatal: true}`,
+				`{type: command, path: {{.WorkDir}}/test/test-report-err.sh, run_on_err: true}`,
+			},
+			ExpectStepReports: []expectStep{
+				expectStep{ExpectedEdge: hooks.Pre, ExpectStatus: hooks.StepErr},
+				expectStep{ExpectedEdge: hooks.Pre, ExpectStatus: hooks.StepSkippedDueToFatalErr},
+				expectStep{ExpectedEdge: hooks.Callback, ExpectStatus: hooks.StepSkippedDueToFatalErr},
+				expectStep{ExpectedEdge: hooks.Post, ExpectStatus: hooks.StepSkippedDueToFatalErr},
+				expectStep{ExpectedEdge: hooks.Post, ExpectStatus: hooks.StepSkippedDueToFatalErr},
+				expectStep{
+					ExpectedEdge: hooks.Err,
+					ExpectStatus: hooks.StepOk,
+					OutputTest:   containsTest(fmt.Sprintf("TEST err_testing %s@%s ZREPL_ERROR=command hook invocation", testFSName, testSnapshotName)),
+				},
+			},
+		},
+
+		testCase{
+			Name:   "err_edge_skipped_without_error",
+			Config: []string{`{type: command, path: {{.WorkDir}}/test/test-report-err.sh, run_on_err: true}`},
+			ExpectStepReports: []expectStep{
+				expectStep{ExpectedEdge: hooks.Pre, ExpectStatus: hooks.StepOk},
+				expectStep{ExpectedEdge: hooks.Callback, ExpectStatus: hooks.StepOk},
+				expectStep{ExpectedEdge: hooks.Post, ExpectStatus: hooks.StepOk},
+				expectStep{ExpectedEdge: hooks.Err, ExpectStatus: hooks.StepSkippedNoErr},
+			},
+		},
+
 		/*
 			Following not intended to test functionality of
 			filter package. Only to demonstrate that hook
@@ -417,7 +553,11 @@ jobs:
 
 			filteredHooks, err := hookList.CopyFilteredForFilesystem(fs)
 			require.NoError(t, err)
-			plan, err := hooks.NewPlan(&filteredHooks, hooks.PhaseTesting, cb, hookEnvExtra)
+			metrics := hooks.NewMetrics("TestHooks")
+			registry := prometheus.NewPedanticRegistry()
+			metrics.Register(registry)
+
+			plan, err := hooks.NewPlan(&filteredHooks, hooks.PhaseTesting, cb, hookEnvExtra, metrics)
 			require.NoError(t, err)
 			t.Logf("REPORT PRE EXECUTION:\n%s", plan.Report())
 
@@ -483,6 +623,220 @@ jobs:
 				}
 			}
 
+			// Check that the metrics agree with the report
+			var expectRuns, expectFailures float64
+			for _, r := range report {
+				if r.Edge == hooks.Callback || r.Report == nil {
+					continue
+				}
+				expectRuns++
+				if r.Report.HadError() {
+					expectFailures++
+				}
+			}
+			require.Equal(t, expectRuns, sumCounter(t, registry, "zrepl_hooks_runs"))
+			require.Equal(t, expectFailures, sumCounter(t, registry, "zrepl_hooks_failures"))
+
+		})
+	}
+}
+
+func sumCounter(t *testing.T, registry *prometheus.Registry, name string) (sum float64) {
+	mfs, err := registry.Gather()
+	require.NoError(t, err)
+	for _, mf := range mfs {
+		if mf.GetName() != name {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			sum += m.GetCounter().GetValue()
+		}
+	}
+	return sum
+}
+
+type captureOutlet struct {
+	mtx     sync.Mutex
+	entries []logger.Entry
+}
+
+func (o *captureOutlet) WriteEntry(e logger.Entry) error {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	o.entries = append(o.entries, e)
+	return nil
+}
+
+func TestCommandHookOutputModes(t *testing.T) {
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+
+	type logLine struct {
+		Level  logger.Level
+		Field  string
+		Output string
+	}
+
+	run := func(t *testing.T, output string) []logLine {
+		ctx, end := trace.WithTaskFromStack(context.Background())
+		defer end()
+
+		outlet := &captureOutlet{}
+		outlets := logger.NewOutlets()
+		outlets.Add(outlet, logger.Debug)
+		ctx = logging.WithLoggers(ctx, logging.SubsystemLoggersWithUniversalLogger(logger.NewLogger(outlets, 0)))
+
+		h, err := hooks.NewCommandHook(&config.HookCommand{
+			Path:               cwd + "/test/test-stdout-stderr.sh",
+			Timeout:            10 * time.Second,
+			Output:             output,
+			HookSettingsCommon: config.HookSettingsCommon{OnTimeout: string(hooks.TimeoutLikeError)},
+		})
+		require.NoError(t, err)
+		report := h.Run(ctx, hooks.Pre, hooks.PhaseTesting, false, hooks.Env{}, nil)
+		require.False(t, report.HadError(), "%s", report)
+
+		var lines []logLine
+		for _, e := range outlet.entries {
+			if e.Message != "hook output" {
+				continue
+			}
+			for _, f := range []string{"stdout", "stderr"} {
+				if v, ok := e.Fields[f]; ok {
+					lines = append(lines, logLine{e.Level, f, v.(string)})
+				}
+			}
+		}
+		return lines
+	}
+
+	t.Run("lines", func(t *testing.T) {
+		lines := run(t, "lines")
+		var stdout, stderr []string
+		for _, l := range lines {
+			switch l.Field {
+			case "stdout":
+				require.Equal(t, logger.Info, l.Level)
+				stdout = append(stdout, l.Output)
+			case "stderr":
+				require.Equal(t, logger.Warn, l.Level)
+				stderr = append(stderr, l.Output)
+			}
+		}
+		require.Equal(t, []string{"out 1", "out 2"}, stdout)
+		require.Equal(t, []string{"err 1", "err 2"}, stderr)
+	})
+
+	t.Run("grouped", func(t *testing.T) {
+		lines := run(t, "grouped")
+		require.ElementsMatch(t, []logLine{
+			{logger.Info, "stdout", "out 1\nout 2"},
+			{logger.Warn, "stderr", "err 1\nerr 2"},
+		}, lines)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := hooks.NewCommandHook(&config.HookCommand{
+			Path:               cwd + "/test/test-stdout-stderr.sh",
+			Output:             "bogus",
+			HookSettingsCommon: config.HookSettingsCommon{OnTimeout: string(hooks.TimeoutLikeError)},
+		})
+		require.Error(t, err)
+	})
+}
+
+func TestCommandHookStdinJSON(t *testing.T) {
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+	ctx = logging.WithLoggers(ctx, logging.SubsystemLoggersWithUniversalLogger(logger.NewTestLogger(t)))
+	ctx = hooks.WithPlanFilesystems(ctx, []string{"pool/a", "pool/b"})
+
+	run := func(t *testing.T, stdinJSON bool) []byte {
+		h, err := hooks.NewCommandHook(&config.HookCommand{
+			Path:               cwd + "/test/test-stdin-json.sh",
+			Timeout:            10 * time.Second,
+			Output:             string(hooks.OutputLines),
+			StdinJSON:          stdinJSON,
+			HookSettingsCommon: config.HookSettingsCommon{OnTimeout: string(hooks.TimeoutLikeError)},
 		})
+		require.NoError(t, err)
+		env := hooks.Env{hooks.EnvFS: "pool/a", hooks.EnvSnapshot: "zrepl_1"}
+		report := h.Run(ctx, hooks.Post, hooks.PhaseTesting, true, env, nil)
+		require.False(t, report.HadError(), "%s", report)
+		return report.(*hooks.CommandHookReport).CapturedStdoutStderrCombined
 	}
+
+	t.Run("enabled", func(t *testing.T) {
+		var doc hooks.CommandHookStdinJSON
+		require.NoError(t, json.Unmarshal(run(t, true), &doc))
+		require.False(t, doc.Time.IsZero())
+		doc.Time = time.Time{}
+		require.Equal(t, hooks.CommandHookStdinJSON{
+			Type:        "post_testing",
+			Edge:        "post",
+			Phase:       "testing",
+			DryRun:      true,
+			Filesystem:  "pool/a",
+			Snapshot:    "zrepl_1",
+			Filesystems: []string{"pool/a", "pool/b"},
+			Timeout:     10,
+		}, doc)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		require.Empty(t, run(t, false))
+	})
+}
+
+func TestCommandHookGraceTimeout(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+	ctx = logging.WithLoggers(ctx, logging.SubsystemLoggersWithUniversalLogger(logger.NewTestLogger(t)))
+
+	run := func(t *testing.T, script string, graceTimeout time.Duration) (*hooks.CommandHookReport, time.Duration) {
+		h, err := hooks.NewCommandHook(&config.HookCommand{
+			Path:               cwd + "/test/" + script,
+			Timeout:            1 * time.Second,
+			GraceTimeout:       graceTimeout,
+			Output:             string(hooks.OutputLines),
+			HookSettingsCommon: config.HookSettingsCommon{OnTimeout: string(hooks.TimeoutLikeError)},
+		})
+		require.NoError(t, err)
+		begin := time.Now()
+		report := h.Run(ctx, hooks.Pre, hooks.PhaseTesting, false, hooks.Env{}, nil)
+		return report.(*hooks.CommandHookReport), time.Since(begin)
+	}
+
+	t.Run("sigterm", func(t *testing.T) {
+		report, took := run(t, "test-timeout-trap-term.sh", 10*time.Second)
+		require.True(t, report.HadTimeout())
+		require.Regexp(t, `timed out after 1s`, report.Error())
+		require.Contains(t, string(report.CapturedStdoutStderrCombined), "TEST cleanup after SIGTERM")
+		require.True(t, took < 5*time.Second, "hook should exit right after SIGTERM, took %s", took)
+	})
+
+	t.Run("sigkill_after_grace_timeout", func(t *testing.T) {
+		report, took := run(t, "test-timeout-ignore-term.sh", 1*time.Second)
+		require.True(t, report.HadTimeout())
+		require.Regexp(t, `timed out after 1s: signal: killed`, report.Error())
+		require.True(t, took >= 2*time.Second, "hook should be killed after the grace timeout, took %s", took)
+		require.True(t, took < 5*time.Second, "hook should be killed after the grace timeout, took %s", took)
+	})
+
+	t.Run("no_grace_timeout", func(t *testing.T) {
+		report, took := run(t, "test-timeout-trap-term.sh", 0)
+		require.True(t, report.HadTimeout())
+		require.Regexp(t, `signal: killed`, report.Error())
+		require.NotContains(t, string(report.CapturedStdoutStderrCombined), "cleanup")
+		require.True(t, took < 5*time.Second, "took %s", took)
+	})
 }
@@ -13,6 +13,7 @@ const (
 	_StepStatusName_2 = "Err"
 	_StepStatusName_3 = "SkippedDueToFatalErr"
 	_StepStatusName_4 = "SkippedDueToPreErr"
+	_StepStatusName_5 = "SkippedNoErr"
 )
 
 var (
@@ -21,6 +22,7 @@ var (
 	_StepStatusIndex_2 = [...]uint8{0, 3}
 	_StepStatusIndex_3 = [...]uint8{0, 20}
 	_StepStatusIndex_4 = [...]uint8{0, 18}
+	_StepStatusIndex_5 = [...]uint8{0, 12}
 )
 
 func (i StepStatus) String() string {
@@ -36,12 +38,14 @@ func (i StepStatus) String() string {
 		return _StepStatusName_3
 	case i == 32:
 		return _StepStatusName_4
+	case i == 64:
+		return _StepStatusName_5
 	default:
 		return fmt.Sprintf("StepStatus(%d)", i)
 	}
 }
 
-var _StepStatusValues = []StepStatus{1, 2, 4, 8, 16, 32}
+var _StepStatusValues = []StepStatus{1, 2, 4, 8, 16, 32, 64}
 
 var _StepStatusNameToValueMap = map[string]StepStatus{
 	_StepStatusName_0[0:7]:  1,
@@ -50,6 +54,7 @@ var _StepStatusNameToValueMap = map[string]StepStatus{
 	_StepStatusName_2[0:3]:  8,
 	_StepStatusName_3[0:20]: 16,
 	_StepStatusName_4[0:18]: 32,
+	_StepStatusName_5[0:12]: 64,
 }
 
 // StepStatusString retrieves an enum value from the enum constants string name.
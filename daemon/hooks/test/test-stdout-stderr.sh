@@ -0,0 +1,6 @@
+#!/bin/sh -eu
+
+echo "out 1"
+echo "err 1" 1>&2
+echo "out 2"
+echo "err 2" 1>&2
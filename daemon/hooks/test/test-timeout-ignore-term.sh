@@ -0,0 +1,5 @@
+#!/bin/sh -eu
+
+trap '' TERM
+echo "TEST $ZREPL_HOOKTYPE"
+sleep $(($ZREPL_TIMEOUT + 10)) >/dev/null 2>&1
@@ -0,0 +1,7 @@
+#!/bin/sh -eu
+
+# run sleep in the background so that the shell handles SIGTERM while waiting
+sleep $(($ZREPL_TIMEOUT + 10)) >/dev/null 2>&1 &
+trap 'kill $!; echo "TEST cleanup after SIGTERM"; exit 0' TERM
+echo "TEST $ZREPL_HOOKTYPE"
+wait $!
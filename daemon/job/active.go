@@ -21,6 +21,7 @@ import (
 	"github.com/zrepl/zrepl/replication"
 	"github.com/zrepl/zrepl/replication/driver"
 	"github.com/zrepl/zrepl/replication/logic"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
 	"github.com/zrepl/zrepl/replication/report"
 	"github.com/zrepl/zrepl/rpc"
 	"github.com/zrepl/zrepl/transport"
@@ -34,10 +35,16 @@ type ActiveSide struct {
 	connecter transport.Connecter
 
 	prunerFactory *pruner.PrunerFactory
+	// non-nil for the targets of a PushFanOut, see there
+	sharedSenderPruning *fanOutSenderPruning
 
 	promRepStateSecs    *prometheus.HistogramVec // labels: state
 	promPruneSecs       *prometheus.HistogramVec // labels: prune_side
 	promBytesReplicated *prometheus.CounterVec   // labels: filesystem
+	promReplicationLag  *prometheus.GaugeVec     // labels: filesystem
+	invocationMetrics   *invocationMetrics
+
+	maxRunDuration time.Duration // 0 means unlimited
 
 	tasksMtx sync.Mutex
 	tasks    activeSideTasks
@@ -59,12 +66,17 @@ type activeSideTasks struct {
 	// valid for state ActiveSideReplicating, ActiveSidePruneSender, ActiveSidePruneReceiver, ActiveSideDone
 	replicationReport driver.ReportFunc
 	replicationCancel context.CancelFunc
+	// nil if neither endpoint collects RPC statistics
+	replicationRPCStats func() report.RPCStats
 
 	// valid for state ActiveSidePruneSender, ActiveSidePruneReceiver, ActiveSideDone
 	prunerSender, prunerReceiver *pruner.Pruner
 
 	// valid for state ActiveSidePruneReceiver, ActiveSideDone
 	prunerSenderCancel, prunerReceiverCancel context.CancelFunc
+
+	// set after the invocation was cancelled because it exceeded maxRunDuration, in any state
+	timedOut bool
 }
 
 func (a *ActiveSide) updateTasks(u func(*activeSideTasks)) activeSideTasks {
@@ -87,9 +99,32 @@ type activeMode interface {
 	PlannerPolicy() logic.PlannerPolicy
 	RunPeriodic(ctx context.Context, wakeUpCommon chan<- struct{})
 	SnapperReport() *snapper.Report
+	SnapperDumpState() (dump string, ok bool)
+	TriggerSnapshot() error
+	RegisterMetrics(registerer prometheus.Registerer)
 	ResetConnectBackoff()
 }
 
+// implemented by endpoints that collect RPC statistics, i.e., rpc.Client
+type rpcStatser interface {
+	Stats() report.RPCStats
+}
+
+// rpcStatsOf returns the Stats method of the first endpoint that collects RPC statistics, or nil.
+func rpcStatsOf(endpoints ...interface{}) func() report.RPCStats {
+	for _, e := range endpoints {
+		if s, ok := e.(rpcStatser); ok {
+			return s.Stats
+		}
+	}
+	return nil
+}
+
+// optionally implemented by activeMode, invoked after replication
+type activeModeTopologySyncer interface {
+	SyncTopology(ctx context.Context)
+}
+
 type modePush struct {
 	setupMtx      sync.Mutex
 	sender        *endpoint.Sender
@@ -135,6 +170,18 @@ func (m *modePush) SnapperReport() *snapper.Report {
 	return m.snapper.Report()
 }
 
+func (m *modePush) SnapperDumpState() (string, bool) {
+	return m.snapper.DumpState(), true
+}
+
+func (m *modePush) TriggerSnapshot() error {
+	return m.snapper.Trigger()
+}
+
+func (m *modePush) RegisterMetrics(registerer prometheus.Registerer) {
+	m.snapper.RegisterMetrics(registerer)
+}
+
 func (m *modePush) ResetConnectBackoff() {
 	m.setupMtx.Lock()
 	defer m.setupMtx.Unlock()
@@ -146,7 +193,7 @@ func (m *modePush) ResetConnectBackoff() {
 func modePushFromConfig(g *config.Global, in *config.PushJob, jobID endpoint.JobID) (*modePush, error) {
 	m := &modePush{}
 
-	fsf, err := filters.DatasetMapFilterFromConfig(in.Filesystems)
+	fsf, err := filters.FilesystemsFilterFromConfig(in.Filesystems, in.FilesystemProperty)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot build filesystem filter")
 	}
@@ -156,12 +203,30 @@ func modePushFromConfig(g *config.Global, in *config.PushJob, jobID endpoint.Job
 		Encrypt:                     &zfs.NilBool{B: in.Send.Encrypted},
 		DisableIncrementalStepHolds: in.Send.StepHolds.DisableIncremental,
 		JobID:                       jobID,
+		SendOptions:                 sendOptionsFromConfig(in.Send),
+
+		ReleaseStaleStepHoldsOnStartup: in.Send.StepHolds.ReleaseStaleOnStartup,
+		PoolIOThrottle:                 poolIOThrottleFromConfig(in.Send),
+		LastReplicatedProperty:         in.Send.LastReplicatedProperty,
+	}
+	if in.Send.Tee != nil {
+		m.senderConfig.TeeDirectory = in.Send.Tee.Directory
+		if c := in.Send.Tee.Compression; c != nil {
+			m.senderConfig.TeeCompression = endpoint.TeeCompression{Codec: c.Codec, Level: c.Level}
+		}
+	}
+	if naming, ok := in.Snapshotting.Naming(); ok {
+		m.senderConfig.SnapshotPrefix = naming.Prefix
+	}
+	if err := m.senderConfig.Validate(); err != nil {
+		return nil, errors.Wrap(err, "cannot build sender config")
 	}
 	m.plannerPolicy = &logic.PlannerPolicy{
-		EncryptedSend: logic.TriFromBool(in.Send.Encrypted),
+		EncryptedSend:  logic.TriFromBool(in.Send.Encrypted),
+		CompressedSend: logic.TriFromBool(in.Send.Compressed),
 	}
 
-	if m.snapper, err = snapper.FromConfig(g, fsf, in.Snapshotting); err != nil {
+	if m.snapper, err = snapper.FromConfig(g, fsf, in.Snapshotting, jobID.String()); err != nil {
 		return nil, errors.Wrap(err, "cannot build snapper")
 	}
 
@@ -176,6 +241,9 @@ type modePull struct {
 	rootFS         *zfs.DatasetPath
 	plannerPolicy  *logic.PlannerPolicy
 	interval       config.PositiveDurationOrManual
+
+	staleResumeStatePolicy *endpoint.StaleResumeStatePolicy // may be nil
+	topologySyncPolicy     *endpoint.TopologySyncPolicy     // may be nil
 }
 
 func (m *modePull) ConnectEndpoints(ctx context.Context, connecter transport.Connecter) {
@@ -207,6 +275,13 @@ func (*modePull) Type() Type { return TypePull }
 func (m *modePull) PlannerPolicy() logic.PlannerPolicy { return *m.plannerPolicy }
 
 func (m *modePull) RunPeriodic(ctx context.Context, wakeUpCommon chan<- struct{}) {
+	if m.staleResumeStatePolicy != nil {
+		err := endpoint.CleanupStaleResumeStates(ctx, m.rootFS, *m.staleResumeStatePolicy)
+		if err != nil {
+			GetLogger(ctx).WithError(err).Error("stale resume state cleanup failed")
+		}
+	}
+
 	if m.interval.Manual {
 		GetLogger(ctx).Info("manual pull configured, periodic pull disabled")
 		// "waiting for wakeups" is printed in common ActiveSide.do
@@ -231,10 +306,34 @@ func (m *modePull) RunPeriodic(ctx context.Context, wakeUpCommon chan<- struct{}
 	}
 }
 
+func (m *modePull) SyncTopology(ctx context.Context) {
+	if m.topologySyncPolicy == nil {
+		return
+	}
+	m.setupMtx.Lock()
+	sender := m.sender
+	m.setupMtx.Unlock()
+
+	res, err := sender.ListFilesystems(ctx, &pdu.ListFilesystemReq{})
+	if err != nil {
+		GetLogger(ctx).WithError(err).Error("cannot list sender filesystems for topology sync")
+		return
+	}
+	if err := endpoint.SyncTopology(ctx, m.rootFS, m.receiverConfig.Rename, res.GetFilesystems(), *m.topologySyncPolicy); err != nil {
+		GetLogger(ctx).WithError(err).Error("topology sync failed")
+	}
+}
+
 func (m *modePull) SnapperReport() *snapper.Report {
 	return nil
 }
 
+func (m *modePull) SnapperDumpState() (string, bool) { return "", false }
+
+func (m *modePull) TriggerSnapshot() error { return ErrJobDoesNotSnapshot }
+
+func (m *modePull) RegisterMetrics(registerer prometheus.Registerer) {}
+
 func (m *modePull) ResetConnectBackoff() {
 	m.setupMtx.Lock()
 	defer m.setupMtx.Unlock()
@@ -254,9 +353,13 @@ func modePullFromConfig(g *config.Global, in *config.PullJob, jobID endpoint.Job
 	if m.rootFS.Length() <= 0 {
 		return nil, errors.New("RootFS must not be empty") // duplicates error check of receiver
 	}
+	if err := m.rootFS.ValidateSnapshotHeadroom(); err != nil {
+		return nil, errors.Wrap(err, "RootFS")
+	}
 
 	m.plannerPolicy = &logic.PlannerPolicy{
-		EncryptedSend: logic.DontCare,
+		EncryptedSend:  logic.DontCare,
+		CompressedSend: logic.DontCare,
 	}
 
 	m.receiverConfig = endpoint.ReceiverConfig{
@@ -265,10 +368,31 @@ func modePullFromConfig(g *config.Global, in *config.PullJob, jobID endpoint.Job
 		AppendClientIdentity:       false, // !
 		UpdateLastReceivedHold:     true,
 	}
+	if m.receiverConfig.EncryptionRoot, err = encryptionRootPolicyFromConfig(in.Recv); err != nil {
+		return nil, errors.Wrap(err, "cannot build encryption root policy")
+	}
+	if m.receiverConfig.Rename, err = receiverRenameFromConfig(in.Recv); err != nil {
+		return nil, errors.Wrap(err, "cannot build rename mapping")
+	}
+	if in.Recv.Properties != nil {
+		m.receiverConfig.SetProperties = in.Recv.Properties.Override
+		m.receiverConfig.ExcludeProperties = in.Recv.Properties.Exclude
+	}
 	if err := m.receiverConfig.Validate(); err != nil {
 		return nil, errors.Wrap(err, "cannot build receiver config")
 	}
 
+	if m.staleResumeStatePolicy, err = staleResumeStatePolicyFromConfig(in.Recv); err != nil {
+		return nil, errors.Wrap(err, "cannot build stale resume state policy")
+	}
+
+	if in.TopologySync != nil {
+		m.topologySyncPolicy = &endpoint.TopologySyncPolicy{
+			DestroyOrphaned: in.TopologySync.DestroyOrphaned,
+			DryRun:          in.TopologySync.DryRun,
+		}
+	}
+
 	return m, nil
 }
 
@@ -306,7 +430,21 @@ func activeSide(g *config.Global, in *config.ActiveJob, configJob interface{}) (
 		Help:        "number of bytes replicated from sender to receiver per filesystem",
 		ConstLabels: prometheus.Labels{"zrepl_job": j.name.String()},
 	}, []string{"filesystem"})
+	j.promReplicationLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace:   "zrepl",
+		Subsystem:   "replication",
+		Name:        "lag_seconds",
+		Help:        "seconds between the creation of the newest snapshot on the sender and that of the newest snapshot replicated to the receiver per filesystem (-1 if never replicated)",
+		ConstLabels: prometheus.Labels{"zrepl_job": j.name.String()},
+	}, []string{"filesystem"})
+
+	j.invocationMetrics = newInvocationMetrics(j.name.String())
 
+	j.maxRunDuration = in.MaxRunDuration
+
+	if in.Connect.Ret == nil {
+		return nil, errors.New("connect must be specified")
+	}
 	j.connecter, err = fromconfig.ConnecterFromConfig(g, in.Connect)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot build client")
@@ -331,6 +469,9 @@ func (j *ActiveSide) RegisterMetrics(registerer prometheus.Registerer) {
 	registerer.MustRegister(j.promRepStateSecs)
 	registerer.MustRegister(j.promPruneSecs)
 	registerer.MustRegister(j.promBytesReplicated)
+	registerer.MustRegister(j.promReplicationLag)
+	j.invocationMetrics.register(registerer)
+	j.mode.RegisterMetrics(registerer)
 }
 
 func (j *ActiveSide) Name() string { return j.name.String() }
@@ -339,6 +480,11 @@ type ActiveSideStatus struct {
 	Replication                    *report.Report
 	PruningSender, PruningReceiver *pruner.Report
 	Snapshotting                   *snapper.Report
+	// set if the last invocation was cancelled because it exceeded max_run_duration
+	TimedOutAfter time.Duration `json:",omitempty"`
+	// only set for push jobs with multiple targets, keyed by target name
+	// (Snapshotting is shared among the targets and only set on the outer status)
+	Targets map[string]*ActiveSideStatus `json:",omitempty"`
 }
 
 func (j *ActiveSide) Status() *Status {
@@ -348,6 +494,10 @@ func (j *ActiveSide) Status() *Status {
 	t := j.mode.Type()
 	if tasks.replicationReport != nil {
 		s.Replication = tasks.replicationReport()
+		if tasks.replicationRPCStats != nil {
+			stats := tasks.replicationRPCStats()
+			s.Replication.RPC = &stats
+		}
 	}
 	if tasks.prunerSender != nil {
 		s.PruningSender = tasks.prunerSender.Report()
@@ -355,26 +505,43 @@ func (j *ActiveSide) Status() *Status {
 	if tasks.prunerReceiver != nil {
 		s.PruningReceiver = tasks.prunerReceiver.Report()
 	}
+	if tasks.timedOut {
+		s.TimedOutAfter = j.maxRunDuration
+	}
 	s.Snapshotting = j.mode.SnapperReport()
 	return &Status{Type: t, JobSpecific: s}
 }
 
+var _ SnapperStateDumper = (*ActiveSide)(nil)
+
+func (j *ActiveSide) SnapperDumpState() (string, bool) { return j.mode.SnapperDumpState() }
+
+var _ SnapshotTriggerer = (*ActiveSide)(nil)
+
+func (j *ActiveSide) TriggerSnapshot() error { return j.mode.TriggerSnapshot() }
+
 func (j *ActiveSide) OwnedDatasetSubtreeRoot() (rfs *zfs.DatasetPath, ok bool) {
-	pull, ok := j.mode.(*modePull)
-	if !ok {
-		_ = j.mode.(*modePush) // make sure we didn't introduce a new job type
+	switch m := j.mode.(type) {
+	case *modePull:
+		return m.rootFS.Copy(), true
+	case *modePush, *modePushTarget:
 		return nil, false
+	default:
+		panic(fmt.Sprintf("implementation error: unknown mode type %T", m))
 	}
-	return pull.rootFS.Copy(), true
 }
 
 func (j *ActiveSide) SenderConfig() *endpoint.SenderConfig {
-	push, ok := j.mode.(*modePush)
-	if !ok {
-		_ = j.mode.(*modePull) // make sure we didn't introduce a new job type
+	switch m := j.mode.(type) {
+	case *modePush:
+		return m.senderConfig
+	case *modePushTarget:
+		return m.senderConfig
+	case *modePull:
 		return nil
+	default:
+		panic(fmt.Sprintf("implementation error: unknown mode type %T", m))
 	}
-	return push.senderConfig
 }
 
 // The active side of a replication uses one end (sender or receiver)
@@ -396,7 +563,9 @@ func (j *ActiveSide) Run(ctx context.Context) {
 
 	defer log.Info("job exiting")
 
-	periodicDone := make(chan struct{})
+	releaseStaleHoldsOnStartup(ctx, j.SenderConfig())
+
+	periodicDone := snapper.NewSnapshotsTakenChan()
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 	periodicCtx, endTask := trace.WithTask(ctx, "periodic")
@@ -418,11 +587,51 @@ outer:
 		}
 		invocationCount++
 		invocationCtx, endSpan := trace.WithSpan(ctx, fmt.Sprintf("invocation-%d", invocationCount))
+		cancelInvocation := func() {}
+		if j.maxRunDuration > 0 {
+			invocationCtx, cancelInvocation = context.WithTimeout(invocationCtx, j.maxRunDuration)
+		}
+		begin := time.Now()
 		j.do(invocationCtx)
+		timedOut := ctx.Err() == nil && invocationCtx.Err() == context.DeadlineExceeded
+		cancelInvocation()
+		if timedOut {
+			// the cancelled context has terminated the connections and zfs processes of the invocation
+			log.WithField("max_run_duration", j.maxRunDuration).Error("invocation timed out: cancelled it because it exceeded max_run_duration")
+			j.updateTasks(func(tasks *activeSideTasks) { tasks.timedOut = true })
+			j.invocationMetrics.timeouts.Inc()
+		}
+		if ctx.Err() == nil { // invocations interrupted by shutdown are neither successes nor failures
+			j.invocationMetrics.observe(begin, j.invocationError())
+		}
 		endSpan()
 	}
 }
 
+// invocationError returns nil iff the most recent invocation of j.do completed replication and pruning without errors.
+func (j *ActiveSide) invocationError() error {
+	tasks := j.updateTasks(nil)
+	if tasks.timedOut {
+		return fmt.Errorf("invocation timed out after %s", j.maxRunDuration)
+	}
+	if tasks.state != ActiveSideDone {
+		return fmt.Errorf("invocation ended in state %s", tasks.state)
+	}
+	if tasks.replicationReport == nil {
+		return errors.New("replication did not start")
+	}
+	if err := replicationError(tasks.replicationReport()); err != nil {
+		return errors.Wrap(err, "replication")
+	}
+	if err := prunerError(tasks.prunerSender); err != nil {
+		return errors.Wrap(err, "pruning sender")
+	}
+	if err := prunerError(tasks.prunerReceiver); err != nil {
+		return errors.Wrap(err, "pruning receiver")
+	}
+	return nil
+}
+
 func (j *ActiveSide) do(ctx context.Context) {
 
 	j.mode.ConnectEndpoints(ctx, j.connecter)
@@ -441,7 +650,9 @@ func (j *ActiveSide) do(ctx context.Context) {
 	}()
 
 	sender, receiver := j.mode.SenderReceiver()
+	rpcStats := rpcStatsOf(sender, receiver)
 
+	var replicationErr error // nil iff the replication attempt completed without errors
 	{
 		select {
 		case <-ctx.Done():
@@ -456,14 +667,49 @@ func (j *ActiveSide) do(ctx context.Context) {
 			*tasks = activeSideTasks{}
 			tasks.replicationCancel = func() { repCancel(); endSpan() }
 			tasks.replicationReport, repWait = replication.Do(
-				ctx, logic.NewPlanner(j.promRepStateSecs, j.promBytesReplicated, sender, receiver, j.mode.PlannerPolicy()),
+				ctx, logic.NewPlanner(j.promRepStateSecs, j.promBytesReplicated, j.promReplicationLag, sender, receiver, j.mode.PlannerPolicy()),
 			)
+			tasks.replicationRPCStats = rpcStats
 			tasks.state = ActiveSideReplicating
 		})
 		GetLogger(ctx).Info("start replication")
 		repWait(true) // wait blocking
-		repCancel()   // always cancel to free up context resources
+		if replicationErr = ctx.Err(); replicationErr == nil {
+			replicationErr = replicationError(j.updateTasks(nil).replicationReport())
+		}
+		repCancel() // always cancel to free up context resources
 		endSpan()
+		if rpcStats != nil {
+			// the endpoints are also used for pruning, report the statistics of the replication only
+			stats := rpcStats()
+			j.updateTasks(func(tasks *activeSideTasks) {
+				tasks.replicationRPCStats = func() report.RPCStats { return stats }
+			})
+			GetLogger(ctx).
+				WithField("requests", stats.Requests).
+				WithField("errors", stats.Errors).
+				WithField("request_time", stats.RequestTime).
+				WithField("stream_read_time", stats.StreamReadTime).
+				WithField("stream_bytes_sent", stats.StreamBytesSent).
+				WithField("stream_bytes_received", stats.StreamBytesReceived).
+				Info("replication rpc statistics")
+		}
+	}
+
+	if ts, ok := j.mode.(activeModeTopologySyncer); ok {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		if replicationErr != nil {
+			// a filesystem that failed to replicate or was not listed might appear orphaned
+			GetLogger(ctx).WithError(replicationErr).Warn("skipping topology sync because replication did not complete without errors")
+		} else {
+			ctx, endSpan := trace.WithSpan(ctx, "topology_sync")
+			ts.SyncTopology(ctx)
+			endSpan()
+		}
 	}
 
 	{
@@ -474,13 +720,21 @@ func (j *ActiveSide) do(ctx context.Context) {
 		}
 		ctx, endSpan := trace.WithSpan(ctx, "prune_sender")
 		ctx, senderCancel := context.WithCancel(ctx)
+		var history pruner.History = sender
+		unlock := func() {}
+		if shared := j.sharedSenderPruning; shared != nil {
+			shared.mtx.Lock()
+			history = fanOutCursorHistory{target: sender, fanOut: shared.fanOut}
+			unlock = shared.mtx.Unlock
+		}
 		tasks := j.updateTasks(func(tasks *activeSideTasks) {
-			tasks.prunerSender = j.prunerFactory.BuildSenderPruner(ctx, sender, sender)
+			tasks.prunerSender = j.prunerFactory.BuildSenderPruner(ctx, sender, history)
 			tasks.prunerSenderCancel = func() { senderCancel(); endSpan() }
 			tasks.state = ActiveSidePruneSender
 		})
 		GetLogger(ctx).Info("start pruning sender")
 		tasks.prunerSender.Prune()
+		unlock()
 		GetLogger(ctx).Info("finished pruning sender")
 		senderCancel()
 		endSpan()
@@ -0,0 +1,303 @@
+package job
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/daemon/job/reset"
+	"github.com/zrepl/zrepl/daemon/job/wakeup"
+	"github.com/zrepl/zrepl/daemon/logging"
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+	"github.com/zrepl/zrepl/daemon/pruner"
+	"github.com/zrepl/zrepl/daemon/snapper"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+	"github.com/zrepl/zrepl/zfs"
+	"github.com/zrepl/zrepl/zfs/zfscmd"
+)
+
+// PushFanOut is a push job that replicates to multiple targets.
+//
+// Snapshots are taken by a single snapper shared among the targets.
+// Each target is an ActiveSide in push mode with its own job ID (see FanOutTargetJobName)
+// and hence its own replication cursors and holds on the sender.
+// Targets run independently of each other, i.e., a failing target does not block the others.
+type PushFanOut struct {
+	name    endpoint.JobID
+	snapper *snapper.PeriodicOrManual
+	targets []*fanOutTarget
+}
+
+type fanOutTarget struct {
+	name           string
+	side           *ActiveSide
+	snapshotsTaken chan struct{} // fed by PushFanOut.Run, consumed by modePushTarget.RunPeriodic
+}
+
+// FanOutTargetJobName returns the job ID used for target of the push job jobName.
+func FanOutTargetJobName(jobName, target string) string {
+	return jobName + ":" + target
+}
+
+// The targets of a PushFanOut prune the same snapshots on the sender.
+// A snapshot is only considered replicated once it has been replicated to all targets,
+// and sender pruning is serialized among the targets.
+type fanOutSenderPruning struct {
+	mtx    sync.Mutex
+	fanOut *endpoint.SenderFanOut
+}
+
+// fanOutCursorHistory implements pruner.History by returning the oldest of the
+// most recent replication cursors of all targets (see endpoint.GetOldestReplicationCursorOfJobs).
+// If any target has no replication cursor yet, it reports that the cursor does not exist.
+type fanOutCursorHistory struct {
+	// the Target passed as Target to BuildSenderPruner
+	target pruner.Target
+	fanOut *endpoint.SenderFanOut
+}
+
+var _ pruner.History = fanOutCursorHistory{}
+
+func (h fanOutCursorHistory) ListFilesystems(ctx context.Context, req *pdu.ListFilesystemReq) (*pdu.ListFilesystemRes, error) {
+	return h.target.ListFilesystems(ctx, req)
+}
+
+func (h fanOutCursorHistory) ReplicationCursor(ctx context.Context, req *pdu.ReplicationCursorReq) (*pdu.ReplicationCursorRes, error) {
+	oldest, err := endpoint.GetOldestReplicationCursorOfJobs(ctx, req.GetFilesystem(), h.fanOut.TargetJobIDs, &h.fanOut.LegacyJobID)
+	if err != nil {
+		return nil, err
+	}
+	if oldest == nil {
+		return &pdu.ReplicationCursorRes{Result: &pdu.ReplicationCursorRes_Notexist{Notexist: true}}, nil
+	}
+	return &pdu.ReplicationCursorRes{Result: &pdu.ReplicationCursorRes_Guid{Guid: oldest.Guid}}, nil
+}
+
+// modePushTarget is the mode of a PushFanOut's target.
+// It does not take snapshots but replicates on the target's schedule.
+type modePushTarget struct {
+	*modePush
+	interval       *config.PositiveDurationOrManual // nil => after every snapshotting round
+	snapshotsTaken <-chan struct{}
+}
+
+func (m *modePushTarget) RunPeriodic(ctx context.Context, wakeUpCommon chan<- struct{}) {
+	if m.interval == nil {
+		for {
+			select {
+			case <-m.snapshotsTaken:
+				select {
+				case wakeUpCommon <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+
+	if m.interval.Manual {
+		GetLogger(ctx).Info("manual replication configured for target, periodic replication disabled")
+		// "waiting for wakeups" is printed in common ActiveSide.do
+		return
+	}
+	t := time.NewTicker(m.interval.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			select {
+			case wakeUpCommon <- struct{}{}:
+			default:
+				GetLogger(ctx).
+					WithField("interval", m.interval.Interval).
+					Warn("replication to target took longer than its interval")
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *modePushTarget) SnapperReport() *snapper.Report { return nil }
+
+func (m *modePushTarget) SnapperDumpState() (string, bool) { return "", false }
+
+func (m *modePushTarget) TriggerSnapshot() error { return ErrJobDoesNotSnapshot }
+
+func (m *modePushTarget) RegisterMetrics(registerer prometheus.Registerer) {}
+
+func pushFanOutFromConfig(g *config.Global, in *config.PushJob) (j *PushFanOut, err error) {
+	j = &PushFanOut{}
+	j.name, err = endpoint.MakeJobID(in.Name)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid job name")
+	}
+	if in.Connect.Ret != nil {
+		return nil, errors.New("connect and targets are mutually exclusive")
+	}
+
+	fsf, err := filters.FilesystemsFilterFromConfig(in.Filesystems, in.FilesystemProperty)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot build filesystem filter")
+	}
+	if j.snapper, err = snapper.FromConfig(g, fsf, in.Snapshotting, j.name.String()); err != nil {
+		return nil, errors.Wrap(err, "cannot build snapper")
+	}
+
+	fanOut := &endpoint.SenderFanOut{LegacyJobID: j.name}
+	senderPruning := &fanOutSenderPruning{fanOut: fanOut}
+	seen := make(map[string]bool, len(in.Targets))
+	for _, t := range in.Targets {
+		if t.Name == "" {
+			return nil, errors.New("target name must not be empty")
+		}
+		if seen[t.Name] {
+			return nil, errors.Errorf("duplicate target name %q", t.Name)
+		}
+		seen[t.Name] = true
+
+		tin := *in
+		tin.Name = FanOutTargetJobName(in.Name, t.Name)
+		tin.Connect = t.Connect
+		tin.Targets = nil
+		tin.Snapshotting = config.SnapshottingEnum{Ret: &config.SnapshottingManual{Type: "manual"}}
+		side, err := activeSide(g, &tin.ActiveJob, &tin)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot build target %q", t.Name)
+		}
+
+		target := &fanOutTarget{
+			name:           t.Name,
+			side:           side,
+			snapshotsTaken: make(chan struct{}, 1),
+		}
+		side.mode = &modePushTarget{
+			modePush:       side.mode.(*modePush),
+			interval:       t.Interval,
+			snapshotsTaken: target.snapshotsTaken,
+		}
+		side.sharedSenderPruning = senderPruning
+		fanOut.TargetJobIDs = append(fanOut.TargetJobIDs, side.name)
+		j.targets = append(j.targets, target)
+	}
+	for _, t := range j.targets {
+		senderConfig := t.side.mode.(*modePushTarget).senderConfig
+		senderConfig.FanOut = fanOut
+		if err := senderConfig.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "cannot build sender config of target %q", t.name)
+		}
+	}
+
+	return j, nil
+}
+
+func (j *PushFanOut) Name() string { return j.name.String() }
+
+func (j *PushFanOut) RegisterMetrics(registerer prometheus.Registerer) {
+	j.snapper.RegisterMetrics(registerer)
+	for _, t := range j.targets {
+		t.side.RegisterMetrics(registerer)
+	}
+}
+
+func (j *PushFanOut) Status() *Status {
+	s := &ActiveSideStatus{
+		Snapshotting: j.snapper.Report(),
+		Targets:      make(map[string]*ActiveSideStatus, len(j.targets)),
+	}
+	for _, t := range j.targets {
+		s.Targets[t.name] = t.side.Status().JobSpecific.(*ActiveSideStatus)
+	}
+	return &Status{Type: TypePush, JobSpecific: s}
+}
+
+var _ SnapperStateDumper = (*PushFanOut)(nil)
+
+func (j *PushFanOut) SnapperDumpState() (string, bool) { return j.snapper.DumpState(), true }
+
+var _ SnapshotTriggerer = (*PushFanOut)(nil)
+
+func (j *PushFanOut) TriggerSnapshot() error { return j.snapper.Trigger() }
+
+func (j *PushFanOut) OwnedDatasetSubtreeRoot() (rfs *zfs.DatasetPath, ok bool) { return nil, false }
+
+// SenderConfig returns the sender config of the first target.
+// Only the job ID differs between the targets' sender configs, and v1 replication cursors
+// (the only user of the job ID outside of the job) predate fan-out replication.
+func (j *PushFanOut) SenderConfig() *endpoint.SenderConfig {
+	return j.targets[0].side.SenderConfig()
+}
+
+func (j *PushFanOut) Run(ctx context.Context) {
+	ctx, endTask := trace.WithTaskAndSpan(ctx, "push-fan-out-job", j.Name())
+	defer endTask()
+
+	log := GetLogger(ctx)
+
+	defer log.Info("job exiting")
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	snapshotsTaken := snapper.NewSnapshotsTakenChan()
+	periodicCtx, endTask := trace.WithTask(ctx, "periodic")
+	defer endTask()
+	go j.snapper.Run(periodicCtx, snapshotsTaken)
+
+	wakeups := make([]wakeup.Func, len(j.targets))
+	resets := make([]reset.Func, len(j.targets))
+	var wg sync.WaitGroup
+	for i, t := range j.targets {
+		tctx := logging.WithInjectedField(ctx, "target", t.name)
+		tctx = zfscmd.WithJobID(tctx, t.side.Name())
+		tctx, wakeups[i] = wakeup.Context(tctx)
+		tctx, resets[i] = reset.Context(tctx)
+		wg.Add(1)
+		go func(ctx context.Context, t *fanOutTarget) {
+			defer wg.Done()
+			ctx, endTask := trace.WithTask(ctx, "target")
+			defer endTask()
+			t.side.Run(ctx)
+		}(tctx, t)
+	}
+
+outer:
+	for {
+		select {
+		case <-ctx.Done():
+			log.WithError(ctx.Err()).Info("context")
+			break outer
+
+		case <-wakeup.Wait(ctx):
+			for i, t := range j.targets {
+				if err := wakeups[i](); err != nil {
+					log.WithField("target", t.name).WithError(err).Info("cannot wake up target")
+				}
+			}
+		case <-reset.Wait(ctx):
+			for i, t := range j.targets {
+				if err := resets[i](); err != nil {
+					log.WithField("target", t.name).WithError(err).Info("cannot reset target")
+				}
+			}
+		case <-snapshotsTaken:
+			for _, t := range j.targets {
+				select {
+				case t.snapshotsTaken <- struct{}{}:
+				default: // replication already pending
+				}
+			}
+		}
+	}
+
+	cancel()
+	wg.Wait()
+}
@@ -8,12 +8,35 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/zfs"
 )
 
+// JobsFromConfig builds the jobs in c.
+//
+// If global.on_job_init_error is set to disable_job, a job that cannot be built
+// is replaced by a DisabledJob instead of failing the entire config.
+// Errors that involve multiple jobs, e.g. overlapping root filesystems, are always returned.
 func JobsFromConfig(c *config.Config) ([]Job, error) {
+	var disableFailed bool
+	switch c.Global.OnJobInitError {
+	case "fail":
+	case "disable_job":
+		disableFailed = true
+	default:
+		return nil, errors.Errorf("invalid global.on_job_init_error value %q", c.Global.OnJobInitError)
+	}
+
 	js := make([]Job, len(c.Jobs))
 	for i := range c.Jobs {
 		j, err := buildJob(c.Global, c.Jobs[i])
+		if err != nil && disableFailed {
+			// jobs without a valid name cannot be addressed, e.g. in status, hence not be disabled
+			if _, nameErr := endpoint.MakeJobID(c.Jobs[i].Name()); nameErr == nil {
+				j, err = newDisabledJob(c.Jobs[i], err), nil
+			}
+		}
 		if err != nil {
 			return nil, err
 		}
@@ -38,6 +61,30 @@ func JobsFromConfig(c *config.Config) ([]Job, error) {
 		}
 	}
 
+	// receiving-side root filesystems must not overlap with sent filesystems
+	if err := validateReceivingSidesDoNotOverlapSources(c.Jobs, js); err != nil {
+		return nil, err
+	}
+
+	// the job IDs of push targets must not collide with job names
+	{
+		names := make(map[string]bool, len(js))
+		for _, j := range js {
+			names[j.Name()] = true
+		}
+		for _, j := range js {
+			fo, ok := j.(*PushFanOut)
+			if !ok {
+				continue
+			}
+			for _, t := range fo.targets {
+				if names[t.side.Name()] {
+					return nil, errors.Errorf("job ID %q of target %q of job %q collides with job name", t.side.Name(), t.name, fo.Name())
+				}
+			}
+		}
+	}
+
 	return js, nil
 }
 
@@ -63,7 +110,11 @@ func buildJob(c *config.Global, in config.JobEnum) (j Job, err error) {
 			return cannotBuildJob(err, v.Name)
 		}
 	case *config.PushJob:
-		j, err = activeSide(c, &v.ActiveJob, v)
+		if len(v.Targets) > 0 {
+			j, err = pushFanOutFromConfig(c, v)
+		} else {
+			j, err = activeSide(c, &v.ActiveJob, v)
+		}
 		if err != nil {
 			return cannotBuildJob(err, v.Name)
 		}
@@ -107,3 +158,134 @@ func validateReceivingSidesDoNotOverlap(receivingRootFSs []string) error {
 	}
 	return nil
 }
+
+// validateReceivingSidesDoNotOverlapSources makes sure that no receiving job (pull, sink)
+// receives into a filesystem that is sent by a sending job (push, source) on the same host.
+// Otherwise, a misconfiguration could receive onto live source data.
+// Cascading setups that deliberately send received filesystems must set recv.allow_source_overlap.
+//
+// confs[i] must be the config of js[i].
+func validateReceivingSidesDoNotOverlapSources(confs []config.JobEnum, js []Job) error {
+	for i, rj := range js {
+		rfs, ok := rj.OwnedDatasetSubtreeRoot()
+		if !ok {
+			continue
+		}
+		if recv := recvOptionsFromConfig(confs[i]); recv != nil && recv.AllowSourceOverlap {
+			continue
+		}
+		for _, sj := range js {
+			senderConfig := sj.SenderConfig()
+			if senderConfig == nil {
+				continue
+			}
+			var overlap bool
+			var err error
+			// property filters can only narrow down the filesystems => conservatively check the names only
+			if fsf, ok := filters.NameFilter(senderConfig.FSF).(*filters.DatasetMapFilter); ok {
+				overlap, err = fsf.MayPassWithin(rfs)
+			} else {
+				overlap, err = senderConfig.FSF.Filter(rfs)
+			}
+			if err != nil {
+				return errors.Wrapf(err, "cannot check whether root_fs of job %q overlaps with filesystems of job %q", rj.Name(), sj.Name())
+			}
+			if overlap {
+				return errors.Errorf("root_fs %q of job %q overlaps with the filesystems sent by job %q (set recv.allow_source_overlap if this is intended)",
+					rfs.ToString(), rj.Name(), sj.Name())
+			}
+		}
+	}
+	return nil
+}
+
+func recvOptionsFromConfig(in config.JobEnum) *config.RecvOptions {
+	switch v := in.Ret.(type) {
+	case *config.PullJob:
+		return v.Recv
+	case *config.SinkJob:
+		return v.Recv
+	default:
+		return nil
+	}
+}
+
+// returns nil if no stale resume state cleanup is configured
+func staleResumeStatePolicyFromConfig(in *config.RecvOptions) (*endpoint.StaleResumeStatePolicy, error) {
+	if in.StaleResumeState == nil {
+		return nil, nil
+	}
+	p := &endpoint.StaleResumeStatePolicy{
+		OlderThan: in.StaleResumeState.OlderThan,
+	}
+	switch in.StaleResumeState.Action {
+	case "resume":
+		p.Action = endpoint.StaleResumeStateResume
+	case "abort":
+		p.Action = endpoint.StaleResumeStateAbort
+	default:
+		return nil, fmt.Errorf("invalid stale resume state action %q, must be \"resume\" or \"abort\"", in.StaleResumeState.Action)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func sendOptionsFromConfig(in *config.SendOptions) zfs.SendOptions {
+	return zfs.SendOptions{
+		Compressed:   in.Compressed,
+		LargeBlocks:  in.LargeBlocks,
+		EmbeddedData: in.EmbeddedData,
+	}
+}
+
+// returns nil if the pool IO throttle is disabled
+func poolIOThrottleFromConfig(in *config.SendOptions) *endpoint.PoolIOThrottleConfig {
+	t := in.PoolIOThrottle
+	if t == nil {
+		return nil
+	}
+	return &endpoint.PoolIOThrottleConfig{
+		MaxRate:       t.MaxRate,
+		MinRate:       t.MinRate,
+		IdleThreshold: t.IdleThreshold,
+		BusyThreshold: t.BusyThreshold,
+		Interval:      t.Interval,
+	}
+}
+
+// returns nil if no encryption root handling is configured
+func encryptionRootPolicyFromConfig(in *config.RecvOptions) (*endpoint.EncryptionRootPolicy, error) {
+	if in.EncryptionRoot == nil {
+		return nil, nil
+	}
+	p := &endpoint.EncryptionRootPolicy{
+		KeyLocation: in.EncryptionRoot.KeyLocation,
+		KeyFormat:   in.EncryptionRoot.KeyFormat,
+	}
+	switch in.EncryptionRoot.Action {
+	case "inherit":
+		p.Action = endpoint.EncryptionRootInherit
+	case "own":
+		p.Action = endpoint.EncryptionRootOwn
+	default:
+		return nil, fmt.Errorf("invalid encryption root action %q, must be \"inherit\" or \"own\"", in.EncryptionRoot.Action)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// returns nil if no filesystems are renamed
+func receiverRenameFromConfig(in *config.RecvOptions) (endpoint.ReceiverRename, error) {
+	if len(in.Rename) == 0 {
+		return nil, nil
+	}
+	m, err := filters.DatasetRenameMapFromConfig(in.Rename)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -1,13 +1,16 @@
 package job
 
 import (
+	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/endpoint"
 )
 
 func TestValidateReceivingSidesDoNotOverlap(t *testing.T) {
@@ -108,3 +111,288 @@ jobs:
 	}
 
 }
+
+func TestPushFanOutFromConfig(t *testing.T) {
+	tmpl := `
+jobs:
+- name: push
+  type: push
+%s
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep_sender:
+    - type: not_replicated
+    keep_receiver:
+    - type: last_n
+      count: 10
+%s
+`
+	const targets = `
+  targets:
+  - name: onsite
+    connect:
+      type: local
+      listener_name: foo
+      client_identity: bar
+  - name: offsite
+    interval: 1h
+    connect:
+      type: local
+      listener_name: foo
+      client_identity: baz
+`
+	const connect = `
+  connect:
+    type: local
+    listener_name: foo
+    client_identity: bar
+`
+	const collidingJob = `
+- name: "push:offsite"
+  type: snap
+  filesystems: {"<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep:
+    - type: last_n
+      count: 10
+`
+
+	build := func(t *testing.T, push, other string) ([]Job, error) {
+		conf, err := config.ParseConfigBytes([]byte(fmt.Sprintf(tmpl, push, other)))
+		require.NoError(t, err)
+		return JobsFromConfig(conf)
+	}
+
+	t.Run("targets", func(t *testing.T) {
+		jobs, err := build(t, targets, "")
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+		fo, ok := jobs[0].(*PushFanOut)
+		require.True(t, ok)
+		assert.Equal(t, "push", fo.Name())
+		require.Len(t, fo.targets, 2)
+		assert.Equal(t, "push:onsite", fo.targets[0].side.Name())
+		assert.Equal(t, "push:offsite", fo.targets[1].side.Name())
+		assert.Nil(t, fo.targets[0].side.mode.(*modePushTarget).interval)
+		assert.Equal(t, time.Hour, fo.targets[1].side.mode.(*modePushTarget).interval.Interval)
+		assert.Equal(t, fo.targets[0].side.sharedSenderPruning, fo.targets[1].side.sharedSenderPruning)
+		assert.Equal(t, "push:onsite", fo.SenderConfig().JobID.String())
+
+		st := fo.Status().JobSpecific.(*ActiveSideStatus)
+		assert.Len(t, st.Targets, 2)
+	})
+
+	t.Run("connect and targets", func(t *testing.T) {
+		_, err := build(t, connect+targets, "")
+		assert.Error(t, err)
+	})
+
+	t.Run("neither connect nor targets", func(t *testing.T) {
+		_, err := build(t, "", "")
+		assert.Error(t, err)
+	})
+
+	t.Run("target job id collides with job name", func(t *testing.T) {
+		_, err := build(t, targets, collidingJob)
+		assert.Error(t, err)
+	})
+}
+
+func TestValidateReceivingSidesDoNotOverlapSources(t *testing.T) {
+	tmpl := `
+jobs:
+- name: sink
+  type: sink
+  serve:
+    type: local
+    listener_name: sink
+  root_fs: %q
+%s
+- name: push
+  type: push
+  connect:
+    type: local
+    listener_name: sink
+    client_identity: laptop
+  filesystems: {
+    "pool/data<": true,
+    "pool/data/tmp<": false,
+  }
+  snapshotting:
+    type: manual
+  pruning:
+    keep_sender:
+    - type: not_replicated
+    keep_receiver:
+    - type: last_n
+      count: 10
+`
+	const allowOverlap = `
+  recv:
+    allow_source_overlap: true
+`
+	type Case struct {
+		rootFS string
+		recv   string
+		valid  bool
+	}
+	cases := []Case{
+		{"backups", "", true},
+		{"pool/backups", "", true},
+		{"pool/data/tmp/backups", "", true},
+		{"pool/data/backups", "", false},
+		{"pool/data", "", false},
+		{"pool", "", false},
+		{"pool", allowOverlap, true},
+	}
+
+	for i := range cases {
+		c := cases[i]
+		t.Run(fmt.Sprintf("%s_%v", c.rootFS, c.recv != ""), func(t *testing.T) {
+			conf, err := config.ParseConfigBytes([]byte(fmt.Sprintf(tmpl, c.rootFS, c.recv)))
+			require.NoError(t, err)
+			_, err = JobsFromConfig(conf)
+			if c.valid {
+				assert.NoError(t, err)
+			} else {
+				t.Logf("error: %s", err)
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestJobsFromConfigOnJobInitError(t *testing.T) {
+	tmpl := `
+global:
+  on_job_init_error: %s
+jobs:
+- name: healthy
+  type: snap
+  filesystems: {"pool/a<": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep:
+    - type: last_n
+      count: 10
+- name: broken
+  type: snap
+  filesystems: {"pool/b@invalid": true}
+  snapshotting:
+    type: manual
+  pruning:
+    keep:
+    - type: last_n
+      count: 10
+`
+	parse := func(t *testing.T, mode string) *config.Config {
+		conf, err := config.ParseConfigBytes([]byte(fmt.Sprintf(tmpl, mode)))
+		require.NoError(t, err)
+		return conf
+	}
+
+	t.Run("fail", func(t *testing.T) {
+		jobs, err := JobsFromConfig(parse(t, "fail"))
+		assert.Error(t, err)
+		assert.Nil(t, jobs)
+	})
+
+	t.Run("disable_job", func(t *testing.T) {
+		jobs, err := JobsFromConfig(parse(t, "disable_job"))
+		require.NoError(t, err)
+		require.Len(t, jobs, 2)
+		assert.IsType(t, &SnapJob{}, jobs[0])
+		require.IsType(t, &DisabledJob{}, jobs[1])
+		assert.Equal(t, "broken", jobs[1].Name())
+
+		status := jobs[1].Status()
+		assert.Equal(t, TypeDisabled, status.Type)
+		st := status.JobSpecific.(*DisabledJobStatus)
+		assert.Equal(t, TypeSnap, st.ConfiguredType)
+		assert.NotEmpty(t, st.Err)
+
+		// the status must survive the round trip to the client
+		j, err := json.Marshal(status)
+		require.NoError(t, err)
+		var decoded Status
+		require.NoError(t, json.Unmarshal(j, &decoded))
+		assert.Equal(t, st, decoded.JobSpecific)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := JobsFromConfig(parse(t, "ignore"))
+		assert.Error(t, err)
+	})
+}
+
+func TestStaleResumeStatePolicyFromConfig(t *testing.T) {
+	p, err := staleResumeStatePolicyFromConfig(&config.RecvOptions{})
+	require.NoError(t, err)
+	assert.Nil(t, p, "cleanup is disabled by default")
+
+	p, err = staleResumeStatePolicyFromConfig(&config.RecvOptions{
+		StaleResumeState: &config.RecvOptionsStaleResumeState{Action: "abort", OlderThan: 2 * time.Hour},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &endpoint.StaleResumeStatePolicy{Action: endpoint.StaleResumeStateAbort, OlderThan: 2 * time.Hour}, p)
+
+	p, err = staleResumeStatePolicyFromConfig(&config.RecvOptions{
+		StaleResumeState: &config.RecvOptionsStaleResumeState{Action: "resume"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, endpoint.StaleResumeStateResume, p.Action)
+
+	_, err = staleResumeStatePolicyFromConfig(&config.RecvOptions{
+		StaleResumeState: &config.RecvOptionsStaleResumeState{Action: "delete"},
+	})
+	assert.Error(t, err)
+	_, err = staleResumeStatePolicyFromConfig(&config.RecvOptions{
+		StaleResumeState: &config.RecvOptionsStaleResumeState{Action: "abort", OlderThan: -time.Hour},
+	})
+	assert.Error(t, err)
+}
+
+func TestTeeCompressionCodecFromConfig(t *testing.T) {
+	tmpl := `
+jobs:
+- name: push
+  type: push
+  connect:
+    type: local
+    listener_name: foo
+    client_identity: bar
+  filesystems: {"<": true}
+  send:
+    encrypted: false
+    tee:
+      directory: /tmp
+      compression:
+        codec: %s
+  snapshotting:
+    type: manual
+  pruning:
+    keep_sender:
+    - type: not_replicated
+    keep_receiver:
+    - type: last_n
+      count: 10
+`
+	build := func(t *testing.T, codec string) error {
+		conf, err := config.ParseConfigBytes([]byte(fmt.Sprintf(tmpl, codec)))
+		require.NoError(t, err)
+		_, err = JobsFromConfig(conf)
+		return err
+	}
+
+	assert.NoError(t, build(t, "gzip"))
+	for _, codec := range []string{"zstd", "lz4"} {
+		err := build(t, codec)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not supported")
+	}
+}
@@ -0,0 +1,65 @@
+package job
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/endpoint"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// DisabledJob takes the place of a job that could not be built from its config
+// if global.on_job_init_error is set to disable_job (see JobsFromConfig).
+// It does nothing but report the error.
+type DisabledJob struct {
+	name           string
+	configuredType Type
+	err            error
+}
+
+type DisabledJobStatus struct {
+	// the type of the job in the config, empty if unknown
+	ConfiguredType Type
+	Err            string
+}
+
+func newDisabledJob(in config.JobEnum, err error) *DisabledJob {
+	var t Type
+	switch in.Ret.(type) {
+	case *config.SnapJob:
+		t = TypeSnap
+	case *config.PushJob:
+		t = TypePush
+	case *config.SinkJob:
+		t = TypeSink
+	case *config.PullJob:
+		t = TypePull
+	case *config.SourceJob:
+		t = TypeSource
+	}
+	return &DisabledJob{name: in.Name(), configuredType: t, err: err}
+}
+
+func (j *DisabledJob) Name() string { return j.name }
+
+// Err returns the error that caused the job to be disabled.
+func (j *DisabledJob) Err() error { return j.err }
+
+func (j *DisabledJob) Status() *Status {
+	return &Status{Type: TypeDisabled, JobSpecific: &DisabledJobStatus{
+		ConfiguredType: j.configuredType,
+		Err:            j.err.Error(),
+	}}
+}
+
+func (j *DisabledJob) RegisterMetrics(registerer prometheus.Registerer) {}
+
+func (j *DisabledJob) OwnedDatasetSubtreeRoot() (rfs *zfs.DatasetPath, ok bool) { return nil, false }
+
+func (j *DisabledJob) SenderConfig() *endpoint.SenderConfig { return nil }
+
+func (j *DisabledJob) Run(ctx context.Context) {
+	GetLogger(ctx).WithError(j.err).Error("job is disabled because it could not be built from the config")
+}
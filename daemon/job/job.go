@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/zrepl/zrepl/daemon/logging"
+	"github.com/zrepl/zrepl/daemon/logging/trace"
 	"github.com/zrepl/zrepl/endpoint"
 	"github.com/zrepl/zrepl/logger"
 	"github.com/zrepl/zrepl/zfs"
@@ -30,6 +32,20 @@ type Job interface {
 	SenderConfig() *endpoint.SenderConfig
 }
 
+// SnapperStateDumper is implemented by jobs that can take snapshots.
+// ok is false if the job's mode does not snapshot, e.g., for a pull job.
+type SnapperStateDumper interface {
+	SnapperDumpState() (dump string, ok bool)
+}
+
+var ErrJobDoesNotSnapshot = errors.New("job does not take snapshots")
+
+// SnapshotTriggerer is implemented by jobs that can take snapshots, see snapper.Snapper.Trigger.
+// Jobs whose mode does not snapshot return ErrJobDoesNotSnapshot.
+type SnapshotTriggerer interface {
+	TriggerSnapshot() error
+}
+
 type Type string
 
 const (
@@ -39,6 +55,8 @@ const (
 	TypeSink     Type = "sink"
 	TypePull     Type = "pull"
 	TypeSource   Type = "source"
+	// a job that could not be built from its config, see DisabledJob
+	TypeDisabled Type = "disabled"
 )
 
 type Status struct {
@@ -99,6 +117,11 @@ func (s *Status) UnmarshalJSON(in []byte) (err error) {
 		err = json.Unmarshal(jobJSON, &st)
 		s.JobSpecific = &st
 
+	case TypeDisabled:
+		var st DisabledJobStatus
+		err = json.Unmarshal(jobJSON, &st)
+		s.JobSpecific = &st
+
 	case TypeInternal:
 		// internal jobs do not report specifics
 	default:
@@ -106,3 +129,24 @@ func (s *Status) UnmarshalJSON(in []byte) (err error) {
 	}
 	return err
 }
+
+// releaseStaleHoldsOnStartup releases the job's planned-version-holds and,
+// if configured in senderConfig (may be nil), its stale step holds.
+// It is safe to call while the job sends, see endpoint.ReleaseStaleStepHolds.
+func releaseStaleHoldsOnStartup(ctx context.Context, senderConfig *endpoint.SenderConfig) {
+	if senderConfig == nil {
+		return
+	}
+	ctx, endSpan := trace.WithSpan(ctx, "release-stale-holds")
+	defer endSpan()
+	if err := endpoint.ReleaseStalePlannedHolds(ctx, senderConfig.FSF, senderConfig.JobID); err != nil {
+		GetLogger(ctx).WithError(err).Error("cannot release stale planned-version-holds")
+	}
+	if !senderConfig.ReleaseStaleStepHoldsOnStartup {
+		return
+	}
+	GetLogger(ctx).Info("releasing stale step holds")
+	if err := endpoint.ReleaseStaleStepHolds(ctx, senderConfig.FSF, senderConfig.JobID); err != nil {
+		GetLogger(ctx).WithError(err).Error("cannot release stale step holds")
+	}
+}
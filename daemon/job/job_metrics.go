@@ -0,0 +1,135 @@
+package job
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zrepl/zrepl/daemon/pruner"
+	"github.com/zrepl/zrepl/replication/report"
+)
+
+// invocationMetrics track the outcome of the invocations of a job,
+// i.e., replication and pruning for active side jobs, pruning for snap jobs.
+type invocationMetrics struct {
+	created time.Time
+
+	mtx         sync.Mutex
+	lastSuccess time.Time // zero if no invocation has succeeded yet
+
+	lastSuccessSeconds prometheus.GaugeFunc
+	lastDuration       prometheus.Gauge
+	failures           prometheus.Counter
+	timeouts           prometheus.Counter
+}
+
+func newInvocationMetrics(jobName string) *invocationMetrics {
+	m := &invocationMetrics{created: time.Now()}
+	constLabels := prometheus.Labels{"zrepl_job": jobName}
+	m.lastSuccessSeconds = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace:   "zrepl",
+		Subsystem:   "job",
+		Name:        "last_success_seconds",
+		Help:        "seconds since the end of the last successful invocation of the job (since the job was created if there was none)",
+		ConstLabels: constLabels,
+	}, m.secondsSinceLastSuccess)
+	m.lastDuration = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   "zrepl",
+		Subsystem:   "job",
+		Name:        "last_run_duration_seconds",
+		Help:        "duration of the last invocation of the job, whether successful or not",
+		ConstLabels: constLabels,
+	})
+	m.failures = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   "zrepl",
+		Subsystem:   "job",
+		Name:        "failures",
+		Help:        "number of failed invocations of the job",
+		ConstLabels: constLabels,
+	})
+	m.timeouts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace:   "zrepl",
+		Subsystem:   "job",
+		Name:        "timeouts",
+		Help:        "number of invocations of the job that were cancelled because they exceeded max_run_duration (also counted as failures)",
+		ConstLabels: constLabels,
+	})
+	return m
+}
+
+func (m *invocationMetrics) register(registerer prometheus.Registerer) {
+	registerer.MustRegister(m.lastSuccessSeconds)
+	registerer.MustRegister(m.lastDuration)
+	registerer.MustRegister(m.failures)
+	registerer.MustRegister(m.timeouts)
+}
+
+func (m *invocationMetrics) secondsSinceLastSuccess() float64 {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	since := m.lastSuccess
+	if since.IsZero() {
+		since = m.created
+	}
+	return time.Since(since).Seconds()
+}
+
+// observe records an invocation that started at begin and just ended with err (nil on success).
+func (m *invocationMetrics) observe(begin time.Time, err error) {
+	end := time.Now()
+	m.lastDuration.Set(end.Sub(begin).Seconds())
+	if err != nil {
+		m.failures.Inc()
+		return
+	}
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.lastSuccess = end
+}
+
+// replicationError returns nil iff the last attempt of rep is done without errors.
+func replicationError(rep *report.Report) error {
+	if rep == nil || len(rep.Attempts) == 0 {
+		if rep != nil && rep.WaitReconnectError != nil {
+			return rep.WaitReconnectError
+		}
+		return errors.New("replication did not start")
+	}
+	last := rep.Attempts[len(rep.Attempts)-1]
+	if last.PlanError != nil {
+		return last.PlanError
+	}
+	for _, fs := range last.Filesystems {
+		if err := fs.Error(); err != nil {
+			return errors.Wrapf(err, "filesystem %s", fs.Info.Name)
+		}
+	}
+	if last.State != report.AttemptDone {
+		return fmt.Errorf("replication attempt ended in state %s", last.State)
+	}
+	return nil
+}
+
+// prunerError returns nil iff p completed without errors.
+// p may be nil if pruning did not start.
+func prunerError(p *pruner.Pruner) error {
+	if p == nil {
+		return errors.New("pruning did not start")
+	}
+	r := p.Report()
+	if r.Error != "" {
+		return errors.New(r.Error)
+	}
+	for _, fs := range r.Completed {
+		if fs.LastError != "" {
+			return fmt.Errorf("filesystem %s: %s", fs.Filesystem, fs.LastError)
+		}
+	}
+	if len(r.Pending) > 0 {
+		return fmt.Errorf("pruning ended in state %s with pending filesystems", r.State)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package job
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zrepl/zrepl/replication/report"
+)
+
+func TestInvocationMetrics(t *testing.T) {
+	m := newInvocationMetrics("j")
+	m.created = time.Now().Add(-time.Hour)
+	assert.InDelta(t, time.Hour.Seconds(), testutil.ToFloat64(m.lastSuccessSeconds), 60, "no success yet => since creation")
+
+	m.observe(time.Now().Add(-2*time.Minute), errors.New("failed"))
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.failures))
+	assert.InDelta(t, 120, testutil.ToFloat64(m.lastDuration), 1)
+	assert.InDelta(t, time.Hour.Seconds(), testutil.ToFloat64(m.lastSuccessSeconds), 60)
+
+	m.observe(time.Now().Add(-time.Minute), nil)
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.failures))
+	assert.InDelta(t, 60, testutil.ToFloat64(m.lastDuration), 1)
+	assert.InDelta(t, 0, testutil.ToFloat64(m.lastSuccessSeconds), 1)
+}
+
+func TestReplicationError(t *testing.T) {
+	now := time.Now()
+	assert.Error(t, replicationError(nil))
+	assert.Error(t, replicationError(&report.Report{WaitReconnectError: report.NewTimedError("connection refused", now)}))
+
+	done := &report.AttemptReport{
+		State: report.AttemptDone,
+		Filesystems: []*report.FilesystemReport{
+			{Info: &report.FilesystemInfo{Name: "pool/a"}, State: report.FilesystemDone},
+		},
+	}
+	assert.NoError(t, replicationError(&report.Report{Attempts: []*report.AttemptReport{done}}))
+
+	planErr := &report.AttemptReport{State: report.AttemptPlanningError, PlanError: report.NewTimedError("cannot list", now)}
+	assert.Error(t, replicationError(&report.Report{Attempts: []*report.AttemptReport{planErr}}))
+	assert.NoError(t, replicationError(&report.Report{Attempts: []*report.AttemptReport{planErr, done}}), "only the last attempt counts")
+
+	fsErr := &report.AttemptReport{
+		State: report.AttemptDone,
+		Filesystems: []*report.FilesystemReport{
+			{Info: &report.FilesystemInfo{Name: "pool/a"}, State: report.FilesystemDone},
+			{Info: &report.FilesystemInfo{Name: "pool/b"}, State: report.FilesystemSteppingErrored, StepError: report.NewTimedError("recv failed", now)},
+		},
+	}
+	err := replicationError(&report.Report{Attempts: []*report.AttemptReport{fsErr}})
+	assert.EqualError(t, err, "filesystem pool/b: recv failed")
+
+	assert.Error(t, prunerError(nil), "pruning did not start")
+}
+
+func TestInvocationErrorTimedOut(t *testing.T) {
+	j := &ActiveSide{maxRunDuration: time.Hour}
+	j.updateTasks(func(tasks *activeSideTasks) {
+		tasks.state = ActiveSideReplicating
+		tasks.timedOut = true
+	})
+	err := j.invocationError()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "timed out after 1h0m0s")
+	}
+}
@@ -29,11 +29,15 @@ type passiveMode interface {
 	Handler() rpc.Handler
 	RunPeriodic(ctx context.Context)
 	SnapperReport() *snapper.Report // may be nil
+	SnapperDumpState() (dump string, ok bool)
+	TriggerSnapshot() error
+	RegisterMetrics(registerer prometheus.Registerer)
 	Type() Type
 }
 
 type modeSink struct {
-	receiverConfig endpoint.ReceiverConfig
+	receiverConfig         endpoint.ReceiverConfig
+	staleResumeStatePolicy *endpoint.StaleResumeStatePolicy // may be nil
 }
 
 func (m *modeSink) Type() Type { return TypeSink }
@@ -42,9 +46,23 @@ func (m *modeSink) Handler() rpc.Handler {
 	return endpoint.NewReceiver(m.receiverConfig)
 }
 
-func (m *modeSink) RunPeriodic(_ context.Context)  {}
+func (m *modeSink) RunPeriodic(ctx context.Context) {
+	if m.staleResumeStatePolicy != nil {
+		err := endpoint.CleanupStaleResumeStates(ctx, m.receiverConfig.RootWithoutClientComponent, *m.staleResumeStatePolicy)
+		if err != nil {
+			GetLogger(ctx).WithError(err).Error("stale resume state cleanup failed")
+		}
+	}
+}
+
 func (m *modeSink) SnapperReport() *snapper.Report { return nil }
 
+func (m *modeSink) SnapperDumpState() (string, bool) { return "", false }
+
+func (m *modeSink) TriggerSnapshot() error { return ErrJobDoesNotSnapshot }
+
+func (m *modeSink) RegisterMetrics(registerer prometheus.Registerer) {}
+
 func modeSinkFromConfig(g *config.Global, in *config.SinkJob, jobID endpoint.JobID) (m *modeSink, err error) {
 	m = &modeSink{}
 
@@ -52,6 +70,9 @@ func modeSinkFromConfig(g *config.Global, in *config.SinkJob, jobID endpoint.Job
 	if err != nil {
 		return nil, errors.New("root dataset is not a valid zfs filesystem path")
 	}
+	if err := rootDataset.ValidateSnapshotHeadroom(); err != nil {
+		return nil, errors.Wrap(err, "root dataset")
+	}
 
 	m.receiverConfig = endpoint.ReceiverConfig{
 		JobID:                      jobID,
@@ -59,10 +80,24 @@ func modeSinkFromConfig(g *config.Global, in *config.SinkJob, jobID endpoint.Job
 		AppendClientIdentity:       true, // !
 		UpdateLastReceivedHold:     true,
 	}
+	if m.receiverConfig.EncryptionRoot, err = encryptionRootPolicyFromConfig(in.Recv); err != nil {
+		return nil, errors.Wrap(err, "cannot build encryption root policy")
+	}
+	if m.receiverConfig.Rename, err = receiverRenameFromConfig(in.Recv); err != nil {
+		return nil, errors.Wrap(err, "cannot build rename mapping")
+	}
+	if in.Recv.Properties != nil {
+		m.receiverConfig.SetProperties = in.Recv.Properties.Override
+		m.receiverConfig.ExcludeProperties = in.Recv.Properties.Exclude
+	}
 	if err := m.receiverConfig.Validate(); err != nil {
 		return nil, errors.Wrap(err, "cannot build receiver config")
 	}
 
+	if m.staleResumeStatePolicy, err = staleResumeStatePolicyFromConfig(in.Recv); err != nil {
+		return nil, errors.Wrap(err, "cannot build stale resume state policy")
+	}
+
 	return m, nil
 }
 
@@ -74,7 +109,7 @@ type modeSource struct {
 func modeSourceFromConfig(g *config.Global, in *config.SourceJob, jobID endpoint.JobID) (m *modeSource, err error) {
 	// FIXME exact dedup of modePush
 	m = &modeSource{}
-	fsf, err := filters.DatasetMapFilterFromConfig(in.Filesystems)
+	fsf, err := filters.FilesystemsFilterFromConfig(in.Filesystems, in.FilesystemProperty)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot build filesystem filter")
 	}
@@ -83,9 +118,26 @@ func modeSourceFromConfig(g *config.Global, in *config.SourceJob, jobID endpoint
 		Encrypt:                     &zfs.NilBool{B: in.Send.Encrypted},
 		DisableIncrementalStepHolds: in.Send.StepHolds.DisableIncremental,
 		JobID:                       jobID,
+		SendOptions:                 sendOptionsFromConfig(in.Send),
+
+		ReleaseStaleStepHoldsOnStartup: in.Send.StepHolds.ReleaseStaleOnStartup,
+		PoolIOThrottle:                 poolIOThrottleFromConfig(in.Send),
+		LastReplicatedProperty:         in.Send.LastReplicatedProperty,
+	}
+	if in.Send.Tee != nil {
+		m.senderConfig.TeeDirectory = in.Send.Tee.Directory
+		if c := in.Send.Tee.Compression; c != nil {
+			m.senderConfig.TeeCompression = endpoint.TeeCompression{Codec: c.Codec, Level: c.Level}
+		}
+	}
+	if naming, ok := in.Snapshotting.Naming(); ok {
+		m.senderConfig.SnapshotPrefix = naming.Prefix
+	}
+	if err := m.senderConfig.Validate(); err != nil {
+		return nil, errors.Wrap(err, "cannot build sender config")
 	}
 
-	if m.snapper, err = snapper.FromConfig(g, fsf, in.Snapshotting); err != nil {
+	if m.snapper, err = snapper.FromConfig(g, fsf, in.Snapshotting, jobID.String()); err != nil {
 		return nil, errors.Wrap(err, "cannot build snapper")
 	}
 
@@ -106,6 +158,18 @@ func (m *modeSource) SnapperReport() *snapper.Report {
 	return m.snapper.Report()
 }
 
+func (m *modeSource) SnapperDumpState() (string, bool) {
+	return m.snapper.DumpState(), true
+}
+
+func (m *modeSource) TriggerSnapshot() error {
+	return m.snapper.Trigger()
+}
+
+func (m *modeSource) RegisterMetrics(registerer prometheus.Registerer) {
+	m.snapper.RegisterMetrics(registerer)
+}
+
 func passiveSideFromConfig(g *config.Global, in *config.PassiveJob, configJob interface{}) (s *PassiveSide, err error) {
 
 	s = &PassiveSide{}
@@ -136,15 +200,25 @@ func (j *PassiveSide) Name() string { return j.name.String() }
 
 type PassiveStatus struct {
 	Snapper *snapper.Report
+	Sends   []endpoint.SendProgress // the sends to clients that are in progress, empty for sink jobs
 }
 
 func (s *PassiveSide) Status() *Status {
 	st := &PassiveStatus{
 		Snapper: s.mode.SnapperReport(),
+		Sends:   endpoint.ActiveSendsProgress(s.name),
 	}
 	return &Status{Type: s.mode.Type(), JobSpecific: st}
 }
 
+var _ SnapperStateDumper = (*PassiveSide)(nil)
+
+func (j *PassiveSide) SnapperDumpState() (string, bool) { return j.mode.SnapperDumpState() }
+
+var _ SnapshotTriggerer = (*PassiveSide)(nil)
+
+func (j *PassiveSide) TriggerSnapshot() error { return j.mode.TriggerSnapshot() }
+
 func (j *PassiveSide) OwnedDatasetSubtreeRoot() (rfs *zfs.DatasetPath, ok bool) {
 	sink, ok := j.mode.(*modeSink)
 	if !ok {
@@ -163,13 +237,18 @@ func (j *PassiveSide) SenderConfig() *endpoint.SenderConfig {
 	return source.senderConfig
 }
 
-func (*PassiveSide) RegisterMetrics(registerer prometheus.Registerer) {}
+func (j *PassiveSide) RegisterMetrics(registerer prometheus.Registerer) {
+	j.mode.RegisterMetrics(registerer)
+}
 
 func (j *PassiveSide) Run(ctx context.Context) {
 	ctx, endTask := trace.WithTaskAndSpan(ctx, "passive-side-job", j.Name())
 	defer endTask()
 	log := GetLogger(ctx)
 	defer log.Info("job exiting")
+
+	releaseStaleHoldsOnStartup(ctx, j.SenderConfig())
+
 	{
 		ctx, endTask := trace.WithTask(ctx, "periodic") // shadowing
 		defer endTask()
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
@@ -26,7 +27,8 @@ type SnapJob struct {
 
 	prunerFactory *pruner.LocalPrunerFactory
 
-	promPruneSecs *prometheus.HistogramVec // labels: prune_side
+	promPruneSecs     *prometheus.HistogramVec // labels: prune_side
+	invocationMetrics *invocationMetrics
 
 	pruner *pruner.Pruner
 }
@@ -37,19 +39,19 @@ func (j *SnapJob) Type() Type { return TypeSnap }
 
 func snapJobFromConfig(g *config.Global, in *config.SnapJob) (j *SnapJob, err error) {
 	j = &SnapJob{}
-	fsf, err := filters.DatasetMapFilterFromConfig(in.Filesystems)
+	fsf, err := filters.FilesystemsFilterFromConfig(in.Filesystems, in.FilesystemProperty)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot build filesystem filter")
 	}
 	j.fsfilter = fsf
 
-	if j.snapper, err = snapper.FromConfig(g, fsf, in.Snapshotting); err != nil {
-		return nil, errors.Wrap(err, "cannot build snapper")
-	}
 	j.name, err = endpoint.MakeJobID(in.Name)
 	if err != nil {
 		return nil, errors.Wrap(err, "invalid job name")
 	}
+	if j.snapper, err = snapper.FromConfig(g, fsf, in.Snapshotting, j.name.String()); err != nil {
+		return nil, errors.Wrap(err, "cannot build snapper")
+	}
 	j.promPruneSecs = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Namespace:   "zrepl",
 		Subsystem:   "pruning",
@@ -57,6 +59,7 @@ func snapJobFromConfig(g *config.Global, in *config.SnapJob) (j *SnapJob, err er
 		Help:        "seconds spent in pruner",
 		ConstLabels: prometheus.Labels{"zrepl_job": j.name.String()},
 	}, []string{"prune_side"})
+	j.invocationMetrics = newInvocationMetrics(j.name.String())
 	j.prunerFactory, err = pruner.NewLocalPrunerFactory(in.Pruning, j.promPruneSecs)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot build snapjob pruning rules")
@@ -66,6 +69,8 @@ func snapJobFromConfig(g *config.Global, in *config.SnapJob) (j *SnapJob, err er
 
 func (j *SnapJob) RegisterMetrics(registerer prometheus.Registerer) {
 	registerer.MustRegister(j.promPruneSecs)
+	j.invocationMetrics.register(registerer)
+	j.snapper.RegisterMetrics(registerer)
 }
 
 type SnapJobStatus struct {
@@ -83,6 +88,14 @@ func (j *SnapJob) Status() *Status {
 	return &Status{Type: t, JobSpecific: s}
 }
 
+var _ SnapperStateDumper = (*SnapJob)(nil)
+
+func (j *SnapJob) SnapperDumpState() (string, bool) { return j.snapper.DumpState(), true }
+
+var _ SnapshotTriggerer = (*SnapJob)(nil)
+
+func (j *SnapJob) TriggerSnapshot() error { return j.snapper.Trigger() }
+
 func (j *SnapJob) OwnedDatasetSubtreeRoot() (rfs *zfs.DatasetPath, ok bool) {
 	return nil, false
 }
@@ -96,7 +109,7 @@ func (j *SnapJob) Run(ctx context.Context) {
 
 	defer log.Info("job exiting")
 
-	periodicDone := make(chan struct{})
+	periodicDone := snapper.NewSnapshotsTakenChan()
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 	periodicCtx, endTask := trace.WithTask(ctx, "snapshotting")
@@ -118,7 +131,11 @@ outer:
 		invocationCount++
 
 		invocationCtx, endSpan := trace.WithSpan(ctx, fmt.Sprintf("invocation-%d", invocationCount))
+		begin := time.Now()
 		j.doPrune(invocationCtx)
+		if ctx.Err() == nil { // invocations interrupted by shutdown are neither successes nor failures
+			j.invocationMetrics.observe(begin, prunerError(j.pruner))
+		}
 		endSpan()
 	}
 }
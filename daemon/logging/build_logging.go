@@ -6,6 +6,7 @@ import (
 	"crypto/x509"
 	"log/syslog"
 	"os"
+	"time"
 
 	"github.com/mattn/go-isatty"
 	"github.com/pkg/errors"
@@ -33,12 +34,10 @@ func OutletsFromConfig(in config.LoggingOutletEnumList) (*logger.Outlets, error)
 		if err != nil {
 			return nil, errors.Wrapf(err, "cannot parse outlet #%d", lei)
 		}
-		var _ logger.Outlet = WriterOutlet{}
-		var _ logger.Outlet = &SyslogOutlet{}
-		switch outlet.(type) {
-		case *SyslogOutlet:
+		switch le.Ret.(type) {
+		case *config.SyslogLoggingOutlet:
 			syslogOutlets++
-		case WriterOutlet:
+		case *config.StdoutLoggingOutlet:
 			stdoutOutlets++
 		}
 
@@ -196,10 +195,15 @@ func parseLogFormat(i interface{}) (f EntryFormatter, err error) {
 
 func ParseOutlet(in config.LoggingOutletEnum) (o logger.Outlet, level logger.Level, err error) {
 
+	var dedupWindow time.Duration
 	parseCommon := func(common config.LoggingOutletCommon) (logger.Level, EntryFormatter, error) {
 		if common.Level == "" || common.Format == "" {
 			return 0, nil, errors.Errorf("must specify 'level' and 'format' field")
 		}
+		if common.DedupWindow < 0 {
+			return 0, nil, errors.Errorf("'dedup_window' must not be negative")
+		}
+		dedupWindow = common.DedupWindow
 
 		minLevel, err := logger.ParseLevel(common.Level)
 		if err != nil {
@@ -236,6 +240,9 @@ func ParseOutlet(in config.LoggingOutletEnum) (o logger.Outlet, level logger.Lev
 	default:
 		panic(v)
 	}
+	if err == nil && dedupWindow > 0 {
+		o = NewDedupOutlet(o, dedupWindow)
+	}
 	return o, level, err
 }
 
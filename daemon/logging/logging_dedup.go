@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zrepl/zrepl/logger"
+)
+
+// DedupOutlet suppresses repetitions of warnings and errors that occur within a time window,
+// e.g. if a filesystem fails in every replication or snapshotting cycle.
+//
+// Entries are repetitions of each other if they have the same level, message and fields,
+// ignoring the span field (which differs between cycles) and the error field.
+// The first occurrence of an entry is always written.
+// Subsequent occurrences with the same error are suppressed until the window expires,
+// at which point a single summary entry with the number of repetitions is written.
+// An occurrence with a different error (i.e., a state change) is written immediately.
+//
+// Entries with a level below logger.Warn are passed through.
+type DedupOutlet struct {
+	outlet logger.Outlet
+	window time.Duration
+
+	mtx     sync.Mutex // serializes writes to outlet
+	entries map[string]*dedupEntry
+}
+
+type dedupEntry struct {
+	err      string       // error field of the most recently written occurrence
+	repeated int          // number of suppressed occurrences
+	last     logger.Entry // most recently suppressed occurrence, valid iff repeated > 0
+}
+
+var _ logger.Outlet = (*DedupOutlet)(nil)
+
+func NewDedupOutlet(outlet logger.Outlet, window time.Duration) *DedupOutlet {
+	return &DedupOutlet{
+		outlet:  outlet,
+		window:  window,
+		entries: make(map[string]*dedupEntry),
+	}
+}
+
+func (o *DedupOutlet) WriteEntry(entry logger.Entry) error {
+	if entry.Level < logger.Warn {
+		return o.outlet.WriteEntry(entry)
+	}
+
+	key := dedupKey(entry)
+	var errField string
+	if e, ok := entry.Fields[logger.FieldError]; ok {
+		errField = fmt.Sprint(e)
+	}
+
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	d, ok := o.entries[key]
+	if !ok {
+		d = &dedupEntry{err: errField}
+		o.entries[key] = d
+		time.AfterFunc(o.window, func() { o.expire(key, d) })
+		return o.outlet.WriteEntry(entry)
+	}
+	if d.err != errField {
+		o.writeSummary(d)
+		d.err = errField
+		return o.outlet.WriteEntry(entry)
+	}
+	d.repeated++
+	d.last = entry
+	return nil
+}
+
+func (o *DedupOutlet) expire(key string, d *dedupEntry) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	if o.entries[key] != d {
+		return
+	}
+	delete(o.entries, key)
+	// there is nobody to return the error to, the next entry will likely fail as well
+	_ = o.writeSummary(d)
+}
+
+// o.mtx must be held
+func (o *DedupOutlet) writeSummary(d *dedupEntry) error {
+	if d.repeated == 0 {
+		return nil
+	}
+	summary := d.last
+	summary.Message = fmt.Sprintf("%s (repeated %d times within %s)", summary.Message, d.repeated, o.window)
+	d.repeated = 0
+	d.last = logger.Entry{}
+	return o.outlet.WriteEntry(summary)
+}
+
+func dedupKey(entry logger.Entry) string {
+	fields := make([]string, 0, len(entry.Fields))
+	for field, val := range entry.Fields {
+		if field == SpanField || field == logger.FieldError {
+			continue
+		}
+		fields = append(fields, fmt.Sprintf("%q=%v", field, val))
+	}
+	sort.Strings(fields)
+	return fmt.Sprintf("%d %q %s", entry.Level, entry.Message, strings.Join(fields, " "))
+}
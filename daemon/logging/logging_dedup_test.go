@@ -0,0 +1,73 @@
+package logging
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/logger"
+)
+
+type captureOutlet struct {
+	mtx     sync.Mutex
+	entries []logger.Entry
+}
+
+func (o *captureOutlet) WriteEntry(e logger.Entry) error {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	o.entries = append(o.entries, e)
+	return nil
+}
+
+func (o *captureOutlet) messages() []string {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	msgs := make([]string, len(o.entries))
+	for i, e := range o.entries {
+		msgs[i] = e.Message
+	}
+	return msgs
+}
+
+func TestDedupOutlet(t *testing.T) {
+	capture := &captureOutlet{}
+	o := NewDedupOutlet(capture, 100*time.Millisecond)
+
+	entry := func(level logger.Level, msg, span, err string) logger.Entry {
+		return logger.Entry{
+			Level:   level,
+			Message: msg,
+			Time:    time.Now(),
+			Fields:  logger.Fields{JobField: "j", SpanField: span, logger.FieldError: err},
+		}
+	}
+
+	// first occurrence is written, repetitions (in different spans) are not
+	require.NoError(t, o.WriteEntry(entry(logger.Error, "failed", "s1", "a")))
+	require.NoError(t, o.WriteEntry(entry(logger.Error, "failed", "s2", "a")))
+	require.NoError(t, o.WriteEntry(entry(logger.Error, "failed", "s3", "a")))
+	// other messages and levels below warn are not affected
+	require.NoError(t, o.WriteEntry(entry(logger.Warn, "failed", "s3", "a")))
+	require.NoError(t, o.WriteEntry(entry(logger.Info, "progress", "s3", "")))
+	require.NoError(t, o.WriteEntry(entry(logger.Info, "progress", "s3", "")))
+	assert.Equal(t, []string{"failed", "failed", "progress", "progress"}, capture.messages())
+
+	// a different error is a state change => summary + new error
+	require.NoError(t, o.WriteEntry(entry(logger.Error, "failed", "s4", "b")))
+	require.NoError(t, o.WriteEntry(entry(logger.Error, "failed", "s5", "b")))
+	assert.Equal(t, []string{
+		"failed", "failed", "progress", "progress",
+		"failed (repeated 2 times within 100ms)", "failed",
+	}, capture.messages())
+
+	// once the window expires, the summary is written and the next occurrence is written immediately
+	time.Sleep(200 * time.Millisecond)
+	require.NoError(t, o.WriteEntry(entry(logger.Error, "failed", "s6", "b")))
+	msgs := capture.messages()
+	require.Len(t, msgs, 8)
+	assert.Equal(t, []string{"failed (repeated 1 times within 100ms)", "failed"}, msgs[6:])
+}
@@ -5,12 +5,14 @@ import (
 	"math/rand"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/zrepl/zrepl/util/envconst"
 )
 
 var genIdPRNG = rand.New(rand.NewSource(1))
+var genIdPRNGMtx sync.Mutex // a rand.Rand is not safe for concurrent use
 
 func init() {
 	genIdPRNG.Seed(time.Now().UnixNano())
@@ -29,6 +31,7 @@ func genID() string {
 	var out strings.Builder
 	enc := base64.NewEncoder(base64.RawStdEncoding, &out)
 	buf := make([]byte, genIdNumBytes)
+	genIdPRNGMtx.Lock()
 	for i := 0; i < len(buf); {
 		n, err := genIdPRNG.Read(buf[i:])
 		if err != nil {
@@ -36,6 +39,7 @@ func genID() string {
 		}
 		i += n
 	}
+	genIdPRNGMtx.Unlock()
 	n, err := enc.Write(buf[:])
 	if err != nil || n != len(buf) {
 		panic(err)
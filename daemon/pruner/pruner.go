@@ -13,6 +13,7 @@ import (
 
 	"github.com/zrepl/zrepl/config"
 	"github.com/zrepl/zrepl/daemon/logging"
+	"github.com/zrepl/zrepl/daemon/logging/trace"
 	"github.com/zrepl/zrepl/logger"
 	"github.com/zrepl/zrepl/pruning"
 	"github.com/zrepl/zrepl/replication/logic/pdu"
@@ -50,9 +51,11 @@ type args struct {
 	target                         Target
 	receiver                       History
 	rules                          []pruning.KeepRule
+	gracePeriod                    time.Duration
 	retryWait                      time.Duration
 	considerSnapAtCursorReplicated bool
 	promPruneSecs                  prometheus.Observer
+	destroy                        destroyLimits
 }
 
 type Pruner struct {
@@ -72,15 +75,39 @@ type Pruner struct {
 type PrunerFactory struct {
 	senderRules                    []pruning.KeepRule
 	receiverRules                  []pruning.KeepRule
+	gracePeriod                    time.Duration
 	retryWait                      time.Duration
 	considerSnapAtCursorReplicated bool
 	promPruneSecs                  *prometheus.HistogramVec
+	destroy                        destroyLimits
 }
 
 type LocalPrunerFactory struct {
 	keepRules     []pruning.KeepRule
+	gracePeriod   time.Duration
 	retryWait     time.Duration
 	promPruneSecs *prometheus.HistogramVec
+	destroy       destroyLimits
+}
+
+// destroyLimits limits the load of the Exec state, see config.PruningDestroy
+type destroyLimits struct {
+	concurrency int
+	batchSize   int // 0 means unlimited
+	pause       time.Duration
+}
+
+func destroyLimitsFromConfig(in *config.PruningDestroy) (destroyLimits, error) {
+	if in == nil {
+		return destroyLimits{concurrency: 1}, nil
+	}
+	if in.Concurrency < 1 {
+		return destroyLimits{}, fmt.Errorf("destroy.concurrency must be at least 1")
+	}
+	if in.BatchSize < 0 {
+		return destroyLimits{}, fmt.Errorf("destroy.batch_size must not be negative")
+	}
+	return destroyLimits{concurrency: in.Concurrency, batchSize: in.BatchSize, pause: in.Pause}, nil
 }
 
 func NewLocalPrunerFactory(in config.PruningLocal, promPruneSecs *prometheus.HistogramVec) (*LocalPrunerFactory, error) {
@@ -95,10 +122,16 @@ func NewLocalPrunerFactory(in config.PruningLocal, promPruneSecs *prometheus.His
 			return nil, fmt.Errorf("single-site pruner cannot support `not_replicated` keep rule")
 		}
 	}
+	destroy, err := destroyLimitsFromConfig(in.Destroy)
+	if err != nil {
+		return nil, err
+	}
 	f := &LocalPrunerFactory{
 		keepRules:     rules,
+		gracePeriod:   in.GracePeriod,
 		retryWait:     envconst.Duration("ZREPL_PRUNER_RETRY_INTERVAL", 10*time.Second),
 		promPruneSecs: promPruneSecs,
+		destroy:       destroy,
 	}
 	return f, nil
 }
@@ -122,12 +155,18 @@ func NewPrunerFactory(in config.PruningSenderReceiver, promPruneSecs *prometheus
 		}
 		considerSnapAtCursorReplicated = considerSnapAtCursorReplicated || !knr.KeepSnapshotAtCursor
 	}
+	destroy, err := destroyLimitsFromConfig(in.Destroy)
+	if err != nil {
+		return nil, err
+	}
 	f := &PrunerFactory{
 		senderRules:                    keepRulesSender,
 		receiverRules:                  keepRulesReceiver,
+		gracePeriod:                    in.GracePeriod,
 		retryWait:                      envconst.Duration("ZREPL_PRUNER_RETRY_INTERVAL", 10*time.Second),
 		considerSnapAtCursorReplicated: considerSnapAtCursorReplicated,
 		promPruneSecs:                  promPruneSecs,
+		destroy:                        destroy,
 	}
 	return f, nil
 }
@@ -139,9 +178,11 @@ func (f *PrunerFactory) BuildSenderPruner(ctx context.Context, target Target, re
 			target,
 			receiver,
 			f.senderRules,
+			f.gracePeriod,
 			f.retryWait,
 			f.considerSnapAtCursorReplicated,
 			f.promPruneSecs.WithLabelValues("sender"),
+			f.destroy,
 		},
 		state: Plan,
 	}
@@ -155,9 +196,11 @@ func (f *PrunerFactory) BuildReceiverPruner(ctx context.Context, target Target,
 			target,
 			receiver,
 			f.receiverRules,
+			f.gracePeriod,
 			f.retryWait,
 			false, // senseless here anyways
 			f.promPruneSecs.WithLabelValues("receiver"),
+			f.destroy,
 		},
 		state: Plan,
 	}
@@ -171,9 +214,11 @@ func (f *LocalPrunerFactory) BuildLocalPruner(ctx context.Context, target Target
 			target,
 			receiver,
 			f.keepRules,
+			f.gracePeriod,
 			f.retryWait,
 			false, // considerSnapAtCursorReplicated is not relevant for local pruning
 			f.promPruneSecs.WithLabelValues("local"),
+			f.destroy,
 		},
 		state: Plan,
 	}
@@ -222,6 +267,8 @@ type FSReport struct {
 	SnapshotList, DestroyList []SnapshotReport
 	SkipReason                FSSkipReason
 	LastError                 string
+	// number of snapshots of DestroyList that have been destroyed so far
+	Destroyed int
 }
 
 type SnapshotReport struct {
@@ -275,6 +322,7 @@ type fs struct {
 
 	// only during Exec state, also used by execQueue
 	execErrLast error
+	destroyed   int
 }
 
 type FSSkipReason string
@@ -296,6 +344,7 @@ func (f *fs) Report() FSReport {
 	r := FSReport{}
 	r.Filesystem = f.path
 	r.SkipReason = f.skipReason
+	r.Destroyed = f.destroyed
 	if !r.SkipReason.NotSkipped() {
 		return r
 	}
@@ -461,7 +510,7 @@ tfss_loop:
 		}
 
 		// Apply prune rules
-		pfs.destroyList = pruning.PruneSnapshots(pfs.snaps, a.rules)
+		pfs.destroyList = pruning.PruneSnapshotsWithGracePeriod(pfs.snaps, a.rules, a.gracePeriod, time.Now())
 	}
 
 	u(func(pruner *Pruner) {
@@ -472,16 +521,28 @@ tfss_loop:
 		pruner.state = Exec
 	})
 
-	for {
-		var pfs *fs
-		u(func(pruner *Pruner) {
-			pfs = pruner.execQueue.Pop()
-		})
-		if pfs == nil {
-			break
-		}
-		doOneAttemptExec(a, u, pfs)
+	// each worker is a task of its own because the target's methods create spans
+	var workers sync.WaitGroup
+	for i := 0; i < a.destroy.concurrency; i++ {
+		ctx, endTask := trace.WithTask(ctx, "prune-exec")
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			defer endTask()
+			pacer := &destroyPacer{pause: a.destroy.pause}
+			for {
+				var pfs *fs
+				u(func(pruner *Pruner) {
+					pfs = pruner.execQueue.Pop()
+				})
+				if pfs == nil {
+					break
+				}
+				doOneAttemptExec(ctx, a, u, pfs, pacer)
+			}
+		}()
 	}
+	workers.Wait()
 
 	var rep *Report
 	{
@@ -509,46 +570,124 @@ tfss_loop:
 
 }
 
+// destroyPacer enforces destroyLimits.pause between the destroy requests of a single worker.
+type destroyPacer struct {
+	pause time.Duration
+	last  time.Time // end of the previous request, zero if there was none
+}
+
+func (p *destroyPacer) wait(ctx context.Context) error {
+	if p.pause <= 0 || p.last.IsZero() {
+		return nil
+	}
+	d := time.Until(p.last.Add(p.pause))
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *destroyPacer) done() { p.last = time.Now() }
+
+// destroyBatches splits l into batches of at most batchSize, or a single batch if batchSize is 0.
+// There are no batches if l is empty.
+func destroyBatches(l []*pdu.FilesystemVersion, batchSize int) [][]*pdu.FilesystemVersion {
+	if len(l) == 0 {
+		return nil
+	}
+	if batchSize <= 0 || len(l) <= batchSize {
+		return [][]*pdu.FilesystemVersion{l}
+	}
+	batches := make([][]*pdu.FilesystemVersion, 0, (len(l)+batchSize-1)/batchSize)
+	for len(l) > 0 {
+		n := batchSize
+		if n > len(l) {
+			n = len(l)
+		}
+		batches = append(batches, l[:n])
+		l = l[n:]
+	}
+	return batches
+}
+
 // attempts to exec pfs, puts it back into the queue with the result
-func doOneAttemptExec(a *args, u updater, pfs *fs) {
+//
+// The destroy list is split into batches of at most a.destroy.batchSize snapshots, one request each.
+// A failed request aborts the remaining batches, failed destroys within a batch do not.
+func doOneAttemptExec(ctx context.Context, a *args, u updater, pfs *fs, pacer *destroyPacer) {
 
 	destroyList := make([]*pdu.FilesystemVersion, len(pfs.destroyList))
 	for i := range destroyList {
 		destroyList[i] = pfs.destroyList[i].(snapshot).fsv
-		GetLogger(a.ctx).
+		GetLogger(ctx).
 			WithField("fs", pfs.path).
 			WithField("destroy_snap", destroyList[i].Name).
 			Debug("policy destroys snapshot")
 	}
-	req := pdu.DestroySnapshotsReq{
-		Filesystem: pfs.path,
-		Snapshots:  destroyList,
+
+	destroyFails := make([]*pdu.DestroySnapshotRes, 0)
+	var err error
+	for _, batch := range destroyBatches(destroyList, a.destroy.batchSize) {
+		if err = pacer.wait(ctx); err != nil {
+			break
+		}
+		req := pdu.DestroySnapshotsReq{
+			Filesystem: pfs.path,
+			Snapshots:  batch,
+		}
+		GetLogger(ctx).WithField("fs", pfs.path).WithField("count", len(batch)).Debug("destroying snapshots")
+		var res *pdu.DestroySnapshotsRes
+		res, err = a.target.DestroySnapshots(ctx, &req)
+		pacer.done()
+		if err != nil {
+			break
+		}
+		// check if all snapshots were destroyed
+		destroyResults := make(map[string]*pdu.DestroySnapshotRes)
+		for _, fsres := range res.Results {
+			destroyResults[fsres.Snapshot.Name] = fsres
+		}
+		destroyed := 0
+		for _, reqDestroy := range batch {
+			res, ok := destroyResults[reqDestroy.Name]
+			if !ok {
+				err = fmt.Errorf("missing destroy-result for %s", reqDestroy.RelName())
+				break
+			} else if res.Error != "" {
+				destroyFails = append(destroyFails, res)
+			} else {
+				destroyed++
+			}
+		}
+		var progress int
+		pfs.mtx.Lock()
+		pfs.destroyed += destroyed
+		progress = pfs.destroyed
+		pfs.mtx.Unlock()
+		GetLogger(ctx).
+			WithField("fs", pfs.path).
+			WithField("destroyed", progress).
+			WithField("total", len(destroyList)).
+			Info(fmt.Sprintf("destroyed %d/%d snapshots", progress, len(destroyList)))
+		if err != nil {
+			break
+		}
 	}
-	GetLogger(a.ctx).WithField("fs", pfs.path).Debug("destroying snapshots")
-	res, err := a.target.DestroySnapshots(a.ctx, &req)
 	if err != nil {
 		u(func(pruner *Pruner) {
 			pruner.execQueue.Put(pfs, err, false)
 		})
+		GetLogger(ctx).WithField("fs", pfs.path).WithError(err).Error("cannot destroy snapshots")
 		return
 	}
-	// check if all snapshots were destroyed
-	destroyResults := make(map[string]*pdu.DestroySnapshotRes)
-	for _, fsres := range res.Results {
-		destroyResults[fsres.Snapshot.Name] = fsres
-	}
-	err = nil
-	destroyFails := make([]*pdu.DestroySnapshotRes, 0)
-	for _, reqDestroy := range destroyList {
-		res, ok := destroyResults[reqDestroy.Name]
-		if !ok {
-			err = fmt.Errorf("missing destroy-result for %s", reqDestroy.RelName())
-			break
-		} else if res.Error != "" {
-			destroyFails = append(destroyFails, res)
-		}
-	}
-	if err == nil && len(destroyFails) > 0 {
+	if len(destroyFails) > 0 {
 		names := make([]string, len(destroyFails))
 		pairs := make([]string, len(destroyFails))
 		allSame := true
@@ -570,7 +709,7 @@ func doOneAttemptExec(a *args, u updater, pfs *fs) {
 		pruner.execQueue.Put(pfs, err, err == nil)
 	})
 	if err != nil {
-		GetLogger(a.ctx).WithError(err).Error("target could not destroy snapshots")
+		GetLogger(ctx).WithError(err).Error("target could not destroy snapshots")
 		return
 	}
 }
@@ -9,6 +9,7 @@ import (
 type execQueue struct {
 	mtx                sync.Mutex
 	pending, completed []*fs
+	active             []*fs // popped but not yet put back
 }
 
 func newExecQueue(cap int) *execQueue {
@@ -23,9 +24,13 @@ func (q *execQueue) Report() (pending, completed []FSReport) {
 	q.mtx.Lock()
 	defer q.mtx.Unlock()
 
-	pending = make([]FSReport, len(q.pending))
-	for i, fs := range q.pending {
-		pending[i] = fs.Report()
+	// active filesystems are reported as pending, with their progress
+	pending = make([]FSReport, 0, len(q.active)+len(q.pending))
+	for _, fs := range q.active {
+		pending = append(pending, fs.Report())
+	}
+	for _, fs := range q.pending {
+		pending = append(pending, fs.Report())
 	}
 	completed = make([]FSReport, len(q.completed))
 	for i, fs := range q.completed {
@@ -47,15 +52,27 @@ func (q *execQueue) HasCompletedFSWithErrors() bool {
 }
 
 func (q *execQueue) Pop() *fs {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
 	if len(q.pending) == 0 {
 		return nil
 	}
 	fs := q.pending[0]
 	q.pending = q.pending[1:]
+	q.active = append(q.active, fs)
 	return fs
 }
 
 func (q *execQueue) Put(fs *fs, err error, done bool) {
+	q.mtx.Lock()
+	for i, a := range q.active {
+		if a == fs {
+			q.active = append(q.active[:i], q.active[i+1:]...)
+			break
+		}
+	}
+	q.mtx.Unlock()
+
 	fs.mtx.Lock()
 	fs.execErrLast = err
 	if done || err != nil {
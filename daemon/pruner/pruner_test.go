@@ -0,0 +1,84 @@
+package pruner
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+)
+
+type destroyRecordingTarget struct {
+	Target // only DestroySnapshots is used
+	mtx    sync.Mutex
+	reqs   [][]string
+	fail   map[string]string // snapshot name => error
+}
+
+func (t *destroyRecordingTarget) DestroySnapshots(ctx context.Context, req *pdu.DestroySnapshotsReq) (*pdu.DestroySnapshotsRes, error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	var names []string
+	res := &pdu.DestroySnapshotsRes{}
+	for _, s := range req.Snapshots {
+		names = append(names, s.Name)
+		res.Results = append(res.Results, &pdu.DestroySnapshotRes{Snapshot: s, Error: t.fail[s.Name]})
+	}
+	t.reqs = append(t.reqs, names)
+	return res, nil
+}
+
+func TestDestroyBatches(t *testing.T) {
+	l := make([]*pdu.FilesystemVersion, 5)
+	for i := range l {
+		l[i] = &pdu.FilesystemVersion{Name: fmt.Sprintf("s%d", i)}
+	}
+	assert.Nil(t, destroyBatches(nil, 2))
+	assert.Len(t, destroyBatches(l, 0), 1)
+	assert.Len(t, destroyBatches(l, 5), 1)
+	batches := destroyBatches(l, 2)
+	require.Len(t, batches, 3)
+	assert.Equal(t, l[4:], batches[2])
+}
+
+func TestDoOneAttemptExecBatches(t *testing.T) {
+	pfs := &fs{path: "pool/fs"}
+	for i := 0; i < 5; i++ {
+		fsv := &pdu.FilesystemVersion{Name: fmt.Sprintf("s%d", i), Creation: time.Now().Format(time.RFC3339)}
+		pfs.destroyList = append(pfs.destroyList, snapshot{fsv: fsv})
+	}
+
+	target := &destroyRecordingTarget{fail: map[string]string{"s3": "dataset is busy"}}
+	p := &Pruner{execQueue: newExecQueue(1)}
+	p.execQueue.Put(pfs, nil, false)
+	u := func(f func(*Pruner)) {
+		p.mtx.Lock()
+		defer p.mtx.Unlock()
+		f(p)
+	}
+	a := &args{
+		target:  target,
+		destroy: destroyLimits{concurrency: 1, batchSize: 2, pause: 10 * time.Millisecond},
+	}
+	ctx := context.WithValue(context.Background(), contextKeyPruneSide, "test")
+
+	require.Equal(t, pfs, p.execQueue.Pop())
+	pending, _ := p.execQueue.Report()
+	require.Len(t, pending, 1, "filesystems that are being pruned are reported as pending")
+
+	begin := time.Now()
+	doOneAttemptExec(ctx, a, u, pfs, &destroyPacer{pause: a.destroy.pause})
+	assert.True(t, time.Since(begin) >= 2*a.destroy.pause, "pause between requests")
+
+	assert.Equal(t, [][]string{{"s0", "s1"}, {"s2", "s3"}, {"s4"}}, target.reqs)
+	pending, completed := p.execQueue.Report()
+	assert.Empty(t, pending)
+	require.Len(t, completed, 1)
+	assert.Equal(t, 4, completed[0].Destroyed)
+	assert.Contains(t, completed[0].LastError, "dataset is busy")
+}
@@ -0,0 +1,202 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/job"
+	"github.com/zrepl/zrepl/daemon/logging"
+)
+
+// reloader applies a modified config to the running daemon.
+//
+// Jobs that were added to the config are started, jobs that were removed are stopped,
+// and jobs whose config changed are restarted with the new config.
+// Unaffected jobs keep running.
+//
+// Changes that cannot be applied live are logged and deferred to the next daemon restart:
+// the global section, the type of a job, and its connect or serve section.
+// Jobs with such changes keep running with their current config.
+//
+// If the new config cannot be parsed or its jobs cannot be built, nothing is changed.
+type reloader struct {
+	mtx     sync.Mutex
+	jobs    *jobs
+	reparse func() (*config.Config, error)
+
+	// the config of the running jobs
+	global   *config.Global
+	jobConfs map[string]config.JobEnum // by job name
+}
+
+func newReloader(jobs *jobs, conf *config.Config, reparse func() (*config.Config, error)) *reloader {
+	r := &reloader{
+		jobs:     jobs,
+		reparse:  reparse,
+		global:   conf.Global,
+		jobConfs: make(map[string]config.JobEnum, len(conf.Jobs)),
+	}
+	for _, jc := range conf.Jobs {
+		r.jobConfs[jc.Name()] = jc
+	}
+	return r
+}
+
+type reloadPlan struct {
+	add, remove, restart []string
+	deferred             map[string]string // job name => reason
+	globalChanged        bool
+}
+
+func (p *reloadPlan) String() string {
+	var deferred []string
+	for name, reason := range p.deferred {
+		deferred = append(deferred, fmt.Sprintf("%s (%s)", name, reason))
+	}
+	sort.Strings(deferred)
+	return fmt.Sprintf("add=[%s] remove=[%s] restart=[%s] deferred=[%s] global_changed=%v",
+		strings.Join(p.add, ","), strings.Join(p.remove, ","), strings.Join(p.restart, ","),
+		strings.Join(deferred, ", "), p.globalChanged)
+}
+
+func jobTransportConfig(jc config.JobEnum) interface{} {
+	switch v := jc.Ret.(type) {
+	case *config.PushJob:
+		if len(v.Targets) > 0 {
+			connects := make(map[string]interface{}, len(v.Targets))
+			for _, t := range v.Targets {
+				connects[t.Name] = t.Connect.Ret
+			}
+			return connects
+		}
+		return v.Connect.Ret
+	case *config.PullJob:
+		return v.Connect.Ret
+	case *config.SinkJob:
+		return v.Serve.Ret
+	case *config.SourceJob:
+		return v.Serve.Ret
+	default:
+		return nil
+	}
+}
+
+func makeReloadPlan(oldGlobal *config.Global, oldJobs map[string]config.JobEnum, newConf *config.Config) *reloadPlan {
+	p := &reloadPlan{
+		deferred:      make(map[string]string),
+		globalChanged: !reflect.DeepEqual(oldGlobal, newConf.Global),
+	}
+	seen := make(map[string]bool, len(newConf.Jobs))
+	for _, nc := range newConf.Jobs {
+		name := nc.Name()
+		seen[name] = true
+		oc, ok := oldJobs[name]
+		switch {
+		case !ok:
+			p.add = append(p.add, name)
+		case reflect.DeepEqual(oc.Ret, nc.Ret):
+			// unchanged
+		case reflect.TypeOf(oc.Ret) != reflect.TypeOf(nc.Ret):
+			p.deferred[name] = "job type changed"
+		case !reflect.DeepEqual(jobTransportConfig(oc), jobTransportConfig(nc)):
+			p.deferred[name] = "transport config changed"
+		default:
+			p.restart = append(p.restart, name)
+		}
+	}
+	for name := range oldJobs {
+		if !seen[name] {
+			p.remove = append(p.remove, name)
+		}
+	}
+	sort.Strings(p.add)
+	sort.Strings(p.remove)
+	sort.Strings(p.restart)
+	return p
+}
+
+func (r *reloader) reload(ctx context.Context) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	log := logging.GetLogger(ctx, logging.SubsysMeta)
+	log.Info("reloading config")
+
+	newConf, err := r.reparse()
+	if err != nil {
+		log.WithError(err).Error("cannot parse config, continuing with the current config")
+		return
+	}
+	for _, jc := range newConf.Jobs {
+		if IsInternalJobName(jc.Name()) {
+			log.WithField("job", jc.Name()).Error("internal job name used in config, continuing with the current config")
+			return
+		}
+	}
+
+	// build all jobs before changing anything so that config errors leave the daemon untouched
+	// (global changes are deferred => build the jobs with the current global config)
+	built, err := job.JobsFromConfig(&config.Config{Global: r.global, Jobs: newConf.Jobs})
+	if err != nil {
+		log.WithError(err).Error("cannot build jobs from config, continuing with the current config")
+		return
+	}
+	builtByName := make(map[string]job.Job, len(built))
+	for _, j := range built {
+		builtByName[j.Name()] = j
+	}
+
+	plan := makeReloadPlan(r.global, r.jobConfs, newConf)
+	log.WithField("plan", plan.String()).Info("applying config changes")
+	if plan.globalChanged {
+		log.Warn("changes to the global config section are only applied after a daemon restart")
+	}
+	for name, reason := range plan.deferred {
+		log.WithField("job", name).WithField("reason", reason).Warn("job config change is only applied after a daemon restart")
+	}
+
+	newJobConfs := make(map[string]config.JobEnum, len(newConf.Jobs))
+	for _, jc := range newConf.Jobs {
+		newJobConfs[jc.Name()] = jc
+	}
+
+	failed := 0
+	for _, name := range plan.remove {
+		if err := r.jobs.stop(name); err != nil {
+			log.WithField("job", name).WithError(err).Error("cannot stop removed job")
+			failed++
+			continue
+		}
+		delete(r.jobConfs, name)
+	}
+	for _, name := range plan.restart {
+		if err := r.jobs.stop(name); err != nil {
+			log.WithField("job", name).WithError(err).Error("cannot stop job for restart")
+			failed++
+			continue
+		}
+		r.jobs.start(ctx, builtByName[name], false)
+		r.jobConfs[name] = newJobConfs[name]
+	}
+	for _, name := range plan.add {
+		r.jobs.start(ctx, builtByName[name], false)
+		r.jobConfs[name] = newJobConfs[name]
+	}
+
+	l := log.
+		WithField("added", len(plan.add)).
+		WithField("removed", len(plan.remove)).
+		WithField("restarted", len(plan.restart)).
+		WithField("deferred", len(plan.deferred)).
+		WithField("failed", failed)
+	if failed > 0 {
+		l.Error("config reload finished with errors")
+	} else {
+		l.Info("config reload finished")
+	}
+}
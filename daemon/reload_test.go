@@ -0,0 +1,84 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+)
+
+func TestMakeReloadPlan(t *testing.T) {
+	parse := func(s string) *config.Config {
+		c, err := config.ParseConfigBytes([]byte(s))
+		require.NoError(t, err)
+		return c
+	}
+	old := parse(`
+jobs:
+- name: unchanged
+  type: snap
+  filesystems: {"pool/a<": true}
+  snapshotting: {type: periodic, prefix: zrepl_, interval: 10m}
+  pruning: {keep: [{type: last_n, count: 10}]}
+- name: schedule
+  type: snap
+  filesystems: {"pool/b<": true}
+  snapshotting: {type: periodic, prefix: zrepl_, interval: 10m}
+  pruning: {keep: [{type: last_n, count: 10}]}
+- name: transport
+  type: sink
+  root_fs: pool/sink
+  serve: {type: tcp, listen: ":8888", clients: {"10.0.0.1": "foo"}}
+- name: removed
+  type: sink
+  root_fs: pool/sink2
+  serve: {type: tcp, listen: ":8889", clients: {"10.0.0.1": "foo"}}
+`)
+	oldJobs := make(map[string]config.JobEnum)
+	for _, jc := range old.Jobs {
+		oldJobs[jc.Name()] = jc
+	}
+
+	newConf := parse(`
+global:
+  logging:
+  - type: stdout
+    level: debug
+    format: human
+jobs:
+- name: unchanged
+  type: snap
+  filesystems: {"pool/a<": true}
+  snapshotting: {type: periodic, prefix: zrepl_, interval: 10m}
+  pruning: {keep: [{type: last_n, count: 10}]}
+- name: schedule
+  type: snap
+  filesystems: {"pool/b<": true}
+  snapshotting: {type: periodic, prefix: zrepl_, interval: 20m}
+  pruning: {keep: [{type: last_n, count: 10}]}
+- name: transport
+  type: sink
+  root_fs: pool/sink
+  serve: {type: tcp, listen: ":9999", clients: {"10.0.0.1": "foo"}}
+- name: added
+  type: sink
+  root_fs: pool/sink3
+  serve: {type: tcp, listen: ":8890", clients: {"10.0.0.1": "foo"}}
+`)
+
+	plan := makeReloadPlan(old.Global, oldJobs, newConf)
+	assert.Equal(t, []string{"added"}, plan.add)
+	assert.Equal(t, []string{"removed"}, plan.remove)
+	assert.Equal(t, []string{"schedule"}, plan.restart)
+	assert.Equal(t, map[string]string{"transport": "transport config changed"}, plan.deferred)
+	assert.True(t, plan.globalChanged)
+
+	plan = makeReloadPlan(old.Global, oldJobs, old)
+	assert.Empty(t, plan.add)
+	assert.Empty(t, plan.remove)
+	assert.Empty(t, plan.restart)
+	assert.Empty(t, plan.deferred)
+	assert.False(t, plan.globalChanged)
+}
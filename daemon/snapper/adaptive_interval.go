@@ -0,0 +1,59 @@
+package snapper
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// adaptiveInterval grows the snapshot interval of a filesystem
+// after each snapshot for which no bytes had been written since the previous snapshot,
+// and resets it to the base interval as soon as changes are detected.
+type adaptiveInterval struct {
+	growthFactor float64
+	maxInterval  time.Duration
+}
+
+// returns nil if in is nil
+func adaptiveIntervalFromConfig(interval time.Duration, in *config.SnapshottingAdaptiveInterval) (*adaptiveInterval, error) {
+	if in == nil {
+		return nil, nil
+	}
+	if in.GrowthFactor <= 1 {
+		return nil, fmt.Errorf("growth_factor must be greater than 1, got %v", in.GrowthFactor)
+	}
+	if in.MaxInterval < interval {
+		return nil, fmt.Errorf("max_interval (%s) must not be less than interval (%s)", in.MaxInterval, interval)
+	}
+	return &adaptiveInterval{
+		growthFactor: in.GrowthFactor,
+		maxInterval:  in.MaxInterval,
+	}, nil
+}
+
+// next returns the interval that follows cur, given that written bytes had been written
+// since the previous snapshot.
+func (a *adaptiveInterval) next(base, cur time.Duration, written int64) time.Duration {
+	if written != 0 {
+		return base
+	}
+	n := time.Duration(float64(cur) * a.growthFactor)
+	if n > a.maxInterval || n < cur { // n < cur: overflow
+		n = a.maxInterval
+	}
+	return n
+}
+
+// updateAdaptiveInterval must be called with s.mtx held after a snapshot of fs was taken,
+// before s.scheduleNext(fs).
+// A non-nil writtenErr is treated like a change to fs.
+func (s *Snapper) updateAdaptiveInterval(fs *zfs.DatasetPath, written int64, writtenErr error) (interval time.Duration) {
+	st := s.scheduleOf(fs)
+	if writtenErr != nil {
+		written = -1
+	}
+	st.interval = s.args.adaptive.next(s.args.intervalFor(fs), st.interval, written)
+	return st.interval
+}
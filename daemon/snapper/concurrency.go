@@ -0,0 +1,56 @@
+package snapper
+
+import (
+	"github.com/pkg/errors"
+)
+
+// concurrencyFromConfig treats 0 like 1, i.e., filesystems are snapshotted one after another.
+func concurrencyFromConfig(concurrency int) (int, error) {
+	if concurrency < 0 {
+		return 0, errors.Errorf("concurrency must not be negative, got %d", concurrency)
+	}
+	if concurrency == 0 {
+		return 1, nil
+	}
+	return concurrency, nil
+}
+
+// snapshotLanes partitions order into lanes that are processed concurrently, each lane sequentially in order.
+// With a concurrency of 1 (or 0, for args that are not from config), there is a single lane.
+// If serializePerPool is set, the filesystems of a pool share a lane to avoid contention on the pool's txg sync.
+// The number of lanes can exceed concurrency, the number of concurrent snapshots is limited by the caller.
+func (a args) snapshotLanes(order []jitteredFS) [][]jitteredFS {
+	if len(order) == 0 {
+		return nil
+	}
+	if a.concurrency <= 1 {
+		return [][]jitteredFS{order}
+	}
+	if !a.serializePerPool {
+		lanes := make([][]jitteredFS, len(order))
+		for i := range order {
+			lanes[i] = order[i : i+1]
+		}
+		return lanes
+	}
+	var lanes [][]jitteredFS
+	laneOfPool := make(map[string]int)
+	for _, jfs := range order {
+		i, ok := laneOfPool[jfs.fs.Pool()]
+		if !ok {
+			i = len(lanes)
+			laneOfPool[jfs.fs.Pool()] = i
+			lanes = append(lanes, nil)
+		}
+		lanes[i] = append(lanes[i], jfs)
+	}
+	return lanes
+}
+
+func (a args) snapshotSemaphore() chan struct{} {
+	n := a.concurrency
+	if n < 1 {
+		n = 1
+	}
+	return make(chan struct{}, n)
+}
@@ -0,0 +1,248 @@
+package snapper
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// cronSpec is a cron expression with the five standard fields
+// minute, hour, day of month, month and day of week, evaluated in location.
+//
+// Each field is `*` or a comma-separated list of values and ranges (`1-5`),
+// optionally with a step (`*/15`, `1-30/5`). Day of week is 0-7, both 0 and 7 are Sunday.
+// As in Vixie cron, if both day of month and day of week are restricted,
+// a day matches if either of them matches.
+// The macros @yearly, @annually, @monthly, @weekly, @daily, @midnight and @hourly are supported as well.
+type cronSpec struct {
+	expr     string
+	location *time.Location
+
+	minute, hour, dom, month, dow uint64 // bit i is set if value i matches
+	domStar, dowStar              bool
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronSearchLimit bounds the search for the next fire time.
+// Leap days are the rarest matches of any satisfiable spec, they occur at least every 8 years.
+const cronSearchLimit = 9
+
+func parseCronSpec(expr string, location *time.Location) (*cronSpec, error) {
+	fieldsExpr := expr
+	if m, ok := cronMacros[strings.TrimSpace(expr)]; ok {
+		fieldsExpr = m
+	}
+	fields := strings.Fields(fieldsExpr)
+	if len(fields) != 5 {
+		return nil, errors.Errorf("cron expression %q must have 5 fields (minute hour day-of-month month day-of-week)", expr)
+	}
+	c := &cronSpec{expr: expr, location: location}
+	var err error
+	parse := func(i int, name string, min, max uint) uint64 {
+		if err != nil {
+			return 0
+		}
+		var bits uint64
+		bits, err = parseCronField(fields[i], min, max)
+		if err != nil {
+			err = errors.Wrapf(err, "cron expression %q: invalid %s field", expr, name)
+		}
+		return bits
+	}
+	c.minute = parse(0, "minute", 0, 59)
+	c.hour = parse(1, "hour", 0, 23)
+	c.dom = parse(2, "day-of-month", 1, 31)
+	c.month = parse(3, "month", 1, 12)
+	c.dow = parse(4, "day-of-week", 0, 7)
+	if err != nil {
+		return nil, err
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1 << 0
+	}
+	c.domStar = fields[2] == "*"
+	c.dowStar = fields[4] == "*"
+
+	if c.next(time.Date(2000, time.January, 1, 0, 0, 0, 0, location)).IsZero() {
+		return nil, errors.Errorf("cron expression %q never matches", expr)
+	}
+	return c, nil
+}
+
+func parseCronField(field string, min, max uint) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, uint64(1)
+		if i := strings.Index(part, "/"); i != -1 {
+			s, err := strconv.ParseUint(part[i+1:], 10, 8)
+			if err != nil || s == 0 {
+				return 0, errors.Errorf("invalid step in %q", part)
+			}
+			rng, step = part[:i], s
+		}
+		var lo, hi uint64
+		switch {
+		case rng == "*":
+			lo, hi = uint64(min), uint64(max)
+		case strings.Contains(rng, "-"):
+			i := strings.Index(rng, "-")
+			var err error
+			if lo, err = strconv.ParseUint(rng[:i], 10, 8); err != nil {
+				return 0, errors.Errorf("invalid range %q", rng)
+			}
+			if hi, err = strconv.ParseUint(rng[i+1:], 10, 8); err != nil {
+				return 0, errors.Errorf("invalid range %q", rng)
+			}
+		default:
+			v, err := strconv.ParseUint(rng, 10, 8)
+			if err != nil {
+				return 0, errors.Errorf("invalid value %q", rng)
+			}
+			lo, hi = v, v
+			if step != 1 {
+				// `5/10` means `5-max/10`
+				hi = uint64(max)
+			}
+		}
+		if lo < uint64(min) || hi > uint64(max) || lo > hi {
+			return 0, errors.Errorf("%q is out of range %d-%d", rng, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func (c *cronSpec) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the earliest fire time of c that is strictly after t,
+// or the zero time if there is none within cronSearchLimit years.
+//
+// Fire times that do not exist because of a daylight saving time transition are skipped,
+// fire times that occur twice fire twice.
+func (c *cronSpec) next(t time.Time) time.Time {
+	t = t.In(c.location)
+	limit := t.AddDate(cronSearchLimit, 0, 0)
+	// the next full minute (time zone offsets are whole minutes)
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.location)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.location)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			// advance in absolute time so that repeated hours are not skipped or revisited
+			t = t.Add(time.Hour - time.Duration(t.Minute())*time.Minute)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (c *cronSpec) String() string { return c.expr }
+
+// CronFromConfig returns a snapper that snapshots at the fire times of the cron expression of in
+// instead of every interval.
+func CronFromConfig(g *config.Global, fsf zfs.DatasetFilter, in *config.SnapshottingCron, hookMetrics *hooks.Metrics) (*Snapper, error) {
+	if in.Prefix == "" {
+		return nil, errors.New("prefix must not be empty")
+	}
+
+	cronLocation, err := time.LoadLocation(in.CronLocation)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid cron_location")
+	}
+	cron, err := parseCronSpec(in.Cron, cronLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	hookList, err := hooks.ListFromConfig(&in.Hooks)
+	if err != nil {
+		return nil, errors.Wrap(err, "hook config error")
+	}
+
+	datasets, err := datasetsFromConfig(fsf, in.Datasets)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid dataset list")
+	}
+
+	if in.SnapshotProperty != "" && !strings.Contains(in.SnapshotProperty, ":") {
+		return nil, errors.Errorf("snapshot_property %q is not a ZFS user property (must contain a colon)", in.SnapshotProperty)
+	}
+
+	timestampFormat, err := TimestampFormatFromConfig(in.Naming())
+	if err != nil {
+		return nil, err
+	}
+
+	if in.MaxCycleDuration < 0 {
+		return nil, errors.New("max_cycle_duration must not be negative")
+	}
+
+	mounted, err := mountedFilterFromConfig(in.Mounted)
+	if err != nil {
+		return nil, err
+	}
+	concurrency, err := concurrencyFromConfig(in.Concurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	args := args{
+		prefix:   in.Prefix,
+		cron:     cron,
+		fsf:      fsf,
+		datasets: datasets,
+		hooks:    hookList,
+		verify:   in.Verify,
+		clock:    realClock{},
+
+		timestampFormat:  timestampFormat,
+		maxCycleDuration: in.MaxCycleDuration,
+		skipUnchanged:    in.SkipUnchanged,
+		initialSnapshot:  in.InitialSnapshot,
+		mounted:          mounted,
+		concurrency:      concurrency,
+		serializePerPool: in.SerializePerPool,
+
+		hookMetrics:             hookMetrics,
+		snapshotProperty:        in.SnapshotProperty,
+		snapshotPropertyInherit: in.SnapshotPropertyInherit,
+		// ctx and log is set in Run()
+	}
+
+	return newSnapper(args), nil
+}
@@ -0,0 +1,81 @@
+package snapper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronSpecNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	at := func(loc *time.Location, s string) time.Time {
+		t, err := time.ParseInLocation("2006-01-02 15:04:05", s, loc)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+
+	tcs := []struct {
+		expr   string
+		loc    *time.Location
+		after  string
+		expect string
+	}{
+		{"0 2 * * *", time.UTC, "2020-03-01 01:59:59", "2020-03-01 02:00:00"},
+		{"0 2 * * *", time.UTC, "2020-03-01 02:00:00", "2020-03-02 02:00:00"},
+		{"*/15 * * * *", time.UTC, "2020-03-01 10:07:30", "2020-03-01 10:15:00"},
+		{"30 8-18/2 * * 1-5", time.UTC, "2020-03-06 18:30:00", "2020-03-09 08:30:00"}, // Friday evening => Monday morning
+		{"0 0 29 2 *", time.UTC, "2021-01-01 00:00:00", "2024-02-29 00:00:00"},
+		{"0 0 1 * 0", time.UTC, "2020-03-02 00:00:00", "2020-03-08 00:00:00"}, // day of month or day of week
+		{"0 0 * * 7", time.UTC, "2020-03-02 00:00:00", "2020-03-08 00:00:00"}, // 7 is Sunday
+		{"@hourly", time.UTC, "2020-03-01 10:00:00", "2020-03-01 11:00:00"},
+		{"@weekly", time.UTC, "2020-03-01 10:00:00", "2020-03-08 00:00:00"},
+		// 02:30 does not exist on the day of the spring-forward transition
+		{"30 2 * * *", berlin, "2020-03-29 00:00:00", "2020-03-30 02:30:00"},
+		{"0 2 * * *", berlin, "2020-03-01 00:00:00", "2020-03-01 02:00:00"},
+	}
+	for _, tc := range tcs {
+		c, err := parseCronSpec(tc.expr, tc.loc)
+		require.NoError(t, err, tc.expr)
+		next := c.next(at(tc.loc, tc.after))
+		assert.True(t, at(tc.loc, tc.expect).Equal(next), "%q after %s: expected %s, got %s", tc.expr, tc.after, tc.expect, next)
+	}
+
+	// the repeated hour of the fall-back transition fires twice
+	c, err := parseCronSpec("30 2 * * *", berlin)
+	require.NoError(t, err)
+	first := c.next(at(berlin, "2020-10-25 00:00:00"))
+	second := c.next(first)
+	assert.Equal(t, time.Hour, second.Sub(first))
+	assert.Equal(t, 2, second.In(berlin).Hour())
+
+	// the cron expression replaces the interval of the snapper
+	c, err = parseCronSpec("0 2 * * *", time.UTC)
+	require.NoError(t, err)
+	a := args{cron: c, interval: time.Minute, alignWallclock: true}
+	assert.True(t, at(time.UTC, "2020-03-02 02:00:00").Equal(a.nextTick(at(time.UTC, "2020-03-01 02:00:00"), a.interval)))
+}
+
+func TestParseCronSpecInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"0 0 31 4 *", // April has 30 days
+	} {
+		_, err := parseCronSpec(expr, time.UTC)
+		assert.Error(t, err, expr)
+	}
+}
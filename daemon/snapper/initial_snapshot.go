@@ -0,0 +1,132 @@
+package snapper
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/daemon/logging"
+	"github.com/zrepl/zrepl/util/envconst"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// how often a waiting snapper checks for new filesystems if args.initialSnapshot is set
+var initialSnapshotCheckInterval = envconst.Duration("ZREPL_SNAPPER_INITIAL_SNAPSHOT_CHECK_INTERVAL", 1*time.Minute)
+
+// initialSnapshotFSState is the state of a filesystem that the snapper has not seen before.
+type initialSnapshotFSState struct {
+	isPlaceholder       bool
+	hasResumeToken      bool
+	hasPrefixedSnapshot bool
+}
+
+// needsInitialSnapshot returns whether the filesystem must be snapshotted right away, and why not if it must not.
+func (s initialSnapshotFSState) needsInitialSnapshot() (take bool, reason string) {
+	switch {
+	case s.isPlaceholder:
+		return false, "placeholder filesystem"
+	case s.hasResumeToken:
+		return false, "filesystem has partially received state"
+	case s.hasPrefixedSnapshot:
+		return false, "filesystem already has a snapshot with the prefix"
+	default:
+		return true, ""
+	}
+}
+
+func getInitialSnapshotFSState(ctx context.Context, a args, fs *zfs.DatasetPath) (s initialSnapshotFSState, err error) {
+	ph, err := zfs.ZFSGetFilesystemPlaceholderState(ctx, fs)
+	if err != nil {
+		return s, errors.Wrap(err, "cannot get placeholder state")
+	}
+	s.isPlaceholder = ph.IsPlaceholder
+	token, err := zfs.ZFSGetReceiveResumeTokenOrEmptyStringIfNotSupported(ctx, fs)
+	if err != nil {
+		return s, errors.Wrap(err, "cannot get receive resume token")
+	}
+	s.hasResumeToken = token != ""
+	versions, err := zfs.ZFSListFilesystemVersions(ctx, fs, zfs.ListFilesystemVersionsOptions{
+		Types:           zfs.Snapshots,
+		ShortnamePrefix: a.prefix,
+	})
+	if err != nil {
+		return s, errors.Wrap(err, "cannot list snapshots")
+	}
+	s.hasPrefixedSnapshot = len(versions) > 0
+	return s, nil
+}
+
+// unknownFSes returns the filesystems of fss that are not in known.
+func unknownFSes(fss []*zfs.DatasetPath, known map[string]bool) (unknown []*zfs.DatasetPath) {
+	for _, fs := range fss {
+		if !known[fs.ToString()] {
+			unknown = append(unknown, fs)
+		}
+	}
+	return unknown
+}
+
+// setKnownFSes remembers fss as the filesystems that have been seen by the snapper,
+// i.e., that are not candidates for an initial snapshot.
+func (s *Snapper) setKnownFSes(fss []*zfs.DatasetPath) {
+	s.knownFSes = make(map[string]bool, len(fss))
+	for _, fs := range fss {
+		s.knownFSes[fs.ToString()] = true
+	}
+}
+
+// takeInitialSnapshots snapshots the filesystems that the snapper has not seen before
+// and that have no snapshot with a.prefix, see initialSnapshotFSState.
+// Hooks are not run for initial snapshots.
+// Filesystems that could not be checked or snapshotted are checked again on the next call.
+func takeInitialSnapshots(a args, u updater) {
+	fss, err := listFSes(a.ctx, a)
+	if err != nil {
+		getLogger(a.ctx).WithError(err).Error("cannot list filesystems to check for initial snapshots")
+		return
+	}
+	var known map[string]bool
+	u(func(s *Snapper) {
+		known = s.knownFSes
+	})
+
+	taken := 0
+	failed := make(map[string]bool)
+	for _, fs := range unknownFSes(fss, known) {
+		ctx := logging.WithInjectedField(a.ctx, "fs", fs.ToString())
+		l := getLogger(ctx)
+		state, err := getInitialSnapshotFSState(ctx, a, fs)
+		if err != nil {
+			l.WithError(err).Error("cannot determine whether filesystem needs an initial snapshot")
+			failed[fs.ToString()] = true
+			continue
+		}
+		if take, reason := state.needsInitialSnapshot(); !take {
+			l.WithField("reason", reason).Debug("no initial snapshot required")
+			continue
+		}
+		snapname := a.timestampFormat.SnapshotName(a.prefix, a.clock.Now())
+		l = l.WithField("snap", snapname)
+		if err := zfs.ZFSSnapshot(ctx, fs, snapname, false); err != nil {
+			l.WithError(err).Error("cannot take initial snapshot")
+			failed[fs.ToString()] = true
+			continue
+		}
+		l.Info("took initial snapshot of new filesystem")
+		taken++
+	}
+
+	nowKnown := make([]*zfs.DatasetPath, 0, len(fss))
+	for _, fs := range fss {
+		if !failed[fs.ToString()] {
+			nowKnown = append(nowKnown, fs)
+		}
+	}
+	u(func(s *Snapper) {
+		s.setKnownFSes(nowKnown)
+	})
+	if taken > 0 {
+		notifySnapshotsTaken(a.ctx, a.snapshotsTaken)
+	}
+}
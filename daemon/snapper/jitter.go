@@ -0,0 +1,50 @@
+package snapper
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+type jitteredFS struct {
+	fs     *zfs.DatasetPath
+	offset time.Duration // since the start of the snapshotting round
+}
+
+// newJitterRand returns a source of random offsets for a single snapper (*rand.Rand is not safe for concurrent use).
+func newJitterRand() func(n int64) int64 {
+	return rand.New(rand.NewSource(time.Now().UnixNano())).Int63n
+}
+
+// jitteredOrder returns the filesystems of plan in the order in which they are snapshotted.
+// If jitter is zero, all offsets are zero and the order is unspecified.
+// Otherwise each filesystem gets a random offset in [0, jitter) and the result is sorted by offset.
+func (a args) jitteredOrder(plan map[*zfs.DatasetPath]*snapProgress) []jitteredFS {
+	order := make([]jitteredFS, 0, len(plan))
+	for fs := range plan {
+		var offset time.Duration
+		if a.jitter > 0 {
+			offset = time.Duration(a.jitterRand(int64(a.jitter)))
+		}
+		order = append(order, jitteredFS{fs, offset})
+	}
+	sort.SliceStable(order, func(i, j int) bool { return order[i].offset < order[j].offset })
+	return order
+}
+
+// waitJitter blocks until roundStart+offset or until ctx is done.
+func (a args) waitJitter(ctx context.Context, roundStart time.Time, offset time.Duration) {
+	d := roundStart.Add(offset).Sub(a.clock.Now())
+	if d <= 0 {
+		return
+	}
+	t := a.clock.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C():
+	case <-ctx.Done():
+	}
+}
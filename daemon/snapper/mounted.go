@@ -0,0 +1,43 @@
+package snapper
+
+import (
+	"github.com/pkg/errors"
+)
+
+// mountedFilter restricts snapshotting to mounted or unmounted filesystems, based on the `mounted` property.
+type mountedFilter int
+
+const (
+	mountedAny mountedFilter = iota
+	mountedOnly
+	unmountedOnly
+)
+
+func mountedFilterFromConfig(in string) (mountedFilter, error) {
+	switch in {
+	case "", "any":
+		return mountedAny, nil
+	case "mounted":
+		return mountedOnly, nil
+	case "unmounted":
+		return unmountedOnly, nil
+	default:
+		return mountedAny, errors.Errorf("mounted must be one of any, mounted or unmounted, got %q", in)
+	}
+}
+
+// excludes returns true if a dataset whose `mounted` property has value mounted must not be snapshotted.
+// Volumes have no `mounted` property (value "-"), they are never excluded.
+func (f mountedFilter) excludes(mounted string) bool {
+	if mounted != "yes" && mounted != "no" {
+		return false
+	}
+	switch f {
+	case mountedOnly:
+		return mounted != "yes"
+	case unmountedOnly:
+		return mounted != "no"
+	default:
+		return false
+	}
+}
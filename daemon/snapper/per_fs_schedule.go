@@ -0,0 +1,140 @@
+package snapper
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// intervalOverride replaces args.interval for the filesystems matched by filter.
+type intervalOverride struct {
+	filesystems config.FilesystemsFilter // for DumpState
+	filter      zfs.DatasetFilter
+	interval    time.Duration
+}
+
+// returns nil if in is empty
+func intervalOverridesFromConfig(in []config.SnapshottingIntervalOverride) ([]intervalOverride, error) {
+	if len(in) == 0 {
+		return nil, nil
+	}
+	overrides := make([]intervalOverride, len(in))
+	for i, o := range in {
+		if o.Interval <= 0 {
+			return nil, fmt.Errorf("override #%d: interval must be positive", i+1)
+		}
+		if len(o.Filesystems) == 0 {
+			return nil, fmt.Errorf("override #%d: filesystems must not be empty", i+1)
+		}
+		f, err := filters.DatasetMapFilterFromConfig(o.Filesystems)
+		if err != nil {
+			return nil, fmt.Errorf("override #%d: invalid filesystems filter: %s", i+1, err)
+		}
+		overrides[i] = intervalOverride{filesystems: o.Filesystems, filter: f, interval: o.Interval}
+	}
+	return overrides, nil
+}
+
+// intervalFor returns the interval of the first override that matches fs, or a.interval if none matches.
+func (a args) intervalFor(fs *zfs.DatasetPath) time.Duration {
+	for _, o := range a.intervalOverrides {
+		// a DatasetMapFilter only fails if it is used as a mapping, which intervalOverridesFromConfig rules out
+		if pass, err := o.filter.Filter(fs); err == nil && pass {
+			return o.interval
+		}
+	}
+	return a.interval
+}
+
+// perFSSchedule returns true if filesystems may be due at different times,
+// in which case Snapper.schedule tracks when each filesystem is due.
+func (a args) perFSSchedule() bool {
+	return a.adaptive != nil || len(a.intervalOverrides) > 0
+}
+
+// per-filesystem state if args.perFSSchedule(), protected by Snapper.mtx
+type fsSchedule struct {
+	interval time.Duration // args.intervalFor the filesystem, or the adapted interval
+	nextDue  time.Time
+}
+
+// scheduleOf returns the schedule of fs, creating it if fs is not scheduled yet.
+// New schedules are due immediately.
+//
+// Must be called with s.mtx held.
+func (s *Snapper) scheduleOf(fs *zfs.DatasetPath) *fsSchedule {
+	st, ok := s.schedule[fs.ToString()]
+	if !ok {
+		st = &fsSchedule{interval: s.args.intervalFor(fs)}
+		s.schedule[fs.ToString()] = st
+	}
+	return st
+}
+
+// scheduleDue returns those filesystems in fss that are due at now.
+// The schedules of filesystems that are no longer in fss are dropped.
+//
+// Must be called with s.mtx held.
+func (s *Snapper) scheduleDue(now time.Time, fss []*zfs.DatasetPath) []*zfs.DatasetPath {
+	schedule := make(map[string]*fsSchedule, len(fss))
+	due := make([]*zfs.DatasetPath, 0, len(fss))
+	for _, fs := range fss {
+		st, ok := s.schedule[fs.ToString()]
+		if !ok {
+			st = &fsSchedule{interval: s.args.intervalFor(fs), nextDue: now}
+		}
+		schedule[fs.ToString()] = st
+		if !now.Before(st.nextDue) {
+			due = append(due, fs)
+		}
+	}
+	s.schedule = schedule
+	return due
+}
+
+// scheduleSyncPoints schedules each filesystem in fss at its sync point, as determined by findSyncPoint.
+// Filesystems without a sync point remain due immediately.
+//
+// Must be called with s.mtx held.
+func (s *Snapper) scheduleSyncPoints(fss []*zfs.DatasetPath, syncPoints map[string]time.Time) {
+	for _, fs := range fss {
+		if sp, ok := syncPoints[fs.ToString()]; ok {
+			s.scheduleOf(fs).nextDue = sp
+		}
+	}
+}
+
+// scheduleNext must be called with s.mtx held after fs was part of the snapshotting round
+// that started at s.lastInvocation, regardless of whether the snapshot was taken.
+func (s *Snapper) scheduleNext(fs *zfs.DatasetPath) {
+	st := s.scheduleOf(fs)
+	st.nextDue = s.args.nextTickFor(fs, s.lastInvocation, st.interval)
+}
+
+// earliestDueAfter returns the earliest time after t at which a filesystem is due, or the zero time if there is none.
+// Filesystems that are already due at t are ignored because they were due in the round at t:
+// if that round failed, retrying them immediately would result in a busy loop.
+//
+// Must be called with s.mtx held.
+func (s *Snapper) earliestDueAfter(t time.Time) (earliest time.Time) {
+	for _, st := range s.schedule {
+		if st.nextDue.After(t) && (earliest.IsZero() || st.nextDue.Before(earliest)) {
+			earliest = st.nextDue
+		}
+	}
+	return earliest
+}
+
+// scheduledIntervals returns a copy of the current per-filesystem intervals.
+//
+// Must be called with s.mtx held.
+func (s *Snapper) scheduledIntervals() map[string]time.Duration {
+	res := make(map[string]time.Duration, len(s.schedule))
+	for fs, st := range s.schedule {
+		res[fs] = st.interval
+	}
+	return res
+}
@@ -0,0 +1,34 @@
+package snapper
+
+import (
+	"strings"
+
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// matchingHookSets returns a value for each of fss that is equal for two filesystems
+// if and only if the same hooks of hookList match them.
+//
+// The hooks of a recursive snapshot run once, for its topmost dataset, thus the values
+// are used as the keys of zfs.SnapshotSubtrees: a dataset is only snapshotted together with
+// its parent if the same hooks match both.
+func matchingHookSets(hookList hooks.List, fss []*zfs.DatasetPath) (map[string]string, error) {
+	sets := make(map[string]string, len(fss))
+	for _, fs := range fss {
+		var set strings.Builder
+		for _, h := range hookList {
+			pass, err := h.Filesystems().Filter(fs)
+			if err != nil {
+				return nil, err
+			}
+			if pass {
+				set.WriteByte('1')
+			} else {
+				set.WriteByte('0')
+			}
+		}
+		sets[fs.ToString()] = set.String()
+	}
+	return sets, nil
+}
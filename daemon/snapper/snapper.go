@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -27,6 +28,8 @@ const (
 	SnapStarted
 	SnapDone
 	SnapError
+	SnapIncomplete // not snapshotted because the round exceeded args.maxCycleDuration
+	SnapSkipped    // not snapshotted because args.skipUnchanged and nothing was written since the latest snapshot
 )
 
 // All fields protected by Snapper.mtx
@@ -38,10 +41,12 @@ type snapProgress struct {
 	startAt  time.Time
 	hookPlan *hooks.Plan
 
-	// SnapDone
+	// SnapDone, SnapSkipped
 	doneAt time.Time
+	guid   uint64 // only if args.verify
 
-	// SnapErr TODO disambiguate state
+	// SnapError
+	err        error
 	runResults hooks.PlanReport
 }
 
@@ -49,10 +54,42 @@ type args struct {
 	ctx            context.Context
 	prefix         string
 	interval       time.Duration
-	fsf            *filters.DatasetMapFilter
+	cron           *cronSpec // if not nil, snapshots are taken at its fire times instead of every interval
+	fsf            zfs.DatasetFilter
+	datasets       []*zfs.DatasetPath // if not nil, snapshot exactly these datasets instead of those matched by fsf
 	snapshotsTaken chan<- struct{}
 	hooks          *hooks.List
+	hookMetrics    *hooks.Metrics
 	dryRun         bool
+	verify         bool
+	adaptive       *adaptiveInterval // nil if disabled
+	alignWallclock bool
+	// determines the snapshot names together with prefix
+	timestampFormat *TimestampFormat
+	// if not empty, the first override that matches a filesystem determines its interval instead of interval
+	intervalOverrides []intervalOverride
+	// upper bound for Planning + Snapshotting, 0 means unlimited
+	maxCycleDuration time.Duration
+	// if > 0, each filesystem is snapshotted at a random offset in [0, jitter) after the start of the round
+	jitter     time.Duration
+	jitterRand func(n int64) int64
+	// datasets with this property set to "off" are not snapshotted, empty if disabled
+	snapshotProperty        string
+	snapshotPropertyInherit bool // if false, only locally set values exclude datasets
+	// do not snapshot filesystems whose `written` property is zero
+	skipUnchanged bool
+	// while waiting, snapshot new filesystems without a snapshot with prefix, see takeInitialSnapshots
+	initialSnapshot bool
+	// snapshot subtrees atomically, see zfs.SnapshotSubtrees
+	recursive bool
+	// only snapshot mounted or unmounted filesystems
+	mounted mountedFilter
+	// maximum number of filesystems that are snapshotted (including their hooks) concurrently, see snapshotLanes
+	concurrency      int
+	serializePerPool bool
+	// see Snapper.Trigger
+	trigger chan struct{}
+	clock   Clock
 }
 
 type Snapper struct {
@@ -64,10 +101,21 @@ type Snapper struct {
 	// set in state Plan, used in Waiting
 	lastInvocation time.Time
 
+	// valid for state Planning: the round was started by Trigger, snapshot all filesystems
+	forced bool
+
 	// valid for state Snapshotting
+	// If args.recursive, the datasets of a recursive snapshot share their snapProgress.
 	plan map[*zfs.DatasetPath]*snapProgress
 
+	// only used if args.perFSSchedule(), keyed by filesystem name
+	schedule map[string]*fsSchedule
+
+	// only used if args.initialSnapshot: names of the filesystems listed in the last round or check
+	knownFSes map[string]bool
+
 	// valid for state SyncUp and Waiting
+	// With a per-filesystem schedule, this is the earliest time at which any filesystem is due.
 	sleepUntil time.Time
 
 	// valid for state Err
@@ -109,7 +157,7 @@ func getLogger(ctx context.Context) Logger {
 	return logging.GetLogger(ctx, logging.SubsysSnapshot)
 }
 
-func PeriodicFromConfig(g *config.Global, fsf *filters.DatasetMapFilter, in *config.SnapshottingPeriodic) (*Snapper, error) {
+func PeriodicFromConfig(g *config.Global, fsf zfs.DatasetFilter, in *config.SnapshottingPeriodic, hookMetrics *hooks.Metrics) (*Snapper, error) {
 	if in.Prefix == "" {
 		return nil, errors.New("prefix must not be empty")
 	}
@@ -122,17 +170,147 @@ func PeriodicFromConfig(g *config.Global, fsf *filters.DatasetMapFilter, in *con
 		return nil, errors.Wrap(err, "hook config error")
 	}
 
+	datasets, err := datasetsFromConfig(fsf, in.Datasets)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid dataset list")
+	}
+
+	if in.SnapshotProperty != "" && !strings.Contains(in.SnapshotProperty, ":") {
+		return nil, errors.Errorf("snapshot_property %q is not a ZFS user property (must contain a colon)", in.SnapshotProperty)
+	}
+
+	timestampFormat, err := TimestampFormatFromConfig(in.Naming())
+	if err != nil {
+		return nil, err
+	}
+
+	adaptive, err := adaptiveIntervalFromConfig(in.Interval, in.AdaptiveInterval)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid adaptive_interval config")
+	}
+
+	if in.MaxCycleDuration < 0 {
+		return nil, errors.New("max_cycle_duration must not be negative")
+	}
+	if in.Jitter < 0 {
+		return nil, errors.New("jitter must not be negative")
+	}
+	if in.Jitter >= in.Interval {
+		return nil, errors.Errorf("jitter (%s) must be shorter than interval (%s)", in.Jitter, in.Interval)
+	}
+	if in.MaxCycleDuration > 0 && in.Jitter >= in.MaxCycleDuration {
+		return nil, errors.Errorf("jitter (%s) must be shorter than max_cycle_duration (%s)", in.Jitter, in.MaxCycleDuration)
+	}
+
+	overrides, err := intervalOverridesFromConfig(in.IntervalOverrides)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid interval_overrides")
+	}
+	if adaptive != nil {
+		for i, o := range overrides {
+			if o.interval > adaptive.maxInterval {
+				return nil, errors.Errorf("interval_overrides: override #%d: interval (%s) must not exceed adaptive_interval.max_interval (%s)", i+1, o.interval, adaptive.maxInterval)
+			}
+		}
+	}
+	for i, o := range overrides {
+		if in.Jitter >= o.interval {
+			return nil, errors.Errorf("interval_overrides: override #%d: interval (%s) must be longer than jitter (%s)", i+1, o.interval, in.Jitter)
+		}
+	}
+	mounted, err := mountedFilterFromConfig(in.Mounted)
+	if err != nil {
+		return nil, err
+	}
+	concurrency, err := concurrencyFromConfig(in.Concurrency)
+	if err != nil {
+		return nil, err
+	}
+
+	if in.Recursive {
+		// these snapshot or skip filesystems individually, which would break up recursive snapshots
+		switch {
+		case adaptive != nil:
+			return nil, errors.New("recursive cannot be combined with adaptive_interval")
+		case len(overrides) > 0:
+			return nil, errors.New("recursive cannot be combined with interval_overrides")
+		case in.Jitter > 0:
+			return nil, errors.New("recursive cannot be combined with jitter")
+		case in.SkipUnchanged:
+			return nil, errors.New("recursive cannot be combined with skip_unchanged")
+		}
+	}
+
 	args := args{
 		prefix:   in.Prefix,
 		interval: in.Interval,
 		fsf:      fsf,
+		datasets: datasets,
 		hooks:    hookList,
+		verify:   in.Verify,
+		adaptive: adaptive,
+		clock:    realClock{},
+
+		alignWallclock:    in.AlignToWallclock,
+		timestampFormat:   timestampFormat,
+		intervalOverrides: overrides,
+		maxCycleDuration:  in.MaxCycleDuration,
+		jitter:            in.Jitter,
+		jitterRand:        newJitterRand(),
+		skipUnchanged:     in.SkipUnchanged,
+		initialSnapshot:   in.InitialSnapshot,
+		recursive:         in.Recursive,
+		mounted:           mounted,
+		concurrency:       concurrency,
+		serializePerPool:  in.SerializePerPool,
+
+		hookMetrics:             hookMetrics,
+		snapshotProperty:        in.SnapshotProperty,
+		snapshotPropertyInherit: in.SnapshotPropertyInherit,
 		// ctx and log is set in Run()
 	}
 
-	return &Snapper{state: SyncUp, args: args}, nil
+	return newSnapper(args), nil
 }
 
+func newSnapper(args args) *Snapper {
+	args.trigger = make(chan struct{}, 1)
+	return &Snapper{state: SyncUp, args: args, schedule: make(map[string]*fsSchedule)}
+}
+
+var snapshotsTakenBufferDepth = envconst.Int("ZREPL_SNAPPER_SNAPSHOTS_TAKEN_BUFFER_DEPTH", 1)
+
+// NewSnapshotsTakenChan returns a channel for use as Run's snapshotsTaken argument.
+//
+// Run never blocks on snapshotsTaken, but drops the event if the channel is full.
+// The returned channel is buffered, thus a full channel means that an event is still pending,
+// and dropping the new event only coalesces it with the pending one.
+// With an unbuffered channel, the event would be lost if the receiver is not ready.
+func NewSnapshotsTakenChan() chan struct{} {
+	depth := snapshotsTakenBufferDepth
+	if depth < 1 {
+		depth = 1
+	}
+	return make(chan struct{}, depth)
+}
+
+// notifySnapshotsTaken sends an event on snapshotsTaken without blocking and reports whether it was sent.
+// A nil channel means that nobody is interested in the events, e.g., for snap jobs.
+// If the channel is full, the event is coalesced with the pending one.
+func notifySnapshotsTaken(ctx context.Context, snapshotsTaken chan<- struct{}) (sent bool) {
+	if snapshotsTaken == nil {
+		return false
+	}
+	select {
+	case snapshotsTaken <- struct{}{}:
+		return true
+	default:
+		getLogger(ctx).Warn("callback channel is full, coalescing snapshot update event with pending one")
+		return false
+	}
+}
+
+// snapshotsTaken should be created using NewSnapshotsTakenChan.
 func (s *Snapper) Run(ctx context.Context, snapshotsTaken chan<- struct{}) {
 	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
 	getLogger(ctx).Debug("start")
@@ -190,43 +368,102 @@ func onMainCtxDone(ctx context.Context, u updater) state {
 
 func syncUp(a args, u updater) state {
 	u(func(snapper *Snapper) {
-		snapper.lastInvocation = time.Now()
+		snapper.lastInvocation = a.clock.Now()
 	})
-	fss, err := listFSes(a.ctx, a.fsf)
+	warnMissingDatasets(a.ctx, a)
+	fss, err := listFSes(a.ctx, a)
 	if err != nil {
 		return onErr(err, u)
 	}
-	syncPoint, err := findSyncPoint(a.ctx, fss, a.prefix, a.interval)
+	var intervals map[string]time.Duration
+	u(func(s *Snapper) {
+		intervals = s.scheduledIntervals()
+	})
+	nextTickFor := func(fs *zfs.DatasetPath, last time.Time) time.Time {
+		if i, ok := intervals[fs.ToString()]; ok {
+			return a.nextTickFor(fs, last, i)
+		}
+		return a.nextTickFor(fs, last, a.intervalFor(fs))
+	}
+	syncPoint, fsSyncPoints, err := findSyncPoint(a.ctx, a.clock, fss, a.prefix, a.timestampFormat, nextTickFor)
 	if err != nil {
 		return onErr(err, u)
 	}
 	u(func(s *Snapper) {
 		s.sleepUntil = syncPoint
+		if a.perFSSchedule() {
+			s.scheduleSyncPoints(fss, fsSyncPoints)
+		}
+		s.setKnownFSes(fss)
 	})
-	t := time.NewTimer(time.Until(syncPoint))
+	t := a.clock.NewTimer(syncPoint.Sub(a.clock.Now()))
 	defer t.Stop()
 	select {
-	case <-t.C:
+	case <-t.C():
 		return u(func(s *Snapper) {
 			s.state = Planning
 		}).sf()
+	case <-a.trigger:
+		return onTrigger(a, u)
 	case <-a.ctx.Done():
 		return onMainCtxDone(a.ctx, u)
 	}
 }
 
+// cycleContext returns a context that is cancelled once the snapshotting round
+// that started at lastInvocation exceeds a.maxCycleDuration.
+func (a args) cycleContext(lastInvocation time.Time) (context.Context, context.CancelFunc) {
+	if a.maxCycleDuration == 0 {
+		return context.WithCancel(a.ctx)
+	}
+	return context.WithTimeout(a.ctx, lastInvocation.Add(a.maxCycleDuration).Sub(a.clock.Now()))
+}
+
 func plan(a args, u updater) state {
+	now := a.clock.Now()
+	a.drainTrigger()
+	var forced bool
 	u(func(snapper *Snapper) {
-		snapper.lastInvocation = time.Now()
+		snapper.lastInvocation = now
+		forced = snapper.forced
+		snapper.forced = false
 	})
-	fss, err := listFSes(a.ctx, a.fsf)
+	cycleCtx, cancel := a.cycleContext(now)
+	defer cancel()
+	fss, err := listFSes(cycleCtx, a)
 	if err != nil {
 		return onErr(err, u)
 	}
+	u(func(snapper *Snapper) {
+		snapper.setKnownFSes(fss)
+		if a.perFSSchedule() {
+			due := snapper.scheduleDue(now, fss)
+			if !forced {
+				fss = due
+			}
+		}
+	})
 
 	plan := make(map[*zfs.DatasetPath]*snapProgress, len(fss))
-	for _, fs := range fss {
-		plan[fs] = &snapProgress{state: SnapPending}
+	if a.recursive {
+		all, err := zfs.ZFSListMapping(cycleCtx, zfs.NoFilter())
+		if err != nil {
+			return onErr(errors.Wrap(err, "cannot list datasets for recursive snapshots"), u)
+		}
+		hookSets, err := matchingHookSets(*a.hooks, fss)
+		if err != nil {
+			return onErr(errors.Wrap(err, "cannot match hooks for recursive snapshots"), u)
+		}
+		for _, subtree := range zfs.SnapshotSubtrees(fss, all, hookSets) {
+			progress := &snapProgress{state: SnapPending}
+			for _, fs := range subtree.Datasets {
+				plan[fs] = progress
+			}
+		}
+	} else {
+		for _, fs := range fss {
+			plan[fs] = &snapProgress{state: SnapPending}
+		}
 	}
 	return u(func(s *Snapper) {
 		s.state = Snapshotting
@@ -235,25 +472,92 @@ func plan(a args, u updater) state {
 	}).sf()
 }
 
+// snapshotGroup creates snapshot name of the datasets of group atomically, see zfs.ZFSSnapshotMany.
+// If some snapshots could not be created, the error of the first one is returned.
+func snapshotGroup(ctx context.Context, group []*zfs.DatasetPath, name string) error {
+	specs := make([]*zfs.SnapshotSpec, len(group))
+	errs := make([]error, len(group))
+	for i, fs := range group {
+		specs[i] = &zfs.SnapshotSpec{FS: fs, Name: name, ErrOut: &errs[i]}
+	}
+	zfs.ZFSSnapshotMany(ctx, specs)
+	for i, err := range errs {
+		if err != nil {
+			return errors.Wrapf(err, "cannot snapshot %s", specs[i])
+		}
+	}
+	return nil
+}
+
 func snapshot(a args, u updater) state {
 
 	var plan map[*zfs.DatasetPath]*snapProgress
+	var lastInvocation time.Time
 	u(func(snapper *Snapper) {
 		plan = snapper.plan
+		lastInvocation = snapper.lastInvocation
 	})
 
+	// Snapshots that have already been taken when the round exceeds its maximum duration are kept,
+	// the remaining filesystems are left for the next round.
+	cycleCtx, cancel := a.cycleContext(lastInvocation)
+	defer cancel()
+	var incomplete []string
+
+	planFSs := make([]string, 0, len(plan))
+	for fs := range plan {
+		planFSs = append(planFSs, fs.ToString())
+	}
+	sort.Strings(planFSs)
+	cycleCtx = hooks.WithPlanFilesystems(cycleCtx, planFSs)
+
 	hookMatchCount := make(map[hooks.Hook]int, len(*a.hooks))
 	for _, h := range *a.hooks {
 		hookMatchCount[h] = 0
 	}
 
-	anyFsHadErr := false
-	// TODO channel programs -> allow a little jitter?
+	// datasets that share a snapProgress are snapshotted together, sorted by name => recursive snapshot of the first
+	groups := make(map[*snapProgress][]*zfs.DatasetPath, len(plan))
 	for fs, progress := range plan {
-		suffix := time.Now().In(time.UTC).Format("20060102_150405_000")
-		snapname := fmt.Sprintf("%s%s", a.prefix, suffix)
+		groups[progress] = append(groups[progress], fs)
+	}
+	for _, g := range groups {
+		sort.Slice(g, func(i, j int) bool { return g[i].ToString() < g[j].ToString() })
+	}
+
+	// with concurrency > 1, filesystems are snapshotted concurrently: mtx protects the variables below
+	var mtx sync.Mutex
+	anyFsHadErr := false
+	skipped := 0
+	snapshotFS := func(laneCtx context.Context, fs *zfs.DatasetPath) {
+		progress := plan[fs]
+		group := groups[progress]
+		recursive := len(group) > 1
+		if laneCtx.Err() != nil {
+			mtx.Lock()
+			for _, fs := range group {
+				incomplete = append(incomplete, fs.ToString())
+			}
+			mtx.Unlock()
+			u(func(snapper *Snapper) {
+				progress.state = SnapIncomplete
+				if a.perFSSchedule() {
+					snapper.scheduleNext(fs)
+				}
+			})
+			return
+		}
+
+		ctx := logging.WithInjectedField(laneCtx, "fs", fs.ToString())
 
-		ctx := logging.WithInjectedField(a.ctx, "fs", fs.ToString())
+		if a.skipUnchanged && skipUnchanged(ctx, a, u, fs, progress) {
+			mtx.Lock()
+			skipped++
+			mtx.Unlock()
+			return
+		}
+
+		snapname := a.timestampFormat.SnapshotName(a.prefix, a.clock.Now())
 		ctx = logging.WithInjectedField(ctx, "snap", snapname)
 
 		hookEnvExtra := hooks.Env{
@@ -263,15 +567,48 @@ func snapshot(a args, u updater) state {
 
 		jobCallback := hooks.NewCallbackHookForFilesystem("snapshot", fs, func(ctx context.Context) (err error) {
 			l := getLogger(ctx)
+			written, writtenErr := zfs.ZFSGetWrittenSince(ctx, fs, "")
+			if writtenErr != nil {
+				l.WithError(writtenErr).Warn("cannot get bytes written since last snapshot")
+			} else {
+				l.WithField("written", written).Debug("bytes written since last snapshot")
+			}
+			if recursive {
+				l = l.WithField("recursive", len(group))
+			}
 			l.Debug("create snapshot")
-			err = zfs.ZFSSnapshot(ctx, fs, snapname, false) // TODO propagate context to ZFSSnapshot
-			if err != nil {
+			if err = snapshotGroup(ctx, group, snapname); err != nil {
 				l.WithError(err).Error("cannot create snapshot")
+				return
+			}
+			if a.verify {
+				guid, err := verifySnapshot(ctx, fs, snapname)
+				if err != nil {
+					l.WithError(err).Error("snapshot verification failed")
+					return err
+				}
+				for _, child := range group[1:] {
+					if _, err := verifySnapshot(ctx, child, snapname); err != nil {
+						l.WithError(err).WithField("child", child.ToString()).Error("snapshot verification failed")
+						return err
+					}
+				}
+				u(func(snapper *Snapper) {
+					progress.guid = guid
+				})
+			}
+			if a.adaptive != nil {
+				var interval time.Duration
+				u(func(snapper *Snapper) {
+					interval = snapper.updateAdaptiveInterval(fs, written, writtenErr)
+				})
+				l.WithField("interval", interval).Debug("adapted snapshot interval")
 			}
 			return
 		})
 
 		fsHadErr := false
+		var fsErr error // if fsHadErr
 		var planReport hooks.PlanReport
 		var plan *hooks.Plan
 		{
@@ -279,24 +616,28 @@ func snapshot(a args, u updater) state {
 			if err != nil {
 				getLogger(ctx).WithError(err).Error("unexpected filter error")
 				fsHadErr = true
+				fsErr = err
 				goto updateFSState
 			}
 			// account for running hooks
+			mtx.Lock()
 			for _, h := range filteredHooks {
 				hookMatchCount[h] = hookMatchCount[h] + 1
 			}
+			mtx.Unlock()
 
 			var planErr error
-			plan, planErr = hooks.NewPlan(&filteredHooks, hooks.PhaseSnapshot, jobCallback, hookEnvExtra)
+			plan, planErr = hooks.NewPlan(&filteredHooks, hooks.PhaseSnapshot, jobCallback, hookEnvExtra, a.hookMetrics)
 			if planErr != nil {
 				fsHadErr = true
+				fsErr = planErr
 				getLogger(ctx).WithError(planErr).Error("cannot create job hook plan")
 				goto updateFSState
 			}
 		}
 		u(func(snapper *Snapper) {
 			progress.name = snapname
-			progress.startAt = time.Now()
+			progress.startAt = a.clock.Now()
 			progress.hookPlan = plan
 			progress.state = SnapStarted
 		})
@@ -306,6 +647,7 @@ func snapshot(a args, u updater) state {
 			planReport = plan.Report()
 			fsHadErr = planReport.HadError() // not just fatal errors
 			if fsHadErr {
+				fsErr = planReportError(planReport)
 				getLogger(ctx).WithField("report", planReport.String()).Error("end run job plan with error")
 			} else {
 				getLogger(ctx).WithField("report", planReport.String()).Info("end run job plan successful")
@@ -313,27 +655,68 @@ func snapshot(a args, u updater) state {
 		}
 
 	updateFSState:
+		mtx.Lock()
 		anyFsHadErr = anyFsHadErr || fsHadErr
+		mtx.Unlock()
 		u(func(snapper *Snapper) {
-			progress.doneAt = time.Now()
+			progress.doneAt = a.clock.Now()
 			progress.state = SnapDone
 			if fsHadErr {
 				progress.state = SnapError
+				progress.err = fsErr
 			}
 			progress.runResults = planReport
+			if a.perFSSchedule() {
+				snapper.scheduleNext(fs)
+			}
 		})
 	}
 
-	select {
-	case a.snapshotsTaken <- struct{}{}:
-	default:
-		if a.snapshotsTaken != nil {
-			getLogger(a.ctx).Warn("callback channel is full, discarding snapshot update event")
+	order := a.jitteredOrder(plan)
+	leaders := order[:0]
+	for _, jfs := range order {
+		if group := groups[plan[jfs.fs]]; jfs.fs == group[0] {
+			leaders = append(leaders, jfs) // the other members are snapshotted together with group[0]
 		}
 	}
+	sem := a.snapshotSemaphore()
+	var wg sync.WaitGroup
+	for _, lane := range a.snapshotLanes(leaders) {
+		wg.Add(1)
+		go func(lane []jitteredFS) {
+			defer wg.Done()
+			// zfs commands and hooks create trace spans, which must not be concurrent within a task
+			laneCtx, endTask := trace.WithTask(cycleCtx, "snapshot-lane")
+			defer endTask()
+			for _, jfs := range lane {
+				// wait without holding a slot, the filesystems of other lanes might be due earlier
+				if jfs.offset > 0 {
+					getLogger(laneCtx).WithField("fs", jfs.fs.ToString()).WithField("offset", jfs.offset).Debug("wait for jitter offset")
+					a.waitJitter(laneCtx, lastInvocation, jfs.offset)
+				}
+				sem <- struct{}{}
+				snapshotFS(laneCtx, jfs.fs)
+				<-sem
+			}
+		}(lane)
+	}
+	wg.Wait()
+
+	// with a per-filesystem schedule, rounds in which no filesystem is due are expected
+	if len(plan) > skipped {
+		notifySnapshotsTaken(a.ctx, a.snapshotsTaken)
+	}
+
+	if len(incomplete) > 0 {
+		sort.Strings(incomplete)
+		getLogger(a.ctx).
+			WithField("max_cycle_duration", a.maxCycleDuration).
+			WithField("incomplete", incomplete).
+			Warn("snapshotting round exceeded max_cycle_duration, remaining filesystems are snapshotted in the next round")
+	}
 
 	for h, mc := range hookMatchCount {
-		if mc == 0 {
+		if mc == 0 && len(incomplete) == 0 && len(plan) > 0 { // with incomplete filesystems, the hook might have matched them
 			hookIdx := -1
 			for idx, ah := range *a.hooks {
 				if ah == h {
@@ -356,13 +739,50 @@ func snapshot(a args, u updater) state {
 	}).sf()
 }
 
+// skipUnchanged moves progress to SnapSkipped and returns true if nothing has been written to fs since its latest snapshot.
+// The `written` property of a filesystem is relative to its latest snapshot, i.e., equivalent to written@<latest>.
+// If fs has no snapshots, `written` equals `referenced`, which is never zero.
+// If the property cannot be determined, fs is snapshotted as usual.
+func skipUnchanged(ctx context.Context, a args, u updater, fs *zfs.DatasetPath, progress *snapProgress) bool {
+	l := getLogger(ctx)
+	written, err := zfs.ZFSGetWrittenSince(ctx, fs, "")
+	if err != nil {
+		l.WithError(err).Warn("cannot determine whether filesystem changed since latest snapshot, snapshotting it anyway")
+		return false
+	}
+	if written != 0 {
+		return false
+	}
+	l.Info("skip snapshot, filesystem unchanged since latest snapshot")
+	u(func(snapper *Snapper) {
+		progress.state = SnapSkipped
+		progress.doneAt = a.clock.Now()
+		if a.adaptive != nil {
+			snapper.updateAdaptiveInterval(fs, written, nil)
+		}
+		if a.perFSSchedule() {
+			snapper.scheduleNext(fs)
+		}
+	})
+	return true
+}
+
 func wait(a args, u updater) state {
 	var sleepUntil time.Time
+	var checkInitialSnapshots bool
 	u(func(snapper *Snapper) {
+		checkInitialSnapshots = a.initialSnapshot && snapper.state != SyncUpErrWait
 		lastTick := snapper.lastInvocation
-		snapper.sleepUntil = lastTick.Add(a.interval)
+		snapper.sleepUntil = a.nextTick(lastTick, a.interval)
+		if len(a.intervalOverrides) > 0 {
+			// Overrides may be shorter than a.interval.
+			// Without overrides, we keep waking up at a.interval ticks, thus adapted intervals are rounded up to multiples of a.interval.
+			if due := snapper.earliestDueAfter(lastTick); !due.IsZero() && due.Before(snapper.sleepUntil) {
+				snapper.sleepUntil = due
+			}
+		}
 		sleepUntil = snapper.sleepUntil
-		log := getLogger(a.ctx).WithField("sleep_until", sleepUntil).WithField("duration", a.interval)
+		log := getLogger(a.ctx).WithField("sleep_until", sleepUntil).WithField("duration", sleepUntil.Sub(lastTick))
 		logFunc := log.Debug
 		if snapper.state == ErrorWait || snapper.state == SyncUpErrWait {
 			logFunc = log.Error
@@ -370,27 +790,259 @@ func wait(a args, u updater) state {
 		logFunc("enter wait-state after error")
 	})
 
-	t := time.NewTimer(time.Until(sleepUntil))
+	t := a.clock.NewTimer(sleepUntil.Sub(a.clock.Now()))
 	defer t.Stop()
 
+	var checkC <-chan time.Time // nil blocks forever
+	if checkInitialSnapshots {
+		ct := a.clock.NewTimer(initialSnapshotCheckInterval)
+		defer ct.Stop()
+		checkC = ct.C()
+	}
+
 	select {
-	case <-t.C:
+	case <-t.C():
 		return u(func(snapper *Snapper) {
 			snapper.state = Planning
 		}).sf()
+	case <-checkC:
+		takeInitialSnapshots(a, u)
+		return u(nil).sf() // keep waiting
+	case <-a.trigger:
+		return onTrigger(a, u)
 	case <-a.ctx.Done():
 		return onMainCtxDone(a.ctx, u)
 	}
 }
 
-func listFSes(ctx context.Context, mf *filters.DatasetMapFilter) (fss []*zfs.DatasetPath, err error) {
-	return zfs.ZFSListMapping(ctx, mf)
+// verifySnapshot checks that fs@snapname exists and returns its GUID.
+// Some broken setups have been observed to exit `zfs snapshot` with status 0 without creating the snapshot.
+func verifySnapshot(ctx context.Context, fs *zfs.DatasetPath, snapname string) (guid uint64, err error) {
+	v, err := zfs.ZFSGetFilesystemVersion(ctx, fmt.Sprintf("%s@%s", fs.ToString(), snapname))
+	if err != nil {
+		return 0, errors.Wrap(err, "snapshot does not exist although `zfs snapshot` succeeded")
+	}
+	if v.Guid == 0 {
+		return 0, errors.New("snapshot exists but has no GUID")
+	}
+	return v.Guid, nil
+}
+
+func listFSes(ctx context.Context, a args) (fss []*zfs.DatasetPath, err error) {
+	var props []string
+	if a.snapshotProperty != "" {
+		props = append(props, a.snapshotProperty)
+	}
+	if a.mounted != mountedAny {
+		props = append(props, "mounted")
+	}
+	// value returns the value of property prop from fields, which are the values of props
+	value := func(fields []string, prop string) string {
+		for i, p := range props {
+			if p == prop {
+				return fields[i]
+			}
+		}
+		return ""
+	}
+
+	type candidate struct {
+		fs        *zfs.DatasetPath
+		propValue string
+		mounted   string
+	}
+	var candidates []candidate
+	if a.datasets == nil {
+		res, err := zfs.ZFSListMappingProperties(ctx, a.fsf, props)
+		if err != nil {
+			return nil, err
+		}
+		candidates = make([]candidate, len(res))
+		for i, r := range res {
+			candidates[i].fs = r.Path
+			candidates[i].propValue = value(r.Fields, a.snapshotProperty)
+			candidates[i].mounted = value(r.Fields, "mounted")
+		}
+	} else {
+		// explicit list: no need to list all datasets, their existence is checked in syncUp
+		candidates = make([]candidate, 0, len(a.datasets))
+		for _, ds := range a.datasets {
+			c := candidate{fs: ds.Copy()}
+			if len(props) > 0 {
+				p, err := zfs.ZFSGet(ctx, ds, props)
+				if _, ok := err.(*zfs.DatasetDoesNotExist); ok {
+					getLogger(ctx).WithField("fs", ds.ToString()).Warn("dataset does not exist, not snapshotting it")
+					continue
+				} else if err != nil {
+					return nil, errors.Wrapf(err, "cannot get properties of dataset %q", ds.ToString())
+				}
+				c.propValue = p.Get(a.snapshotProperty)
+				c.mounted = p.Get("mounted")
+			}
+			candidates = append(candidates, c)
+		}
+	}
+
+	if pf, ok := a.fsf.(zfs.DatasetPropertyFilter); ok && a.datasets != nil {
+		// zfs.ZFSListMappingProperties applies the property filter, but we did not use it for the explicit list
+		names := make([]string, len(candidates))
+		for i, c := range candidates {
+			names[i] = c.fs.ToString()
+		}
+		vals, err := zfs.ZFSGetPropertyValues(ctx, pf.FilterProperties(), names...)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot get properties for filesystems filter")
+		}
+		filtered := candidates[:0]
+		for _, c := range candidates {
+			pass, err := pf.FilterWithProperties(c.fs, vals[c.fs.ToString()])
+			if err != nil {
+				return nil, err
+			}
+			if pass {
+				filtered = append(filtered, c)
+			}
+		}
+		candidates = filtered
+	}
+
+	fss = make([]*zfs.DatasetPath, 0, len(candidates))
+	for _, c := range candidates {
+		if a.mounted.excludes(c.mounted) {
+			getLogger(ctx).WithField("fs", c.fs.ToString()).WithField("mounted", c.mounted).
+				Debug("dataset excluded from snapshotting by mounted setting")
+			continue
+		}
+		excluded, err := excludedBySnapshotProperty(ctx, a, c.fs, c.propValue)
+		if err != nil {
+			return nil, err
+		}
+		if excluded {
+			getLogger(ctx).WithField("fs", c.fs.ToString()).WithField("property", a.snapshotProperty).
+				Debug("dataset opted out of snapshotting")
+			continue
+		}
+		fss = append(fss, c.fs)
+	}
+	return fss, nil
+}
+
+// warnMissingDatasets logs a warning for each dataset of an explicit dataset list that does not exist.
+// It only runs once when the snapper starts, i.e., after each change of the configuration.
+// In the snapshotting rounds, missing datasets only affect themselves.
+func warnMissingDatasets(ctx context.Context, a args) (missing []string) {
+	for _, ds := range a.datasets {
+		l := getLogger(ctx).WithField("fs", ds.ToString())
+		_, err := zfs.ZFSGet(ctx, ds, []string{"name"})
+		if _, ok := err.(*zfs.DatasetDoesNotExist); ok {
+			l.Warn("dataset in snapshotting datasets list does not exist")
+			missing = append(missing, ds.ToString())
+		} else if err != nil {
+			l.WithError(err).Warn("cannot check that dataset in snapshotting datasets list exists")
+		}
+	}
+	return missing
+}
+
+// propValue is the effective value of a.snapshotProperty, as returned by zfs list or zfs get
+func excludedBySnapshotProperty(ctx context.Context, a args, fs *zfs.DatasetPath, propValue string) (bool, error) {
+	if a.snapshotProperty == "" || propValue != "off" {
+		return false, nil
+	}
+	if a.snapshotPropertyInherit {
+		return true, nil
+	}
+	// only exclude if set on the dataset itself
+	local, err := zfs.ZFSGetRawLocalSource(ctx, fs.ToString(), []string{a.snapshotProperty})
+	if err != nil {
+		return false, errors.Wrapf(err, "cannot get source of property %q of dataset %q", a.snapshotProperty, fs.ToString())
+	}
+	return local.Get(a.snapshotProperty) == "off", nil
+}
+
+// returns nil if in is empty
+// The datasets are only checked against the name filter of fsf, property filters are applied in listFSes.
+func datasetsFromConfig(fsf zfs.DatasetFilter, in []string) ([]*zfs.DatasetPath, error) {
+	fsf = filters.NameFilter(fsf)
+	if len(in) == 0 {
+		return nil, nil
+	}
+	datasets := make([]*zfs.DatasetPath, 0, len(in))
+	seen := make(map[string]bool, len(in))
+	for _, s := range in {
+		ds, err := zfs.NewDatasetPath(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid dataset name %q", s)
+		}
+		if ds.Empty() {
+			return nil, errors.New("dataset name must not be empty")
+		}
+		if err := ds.ValidateSnapshotHeadroom(); err != nil {
+			return nil, errors.Wrapf(err, "invalid dataset name %q", s)
+		}
+		if seen[ds.ToString()] {
+			return nil, errors.Errorf("dataset %q listed more than once", s)
+		}
+		seen[ds.ToString()] = true
+		pass, err := fsf.Filter(ds)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot apply filesystems filter to dataset %q", s)
+		}
+		if !pass {
+			return nil, errors.Errorf("dataset %q is not matched by the job's filesystems filter", s)
+		}
+		datasets = append(datasets, ds)
+	}
+	return datasets, nil
 }
 
 var syncUpWarnNoSnapshotUntilSyncupMinDuration = envconst.Duration("ZREPL_SNAPPER_SYNCUP_WARN_MIN_DURATION", 1*time.Second)
 
+// nextTick returns the time at which a filesystem with the given (possibly adapted) interval
+// should be snapshotted next if it was last snapshotted at last.
+//
+// If a.alignWallclock is set, the result is a wall-clock multiple of a.interval (or of the
+// filesystem's interval override, see nextTickFor), independent of how late in its tick last happened.
+// Thus, late timer wakeups and long snapshotting rounds do not accumulate.
+// The result may be in the past if the snapshot is overdue, in which case the snapper snapshots immediately
+// and is back in sync with the wall-clock ticks after that.
+//
+// The result retains the monotonic clock reading of last (see package time), if any.
+// Thus, once last is known, the wait for the next tick is measured in monotonic time and
+// steps of the wall clock (e.g. by NTP) neither shorten nor extend it.
+// The wall clock is only consulted to compute the alignment at the time of last.
+//
+// If a.cron is set, the result is the first fire time of a.cron after last, the intervals are ignored.
+// It is computed from the wall-clock time of last and carries no monotonic clock reading.
+func (a args) nextTick(last time.Time, interval time.Duration) time.Time {
+	return a.nextTickAligned(last, a.interval, interval)
+}
+
+// nextTickFor is like nextTick, but for the filesystem fs, whose ticks are aligned to a.intervalFor(fs).
+func (a args) nextTickFor(fs *zfs.DatasetPath, last time.Time, interval time.Duration) time.Time {
+	return a.nextTickAligned(last, a.intervalFor(fs), interval)
+}
+
+func (a args) nextTickAligned(last time.Time, alignTo, interval time.Duration) time.Time {
+	if a.cron != nil {
+		return a.cron.next(last)
+	}
+	if a.alignWallclock {
+		// Truncate strips the monotonic clock reading => compute the offset in wall-clock time and add it to last
+		return last.Add(last.Truncate(alignTo).Add(interval).Sub(last))
+	}
+	return last.Add(interval)
+}
+
 // see docs/snapshotting.rst
-func findSyncPoint(ctx context.Context, fss []*zfs.DatasetPath, prefix string, interval time.Duration) (syncPoint time.Time, err error) {
+//
+// nextTickFor returns the optimal snapshot time of a filesystem given the creation time of its latest snapshot,
+// see args.nextTickFor.
+//
+// In addition to the sync point, which is the earliest of all per-filesystem sync points,
+// the per-filesystem sync points are returned, keyed by filesystem name.
+// Filesystems whose sync point could not be determined are not part of fsSyncPoints.
+func findSyncPoint(ctx context.Context, clock Clock, fss []*zfs.DatasetPath, prefix string, format *TimestampFormat, nextTickFor func(fs *zfs.DatasetPath, last time.Time) time.Time) (syncPoint time.Time, fsSyncPoints map[string]time.Time, err error) {
 
 	const (
 		prioHasVersions int = iota
@@ -404,18 +1056,18 @@ func findSyncPoint(ctx context.Context, fss []*zfs.DatasetPath, prefix string, i
 	}
 
 	if len(fss) == 0 {
-		return time.Now(), nil
+		return clock.Now(), nil, nil
 	}
 
 	snaptimes := make([]snapTime, 0, len(fss))
 	hardErrs := 0
 
-	now := time.Now()
+	now := clock.Now()
 
 	getLogger(ctx).Debug("examine filesystem state to find sync point")
 	for _, d := range fss {
 		ctx := logging.WithInjectedField(ctx, "fs", d.ToString())
-		syncPoint, err := findSyncPointFSNextOptimalSnapshotTime(ctx, now, interval, prefix, d)
+		syncPoint, err := findSyncPointFSNextOptimalSnapshotTime(ctx, now, func(last time.Time) time.Time { return nextTickFor(d, last) }, prefix, format, d)
 		if err == findSyncPointFSNoFilesystemVersionsErr {
 			snaptimes = append(snaptimes, snapTime{
 				ds:   d,
@@ -436,7 +1088,7 @@ func findSyncPoint(ctx context.Context, fss []*zfs.DatasetPath, prefix string, i
 	}
 
 	if hardErrs == len(fss) {
-		return time.Time{}, fmt.Errorf("hard errors in determining sync point for every matching filesystem")
+		return time.Time{}, nil, fmt.Errorf("hard errors in determining sync point for every matching filesystem")
 	}
 
 	if len(snaptimes) == 0 {
@@ -463,14 +1115,20 @@ func findSyncPoint(ctx context.Context, fss []*zfs.DatasetPath, prefix string, i
 		}
 	}
 
-	return snaptimes[0].time, nil
+	fsSyncPoints = make(map[string]time.Time, len(snaptimes))
+	for _, st := range snaptimes {
+		fsSyncPoints[st.ds.ToString()] = st.time
+	}
+	return snaptimes[0].time, fsSyncPoints, nil
 
 }
 
 var findSyncPointFSNoFilesystemVersionsErr = fmt.Errorf("no filesystem versions")
 
-func findSyncPointFSNextOptimalSnapshotTime(ctx context.Context, now time.Time, interval time.Duration, prefix string, d *zfs.DatasetPath) (time.Time, error) {
+func findSyncPointFSNextOptimalSnapshotTime(ctx context.Context, now time.Time, nextTick func(last time.Time) time.Time, prefix string, format *TimestampFormat, d *zfs.DatasetPath) (time.Time, error) {
 
+	// Only snapshots determine the snapshotting schedule. Bookmarks are ignored:
+	// a bookmark outlives its snapshot and would make the filesystem look up to date.
 	fsvs, err := zfs.ZFSListFilesystemVersions(ctx, d, zfs.ListFilesystemVersionsOptions{
 		Types:           zfs.Snapshots,
 		ShortnamePrefix: prefix,
@@ -481,6 +1139,7 @@ func findSyncPointFSNextOptimalSnapshotTime(ctx context.Context, now time.Time,
 	if len(fsvs) <= 0 {
 		return time.Time{}, findSyncPointFSNoFilesystemVersionsErr
 	}
+	warnForeignSnapshots(ctx, prefix, format, fsvs)
 
 	// Sort versions by creation
 	sort.SliceStable(fsvs, func(i, j int) bool {
@@ -490,10 +1149,45 @@ func findSyncPointFSNextOptimalSnapshotTime(ctx context.Context, now time.Time,
 	latest := fsvs[len(fsvs)-1]
 	getLogger(ctx).WithField("creation", latest.Creation).Debug("found latest snapshot")
 
-	since := now.Sub(latest.Creation)
-	if since < 0 {
-		return time.Time{}, fmt.Errorf("snapshot %q is from the future: creation=%q now=%q", latest.ToAbsPath(d), latest.Creation, now)
+	return nextOptimalSnapshotTime(ctx, now, latest.Creation, nextTick), nil
+}
+
+// Snapshots that have the job's prefix but do not follow its naming scheme were most likely
+// created by another tool whose prefix collides with the job's. They determine the snapshotting schedule
+// like the job's own snapshots and are subject to the job's pruning rules.
+// zrepl does not tag the snapshots it creates, thus snapshots of other tools that happen to follow the naming scheme go unnoticed.
+func warnForeignSnapshots(ctx context.Context, prefix string, format *TimestampFormat, fsvs []zfs.FilesystemVersion) {
+	const maxListed = 5
+	var foreign []string
+	for _, v := range fsvs {
+		if !format.IsSnapshotName(prefix, v.Name) {
+			foreign = append(foreign, v.Name)
+		}
 	}
+	if len(foreign) == 0 {
+		return
+	}
+	l := getLogger(ctx).WithField("count", len(foreign))
+	if len(foreign) > maxListed {
+		foreign = foreign[:maxListed]
+	}
+	l.WithField("snapshots", strings.Join(foreign, ", ")).
+		Warn("snapshots have the job's prefix but not its timestamp format, the prefix likely collides with snapshots of another tool: " +
+			"they count for the snapshotting schedule and may be destroyed by the job's pruning rules")
+}
 
-	return latest.Creation.Add(interval), nil
+// nextOptimalSnapshotTime returns the next tick after the creation time of the latest snapshot.
+//
+// If the latest snapshot is from the future, the wall clock has likely been stepped backwards since it was taken
+// (e.g. by NTP). Snapshotting immediately would create snapshots whose wall-clock names sort before the latest one,
+// and, after every restart of the daemon, yet another one. Thus the latest snapshot is treated as if it had been taken now.
+func nextOptimalSnapshotTime(ctx context.Context, now, latestCreation time.Time, nextTick func(last time.Time) time.Time) time.Time {
+	if latestCreation.After(now) {
+		getLogger(ctx).
+			WithField("creation", latestCreation).
+			WithField("now", now).
+			Warn("latest snapshot is from the future, assuming the clock has been stepped backwards")
+		return nextTick(now)
+	}
+	return nextTick(latestCreation)
 }
@@ -4,8 +4,12 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
 	"github.com/zrepl/zrepl/config"
-	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/zfs"
 )
 
 // FIXME: properly abstract snapshotting:
@@ -15,7 +19,8 @@ import (
 //     - mixed modes?
 //   - support a `zrepl snapshot JOBNAME` subcommand for config.SnapshottingManual
 type PeriodicOrManual struct {
-	s *Snapper
+	s           *Snapper
+	hookMetrics *hooks.Metrics
 }
 
 func (s *PeriodicOrManual) Run(ctx context.Context, wakeUpCommon chan<- struct{}) {
@@ -24,6 +29,12 @@ func (s *PeriodicOrManual) Run(ctx context.Context, wakeUpCommon chan<- struct{}
 	}
 }
 
+func (s *PeriodicOrManual) RegisterMetrics(registerer prometheus.Registerer) {
+	if s.s != nil {
+		s.hookMetrics.Register(registerer)
+	}
+}
+
 // Returns nil if manual
 func (s *PeriodicOrManual) Report() *Report {
 	if s.s != nil {
@@ -32,14 +43,41 @@ func (s *PeriodicOrManual) Report() *Report {
 	return nil
 }
 
-func FromConfig(g *config.Global, fsf *filters.DatasetMapFilter, in config.SnapshottingEnum) (*PeriodicOrManual, error) {
+// see Snapper.DumpState
+func (s *PeriodicOrManual) DumpState() string {
+	if s.s != nil {
+		return s.s.DumpState()
+	}
+	return "manual snapshotting: no snapper state\n"
+}
+
+var ErrManualSnapshotting = errors.New("job uses manual snapshotting")
+
+// see Snapper.Trigger
+func (s *PeriodicOrManual) Trigger() error {
+	if s.s != nil {
+		return s.s.Trigger()
+	}
+	return ErrManualSnapshotting
+}
+
+// jobName is used as a label for the hook metrics
+func FromConfig(g *config.Global, fsf zfs.DatasetFilter, in config.SnapshottingEnum, jobName string) (*PeriodicOrManual, error) {
 	switch v := in.Ret.(type) {
 	case *config.SnapshottingPeriodic:
-		snapper, err := PeriodicFromConfig(g, fsf, v)
+		hookMetrics := hooks.NewMetrics(jobName)
+		snapper, err := PeriodicFromConfig(g, fsf, v, hookMetrics)
+		if err != nil {
+			return nil, err
+		}
+		return &PeriodicOrManual{snapper, hookMetrics}, nil
+	case *config.SnapshottingCron:
+		hookMetrics := hooks.NewMetrics(jobName)
+		snapper, err := CronFromConfig(g, fsf, v, hookMetrics)
 		if err != nil {
 			return nil, err
 		}
-		return &PeriodicOrManual{snapper}, nil
+		return &PeriodicOrManual{snapper, hookMetrics}, nil
 	case *config.SnapshottingManual:
 		return &PeriodicOrManual{}, nil
 	default:
@@ -0,0 +1,112 @@
+package snapper
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DumpState returns an exhaustive, human-readable dump of the snapper's
+// resolved config and internal state, meant for support bundles.
+// In contrast to Report, it is not meant to be parsed.
+//
+// The hook reports are not part of the dump because they contain the hook's
+// environment and output, which might contain secrets.
+func (s *Snapper) DumpState() string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var b strings.Builder
+	p := func(indent int, format string, args ...interface{}) {
+		fmt.Fprintf(&b, "%s%s\n", strings.Repeat("  ", indent), fmt.Sprintf(format, args...))
+	}
+	ts := func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Format(time.RFC3339Nano)
+	}
+
+	a := s.args
+	p(0, "config:")
+	p(1, "prefix: %q", a.prefix)
+	p(1, "timestamp_format: %q timestamp_location: %s", a.timestampFormat.layout, a.timestampFormat.location)
+	if a.cron != nil {
+		p(1, "cron: %q cron_location: %s", a.cron, a.cron.location)
+	} else {
+		p(1, "interval: %s", a.interval)
+	}
+	for i, o := range a.intervalOverrides {
+		p(2, "override #%d: interval=%s filesystems=%v", i+1, o.interval, o.filesystems)
+	}
+	p(1, "align_to_wallclock: %v", a.alignWallclock)
+	p(1, "jitter: %s", a.jitter)
+	if a.adaptive != nil {
+		p(1, "adaptive_interval: growth_factor=%v max_interval=%s", a.adaptive.growthFactor, a.adaptive.maxInterval)
+	} else {
+		p(1, "adaptive_interval: disabled")
+	}
+	if a.datasets != nil {
+		p(1, "datasets:")
+		for _, d := range a.datasets {
+			p(2, "%s", d.ToString())
+		}
+	} else {
+		p(1, "datasets: matched by filesystems filter")
+	}
+	p(1, "verify: %v", a.verify)
+	p(1, "recursive: %v", a.recursive)
+	p(1, "snapshot_property: %q (inherit=%v)", a.snapshotProperty, a.snapshotPropertyInherit)
+	p(1, "dry_run: %v", a.dryRun)
+	p(1, "hooks:")
+	if a.hooks != nil {
+		for i, h := range *a.hooks {
+			p(2, "#%d %T %s (err_is_fatal=%v timeout_is_fatal=%v)", i+1, h, h.String(), h.ErrIsFatal(), h.TimeoutIsFatal())
+		}
+	}
+
+	p(0, "state: %s", s.state)
+	p(0, "last_invocation: %s", ts(s.lastInvocation))
+	p(0, "sleep_until: %s", ts(s.sleepUntil))
+	p(0, "error: %s", errOrEmptyString(s.err))
+
+	p(0, "plan:")
+	fss := make([]string, 0, len(s.plan))
+	progress := make(map[string]*snapProgress, len(s.plan))
+	for fs, sp := range s.plan {
+		fss = append(fss, fs.ToString())
+		progress[fs.ToString()] = sp
+	}
+	sort.Strings(fss)
+	for _, fs := range fss {
+		sp := progress[fs]
+		p(1, "%s: %s", fs, sp.state)
+		p(2, "snapshot: %q", sp.name)
+		p(2, "start_at: %s", ts(sp.startAt))
+		p(2, "done_at: %s", ts(sp.doneAt))
+		if a.verify {
+			p(2, "guid: %d", sp.guid)
+		}
+		if sp.hookPlan != nil {
+			for _, step := range sp.hookPlan.Report() {
+				p(2, "hook %s %s: %s (%s - %s)", step.Edge, step.Hook.String(), step.Status, ts(step.Begin), ts(step.End))
+			}
+		}
+	}
+
+	if a.perFSSchedule() {
+		p(0, "schedule:")
+		fss := make([]string, 0, len(s.schedule))
+		for fs := range s.schedule {
+			fss = append(fss, fs)
+		}
+		sort.Strings(fss)
+		for _, fs := range fss {
+			st := s.schedule[fs]
+			p(1, "%s: interval=%s next_due=%s", fs, st.interval, ts(st.nextDue))
+		}
+	}
+
+	return b.String()
+}
@@ -29,8 +29,12 @@ type ReportFilesystem struct {
 	Hooks         string
 	HooksHadError bool
 
-	// Valid in SnapDone | SnapError
+	// Valid in SnapDone | SnapError | SnapSkipped
 	DoneAt time.Time
+	// Valid in SnapError
+	Error string
+	// Valid in SnapDone if snapshot verification is enabled
+	Guid uint64
 }
 
 func errOrEmptyString(e error) string {
@@ -40,6 +44,22 @@ func errOrEmptyString(e error) string {
 	return ""
 }
 
+// planReportError returns the error of the first failed step of r, or nil if no step failed.
+func planReportError(r hooks.PlanReport) error {
+	for _, step := range r {
+		if step.Status != hooks.StepErr {
+			continue
+		}
+		if step.Report == nil {
+			return fmt.Errorf("%s", step)
+		}
+		return fmt.Errorf("%s: %s", step.Hook, step.Report.Error())
+	}
+	return nil
+}
+
+// Report returns a copy of the snapper's state that can be serialized to JSON.
+// It is safe to call concurrently with Run.
 func (s *Snapper) Report() *Report {
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
@@ -98,6 +118,8 @@ func (s *Snapper) Report() *Report {
 			SnapName:      p.name,
 			StartAt:       p.startAt,
 			DoneAt:        p.doneAt,
+			Error:         errOrEmptyString(p.err),
+			Guid:          p.guid,
 			Hooks:         hooksStr,
 			HooksHadError: hooksHadError,
 		})
@@ -0,0 +1,1068 @@
+package snapper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/daemon/filters"
+	"github.com/zrepl/zrepl/daemon/hooks"
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+type fakeClock struct {
+	mtx    sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+var _ Clock = (*fakeClock)(nil)
+
+func (c *fakeClock) Now() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) NewTimer(d time.Duration) Timer {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	t := &fakeTimer{deadline: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+func (c *fakeClock) numTimers() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return len(c.timers)
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.fire(c.now) {
+			continue
+		}
+		pending = append(pending, t)
+	}
+	c.timers = pending
+}
+
+type fakeTimer struct {
+	mtx      sync.Mutex
+	deadline time.Time
+	stopped  bool
+	c        chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.c }
+
+func (t *fakeTimer) Stop() bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	wasActive := !t.stopped
+	t.stopped = true
+	return wasActive
+}
+
+// returns true if the timer is no longer pending
+func (t *fakeTimer) fire(now time.Time) bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.stopped {
+		return true
+	}
+	if now.Before(t.deadline) {
+		return false
+	}
+	t.stopped = true
+	t.c <- now
+	return true
+}
+
+func TestWaitTransitionsToPlanningAfterInterval(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s := &Snapper{
+		state:          Waiting,
+		lastInvocation: clock.Now(),
+		args: args{
+			ctx:      ctx,
+			interval: 10 * time.Minute,
+			clock:    clock,
+		},
+	}
+	u := func(u func(*Snapper)) State {
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+		if u != nil {
+			u(s)
+		}
+		return s.state
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		wait(s.args, u)
+	}()
+
+	for clock.numTimers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	var sleepUntil time.Time
+	u(func(s *Snapper) { sleepUntil = s.sleepUntil })
+	assert.Equal(t, clock.Now().Add(10*time.Minute), sleepUntil)
+
+	clock.Advance(9 * time.Minute)
+	select {
+	case <-done:
+		t.Fatal("wait must not return before the interval has elapsed")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	clock.Advance(1 * time.Minute)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait did not return after the interval elapsed")
+	}
+	require.Equal(t, Planning, u(nil))
+}
+
+func TestDatasetsFromConfig(t *testing.T) {
+	fsf, err := filters.DatasetMapFilterFromConfig(map[string]bool{
+		"pool/a<":    true,
+		"pool/a/tmp": false,
+	})
+	require.NoError(t, err)
+
+	ds, err := datasetsFromConfig(fsf, nil)
+	require.NoError(t, err)
+	assert.Nil(t, ds, "empty list means: use the filter")
+
+	ds, err = datasetsFromConfig(fsf, []string{"pool/a", "pool/a/b"})
+	require.NoError(t, err)
+	require.Len(t, ds, 2)
+	assert.Equal(t, "pool/a/b", ds[1].ToString())
+
+	for _, invalid := range [][]string{
+		{"pool/a/tmp"},       // not matched by filter
+		{"pool/other"},       // not matched by filter
+		{""},                 // empty
+		{"pool/a@snap"},      // not a dataset
+		{"pool/a", "pool/a"}, // duplicate
+	} {
+		_, err = datasetsFromConfig(fsf, invalid)
+		assert.Error(t, err, "%v", invalid)
+	}
+}
+
+func TestListFSesExplicitDatasetsMissing(t *testing.T) {
+	dir, cleanup := withFakeZFS(t, `
+[ "$1" = get ] || exit 1
+echo "$*" >> "$FAKEZFS_DIR/log"
+if [ "$6" = pool/a/missing ]; then
+	echo "cannot open 'pool/a/missing': dataset does not exist" >&2
+	exit 1
+fi
+IFS=,
+for p in $5; do
+	v=on; [ "$p" = mounted ] && v=yes
+	printf '%s\t%s\t%s\n' "$p" "$v" local
+done
+`)
+	defer cleanup()
+	calls := func() int {
+		log, err := ioutil.ReadFile(filepath.Join(dir, "log"))
+		if os.IsNotExist(err) {
+			return 0
+		}
+		require.NoError(t, err)
+		return strings.Count(string(log), "\n")
+	}
+
+	fsf, err := filters.DatasetMapFilterFromConfig(map[string]bool{"pool/a<": true})
+	require.NoError(t, err)
+	datasets, err := datasetsFromConfig(fsf, []string{"pool/a", "pool/a/missing", "pool/a/b"})
+	require.NoError(t, err)
+	a := args{fsf: fsf, datasets: datasets, snapshotProperty: "zrepl:snapshot", snapshotPropertyInherit: true}
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+
+	assert.Equal(t, []string{"pool/a/missing"}, warnMissingDatasets(ctx, a))
+
+	fss, err := listFSes(ctx, a)
+	require.NoError(t, err, "a missing dataset must not fail the round")
+	var names []string
+	for _, fs := range fss {
+		names = append(names, fs.ToString())
+	}
+	assert.Equal(t, []string{"pool/a", "pool/a/b"}, names)
+
+	// without properties to check, rounds do not check existence at all
+	a.snapshotProperty = ""
+	before := calls()
+	fss, err = listFSes(ctx, a)
+	require.NoError(t, err)
+	assert.Len(t, fss, 3)
+	assert.Equal(t, before, calls())
+}
+
+func TestListFSesSnapshotProperty(t *testing.T) {
+	// pool/a/b inherits off from pool/a, the property is unset on pool/d
+	dir, cleanup := withFakeZFS(t, `
+echo "$*" >> "$FAKEZFS_DIR/log"
+case "$1" in
+list)
+	if [ "$5" = name ]; then
+		printf 'pool/a\npool/a/b\npool/c\npool/d\n'
+	else
+		printf 'pool/a\toff\npool/a/b\toff\npool/c\ton\npool/d\t-\n'
+	fi ;;
+get)
+	case "$6" in
+	pool/a) printf '%s\toff\tlocal\n' "$5" ;;
+	pool/a/b) printf '%s\toff\tinherited from pool/a\n' "$5" ;;
+	*) exit 1 ;;
+	esac ;;
+*) exit 1 ;;
+esac
+`)
+	defer cleanup()
+	log := filepath.Join(dir, "log")
+
+	fsf, err := filters.DatasetMapFilterFromConfig(map[string]bool{"<": true})
+	require.NoError(t, err)
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+
+	tcs := []struct {
+		name     string
+		property string
+		inherit  bool
+		expect   []string
+		gets     int // zfs get invocations to determine the property's source
+	}{
+		{"local-off-only", "zrepl:snapshot", false, []string{"pool/a/b", "pool/c", "pool/d"}, 2},
+		{"inherited-off", "zrepl:snapshot", true, []string{"pool/c", "pool/d"}, 0},
+		{"no-property", "", false, []string{"pool/a", "pool/a/b", "pool/c", "pool/d"}, 0},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			os.Remove(log)
+			a := args{fsf: fsf, snapshotProperty: tc.property, snapshotPropertyInherit: tc.inherit}
+			fss, err := listFSes(ctx, a)
+			require.NoError(t, err)
+			var names []string
+			for _, fs := range fss {
+				names = append(names, fs.ToString())
+			}
+			assert.Equal(t, tc.expect, names)
+
+			invocations, err := ioutil.ReadFile(log)
+			require.NoError(t, err)
+			assert.Equal(t, tc.gets, strings.Count(string(invocations), "get "), "%s", invocations)
+		})
+	}
+}
+
+func TestAdaptiveInterval(t *testing.T) {
+	base := 10 * time.Minute
+	ai := &adaptiveInterval{growthFactor: 2, maxInterval: 35 * time.Minute}
+
+	assert.Equal(t, 20*time.Minute, ai.next(base, base, 0))
+	assert.Equal(t, 35*time.Minute, ai.next(base, 20*time.Minute, 0), "capped")
+	assert.Equal(t, base, ai.next(base, 35*time.Minute, 4096), "reset on change")
+
+	fs := func(s string) *zfs.DatasetPath {
+		p, err := zfs.NewDatasetPath(s)
+		require.NoError(t, err)
+		return p
+	}
+	cold, hot := fs("pool/cold"), fs("pool/hot")
+	s := &Snapper{
+		args:     args{interval: base, adaptive: ai},
+		schedule: make(map[string]*fsSchedule),
+	}
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	round := func(writtenCold int64) (due []string) {
+		s.lastInvocation = now
+		for _, d := range s.scheduleDue(now, []*zfs.DatasetPath{cold, hot}) {
+			due = append(due, d.ToString())
+			written := int64(1)
+			if d.Equal(cold) {
+				written = writtenCold
+			}
+			s.updateAdaptiveInterval(d, written, nil)
+			s.scheduleNext(d)
+		}
+		now = now.Add(base)
+		return due
+	}
+
+	assert.Equal(t, []string{"pool/cold", "pool/hot"}, round(0)) // cold: 20m
+	assert.Equal(t, []string{"pool/hot"}, round(0))
+	assert.Equal(t, []string{"pool/cold", "pool/hot"}, round(0)) // cold: 35m
+	assert.Equal(t, []string{"pool/hot"}, round(0))
+	assert.Equal(t, []string{"pool/hot"}, round(0))
+	assert.Equal(t, []string{"pool/hot"}, round(0))
+	assert.Equal(t, []string{"pool/cold", "pool/hot"}, round(1)) // cold changed: back to 10m
+	assert.Equal(t, []string{"pool/cold", "pool/hot"}, round(0))
+
+	assert.Equal(t, map[string]time.Duration{"pool/cold": 20 * time.Minute, "pool/hot": base}, s.scheduledIntervals())
+}
+
+func TestNextTickAlignWallclock(t *testing.T) {
+	at := func(h, m, s int) time.Time { return time.Date(2020, 1, 1, h, m, s, 0, time.UTC) }
+
+	unaligned := args{interval: time.Hour}
+	assert.Equal(t, at(11, 7, 0), unaligned.nextTick(at(10, 7, 0), time.Hour))
+
+	a := args{interval: time.Hour, alignWallclock: true}
+	assert.Equal(t, at(11, 0, 0), a.nextTick(at(10, 7, 0), time.Hour))
+	assert.Equal(t, at(11, 0, 0), a.nextTick(at(10, 0, 0), time.Hour))
+	assert.Equal(t, at(11, 0, 0), a.nextTick(at(10, 0, 3), time.Hour), "late wakeups must not accumulate")
+	assert.Equal(t, at(12, 0, 0), a.nextTick(at(10, 7, 0), 2*time.Hour), "adapted intervals are aligned to the base interval")
+
+	a = args{interval: 15 * time.Minute, alignWallclock: true}
+	assert.Equal(t, at(10, 15, 0), a.nextTick(at(10, 7, 0), 15*time.Minute))
+	// overdue: the next tick is in the past, the snapper snapshots immediately
+	assert.True(t, a.nextTick(at(8, 0, 0), 15*time.Minute).Before(at(10, 0, 0)))
+}
+
+func TestDumpState(t *testing.T) {
+	fs, err := zfs.NewDatasetPath("pool/a")
+	require.NoError(t, err)
+	now := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	s := &Snapper{
+		state:          Snapshotting,
+		lastInvocation: now,
+		args:           args{prefix: "zrepl_", interval: time.Hour, adaptive: &adaptiveInterval{growthFactor: 2, maxInterval: 4 * time.Hour}, timestampFormat: &TimestampFormat{defaultTimestampLayout, time.UTC}},
+		plan: map[*zfs.DatasetPath]*snapProgress{
+			fs: {state: SnapStarted, name: "zrepl_20200101_100000_000", startAt: now},
+		},
+		schedule: map[string]*fsSchedule{"pool/a": {interval: 2 * time.Hour, nextDue: now.Add(2 * time.Hour)}},
+	}
+	dump := s.DumpState()
+	for _, expect := range []string{
+		`prefix: "zrepl_"`,
+		`timestamp_format: "20060102_150405_000" timestamp_location: UTC`,
+		"interval: 1h0m0s",
+		"state: Snapshotting",
+		"last_invocation: 2020-01-01T10:00:00Z",
+		"sleep_until: -",
+		"pool/a: SnapStarted",
+		`snapshot: "zrepl_20200101_100000_000"`,
+		"pool/a: interval=2h0m0s next_due=2020-01-01T12:00:00Z",
+	} {
+		assert.Contains(t, dump, expect)
+	}
+}
+
+func TestReport(t *testing.T) {
+	a, err := zfs.NewDatasetPath("pool/a")
+	require.NoError(t, err)
+	b, err := zfs.NewDatasetPath("pool/b")
+	require.NoError(t, err)
+	c, err := zfs.NewDatasetPath("pool/c")
+	require.NoError(t, err)
+	now := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	s := &Snapper{
+		state:      Snapshotting,
+		sleepUntil: now.Add(time.Hour),
+		plan: map[*zfs.DatasetPath]*snapProgress{
+			b: {state: SnapError, name: "zrepl_b", startAt: now, doneAt: now.Add(time.Second), err: errors.New("cannot create snapshot")},
+			a: {state: SnapDone, name: "zrepl_a", startAt: now, doneAt: now.Add(time.Second)},
+			c: {state: SnapSkipped, doneAt: now},
+		},
+	}
+
+	// concurrent callers must not race with updates by Run
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Report()
+		}()
+	}
+	s.mtx.Lock()
+	s.plan[a].guid = 23
+	s.mtx.Unlock()
+	wg.Wait()
+
+	r := s.Report()
+	assert.Equal(t, Snapshotting, r.State)
+	assert.Equal(t, now.Add(time.Hour), r.SleepUntil)
+	require.Len(t, r.Progress, 3)
+	assert.Equal(t, "pool/a", r.Progress[0].Path)
+	assert.Equal(t, "", r.Progress[0].Error)
+	assert.Equal(t, "pool/b", r.Progress[1].Path)
+	assert.Equal(t, SnapError, r.Progress[1].State)
+	assert.Equal(t, "cannot create snapshot", r.Progress[1].Error)
+	assert.Equal(t, SnapSkipped, r.Progress[2].State)
+	assert.Equal(t, "SnapSkipped", r.Progress[2].State.String())
+
+	j, err := json.Marshal(r)
+	require.NoError(t, err)
+	var decoded Report
+	require.NoError(t, json.Unmarshal(j, &decoded))
+	assert.Equal(t, r, &decoded)
+}
+
+func TestNextTickRetainsMonotonicClockReading(t *testing.T) {
+	// time.Time.String includes the monotonic clock reading as "m=±<value>"
+	now := time.Now()
+	require.Contains(t, now.String(), "m=")
+
+	for _, align := range []bool{false, true} {
+		a := args{interval: time.Hour, alignWallclock: align}
+		next := a.nextTick(now, time.Hour)
+		assert.Contains(t, next.String(), "m=", "align=%v", align)
+		assert.Equal(t, next.Round(0), a.nextTick(now.Round(0), time.Hour), "align=%v", align)
+	}
+}
+
+func TestNextOptimalSnapshotTimeBackwardsClockStep(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	a := args{interval: time.Hour}
+	nextTick := func(last time.Time) time.Time { return a.nextTick(last, a.interval) }
+
+	assert.Equal(t, now.Add(30*time.Minute), nextOptimalSnapshotTime(ctx, now, now.Add(-30*time.Minute), nextTick))
+	// the latest snapshot is from the future => wait a full interval instead of snapshotting immediately or failing
+	assert.Equal(t, now.Add(time.Hour), nextOptimalSnapshotTime(ctx, now, now.Add(2*time.Hour), nextTick))
+}
+
+func TestCycleContext(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)}
+	a := args{ctx: context.Background(), clock: clock}
+
+	ctx, cancel := a.cycleContext(clock.Now().Add(-time.Hour))
+	defer cancel()
+	_, hasDeadline := ctx.Deadline()
+	assert.False(t, hasDeadline, "0 means unlimited")
+	assert.NoError(t, ctx.Err())
+
+	a.maxCycleDuration = time.Hour
+	ctx, cancel = a.cycleContext(clock.Now())
+	defer cancel()
+	deadline, hasDeadline := ctx.Deadline()
+	require.True(t, hasDeadline)
+	assert.True(t, deadline.After(time.Now().Add(59*time.Minute)))
+	assert.NoError(t, ctx.Err())
+
+	ctx, cancel = a.cycleContext(clock.Now().Add(-2 * time.Hour))
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, ctx.Err(), "round started more than max_cycle_duration ago")
+}
+
+func TestIntervalOverrides(t *testing.T) {
+	overrides, err := intervalOverridesFromConfig([]config.SnapshottingIntervalOverride{
+		{Filesystems: config.FilesystemsFilter{"pool/hot<": true}, Interval: 5 * time.Minute},
+		{Filesystems: config.FilesystemsFilter{"pool<": true, "pool/hot/archive": true}, Interval: 24 * time.Hour},
+	})
+	require.NoError(t, err)
+
+	_, err = intervalOverridesFromConfig([]config.SnapshottingIntervalOverride{{Filesystems: config.FilesystemsFilter{"pool<": true}}})
+	assert.Error(t, err, "interval must be positive")
+	_, err = intervalOverridesFromConfig([]config.SnapshottingIntervalOverride{{Interval: time.Minute}})
+	assert.Error(t, err, "filesystems must not be empty")
+
+	fs := func(s string) *zfs.DatasetPath {
+		p, err := zfs.NewDatasetPath(s)
+		require.NoError(t, err)
+		return p
+	}
+	hot, hotArchive, cold, other := fs("pool/hot"), fs("pool/hot/archive"), fs("pool/cold"), fs("other/fs")
+
+	a := args{interval: time.Hour, intervalOverrides: overrides, alignWallclock: true}
+	assert.True(t, a.perFSSchedule())
+	assert.False(t, args{interval: time.Hour}.perFSSchedule())
+	assert.Equal(t, 5*time.Minute, a.intervalFor(hot))
+	assert.Equal(t, 5*time.Minute, a.intervalFor(hotArchive), "first match wins")
+	assert.Equal(t, 24*time.Hour, a.intervalFor(cold))
+	assert.Equal(t, time.Hour, a.intervalFor(other), "default interval")
+
+	at := func(h, m int) time.Time { return time.Date(2020, 1, 1, h, m, 0, 0, time.UTC) }
+	assert.Equal(t, at(10, 10), a.nextTickFor(hot, at(10, 7), 5*time.Minute), "aligned to the override's interval")
+	assert.Equal(t, at(11, 0), a.nextTickFor(other, at(10, 7), time.Hour))
+
+	s := &Snapper{args: a, schedule: make(map[string]*fsSchedule)}
+	fss := []*zfs.DatasetPath{hot, cold, other}
+	// sync-up: hot has no snapshots yet, cold was snapshotted recently, other is overdue
+	s.scheduleSyncPoints(fss, map[string]time.Time{"pool/cold": at(23, 0), "other/fs": at(9, 0)})
+	due := func(now time.Time) (names []string) {
+		s.lastInvocation = now
+		for _, d := range s.scheduleDue(now, fss) {
+			names = append(names, d.ToString())
+			s.scheduleNext(d)
+		}
+		return names
+	}
+	assert.Equal(t, []string{"pool/hot", "other/fs"}, due(at(10, 0)))
+	assert.Equal(t, at(10, 5), s.earliestDueAfter(at(10, 0)), "wait only for the filesystem with the shortest interval")
+	assert.Equal(t, []string{"pool/hot"}, due(at(10, 5)))
+	assert.Equal(t, at(10, 10), s.earliestDueAfter(at(10, 5)))
+	assert.Equal(t, []string{"pool/hot", "other/fs"}, due(at(11, 0)))
+	assert.Equal(t, []string{"pool/hot", "pool/cold", "other/fs"}, due(at(23, 0)))
+
+	// filesystems that are due but were not scheduled (failed round) do not cause a busy loop
+	s.schedule["pool/hot"].nextDue = at(23, 5)
+	s.schedule["other/fs"].nextDue = at(23, 0)
+	assert.Equal(t, at(23, 5), s.earliestDueAfter(at(23, 0)))
+}
+
+func TestTimestampFormatFromConfig(t *testing.T) {
+	now := time.Date(2020, 3, 4, 5, 6, 7, 890000000, time.UTC)
+	name := func(layout, location string) (string, error) {
+		f, err := TimestampFormatFromConfig(config.SnapshotNaming{Prefix: "zrepl_", TimestampFormat: layout, TimestampLocation: location})
+		if err != nil {
+			return "", err
+		}
+		return f.SnapshotName("zrepl_", now), nil
+	}
+
+	n, err := name("", "")
+	require.NoError(t, err)
+	assert.Equal(t, "zrepl_20200304_050607_000", n, "defaults")
+
+	n, err = name("2006-01-02T15:04:05.000", "Europe/Berlin")
+	require.NoError(t, err)
+	assert.Equal(t, "zrepl_2020-03-04T06:06:07.890", n)
+
+	for _, invalid := range []struct{ layout, location string }{
+		{"2006/01/02", "UTC"},            // '/' is not allowed in snapshot names
+		{"2006@01", "UTC"},               // forbidden by NewDatasetPath
+		{"2006-01-02 15:04 MST|", "UTC"}, // forbidden by NewDatasetPath
+		{"daily", "UTC"},                 // not time-dependent
+		{"", "Mars/Olympus_Mons"},        // unknown location
+	} {
+		_, err := name(invalid.layout, invalid.location)
+		assert.Error(t, err, "%#v", invalid)
+	}
+}
+
+func TestTimestampFormatIsSnapshotName(t *testing.T) {
+	f, err := TimestampFormatFromConfig(config.SnapshotNaming{Prefix: "backup-"})
+	require.NoError(t, err)
+	assert.True(t, f.IsSnapshotName("backup-", f.SnapshotName("backup-", time.Now())))
+	assert.True(t, f.IsSnapshotName("backup-", "backup-20200304_050607_000"))
+	assert.False(t, f.IsSnapshotName("backup-", "backup-2020-03-04"), "other tool's naming scheme")
+	assert.False(t, f.IsSnapshotName("backup-", "backup-weekly"))
+	assert.False(t, f.IsSnapshotName("zrepl_", "backup-20200304_050607_000"), "other prefix")
+
+	f, err = TimestampFormatFromConfig(config.SnapshotNaming{Prefix: "zrepl_", TimestampFormat: "2006-01-02T15:04", TimestampLocation: "Europe/Berlin"})
+	require.NoError(t, err)
+	assert.True(t, f.IsSnapshotName("zrepl_", f.SnapshotName("zrepl_", time.Now())))
+	assert.False(t, f.IsSnapshotName("zrepl_", "zrepl_20200304_050607_000"))
+}
+
+func TestNotifySnapshotsTaken(t *testing.T) {
+	ctx := context.Background()
+
+	assert.False(t, notifySnapshotsTaken(ctx, nil), "nil channel is a no-op")
+
+	ch := make(chan struct{}, 1)
+	assert.True(t, notifySnapshotsTaken(ctx, ch), "empty channel")
+	assert.Len(t, ch, 1)
+
+	assert.False(t, notifySnapshotsTaken(ctx, ch), "full channel: coalesced with the pending event")
+	assert.Len(t, ch, 1)
+
+	<-ch
+	assert.True(t, notifySnapshotsTaken(ctx, ch), "drained channel")
+}
+
+func TestJitter(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	plan := make(map[*zfs.DatasetPath]*snapProgress)
+	for _, p := range []string{"pool/a", "pool/b", "pool/c"} {
+		fs, err := zfs.NewDatasetPath(p)
+		require.NoError(t, err)
+		plan[fs] = &snapProgress{state: SnapPending}
+	}
+
+	// without jitter, all filesystems are snapshotted immediately
+	a := args{clock: clock}
+	for _, jfs := range a.jitteredOrder(plan) {
+		assert.Zero(t, jfs.offset)
+	}
+
+	offsets := []int64{int64(7 * time.Second), int64(2 * time.Second), int64(5 * time.Second)}
+	a.jitter = 10 * time.Second
+	a.jitterRand = func(n int64) int64 {
+		assert.Equal(t, int64(a.jitter), n)
+		o := offsets[0]
+		offsets = offsets[1:]
+		return o
+	}
+	order := a.jitteredOrder(plan)
+	require.Len(t, order, 3)
+	for i, expect := range []time.Duration{2 * time.Second, 5 * time.Second, 7 * time.Second} {
+		assert.Equal(t, expect, order[i].offset)
+	}
+
+	roundStart := clock.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		a.waitJitter(ctx, roundStart, order[0].offset)
+		close(done)
+	}()
+	for clock.numTimers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(2 * time.Second)
+	<-done
+
+	// offsets in the past do not wait
+	clock.Advance(4 * time.Second)
+	a.waitJitter(ctx, roundStart, order[1].offset)
+
+	// cancellation interrupts the wait
+	done = make(chan struct{})
+	go func() {
+		a.waitJitter(ctx, roundStart, order[2].offset)
+		close(done)
+	}()
+	cancel()
+	<-done
+}
+
+func TestMatchingHookSets(t *testing.T) {
+	hook := func(filesystems config.FilesystemsFilter) config.HookEnum {
+		return config.HookEnum{Ret: &config.HookCommand{
+			Path:               "/bin/true",
+			Filesystems:        filesystems,
+			Output:             "lines",
+			HookSettingsCommon: config.HookSettingsCommon{Type: "command", OnTimeout: "like_error"},
+		}}
+	}
+	hookList, err := hooks.ListFromConfig(&config.HookList{
+		hook(config.FilesystemsFilter{"pool<": true}),
+		hook(config.FilesystemsFilter{"pool/a/c<": true}),
+	})
+	require.NoError(t, err)
+
+	var fss []*zfs.DatasetPath
+	for _, p := range []string{"pool/a", "pool/a/b", "pool/a/c", "pool/a/c/d"} {
+		fs, err := zfs.NewDatasetPath(p)
+		require.NoError(t, err)
+		fss = append(fss, fs)
+	}
+	sets, err := matchingHookSets(*hookList, fss)
+	require.NoError(t, err)
+	assert.Equal(t, sets["pool/a"], sets["pool/a/b"])
+	assert.Equal(t, sets["pool/a/c"], sets["pool/a/c/d"])
+	assert.NotEqual(t, sets["pool/a"], sets["pool/a/c"])
+}
+
+func TestPeriodicFromConfigRecursive(t *testing.T) {
+	in := &config.SnapshottingPeriodic{
+		Prefix:            "zrepl_",
+		Interval:          10 * time.Minute,
+		TimestampFormat:   "20060102_150405_000",
+		TimestampLocation: "UTC",
+		Recursive:         true,
+	}
+	s, err := PeriodicFromConfig(nil, zfs.NoFilter(), in, nil)
+	require.NoError(t, err)
+	assert.True(t, s.args.recursive)
+
+	in.SkipUnchanged = true
+	_, err = PeriodicFromConfig(nil, zfs.NoFilter(), in, nil)
+	assert.Error(t, err)
+}
+
+func TestMountedFilter(t *testing.T) {
+	f, err := mountedFilterFromConfig("any")
+	require.NoError(t, err)
+	assert.Equal(t, mountedAny, f)
+	_, err = mountedFilterFromConfig("yes")
+	assert.Error(t, err)
+
+	tcs := []struct {
+		filter          mountedFilter
+		yes, no, volume bool // expected: included
+	}{
+		{mountedAny, true, true, true},
+		{mountedOnly, true, false, true},
+		{unmountedOnly, false, true, true},
+	}
+	for _, tc := range tcs {
+		assert.Equal(t, tc.yes, !tc.filter.excludes("yes"), "filter %v", tc.filter)
+		assert.Equal(t, tc.no, !tc.filter.excludes("no"), "filter %v", tc.filter)
+		assert.Equal(t, tc.volume, !tc.filter.excludes("-"), "filter %v: volumes have no mounted property", tc.filter)
+	}
+
+	in := &config.SnapshottingPeriodic{
+		Prefix:            "zrepl_",
+		Interval:          10 * time.Minute,
+		TimestampFormat:   "20060102_150405_000",
+		TimestampLocation: "UTC",
+		Mounted:           "unmounted",
+	}
+	s, err := PeriodicFromConfig(nil, zfs.NoFilter(), in, nil)
+	require.NoError(t, err)
+	assert.Equal(t, unmountedOnly, s.args.mounted)
+	in.Mounted = "invalid"
+	_, err = PeriodicFromConfig(nil, zfs.NoFilter(), in, nil)
+	assert.Error(t, err)
+}
+
+func TestSnapshotLanes(t *testing.T) {
+	var order []jitteredFS
+	for _, fs := range []string{"a/1", "b/1", "a/2", "c", "b/2"} {
+		p, err := zfs.NewDatasetPath(fs)
+		require.NoError(t, err)
+		order = append(order, jitteredFS{fs: p})
+	}
+	names := func(lanes [][]jitteredFS) (res [][]string) {
+		for _, l := range lanes {
+			var n []string
+			for _, jfs := range l {
+				n = append(n, jfs.fs.ToString())
+			}
+			res = append(res, n)
+		}
+		return res
+	}
+
+	assert.Nil(t, args{concurrency: 4}.snapshotLanes(nil))
+	assert.Equal(t, [][]string{{"a/1", "b/1", "a/2", "c", "b/2"}}, names(args{}.snapshotLanes(order)))
+	assert.Equal(t, [][]string{{"a/1", "b/1", "a/2", "c", "b/2"}}, names(args{concurrency: 1, serializePerPool: true}.snapshotLanes(order)))
+	assert.Equal(t, [][]string{{"a/1"}, {"b/1"}, {"a/2"}, {"c"}, {"b/2"}}, names(args{concurrency: 2}.snapshotLanes(order)))
+	// lanes preserve the (jittered) order within a pool
+	assert.Equal(t, [][]string{{"a/1", "a/2"}, {"b/1", "b/2"}, {"c"}}, names(args{concurrency: 2, serializePerPool: true}.snapshotLanes(order)))
+
+	in := &config.SnapshottingPeriodic{
+		Prefix:            "zrepl_",
+		Interval:          10 * time.Minute,
+		TimestampFormat:   "20060102_150405_000",
+		TimestampLocation: "UTC",
+	}
+	s, err := PeriodicFromConfig(nil, zfs.NoFilter(), in, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, s.args.concurrency)
+	assert.Equal(t, 1, cap(s.args.snapshotSemaphore()))
+	in.Concurrency, in.SerializePerPool = 3, true
+	s, err = PeriodicFromConfig(nil, zfs.NoFilter(), in, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, s.args.concurrency)
+	assert.True(t, s.args.serializePerPool)
+	in.Concurrency = -1
+	_, err = PeriodicFromConfig(nil, zfs.NoFilter(), in, nil)
+	assert.Error(t, err)
+}
+
+func TestSnapshotConcurrency(t *testing.T) {
+	dir, cleanup := withFakeZFS(t, `
+case "$1" in
+get) printf 'written\t4096\t-\n' ;;
+snapshot)
+	fs="${2%@*}"
+	echo "start $fs" >> "$FAKEZFS_DIR/log"
+	sleep 0.1
+	echo "end $fs" >> "$FAKEZFS_DIR/log"
+	if [ "$fs" = pool_c/err ]; then
+		echo "cannot create snapshot" >&2
+		exit 1
+	fi
+	;;
+*) exit 1 ;;
+esac
+`)
+	defer cleanup()
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+
+	format, err := TimestampFormatFromConfig(config.SnapshotNaming{Prefix: "zrepl_"})
+	require.NoError(t, err)
+	s := newSnapper(args{
+		ctx:              ctx,
+		clock:            &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		hooks:            &hooks.List{},
+		prefix:           "zrepl_",
+		timestampFormat:  format,
+		concurrency:      2,
+		serializePerPool: true,
+	})
+	u := func(u func(*Snapper)) State {
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+		if u != nil {
+			u(s)
+		}
+		return s.state
+	}
+	plan := make(map[*zfs.DatasetPath]*snapProgress)
+	for _, name := range []string{"pool_a/1", "pool_a/2", "pool_a/3", "pool_b/1", "pool_b/2", "pool_c/err"} {
+		fs, err := zfs.NewDatasetPath(name)
+		require.NoError(t, err)
+		plan[fs] = &snapProgress{state: SnapPending}
+	}
+	s.state, s.plan = Snapshotting, plan
+
+	snapshot(s.args, u)
+
+	log, err := ioutil.ReadFile(filepath.Join(dir, "log"))
+	require.NoError(t, err)
+	running, maxRunning := 0, 0
+	runningInPool := make(map[string]int)
+	started := 0
+	for _, l := range strings.Split(strings.TrimSpace(string(log)), "\n") {
+		fields := strings.Fields(l)
+		require.Len(t, fields, 2)
+		pool := strings.Split(fields[1], "/")[0]
+		switch fields[0] {
+		case "start":
+			started++
+			running++
+			runningInPool[pool]++
+			assert.Equal(t, 1, runningInPool[pool], "snapshots of pool %s must be serialized", pool)
+		case "end":
+			running--
+			runningInPool[pool]--
+		}
+		if running > maxRunning {
+			maxRunning = running
+		}
+	}
+	assert.Equal(t, len(plan), started)
+	assert.Equal(t, 2, maxRunning, "at most concurrency snapshots at a time, but more than one")
+
+	assert.Equal(t, ErrorWait, u(nil))
+	for fs, progress := range plan {
+		if fs.ToString() == "pool_c/err" {
+			assert.Equal(t, SnapError, progress.state)
+			assert.Error(t, progress.err)
+		} else {
+			assert.Equal(t, SnapDone, progress.state, "%s", fs.ToString())
+		}
+	}
+}
+
+func TestSnapshotRecursiveGroup(t *testing.T) {
+	tcs := []struct {
+		name     string
+		datasets string // listed below pool/a when snapshotting
+		expect   string
+	}{
+		{"complete-subtree", "pool/a\npool/a/b", "snapshot -r pool/a@zrepl_x"},
+		// created after planning, must not be snapshotted
+		{"new-child", "pool/a\npool/a/b\npool/a/new", "snapshot pool/a@zrepl_x pool/a/b@zrepl_x"},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, cleanup := withFakeZFS(t, `
+case "$1" in
+get) printf 'written\t4096\t-\n' ;;
+list) printf '`+tc.datasets+`\n' ;;
+snapshot) echo "$*" | sed 's/@zrepl_[^ ]*/@zrepl_x/g' >> "$FAKEZFS_DIR/log" ;;
+*) exit 1 ;;
+esac
+`)
+			defer cleanup()
+			ctx, end := trace.WithTaskFromStack(context.Background())
+			defer end()
+
+			format, err := TimestampFormatFromConfig(config.SnapshotNaming{Prefix: "zrepl_"})
+			require.NoError(t, err)
+			s := newSnapper(args{
+				ctx:             ctx,
+				clock:           &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+				hooks:           &hooks.List{},
+				prefix:          "zrepl_",
+				timestampFormat: format,
+				recursive:       true,
+			})
+			u := func(u func(*Snapper)) State {
+				s.mtx.Lock()
+				defer s.mtx.Unlock()
+				if u != nil {
+					u(s)
+				}
+				return s.state
+			}
+			progress := &snapProgress{state: SnapPending}
+			plan := make(map[*zfs.DatasetPath]*snapProgress)
+			for _, name := range []string{"pool/a", "pool/a/b"} {
+				fs, err := zfs.NewDatasetPath(name)
+				require.NoError(t, err)
+				plan[fs] = progress
+			}
+			s.state, s.plan = Snapshotting, plan
+
+			snapshot(s.args, u)
+
+			log, err := ioutil.ReadFile(filepath.Join(dir, "log"))
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, strings.TrimSpace(string(log)))
+			assert.Equal(t, SnapDone, progress.state)
+		})
+	}
+}
+
+func TestTrigger(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s := newSnapper(args{
+		ctx:      ctx,
+		interval: 10 * time.Minute,
+		clock:    clock,
+	})
+	s.state = Waiting
+	s.lastInvocation = clock.Now()
+	u := func(u func(*Snapper)) State {
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+		if u != nil {
+			u(s)
+		}
+		return s.state
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		wait(s.args, u)
+	}()
+	for clock.numTimers() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	require.NoError(t, s.Trigger())
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("wait did not return after trigger")
+	}
+	require.Equal(t, Planning, u(nil))
+	u(func(s *Snapper) { assert.True(t, s.forced) })
+
+	// triggers are ignored while planning or snapshotting
+	assert.Equal(t, ErrSnapshottingInProgress, s.Trigger())
+	u(func(s *Snapper) { s.state = Snapshotting })
+	assert.Equal(t, ErrSnapshottingInProgress, s.Trigger())
+
+	// at most one trigger is pending
+	u(func(s *Snapper) { s.state = ErrorWait })
+	require.NoError(t, s.Trigger())
+	assert.Equal(t, ErrAlreadyTriggered, s.Trigger())
+	s.args.drainTrigger()
+	require.NoError(t, s.Trigger())
+
+	var manual PeriodicOrManual
+	assert.Equal(t, ErrManualSnapshotting, manual.Trigger())
+}
+
+func TestInitialSnapshotDecision(t *testing.T) {
+	tcs := []struct {
+		state initialSnapshotFSState
+		take  bool
+	}{
+		{initialSnapshotFSState{}, true},
+		{initialSnapshotFSState{hasPrefixedSnapshot: true}, false},
+		{initialSnapshotFSState{isPlaceholder: true}, false},
+		{initialSnapshotFSState{hasResumeToken: true}, false},
+		{initialSnapshotFSState{isPlaceholder: true, hasResumeToken: true}, false},
+	}
+	for _, tc := range tcs {
+		take, reason := tc.state.needsInitialSnapshot()
+		assert.Equal(t, tc.take, take, "%#v", tc.state)
+		assert.Equal(t, tc.take, reason == "", "%#v: %q", tc.state, reason)
+	}
+
+	var fss []*zfs.DatasetPath
+	for _, name := range []string{"pool/a", "pool/b", "pool/c"} {
+		fs, err := zfs.NewDatasetPath(name)
+		require.NoError(t, err)
+		fss = append(fss, fs)
+	}
+	s := &Snapper{}
+	assert.Equal(t, fss, unknownFSes(fss, s.knownFSes), "before the first listing, all filesystems are unknown")
+	s.setKnownFSes(fss[:2])
+	assert.Equal(t, fss[2:], unknownFSes(fss, s.knownFSes))
+	s.setKnownFSes(fss)
+	assert.Empty(t, unknownFSes(fss, s.knownFSes))
+
+	in := &config.SnapshottingPeriodic{
+		Prefix:            "zrepl_",
+		Interval:          10 * time.Minute,
+		TimestampFormat:   "20060102_150405_000",
+		TimestampLocation: "UTC",
+		InitialSnapshot:   true,
+	}
+	p, err := PeriodicFromConfig(nil, zfs.NoFilter(), in, nil)
+	require.NoError(t, err)
+	assert.True(t, p.args.initialSnapshot)
+}
+
+func TestSkipUnchanged(t *testing.T) {
+	_, cleanup := withFakeZFS(t, `
+[ "$1" = get ] && [ "$5" = written ] || exit 1
+case "$6" in
+	pool/unchanged) printf 'written\t0\t-\n' ;;
+	pool/changed)   printf 'written\t4096\t-\n' ;;
+	*) echo "cannot open '$6': dataset does not exist" >&2; exit 1 ;;
+esac
+`)
+	defer cleanup()
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+
+	clock := &fakeClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	s := newSnapper(args{ctx: ctx, clock: clock, skipUnchanged: true})
+	u := func(u func(*Snapper)) State {
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+		if u != nil {
+			u(s)
+		}
+		return s.state
+	}
+
+	tcs := []struct {
+		fs   string
+		skip bool
+	}{
+		{"pool/unchanged", true},
+		{"pool/changed", false},
+		{"pool/error", false}, // snapshotted anyway
+	}
+	for _, tc := range tcs {
+		t.Run(tc.fs, func(t *testing.T) {
+			fs, err := zfs.NewDatasetPath(tc.fs)
+			require.NoError(t, err)
+			progress := &snapProgress{state: SnapPending}
+			assert.Equal(t, tc.skip, skipUnchanged(ctx, s.args, u, fs, progress))
+			if tc.skip {
+				assert.Equal(t, SnapSkipped, progress.state)
+				assert.Equal(t, clock.Now(), progress.doneAt)
+			} else {
+				assert.Equal(t, SnapPending, progress.state)
+			}
+		})
+	}
+}
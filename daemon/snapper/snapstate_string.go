@@ -12,12 +12,16 @@ func _() {
 	_ = x[SnapStarted-2]
 	_ = x[SnapDone-4]
 	_ = x[SnapError-8]
+	_ = x[SnapIncomplete-16]
+	_ = x[SnapSkipped-32]
 }
 
 const (
 	_SnapState_name_0 = "SnapPendingSnapStarted"
 	_SnapState_name_1 = "SnapDone"
 	_SnapState_name_2 = "SnapError"
+	_SnapState_name_3 = "SnapIncomplete"
+	_SnapState_name_4 = "SnapSkipped"
 )
 
 var (
@@ -33,6 +37,10 @@ func (i SnapState) String() string {
 		return _SnapState_name_1
 	case i == 8:
 		return _SnapState_name_2
+	case i == 16:
+		return _SnapState_name_3
+	case i == 32:
+		return _SnapState_name_4
 	default:
 		return "SnapState(" + strconv.FormatInt(int64(i), 10) + ")"
 	}
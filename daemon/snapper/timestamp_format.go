@@ -0,0 +1,72 @@
+package snapper
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/config"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+const (
+	defaultTimestampLayout   = "20060102_150405_000"
+	defaultTimestampLocation = "UTC"
+)
+
+// TimestampFormat determines the part of a snapshot name that follows the prefix.
+// The zero value is not valid, use TimestampFormatFromConfig.
+type TimestampFormat struct {
+	layout   string // as understood by time.Time.Format
+	location *time.Location
+}
+
+// TimestampFormatFromConfig validates the timestamp format of in by formatting sample times:
+// the result must be a valid snapshot name and must depend on the time.
+func TimestampFormatFromConfig(in config.SnapshotNaming) (*TimestampFormat, error) {
+	return timestampFormatFromConfig(in.Prefix, in.TimestampFormat, in.TimestampLocation)
+}
+
+func timestampFormatFromConfig(prefix, layout, locationName string) (*TimestampFormat, error) {
+	if layout == "" {
+		layout = defaultTimestampLayout
+	}
+	if locationName == "" {
+		locationName = defaultTimestampLocation
+	}
+	location, err := time.LoadLocation(locationName)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid timestamp_location")
+	}
+	f := &TimestampFormat{layout: layout, location: location}
+
+	a := f.SnapshotName(prefix, time.Date(2006, time.January, 2, 15, 4, 5, 123456789, time.UTC))
+	b := f.SnapshotName(prefix, time.Date(2019, time.December, 31, 23, 59, 58, 0, time.UTC))
+	for _, sample := range []string{a, b} {
+		if _, err := zfs.NewDatasetPath(sample); err != nil {
+			return nil, errors.Wrapf(err, "timestamp_format %q produces invalid snapshot name %q", layout, sample)
+		}
+		if strings.Contains(sample, "/") {
+			return nil, errors.Errorf("timestamp_format %q produces invalid snapshot name %q: must not contain '/'", layout, sample)
+		}
+	}
+	if a == b {
+		return nil, errors.Errorf("timestamp_format %q does not contain any time fields", layout)
+	}
+	return f, nil
+}
+
+// SnapshotName returns the name (without filesystem) of a snapshot with prefix taken at now.
+func (f *TimestampFormat) SnapshotName(prefix string, now time.Time) string {
+	return prefix + now.In(f.location).Format(f.layout)
+}
+
+// IsSnapshotName returns true if name (without filesystem) could have been returned by SnapshotName for prefix.
+func (f *TimestampFormat) IsSnapshotName(prefix, name string) bool {
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	_, err := time.ParseInLocation(f.layout, strings.TrimPrefix(name, prefix), f.location)
+	return err == nil
+}
@@ -0,0 +1,51 @@
+package snapper
+
+import (
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrSnapshottingInProgress = errors.New("snapshotting is already in progress")
+	ErrAlreadyTriggered       = errors.New("snapshotting has already been triggered")
+	ErrSnapperStopped         = errors.New("snapper is stopped")
+)
+
+// Trigger makes the snapper start a snapshotting round immediately if it is waiting,
+// instead of at the next scheduled time.
+// All filesystems are snapshotted, regardless of whether they are due.
+// The schedule continues relative to the start of the triggered round.
+//
+// Triggers are ignored while the snapper is planning or snapshotting (ErrSnapshottingInProgress).
+func (s *Snapper) Trigger() error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	switch s.state {
+	case Planning, Snapshotting:
+		return ErrSnapshottingInProgress
+	case Stopped:
+		return ErrSnapperStopped
+	}
+	select {
+	case s.args.trigger <- struct{}{}:
+		return nil
+	default:
+		return ErrAlreadyTriggered
+	}
+}
+
+// onTrigger is the transition of the waiting states if Trigger was called.
+func onTrigger(a args, u updater) state {
+	getLogger(a.ctx).Info("snapshotting triggered, start snapshotting round")
+	return u(func(s *Snapper) {
+		s.state = Planning
+		s.forced = true
+	}).sf()
+}
+
+// drainTrigger discards a trigger that raced with the start of a round.
+func (a args) drainTrigger() {
+	select {
+	case <-a.trigger:
+	default:
+	}
+}
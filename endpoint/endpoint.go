@@ -25,6 +25,35 @@ type SenderConfig struct {
 	Encrypt                     *zfs.NilBool
 	DisableIncrementalStepHolds bool
 	JobID                       JobID
+	// Flags for unencrypted sends, encrypted sends are always raw.
+	SendOptions zfs.SendOptions
+	// If not empty, a copy of every send stream is written to a file in this directory.
+	TeeDirectory string
+	// Compression of the files in TeeDirectory, the zero value means no compression.
+	TeeCompression TeeCompression
+	// Set if the sender is used by one of multiple targets of a push job.
+	FanOut *SenderFanOut
+	// Not used by Sender: the job calls ReleaseStaleStepHolds on startup if set.
+	ReleaseStaleStepHoldsOnStartup bool
+	// If not nil, send streams are throttled depending on the IO of the sending pool.
+	PoolIOThrottle *PoolIOThrottleConfig
+	// If set, SendCompleted records the time and the sent snapshot in LastReplicatedPropertyName.
+	LastReplicatedProperty bool
+	// The prefix of the job's snapshots, empty if the job does not create snapshots.
+	// DestroySnapshots never destroys the newest snapshot with this prefix, see doDestroySnapshots.
+	SnapshotPrefix string
+}
+
+// SenderFanOut describes the targets of a push job with multiple targets.
+// Each target has its own job ID and thus its own replication cursor.
+//
+// A push job that is changed from a single target to multiple targets has replication cursors
+// of its previous job ID (the LegacyJobID). These are used in place of a target's
+// replication cursor until all targets have their own, at which point they are destroyed.
+type SenderFanOut struct {
+	// the job IDs of all targets, including SenderConfig.JobID
+	TargetJobIDs []JobID
+	LegacyJobID  JobID
 }
 
 func (c *SenderConfig) Validate() error {
@@ -35,6 +64,32 @@ func (c *SenderConfig) Validate() error {
 	if _, err := StepHoldTag(c.JobID); err != nil {
 		return fmt.Errorf("JobID cannot be used for hold tag: %s", err)
 	}
+	if c.TeeDirectory != "" && !path.IsAbs(c.TeeDirectory) {
+		return fmt.Errorf("`TeeDirectory` must be an absolute path, got %q", c.TeeDirectory)
+	}
+	if err := c.TeeCompression.Validate(); err != nil {
+		return errors.Wrap(err, "`TeeCompression` invalid")
+	}
+	if c.PoolIOThrottle != nil {
+		if err := c.PoolIOThrottle.Validate(); err != nil {
+			return errors.Wrap(err, "`PoolIOThrottle` invalid")
+		}
+	}
+	if c.FanOut != nil {
+		if err := c.FanOut.LegacyJobID.Validate(); err != nil {
+			return errors.Wrap(err, "`FanOut.LegacyJobID` invalid")
+		}
+		found := false
+		for _, id := range c.FanOut.TargetJobIDs {
+			if err := id.Validate(); err != nil {
+				return errors.Wrap(err, "`FanOut.TargetJobIDs` invalid")
+			}
+			found = found || id == c.JobID
+		}
+		if !found {
+			return fmt.Errorf("`FanOut.TargetJobIDs` must contain `JobID`")
+		}
+	}
 	return nil
 }
 
@@ -42,19 +97,37 @@ func (c *SenderConfig) Validate() error {
 type Sender struct {
 	FSFilter                    zfs.DatasetFilter
 	encrypt                     *zfs.NilBool
+	sendOptions                 zfs.SendOptions
 	disableIncrementalStepHolds bool
 	jobId                       JobID
+	teeDirectory                string
+	teeCompression              TeeCompression
+	fanOut                      *SenderFanOut
+	poolIOThrottle              *poolIOThrottle // nil if not throttled
+	lastReplicatedProperty      bool
+	snapshotPrefix              string
 }
 
 func NewSender(conf SenderConfig) *Sender {
 	if err := conf.Validate(); err != nil {
 		panic("invalid config" + err.Error())
 	}
+	var throttle *poolIOThrottle
+	if conf.PoolIOThrottle != nil {
+		throttle = newPoolIOThrottle(*conf.PoolIOThrottle, conf.JobID)
+	}
 	return &Sender{
 		FSFilter:                    conf.FSF,
 		encrypt:                     conf.Encrypt,
+		sendOptions:                 conf.SendOptions,
 		disableIncrementalStepHolds: conf.DisableIncrementalStepHolds,
 		jobId:                       conf.JobID,
+		teeDirectory:                conf.TeeDirectory,
+		teeCompression:              conf.TeeCompression,
+		fanOut:                      conf.FanOut,
+		poolIOThrottle:              throttle,
+		lastReplicatedProperty:      conf.LastReplicatedProperty,
+		snapshotPrefix:              conf.SnapshotPrefix,
 	}
 }
 
@@ -76,25 +149,53 @@ func (s *Sender) filterCheckFS(fs string) (*zfs.DatasetPath, error) {
 	return dp, nil
 }
 
+// ListFilesystems returns the filesystems that the client may replicate from this Sender.
+// These are exactly the filesystems matched by the job's filesystems filter;
+// Sender methods that operate on a filesystem reject filesystems that are not matched (see filterCheckFS).
+// Active sides thus enumerate the filesystems to replicate using this RPC and need no filter of their own.
 func (s *Sender) ListFilesystems(ctx context.Context, r *pdu.ListFilesystemReq) (*pdu.ListFilesystemRes, error) {
 	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
 
-	fss, err := zfs.ZFSListMapping(ctx, s.FSFilter)
+	// the planner replicates a clone's origin first and then sends the clone relative to it
+	mapping, err := zfs.ZFSListMappingProperties(ctx, s.FSFilter, []string{"origin"})
 	if err != nil {
 		return nil, err
 	}
+	fss := make([]*zfs.DatasetPath, len(mapping))
+	for i := range mapping {
+		fss[i] = mapping[i].Path
+	}
 	rfss := make([]*pdu.Filesystem, len(fss))
 	for i := range fss {
 		encEnabled, err := zfs.ZFSGetEncryptionEnabled(ctx, fss[i].ToString())
 		if err != nil {
 			return nil, errors.Wrap(err, "cannot get filesystem encryption status")
 		}
+		// In cascaded replication (A => B => C), B's filesystems might be placeholders
+		// or have a partially received stream from A.
+		// The planner refuses to replicate such filesystems to C.
+		ph, err := zfs.ZFSGetFilesystemPlaceholderState(ctx, fss[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot get placeholder state for fs %q", fss[i].ToString())
+		}
+		token, err := zfs.ZFSGetReceiveResumeTokenOrEmptyStringIfNotSupported(ctx, fss[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot get receive resume token for fs %q", fss[i].ToString())
+		}
+		originFS, origin, err := zfs.ZFSResolveOrigin(ctx, mapping[i].Fields[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot get origin of fs %q", fss[i].ToString())
+		}
 		rfss[i] = &pdu.Filesystem{
-			Path: fss[i].ToString(),
-			// ResumeToken does not make sense from Sender
-			IsPlaceholder: false, // sender FSs are never placeholders
+			Path:          fss[i].ToString(),
+			ResumeToken:   token,
+			IsPlaceholder: ph.IsPlaceholder,
 			IsEncrypted:   encEnabled,
 		}
+		if origin != nil {
+			rfss[i].OriginFilesystem = originFS.ToString()
+			rfss[i].Origin = pdu.FilesystemVersionFromZFS(origin)
+		}
 	}
 	res := &pdu.ListFilesystemRes{Filesystems: rfss}
 	return res, nil
@@ -149,6 +250,22 @@ func (s *Sender) Send(ctx context.Context, r *pdu.SendReq) (*pdu.SendRes, io.Rea
 	if err != nil {
 		return nil, nil, err
 	}
+	if r.GetFromOriginFilesystem() != "" {
+		// the stream is relative to the origin => must be allowed to send the origin as well
+		if _, err := s.filterCheckFS(r.GetFromOriginFilesystem()); err != nil {
+			return nil, nil, errors.Wrap(err, "clone origin")
+		}
+	}
+
+	// the send is active until the send stream is closed, see ReleaseStaleStepHolds
+	endSend := beginSend(s.jobId, r.Filesystem)
+	sendStreamReturned := false
+	defer func() {
+		if !sendStreamReturned {
+			endSend()
+		}
+	}()
+
 	switch r.Encrypted {
 	case pdu.Tri_DontCare:
 		// use s.encrypt setting
@@ -172,7 +289,10 @@ func (s *Sender) Send(ctx context.Context, r *pdu.SendReq) (*pdu.SendRes, io.Rea
 		From:        uncheckedSendArgsFromPDU(r.GetFrom()), // validated by zfs.ZFSSendDry / zfs.ZFSSend
 		To:          uncheckedSendArgsFromPDU(r.GetTo()),   // validated by zfs.ZFSSendDry / zfs.ZFSSend
 		Encrypted:   s.encrypt,
+		Options:     s.sendOptions,
 		ResumeToken: r.ResumeToken, // nil or not nil, depending on decoding success
+
+		FromOriginFS: r.GetFromOriginFilesystem(), // validated by sendArgsUnvalidated.Validate
 	}
 
 	sendArgs, err := sendArgsUnvalidated.Validate(ctx)
@@ -211,9 +331,13 @@ func (s *Sender) Send(ctx context.Context, r *pdu.SendReq) (*pdu.SendRes, io.Rea
 		return res, nil, nil
 	}
 
+	// The abstractions for `From` are per-filesystem, but a clone origin belongs to another filesystem.
+	// No hold is needed for it: ZFS refuses to destroy the origin of a clone.
+	fromIsCloneOrigin := sendArgs.FromOriginFS != ""
+
 	// create a replication cursor for `From` (usually an idempotent no-op because SendCompleted already created it before)
 	var fromReplicationCursor Abstraction
-	if sendArgs.From != nil {
+	if sendArgs.From != nil && !fromIsCloneOrigin {
 		// For all but the first replication, this should always be a no-op because SendCompleted already moved the cursor
 		fromReplicationCursor, err = CreateReplicationCursor(ctx, sendArgs.FS, *sendArgs.FromVersion, s.jobId) // no shadow
 		if err == zfs.ErrBookmarkCloningNotSupported {
@@ -224,11 +348,11 @@ func (s *Sender) Send(ctx context.Context, r *pdu.SendReq) (*pdu.SendRes, io.Rea
 		}
 	}
 
-	takeStepHolds := sendArgs.FromVersion == nil || !s.disableIncrementalStepHolds
+	takeStepHolds := sendArgs.FromVersion == nil || fromIsCloneOrigin || !s.disableIncrementalStepHolds
 
 	var fromHold, toHold Abstraction
 	// make sure `From` doesn't go away in order to make this step resumable
-	if sendArgs.From != nil && takeStepHolds {
+	if sendArgs.From != nil && !fromIsCloneOrigin && takeStepHolds {
 		fromHold, err = HoldStep(ctx, sendArgs.FS, *sendArgs.FromVersion, s.jobId) // no shadow
 		if err == zfs.ErrBookmarkCloningNotSupported {
 			getLogger(ctx).Debug("not creating step bookmark because ZFS does not support it")
@@ -277,7 +401,7 @@ func (s *Sender) Send(ctx context.Context, r *pdu.SendReq) (*pdu.SendRes, io.Rea
 			// last line of defense: check that we don't destroy the incremental `from` and `to`
 			// if we did that, we might be about to blow away the last common filesystem version between sender and receiver
 			mustLiveVersions := []zfs.FilesystemVersion{sendArgs.ToVersion}
-			if sendArgs.FromVersion != nil {
+			if sendArgs.FromVersion != nil && !fromIsCloneOrigin {
 				mustLiveVersions = append(mustLiveVersions, *sendArgs.FromVersion)
 			}
 			for _, staleVersion := range obsoleteAbs {
@@ -300,13 +424,37 @@ func (s *Sender) Send(ctx context.Context, r *pdu.SendReq) (*pdu.SendRes, io.Rea
 		}
 	}
 
-	sendStream, err := zfs.ZFSSend(ctx, sendArgs)
+	zfsSendStream, err := zfs.ZFSSend(ctx, sendArgs)
 	if err != nil {
 		// it's ok to not destroy the abstractions we just created here, a new send attempt will take care of it
 		return nil, nil, errors.Wrap(err, "zfs send failed")
 	}
 
-	return res, sendStream, nil
+	var sendStream io.ReadCloser = zfsSendStream
+	if s.poolIOThrottle != nil {
+		fs, _ := zfs.NewDatasetPath(sendArgs.FS) // validated by filterCheckFS
+		var throttleDone func()
+		sendStream, throttleDone = s.poolIOThrottle.limit(ctx, fs.Pool(), sendStream)
+		endSendUnthrottled := endSend
+		endSend = func() {
+			throttleDone()
+			endSendUnthrottled()
+		}
+	}
+
+	if s.teeDirectory != "" {
+		teeFile, err := createSendTeeFile(s.teeDirectory, sendArgs, s.teeCompression)
+		if err != nil {
+			sendStream.Close()
+			return nil, nil, errors.Wrap(err, "cannot create send stream tee file")
+		}
+		getLogger(ctx).WithField("tee_file", teeFile.path).Debug("writing copy of send stream")
+		sendStream = zfs.NewStreamCopier(sendStream, teeFile)
+	}
+
+	sendStreamReturned = true
+	key := activeSendKey{s.jobId, sendArgs.FS}
+	return res, newSendStreamEndingSend(key, sendArgs.ToVersion.RelName(), expSize, sendStream, endSend), nil
 }
 
 func (p *Sender) SendCompleted(ctx context.Context, r *pdu.SendCompletedReq) (*pdu.SendCompletedRes, error) {
@@ -320,7 +468,7 @@ func (p *Sender) SendCompleted(ctx context.Context, r *pdu.SendCompletedReq) (*p
 	fs := fsp.ToString()
 
 	var from *zfs.FilesystemVersion
-	if orig.GetFrom() != nil {
+	if orig.GetFrom() != nil && orig.GetFromOriginFilesystem() == "" { // a clone origin belongs to another filesystem
 		f, err := sendArgsFromPDUAndValidateExistsAndGetVersion(ctx, fs, orig.GetFrom()) // no shadow
 		if err != nil {
 			return nil, errors.Wrap(err, "validate `from` exists")
@@ -358,15 +506,59 @@ func (p *Sender) SendCompleted(ctx context.Context, r *pdu.SendCompletedReq) (*p
 		log(ctx).WithField("to_cursor", toReplicationCursor.String()).Info("successfully created `to` replication cursor")
 	}
 
+	if p.lastReplicatedProperty {
+		// the step is complete regardless, a later SendCompleted will update the property
+		if err := setLastReplicatedProperty(ctx, fsp, to); err != nil {
+			log(ctx).WithError(err).Error("cannot set last replicated property")
+		}
+	}
+
 	keep := func(a Abstraction) bool {
 		return AbstractionEquals(a, toReplicationCursor)
 	}
 	sendAbstractionsCacheSingleton.TryBatchDestroy(ctx, p.jobId, fs, keep, nil)
 
+	if p.fanOut != nil && toReplicationCursor != nil {
+		err := DestroyMigratedLegacyReplicationCursors(ctx, fs, p.fanOut.TargetJobIDs, p.fanOut.LegacyJobID)
+		if err != nil {
+			log(ctx).WithError(err).Error("cannot destroy legacy replication cursors")
+		}
+	}
+
 	return &pdu.SendCompletedRes{}, nil
 
 }
 
+// HoldPlannedVersions protects the snapshots that the remaining steps of an active side's
+// replication plan depend on from being destroyed, e.g., by a concurrent prune.
+// See MovePlannedHolds for semantics.
+func (p *Sender) HoldPlannedVersions(ctx context.Context, req *pdu.HoldPlannedVersionsReq) (*pdu.HoldPlannedVersionsRes, error) {
+	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
+
+	dp, err := p.filterCheckFS(req.GetFilesystem())
+	if err != nil {
+		return nil, err
+	}
+	fs := dp.ToString()
+
+	keep := make([]zfs.FilesystemVersion, 0, len(req.GetVersions()))
+	for _, v := range req.GetVersions() {
+		if v.GetType() != pdu.FilesystemVersion_Snapshot {
+			return nil, fmt.Errorf("version %q is not a snapshot", v.GetName())
+		}
+		version, err := sendArgsFromPDUAndValidateExistsAndGetVersion(ctx, fs, v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "validate %q exists", v.GetName())
+		}
+		keep = append(keep, version)
+	}
+
+	if err := MovePlannedHolds(ctx, fs, keep, p.jobId); err != nil {
+		return nil, err
+	}
+	return &pdu.HoldPlannedVersionsRes{}, nil
+}
+
 func (p *Sender) DestroySnapshots(ctx context.Context, req *pdu.DestroySnapshotsReq) (*pdu.DestroySnapshotsRes, error) {
 	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
 
@@ -374,7 +566,7 @@ func (p *Sender) DestroySnapshots(ctx context.Context, req *pdu.DestroySnapshots
 	if err != nil {
 		return nil, err
 	}
-	return doDestroySnapshots(ctx, dp, req.Snapshots)
+	return doDestroySnapshots(ctx, dp, req.Snapshots, p.snapshotPrefix)
 }
 
 func (p *Sender) Ping(ctx context.Context, req *pdu.PingReq) (*pdu.PingRes, error) {
@@ -410,6 +602,12 @@ func (p *Sender) ReplicationCursor(ctx context.Context, req *pdu.ReplicationCurs
 	if err != nil {
 		return nil, err
 	}
+	if cursor == nil && p.fanOut != nil {
+		cursor, err = GetMostRecentReplicationCursorOfJob(ctx, dp.ToString(), p.fanOut.LegacyJobID)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if cursor == nil {
 		return &pdu.ReplicationCursorRes{Result: &pdu.ReplicationCursorRes_Notexist{Notexist: true}}, nil
 	}
@@ -439,6 +637,26 @@ type ReceiverConfig struct {
 	AppendClientIdentity       bool
 
 	UpdateLastReceivedHold bool
+
+	EncryptionRoot *EncryptionRootPolicy // may be nil
+
+	// Set on every received filesystem using `zfs recv -o`.
+	// After each receive, properties that did not take effect are logged as warnings.
+	SetProperties map[string]string
+	// Excluded from the stream on every receive using `zfs recv -x`.
+	ExcludeProperties []string
+
+	// Renames received filesystems below the client root. May be nil.
+	Rename ReceiverRename
+}
+
+// A ReceiverRename renames the filesystems received by a Receiver.
+// Paths are relative to the client root.
+type ReceiverRename interface {
+	// Map returns the local path of the received filesystem fs, or nil if fs is not renamed.
+	Map(fs *zfs.DatasetPath) (*zfs.DatasetPath, error)
+	// Unmap inverts Map: it returns the received filesystem that Map maps to local, or nil if there is none.
+	Unmap(local *zfs.DatasetPath) (*zfs.DatasetPath, error)
 }
 
 func (c *ReceiverConfig) copyIn() {
@@ -450,6 +668,17 @@ func (c *ReceiverConfig) Validate() error {
 	if c.RootWithoutClientComponent.Length() <= 0 {
 		return errors.New("RootWithoutClientComponent must not be an empty dataset path")
 	}
+	if c.EncryptionRoot != nil {
+		if err := c.EncryptionRoot.Validate(); err != nil {
+			return errors.Wrap(err, "`EncryptionRoot` invalid")
+		}
+	}
+	if err := zfs.ValidateRecvSetProperties(c.SetProperties); err != nil {
+		return errors.Wrap(err, "`SetProperties` invalid")
+	}
+	if err := zfs.ValidateRecvExcludeProperties(c.ExcludeProperties, c.SetProperties); err != nil {
+		return errors.Wrap(err, "`ExcludeProperties` invalid")
+	}
 	return nil
 }
 
@@ -506,8 +735,13 @@ func (s *Receiver) clientRootFromCtx(ctx context.Context) *zfs.DatasetPath {
 	return clientRoot
 }
 
+func (s *Receiver) subrootFromCtx(ctx context.Context) subroot {
+	return subroot{localRoot: s.clientRootFromCtx(ctx), rename: s.conf.Rename}
+}
+
 type subroot struct {
 	localRoot *zfs.DatasetPath
+	rename    ReceiverRename // may be nil
 }
 
 var _ zfs.DatasetFilter = subroot{}
@@ -525,9 +759,41 @@ func (f subroot) MapToLocal(fs string) (*zfs.DatasetPath, error) {
 	if p.Length() == 0 {
 		return nil, errors.Errorf("cannot map empty filesystem")
 	}
-	c := f.localRoot.Copy()
-	c.Extend(p)
-	return c, nil
+	if f.rename != nil {
+		renamed, err := f.rename.Map(p)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot rename filesystem %q", fs)
+		}
+		if renamed != nil {
+			p = renamed
+		} else if other, err := f.rename.Unmap(p); err != nil {
+			return nil, errors.Wrapf(err, "cannot rename filesystem %q", fs)
+		} else if other != nil {
+			return nil, errors.Errorf("filesystem %q collides with the renamed filesystem %q", fs, other.ToString())
+		}
+		if p.Length() == 0 {
+			return nil, errors.Errorf("filesystem %q is renamed to the client root", fs)
+		}
+	}
+	return f.localRoot.Extended(p), nil
+}
+
+// MapToRemote inverts MapToLocal for local, which must pass f.
+// It returns nil if local is not the counterpart of any received filesystem,
+// i.e., if it is the counterpart of a filesystem that is renamed to another local path.
+func (f subroot) MapToRemote(local *zfs.DatasetPath) (*zfs.DatasetPath, error) {
+	p, _ := local.Relative(f.localRoot)
+	if f.rename == nil {
+		return p, nil
+	}
+	orig, err := f.rename.Unmap(p)
+	if err != nil || orig != nil {
+		return orig, err
+	}
+	if renamed, err := f.rename.Map(p); err != nil || renamed != nil {
+		return nil, err
+	}
+	return p, nil
 }
 
 func (s *Receiver) ListFilesystems(ctx context.Context, req *pdu.ListFilesystemReq) (*pdu.ListFilesystemRes, error) {
@@ -540,8 +806,8 @@ func (s *Receiver) ListFilesystems(ctx context.Context, req *pdu.ListFilesystemR
 		return nil, errors.New("root_fs does not exist")
 	}
 
-	root := s.clientRootFromCtx(ctx)
-	filtered, err := zfs.ZFSListMapping(ctx, subroot{root})
+	root := s.subrootFromCtx(ctx)
+	filtered, err := zfs.ZFSListMapping(ctx, root)
 	if err != nil {
 		return nil, err
 	}
@@ -572,10 +838,18 @@ func (s *Receiver) ListFilesystems(ctx context.Context, req *pdu.ListFilesystemR
 		}
 		l.WithField("receive_resume_token", token).Debug("receive resume token")
 
-		a.TrimPrefix(root)
+		remote, err := root.MapToRemote(a)
+		if err != nil {
+			l.WithError(err).Error("cannot map filesystem to sender filesystem")
+			return nil, err
+		}
+		if remote == nil {
+			l.Debug("skipping filesystem, its sender filesystem is renamed to another filesystem")
+			continue
+		}
 
 		fs := &pdu.Filesystem{
-			Path:          a.ToString(),
+			Path:          remote.ToString(),
 			IsPlaceholder: ph.IsPlaceholder,
 			ResumeToken:   token,
 			IsEncrypted:   encEnabled,
@@ -592,8 +866,7 @@ func (s *Receiver) ListFilesystems(ctx context.Context, req *pdu.ListFilesystemR
 func (s *Receiver) ListFilesystemVersions(ctx context.Context, req *pdu.ListFilesystemVersionsReq) (*pdu.ListFilesystemVersionsRes, error) {
 	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
 
-	root := s.clientRootFromCtx(ctx)
-	lp, err := subroot{root}.MapToLocal(req.GetFilesystem())
+	lp, err := s.subrootFromCtx(ctx).MapToLocal(req.GetFilesystem())
 	if err != nil {
 		return nil, err
 	}
@@ -649,8 +922,7 @@ func (s *Receiver) Receive(ctx context.Context, req *pdu.ReceiveReq, receive io.
 	getLogger(ctx).Debug("incoming Receive")
 	defer receive.Close()
 
-	root := s.clientRootFromCtx(ctx)
-	lp, err := subroot{root}.MapToLocal(req.Filesystem)
+	lp, err := s.subrootFromCtx(ctx).MapToLocal(req.Filesystem)
 	if err != nil {
 		return nil, errors.Wrap(err, "`Filesystem` invalid")
 	}
@@ -753,6 +1025,8 @@ func (s *Receiver) Receive(ctx context.Context, req *pdu.ReceiveReq, receive io.
 		}
 	}
 
+	recvOpts.SetProperties = s.conf.SetProperties
+	recvOpts.ExcludeProperties = s.conf.ExcludeProperties
 	recvOpts.SavePartialRecvState, err = zfs.ResumeRecvSupported(ctx, lp)
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot determine whether we can use resumable send & recv")
@@ -863,6 +1137,17 @@ func (s *Receiver) Receive(ctx context.Context, req *pdu.ReceiveReq, receive io.
 		return nil, errors.Wrap(err, msg)
 	}
 
+	if len(s.conf.SetProperties) > 0 {
+		// the data has been received, a failing check does not fail the step
+		mismatches, err := zfs.RecvPropertyMismatches(ctx, lp, s.conf.SetProperties)
+		if err != nil {
+			log.WithError(err).Error("cannot check whether the recv property overrides took effect")
+		}
+		for _, m := range mismatches {
+			log.WithField("property", m.Property).Warn("recv property override did not take effect: " + m.String())
+		}
+	}
+
 	if s.conf.UpdateLastReceivedHold {
 		log.Debug("move last-received-hold")
 		if err := MoveLastReceivedHold(ctx, lp.ToString(), toRecvd, s.conf.JobID); err != nil {
@@ -870,18 +1155,26 @@ func (s *Receiver) Receive(ctx context.Context, req *pdu.ReceiveReq, receive io.
 		}
 	}
 
+	if s.conf.EncryptionRoot != nil {
+		if err := applyEncryptionRootPolicy(ctx, lp.ToString(), *s.conf.EncryptionRoot); err != nil {
+			log.WithError(err).Error("cannot apply encryption root policy")
+			return nil, errors.Wrap(err, "received snapshot, but cannot apply encryption root policy")
+		}
+	}
+
 	return &pdu.ReceiveRes{}, nil
 }
 
 func (s *Receiver) DestroySnapshots(ctx context.Context, req *pdu.DestroySnapshotsReq) (*pdu.DestroySnapshotsRes, error) {
 	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
 
-	root := s.clientRootFromCtx(ctx)
-	lp, err := subroot{root}.MapToLocal(req.Filesystem)
+	lp, err := s.subrootFromCtx(ctx).MapToLocal(req.Filesystem)
 	if err != nil {
 		return nil, err
 	}
-	return doDestroySnapshots(ctx, lp, req.Snapshots)
+	// A receiver cannot distinguish received snapshots by name, but it does not need to:
+	// an incremental receive requires the newest snapshot to be the incremental source.
+	return doDestroySnapshots(ctx, lp, req.Snapshots, "")
 }
 
 func (p *Receiver) SendCompleted(ctx context.Context, _ *pdu.SendCompletedReq) (*pdu.SendCompletedRes, error) {
@@ -890,26 +1183,69 @@ func (p *Receiver) SendCompleted(ctx context.Context, _ *pdu.SendCompletedReq) (
 	return &pdu.SendCompletedRes{}, nil
 }
 
-func doDestroySnapshots(ctx context.Context, lp *zfs.DatasetPath, snaps []*pdu.FilesystemVersion) (*pdu.DestroySnapshotsRes, error) {
-	reqs := make([]*zfs.DestroySnapOp, len(snaps))
-	ress := make([]*pdu.DestroySnapshotRes, len(snaps))
-	errs := make([]error, len(snaps))
-	for i, fsv := range snaps {
+func (s *Receiver) HoldPlannedVersions(ctx context.Context, _ *pdu.HoldPlannedVersionsReq) (*pdu.HoldPlannedVersionsRes, error) {
+	defer trace.WithSpanFromStackUpdateCtx(&ctx)()
+	return nil, fmt.Errorf("HoldPlannedVersions not implemented for Receiver")
+}
+
+// doDestroySnapshots is the destroy path shared by Sender and Receiver.
+//
+// It never destroys the newest snapshot of lp whose name starts with prefix (any snapshot if prefix is empty),
+// regardless of what was requested: losing it would break incremental replication,
+// so a request to destroy it is always a bug in the pruning policy or its implementation.
+// Snapshots without prefix, e.g., manual ones, must not count as the newest snapshot
+// because they would leave the job's newest snapshot, the incremental base, unprotected.
+func doDestroySnapshots(ctx context.Context, lp *zfs.DatasetPath, snaps []*pdu.FilesystemVersion, prefix string) (*pdu.DestroySnapshotsRes, error) {
+	for _, fsv := range snaps {
 		if fsv.Type != pdu.FilesystemVersion_Snapshot {
 			return nil, fmt.Errorf("version %q is not a snapshot", fsv.Name)
 		}
+	}
+
+	existing, err := zfs.ZFSListFilesystemVersions(ctx, lp, zfs.ListFilesystemVersionsOptions{
+		Types:           zfs.Snapshots,
+		ShortnamePrefix: prefix,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot list snapshots to determine the newest snapshot")
+	}
+	newest := newestSnapshot(existing)
+
+	reqs := make([]*zfs.DestroySnapOp, 0, len(snaps))
+	ress := make([]*pdu.DestroySnapshotRes, len(snaps))
+	errs := make([]error, len(snaps))
+	for i, fsv := range snaps {
 		ress[i] = &pdu.DestroySnapshotRes{
 			Snapshot: fsv,
 			// Error set after batch operation
 		}
-		reqs[i] = &zfs.DestroySnapOp{
+		if newest != nil && fsv.Name == newest.Name {
+			getLogger(ctx).
+				WithField("fs", lp.ToString()).
+				WithField("snap", fsv.Name).
+				WithField("prefix", prefix).
+				Error("refusing to destroy the newest snapshot of the filesystem, this is a bug in the pruning policy or its implementation")
+			errs[i] = fmt.Errorf("refusing to destroy the newest snapshot of filesystem %q", lp.ToString())
+			continue
+		}
+		reqs = append(reqs, &zfs.DestroySnapOp{
 			Filesystem: lp.ToString(),
 			Name:       fsv.Name,
 			ErrOut:     &errs[i],
-		}
+		})
 	}
-	zfs.ZFSDestroyFilesystemVersions(ctx, reqs)
-	for i := range reqs {
+	// Destroy runs of consecutive snapshots using ranges.
+	// The order must not be derived from existing because the latter skips unparseable snapshots,
+	// which a range would destroy.
+	order, err := zfs.ZFSListSnapshotOrder(ctx, lp)
+	if err != nil {
+		getLogger(ctx).WithError(err).WithField("fs", lp.ToString()).
+			Warn("cannot list snapshot order, destroying snapshots without ranges")
+		zfs.ZFSDestroyFilesystemVersions(ctx, reqs)
+	} else {
+		zfs.ZFSDestroyFilesystemVersionsRanged(ctx, reqs, zfs.SnapshotOrder{lp.ToString(): order})
+	}
+	for i := range snaps {
 		if errs[i] != nil {
 			if de, ok := errs[i].(*zfs.DestroySnapshotsError); ok && len(de.Reason) == 1 {
 				ress[i].Error = de.Reason[0]
@@ -922,3 +1258,19 @@ func doDestroySnapshots(ctx context.Context, lp *zfs.DatasetPath, snaps []*pdu.F
 		Results: ress,
 	}, nil
 }
+
+// newestSnapshot returns the snapshot in versions with the highest createtxg,
+// or nil if versions contains no snapshot.
+func newestSnapshot(versions []zfs.FilesystemVersion) *zfs.FilesystemVersion {
+	var newest *zfs.FilesystemVersion
+	for i := range versions {
+		v := &versions[i]
+		if v.Type != zfs.Snapshot {
+			continue
+		}
+		if newest == nil || v.CreateTXG > newest.CreateTXG {
+			newest = v
+		}
+	}
+	return newest
+}
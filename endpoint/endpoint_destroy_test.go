@@ -0,0 +1,132 @@
+package endpoint
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestNewestSnapshot(t *testing.T) {
+	assert.Nil(t, newestSnapshot(nil))
+	assert.Nil(t, newestSnapshot([]zfs.FilesystemVersion{
+		{Type: zfs.Bookmark, Name: "b", CreateTXG: 10},
+	}))
+
+	newest := newestSnapshot([]zfs.FilesystemVersion{
+		{Type: zfs.Snapshot, Name: "a", CreateTXG: 5},
+		{Type: zfs.Bookmark, Name: "b", CreateTXG: 10},
+		{Type: zfs.Snapshot, Name: "c", CreateTXG: 7},
+		{Type: zfs.Snapshot, Name: "d", CreateTXG: 6},
+	})
+	require.NotNil(t, newest)
+	assert.Equal(t, "c", newest.Name)
+}
+
+// fakeZFSDestroyScript serves the snapshots of pool/fs and logs the arguments of
+// each `zfs destroy` to $FAKEZFS_DIR/destroy.
+// Snapshot b has an unparseable creation date, thus ZFSListFilesystemVersions skips it.
+const fakeZFSDestroyScript = `
+case "$1" in
+list)
+	if [ "$5" = "name" ]; then
+		printf 'pool/fs@a\npool/fs@b\npool/fs@c\npool/fs@d\npool/fs@e\n'
+	else
+		printf 'pool/fs@a\t1\t10\t1600000000\t0\n'
+		printf 'pool/fs@b\t2\t11\t-\t0\n'
+		printf 'pool/fs@c\t3\t12\t1600000002\t0\n'
+		printf 'pool/fs@d\t4\t13\t1600000003\t0\n'
+		printf 'pool/fs@e\t5\t14\t1600000004\t0\n'
+	fi
+	;;
+destroy)
+	if [ $# -eq 1 ]; then
+		# feature check
+		echo 'usage: destroy <filesystem|volume>@<snap>[%<snap>][,...]' >&2
+		exit 2
+	fi
+	echo "$2" >> "$FAKEZFS_DIR/destroy"
+	;;
+*)
+	exit 1
+	;;
+esac
+`
+
+func TestDoDestroySnapshotsDoesNotRangeOverUnparseableSnapshot(t *testing.T) {
+	dir, cleanup := withFakeZFS(t, fakeZFSDestroyScript)
+	defer cleanup()
+
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+
+	fs, err := zfs.NewDatasetPath("pool/fs")
+	require.NoError(t, err)
+	snap := func(name string) *pdu.FilesystemVersion {
+		return &pdu.FilesystemVersion{Type: pdu.FilesystemVersion_Snapshot, Name: name}
+	}
+	// a, c and d are consecutive among the parseable snapshots, but b lies between them
+	res, err := doDestroySnapshots(ctx, fs, []*pdu.FilesystemVersion{snap("a"), snap("c"), snap("d")}, "")
+	require.NoError(t, err)
+	for _, r := range res.Results {
+		assert.Empty(t, r.Error, r.Snapshot.Name)
+	}
+
+	destroyed, err := ioutil.ReadFile(filepath.Join(dir, "destroy"))
+	require.NoError(t, err)
+	assert.Equal(t, "pool/fs@a,c,d\n", string(destroyed))
+}
+
+func TestDoDestroySnapshotsRefusesNewestWithPrefix(t *testing.T) {
+	dir, cleanup := withFakeZFS(t, `
+case "$1" in
+list)
+	if [ "$5" = "name" ]; then
+		printf 'pool/fs@zrepl_1\npool/fs@zrepl_2\npool/fs@manual\n'
+	else
+		printf 'pool/fs@zrepl_1\t1\t10\t1600000000\t0\n'
+		printf 'pool/fs@zrepl_2\t2\t11\t1600000001\t0\n'
+		printf 'pool/fs@manual\t3\t12\t1600000002\t0\n'
+	fi
+	;;
+destroy)
+	if [ $# -eq 1 ]; then
+		echo 'usage: destroy <filesystem|volume>@<snap>[%<snap>][,...]' >&2
+		exit 2
+	fi
+	echo "$2" >> "$FAKEZFS_DIR/destroy"
+	;;
+*)
+	exit 1
+	;;
+esac
+`)
+	defer cleanup()
+
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+
+	fs, err := zfs.NewDatasetPath("pool/fs")
+	require.NoError(t, err)
+	snaps := []*pdu.FilesystemVersion{
+		{Type: pdu.FilesystemVersion_Snapshot, Name: "zrepl_1"},
+		{Type: pdu.FilesystemVersion_Snapshot, Name: "zrepl_2"},
+	}
+	// the manual snapshot is newer, but zrepl_2 is the newest with the job's prefix
+	res, err := doDestroySnapshots(ctx, fs, snaps, "zrepl_")
+	require.NoError(t, err)
+	require.Len(t, res.Results, 2)
+	assert.Empty(t, res.Results[0].Error)
+	assert.Contains(t, res.Results[1].Error, "refusing to destroy the newest snapshot")
+
+	destroyed, err := ioutil.ReadFile(filepath.Join(dir, "destroy"))
+	require.NoError(t, err)
+	assert.Equal(t, "pool/fs@zrepl_1\n", string(destroyed))
+}
@@ -0,0 +1,27 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// LastReplicatedPropertyName is the user property in which SendCompleted records
+// when a filesystem was last replicated and which snapshot was sent, see SenderConfig.LastReplicatedProperty.
+// It is set locally on every replicated filesystem. Children inherit it, so readers must only consider the local value.
+const LastReplicatedPropertyName = "zrepl:last_replicated"
+
+// lastReplicatedPropertyValue looks like `2020-09-14T10:26:30Z @zrepl_20200914_102630_000`.
+func lastReplicatedPropertyValue(to zfs.FilesystemVersion, now time.Time) string {
+	return fmt.Sprintf("%s %s", now.UTC().Format(time.RFC3339), to.RelName())
+}
+
+// setLastReplicatedProperty must only be called once the replication cursor has been moved to `to`.
+// A single `zfs set` replaces the previous value atomically.
+func setLastReplicatedProperty(ctx context.Context, fs *zfs.DatasetPath, to zfs.FilesystemVersion) error {
+	props := zfs.NewZFSProperties()
+	props.Set(LastReplicatedPropertyName, lastReplicatedPropertyValue(to, time.Now()))
+	return zfs.ZFSSet(ctx, fs, props)
+}
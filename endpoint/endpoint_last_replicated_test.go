@@ -0,0 +1,16 @@
+package endpoint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestLastReplicatedPropertyValue(t *testing.T) {
+	to := zfs.FilesystemVersion{Type: zfs.Snapshot, Name: "zrepl_20200914_102630_000"}
+	now := time.Date(2020, 9, 14, 12, 26, 45, 123, time.FixedZone("CEST", 2*60*60))
+	assert.Equal(t, "2020-09-14T10:26:45Z @zrepl_20200914_102630_000", lastReplicatedPropertyValue(to, now))
+}
@@ -4,4 +4,5 @@ import "github.com/prometheus/client_golang/prometheus"
 
 func RegisterMetrics(r prometheus.Registerer) {
 	r.MustRegister(sendAbstractionsCacheMetrics.count)
+	r.MustRegister(sendThrottleMetrics.rate)
 }
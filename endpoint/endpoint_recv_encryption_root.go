@@ -0,0 +1,121 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+type EncryptionRootAction int
+
+const (
+	// Make the received filesystem inherit the encryption root of its parent on the receiver (`zfs change-key -i`).
+	EncryptionRootInherit EncryptionRootAction = 1 + iota
+	// Make the received filesystem its own encryption root with the configured key (`zfs change-key -o keylocation=...`).
+	EncryptionRootOwn
+)
+
+func (a EncryptionRootAction) String() string {
+	switch a {
+	case EncryptionRootInherit:
+		return "inherit"
+	case EncryptionRootOwn:
+		return "own"
+	default:
+		return fmt.Sprintf("EncryptionRootAction(%d)", int(a))
+	}
+}
+
+// EncryptionRootPolicy is applied to encrypted filesystems after each successful receive.
+// It is a no-op for unencrypted filesystems and for filesystems that already are in the desired state.
+type EncryptionRootPolicy struct {
+	Action EncryptionRootAction
+	// Only for EncryptionRootOwn, required.
+	KeyLocation string
+	// Only for EncryptionRootOwn, optional, defaults to the current keyformat.
+	KeyFormat string
+}
+
+func (p EncryptionRootPolicy) Validate() error {
+	switch p.Action {
+	case EncryptionRootInherit:
+		if p.KeyLocation != "" || p.KeyFormat != "" {
+			return fmt.Errorf("action %s does not take a keylocation or keyformat", p.Action)
+		}
+	case EncryptionRootOwn:
+		if p.KeyLocation == "" {
+			return fmt.Errorf("action %s requires a keylocation", p.Action)
+		}
+		if p.KeyLocation == "prompt" {
+			return fmt.Errorf("keylocation must not be `prompt`")
+		}
+	default:
+		return fmt.Errorf("invalid action %s", p.Action)
+	}
+	return nil
+}
+
+// changeKeyOptions returns nil if fs is already in the state desired by p.
+func (p EncryptionRootPolicy) changeKeyOptions(fs string, state, parent *zfs.EncryptionState) (*zfs.ChangeKeyOptions, error) {
+	if !state.Encrypted {
+		return nil, nil
+	}
+	switch p.Action {
+	case EncryptionRootInherit:
+		if !state.IsEncryptionRoot(fs) {
+			return nil, nil
+		}
+		if parent == nil || !parent.Encrypted {
+			return nil, errors.New("parent filesystem is not encrypted, cannot inherit its encryption root")
+		}
+		return &zfs.ChangeKeyOptions{Inherit: true}, nil
+	case EncryptionRootOwn:
+		if state.IsEncryptionRoot(fs) && state.KeyLocation == p.KeyLocation {
+			return nil, nil
+		}
+		return &zfs.ChangeKeyOptions{KeyLocation: p.KeyLocation, KeyFormat: p.KeyFormat}, nil
+	default:
+		panic(p.Action)
+	}
+}
+
+// applyEncryptionRootPolicy changes the encryption root of fs as required by p.
+//
+// `zfs change-key` requires the keys to be loaded, which zrepl never does.
+// If they are not loaded, a warning is logged and the change is retried after the next receive.
+func applyEncryptionRootPolicy(ctx context.Context, fs string, p EncryptionRootPolicy) error {
+	log := getLogger(ctx).WithField("fs", fs).WithField("encryption_root_action", p.Action.String())
+
+	state, err := zfs.ZFSGetEncryptionState(ctx, fs)
+	if err != nil {
+		return err
+	}
+	var parent *zfs.EncryptionState
+	if p.Action == EncryptionRootInherit && state.IsEncryptionRoot(fs) {
+		if parentFS := path.Dir(fs); parentFS != "." {
+			if parent, err = zfs.ZFSGetEncryptionState(ctx, parentFS); err != nil {
+				return err
+			}
+		}
+	}
+
+	opts, err := p.changeKeyOptions(fs, state, parent)
+	if err != nil {
+		return err
+	}
+	if opts == nil {
+		log.Debug("encryption root is in desired state")
+		return nil
+	}
+	if !state.KeyAvailable() || (parent != nil && !parent.KeyAvailable()) {
+		log.Warn("cannot change encryption root because the keys are not loaded, will retry after the next receive")
+		return nil
+	}
+
+	log.WithField("opts", fmt.Sprintf("%#v", opts)).Info("change encryption root")
+	return zfs.ZFSChangeKey(ctx, fs, *opts)
+}
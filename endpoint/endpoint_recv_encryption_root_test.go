@@ -0,0 +1,59 @@
+package endpoint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestEncryptionRootPolicyValidate(t *testing.T) {
+	assert.NoError(t, EncryptionRootPolicy{Action: EncryptionRootInherit}.Validate())
+	assert.NoError(t, EncryptionRootPolicy{Action: EncryptionRootOwn, KeyLocation: "file:///etc/zrepl/key"}.Validate())
+	assert.NoError(t, EncryptionRootPolicy{Action: EncryptionRootOwn, KeyLocation: "file:///etc/zrepl/key", KeyFormat: "raw"}.Validate())
+
+	assert.Error(t, EncryptionRootPolicy{}.Validate())
+	assert.Error(t, EncryptionRootPolicy{Action: EncryptionRootInherit, KeyLocation: "file:///etc/zrepl/key"}.Validate())
+	assert.Error(t, EncryptionRootPolicy{Action: EncryptionRootOwn}.Validate())
+	assert.Error(t, EncryptionRootPolicy{Action: EncryptionRootOwn, KeyLocation: "prompt"}.Validate())
+}
+
+func TestEncryptionRootPolicyChangeKeyOptions(t *testing.T) {
+	const fs = "backup/host/data"
+	unencrypted := &zfs.EncryptionState{}
+	ownRoot := &zfs.EncryptionState{Encrypted: true, EncryptionRoot: fs, KeyStatus: "available", KeyLocation: "prompt"}
+	inheriting := &zfs.EncryptionState{Encrypted: true, EncryptionRoot: "backup/host", KeyStatus: "available"}
+
+	inherit := EncryptionRootPolicy{Action: EncryptionRootInherit}
+	own := EncryptionRootPolicy{Action: EncryptionRootOwn, KeyLocation: "file:///etc/zrepl/key"}
+
+	opts, err := inherit.changeKeyOptions(fs, unencrypted, nil)
+	require.NoError(t, err)
+	assert.Nil(t, opts)
+	opts, err = own.changeKeyOptions(fs, unencrypted, nil)
+	require.NoError(t, err)
+	assert.Nil(t, opts)
+
+	opts, err = inherit.changeKeyOptions(fs, ownRoot, inheriting)
+	require.NoError(t, err)
+	assert.Equal(t, &zfs.ChangeKeyOptions{Inherit: true}, opts)
+	_, err = inherit.changeKeyOptions(fs, ownRoot, unencrypted)
+	assert.Error(t, err, "parent not encrypted")
+	opts, err = inherit.changeKeyOptions(fs, inheriting, nil)
+	require.NoError(t, err)
+	assert.Nil(t, opts, "already inherits")
+
+	opts, err = own.changeKeyOptions(fs, ownRoot, nil)
+	require.NoError(t, err)
+	assert.Equal(t, &zfs.ChangeKeyOptions{KeyLocation: own.KeyLocation}, opts, "keylocation differs")
+	opts, err = own.changeKeyOptions(fs, inheriting, nil)
+	require.NoError(t, err)
+	assert.Equal(t, &zfs.ChangeKeyOptions{KeyLocation: own.KeyLocation}, opts)
+	rekeyed := *ownRoot
+	rekeyed.KeyLocation = own.KeyLocation
+	opts, err = own.changeKeyOptions(fs, &rekeyed, nil)
+	require.NoError(t, err)
+	assert.Nil(t, opts, "already rekeyed")
+}
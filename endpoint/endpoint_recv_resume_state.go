@@ -0,0 +1,103 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+type StaleResumeStateAction int
+
+const (
+	// Keep the resume state so that the next replication attempt resumes it.
+	StaleResumeStateResume StaleResumeStateAction = 1 + iota
+	// Abort the interrupted receive using `zfs recv -A`.
+	StaleResumeStateAbort
+)
+
+func (a StaleResumeStateAction) String() string {
+	switch a {
+	case StaleResumeStateResume:
+		return "resume"
+	case StaleResumeStateAbort:
+		return "abort"
+	default:
+		return fmt.Sprintf("StaleResumeStateAction(%d)", int(a))
+	}
+}
+
+type StaleResumeStatePolicy struct {
+	Action StaleResumeStateAction
+	// Only resume states whose partially received dataset was created longer than OlderThan ago are considered stale.
+	OlderThan time.Duration
+}
+
+func (p StaleResumeStatePolicy) Validate() error {
+	if p.Action != StaleResumeStateResume && p.Action != StaleResumeStateAbort {
+		return fmt.Errorf("invalid action %s", p.Action)
+	}
+	if p.OlderThan < 0 {
+		return fmt.Errorf("`OlderThan` must not be negative")
+	}
+	return nil
+}
+
+// isStale returns true if a resume state whose partially received dataset was created at created is stale at now.
+func (p StaleResumeStatePolicy) isStale(created, now time.Time) bool {
+	return now.Sub(created) >= p.OlderThan
+}
+
+// CleanupStaleResumeStates applies policy to all filesystems below root that have a receive resume token.
+//
+// Errors for individual filesystems are logged and do not stop the cleanup of the remaining filesystems.
+func CleanupStaleResumeStates(ctx context.Context, root *zfs.DatasetPath, policy StaleResumeStatePolicy) error {
+	if err := policy.Validate(); err != nil {
+		return errors.Wrap(err, "invalid stale resume state policy")
+	}
+
+	fss, err := zfs.ZFSListMapping(ctx, subroot{localRoot: root})
+	if err != nil {
+		return errors.Wrap(err, "cannot list filesystems")
+	}
+	for _, fs := range fss {
+		l := getLogger(ctx).WithField("fs", fs.ToString())
+
+		token, err := zfs.ZFSGetReceiveResumeTokenOrEmptyStringIfNotSupported(ctx, fs)
+		if err != nil {
+			l.WithError(err).Error("cannot get receive resume token")
+			continue
+		}
+		if token == "" {
+			continue
+		}
+
+		created, err := zfs.ZFSGetReceiveResumeStateCreation(ctx, fs)
+		if err != nil {
+			l.WithError(err).Error("cannot determine age of receive resume state")
+			continue
+		}
+		now := time.Now()
+		l = l.WithField("resume_state_age", now.Sub(created).Round(time.Second).String())
+		if !policy.isStale(created, now) {
+			l.Debug("receive resume state is not stale")
+			continue
+		}
+
+		switch policy.Action {
+		case StaleResumeStateResume:
+			l.Info("keeping stale receive resume state, next replication attempt will resume it")
+		case StaleResumeStateAbort:
+			l.Info("aborting stale receive resume state")
+			if err := zfs.ZFSRecvAbort(ctx, fs); err != nil {
+				l.WithError(err).Error("cannot abort stale receive resume state")
+				continue
+			}
+			l.Info("aborted stale receive resume state")
+		}
+	}
+	return nil
+}
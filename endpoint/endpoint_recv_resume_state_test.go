@@ -0,0 +1,25 @@
+package endpoint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaleResumeStatePolicy(t *testing.T) {
+	assert.NoError(t, StaleResumeStatePolicy{Action: StaleResumeStateAbort, OlderThan: time.Hour}.Validate())
+	assert.NoError(t, StaleResumeStatePolicy{Action: StaleResumeStateResume}.Validate())
+	assert.Error(t, StaleResumeStatePolicy{OlderThan: time.Hour}.Validate(), "action must be set")
+	assert.Error(t, StaleResumeStatePolicy{Action: StaleResumeStateAbort, OlderThan: -time.Second}.Validate())
+
+	now := time.Unix(100000, 0)
+	p := StaleResumeStatePolicy{Action: StaleResumeStateAbort, OlderThan: time.Hour}
+	assert.False(t, p.isStale(now, now))
+	assert.False(t, p.isStale(now.Add(-59*time.Minute), now))
+	assert.True(t, p.isStale(now.Add(-time.Hour), now))
+	assert.True(t, p.isStale(now.Add(-48*time.Hour), now))
+
+	p.OlderThan = 0
+	assert.True(t, p.isStale(now, now), "every resume state is stale without older_than")
+}
@@ -0,0 +1,81 @@
+package endpoint
+
+import (
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/zrepl/zrepl/util/bytecounter"
+)
+
+// SendProgress reports how much of a send stream returned by Sender.Send has been read.
+type SendProgress struct {
+	Filesystem string
+	// To is the relative name of the sent version, e.g. "@snap"
+	To        string
+	BytesSent int64
+	// ExpectedSize is 0 if zfs could not estimate the size of the send
+	ExpectedSize int64
+}
+
+// ActiveSendsProgress returns the progress of the send streams of jobID that are not closed yet,
+// sorted by filesystem.
+func ActiveSendsProgress(jobID JobID) []SendProgress {
+	activeSends.mtx.Lock()
+	defer activeSends.mtx.Unlock()
+	var res []SendProgress
+	for s := range activeSends.streams {
+		if s.key.jobID != jobID {
+			continue
+		}
+		res = append(res, SendProgress{
+			Filesystem:   s.key.fs,
+			To:           s.to,
+			BytesSent:    s.Count(),
+			ExpectedSize: s.expectedSize,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Filesystem != res[j].Filesystem {
+			return res[i].Filesystem < res[j].Filesystem
+		}
+		return res[i].To < res[j].To
+	})
+	return res
+}
+
+// sendStreamEndingSend counts the bytes read from a send stream for ActiveSendsProgress
+// and ends the active send when the send stream is closed.
+type sendStreamEndingSend struct {
+	bytecounter.ReadCloser
+	key          activeSendKey
+	to           string
+	expectedSize int64
+	endOnce      sync.Once
+	endSend      func()
+}
+
+func newSendStreamEndingSend(key activeSendKey, to string, expectedSize int64, stream io.ReadCloser, endSend func()) *sendStreamEndingSend {
+	s := &sendStreamEndingSend{
+		ReadCloser:   bytecounter.NewReadCloser(stream),
+		key:          key,
+		to:           to,
+		expectedSize: expectedSize,
+		endSend:      endSend,
+	}
+	activeSends.mtx.Lock()
+	defer activeSends.mtx.Unlock()
+	activeSends.streams[s] = true
+	return s
+}
+
+func (s *sendStreamEndingSend) Close() error {
+	err := s.ReadCloser.Close()
+	s.endOnce.Do(func() {
+		activeSends.mtx.Lock()
+		delete(activeSends.streams, s)
+		activeSends.mtx.Unlock()
+		s.endSend()
+	})
+	return err
+}
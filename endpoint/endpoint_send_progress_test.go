@@ -0,0 +1,42 @@
+package endpoint
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/util/bandwidthlimit"
+)
+
+func TestActiveSendsProgress(t *testing.T) {
+	jobA, jobB := MustMakeJobID("a"), MustMakeJobID("b")
+	data := []byte("stream")
+
+	// the bytes are counted no matter how the stream is wrapped, e.g., throttled
+	throttled := bandwidthlimit.New(0).Reader(context.Background(), ioutil.NopCloser(bytes.NewReader(data)))
+	ended := false
+	s := newSendStreamEndingSend(activeSendKey{jobA, "pool/b"}, "@2", 100, throttled, func() { ended = true })
+	other := newSendStreamEndingSend(activeSendKey{jobA, "pool/a"}, "@1", 0, ioutil.NopCloser(bytes.NewReader(data)), func() {})
+	defer other.Close()
+	otherJob := newSendStreamEndingSend(activeSendKey{jobB, "pool/a"}, "@1", 0, ioutil.NopCloser(bytes.NewReader(data)), func() {})
+	defer otherJob.Close()
+
+	assert.Equal(t, []SendProgress{
+		{Filesystem: "pool/a", To: "@1"},
+		{Filesystem: "pool/b", To: "@2", ExpectedSize: 100},
+	}, ActiveSendsProgress(jobA))
+
+	read, err := ioutil.ReadAll(s)
+	require.NoError(t, err)
+	assert.Equal(t, data, read)
+	assert.Equal(t, SendProgress{Filesystem: "pool/b", To: "@2", BytesSent: int64(len(data)), ExpectedSize: 100}, ActiveSendsProgress(jobA)[1])
+
+	require.NoError(t, s.Close())
+	assert.True(t, ended)
+	assert.Equal(t, []SendProgress{{Filesystem: "pool/a", To: "@1"}}, ActiveSendsProgress(jobA))
+	require.NoError(t, s.Close(), "closing twice must not end the send twice")
+}
@@ -0,0 +1,201 @@
+package endpoint
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// SendTeeManifestSuffix is appended to the name of a send stream tee file
+// to get the name of its manifest file.
+const SendTeeManifestSuffix = ".manifest.json"
+
+// SendTeeManifest describes the content of a send stream tee file.
+// It is written next to the tee file once the tee file has been closed.
+type SendTeeManifest struct {
+	Filesystem string
+	From       string `json:",omitempty"` // empty for full sends
+	To         string
+	Codec      string
+	Level      int `json:",omitempty"`
+	// Size and SHA256 refer to the uncompressed send stream
+	// so that they remain valid if the file is re-compressed.
+	Size   int64
+	SHA256 string
+}
+
+// sendTeeFile writes a (compressed) copy of the send stream to a file and its manifest on Close.
+type sendTeeFile struct {
+	path     string
+	file     *os.File
+	comp     io.WriteCloser
+	hash     hash.Hash
+	manifest SendTeeManifest
+}
+
+var _ io.WriteCloser = (*sendTeeFile)(nil)
+
+// createSendTeeFile creates a new file in dir that receives a copy of the send stream described by sendArgs.
+//
+// The file name encodes filesystem, `from` and `to` version and the creation time.
+// The creation time makes sure that resumed sends, which only carry the remainder of the stream,
+// do not overwrite the copy of the interrupted attempt.
+func createSendTeeFile(dir string, sendArgs zfs.ZFSSendArgsValidated, compression TeeCompression) (*sendTeeFile, error) {
+	codecName := compression.codecName()
+	codec := teeCodecs[codecName]
+
+	var from string
+	if sendArgs.FromVersion != nil {
+		from = sendArgs.FromVersion.RelName()
+	}
+	name := fmt.Sprintf("%s_%s_%s_%s.zfsstream%s",
+		strings.Replace(sendArgs.FS, "/", "_", -1),
+		from,
+		sendArgs.ToVersion.RelName(),
+		time.Now().UTC().Format("20060102_150405.000000000"),
+		codec.ext,
+	)
+	path := filepath.Join(dir, name)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	comp, err := codec.newWriter(file, compression.Level)
+	if err != nil {
+		file.Close()
+		return nil, errors.Wrapf(err, "cannot create %s writer", codecName)
+	}
+	return &sendTeeFile{
+		path: path,
+		file: file,
+		comp: comp,
+		hash: sha256.New(),
+		manifest: SendTeeManifest{
+			Filesystem: sendArgs.FS,
+			From:       from,
+			To:         sendArgs.ToVersion.RelName(),
+			Codec:      codecName,
+			Level:      compression.Level,
+		},
+	}, nil
+}
+
+func (f *sendTeeFile) Write(p []byte) (int, error) {
+	n, err := f.comp.Write(p)
+	f.hash.Write(p[:n])
+	f.manifest.Size += int64(n)
+	return n, err
+}
+
+func (f *sendTeeFile) Close() error {
+	compErr := f.comp.Close()
+	fileErr := f.file.Close()
+	if compErr != nil {
+		return compErr
+	}
+	if fileErr != nil {
+		return fileErr
+	}
+
+	f.manifest.SHA256 = hex.EncodeToString(f.hash.Sum(nil))
+	m, err := json.MarshalIndent(f.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := f.path + SendTeeManifestSuffix + ".tmp"
+	if err := ioutil.WriteFile(tmp, m, 0600); err != nil {
+		return errors.Wrap(err, "write manifest")
+	}
+	return errors.Wrap(os.Rename(tmp, f.path+SendTeeManifestSuffix), "write manifest")
+}
+
+// OpenSendTeeFile opens a file written by the tee send option and its manifest.
+//
+// Reading from the returned stream transparently decompresses the file.
+// Once the end of the stream is reached, size and checksum are checked against the manifest;
+// on mismatch, an error is returned instead of io.EOF.
+func OpenSendTeeFile(path string) (io.ReadCloser, *SendTeeManifest, error) {
+	m, err := ioutil.ReadFile(path + SendTeeManifestSuffix)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "read manifest")
+	}
+	var manifest SendTeeManifest
+	dec := json.NewDecoder(bytes.NewReader(m))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&manifest); err != nil {
+		return nil, nil, errors.Wrap(err, "decode manifest")
+	}
+	codec, ok := teeCodecs[manifest.Codec]
+	if !ok {
+		return nil, nil, errors.Errorf("manifest: unknown codec %q", manifest.Codec)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	decomp, err := codec.newReader(file)
+	if err != nil {
+		file.Close()
+		return nil, nil, errors.Wrapf(err, "cannot create %s reader", manifest.Codec)
+	}
+	return &sendTeeFileReader{file: file, decomp: decomp, hash: sha256.New(), manifest: &manifest}, &manifest, nil
+}
+
+// VerifySendTeeFile reads the file written by the tee send option at path
+// and checks its size and checksum against the manifest.
+func VerifySendTeeFile(path string) (*SendTeeManifest, error) {
+	r, manifest, err := OpenSendTeeFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if _, err := io.Copy(ioutil.Discard, r); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+type sendTeeFileReader struct {
+	file     *os.File
+	decomp   io.ReadCloser
+	hash     hash.Hash
+	size     int64
+	manifest *SendTeeManifest
+}
+
+func (r *sendTeeFileReader) Read(p []byte) (int, error) {
+	n, err := r.decomp.Read(p)
+	r.hash.Write(p[:n])
+	r.size += int64(n)
+	if err == io.EOF {
+		if r.size != r.manifest.Size {
+			return n, errors.Errorf("send stream size %d does not match manifest size %d", r.size, r.manifest.Size)
+		}
+		if sum := hex.EncodeToString(r.hash.Sum(nil)); sum != r.manifest.SHA256 {
+			return n, errors.Errorf("send stream checksum %s does not match manifest checksum %s", sum, r.manifest.SHA256)
+		}
+	}
+	return n, err
+}
+
+func (r *sendTeeFileReader) Close() error {
+	decompErr := r.decomp.Close()
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	return decompErr
+}
@@ -0,0 +1,89 @@
+package endpoint
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// TeeCompression configures the compression of send stream tee files.
+// It is independent of the compression of the send stream itself (zfs send -c).
+type TeeCompression struct {
+	Codec string // empty means no compression
+	Level int    // 0 means the codec's default level
+}
+
+func (c TeeCompression) codecName() string {
+	if c.Codec == "" {
+		return "none"
+	}
+	return c.Codec
+}
+
+// unsupportedTeeCodecs are rejected with a dedicated error because users are likely to try them:
+// zrepl does not depend on a zstd or lz4 implementation.
+var unsupportedTeeCodecs = map[string]bool{
+	"zstd": true,
+	"lz4":  true,
+}
+
+func (c TeeCompression) Validate() error {
+	if unsupportedTeeCodecs[c.codecName()] {
+		return fmt.Errorf("codec %q is not supported for tee files, use \"gzip\" instead", c.Codec)
+	}
+	codec, ok := teeCodecs[c.codecName()]
+	if !ok {
+		names := make([]string, 0, len(teeCodecs))
+		for n := range teeCodecs {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unsupported codec %q, supported codecs: %s", c.Codec, strings.Join(names, ", "))
+	}
+	if _, err := codec.newWriter(ioutil.Discard, c.Level); err != nil {
+		return fmt.Errorf("invalid level for codec %q: %s", c.codecName(), err)
+	}
+	return nil
+}
+
+type teeCodec struct {
+	ext       string // file name extension
+	newWriter func(w io.Writer, level int) (io.WriteCloser, error)
+	newReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+var teeCodecs = map[string]teeCodec{
+	"none": {
+		ext: "",
+		newWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+			if level != 0 {
+				return nil, fmt.Errorf("level must not be set")
+			}
+			return nopWriteCloser{w}, nil
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			return ioutil.NopCloser(r), nil
+		},
+	},
+	"gzip": {
+		ext: ".gz",
+		newWriter: func(w io.Writer, level int) (io.WriteCloser, error) {
+			if level == 0 {
+				level = gzip.DefaultCompression
+			} else if level < gzip.BestSpeed || level > gzip.BestCompression {
+				return nil, fmt.Errorf("level must be in [%d, %d]", gzip.BestSpeed, gzip.BestCompression)
+			}
+			return gzip.NewWriterLevel(w, level)
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
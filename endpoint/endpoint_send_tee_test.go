@@ -0,0 +1,81 @@
+package endpoint
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestSendTeeFileRoundtrip(t *testing.T) {
+	stream := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(stream)
+
+	sendArgs := zfs.ZFSSendArgsValidated{
+		ZFSSendArgsUnvalidated: zfs.ZFSSendArgsUnvalidated{FS: "pool/ds"},
+		ToVersion:              zfs.FilesystemVersion{Type: zfs.Snapshot, Name: "to"},
+	}
+
+	for _, compression := range []TeeCompression{{}, {Codec: "gzip"}, {Codec: "gzip", Level: 1}} {
+		t.Run(compression.codecName(), func(t *testing.T) {
+			require.NoError(t, compression.Validate())
+
+			dir, err := ioutil.TempDir("", "zrepl-send-tee")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+
+			w, err := createSendTeeFile(dir, sendArgs, compression)
+			require.NoError(t, err)
+			_, err = w.Write(stream)
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+
+			r, manifest, err := OpenSendTeeFile(w.path)
+			require.NoError(t, err)
+			assert.Equal(t, compression.codecName(), manifest.Codec)
+			assert.Equal(t, "pool/ds", manifest.Filesystem)
+			assert.Equal(t, "@to", manifest.To)
+			assert.Equal(t, int64(len(stream)), manifest.Size)
+			read, err := ioutil.ReadAll(r)
+			require.NoError(t, err)
+			require.NoError(t, r.Close())
+			assert.True(t, bytes.Equal(stream, read))
+			verified, err := VerifySendTeeFile(w.path)
+			require.NoError(t, err)
+			assert.Equal(t, manifest, verified)
+
+			// checksum mismatch is detected at the end of the stream
+			corrupt, err := os.OpenFile(w.path+SendTeeManifestSuffix, os.O_WRONLY|os.O_TRUNC, 0600)
+			require.NoError(t, err)
+			_, err = corrupt.WriteString(`{"Filesystem":"pool/ds","To":"@to","Codec":"` + manifest.Codec + `","Size":1048576,"SHA256":"0000"}`)
+			require.NoError(t, err)
+			require.NoError(t, corrupt.Close())
+			r, _, err = OpenSendTeeFile(w.path)
+			require.NoError(t, err)
+			_, err = ioutil.ReadAll(r)
+			assert.Error(t, err)
+			r.Close()
+			_, err = VerifySendTeeFile(w.path)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestTeeCompressionValidate(t *testing.T) {
+	assert.NoError(t, TeeCompression{}.Validate())
+	assert.NoError(t, TeeCompression{Codec: "gzip", Level: 9}.Validate())
+	assert.Error(t, TeeCompression{Codec: "gzip", Level: 10}.Validate())
+	assert.Error(t, TeeCompression{Level: 1}.Validate())
+	assert.Error(t, TeeCompression{Codec: "bogus"}.Validate())
+	for _, codec := range []string{"zstd", "lz4"} {
+		err := TeeCompression{Codec: codec}.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not supported")
+	}
+}
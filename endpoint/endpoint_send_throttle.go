@@ -0,0 +1,174 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zrepl/zrepl/daemon/logging"
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+	"github.com/zrepl/zrepl/util/bandwidthlimit"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// PoolIOThrottleConfig configures a bandwidth limit for send streams that adapts to the IO of the sending pool.
+//
+// The limit applies to all send streams of the job from the same pool together.
+// Every Interval, the pool's IO is sampled using `zpool iostat`.
+// The IO that is not caused by our send streams is considered production IO:
+// if it is at least BusyThreshold, the limit is halved, down to MinRate;
+// if it is at most IdleThreshold, the limit is raised by a tenth of the difference between MaxRate and MinRate, up to MaxRate.
+// Sends of a pool start at MaxRate.
+//
+// All rates and thresholds are in bytes per second.
+type PoolIOThrottleConfig struct {
+	MaxRate, MinRate             int64
+	IdleThreshold, BusyThreshold int64
+	Interval                     time.Duration
+}
+
+func (c *PoolIOThrottleConfig) Validate() error {
+	if c.MinRate < bandwidthlimit.MinRate {
+		return fmt.Errorf("`MinRate` must be at least %d", bandwidthlimit.MinRate)
+	}
+	if c.MaxRate < c.MinRate {
+		return fmt.Errorf("`MaxRate` must be at least `MinRate`")
+	}
+	if c.IdleThreshold < 0 || c.BusyThreshold <= c.IdleThreshold {
+		return fmt.Errorf("`BusyThreshold` must be greater than `IdleThreshold`, which must not be negative")
+	}
+	if c.Interval < time.Second {
+		return fmt.Errorf("`Interval` must be at least one second")
+	}
+	return nil
+}
+
+// adapt returns the rate that follows rate if the pool's production IO is production bytes per second.
+func (c *PoolIOThrottleConfig) adapt(rate int64, production int64) int64 {
+	switch {
+	case production >= c.BusyThreshold:
+		rate /= 2
+	case production <= c.IdleThreshold:
+		step := (c.MaxRate - c.MinRate) / 10
+		if step < 1 {
+			step = 1
+		}
+		rate += step
+	}
+	if rate < c.MinRate {
+		rate = c.MinRate
+	}
+	if rate > c.MaxRate {
+		rate = c.MaxRate
+	}
+	return rate
+}
+
+var sendThrottleMetrics struct {
+	rate *prometheus.GaugeVec
+}
+
+func init() {
+	sendThrottleMetrics.rate = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "zrepl",
+		Subsystem: "endpoint",
+		Name:      "send_throttle_rate_bytes",
+		Help:      "current bandwidth limit in bytes per second of the send streams of a job from a pool, adapted to the pool's IO",
+	}, []string{"zrepl_job", "pool"})
+}
+
+type poolIOThrottle struct {
+	config PoolIOThrottleConfig
+	jobID  JobID
+	sample func(ctx context.Context, pools []string, interval time.Duration) (map[string]zfs.ZpoolIOStat, error)
+
+	mtx   sync.Mutex
+	pools map[string]*throttledPool
+}
+
+// throttledPool exists while there are send streams from the pool.
+type throttledPool struct {
+	limiter *bandwidthlimit.Limiter
+	streams int
+	stop    context.CancelFunc
+}
+
+func newPoolIOThrottle(config PoolIOThrottleConfig, jobID JobID) *poolIOThrottle {
+	return &poolIOThrottle{
+		config: config,
+		jobID:  jobID,
+		sample: zfs.ZpoolIOStatSample,
+		pools:  make(map[string]*throttledPool),
+	}
+}
+
+// limit returns stream limited by the throttle of pool and a function that must be called once the stream is closed.
+func (t *poolIOThrottle) limit(ctx context.Context, pool string, stream io.ReadCloser) (_ io.ReadCloser, done func()) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	p, ok := t.pools[pool]
+	if !ok {
+		// the control loop outlives the Send RPC's context, it is stopped when the last stream of the pool is done
+		controlCtx, stop := context.WithCancel(logging.WithInherit(context.Background(), ctx))
+		controlCtx, endTask := trace.WithTask(controlCtx, "send-throttle")
+		p = &throttledPool{limiter: bandwidthlimit.New(t.config.MaxRate), stop: stop}
+		t.pools[pool] = p
+		go func() {
+			defer endTask()
+			t.control(controlCtx, pool, p.limiter)
+		}()
+	}
+	p.streams++
+	return p.limiter.Reader(ctx, stream), func() { t.done(pool) }
+}
+
+func (t *poolIOThrottle) done(pool string) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	p := t.pools[pool]
+	p.streams--
+	if p.streams > 0 {
+		return
+	}
+	p.stop()
+	delete(t.pools, pool)
+	sendThrottleMetrics.rate.DeleteLabelValues(t.jobID.String(), pool)
+}
+
+func (t *poolIOThrottle) control(ctx context.Context, pool string, limiter *bandwidthlimit.Limiter) {
+	log := getLogger(ctx).WithField("pool", pool)
+
+	rate := sendThrottleMetrics.rate.WithLabelValues(t.jobID.String(), pool)
+	rate.Set(float64(limiter.Rate()))
+	for {
+		before, begin := limiter.Bytes(), time.Now()
+		stats, err := t.sample(ctx, []string{pool}, t.config.Interval)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.WithError(err).Warn("cannot sample pool IO, keeping current send bandwidth limit")
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(t.config.Interval):
+			}
+			continue
+		}
+		own := float64(limiter.Bytes()-before) / time.Since(begin).Seconds()
+		production := int64(float64(stats[pool].Bytes()) - own)
+		if production < 0 {
+			production = 0
+		}
+		next := t.config.adapt(limiter.Rate(), production)
+		if next != limiter.Rate() {
+			log.WithField("production_io", production).WithField("rate", next).Debug("adapt send bandwidth limit")
+			limiter.SetRate(next)
+		}
+		rate.Set(float64(next))
+	}
+}
@@ -0,0 +1,70 @@
+package endpoint
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestPoolIOThrottleConfigAdapt(t *testing.T) {
+	c := PoolIOThrottleConfig{
+		MaxRate:       10 << 20,
+		MinRate:       1 << 20,
+		IdleThreshold: 50,
+		BusyThreshold: 500,
+		Interval:      time.Second,
+	}
+	require.NoError(t, c.Validate())
+
+	step := int64(9<<20) / 10
+	assert.Equal(t, int64(5<<20), c.adapt(10<<20, 500))
+	assert.Equal(t, int64(1<<20), c.adapt(3<<19, 10000))
+	assert.Equal(t, 5<<20+step, c.adapt(5<<20, 50))
+	assert.Equal(t, int64(10<<20), c.adapt(10<<20-1, 0))
+	assert.Equal(t, int64(5<<20), c.adapt(5<<20, 200), "between the thresholds, the rate is kept")
+
+	invalid := c
+	invalid.BusyThreshold = invalid.IdleThreshold
+	assert.Error(t, invalid.Validate())
+	invalid = c
+	invalid.MaxRate = 10
+	assert.Error(t, invalid.Validate())
+	invalid = c
+	invalid.MinRate = 1
+	assert.Error(t, invalid.Validate(), "sleeps would be too long")
+}
+
+func TestPoolIOThrottleStreamsSharePool(t *testing.T) {
+	c := PoolIOThrottleConfig{MaxRate: 1 << 30, MinRate: 1 << 20, IdleThreshold: 0, BusyThreshold: 1, Interval: time.Hour}
+	th := newPoolIOThrottle(c, MustMakeJobID("foo"))
+	th.sample = func(ctx context.Context, pools []string, interval time.Duration) (map[string]zfs.ZpoolIOStat, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	ctx := context.Background()
+
+	stream := func() *bytes.Reader { return bytes.NewReader([]byte("stream")) }
+	s1, done1 := th.limit(ctx, "tank", ioutil.NopCloser(stream()))
+	_, done2 := th.limit(ctx, "tank", ioutil.NopCloser(stream()))
+	_, done3 := th.limit(ctx, "backup", ioutil.NopCloser(stream()))
+	assert.Len(t, th.pools, 2)
+	assert.Equal(t, 2, th.pools["tank"].streams)
+
+	read, err := ioutil.ReadAll(s1)
+	require.NoError(t, err)
+	assert.Equal(t, "stream", string(read))
+	assert.Equal(t, int64(len(read)), th.pools["tank"].limiter.Bytes())
+
+	done1()
+	done3()
+	assert.Len(t, th.pools, 1)
+	done2()
+	assert.Empty(t, th.pools)
+}
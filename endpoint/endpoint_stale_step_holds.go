@@ -0,0 +1,124 @@
+package endpoint
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+type activeSendKey struct {
+	jobID JobID
+	fs    string
+}
+
+// activeSends tracks the (job, filesystem) pairs for which Sender.Send is in progress,
+// from before the step holds are taken until the send stream is closed.
+// ReleaseStaleStepHolds uses it to never release the step holds of a running send.
+var activeSends struct {
+	mtx      sync.Mutex
+	cond     *sync.Cond // signaled when a cleanup ends
+	sends    map[activeSendKey]int
+	cleaning map[activeSendKey]bool
+	streams  map[*sendStreamEndingSend]bool // the streams returned by Sender.Send that are not closed yet
+}
+
+func init() {
+	activeSends.cond = sync.NewCond(&activeSends.mtx)
+	activeSends.sends = make(map[activeSendKey]int)
+	activeSends.cleaning = make(map[activeSendKey]bool)
+	activeSends.streams = make(map[*sendStreamEndingSend]bool)
+}
+
+// beginSend marks a send as active, waiting for a step hold cleanup of the same (job, filesystem) pair to finish.
+// The returned func must be called exactly once when the send has ended.
+func beginSend(jobID JobID, fs string) (end func()) {
+	k := activeSendKey{jobID, fs}
+	activeSends.mtx.Lock()
+	defer activeSends.mtx.Unlock()
+	for activeSends.cleaning[k] {
+		activeSends.cond.Wait()
+	}
+	activeSends.sends[k]++
+	return func() {
+		activeSends.mtx.Lock()
+		defer activeSends.mtx.Unlock()
+		activeSends.sends[k]--
+		if activeSends.sends[k] == 0 {
+			delete(activeSends.sends, k)
+		}
+	}
+}
+
+// tryBeginStepHoldCleanup returns ok=false if a send is active for the (job, filesystem) pair.
+// Otherwise, sends for the pair block until the returned func is called.
+func tryBeginStepHoldCleanup(jobID JobID, fs string) (end func(), ok bool) {
+	k := activeSendKey{jobID, fs}
+	activeSends.mtx.Lock()
+	defer activeSends.mtx.Unlock()
+	if activeSends.sends[k] > 0 || activeSends.cleaning[k] {
+		return nil, false
+	}
+	activeSends.cleaning[k] = true
+	return func() {
+		activeSends.mtx.Lock()
+		defer activeSends.mtx.Unlock()
+		delete(activeSends.cleaning, k)
+		activeSends.cond.Broadcast()
+	}, true
+}
+
+// ReleaseStaleStepHolds releases the step holds of jobID on the filesystems matched by fsf
+// that are not associated with a send that is currently in progress.
+//
+// Step holds make interrupted sends resumable and are usually released by the next send of the filesystem.
+// After an ungraceful shutdown, they can linger and prevent pruning of the held snapshots.
+// Releasing them means that interrupted sends cannot be resumed if the held snapshots are pruned in the meantime.
+//
+// Errors for individual holds are logged and do not stop the cleanup of the remaining holds.
+func ReleaseStaleStepHolds(ctx context.Context, fsf zfs.DatasetFilter, jobID JobID) error {
+	q := ListZFSHoldsAndBookmarksQuery{
+		FS:          ListZFSHoldsAndBookmarksQueryFilesystemFilter{Filter: fsf},
+		What:        AbstractionTypeSet{AbstractionStepHold: true},
+		JobID:       &jobID,
+		Concurrency: 1,
+	}
+	abs, listErrs, err := ListAbstractions(ctx, q)
+	if err != nil {
+		return errors.Wrap(err, "list step holds")
+	}
+	for _, e := range listErrs {
+		getLogger(ctx).WithError(e).Error("cannot list step holds")
+	}
+
+	byFS := make(map[string][]Abstraction)
+	var fss []string
+	for _, a := range abs {
+		if _, ok := byFS[a.GetFS()]; !ok {
+			fss = append(fss, a.GetFS())
+		}
+		byFS[a.GetFS()] = append(byFS[a.GetFS()], a)
+	}
+
+	for _, fs := range fss {
+		l := getLogger(ctx).WithField("fs", fs)
+		endCleanup, ok := tryBeginStepHoldCleanup(jobID, fs)
+		if !ok {
+			l.Info("not releasing step holds, send in progress")
+			continue
+		}
+		for _, a := range byFS[fs] {
+			l := l.WithField("hold", a.String())
+			if err := a.Destroy(ctx); err != nil {
+				l.WithError(err).Error("cannot release stale step hold")
+				continue
+			}
+			l.Info("released stale step hold")
+		}
+		SendAbstractionsCacheInvalidate(fs)
+		endCleanup()
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package endpoint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStepHoldCleanupDoesNotRaceWithSend(t *testing.T) {
+	jobA, jobB := MustMakeJobID("a"), MustMakeJobID("b")
+
+	endSend := beginSend(jobA, "pool/fs")
+	_, ok := tryBeginStepHoldCleanup(jobA, "pool/fs")
+	assert.False(t, ok, "cleanup must not start while a send is active")
+	endOther, ok := tryBeginStepHoldCleanup(jobB, "pool/fs")
+	require.True(t, ok, "sends of other jobs are unrelated")
+	endOther()
+	endSend()
+
+	endCleanup, ok := tryBeginStepHoldCleanup(jobA, "pool/fs")
+	require.True(t, ok)
+	_, ok = tryBeginStepHoldCleanup(jobA, "pool/fs")
+	assert.False(t, ok, "only one cleanup at a time")
+
+	sendStarted := make(chan struct{})
+	go func() {
+		end := beginSend(jobA, "pool/fs")
+		close(sendStarted)
+		end()
+	}()
+	select {
+	case <-sendStarted:
+		t.Fatal("send must wait for cleanup to end")
+	case <-time.After(50 * time.Millisecond):
+	}
+	endCleanup()
+	select {
+	case <-sendStarted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("send must start after cleanup ended")
+	}
+}
@@ -0,0 +1,81 @@
+package endpoint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestSenderConfigValidateFanOut(t *testing.T) {
+	a, b := MustMakeJobID("push_a"), MustMakeJobID("push_b")
+	config := func(fanOut *SenderFanOut) *SenderConfig {
+		return &SenderConfig{
+			FSF:     zfs.NoFilter(),
+			Encrypt: &zfs.NilBool{B: false},
+			JobID:   a,
+			FanOut:  fanOut,
+		}
+	}
+
+	assert.NoError(t, config(nil).Validate())
+	assert.NoError(t, config(&SenderFanOut{TargetJobIDs: []JobID{a, b}, LegacyJobID: MustMakeJobID("push")}).Validate())
+
+	// must not panic
+	assert.Error(t, config(&SenderFanOut{TargetJobIDs: []JobID{a, b}}).Validate(), "LegacyJobID must be set")
+	assert.Error(t, config(&SenderFanOut{TargetJobIDs: []JobID{a, {}}, LegacyJobID: MustMakeJobID("push")}).Validate())
+	assert.Error(t, config(&SenderFanOut{TargetJobIDs: []JobID{b}, LegacyJobID: MustMakeJobID("push")}).Validate(), "JobID must be a target")
+}
+
+// fakeRename renames the received filesystems in its keys to its values.
+type fakeRename map[string]string
+
+func (r fakeRename) Map(fs *zfs.DatasetPath) (*zfs.DatasetPath, error) {
+	if local, ok := r[fs.ToString()]; ok {
+		return zfs.NewDatasetPath(local)
+	}
+	return nil, nil
+}
+
+func (r fakeRename) Unmap(local *zfs.DatasetPath) (*zfs.DatasetPath, error) {
+	for fs, l := range r {
+		if l == local.ToString() {
+			return zfs.NewDatasetPath(fs)
+		}
+	}
+	return nil, nil
+}
+
+func TestSubrootRename(t *testing.T) {
+	root, err := zfs.NewDatasetPath("backup/host")
+	require.NoError(t, err)
+	sr := subroot{localRoot: root, rename: fakeRename{"tank/prod/db": "archive/prod-db"}}
+
+	toLocal := func(fs string) string {
+		lp, err := sr.MapToLocal(fs)
+		require.NoError(t, err)
+		return lp.ToString()
+	}
+	toRemote := func(local string) string {
+		lp, err := zfs.NewDatasetPath(local)
+		require.NoError(t, err)
+		fs, err := sr.MapToRemote(lp)
+		require.NoError(t, err)
+		if fs == nil {
+			return ""
+		}
+		return fs.ToString()
+	}
+
+	assert.Equal(t, "backup/host/archive/prod-db", toLocal("tank/prod/db"))
+	assert.Equal(t, "backup/host/tank/prod", toLocal("tank/prod"))
+	assert.Equal(t, "tank/prod/db", toRemote("backup/host/archive/prod-db"))
+	assert.Equal(t, "tank/prod", toRemote("backup/host/tank/prod"))
+	assert.Equal(t, "archive", toRemote("backup/host/archive"), "placeholders of renamed filesystems")
+	assert.Equal(t, "", toRemote("backup/host/tank/prod/db"), "tank/prod/db is received elsewhere")
+
+	_, err = sr.MapToLocal("archive/prod-db")
+	assert.Error(t, err, "must not receive into the renamed target of another filesystem")
+}
@@ -0,0 +1,98 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+type TopologySyncPolicy struct {
+	// Destroy filesystems below the receiver's root whose counterpart no longer exists on the sender.
+	DestroyOrphaned bool
+	// Only log which filesystems would be destroyed.
+	DryRun bool
+}
+
+// SyncTopology reconciles the filesystems below root with senderFSs, the filesystems listed by the sender.
+// rename is the receiver's ReceiverConfig.Rename.
+//
+// Filesystems that appeared on the sender are created by replication itself, so SyncTopology only deals
+// with orphaned filesystems, i.e., filesystems below root that neither correspond to a sender filesystem
+// nor have a descendant that does (those are placeholders).
+// Orphaned filesystems are always logged, but only destroyed (recursively) if policy.DestroyOrphaned is set
+// and policy.DryRun is not.
+//
+// As a safety measure, SyncTopology refuses to do anything if senderFSs is empty:
+// this is more likely a misconfiguration of the sender's filesystem filter than intentional.
+func SyncTopology(ctx context.Context, root *zfs.DatasetPath, rename ReceiverRename, senderFSs []*pdu.Filesystem, policy TopologySyncPolicy) error {
+	if len(senderFSs) == 0 {
+		return fmt.Errorf("sender did not list any filesystems, refusing to sync topology")
+	}
+	sr := subroot{localRoot: root, rename: rename}
+	counterparts := make([]*zfs.DatasetPath, len(senderFSs))
+	for i, fs := range senderFSs {
+		p, err := sr.MapToLocal(fs.GetPath())
+		if err != nil {
+			return errors.Wrapf(err, "invalid sender filesystem %q", fs.GetPath())
+		}
+		counterparts[i] = p
+	}
+
+	local, err := zfs.ZFSListMapping(ctx, sr)
+	if err != nil {
+		return errors.Wrap(err, "cannot list filesystems")
+	}
+
+	orphaned := orphanedFilesystems(root, counterparts, local)
+	if len(orphaned) == 0 {
+		getLogger(ctx).Debug("no orphaned filesystems")
+		return nil
+	}
+
+	var lastErr error
+	for _, fs := range orphaned {
+		l := getLogger(ctx).WithField("fs", fs.ToString())
+		if !policy.DestroyOrphaned {
+			l.Warn("filesystem is orphaned, its counterpart no longer exists on the sender")
+			continue
+		}
+		if policy.DryRun {
+			l.Warn("dry run: would recursively destroy orphaned filesystem")
+			continue
+		}
+		l.Warn("recursively destroying orphaned filesystem")
+		if err := zfs.ZFSDestroyFilesystemRecursive(ctx, fs); err != nil {
+			l.WithError(err).Error("cannot destroy orphaned filesystem")
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// orphanedFilesystems returns the topmost filesystems in local (all below root)
+// that are neither in counterparts, the receive-side counterparts of the sender's filesystems,
+// nor an ancestor of such a counterpart.
+// All descendants of a returned filesystem are orphaned as well.
+func orphanedFilesystems(root *zfs.DatasetPath, counterparts []*zfs.DatasetPath, local []*zfs.DatasetPath) []*zfs.DatasetPath {
+	needed := make(map[string]bool)
+	for _, fs := range counterparts {
+		for p := fs; p.Length() > root.Length(); p = p.Parent() {
+			needed[p.ToString()] = true
+		}
+	}
+	var res []*zfs.DatasetPath
+	for _, fs := range local {
+		if needed[fs.ToString()] {
+			continue
+		}
+		parent := fs.Parent()
+		if parent.Equal(root) || needed[parent.ToString()] {
+			res = append(res, fs)
+		}
+	}
+	return res
+}
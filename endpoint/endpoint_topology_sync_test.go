@@ -0,0 +1,49 @@
+package endpoint
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestOrphanedFilesystems(t *testing.T) {
+	paths := func(ss ...string) []*zfs.DatasetPath {
+		res := make([]*zfs.DatasetPath, len(ss))
+		for i, s := range ss {
+			p, err := zfs.NewDatasetPath(s)
+			require.NoError(t, err)
+			res[i] = p
+		}
+		return res
+	}
+	root := paths("backup/host")[0]
+
+	counterparts := paths("backup/host/pool/a", "backup/host/pool/b/c")
+	local := paths(
+		"backup/host/pool",        // placeholder for pool/a and pool/b/c
+		"backup/host/pool/a",      // exists on sender
+		"backup/host/pool/a/gone", // orphaned
+		"backup/host/pool/b",      // placeholder for pool/b/c
+		"backup/host/pool/b/c",    // exists on sender
+		"backup/host/pool/d",      // orphaned
+		"backup/host/pool/d/e",    // orphaned, but below orphaned pool/d
+		"backup/host/otherpool",   // orphaned
+		"backup/host/otherpool/x", // orphaned, but below orphaned otherpool
+	)
+
+	orphaned := orphanedFilesystems(root, counterparts, local)
+	var names []string
+	for _, o := range orphaned {
+		names = append(names, o.ToString())
+	}
+	assert.Equal(t, []string{
+		"backup/host/pool/a/gone",
+		"backup/host/pool/d",
+		"backup/host/otherpool",
+	}, names)
+
+	assert.Empty(t, orphanedFilesystems(root, counterparts, paths("backup/host/pool", "backup/host/pool/a")))
+}
@@ -26,6 +26,7 @@ const (
 	AbstractionStepBookmark                AbstractionType = "step-bookmark"
 	AbstractionStepHold                    AbstractionType = "step-hold"
 	AbstractionLastReceivedHold            AbstractionType = "last-received-hold"
+	AbstractionPlannedVersionHold          AbstractionType = "planned-version-hold"
 	AbstractionReplicationCursorBookmarkV1 AbstractionType = "replication-cursor-bookmark-v1"
 	AbstractionReplicationCursorBookmarkV2 AbstractionType = "replication-cursor-bookmark-v2"
 )
@@ -34,10 +35,19 @@ var AbstractionTypesAll = map[AbstractionType]bool{
 	AbstractionStepBookmark:                true,
 	AbstractionStepHold:                    true,
 	AbstractionLastReceivedHold:            true,
+	AbstractionPlannedVersionHold:          true,
 	AbstractionReplicationCursorBookmarkV1: true,
 	AbstractionReplicationCursorBookmarkV2: true,
 }
 
+// HoldTagPrefix is the common prefix of the tags of all holds created by zrepl.
+const HoldTagPrefix = "zrepl_"
+
+// IsZreplHoldTag returns true if tag is the tag of a hold created by zrepl.
+func IsZreplHoldTag(tag string) bool {
+	return strings.HasPrefix(tag, HoldTagPrefix)
+}
+
 // Implementation Note:
 // Whenever you add a new accessor, adjust AbstractionJSON.MarshalJSON accordingly
 type Abstraction interface {
@@ -86,6 +96,8 @@ func (t AbstractionType) Validate() error {
 		return nil
 	case AbstractionLastReceivedHold:
 		return nil
+	case AbstractionPlannedVersionHold:
+		return nil
 	case AbstractionReplicationCursorBookmarkV1:
 		return nil
 	case AbstractionReplicationCursorBookmarkV2:
@@ -195,6 +207,8 @@ func (t AbstractionType) BookmarkExtractor() BookmarkExtractor {
 		return nil
 	case AbstractionLastReceivedHold:
 		return nil
+	case AbstractionPlannedVersionHold:
+		return nil
 	default:
 		panic(fmt.Sprintf("unimpl: %q", t))
 	}
@@ -215,6 +229,8 @@ func (t AbstractionType) HoldExtractor() HoldExtractor {
 		return StepHoldExtractor
 	case AbstractionLastReceivedHold:
 		return LastReceivedHoldExtractor
+	case AbstractionPlannedVersionHold:
+		return PlannedHoldExtractor
 	default:
 		panic(fmt.Sprintf("unimpl: %q", t))
 	}
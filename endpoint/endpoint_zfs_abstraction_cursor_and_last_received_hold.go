@@ -118,6 +118,92 @@ func GetReplicationCursors(ctx context.Context, dp *zfs.DatasetPath, jobID JobID
 	return candidates, nil
 }
 
+// GetOldestReplicationCursorOfJobs returns the oldest of the most recent replication cursors
+// of jobIDs on fs, e.g., of the targets of a push job with multiple targets.
+// Snapshots older than that cursor have been replicated to all of these jobs' receivers.
+//
+// A job that has no replication cursor on fs falls back to the most recent
+// replication cursor of legacyJobID (if not nil), see SenderFanOut.
+// If there is a job without a replication cursor, nil is returned.
+func GetOldestReplicationCursorOfJobs(ctx context.Context, fs string, jobIDs []JobID, legacyJobID *JobID) (*zfs.FilesystemVersion, error) {
+	var legacy *zfs.FilesystemVersion
+	legacyDone := false
+	var oldest *zfs.FilesystemVersion
+	for _, jobID := range jobIDs {
+		cursor, err := GetMostRecentReplicationCursorOfJob(ctx, fs, jobID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get replication cursor of job %q", jobID)
+		}
+		if cursor == nil && legacyJobID != nil {
+			if !legacyDone {
+				legacy, err = GetMostRecentReplicationCursorOfJob(ctx, fs, *legacyJobID)
+				if err != nil {
+					return nil, errors.Wrapf(err, "get replication cursor of legacy job %q", *legacyJobID)
+				}
+				legacyDone = true
+			}
+			cursor = legacy
+		}
+		if cursor == nil {
+			return nil, nil
+		}
+		if oldest == nil || cursor.CreateTXG < oldest.CreateTXG {
+			oldest = cursor
+		}
+	}
+	return oldest, nil
+}
+
+// DestroyMigratedLegacyReplicationCursors destroys the replication cursors of legacyJobID on fs
+// once all jobIDs have a replication cursor of their own on fs, see SenderFanOut.
+func DestroyMigratedLegacyReplicationCursors(ctx context.Context, fs string, jobIDs []JobID, legacyJobID JobID) error {
+	for _, jobID := range jobIDs {
+		cursor, err := GetMostRecentReplicationCursorOfJob(ctx, fs, jobID)
+		if err != nil {
+			return errors.Wrapf(err, "get replication cursor of job %q", jobID)
+		}
+		if cursor == nil {
+			return nil // still needed as fallback
+		}
+	}
+
+	q := ListZFSHoldsAndBookmarksQuery{
+		What: AbstractionTypeSet{
+			AbstractionReplicationCursorBookmarkV2: true,
+		},
+		FS: ListZFSHoldsAndBookmarksQueryFilesystemFilter{
+			FS: &fs,
+		},
+		JobID:       &legacyJobID,
+		Concurrency: 1,
+	}
+	abs, absErrs, err := ListAbstractions(ctx, q)
+	if err != nil {
+		return errors.Wrap(err, "legacy replication cursors: list")
+	}
+	if len(absErrs) > 0 {
+		return errors.Wrap(ListAbstractionsErrors(absErrs), "legacy replication cursors: list")
+	}
+
+	var errs []error
+	for res := range BatchDestroy(ctx, abs) {
+		log := getLogger(ctx).
+			WithField("replication_cursor", res.Abstraction)
+		if res.DestroyErr != nil {
+			errs = append(errs, res.DestroyErr)
+			log.WithError(res.DestroyErr).
+				Error("cannot destroy legacy replication cursor")
+		} else {
+			log.Info("destroyed legacy replication cursor, all targets have their own replication cursor")
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	} else {
+		return errorarray.Wrap(errs, "legacy replication cursors: destroy")
+	}
+}
+
 // idempotently create a replication cursor targeting `target`
 //
 // returns ErrBookmarkCloningNotSupported if version is a bookmark and bookmarking bookmarks is not supported by ZFS
@@ -0,0 +1,156 @@
+package endpoint
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+
+	"github.com/pkg/errors"
+
+	"github.com/zrepl/zrepl/util/errorarray"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+var plannedHoldTagRE = regexp.MustCompile("^zrepl_PLAN_J_(.+)$")
+
+func PlannedHoldTag(jobid JobID) (string, error) {
+	return plannedHoldTagImpl(jobid.String())
+}
+
+func plannedHoldTagImpl(jobid string) (string, error) {
+	t := fmt.Sprintf("zrepl_PLAN_J_%s", jobid)
+	if err := zfs.ValidHoldTag(t); err != nil {
+		return "", err
+	}
+	return t, nil
+}
+
+// err != nil always means that the hold is not a planned-version hold
+func ParsePlannedHoldTag(tag string) (JobID, error) {
+	match := plannedHoldTagRE.FindStringSubmatch(tag)
+	if match == nil {
+		return JobID{}, errors.Errorf("parse planned-version-hold tag: does not match regex %s", plannedHoldTagRE.String())
+	}
+	jobID, err := MakeJobID(match[1])
+	if err != nil {
+		return JobID{}, errors.Wrap(err, "parse planned-version-hold tag: invalid job id field")
+	}
+	return jobID, nil
+}
+
+// MovePlannedHolds idempotently holds the snapshots in `keep` with the planned-version-hold tag of jobID
+// and releases the planned-version-holds of jobID on all other snapshots of fs.
+//
+// The new holds are taken before the old ones are released.
+// An empty `keep` releases all planned-version-holds of jobID on fs.
+func MovePlannedHolds(ctx context.Context, fs string, keep []zfs.FilesystemVersion, jobID JobID) error {
+
+	tag, err := PlannedHoldTag(jobID)
+	if err != nil {
+		return errors.Wrap(err, "planned-version-hold: hold tag")
+	}
+
+	keepGuids := make(map[uint64]bool, len(keep))
+	for _, v := range keep {
+		if err := zfs.ZFSHold(ctx, fs, v, tag); err != nil {
+			return errors.Wrap(err, "planned-version-hold: hold")
+		}
+		keepGuids[v.Guid] = true
+	}
+
+	q := ListZFSHoldsAndBookmarksQuery{
+		What: AbstractionTypeSet{
+			AbstractionPlannedVersionHold: true,
+		},
+		FS: ListZFSHoldsAndBookmarksQueryFilesystemFilter{
+			FS: &fs,
+		},
+		JobID:       &jobID,
+		Concurrency: 1,
+	}
+	abs, absErrs, err := ListAbstractions(ctx, q)
+	if err != nil {
+		return errors.Wrap(err, "planned-version-hold: list")
+	}
+	if len(absErrs) > 0 {
+		return errors.Wrap(ListAbstractionsErrors(absErrs), "planned-version-hold: list")
+	}
+
+	var release []Abstraction
+	for _, a := range abs {
+		if !keepGuids[a.GetFilesystemVersion().Guid] {
+			release = append(release, a)
+		}
+	}
+
+	var errs []error
+	for res := range BatchDestroy(ctx, release) {
+		log := getLogger(ctx).
+			WithField("planned-version-hold", res.Abstraction)
+		if res.DestroyErr != nil {
+			errs = append(errs, res.DestroyErr)
+			log.WithError(res.DestroyErr).
+				Error("cannot release planned-version-hold")
+		} else {
+			log.Debug("released planned-version-hold")
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	} else {
+		return errorarray.Wrap(errs, "planned-version-hold: release")
+	}
+}
+
+var _ HoldExtractor = PlannedHoldExtractor
+
+func PlannedHoldExtractor(fs *zfs.DatasetPath, v zfs.FilesystemVersion, holdTag string) Abstraction {
+	if v.Type != zfs.Snapshot {
+		panic("impl error")
+	}
+
+	jobID, err := ParsePlannedHoldTag(holdTag)
+	if err == nil {
+		return &holdBasedAbstraction{
+			Type:              AbstractionPlannedVersionHold,
+			FS:                fs.ToString(),
+			Tag:               holdTag,
+			FilesystemVersion: v,
+			JobID:             jobID,
+		}
+	}
+	return nil
+}
+
+// ReleaseStalePlannedHolds releases all planned-version-holds of jobID on the filesystems matched by fsf.
+//
+// Replication releases the holds of a plan when the plan ends, even if it was cancelled.
+// They can only linger if the daemon exits during a replication, in which case they would prevent pruning
+// of the held snapshots. The holds are only needed while the plan is executed, thus they are always stale
+// when the job starts, and a plan that starts afterwards takes its own holds.
+//
+// Errors for individual holds are logged and do not stop the release of the remaining holds.
+func ReleaseStalePlannedHolds(ctx context.Context, fsf zfs.DatasetFilter, jobID JobID) error {
+	q := ListZFSHoldsAndBookmarksQuery{
+		FS:          ListZFSHoldsAndBookmarksQueryFilesystemFilter{Filter: fsf},
+		What:        AbstractionTypeSet{AbstractionPlannedVersionHold: true},
+		JobID:       &jobID,
+		Concurrency: 1,
+	}
+	abs, listErrs, err := ListAbstractions(ctx, q)
+	if err != nil {
+		return errors.Wrap(err, "list planned-version-holds")
+	}
+	for _, e := range listErrs {
+		getLogger(ctx).WithError(e).Error("cannot list planned-version-holds")
+	}
+	for res := range BatchDestroy(ctx, abs) {
+		l := getLogger(ctx).WithField("planned-version-hold", res.Abstraction)
+		if res.DestroyErr != nil {
+			l.WithError(res.DestroyErr).Error("cannot release stale planned-version-hold")
+			continue
+		}
+		l.Info("released stale planned-version-hold")
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package endpoint
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/zfs/zfscmd"
+)
+
+// withFakeZFS makes zfscmd execute script (a /bin/sh script body) instead of the zfs binary.
+// The returned directory can be used by script for state, e.g. "$FAKEZFS_DIR/log".
+// The caller must call the returned cleanup function.
+func withFakeZFS(t *testing.T, script string) (dir string, cleanup func()) {
+	dir, err := ioutil.TempDir("", "zrepl-endpoint-fakezfs")
+	require.NoError(t, err)
+	bin := filepath.Join(dir, "zfs")
+	err = ioutil.WriteFile(bin, []byte("#!/bin/sh\nFAKEZFS_DIR='"+dir+"'\n"+script), 0755)
+	require.NoError(t, err)
+	require.NoError(t, zfscmd.SetBinaryPaths(map[string]string{"zfs": bin}))
+	return dir, func() {
+		require.NoError(t, zfscmd.SetBinaryPaths(nil))
+		os.RemoveAll(dir)
+	}
+}
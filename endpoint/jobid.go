@@ -76,3 +76,10 @@ func (j *JobID) UnmarshalJSON(b []byte) error {
 }
 
 func (j JobID) MustValidate() { j.expectInitialized() }
+
+// Validate is like MustValidate, but returns an error instead of panicking,
+// e.g., for job IDs that are part of a configuration.
+func (j JobID) Validate() error {
+	_, err := MakeJobID(j.jid)
+	return err
+}
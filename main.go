@@ -11,6 +11,7 @@ func init() {
 	cli.AddSubcommand(daemon.DaemonCmd)
 	cli.AddSubcommand(client.StatusCmd)
 	cli.AddSubcommand(client.SignalCmd)
+	cli.AddSubcommand(client.SnapperStateCmd)
 	cli.AddSubcommand(client.StdinserverCmd)
 	cli.AddSubcommand(client.ConfigcheckCmd)
 	cli.AddSubcommand(client.VersionCmd)
@@ -18,6 +19,11 @@ func init() {
 	cli.AddSubcommand(client.TestCmd)
 	cli.AddSubcommand(client.MigrateCmd)
 	cli.AddSubcommand(client.ZFSAbstractionsCmd)
+	cli.AddSubcommand(client.ReplicateCmd)
+	cli.AddSubcommand(client.SendCmd)
+	cli.AddSubcommand(client.RecvCmd)
+	cli.AddSubcommand(client.RecvAbortCmd)
+	cli.AddSubcommand(client.HoldsCmd)
 }
 
 func main() {
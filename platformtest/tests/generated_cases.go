@@ -16,12 +16,16 @@ var Cases = []Case{BatchDestroy,
 	ListFilesystemsNoFilter,
 	ReceiveForceIntoEncryptedErr,
 	ReceiveForceRollbackWorksUnencrypted,
+	ReceiveRawIncrementalAfterChangeKeyInherit,
+	ReceiveRawIncrementalAfterChangeKeyOwn,
+	ReplicationCursorFanOutLegacyFallback,
 	ReplicationIncrementalCleansUpStaleAbstractionsWithCacheOnSecondReplication,
 	ReplicationIncrementalCleansUpStaleAbstractionsWithoutCacheOnSecondReplication,
 	ReplicationIncrementalDestroysStepHoldsIffIncrementalStepHoldsAreDisabledButStepHoldsExist,
 	ReplicationIncrementalIsPossibleIfCommonSnapshotIsDestroyed,
 	ReplicationIsResumableFullSend__DisableIncrementalStepHolds_False,
 	ReplicationIsResumableFullSend__DisableIncrementalStepHolds_True,
+	ReplicationPreservesClones,
 	ResumableRecvAndTokenHandling,
 	ResumeTokenParsing,
 	SendArgsValidationEncryptedSendOfUnencryptedDatasetForbidden,
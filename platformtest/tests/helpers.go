@@ -164,8 +164,7 @@ func versionRelnamesSorted(versions []zfs.FilesystemVersion) []string {
 func datasetToStringSortedTrimPrefix(prefix *zfs.DatasetPath, paths []*zfs.DatasetPath) []string {
 	var pstrs []string
 	for _, p := range paths {
-		trimmed := p.Copy()
-		trimmed.TrimPrefix(prefix)
+		trimmed, _ := p.Relative(prefix)
 		if trimmed.Length() == 0 {
 			continue
 		}
@@ -0,0 +1,95 @@
+package tests
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/platformtest"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+// The encryption_root recv option changes the wrapping key of received filesystems using `zfs change-key`.
+// These tests check that raw incremental receives into such filesystems keep working
+// and keep the encryption root and key that were set on the receiving side.
+
+func ReceiveRawIncrementalAfterChangeKeyInherit(ctx *platformtest.Context) {
+	implReceiveRawIncrementalAfterChangeKey(ctx, zfs.ChangeKeyOptions{Inherit: true})
+}
+
+func ReceiveRawIncrementalAfterChangeKeyOwn(ctx *platformtest.Context) {
+	const passphraseFilePath = "/tmp/zreplplatformtest.encryption.passphrase.changed"
+	err := ioutil.WriteFile(passphraseFilePath, []byte("changedpassphrase"), 0600)
+	require.NoError(ctx, err)
+	implReceiveRawIncrementalAfterChangeKey(ctx, zfs.ChangeKeyOptions{
+		KeyLocation: "file://" + passphraseFilePath,
+		KeyFormat:   "passphrase",
+	})
+}
+
+func implReceiveRawIncrementalAfterChangeKey(ctx *platformtest.Context, changeKey zfs.ChangeKeyOptions) {
+
+	supported, err := zfs.EncryptionCLISupported(ctx)
+	check(err)
+	if !supported {
+		ctx.SkipNow()
+	}
+
+	platformtest.Run(ctx, platformtest.PanicErr, ctx.RootDataset, `
+		DESTROYROOT
+		CREATEROOT
+		+  "sender" encrypted
+		+  "sender@1"
+		+  "receiver" encrypted
+	`)
+
+	sfs := fmt.Sprintf("%s/sender", ctx.RootDataset)
+	rfsParent := fmt.Sprintf("%s/receiver", ctx.RootDataset)
+	rfs := fmt.Sprintf("%s/receiver/sender", ctx.RootDataset)
+
+	rawRecv := func(from, to *zfs.ZFSSendArgVersion) {
+		sendArgs, err := zfs.ZFSSendArgsUnvalidated{
+			FS:        sfs,
+			Encrypted: &zfs.NilBool{B: true},
+			From:      from,
+			To:        to,
+		}.Validate(ctx)
+		require.NoError(ctx, err)
+		sendStream, err := zfs.ZFSSend(ctx, sendArgs)
+		require.NoError(ctx, err)
+		err = zfs.ZFSRecv(ctx, rfs, to, sendStream, zfs.RecvOptions{})
+		require.NoError(ctx, err)
+	}
+
+	snap1 := sendArgVersion(ctx, sfs, "@1")
+	rawRecv(nil, &snap1)
+
+	// the received filesystem is an encryption root with the sender's wrapping key, which zrepl never loads
+	platformtest.Run(ctx, platformtest.PanicErr, ctx.RootDataset, `
+		R  zfs load-key -L file:///tmp/zreplplatformtest.encryption.passphrase "${ROOTDS}/receiver/sender"
+	`)
+	require.NoError(ctx, zfs.ZFSChangeKey(ctx, rfs, changeKey))
+
+	platformtest.Run(ctx, platformtest.PanicErr, ctx.RootDataset, `
+		+  "sender@2"
+	`)
+	snap2 := sendArgVersion(ctx, sfs, "@2")
+	rawRecv(&snap1, &snap2)
+	_ = fsversion(ctx, rfs, "@2")
+
+	state, err := zfs.ZFSGetEncryptionState(ctx, rfs)
+	require.NoError(ctx, err)
+	require.True(ctx, state.Encrypted)
+	require.True(ctx, state.KeyAvailable())
+	if changeKey.Inherit {
+		require.Equal(ctx, rfsParent, state.EncryptionRoot)
+	} else {
+		require.True(ctx, state.IsEncryptionRoot(rfs))
+		require.Equal(ctx, changeKey.KeyLocation, state.KeyLocation)
+		// fails unless the key at keylocation is the wrapping key
+		platformtest.Run(ctx, platformtest.PanicErr, ctx.RootDataset, `
+			R  zfs load-key -n "${ROOTDS}/receiver/sender"
+		`)
+	}
+}
@@ -29,6 +29,7 @@ import (
 type replicationInvocation struct {
 	sjid, rjid                  endpoint.JobID
 	sfs                         string
+	sfsSubtree                  bool // replicate sfs and all of its children
 	rfsRoot                     string
 	interceptSender             func(e *endpoint.Sender) logic.Sender
 	disableIncrementalStepHolds bool
@@ -41,7 +42,11 @@ func (i replicationInvocation) Do(ctx *platformtest.Context) *report.Report {
 	}
 
 	sfilter := filters.NewDatasetMapFilter(1, true)
-	err := sfilter.Add(i.sfs, "ok")
+	filterPattern := i.sfs
+	if i.sfsSubtree {
+		filterPattern += "<"
+	}
+	err := sfilter.Add(filterPattern, "ok")
 	require.NoError(ctx, err)
 	sender := i.interceptSender(endpoint.NewSender(endpoint.SenderConfig{
 		FSF:                         sfilter.AsFilter(),
@@ -61,7 +66,7 @@ func (i replicationInvocation) Do(ctx *platformtest.Context) *report.Report {
 
 	report, wait := replication.Do(
 		ctx,
-		logic.NewPlanner(nil, nil, sender, receiver, plannerPolicy),
+		logic.NewPlanner(nil, nil, nil, sender, receiver, plannerPolicy),
 	)
 	wait(true)
 	return report()
@@ -124,6 +129,40 @@ func ReplicationIncrementalIsPossibleIfCommonSnapshotIsDestroyed(ctx *platformte
 
 }
 
+func ReplicationPreservesClones(ctx *platformtest.Context) {
+
+	platformtest.Run(ctx, platformtest.PanicErr, ctx.RootDataset, `
+		CREATEROOT
+		+  "sender"
+		+  "sender/a"
+		+  "sender/a@1"
+		R  zfs clone "${ROOTDS}/sender/a@1" "${ROOTDS}/sender/b"
+		+  "sender/b@2"
+		+  "receiver"
+		R  zfs create -p "${ROOTDS}/receiver/${ROOTDS}"
+	`)
+
+	sfs := ctx.RootDataset + "/sender"
+	rep := replicationInvocation{
+		sjid:       endpoint.MustMakeJobID("sender-job"),
+		rjid:       endpoint.MustMakeJobID("receiver-job"),
+		sfs:        sfs,
+		sfsSubtree: true,
+		rfsRoot:    ctx.RootDataset + "/receiver",
+	}
+	rfs := rep.ReceiveSideFilesystem()
+
+	report := rep.Do(ctx)
+	ctx.Logf("\n%s", pretty.Sprint(report))
+
+	_ = fsversion(ctx, rfs+"/b", "@2")
+	originFS, origin, err := zfs.ZFSGetOrigin(ctx, mustDatasetPath(rfs+"/b"))
+	require.NoError(ctx, err)
+	require.NotNil(ctx, origin)
+	require.Equal(ctx, rfs+"/a", originFS.ToString())
+	require.Equal(ctx, fsversion(ctx, sfs+"/a", "@1").Guid, origin.Guid)
+}
+
 func ReplicationIncrementalCleansUpStaleAbstractionsWithCacheOnSecondReplication(ctx *platformtest.Context) {
 	implReplicationIncrementalCleansUpStaleAbstractions(ctx, true)
 }
@@ -73,3 +73,72 @@ func CreateReplicationCursor(ctx *platformtest.Context) {
 	}
 
 }
+
+func ReplicationCursorFanOutLegacyFallback(ctx *platformtest.Context) {
+
+	platformtest.Run(ctx, platformtest.PanicErr, ctx.RootDataset, `
+		CREATEROOT
+		+  "foo bar"
+		+  "foo bar@1"
+		+  "foo bar@2"
+		+  "foo bar@3"
+	`)
+
+	legacy := endpoint.MustMakeJobID("zreplplatformtest")
+	a := endpoint.MustMakeJobID("zreplplatformtest:a")
+	b := endpoint.MustMakeJobID("zreplplatformtest:b")
+	targets := []endpoint.JobID{a, b}
+
+	fs := ctx.RootDataset + "/foo bar"
+	snap1 := fsversion(ctx, fs, "@1")
+	snap2 := fsversion(ctx, fs, "@2")
+	snap3 := fsversion(ctx, fs, "@3")
+
+	mustCreateCursor := func(v zfs.FilesystemVersion, jobID endpoint.JobID) {
+		_, err := endpoint.CreateReplicationCursor(ctx, fs, v, jobID)
+		require.NoError(ctx, err)
+	}
+	requireOldest := func(expect *zfs.FilesystemVersion) {
+		oldest, err := endpoint.GetOldestReplicationCursorOfJobs(ctx, fs, targets, &legacy)
+		require.NoError(ctx, err)
+		if expect == nil {
+			require.Nil(ctx, oldest)
+		} else {
+			require.NotNil(ctx, oldest)
+			require.Equal(ctx, expect.Guid, oldest.Guid)
+		}
+	}
+
+	// no cursors at all
+	requireOldest(nil)
+
+	// the legacy cursor is used for both targets
+	mustCreateCursor(snap2, legacy)
+	requireOldest(&snap2)
+
+	// target b still uses the legacy cursor, which is older
+	mustCreateCursor(snap3, a)
+	requireOldest(&snap2)
+
+	// the legacy cursor must be kept while b has no cursor
+	require.NoError(ctx, endpoint.DestroyMigratedLegacyReplicationCursors(ctx, fs, targets, legacy))
+	legacyCursor, err := endpoint.GetMostRecentReplicationCursorOfJob(ctx, fs, legacy)
+	require.NoError(ctx, err)
+	require.NotNil(ctx, legacyCursor)
+
+	// target b's own cursor takes precedence over the legacy cursor, even if older
+	mustCreateCursor(snap1, b)
+	requireOldest(&snap1)
+
+	// now that all targets have a cursor, the legacy cursor is destroyed
+	require.NoError(ctx, endpoint.DestroyMigratedLegacyReplicationCursors(ctx, fs, targets, legacy))
+	legacyCursor, err = endpoint.GetMostRecentReplicationCursorOfJob(ctx, fs, legacy)
+	require.NoError(ctx, err)
+	require.Nil(ctx, legacyCursor)
+	requireOldest(&snap1)
+
+	// without legacy fallback, a target without cursor means there is no common cursor
+	oldest, err := endpoint.GetOldestReplicationCursorOfJobs(ctx, fs, append(targets, endpoint.MustMakeJobID("zreplplatformtest:c")), nil)
+	require.NoError(ctx, err)
+	require.Nil(ctx, oldest)
+}
@@ -44,6 +44,25 @@ func PruneSnapshots(snaps []Snapshot, keepRules []KeepRule) []Snapshot {
 	return remove
 }
 
+// PruneSnapshotsWithGracePeriod is like PruneSnapshots, but never returns snapshots
+// that are younger than gracePeriod at time now, regardless of keepRules.
+// A gracePeriod <= 0 disables the grace period.
+func PruneSnapshotsWithGracePeriod(snaps []Snapshot, keepRules []KeepRule, gracePeriod time.Duration, now time.Time) []Snapshot {
+	remove := PruneSnapshots(snaps, keepRules)
+	if gracePeriod <= 0 {
+		return remove
+	}
+	graceStart := now.Add(-gracePeriod)
+	filtered := remove[:0]
+	for _, s := range remove {
+		if s.Date().After(graceStart) {
+			continue
+		}
+		filtered = append(filtered, s)
+	}
+	return filtered
+}
+
 func RulesFromConfig(in []config.PruningEnum) (rules []KeepRule, err error) {
 	rules = make([]KeepRule, len(in))
 	for i := range in {
@@ -3,6 +3,8 @@ package pruning
 import (
 	"testing"
 	"time"
+
+	"github.com/stretchr/testify/assert"
 )
 
 type stubSnap struct {
@@ -144,3 +146,30 @@ func TestPruneSnapshots(t *testing.T) {
 
 	testTable(tcs, t)
 }
+
+func TestPruneSnapshotsWithGracePeriod(t *testing.T) {
+	now := time.Unix(100000, 0)
+	ago := func(minutes int) time.Time { return now.Add(-time.Duration(minutes) * time.Minute) }
+	snaps := []Snapshot{
+		stubSnap{name: "old", date: ago(60)},
+		stubSnap{name: "older", date: ago(120)},
+		stubSnap{name: "edge", date: ago(10)},
+		stubSnap{name: "young", date: ago(5)},
+		stubSnap{name: "newest", date: ago(1)},
+	}
+	rules := []KeepRule{KeepLastN{1}}
+
+	destroyed := func(grace time.Duration) map[string]bool {
+		res := make(map[string]bool)
+		for _, s := range PruneSnapshotsWithGracePeriod(snaps, rules, grace, now) {
+			res[s.Name()] = true
+		}
+		return res
+	}
+
+	assert.Equal(t, map[string]bool{"old": true, "older": true, "edge": true, "young": true}, destroyed(0), "zero grace period is disabled")
+	assert.Equal(t, map[string]bool{"old": true, "older": true, "edge": true}, destroyed(10*time.Minute))
+	assert.Equal(t, map[string]bool{"older": true}, destroyed(90*time.Minute))
+	assert.Empty(t, destroyed(24*time.Hour))
+	assert.Empty(t, PruneSnapshotsWithGracePeriod(snaps, nil, 10*time.Minute, now), "no rules keep everything")
+}
@@ -124,6 +124,28 @@ type FS interface {
 	ReportInfo() *report.FilesystemInfo
 }
 
+// FS implementations that acquire resources in PlanFS which must outlive
+// the individual steps (e.g., holds on the snapshots of all planned steps)
+// can implement this interface to release them.
+type FSPlanReleaser interface {
+	// Called exactly once after each successful PlanFS, once the driver is done
+	// with the returned steps, regardless of how many of them were executed successfully.
+	ReleasePlan(context.Context)
+}
+
+// FS implementations whose initial replication depends on another filesystem
+// of the same attempt (e.g., a clone that is sent relative to its origin)
+// can implement this interface.
+type FSWithInitialReplicationDependency interface {
+	// Returns the name (as in ReportInfo) of the filesystem whose replication
+	// must be done before this filesystem's initial replication starts,
+	// or "" if there is no such filesystem.
+	//
+	// The dependency is only an ordering hint: if the replication of the
+	// dependency fails, the initial replication of this filesystem starts anyway.
+	InitialReplicationDependency() string
+}
+
 type Step interface {
 	// Returns true iff the target snapshot is the same for this Step and other.
 	// We do not use TargetDate to avoid problems with wrong system time on
@@ -150,6 +172,7 @@ type fs struct {
 	// ordering relationship that must be maintained for initial replication
 	initialRepOrd struct {
 		parents, children []*fs
+		dependency        *fs // see FSWithInitialReplicationDependency, also has this fs in its children
 		parentDidUpdate   chan struct{}
 	}
 
@@ -173,6 +196,8 @@ type fs struct {
 type step struct {
 	l    *chainlock.L
 	step Step
+	// the error of the step if it failed, the steps after it are not executed
+	err *timedError
 }
 
 type ReportFunc func() *report.Report
@@ -385,6 +410,33 @@ func (a *attempt) doGlobalPlanning(ctx context.Context, prev *attempt) map[*fs]*
 		}
 	}
 
+	// build up initial replication dependencies that are not parent-child relationships
+	byName := make(map[string]*fs, len(a.fss))
+	for _, f := range a.fss {
+		byName[f.fs.ReportInfo().Name] = f
+	}
+	for _, f := range a.fss {
+		fwd, ok := f.fs.(FSWithInitialReplicationDependency)
+		if !ok {
+			continue
+		}
+		dep, ok := byName[fwd.InitialReplicationDependency()]
+		if !ok || dep == f {
+			continue
+		}
+		isParentOfDep := false
+		for _, p := range dep.initialRepOrd.parents {
+			isParentOfDep = isParentOfDep || p == f
+		}
+		if isParentOfDep {
+			// dep waits for f anyways, waiting for dep would deadlock
+			f.debug("ignoring initial replication dependency on child %s", dep.fs.ReportInfo().Name)
+			continue
+		}
+		f.initialRepOrd.dependency = dep
+		dep.initialRepOrd.children = append(dep.initialRepOrd.children, f)
+	}
+
 	return prevs
 }
 
@@ -455,6 +507,9 @@ func (f *fs) do(ctx context.Context, pq *stepQueue, prev *fs) {
 		f.planning.err = newTimedError(err, errTime)
 		return
 	}
+	if r, ok := f.fs.(FSPlanReleaser); ok {
+		defer f.l.DropWhile(func() { r.ReleasePlan(ctx) })
+	}
 	for _, pstep := range psteps {
 		step := &step{
 			l:    f.l,
@@ -526,11 +581,26 @@ func (f *fs) do(ctx context.Context, pq *stepQueue, prev *fs) {
 	for _, p := range f.initialRepOrd.parents {
 		parents = append(parents, p.fs.ReportInfo().Name)
 	}
+	// only the initial replication depends on f.initialRepOrd.dependency
+	// (no need to lock for .report() because step.l == it's fs.l)
+	waitForDependency := len(f.planned.steps) > 0 && !f.planned.steps[0].report().IsIncremental()
+	if d := f.initialRepOrd.dependency; d != nil && waitForDependency {
+		f.debug("wait for initial replication dependency %s", d.fs.ReportInfo().Name)
+	}
 	f.debug("wait for parents %s", parents)
 	for {
 		var initialReplicatingParentsWithErrors []string
 		allParentsPresentOnReceiver := true
 		f.l.DropWhile(func() {
+			if d := f.initialRepOrd.dependency; d != nil && waitForDependency {
+				d.l.HoldWhile(func() {
+					// the dependency is done if it has executed all of its steps or failed
+					dependencyDone := d.planning.err != nil ||
+						(d.planning.done && (d.planned.stepErr != nil || d.planned.step >= len(d.planned.steps)))
+					f.debug("dependencyDone=%v", dependencyDone)
+					allParentsPresentOnReceiver = allParentsPresentOnReceiver && dependencyDone
+				})
+			}
 			for _, p := range f.initialRepOrd.parents {
 				p.l.HoldWhile(func() {
 					// (get the preconditions that allow us to inspect p.planned)
@@ -617,6 +687,7 @@ func (f *fs) do(ctx context.Context, pq *stepQueue, prev *fs) {
 
 		if err != nil {
 			f.planned.stepErr = newTimedError(err, errTime)
+			s.err = f.planned.stepErr
 			break
 		}
 		f.planned.step = i + 1 // fs.planned.step must be == len(fs.planned.steps) if all went OK
@@ -705,6 +776,9 @@ func (f *fs) report() *report.FilesystemReport {
 	}
 	for i := range r.Steps {
 		r.Steps[i] = f.planned.steps[i].report()
+		if i < f.planned.step {
+			r.Steps[i].State = report.StepDone
+		}
 	}
 	return r
 }
@@ -712,7 +786,12 @@ func (f *fs) report() *report.FilesystemReport {
 // caller must hold lock l
 func (s *step) report() *report.StepReport {
 	r := &report.StepReport{
-		Info: s.step.ReportInfo(),
+		Info:  s.step.ReportInfo(),
+		State: report.StepPending,
+	}
+	if s.err != nil {
+		r.State = report.StepFailed
+		r.Error = s.err.IntoReportError()
 	}
 	return r
 }
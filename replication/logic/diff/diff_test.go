@@ -119,6 +119,12 @@ func TestIncrementalPath_BookmarkSupport(t *testing.T) {
 		assert.Equal(t, l("#a,1", "@c,3"), path)
 	})
 
+	// the most recent common snapshot has been pruned on the sender, but its bookmark remains
+	doTest(l("@a,1", "@b,2"), l("#a,1", "#b,2", "@c,3"), func(path []*FilesystemVersion, conflict error) {
+		assert.Nil(t, conflict)
+		assert.Equal(t, l("#b,2", "@c,3"), path)
+	})
+
 	// test that snapshots are preferred over bookmarks in IncrementalPath
 	doTest(l("@a,1"), l("#a,1", "@a,1", "@b,2"), func(path []*FilesystemVersion, conflict error) {
 		assert.Equal(t, l("@a,1", "@b,2"), path)
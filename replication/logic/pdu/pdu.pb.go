@@ -46,7 +46,7 @@ func (x Tri) String() string {
 	return proto.EnumName(Tri_name, int32(x))
 }
 func (Tri) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_pdu_483c6918b7b3d747, []int{0}
+	return fileDescriptor_pdu_ee78635eea31df40, []int{0}
 }
 
 type FilesystemVersion_VersionType int32
@@ -69,7 +69,7 @@ func (x FilesystemVersion_VersionType) String() string {
 	return proto.EnumName(FilesystemVersion_VersionType_name, int32(x))
 }
 func (FilesystemVersion_VersionType) EnumDescriptor() ([]byte, []int) {
-	return fileDescriptor_pdu_483c6918b7b3d747, []int{5, 0}
+	return fileDescriptor_pdu_ee78635eea31df40, []int{5, 0}
 }
 
 type ListFilesystemReq struct {
@@ -82,7 +82,7 @@ func (m *ListFilesystemReq) Reset()         { *m = ListFilesystemReq{} }
 func (m *ListFilesystemReq) String() string { return proto.CompactTextString(m) }
 func (*ListFilesystemReq) ProtoMessage()    {}
 func (*ListFilesystemReq) Descriptor() ([]byte, []int) {
-	return fileDescriptor_pdu_483c6918b7b3d747, []int{0}
+	return fileDescriptor_pdu_ee78635eea31df40, []int{0}
 }
 func (m *ListFilesystemReq) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_ListFilesystemReq.Unmarshal(m, b)
@@ -113,7 +113,7 @@ func (m *ListFilesystemRes) Reset()         { *m = ListFilesystemRes{} }
 func (m *ListFilesystemRes) String() string { return proto.CompactTextString(m) }
 func (*ListFilesystemRes) ProtoMessage()    {}
 func (*ListFilesystemRes) Descriptor() ([]byte, []int) {
-	return fileDescriptor_pdu_483c6918b7b3d747, []int{1}
+	return fileDescriptor_pdu_ee78635eea31df40, []int{1}
 }
 func (m *ListFilesystemRes) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_ListFilesystemRes.Unmarshal(m, b)
@@ -141,20 +141,24 @@ func (m *ListFilesystemRes) GetFilesystems() []*Filesystem {
 }
 
 type Filesystem struct {
-	Path                 string   `protobuf:"bytes,1,opt,name=Path,proto3" json:"Path,omitempty"`
-	ResumeToken          string   `protobuf:"bytes,2,opt,name=ResumeToken,proto3" json:"ResumeToken,omitempty"`
-	IsPlaceholder        bool     `protobuf:"varint,3,opt,name=IsPlaceholder,proto3" json:"IsPlaceholder,omitempty"`
-	IsEncrypted          bool     `protobuf:"varint,4,opt,name=IsEncrypted,proto3" json:"IsEncrypted,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	Path          string `protobuf:"bytes,1,opt,name=Path,proto3" json:"Path,omitempty"`
+	ResumeToken   string `protobuf:"bytes,2,opt,name=ResumeToken,proto3" json:"ResumeToken,omitempty"`
+	IsPlaceholder bool   `protobuf:"varint,3,opt,name=IsPlaceholder,proto3" json:"IsPlaceholder,omitempty"`
+	IsEncrypted   bool   `protobuf:"varint,4,opt,name=IsEncrypted,proto3" json:"IsEncrypted,omitempty"`
+	// If the filesystem is a clone, the filesystem and snapshot it was cloned
+	// from. Empty / null otherwise.
+	OriginFilesystem     string             `protobuf:"bytes,5,opt,name=OriginFilesystem,proto3" json:"OriginFilesystem,omitempty"`
+	Origin               *FilesystemVersion `protobuf:"bytes,6,opt,name=Origin,proto3" json:"Origin,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
 }
 
 func (m *Filesystem) Reset()         { *m = Filesystem{} }
 func (m *Filesystem) String() string { return proto.CompactTextString(m) }
 func (*Filesystem) ProtoMessage()    {}
 func (*Filesystem) Descriptor() ([]byte, []int) {
-	return fileDescriptor_pdu_483c6918b7b3d747, []int{2}
+	return fileDescriptor_pdu_ee78635eea31df40, []int{2}
 }
 func (m *Filesystem) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_Filesystem.Unmarshal(m, b)
@@ -202,6 +206,20 @@ func (m *Filesystem) GetIsEncrypted() bool {
 	return false
 }
 
+func (m *Filesystem) GetOriginFilesystem() string {
+	if m != nil {
+		return m.OriginFilesystem
+	}
+	return ""
+}
+
+func (m *Filesystem) GetOrigin() *FilesystemVersion {
+	if m != nil {
+		return m.Origin
+	}
+	return nil
+}
+
 type ListFilesystemVersionsReq struct {
 	Filesystem           string   `protobuf:"bytes,1,opt,name=Filesystem,proto3" json:"Filesystem,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -213,7 +231,7 @@ func (m *ListFilesystemVersionsReq) Reset()         { *m = ListFilesystemVersion
 func (m *ListFilesystemVersionsReq) String() string { return proto.CompactTextString(m) }
 func (*ListFilesystemVersionsReq) ProtoMessage()    {}
 func (*ListFilesystemVersionsReq) Descriptor() ([]byte, []int) {
-	return fileDescriptor_pdu_483c6918b7b3d747, []int{3}
+	return fileDescriptor_pdu_ee78635eea31df40, []int{3}
 }
 func (m *ListFilesystemVersionsReq) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_ListFilesystemVersionsReq.Unmarshal(m, b)
@@ -251,7 +269,7 @@ func (m *ListFilesystemVersionsRes) Reset()         { *m = ListFilesystemVersion
 func (m *ListFilesystemVersionsRes) String() string { return proto.CompactTextString(m) }
 func (*ListFilesystemVersionsRes) ProtoMessage()    {}
 func (*ListFilesystemVersionsRes) Descriptor() ([]byte, []int) {
-	return fileDescriptor_pdu_483c6918b7b3d747, []int{4}
+	return fileDescriptor_pdu_ee78635eea31df40, []int{4}
 }
 func (m *ListFilesystemVersionsRes) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_ListFilesystemVersionsRes.Unmarshal(m, b)
@@ -293,7 +311,7 @@ func (m *FilesystemVersion) Reset()         { *m = FilesystemVersion{} }
 func (m *FilesystemVersion) String() string { return proto.CompactTextString(m) }
 func (*FilesystemVersion) ProtoMessage()    {}
 func (*FilesystemVersion) Descriptor() ([]byte, []int) {
-	return fileDescriptor_pdu_483c6918b7b3d747, []int{5}
+	return fileDescriptor_pdu_ee78635eea31df40, []int{5}
 }
 func (m *FilesystemVersion) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_FilesystemVersion.Unmarshal(m, b)
@@ -359,9 +377,12 @@ type SendReq struct {
 	// SHOULD clear the resume token on their side and use From and To instead If
 	// ResumeToken is not empty, the GUIDs of From and To MUST correspond to those
 	// encoded in the ResumeToken. Otherwise, the Sender MUST return an error.
-	ResumeToken          string   `protobuf:"bytes,4,opt,name=ResumeToken,proto3" json:"ResumeToken,omitempty"`
-	Encrypted            Tri      `protobuf:"varint,5,opt,name=Encrypted,proto3,enum=Tri" json:"Encrypted,omitempty"`
-	DryRun               bool     `protobuf:"varint,6,opt,name=DryRun,proto3" json:"DryRun,omitempty"`
+	ResumeToken string `protobuf:"bytes,4,opt,name=ResumeToken,proto3" json:"ResumeToken,omitempty"`
+	Encrypted   Tri    `protobuf:"varint,5,opt,name=Encrypted,proto3,enum=Tri" json:"Encrypted,omitempty"`
+	DryRun      bool   `protobuf:"varint,6,opt,name=DryRun,proto3" json:"DryRun,omitempty"`
+	// If not empty, From is a snapshot of FromOriginFilesystem, which MUST be
+	// the origin of the clone Filesystem (clone-preserving initial send).
+	FromOriginFilesystem string   `protobuf:"bytes,7,opt,name=FromOriginFilesystem,proto3" json:"FromOriginFilesystem,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -371,7 +392,7 @@ func (m *SendReq) Reset()         { *m = SendReq{} }
 func (m *SendReq) String() string { return proto.CompactTextString(m) }
 func (*SendReq) ProtoMessage()    {}
 func (*SendReq) Descriptor() ([]byte, []int) {
-	return fileDescriptor_pdu_483c6918b7b3d747, []int{6}
+	return fileDescriptor_pdu_ee78635eea31df40, []int{6}
 }
 func (m *SendReq) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_SendReq.Unmarshal(m, b)
@@ -433,6 +454,13 @@ func (m *SendReq) GetDryRun() bool {
 	return false
 }
 
+func (m *SendReq) GetFromOriginFilesystem() string {
+	if m != nil {
+		return m.FromOriginFilesystem
+	}
+	return ""
+}
+
 type Property struct {
 	Name                 string   `protobuf:"bytes,1,opt,name=Name,proto3" json:"Name,omitempty"`
 	Value                string   `protobuf:"bytes,2,opt,name=Value,proto3" json:"Value,omitempty"`
@@ -445,7 +473,7 @@ func (m *Property) Reset()         { *m = Property{} }
 func (m *Property) String() string { return proto.CompactTextString(m) }
 func (*Property) ProtoMessage()    {}
 func (*Property) Descriptor() ([]byte, []int) {
-	return fileDescriptor_pdu_483c6918b7b3d747, []int{7}
+	return fileDescriptor_pdu_ee78635eea31df40, []int{7}
 }
 func (m *Property) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_Property.Unmarshal(m, b)
@@ -496,7 +524,7 @@ func (m *SendRes) Reset()         { *m = SendRes{} }
 func (m *SendRes) String() string { return proto.CompactTextString(m) }
 func (*SendRes) ProtoMessage()    {}
 func (*SendRes) Descriptor() ([]byte, []int) {
-	return fileDescriptor_pdu_483c6918b7b3d747, []int{8}
+	return fileDescriptor_pdu_ee78635eea31df40, []int{8}
 }
 func (m *SendRes) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_SendRes.Unmarshal(m, b)
@@ -548,7 +576,7 @@ func (m *SendCompletedReq) Reset()         { *m = SendCompletedReq{} }
 func (m *SendCompletedReq) String() string { return proto.CompactTextString(m) }
 func (*SendCompletedReq) ProtoMessage()    {}
 func (*SendCompletedReq) Descriptor() ([]byte, []int) {
-	return fileDescriptor_pdu_483c6918b7b3d747, []int{9}
+	return fileDescriptor_pdu_ee78635eea31df40, []int{9}
 }
 func (m *SendCompletedReq) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_SendCompletedReq.Unmarshal(m, b)
@@ -585,7 +613,7 @@ func (m *SendCompletedRes) Reset()         { *m = SendCompletedRes{} }
 func (m *SendCompletedRes) String() string { return proto.CompactTextString(m) }
 func (*SendCompletedRes) ProtoMessage()    {}
 func (*SendCompletedRes) Descriptor() ([]byte, []int) {
-	return fileDescriptor_pdu_483c6918b7b3d747, []int{10}
+	return fileDescriptor_pdu_ee78635eea31df40, []int{10}
 }
 func (m *SendCompletedRes) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_SendCompletedRes.Unmarshal(m, b)
@@ -620,7 +648,7 @@ func (m *ReceiveReq) Reset()         { *m = ReceiveReq{} }
 func (m *ReceiveReq) String() string { return proto.CompactTextString(m) }
 func (*ReceiveReq) ProtoMessage()    {}
 func (*ReceiveReq) Descriptor() ([]byte, []int) {
-	return fileDescriptor_pdu_483c6918b7b3d747, []int{11}
+	return fileDescriptor_pdu_ee78635eea31df40, []int{11}
 }
 func (m *ReceiveReq) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_ReceiveReq.Unmarshal(m, b)
@@ -671,7 +699,7 @@ func (m *ReceiveRes) Reset()         { *m = ReceiveRes{} }
 func (m *ReceiveRes) String() string { return proto.CompactTextString(m) }
 func (*ReceiveRes) ProtoMessage()    {}
 func (*ReceiveRes) Descriptor() ([]byte, []int) {
-	return fileDescriptor_pdu_483c6918b7b3d747, []int{12}
+	return fileDescriptor_pdu_ee78635eea31df40, []int{12}
 }
 func (m *ReceiveRes) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_ReceiveRes.Unmarshal(m, b)
@@ -704,7 +732,7 @@ func (m *DestroySnapshotsReq) Reset()         { *m = DestroySnapshotsReq{} }
 func (m *DestroySnapshotsReq) String() string { return proto.CompactTextString(m) }
 func (*DestroySnapshotsReq) ProtoMessage()    {}
 func (*DestroySnapshotsReq) Descriptor() ([]byte, []int) {
-	return fileDescriptor_pdu_483c6918b7b3d747, []int{13}
+	return fileDescriptor_pdu_ee78635eea31df40, []int{13}
 }
 func (m *DestroySnapshotsReq) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_DestroySnapshotsReq.Unmarshal(m, b)
@@ -750,7 +778,7 @@ func (m *DestroySnapshotRes) Reset()         { *m = DestroySnapshotRes{} }
 func (m *DestroySnapshotRes) String() string { return proto.CompactTextString(m) }
 func (*DestroySnapshotRes) ProtoMessage()    {}
 func (*DestroySnapshotRes) Descriptor() ([]byte, []int) {
-	return fileDescriptor_pdu_483c6918b7b3d747, []int{14}
+	return fileDescriptor_pdu_ee78635eea31df40, []int{14}
 }
 func (m *DestroySnapshotRes) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_DestroySnapshotRes.Unmarshal(m, b)
@@ -795,7 +823,7 @@ func (m *DestroySnapshotsRes) Reset()         { *m = DestroySnapshotsRes{} }
 func (m *DestroySnapshotsRes) String() string { return proto.CompactTextString(m) }
 func (*DestroySnapshotsRes) ProtoMessage()    {}
 func (*DestroySnapshotsRes) Descriptor() ([]byte, []int) {
-	return fileDescriptor_pdu_483c6918b7b3d747, []int{15}
+	return fileDescriptor_pdu_ee78635eea31df40, []int{15}
 }
 func (m *DestroySnapshotsRes) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_DestroySnapshotsRes.Unmarshal(m, b)
@@ -833,7 +861,7 @@ func (m *ReplicationCursorReq) Reset()         { *m = ReplicationCursorReq{} }
 func (m *ReplicationCursorReq) String() string { return proto.CompactTextString(m) }
 func (*ReplicationCursorReq) ProtoMessage()    {}
 func (*ReplicationCursorReq) Descriptor() ([]byte, []int) {
-	return fileDescriptor_pdu_483c6918b7b3d747, []int{16}
+	return fileDescriptor_pdu_ee78635eea31df40, []int{16}
 }
 func (m *ReplicationCursorReq) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_ReplicationCursorReq.Unmarshal(m, b)
@@ -874,7 +902,7 @@ func (m *ReplicationCursorRes) Reset()         { *m = ReplicationCursorRes{} }
 func (m *ReplicationCursorRes) String() string { return proto.CompactTextString(m) }
 func (*ReplicationCursorRes) ProtoMessage()    {}
 func (*ReplicationCursorRes) Descriptor() ([]byte, []int) {
-	return fileDescriptor_pdu_483c6918b7b3d747, []int{17}
+	return fileDescriptor_pdu_ee78635eea31df40, []int{17}
 }
 func (m *ReplicationCursorRes) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_ReplicationCursorRes.Unmarshal(m, b)
@@ -1010,7 +1038,7 @@ func (m *PingReq) Reset()         { *m = PingReq{} }
 func (m *PingReq) String() string { return proto.CompactTextString(m) }
 func (*PingReq) ProtoMessage()    {}
 func (*PingReq) Descriptor() ([]byte, []int) {
-	return fileDescriptor_pdu_483c6918b7b3d747, []int{18}
+	return fileDescriptor_pdu_ee78635eea31df40, []int{18}
 }
 func (m *PingReq) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_PingReq.Unmarshal(m, b)
@@ -1049,7 +1077,7 @@ func (m *PingRes) Reset()         { *m = PingRes{} }
 func (m *PingRes) String() string { return proto.CompactTextString(m) }
 func (*PingRes) ProtoMessage()    {}
 func (*PingRes) Descriptor() ([]byte, []int) {
-	return fileDescriptor_pdu_483c6918b7b3d747, []int{19}
+	return fileDescriptor_pdu_ee78635eea31df40, []int{19}
 }
 func (m *PingRes) XXX_Unmarshal(b []byte) error {
 	return xxx_messageInfo_PingRes.Unmarshal(m, b)
@@ -1076,6 +1104,85 @@ func (m *PingRes) GetEcho() string {
 	return ""
 }
 
+type HoldPlannedVersionsReq struct {
+	Filesystem string `protobuf:"bytes,1,opt,name=Filesystem,proto3" json:"Filesystem,omitempty"`
+	// The snapshots of Filesystem that the remaining steps of the replication
+	// plan depend on. Holds on previously planned snapshots that are not in this
+	// list are released. An empty list releases all planned holds.
+	Versions             []*FilesystemVersion `protobuf:"bytes,2,rep,name=Versions,proto3" json:"Versions,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *HoldPlannedVersionsReq) Reset()         { *m = HoldPlannedVersionsReq{} }
+func (m *HoldPlannedVersionsReq) String() string { return proto.CompactTextString(m) }
+func (*HoldPlannedVersionsReq) ProtoMessage()    {}
+func (*HoldPlannedVersionsReq) Descriptor() ([]byte, []int) {
+	return fileDescriptor_pdu_ee78635eea31df40, []int{20}
+}
+func (m *HoldPlannedVersionsReq) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_HoldPlannedVersionsReq.Unmarshal(m, b)
+}
+func (m *HoldPlannedVersionsReq) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_HoldPlannedVersionsReq.Marshal(b, m, deterministic)
+}
+func (dst *HoldPlannedVersionsReq) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_HoldPlannedVersionsReq.Merge(dst, src)
+}
+func (m *HoldPlannedVersionsReq) XXX_Size() int {
+	return xxx_messageInfo_HoldPlannedVersionsReq.Size(m)
+}
+func (m *HoldPlannedVersionsReq) XXX_DiscardUnknown() {
+	xxx_messageInfo_HoldPlannedVersionsReq.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_HoldPlannedVersionsReq proto.InternalMessageInfo
+
+func (m *HoldPlannedVersionsReq) GetFilesystem() string {
+	if m != nil {
+		return m.Filesystem
+	}
+	return ""
+}
+
+func (m *HoldPlannedVersionsReq) GetVersions() []*FilesystemVersion {
+	if m != nil {
+		return m.Versions
+	}
+	return nil
+}
+
+type HoldPlannedVersionsRes struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *HoldPlannedVersionsRes) Reset()         { *m = HoldPlannedVersionsRes{} }
+func (m *HoldPlannedVersionsRes) String() string { return proto.CompactTextString(m) }
+func (*HoldPlannedVersionsRes) ProtoMessage()    {}
+func (*HoldPlannedVersionsRes) Descriptor() ([]byte, []int) {
+	return fileDescriptor_pdu_ee78635eea31df40, []int{21}
+}
+func (m *HoldPlannedVersionsRes) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_HoldPlannedVersionsRes.Unmarshal(m, b)
+}
+func (m *HoldPlannedVersionsRes) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_HoldPlannedVersionsRes.Marshal(b, m, deterministic)
+}
+func (dst *HoldPlannedVersionsRes) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_HoldPlannedVersionsRes.Merge(dst, src)
+}
+func (m *HoldPlannedVersionsRes) XXX_Size() int {
+	return xxx_messageInfo_HoldPlannedVersionsRes.Size(m)
+}
+func (m *HoldPlannedVersionsRes) XXX_DiscardUnknown() {
+	xxx_messageInfo_HoldPlannedVersionsRes.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_HoldPlannedVersionsRes proto.InternalMessageInfo
+
 func init() {
 	proto.RegisterType((*ListFilesystemReq)(nil), "ListFilesystemReq")
 	proto.RegisterType((*ListFilesystemRes)(nil), "ListFilesystemRes")
@@ -1097,6 +1204,8 @@ func init() {
 	proto.RegisterType((*ReplicationCursorRes)(nil), "ReplicationCursorRes")
 	proto.RegisterType((*PingReq)(nil), "PingReq")
 	proto.RegisterType((*PingRes)(nil), "PingRes")
+	proto.RegisterType((*HoldPlannedVersionsReq)(nil), "HoldPlannedVersionsReq")
+	proto.RegisterType((*HoldPlannedVersionsRes)(nil), "HoldPlannedVersionsRes")
 	proto.RegisterEnum("Tri", Tri_name, Tri_value)
 	proto.RegisterEnum("FilesystemVersion_VersionType", FilesystemVersion_VersionType_name, FilesystemVersion_VersionType_value)
 }
@@ -1119,6 +1228,7 @@ type ReplicationClient interface {
 	DestroySnapshots(ctx context.Context, in *DestroySnapshotsReq, opts ...grpc.CallOption) (*DestroySnapshotsRes, error)
 	ReplicationCursor(ctx context.Context, in *ReplicationCursorReq, opts ...grpc.CallOption) (*ReplicationCursorRes, error)
 	SendCompleted(ctx context.Context, in *SendCompletedReq, opts ...grpc.CallOption) (*SendCompletedRes, error)
+	HoldPlannedVersions(ctx context.Context, in *HoldPlannedVersionsReq, opts ...grpc.CallOption) (*HoldPlannedVersionsRes, error)
 }
 
 type replicationClient struct {
@@ -1183,6 +1293,15 @@ func (c *replicationClient) SendCompleted(ctx context.Context, in *SendCompleted
 	return out, nil
 }
 
+func (c *replicationClient) HoldPlannedVersions(ctx context.Context, in *HoldPlannedVersionsReq, opts ...grpc.CallOption) (*HoldPlannedVersionsRes, error) {
+	out := new(HoldPlannedVersionsRes)
+	err := c.cc.Invoke(ctx, "/Replication/HoldPlannedVersions", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ReplicationServer is the server API for Replication service.
 type ReplicationServer interface {
 	Ping(context.Context, *PingReq) (*PingRes, error)
@@ -1191,6 +1310,7 @@ type ReplicationServer interface {
 	DestroySnapshots(context.Context, *DestroySnapshotsReq) (*DestroySnapshotsRes, error)
 	ReplicationCursor(context.Context, *ReplicationCursorReq) (*ReplicationCursorRes, error)
 	SendCompleted(context.Context, *SendCompletedReq) (*SendCompletedRes, error)
+	HoldPlannedVersions(context.Context, *HoldPlannedVersionsReq) (*HoldPlannedVersionsRes, error)
 }
 
 func RegisterReplicationServer(s *grpc.Server, srv ReplicationServer) {
@@ -1305,6 +1425,24 @@ func _Replication_SendCompleted_Handler(srv interface{}, ctx context.Context, de
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Replication_HoldPlannedVersions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HoldPlannedVersionsReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ReplicationServer).HoldPlannedVersions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/Replication/HoldPlannedVersions",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ReplicationServer).HoldPlannedVersions(ctx, req.(*HoldPlannedVersionsReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _Replication_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "Replication",
 	HandlerType: (*ReplicationServer)(nil),
@@ -1333,66 +1471,74 @@ var _Replication_serviceDesc = grpc.ServiceDesc{
 			MethodName: "SendCompleted",
 			Handler:    _Replication_SendCompleted_Handler,
 		},
+		{
+			MethodName: "HoldPlannedVersions",
+			Handler:    _Replication_HoldPlannedVersions_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "pdu.proto",
 }
 
-func init() { proto.RegisterFile("pdu.proto", fileDescriptor_pdu_483c6918b7b3d747) }
-
-var fileDescriptor_pdu_483c6918b7b3d747 = []byte{
-	// 833 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x8c, 0x56, 0x5f, 0x6f, 0xe3, 0x44,
-	0x10, 0xaf, 0x13, 0xa7, 0x75, 0x26, 0x3d, 0x2e, 0x9d, 0x96, 0x93, 0xb1, 0xe0, 0x54, 0x2d, 0x08,
-	0xe5, 0x2a, 0x61, 0xa1, 0xf2, 0x47, 0x42, 0x48, 0x27, 0xd1, 0xb4, 0xbd, 0x3b, 0x01, 0x47, 0xb4,
-	0x35, 0x27, 0x74, 0x6f, 0x26, 0x19, 0xb5, 0x56, 0x1d, 0xaf, 0xbb, 0xe3, 0xa0, 0x0b, 0xe2, 0x89,
-	0x47, 0xbe, 0x1e, 0x7c, 0x10, 0x3e, 0x02, 0xf2, 0xc6, 0x4e, 0x9c, 0xd8, 0x41, 0x79, 0xca, 0xce,
-	0x6f, 0x66, 0x77, 0x67, 0x7f, 0xf3, 0x9b, 0x71, 0xa0, 0x9b, 0x4e, 0x66, 0x7e, 0xaa, 0x55, 0xa6,
-	0xc4, 0x31, 0x1c, 0xfd, 0x10, 0x71, 0x76, 0x1d, 0xc5, 0xc4, 0x73, 0xce, 0x68, 0x2a, 0xe9, 0x41,
-	0x5c, 0xd4, 0x41, 0xc6, 0xcf, 0xa0, 0xb7, 0x02, 0xd8, 0xb5, 0x4e, 0xdb, 0x83, 0xde, 0x79, 0xcf,
-	0xaf, 0x04, 0x55, 0xfd, 0xe2, 0x2f, 0x0b, 0x60, 0x65, 0x23, 0x82, 0x3d, 0x0a, 0xb3, 0x3b, 0xd7,
-	0x3a, 0xb5, 0x06, 0x5d, 0x69, 0xd6, 0x78, 0x0a, 0x3d, 0x49, 0x3c, 0x9b, 0x52, 0xa0, 0xee, 0x29,
-	0x71, 0x5b, 0xc6, 0x55, 0x85, 0xf0, 0x13, 0x78, 0xf4, 0x8a, 0x47, 0x71, 0x38, 0xa6, 0x3b, 0x15,
-	0x4f, 0x48, 0xbb, 0xed, 0x53, 0x6b, 0xe0, 0xc8, 0x75, 0x30, 0x3f, 0xe7, 0x15, 0x5f, 0x25, 0x63,
-	0x3d, 0x4f, 0x33, 0x9a, 0xb8, 0xb6, 0x89, 0xa9, 0x42, 0xe2, 0x5b, 0xf8, 0x60, 0xfd, 0x41, 0x6f,
-	0x48, 0x73, 0xa4, 0x12, 0x96, 0xf4, 0x80, 0x4f, 0xab, 0x89, 0x16, 0x09, 0x56, 0x10, 0xf1, 0xfd,
-	0xf6, 0xcd, 0x8c, 0x3e, 0x38, 0xa5, 0x59, 0x50, 0x82, 0x7e, 0x2d, 0x52, 0x2e, 0x63, 0xc4, 0x3f,
-	0x16, 0x1c, 0xd5, 0xfc, 0x78, 0x0e, 0x76, 0x30, 0x4f, 0xc9, 0x5c, 0xfe, 0xde, 0xf9, 0xd3, 0xfa,
-	0x09, 0x7e, 0xf1, 0x9b, 0x47, 0x49, 0x13, 0x9b, 0x33, 0xfa, 0x3a, 0x9c, 0x52, 0x41, 0x9b, 0x59,
-	0xe7, 0xd8, 0x8b, 0x59, 0x34, 0x31, 0x34, 0xd9, 0xd2, 0xac, 0xf1, 0x43, 0xe8, 0x0e, 0x35, 0x85,
-	0x19, 0x05, 0xbf, 0xbc, 0x30, 0xdc, 0xd8, 0x72, 0x05, 0xa0, 0x07, 0x8e, 0x31, 0x22, 0x95, 0xb8,
-	0x1d, 0x73, 0xd2, 0xd2, 0x16, 0xcf, 0xa0, 0x57, 0xb9, 0x16, 0x0f, 0xc1, 0xb9, 0x49, 0xc2, 0x94,
-	0xef, 0x54, 0xd6, 0xdf, 0xcb, 0xad, 0x0b, 0xa5, 0xee, 0xa7, 0xa1, 0xbe, 0xef, 0x5b, 0xe2, 0x6f,
-	0x0b, 0x0e, 0x6e, 0x28, 0x99, 0xec, 0xc0, 0x27, 0x7e, 0x0a, 0xf6, 0xb5, 0x56, 0x53, 0x93, 0x78,
-	0x33, 0x5d, 0xc6, 0x8f, 0x02, 0x5a, 0x81, 0x32, 0x4f, 0x69, 0x8e, 0x6a, 0x05, 0x6a, 0x53, 0x42,
-	0x76, 0x5d, 0x42, 0x02, 0xba, 0x2b, 0x69, 0x74, 0x0c, 0xbf, 0xb6, 0x1f, 0xe8, 0x48, 0xae, 0x60,
-	0x7c, 0x02, 0xfb, 0x97, 0x7a, 0x2e, 0x67, 0x89, 0xbb, 0x6f, 0xb4, 0x53, 0x58, 0xe2, 0x4b, 0x70,
-	0x46, 0x5a, 0xa5, 0xa4, 0xb3, 0xf9, 0x92, 0x6e, 0xab, 0x42, 0xf7, 0x09, 0x74, 0xde, 0x84, 0xf1,
-	0xac, 0xac, 0xc1, 0xc2, 0x10, 0x7f, 0x2e, 0xb9, 0x60, 0x1c, 0xc0, 0xe3, 0x9f, 0x99, 0x26, 0x9b,
-	0x32, 0x77, 0xe4, 0x26, 0x8c, 0x02, 0x0e, 0xaf, 0xde, 0xa5, 0x34, 0xce, 0x68, 0x72, 0x13, 0xfd,
-	0x4e, 0xe6, 0xdd, 0x6d, 0xb9, 0x86, 0xe1, 0x33, 0x80, 0x22, 0x9f, 0x88, 0xd8, 0xb5, 0x8d, 0xdc,
-	0xba, 0x7e, 0x99, 0xa2, 0xac, 0x38, 0xc5, 0x73, 0xe8, 0xe7, 0x39, 0x0c, 0xd5, 0x34, 0x8d, 0x29,
-	0x23, 0x53, 0x98, 0x33, 0xe8, 0xfd, 0xa4, 0xa3, 0xdb, 0x28, 0x09, 0x63, 0x49, 0x0f, 0x05, 0xff,
-	0x8e, 0x5f, 0xd4, 0x4d, 0x56, 0x9d, 0x02, 0x6b, 0xfb, 0x59, 0xfc, 0x01, 0x20, 0x69, 0x4c, 0xd1,
-	0x6f, 0xb4, 0x4b, 0x99, 0x17, 0xe5, 0x6b, 0xfd, 0x6f, 0xf9, 0xce, 0xa0, 0x3f, 0x8c, 0x29, 0xd4,
-	0x55, 0x7e, 0x16, 0x2d, 0x5e, 0xc3, 0xc5, 0x61, 0xe5, 0x76, 0x16, 0xb7, 0x70, 0x7c, 0x49, 0x9c,
-	0x69, 0x35, 0x2f, 0x35, 0xb9, 0x4b, 0x2f, 0xe3, 0xe7, 0xd0, 0x5d, 0xc6, 0xbb, 0xad, 0xad, 0xfd,
-	0xba, 0x0a, 0x12, 0x6f, 0x01, 0x37, 0x2e, 0x2a, 0xda, 0xbe, 0x34, 0xcd, 0x2d, 0x5b, 0xda, 0xbe,
-	0x8c, 0xc9, 0x95, 0x72, 0xa5, 0xb5, 0xd2, 0xa5, 0x52, 0x8c, 0x21, 0x2e, 0x9b, 0x1e, 0x91, 0x4f,
-	0xda, 0x83, 0xfc, 0xe1, 0x71, 0x56, 0x8e, 0x94, 0x63, 0xbf, 0x9e, 0x82, 0x2c, 0x63, 0xc4, 0xd7,
-	0x70, 0x22, 0x29, 0x8d, 0xa3, 0xb1, 0xe9, 0xda, 0xe1, 0x4c, 0xb3, 0xd2, 0xbb, 0xcc, 0xb5, 0xa0,
-	0x71, 0x1f, 0xe3, 0x49, 0x31, 0x44, 0xf2, 0x1d, 0xf6, 0xcb, 0xbd, 0xe5, 0x18, 0x71, 0x5e, 0xab,
-	0x8c, 0xde, 0x45, 0x9c, 0x2d, 0x24, 0xfc, 0x72, 0x4f, 0x2e, 0x91, 0x0b, 0x07, 0xf6, 0x17, 0xe9,
-	0x88, 0x8f, 0xe1, 0x60, 0x14, 0x25, 0xb7, 0x79, 0x02, 0x2e, 0x1c, 0xfc, 0x48, 0xcc, 0xe1, 0x6d,
-	0xd9, 0x35, 0xa5, 0x29, 0x3e, 0x2a, 0x83, 0x38, 0xef, 0xab, 0xab, 0xf1, 0x9d, 0x2a, 0xfb, 0x2a,
-	0x5f, 0x9f, 0x0d, 0xa0, 0x1d, 0xe8, 0x28, 0x1f, 0x31, 0x97, 0x2a, 0xc9, 0x86, 0xa1, 0xa6, 0xfe,
-	0x1e, 0x76, 0xa1, 0x73, 0x1d, 0xc6, 0x4c, 0x7d, 0x0b, 0x1d, 0xb0, 0x03, 0x3d, 0xa3, 0x7e, 0xeb,
-	0xfc, 0xdf, 0x56, 0x3e, 0x00, 0x96, 0x8f, 0x40, 0x0f, 0xec, 0xfc, 0x60, 0x74, 0xfc, 0x22, 0x09,
-	0xaf, 0x5c, 0x31, 0x7e, 0x03, 0x8f, 0xd7, 0xe7, 0x38, 0x23, 0xfa, 0xb5, 0x8f, 0x9f, 0x57, 0xc7,
-	0x18, 0x47, 0xf0, 0xa4, 0xf9, 0x13, 0x80, 0x9e, 0xbf, 0xf5, 0xc3, 0xe2, 0x6d, 0xf7, 0x31, 0x3e,
-	0x87, 0xfe, 0x66, 0xe9, 0xf1, 0xc4, 0x6f, 0x90, 0xb4, 0xd7, 0x84, 0x32, 0x7e, 0x07, 0x47, 0xb5,
-	0xe2, 0xe1, 0xfb, 0x7e, 0x93, 0x10, 0xbc, 0x46, 0x98, 0xf1, 0x2b, 0x78, 0xb4, 0xd6, 0xe2, 0x78,
-	0xe4, 0x6f, 0x8e, 0x0c, 0xaf, 0x06, 0xf1, 0x45, 0xe7, 0x6d, 0x3b, 0x9d, 0xcc, 0x7e, 0xdd, 0x37,
-	0xff, 0x1f, 0xbe, 0xf8, 0x2f, 0x00, 0x00, 0xff, 0xff, 0x27, 0x95, 0xc1, 0x78, 0x4c, 0x08, 0x00,
-	0x00,
+func init() { proto.RegisterFile("pdu.proto", fileDescriptor_pdu_ee78635eea31df40) }
+
+var fileDescriptor_pdu_ee78635eea31df40 = []byte{
+	// 908 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x56, 0xdb, 0x8e, 0xe3, 0x44,
+	0x10, 0x1d, 0x27, 0x9e, 0xc4, 0xa9, 0xcc, 0xb2, 0x99, 0x9a, 0x30, 0x18, 0x0b, 0x56, 0x51, 0x83,
+	0x50, 0x36, 0x12, 0x16, 0x0a, 0x17, 0x09, 0x21, 0xad, 0xc4, 0x64, 0x6e, 0x2b, 0x60, 0x89, 0x7a,
+	0xc2, 0x0a, 0xed, 0x9b, 0x89, 0x4b, 0x13, 0x6b, 0x1c, 0xb7, 0xa7, 0xdb, 0x41, 0x1b, 0xc4, 0x13,
+	0x2f, 0x7c, 0x13, 0xff, 0xc1, 0x17, 0xf0, 0x25, 0xc8, 0x1d, 0x3b, 0x71, 0x62, 0x07, 0x85, 0xa7,
+	0x74, 0x9d, 0x2a, 0xa7, 0xaa, 0xab, 0x4e, 0x1d, 0x1b, 0x5a, 0xb1, 0xbf, 0x70, 0x63, 0x29, 0x12,
+	0xc1, 0xce, 0xe0, 0xf4, 0xfb, 0x40, 0x25, 0xd7, 0x41, 0x48, 0x6a, 0xa9, 0x12, 0x9a, 0x73, 0x7a,
+	0x64, 0x17, 0x65, 0x50, 0xe1, 0xa7, 0xd0, 0xde, 0x00, 0xca, 0x36, 0x7a, 0xf5, 0x7e, 0x7b, 0xd8,
+	0x76, 0x0b, 0x41, 0x45, 0x3f, 0xfb, 0xc7, 0x00, 0xd8, 0xd8, 0x88, 0x60, 0x8e, 0xbd, 0x64, 0x66,
+	0x1b, 0x3d, 0xa3, 0xdf, 0xe2, 0xfa, 0x8c, 0x3d, 0x68, 0x73, 0x52, 0x8b, 0x39, 0x4d, 0xc4, 0x03,
+	0x45, 0x76, 0x4d, 0xbb, 0x8a, 0x10, 0x7e, 0x0c, 0x4f, 0x5e, 0xaa, 0x71, 0xe8, 0x4d, 0x69, 0x26,
+	0x42, 0x9f, 0xa4, 0x5d, 0xef, 0x19, 0x7d, 0x8b, 0x6f, 0x83, 0xe9, 0xff, 0xbc, 0x54, 0x57, 0xd1,
+	0x54, 0x2e, 0xe3, 0x84, 0x7c, 0xdb, 0xd4, 0x31, 0x45, 0x08, 0x07, 0xd0, 0xf9, 0x51, 0x06, 0xf7,
+	0x41, 0xb4, 0xa9, 0xc8, 0x3e, 0xd6, 0xe9, 0x4a, 0x38, 0x0e, 0xa0, 0xb1, 0xc2, 0xec, 0x46, 0xcf,
+	0xe8, 0xb7, 0x87, 0x58, 0xb8, 0xe2, 0x6b, 0x92, 0x2a, 0x10, 0x11, 0xcf, 0x22, 0xd8, 0x37, 0xf0,
+	0xfe, 0x76, 0xa3, 0xb2, 0x00, 0xc5, 0xe9, 0x11, 0x9f, 0x15, 0x1b, 0x90, 0x5d, 0xbc, 0x80, 0xb0,
+	0xef, 0xf6, 0x3f, 0xac, 0xd0, 0x05, 0x2b, 0x37, 0xb3, 0x56, 0x57, 0xd5, 0xb1, 0x8e, 0x61, 0x7f,
+	0x1b, 0x70, 0x5a, 0xf2, 0xe3, 0x10, 0xcc, 0xc9, 0x32, 0x26, 0x9d, 0xfc, 0x9d, 0xe1, 0xb3, 0xf2,
+	0x3f, 0xb8, 0xd9, 0x6f, 0x1a, 0xc5, 0x75, 0x6c, 0x3a, 0xa9, 0x57, 0xde, 0x9c, 0xb2, 0x71, 0xe8,
+	0x73, 0x8a, 0xdd, 0x2c, 0x02, 0x5f, 0xb7, 0xdf, 0xe4, 0xfa, 0x8c, 0x1f, 0x40, 0x6b, 0x24, 0xc9,
+	0x4b, 0x68, 0xf2, 0xf3, 0x8d, 0xee, 0xb9, 0xc9, 0x37, 0x00, 0x3a, 0x60, 0x69, 0x23, 0x10, 0x51,
+	0xd6, 0xe9, 0xb5, 0xcd, 0x9e, 0x43, 0xbb, 0x90, 0x16, 0x4f, 0xc0, 0xba, 0x8b, 0xbc, 0x58, 0xcd,
+	0x44, 0xd2, 0x39, 0x4a, 0xad, 0x0b, 0x21, 0x1e, 0xe6, 0x9e, 0x7c, 0xe8, 0x18, 0xec, 0xcf, 0x1a,
+	0x34, 0xef, 0x28, 0xf2, 0x0f, 0xe8, 0x27, 0x7e, 0x02, 0xe6, 0xb5, 0x14, 0x73, 0x5d, 0x78, 0x75,
+	0xbb, 0xb4, 0x1f, 0x19, 0xd4, 0x26, 0xc2, 0xae, 0xef, 0x8d, 0xaa, 0x4d, 0xc4, 0x2e, 0x35, 0xcd,
+	0x32, 0x35, 0x19, 0xb4, 0x36, 0x94, 0x3b, 0xd6, 0xfd, 0x35, 0xdd, 0x89, 0x0c, 0xf8, 0x06, 0xc6,
+	0x73, 0x68, 0x5c, 0xca, 0x25, 0x5f, 0xac, 0xa8, 0x64, 0xf1, 0xcc, 0xc2, 0x21, 0x74, 0xd3, 0x4a,
+	0x4a, 0x94, 0x6c, 0xea, 0x34, 0x95, 0x3e, 0xf6, 0x05, 0x58, 0x63, 0x29, 0x62, 0x92, 0xc9, 0x72,
+	0x3d, 0x22, 0xa3, 0x30, 0xa2, 0x2e, 0x1c, 0xbf, 0xf6, 0xc2, 0x45, 0x3e, 0xb7, 0x95, 0xc1, 0xfe,
+	0x30, 0xf2, 0xfe, 0x29, 0xec, 0xc3, 0xd3, 0x9f, 0x14, 0xf9, 0xbb, 0x2b, 0x67, 0xf1, 0x5d, 0x18,
+	0x19, 0x9c, 0x5c, 0xbd, 0x8d, 0x69, 0x9a, 0x90, 0x7f, 0x17, 0xfc, 0x46, 0xba, 0x57, 0x75, 0xbe,
+	0x85, 0xe1, 0x73, 0x80, 0xac, 0x9e, 0x80, 0x94, 0x6d, 0x6a, 0x8a, 0xb6, 0xdc, 0xbc, 0x44, 0x5e,
+	0x70, 0xb2, 0x17, 0xd0, 0x49, 0x6b, 0x18, 0x89, 0x79, 0x1c, 0x52, 0x42, 0x7a, 0x98, 0x03, 0x68,
+	0xaf, 0xae, 0xe8, 0x85, 0x9c, 0x1e, 0xb3, 0x99, 0x59, 0x6e, 0x36, 0x6b, 0x5e, 0x74, 0x32, 0x2c,
+	0x3d, 0xaf, 0xd8, 0xef, 0x00, 0x9c, 0xa6, 0x14, 0xfc, 0x4a, 0x87, 0x50, 0x63, 0x35, 0xf2, 0xda,
+	0x7f, 0x8e, 0x7c, 0x00, 0x9d, 0x51, 0x48, 0x9e, 0x2c, 0xf6, 0x67, 0x25, 0x37, 0x25, 0x9c, 0x9d,
+	0x14, 0xb2, 0x2b, 0x76, 0x0f, 0x67, 0x97, 0xa4, 0x12, 0x29, 0x96, 0x39, 0x8f, 0x0f, 0xd9, 0x7f,
+	0xfc, 0x0c, 0x5a, 0xeb, 0x78, 0xbb, 0xb6, 0x77, 0xc7, 0x37, 0x41, 0xec, 0x0d, 0xe0, 0x4e, 0xa2,
+	0x4c, 0x2a, 0x72, 0x53, 0x67, 0xd9, 0x23, 0x15, 0x79, 0x4c, 0xca, 0x94, 0x2b, 0x29, 0x85, 0xcc,
+	0x99, 0xa2, 0x0d, 0x76, 0x59, 0x75, 0x89, 0x54, 0xf5, 0x9b, 0xe9, 0xc5, 0xc3, 0x24, 0x97, 0xa1,
+	0x33, 0xb7, 0x5c, 0x02, 0xcf, 0x63, 0xd8, 0x57, 0xd0, 0xe5, 0x14, 0x87, 0xc1, 0x54, 0x6f, 0xfa,
+	0x68, 0x21, 0x95, 0x90, 0x87, 0x68, 0xe1, 0xa4, 0xf2, 0x39, 0x85, 0xdd, 0x4c, 0x78, 0xd2, 0x27,
+	0xcc, 0xdb, 0xa3, 0xb5, 0xf4, 0x58, 0xaf, 0x44, 0x42, 0x6f, 0x03, 0x95, 0xac, 0x28, 0x7c, 0x7b,
+	0xc4, 0xd7, 0xc8, 0x85, 0x05, 0x8d, 0x55, 0x39, 0xec, 0x23, 0x68, 0x8e, 0x83, 0xe8, 0x3e, 0x2d,
+	0xc0, 0x86, 0xe6, 0x0f, 0xa4, 0x94, 0x77, 0x9f, 0x6f, 0x4d, 0x6e, 0xb2, 0x0f, 0xf3, 0x20, 0x95,
+	0xee, 0xd5, 0xd5, 0x74, 0x26, 0xf2, 0xbd, 0x4a, 0xcf, 0x6c, 0x06, 0xe7, 0xb7, 0x22, 0xf4, 0xc7,
+	0xa1, 0x17, 0x45, 0xe4, 0xff, 0x0f, 0x7d, 0xdf, 0x92, 0xf0, 0xda, 0x01, 0x12, 0x6e, 0xef, 0xc9,
+	0xa4, 0x06, 0x7d, 0xa8, 0x4f, 0x64, 0x90, 0x4a, 0xe3, 0xa5, 0x88, 0x92, 0x91, 0x27, 0xa9, 0x73,
+	0x84, 0x2d, 0x38, 0xbe, 0xf6, 0x42, 0x45, 0x1d, 0x03, 0x2d, 0x30, 0x27, 0x72, 0x41, 0x9d, 0xda,
+	0xf0, 0xaf, 0x3a, 0xb4, 0x0b, 0x8d, 0x44, 0x07, 0xcc, 0xf4, 0x72, 0x68, 0xb9, 0x59, 0x23, 0x9c,
+	0xfc, 0xa4, 0xf0, 0x6b, 0x78, 0xba, 0xfd, 0xfe, 0x51, 0x88, 0x6e, 0xe9, 0x63, 0xc0, 0x29, 0x63,
+	0x0a, 0xc7, 0x70, 0x5e, 0xfd, 0xea, 0x42, 0xc7, 0xdd, 0xfb, 0x42, 0x74, 0xf6, 0xfb, 0x14, 0xbe,
+	0x80, 0xce, 0x2e, 0xfd, 0xb0, 0xeb, 0x56, 0xac, 0x95, 0x53, 0x85, 0x2a, 0xfc, 0x16, 0x4e, 0x4b,
+	0x04, 0xc2, 0x77, 0xdd, 0x2a, 0x32, 0x3a, 0x95, 0xb0, 0xc2, 0x2f, 0xe1, 0xc9, 0x96, 0xcc, 0xe0,
+	0xa9, 0xbb, 0x2b, 0x5b, 0x4e, 0x09, 0x52, 0x78, 0x03, 0x67, 0x15, 0x63, 0xc3, 0xf7, 0xdc, 0x6a,
+	0xda, 0x38, 0x7b, 0x1c, 0xea, 0xe2, 0xf8, 0x4d, 0x3d, 0xf6, 0x17, 0xbf, 0x34, 0xf4, 0x87, 0xd9,
+	0xe7, 0xff, 0x0e, 0x00, 0x51, 0x9d, 0xd0, 0x1c, 0xa5, 0x09, 0x00, 0x00,
 }
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"sync"
 	"time"
 
@@ -43,6 +44,9 @@ type Sender interface {
 	Send(ctx context.Context, r *pdu.SendReq) (*pdu.SendRes, io.ReadCloser, error)
 	SendCompleted(ctx context.Context, r *pdu.SendCompletedReq) (*pdu.SendCompletedRes, error)
 	ReplicationCursor(ctx context.Context, req *pdu.ReplicationCursorReq) (*pdu.ReplicationCursorRes, error)
+	// Holds exactly the snapshots in req.Versions on behalf of the replication plan,
+	// releasing previous such holds for req.Filesystem.
+	HoldPlannedVersions(ctx context.Context, req *pdu.HoldPlannedVersionsReq) (*pdu.HoldPlannedVersionsRes, error)
 }
 
 type Receiver interface {
@@ -53,7 +57,38 @@ type Receiver interface {
 }
 
 type PlannerPolicy struct {
-	EncryptedSend tri // all sends must be encrypted (send -w, and encryption!=off)
+	EncryptedSend  tri // all sends must be encrypted (send -w, and encryption!=off)
+	CompressedSend tri // all non-raw sends must be compressed (send -c)
+}
+
+// resumeTokenMatchesPolicy returns an error if resuming with token would produce a send
+// that does not match policy.
+// Raw sends are always compressed, hence `compressok` is only checked for non-raw sends.
+func resumeTokenMatchesPolicy(token *zfs.ResumeToken, policy PlannerPolicy) error {
+	switch policy.EncryptedSend {
+	case True:
+		if !token.RawOK {
+			return fmt.Errorf("resume token `rawok`=%v is incompatible with encryption policy=%v", token.RawOK, policy.EncryptedSend)
+		}
+	case False:
+		if token.RawOK {
+			return fmt.Errorf("resume token `rawok`=%v is incompatible with encryption policy=%v", token.RawOK, policy.EncryptedSend)
+		}
+	}
+	if token.RawOK {
+		return nil
+	}
+	switch policy.CompressedSend {
+	case True:
+		if !token.CompressOK {
+			return fmt.Errorf("resume token `compressok`=%v is incompatible with compression policy=%v", token.CompressOK, policy.CompressedSend)
+		}
+	case False:
+		if token.CompressOK {
+			return fmt.Errorf("resume token `compressok`=%v is incompatible with compression policy=%v", token.CompressOK, policy.CompressedSend)
+		}
+	}
+	return nil
 }
 
 type Planner struct {
@@ -63,6 +98,7 @@ type Planner struct {
 
 	promSecsPerState    *prometheus.HistogramVec // labels: state
 	promBytesReplicated *prometheus.CounterVec   // labels: filesystem
+	promReplicationLag  *prometheus.GaugeVec     // labels: filesystem
 }
 
 func (p *Planner) Plan(ctx context.Context) ([]driver.FS, error) {
@@ -127,6 +163,13 @@ type Filesystem struct {
 	promBytesReplicated  prometheus.Counter // compat
 
 	sizeEstimateRequestSem *semaphore.S
+
+	// the clone origin of senderFS if it is part of the replicated filesystems, nil otherwise
+	cloneOrigin *pdu.FilesystemVersion
+
+	plannedHolds *plannedHolds // nil if the plan has fewer than two steps
+
+	lag *replicationLag
 }
 
 func (f *Filesystem) EqualToPreviousAttempt(other driver.FS) bool {
@@ -149,8 +192,31 @@ func (f *Filesystem) PlanFS(ctx context.Context) ([]driver.Step, error) {
 	}
 	return dsteps, nil
 }
+
+var _ driver.FSPlanReleaser = (*Filesystem)(nil)
+
+func (f *Filesystem) ReleasePlan(ctx context.Context) {
+	if f.plannedHolds != nil {
+		f.plannedHolds.releaseAll(ctx)
+	}
+}
+
 func (f *Filesystem) ReportInfo() *report.FilesystemInfo {
-	return &report.FilesystemInfo{Name: f.Path} // FIXME compat name
+	return &report.FilesystemInfo{
+		Name:           f.Path, // FIXME compat name
+		ReplicationLag: f.lag.report(),
+	}
+}
+
+var _ driver.FSWithInitialReplicationDependency = (*Filesystem)(nil)
+
+// InitialReplicationDependency returns the clone origin's filesystem so that
+// the origin is replicated before the clone is sent relative to it.
+func (f *Filesystem) InitialReplicationDependency() string {
+	if f.cloneOrigin == nil {
+		return ""
+	}
+	return f.senderFS.GetOriginFilesystem()
 }
 
 type Step struct {
@@ -158,9 +224,19 @@ type Step struct {
 	receiver Receiver
 
 	parent      *Filesystem
+	idx         int                    // index in the parent's plan
 	from, to    *pdu.FilesystemVersion // from may be nil, indicating full send
 	encrypt     tri
 	resumeToken string // empty means no resume token shall be used
+	// bytes of the resumed stream received in a previous attempt, 0 if not resumed or unknown
+	resumeOffset int64
+
+	// If not nil, from is nil and the step is a clone-preserving send relative to
+	// the snapshot fromOrigin of filesystem fromOriginFS.
+	// Reset to nil by doReplication if the receiver does not have the origin
+	// => concurrent reads from Step.ReportInfo must be protected by byteCounterMtx.
+	fromOrigin   *pdu.FilesystemVersion
+	fromOriginFS string
 
 	expectedSize int64 // 0 means no size estimate present / possible
 
@@ -194,10 +270,14 @@ func (s *Step) ReportInfo() *report.StepInfo {
 
 	// get current byteCounter value
 	var byteCounter int64
+	var fromOrigin string
 	s.byteCounterMtx.Lock()
 	if s.byteCounter != nil {
 		byteCounter = s.byteCounter.Count()
 	}
+	if s.fromOrigin != nil {
+		fromOrigin = s.fromOriginFS + s.fromOrigin.RelName()
+	}
 	s.byteCounterMtx.Unlock()
 
 	from := ""
@@ -215,23 +295,32 @@ func (s *Step) ReportInfo() *report.StepInfo {
 	default:
 		panic(fmt.Sprintf("unknown variant %s", s.encrypt))
 	}
+	// the size estimate of a resumed send (zfs send -nvP -t) covers only the remainder of the stream
+	bytesExpected := s.expectedSize
+	if bytesExpected > 0 {
+		bytesExpected += s.resumeOffset
+	}
 	return &report.StepInfo{
-		From:            from,
-		To:              s.to.RelName(),
-		Resumed:         s.resumeToken != "",
-		Encrypted:       encrypted,
-		BytesExpected:   s.expectedSize,
-		BytesReplicated: byteCounter,
+		From:              from,
+		FromBookmark:      s.from != nil && s.from.Type == pdu.FilesystemVersion_Bookmark,
+		FromOrigin:        fromOrigin,
+		To:                s.to.RelName(),
+		Resumed:           s.resumeToken != "",
+		Encrypted:         encrypted,
+		BytesExpected:     bytesExpected,
+		BytesReplicated:   byteCounter + s.resumeOffset,
+		BytesResumeOffset: s.resumeOffset,
 	}
 }
 
-func NewPlanner(secsPerState *prometheus.HistogramVec, bytesReplicated *prometheus.CounterVec, sender Sender, receiver Receiver, policy PlannerPolicy) *Planner {
+func NewPlanner(secsPerState *prometheus.HistogramVec, bytesReplicated *prometheus.CounterVec, replicationLag *prometheus.GaugeVec, sender Sender, receiver Receiver, policy PlannerPolicy) *Planner {
 	return &Planner{
 		sender:              sender,
 		receiver:            receiver,
 		policy:              policy,
 		promSecsPerState:    secsPerState,
 		promBytesReplicated: bytesReplicated,
+		promReplicationLag:  replicationLag,
 	}
 }
 func resolveConflict(conflict error) (path []*pdu.FilesystemVersion, msg string) {
@@ -277,6 +366,11 @@ func (p *Planner) doPlanning(ctx context.Context) ([]*Filesystem, error) {
 
 	sizeEstimateRequestSem := semaphore.New(envconst.Int64("ZREPL_REPLICATION_MAX_CONCURRENT_SIZE_ESTIMATE", 4))
 
+	senderPaths := make(map[string]bool, len(sfss))
+	for _, fs := range sfss {
+		senderPaths[fs.Path] = true
+	}
+
 	q := make([]*Filesystem, 0, len(sfss))
 	for _, fs := range sfss {
 
@@ -291,6 +385,21 @@ func (p *Planner) doPlanning(ctx context.Context) ([]*Filesystem, error) {
 		if p.promBytesReplicated != nil {
 			ctr = p.promBytesReplicated.WithLabelValues(fs.Path)
 		}
+		var lagGauge prometheus.Gauge
+		if p.promReplicationLag != nil {
+			lagGauge = p.promReplicationLag.WithLabelValues(fs.Path)
+		}
+
+		var cloneOrigin *pdu.FilesystemVersion
+		if fs.GetOrigin() != nil {
+			if senderPaths[fs.GetOriginFilesystem()] {
+				cloneOrigin = fs.GetOrigin()
+			} else {
+				log.WithField("filesystem", fs.Path).
+					WithField("origin", fs.GetOriginFilesystem()+fs.GetOrigin().RelName()).
+					Info("clone origin is not replicated, clone will be replicated without its origin")
+			}
+		}
 
 		q = append(q, &Filesystem{
 			sender:                 p.sender,
@@ -301,12 +410,76 @@ func (p *Planner) doPlanning(ctx context.Context) ([]*Filesystem, error) {
 			receiverFS:             receiverFS,
 			promBytesReplicated:    ctr,
 			sizeEstimateRequestSem: sizeEstimateRequestSem,
+			cloneOrigin:            cloneOrigin,
+			lag:                    newReplicationLag(lagGauge),
 		})
 	}
 
 	return q, nil
 }
 
+// checkSenderFSComplete checks whether the sender filesystem is the receiving side of another replication
+// (cascaded replication, A => B => C) that has not completed, and returns the createtxg up to which (inclusive)
+// the versions in sfsvs are safe to send (math.MaxUint64 if all are).
+//
+// If the sender filesystem has a partially received stream, the versions up to the stream's incremental source
+// are complete, but the partially received snapshot and anything after it are not, see senderSafeCreateTXG.
+// (Placeholders, which have no data at all, are rejected before the versions are listed.)
+//
+// Senders of older zrepl versions do not report the resume token, so the check always passes for them.
+func (fs *Filesystem) checkSenderFSComplete(ctx context.Context, sfsvs []*pdu.FilesystemVersion) (safeUntilTXG uint64, err error) {
+	raw := fs.senderFS.GetResumeToken()
+	if raw == "" {
+		return math.MaxUint64, nil
+	}
+	token, err := zfs.ParseResumeToken(ctx, raw)
+	if err != nil {
+		getLogger(ctx).WithField("filesystem", fs.Path).WithError(err).Debug("cannot decode sender filesystem resume token")
+		return 0, errors.New(senderFSPartiallyReceivedMsg)
+	}
+	return senderSafeCreateTXG(token, sfsvs)
+}
+
+const senderFSPartiallyReceivedMsg = "sender filesystem has a partially received stream (receive_resume_token is set): complete the replication to the sender first"
+
+// senderSafeCreateTXG returns the createtxg of the incremental source of token, the resume token of
+// a partially received stream on the sender filesystem whose versions are sfsvs.
+// The partially received snapshot would be the next snapshot after the incremental source,
+// thus the versions up to and including the source are complete and safe to send.
+//
+// A partially received full stream means that the sender filesystem has no complete data at all.
+func senderSafeCreateTXG(token *zfs.ResumeToken, sfsvs []*pdu.FilesystemVersion) (uint64, error) {
+	partial := fmt.Sprintf("%s (partially received snapshot: %q)", senderFSPartiallyReceivedMsg, token.ToName)
+	if !token.HasFromGUID {
+		return 0, errors.New(partial)
+	}
+	for _, v := range sfsvs {
+		if v.GetGuid() == token.FromGUID {
+			return v.GetCreateTXG(), nil
+		}
+	}
+	return 0, fmt.Errorf("%s, its incremental source (guid %d) does not exist on the sender", partial, token.FromGUID)
+}
+
+// truncateUnsafeSteps returns the prefix of steps whose `to` version was created at or before safeUntilTXG.
+func truncateUnsafeSteps(steps []*Step, safeUntilTXG uint64) []*Step {
+	for i, s := range steps {
+		if s.to.GetCreateTXG() > safeUntilTXG {
+			return steps[:i]
+		}
+	}
+	return steps
+}
+
+// resumeOffset returns the number of bytes received before t was created,
+// or 0 if t does not contain it, in which case progress is reported relative to the resumed stream.
+func resumeOffset(t *zfs.ResumeToken) int64 {
+	if !t.HasBytes || t.Bytes > math.MaxInt64 {
+		return 0
+	}
+	return int64(t.Bytes)
+}
+
 func (fs *Filesystem) doPlanning(ctx context.Context) ([]*Step, error) {
 
 	log := func(ctx context.Context) logger.Logger {
@@ -319,6 +492,12 @@ func (fs *Filesystem) doPlanning(ctx context.Context) ([]*Step, error) {
 		return nil, fmt.Errorf("sender filesystem is not encrypted but policy mandates encrypted send")
 	}
 
+	if fs.senderFS.GetIsPlaceholder() {
+		err := errors.New("sender filesystem is a placeholder and has no data to send: replicate it to the sender first")
+		log(ctx).WithError(err).Error("sender filesystem is incomplete")
+		return nil, err
+	}
+
 	sfsvsres, err := fs.sender.ListFilesystemVersions(ctx, &pdu.ListFilesystemVersionsReq{Filesystem: fs.Path})
 	if err != nil {
 		log(ctx).WithError(err).Error("cannot get remote filesystem versions")
@@ -332,6 +511,12 @@ func (fs *Filesystem) doPlanning(ctx context.Context) ([]*Step, error) {
 		return nil, err
 	}
 
+	senderSafeUntilTXG, err := fs.checkSenderFSComplete(ctx, sfsvs)
+	if err != nil {
+		log(ctx).WithError(err).Error("sender filesystem is incomplete")
+		return nil, err
+	}
+
 	var rfsvs []*pdu.FilesystemVersion
 	if fs.receiverFS != nil && !fs.receiverFS.GetIsPlaceholder() {
 		rfsvsres, err := fs.receiver.ListFilesystemVersions(ctx, &pdu.ListFilesystemVersionsReq{Filesystem: fs.Path})
@@ -343,6 +528,7 @@ func (fs *Filesystem) doPlanning(ctx context.Context) ([]*Step, error) {
 	} else {
 		rfsvs = []*pdu.FilesystemVersion{}
 	}
+	fs.lag.planned(sfsvs, rfsvs)
 
 	var resumeToken *zfs.ResumeToken
 	var resumeTokenRaw string
@@ -392,28 +578,26 @@ func (fs *Filesystem) doPlanning(ctx context.Context) ([]*Step, error) {
 			}
 		}
 
-		encryptionMatches := false
-		switch fs.policy.EncryptedSend {
-		case True:
-			encryptionMatches = resumeToken.RawOK && resumeToken.CompressOK
-		case False:
-			encryptionMatches = !resumeToken.RawOK && !resumeToken.CompressOK
-		case DontCare:
-			encryptionMatches = true
-		}
+		policyErr := resumeTokenMatchesPolicy(resumeToken, fs.policy)
 
 		log(ctx).WithField("fromVersion", fromVersion).
 			WithField("toVersion", toVersion).
-			WithField("encryptionMatches", encryptionMatches).
+			WithField("policyMatches", policyErr == nil).
 			Debug("result of resume-token-matching to sender's versions")
 
-		if !encryptionMatches {
-			return nil, fmt.Errorf("resume token `rawok`=%v and `compressok`=%v are incompatible with encryption policy=%v", resumeToken.RawOK, resumeToken.CompressOK, fs.policy.EncryptedSend)
+		if policyErr != nil {
+			return nil, policyErr
 		} else if toVersion == nil {
 			return nil, fmt.Errorf("resume token `toguid` = %v not found on sender (`toname` = %q)", resumeToken.ToGUID, resumeToken.ToName)
 		} else if fromVersion == toVersion {
 			return nil, fmt.Errorf("resume token `fromguid` and `toguid` match same version on sener")
 		}
+		// a partially received clone-preserving send is relative to the clone origin
+		var fromOrigin *pdu.FilesystemVersion
+		var fromOriginFS string
+		if fromVersion == nil && resumeToken.HasFromGUID && fs.cloneOrigin != nil && fs.cloneOrigin.Guid == resumeToken.FromGUID {
+			fromOrigin, fromOriginFS = fs.cloneOrigin, fs.senderFS.GetOriginFilesystem()
+		}
 		// fromVersion may be nil, toVersion is no nil, encryption matches
 		// good to go this one step!
 		resumeStep := &Step{
@@ -425,7 +609,11 @@ func (fs *Filesystem) doPlanning(ctx context.Context) ([]*Step, error) {
 			to:      toVersion,
 			encrypt: fs.policy.EncryptedSend,
 
-			resumeToken: resumeTokenRaw,
+			resumeToken:  resumeTokenRaw,
+			resumeOffset: resumeOffset(resumeToken),
+
+			fromOrigin:   fromOrigin,
+			fromOriginFS: fromOriginFS,
 		}
 
 		// by definition, the resume token _must_ be the receiver's most recent version, if they have any
@@ -468,10 +656,14 @@ func (fs *Filesystem) doPlanning(ctx context.Context) ([]*Step, error) {
 		if len(path) == 0 {
 			return nil, conflict
 		}
+		if len(path) > 1 && path[0].Type == pdu.FilesystemVersion_Bookmark {
+			log(ctx).WithField("bookmark", path[0].RelName()).
+				Info("no common snapshot with receiver, using sender's bookmark as incremental base")
+		}
 
 		steps = make([]*Step, 0, len(path)) // shadow
 		if len(path) == 1 {
-			steps = append(steps, &Step{
+			step := &Step{
 				parent:   fs,
 				sender:   fs.sender,
 				receiver: fs.receiver,
@@ -479,7 +671,15 @@ func (fs *Filesystem) doPlanning(ctx context.Context) ([]*Step, error) {
 				from:    nil,
 				to:      path[0],
 				encrypt: fs.policy.EncryptedSend,
-			})
+			}
+			if fs.receiverFS == nil && fs.cloneOrigin != nil {
+				// preserve the clone relationship on the receiver instead of sending all of the clone's data
+				// (the driver replicates the origin's filesystem first, see InitialReplicationDependency)
+				step.fromOrigin, step.fromOriginFS = fs.cloneOrigin, fs.senderFS.GetOriginFilesystem()
+				log(ctx).WithField("origin", step.fromOriginFS+step.fromOrigin.RelName()).
+					Info("filesystem is a clone, sending it relative to its origin")
+			}
+			steps = append(steps, step)
 		} else {
 			for i := 0; i < len(path)-1; i++ {
 				steps = append(steps, &Step{
@@ -495,9 +695,24 @@ func (fs *Filesystem) doPlanning(ctx context.Context) ([]*Step, error) {
 		}
 	}
 
+	if safe := truncateUnsafeSteps(steps, senderSafeUntilTXG); len(safe) < len(steps) {
+		// fs.checkSenderFSComplete did not fail, thus the sender has a partially received stream
+		if len(safe) == 0 {
+			err := fmt.Errorf("%s: all %d planned steps depend on it", senderFSPartiallyReceivedMsg, len(steps))
+			log(ctx).WithError(err).Error("sender filesystem is incomplete")
+			return nil, err
+		}
+		log(ctx).WithField("planned", len(steps)).WithField("safe", len(safe)).
+			Warn("sender filesystem has a partially received stream, only replicating the steps that precede it")
+		steps = safe
+	}
+
 	if len(steps) == 0 {
 		log(ctx).Info("planning determined that no replication steps are required")
 	}
+	for i := range steps {
+		steps[i].idx = i
+	}
 
 	log(ctx).Debug("compute send size estimate")
 	errs := make(chan error, len(steps))
@@ -538,6 +753,12 @@ func (fs *Filesystem) doPlanning(ctx context.Context) ([]*Step, error) {
 		return nil, significantErr
 	}
 
+	if len(steps) > 1 {
+		// protect the snapshots of later steps from concurrent pruning
+		fs.plannedHolds = &plannedHolds{sender: fs.sender, fs: fs.Path, steps: steps}
+		fs.plannedHolds.holdAll(ctx)
+	}
+
 	log(ctx).Debug("filesystem planning finished")
 	return steps, nil
 }
@@ -568,6 +789,11 @@ func (s *Step) buildSendRequest(dryRun bool) (sr *pdu.SendReq) {
 		ResumeToken: s.resumeToken,
 		DryRun:      dryRun,
 	}
+	defer s.byteCounterMtx.Lock().Unlock()
+	if s.fromOrigin != nil {
+		sr.From = s.fromOrigin
+		sr.FromOriginFilesystem = s.fromOriginFS
+	}
 	return sr
 }
 
@@ -576,6 +802,11 @@ func (s *Step) doReplication(ctx context.Context) error {
 	fs := s.parent.Path
 
 	log := getLogger(ctx).WithField("filesystem", fs)
+
+	if err := s.checkReceiverHasCloneOrigin(ctx); err != nil {
+		return err
+	}
+
 	sr := s.buildSendRequest(false)
 
 	log.Debug("initiate send request")
@@ -631,12 +862,68 @@ func (s *Step) doReplication(ctx context.Context) error {
 		log.WithError(err).Error("error telling sender that replication completed successfully")
 		return err
 	}
+	s.parent.lag.replicated(s.to)
+
+	if s.parent.plannedHolds != nil {
+		s.parent.plannedHolds.move(ctx, s.idx+1)
+	}
 
 	return err
 }
 
+// checkReceiverHasCloneOrigin falls back to a full send if the step is a clone-preserving send
+// but the receiver does not have the clone origin, e.g. because the origin's replication failed.
+func (s *Step) checkReceiverHasCloneOrigin(ctx context.Context) error {
+	s.byteCounterMtx.Lock()
+	origin, originFS := s.fromOrigin, s.fromOriginFS
+	s.byteCounterMtx.Unlock()
+	if origin == nil {
+		return nil
+	}
+
+	log := getLogger(ctx).WithField("filesystem", s.parent.Path).WithField("origin", originFS+origin.RelName())
+
+	present := false
+	rfss, err := s.receiver.ListFilesystems(ctx, &pdu.ListFilesystemReq{})
+	if err != nil {
+		log.WithError(err).Error("cannot list receiver filesystems")
+		return err
+	}
+	for _, rfs := range rfss.GetFilesystems() {
+		if rfs.GetPath() == originFS && !rfs.GetIsPlaceholder() {
+			present = true
+		}
+	}
+	if present {
+		rfsvs, err := s.receiver.ListFilesystemVersions(ctx, &pdu.ListFilesystemVersionsReq{Filesystem: originFS})
+		if err != nil {
+			log.WithError(err).Error("cannot list receiver filesystem versions of clone origin")
+			return err
+		}
+		present = false
+		for _, v := range rfsvs.GetVersions() {
+			if v.Type == pdu.FilesystemVersion_Snapshot && v.Guid == origin.Guid {
+				present = true
+			}
+		}
+	}
+	if present {
+		return nil
+	}
+	if s.resumeToken != "" {
+		return fmt.Errorf("cannot resume clone-preserving send: receiver does not have clone origin %q", originFS+origin.RelName())
+	}
+	log.Warn("receiver does not have clone origin, falling back to full send")
+	defer s.byteCounterMtx.Lock().Unlock()
+	s.fromOrigin, s.fromOriginFS = nil, ""
+	return nil
+}
+
 func (s *Step) String() string {
-	if s.from == nil { // FIXME: ZFS semantics are that to is nil on non-incremental send
+	defer s.byteCounterMtx.Lock().Unlock()
+	if s.fromOrigin != nil {
+		return fmt.Sprintf("%s%s (clone of %s)", s.parent.Path, s.to.RelName(), s.fromOriginFS+s.fromOrigin.RelName())
+	} else if s.from == nil { // FIXME: ZFS semantics are that to is nil on non-incremental send
 		return fmt.Sprintf("%s%s (full)", s.parent.Path, s.to.RelName())
 	} else {
 		return fmt.Sprintf("%s(%s => %s)", s.parent.Path, s.from.RelName(), s.to.RelName())
@@ -0,0 +1,120 @@
+package logic
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+	"github.com/zrepl/zrepl/replication/report"
+)
+
+// replicationLag tracks the replication lag of a Filesystem, see report.FilesystemInfo.ReplicationLag.
+type replicationLag struct {
+	gauge prometheus.Gauge // may be nil
+
+	mtx sync.Mutex
+	// nil until planning has listed the versions of both sides
+	newestSender *pdu.FilesystemVersion
+	// the newest snapshot of the sender that exists on the receiver, nil if there is none
+	newestReplicated *pdu.FilesystemVersion
+}
+
+func newReplicationLag(gauge prometheus.Gauge) *replicationLag {
+	return &replicationLag{gauge: gauge}
+}
+
+// newestSnapshotOf returns the snapshot of vs with the highest createtxg for which matches returns true,
+// or nil if there is none.
+func newestSnapshotOf(vs []*pdu.FilesystemVersion, matches func(v *pdu.FilesystemVersion) bool) *pdu.FilesystemVersion {
+	var newest *pdu.FilesystemVersion
+	for _, v := range vs {
+		if v.Type != pdu.FilesystemVersion_Snapshot || !matches(v) {
+			continue
+		}
+		if newest == nil || v.CreateTXG > newest.CreateTXG {
+			newest = v
+		}
+	}
+	return newest
+}
+
+// planned initializes l from the versions of both sides at planning time.
+func (l *replicationLag) planned(sfsvs, rfsvs []*pdu.FilesystemVersion) {
+	receiverGUIDs := make(map[uint64]bool, len(rfsvs))
+	for _, v := range rfsvs {
+		receiverGUIDs[v.Guid] = true
+	}
+	all := func(*pdu.FilesystemVersion) bool { return true }
+	onReceiver := func(v *pdu.FilesystemVersion) bool { return receiverGUIDs[v.Guid] }
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.newestSender = newestSnapshotOf(sfsvs, all)
+	l.newestReplicated = newestSnapshotOf(sfsvs, onReceiver)
+	l.updateGauge()
+}
+
+// replicated must be called after the sender's snapshot to has been replicated.
+func (l *replicationLag) replicated(to *pdu.FilesystemVersion) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	if l.newestSender == nil {
+		return
+	}
+	if l.newestReplicated == nil || to.CreateTXG > l.newestReplicated.CreateTXG {
+		l.newestReplicated = to
+	}
+	l.updateGauge()
+}
+
+// lag returns nil if the lag is unknown.
+//
+// Must be called with l.mtx held.
+func (l *replicationLag) lag() *time.Duration {
+	if l.newestSender == nil {
+		return nil
+	}
+	var lag time.Duration
+	switch {
+	case l.newestReplicated == nil:
+		lag = report.ReplicationLagNeverReplicated
+	case l.newestReplicated.Guid == l.newestSender.Guid:
+		lag = 0
+	default:
+		newest, err := l.newestSender.CreationAsTime()
+		if err != nil {
+			return nil
+		}
+		replicated, err := l.newestReplicated.CreationAsTime()
+		if err != nil {
+			return nil
+		}
+		lag = newest.Sub(replicated)
+		if lag < 0 {
+			lag = 0 // clock stepped backwards between the creation of the snapshots
+		}
+	}
+	return &lag
+}
+
+// Must be called with l.mtx held.
+func (l *replicationLag) updateGauge() {
+	if l.gauge == nil {
+		return
+	}
+	if lag := l.lag(); lag == nil {
+		// keep the previous value, we cannot tell whether the lag has changed
+	} else if *lag == report.ReplicationLagNeverReplicated {
+		l.gauge.Set(-1)
+	} else {
+		l.gauge.Set(lag.Seconds())
+	}
+}
+
+func (l *replicationLag) report() *time.Duration {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.lag()
+}
@@ -0,0 +1,99 @@
+package logic
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+	"github.com/zrepl/zrepl/util/envconst"
+)
+
+// bounds releaseAll, which does not stop when the replication context is done
+var plannedHoldsReleaseTimeout = envconst.Duration("ZREPL_REPLICATION_PLANNED_HOLDS_RELEASE_TIMEOUT", 1*time.Minute)
+
+// plannedHolds protects the snapshots of a filesystem's multi-step replication plan
+// from being destroyed (e.g. by a concurrent prune) until the step that depends on them has completed.
+//
+// The holds are managed by the sender (see Sender.HoldPlannedVersions)
+// and are best-effort: failure to move them is logged but does not fail replication.
+type plannedHolds struct {
+	sender Sender
+	fs     string
+	steps  []*Step
+
+	mtx  sync.Mutex
+	held bool // false if the initial hold failed, e.g., because the sender does not support the RPC
+}
+
+// detachedContext has the values of its parent (logger, trace), but is never done.
+type detachedContext struct{ context.Context }
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+// versions returns the snapshots that steps[firstStep:] depend on
+func (h *plannedHolds) versions(firstStep int) []*pdu.FilesystemVersion {
+	var vs []*pdu.FilesystemVersion
+	seen := make(map[uint64]bool)
+	add := func(v *pdu.FilesystemVersion) {
+		if v == nil || v.GetType() != pdu.FilesystemVersion_Snapshot || seen[v.GetGuid()] {
+			return
+		}
+		seen[v.GetGuid()] = true
+		vs = append(vs, v)
+	}
+	for _, s := range h.steps[firstStep:] {
+		add(s.from)
+		add(s.to)
+	}
+	return vs
+}
+
+func (h *plannedHolds) doMove(ctx context.Context, firstStep int) error {
+	vs := h.versions(firstStep)
+	getLogger(ctx).WithField("filesystem", h.fs).WithField("versions", vs).Debug("move holds on planned versions")
+	_, err := h.sender.HoldPlannedVersions(ctx, &pdu.HoldPlannedVersionsReq{
+		Filesystem: h.fs,
+		Versions:   vs,
+	})
+	return err
+}
+
+// hold the snapshots of all planned steps
+func (h *plannedHolds) holdAll(ctx context.Context) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if err := h.doMove(ctx, 0); err != nil {
+		getLogger(ctx).WithField("filesystem", h.fs).WithError(err).
+			Warn("cannot hold planned versions, continuing without them (concurrent pruning on the sender might break this replication)")
+		// if the request was interrupted, the sender might have taken the holds nonetheless
+		h.held = ctx.Err() != nil
+		return
+	}
+	h.held = true
+}
+
+// hold the snapshots that steps[firstStep:] depend on and release all others
+func (h *plannedHolds) move(ctx context.Context, firstStep int) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	if !h.held {
+		return
+	}
+	if err := h.doMove(ctx, firstStep); err != nil {
+		getLogger(ctx).WithField("filesystem", h.fs).WithError(err).
+			Warn("cannot move holds on planned versions")
+		return
+	}
+	h.held = firstStep < len(h.steps)
+}
+
+// releaseAll releases all holds, even if ctx is already done (e.g., because replication was cancelled or timed out).
+// Otherwise, the holds would block pruning on the sender until the job restarts.
+func (h *plannedHolds) releaseAll(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(detachedContext{ctx}, plannedHoldsReleaseTimeout)
+	defer cancel()
+	h.move(ctx, len(h.steps))
+}
@@ -0,0 +1,87 @@
+package logic
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+)
+
+// plannedHoldsSender behaves like an RPC sender: requests fail if their context is done.
+type plannedHoldsSender struct {
+	Sender // only HoldPlannedVersions is implemented
+
+	mtx  sync.Mutex
+	held []string // names of the currently held versions
+	// if not nil, called after the holds were taken, before the response is returned
+	afterHold func()
+}
+
+func (s *plannedHoldsSender) HoldPlannedVersions(ctx context.Context, req *pdu.HoldPlannedVersionsReq) (*pdu.HoldPlannedVersionsRes, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if _, ok := ctx.Deadline(); !ok && len(req.GetVersions()) == 0 {
+		panic("release must be bounded by a timeout")
+	}
+	s.mtx.Lock()
+	s.held = nil
+	for _, v := range req.GetVersions() {
+		s.held = append(s.held, v.GetName())
+	}
+	s.mtx.Unlock()
+	if s.afterHold != nil {
+		s.afterHold()
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return &pdu.HoldPlannedVersionsRes{}, nil
+}
+
+func (s *plannedHoldsSender) heldVersions() []string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.held
+}
+
+func TestPlannedHoldsReleasedAfterCancel(t *testing.T) {
+	snap := func(name string, guid uint64) *pdu.FilesystemVersion {
+		return &pdu.FilesystemVersion{Type: pdu.FilesystemVersion_Snapshot, Name: name, Guid: guid}
+	}
+	a, b, c := snap("a", 1), snap("b", 2), snap("c", 3)
+	steps := []*Step{{from: a, to: b}, {from: b, to: c}}
+
+	t.Run("cancelled after planning", func(t *testing.T) {
+		sender := &plannedHoldsSender{}
+		ctx, cancel := context.WithCancel(context.Background())
+		fs := &Filesystem{plannedHolds: &plannedHolds{sender: sender, fs: "pool/fs", steps: steps}}
+		fs.plannedHolds.holdAll(ctx)
+		require.Equal(t, []string{"a", "b", "c"}, sender.heldVersions())
+
+		fs.plannedHolds.move(ctx, 1)
+		require.Equal(t, []string{"b", "c"}, sender.heldVersions())
+
+		// e.g. max_run_duration exceeded during the second step
+		cancel()
+		fs.ReleasePlan(ctx)
+		assert.Empty(t, sender.heldVersions())
+	})
+
+	t.Run("cancelled while holding", func(t *testing.T) {
+		// the sender took the holds, but the response was lost because the replication was cancelled
+		ctx, cancel := context.WithCancel(context.Background())
+		sender := &plannedHoldsSender{afterHold: cancel}
+		fs := &Filesystem{plannedHolds: &plannedHolds{sender: sender, fs: "pool/fs", steps: steps}}
+		fs.plannedHolds.holdAll(ctx)
+		require.Equal(t, []string{"a", "b", "c"}, sender.heldVersions())
+		sender.afterHold = nil
+
+		fs.ReleasePlan(ctx)
+		assert.Empty(t, sender.heldVersions())
+	})
+}
@@ -0,0 +1,161 @@
+package logic
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+	"github.com/zrepl/zrepl/replication/report"
+	"github.com/zrepl/zrepl/zfs"
+)
+
+func TestStepReportInfoResumeOffset(t *testing.T) {
+	to := &pdu.FilesystemVersion{Type: pdu.FilesystemVersion_Snapshot, Name: "b", Creation: "2020-01-01T00:00:00Z"}
+
+	resumed := &Step{to: to, encrypt: DontCare, resumeToken: "1-abc", resumeOffset: 300, expectedSize: 700}
+	info := resumed.ReportInfo()
+	assert.True(t, info.Resumed)
+	assert.Equal(t, int64(300), info.BytesResumeOffset)
+	assert.Equal(t, int64(1000), info.BytesExpected)
+	assert.Equal(t, int64(300), info.BytesReplicated)
+
+	// no size estimate remains no size estimate
+	resumed.expectedSize = 0
+	assert.Equal(t, int64(0), resumed.ReportInfo().BytesExpected)
+
+	// offset unknown: progress relative to the remainder of the stream
+	relative := &Step{to: to, encrypt: DontCare, resumeToken: "1-abc", expectedSize: 700}
+	info = relative.ReportInfo()
+	assert.Equal(t, int64(0), info.BytesResumeOffset)
+	assert.Equal(t, int64(700), info.BytesExpected)
+	assert.Equal(t, int64(0), info.BytesReplicated)
+}
+
+func TestResumeOffset(t *testing.T) {
+	assert.Equal(t, int64(0), resumeOffset(&zfs.ResumeToken{}))
+	assert.Equal(t, int64(23), resumeOffset(&zfs.ResumeToken{HasBytes: true, Bytes: 23}))
+	assert.Equal(t, int64(0), resumeOffset(&zfs.ResumeToken{HasBytes: true, Bytes: 1 << 63}))
+}
+
+func TestReplicationLag(t *testing.T) {
+	v := func(guid uint64, txg uint64, creation string) *pdu.FilesystemVersion {
+		return &pdu.FilesystemVersion{Type: pdu.FilesystemVersion_Snapshot, Name: creation, Guid: guid, CreateTXG: txg, Creation: creation}
+	}
+	a := v(1, 10, "2020-01-01T00:00:00Z")
+	b := v(2, 20, "2020-01-01T01:00:00Z")
+	c := v(3, 30, "2020-01-01T03:00:00Z")
+	bookmark := &pdu.FilesystemVersion{Type: pdu.FilesystemVersion_Bookmark, Name: "c", Guid: 3, CreateTXG: 30, Creation: c.Creation}
+
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "lag"})
+	l := newReplicationLag(gauge)
+	assert.Nil(t, l.report(), "unknown before planning")
+
+	l.planned([]*pdu.FilesystemVersion{a, b, c, bookmark}, nil)
+	require.NotNil(t, l.report())
+	assert.Equal(t, report.ReplicationLagNeverReplicated, *l.report())
+	assert.Equal(t, float64(-1), testutil.ToFloat64(gauge))
+
+	// the receiver's versions are matched by GUID, not by name
+	l.planned([]*pdu.FilesystemVersion{a, b, c}, []*pdu.FilesystemVersion{{Type: pdu.FilesystemVersion_Snapshot, Name: "other", Guid: 1}})
+	assert.Equal(t, 3*time.Hour, *l.report())
+	assert.Equal(t, float64(3*3600), testutil.ToFloat64(gauge))
+
+	l.replicated(b)
+	assert.Equal(t, 2*time.Hour, *l.report())
+	l.replicated(a) // older than what was replicated already
+	assert.Equal(t, 2*time.Hour, *l.report())
+
+	l.replicated(c)
+	assert.Equal(t, time.Duration(0), *l.report())
+	assert.Equal(t, float64(0), testutil.ToFloat64(gauge))
+
+	// a bookmark of the newest snapshot does not count as sender snapshot
+	l.planned([]*pdu.FilesystemVersion{a, bookmark}, []*pdu.FilesystemVersion{a})
+	assert.Equal(t, time.Duration(0), *l.report())
+}
+
+func TestSenderSafeCreateTXG(t *testing.T) {
+	v := func(typ pdu.FilesystemVersion_VersionType, name string, guid, txg uint64) *pdu.FilesystemVersion {
+		return &pdu.FilesystemVersion{Type: typ, Name: name, Guid: guid, CreateTXG: txg}
+	}
+	sfsvs := []*pdu.FilesystemVersion{
+		v(pdu.FilesystemVersion_Snapshot, "a", 1, 10),
+		v(pdu.FilesystemVersion_Bookmark, "b", 2, 20),
+		v(pdu.FilesystemVersion_Snapshot, "c", 3, 30),
+		v(pdu.FilesystemVersion_Snapshot, "d", 4, 40), // e.g., taken locally while the stream was received
+	}
+
+	// partially received incremental c => x
+	txg, err := senderSafeCreateTXG(&zfs.ResumeToken{HasFromGUID: true, FromGUID: 3, HasToGUID: true, ToGUID: 99, ToName: "pool/fs@x"}, sfsvs)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(30), txg)
+
+	// from a bookmark
+	txg, err = senderSafeCreateTXG(&zfs.ResumeToken{HasFromGUID: true, FromGUID: 2, HasToGUID: true, ToGUID: 99}, sfsvs)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(20), txg)
+
+	// partially received full stream: no complete data
+	_, err = senderSafeCreateTXG(&zfs.ResumeToken{HasToGUID: true, ToGUID: 99, ToName: "pool/fs@x"}, sfsvs)
+	assert.Error(t, err)
+
+	// unknown incremental source
+	_, err = senderSafeCreateTXG(&zfs.ResumeToken{HasFromGUID: true, FromGUID: 42, HasToGUID: true, ToGUID: 99}, sfsvs)
+	assert.Error(t, err)
+}
+
+func TestTruncateUnsafeSteps(t *testing.T) {
+	snap := func(txg uint64) *pdu.FilesystemVersion {
+		return &pdu.FilesystemVersion{Type: pdu.FilesystemVersion_Snapshot, CreateTXG: txg}
+	}
+	steps := []*Step{
+		{from: nil, to: snap(10)},
+		{from: snap(10), to: snap(20)},
+		{from: snap(20), to: snap(30)},
+		{from: snap(30), to: snap(40)},
+	}
+	assert.Equal(t, steps, truncateUnsafeSteps(steps, math.MaxUint64))
+	assert.Equal(t, steps[:3], truncateUnsafeSteps(steps, 30))
+	assert.Equal(t, steps[:2], truncateUnsafeSteps(steps, 29))
+	assert.Empty(t, truncateUnsafeSteps(steps, 5))
+	assert.Empty(t, truncateUnsafeSteps(nil, 5))
+}
+
+func TestResumeTokenMatchesPolicy(t *testing.T) {
+	raw := &zfs.ResumeToken{HasRawOk: true, RawOK: true, HasCompressOK: true, CompressOK: true}
+	compressed := &zfs.ResumeToken{HasCompressOK: true, CompressOK: true}
+	plain := &zfs.ResumeToken{}
+
+	tcs := []struct {
+		name    string
+		token   *zfs.ResumeToken
+		policy  PlannerPolicy
+		matches bool
+	}{
+		{"raw, encrypted", raw, PlannerPolicy{EncryptedSend: True, CompressedSend: False}, true},
+		{"raw, unencrypted", raw, PlannerPolicy{EncryptedSend: False, CompressedSend: True}, false},
+		{"raw, dontcare", raw, PlannerPolicy{EncryptedSend: DontCare, CompressedSend: DontCare}, true},
+		{"compressed, unencrypted and compressed", compressed, PlannerPolicy{EncryptedSend: False, CompressedSend: True}, true},
+		{"compressed, unencrypted and uncompressed", compressed, PlannerPolicy{EncryptedSend: False, CompressedSend: False}, false},
+		{"compressed, encrypted", compressed, PlannerPolicy{EncryptedSend: True, CompressedSend: True}, false},
+		{"compressed, dontcare", compressed, PlannerPolicy{EncryptedSend: DontCare, CompressedSend: DontCare}, true},
+		{"plain, unencrypted and uncompressed", plain, PlannerPolicy{EncryptedSend: False, CompressedSend: False}, true},
+		{"plain, unencrypted and compressed", plain, PlannerPolicy{EncryptedSend: False, CompressedSend: True}, false},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			err := resumeTokenMatchesPolicy(tc.token, tc.policy)
+			if tc.matches {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
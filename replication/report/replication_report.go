@@ -2,6 +2,7 @@ package report
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 )
 
@@ -10,10 +11,27 @@ type Report struct {
 	WaitReconnectSince, WaitReconnectUntil time.Time
 	WaitReconnectError                     *TimedError
 	Attempts                               []*AttemptReport
+	// nil if the remote endpoint does not collect RPC statistics
+	RPC *RPCStats `json:",omitempty"`
 }
 
 var _, _ = json.Marshal(&Report{})
 
+// RPCStats are the statistics of the RPC session with the remote endpoint of a replication.
+// If StreamReadTime or RequestTime account for most of the replication's duration,
+// the replication is bound by the network or the remote side rather than by local IO.
+type RPCStats struct {
+	Requests int64 // round-trips, including those that failed
+	Errors   int64
+	// cumulative time until the remote side responded,
+	// for Receive requests including the transfer of the stream to the remote
+	RequestTime time.Duration
+	// cumulative time spent waiting for data of streams sent by the remote side
+	StreamReadTime time.Duration
+	// replication stream bytes, excluding protocol overhead
+	StreamBytesSent, StreamBytesReceived int64
+}
+
 type TimedError struct {
 	Err  string
 	Time time.Time
@@ -79,10 +97,31 @@ type FilesystemReport struct {
 
 type FilesystemInfo struct {
 	Name string
+	// Time between the creation of the newest snapshot of the sender and that of the newest snapshot
+	// of the sender that also exists on the receiver (by GUID), updated after each successful step.
+	// Zero if the receiver is caught up, ReplicationLagNeverReplicated if no snapshot exists on both sides,
+	// nil if unknown, e.g., before planning.
+	ReplicationLag *time.Duration `json:",omitempty"`
 }
 
+// ReplicationLagNeverReplicated is the FilesystemInfo.ReplicationLag of filesystems
+// whose snapshots do not exist on the receiver.
+const ReplicationLagNeverReplicated time.Duration = -1
+
+type StepState string
+
+const (
+	StepPending StepState = "pending" // includes the step that is currently executing
+	StepDone    StepState = "done"
+	StepFailed  StepState = "failed"
+)
+
 type StepReport struct {
 	Info *StepInfo
+	// Empty in reports of daemons that do not report the outcome of individual steps
+	State StepState `json:",omitempty"`
+	// Valid in State = StepFailed
+	Error *TimedError `json:",omitempty"`
 }
 
 type EncryptedEnum string
@@ -94,11 +133,21 @@ const (
 )
 
 type StepInfo struct {
-	From, To        string
+	From, To string
+	// From is a bookmark because the sender no longer has the common snapshot
+	FromBookmark bool `json:",omitempty"`
+	// Full name of the clone origin snapshot if this is a non-incremental
+	// send that preserves the clone relationship on the receiver
+	FromOrigin      string `json:",omitempty"`
 	Resumed         bool
 	Encrypted       EncryptedEnum
 	BytesExpected   int64
 	BytesReplicated int64
+	// Bytes of a resumed stream that were received before this step started.
+	// Included in BytesReplicated, and in BytesExpected if the step has a size estimate.
+	// Zero if the step is not resumed or the offset could not be determined,
+	// in which case progress is relative to the remainder of the stream.
+	BytesResumeOffset int64 `json:",omitempty"`
 }
 
 func (a *AttemptReport) BytesSum() (expected, replicated int64, containsInvalidSizeEstimates bool) {
@@ -162,3 +211,94 @@ func (f *FilesystemReport) NextStep() *StepReport {
 func (f *StepReport) IsIncremental() bool {
 	return f.Info.From != ""
 }
+
+// FailedStep returns the step whose failure caused State = FilesystemSteppingErrored and its index into f.Steps,
+// or -1 and nil if no step failed, e.g., because the filesystem failed while waiting for its parents.
+func (f *FilesystemReport) FailedStep() (int, *StepReport) {
+	for i, step := range f.Steps {
+		if step.State == StepFailed {
+			return i, step
+		}
+	}
+	return -1, nil
+}
+
+// StepFailureSummary describes the outcome of the steps of a filesystem with a failed step, e.g.,
+// `3 of 5 steps succeeded, step 4 (pool/fs@a => pool/fs@b) failed: network error`.
+// Returns the empty string if no step failed.
+func (f *FilesystemReport) StepFailureSummary() string {
+	i, step := f.FailedStep()
+	if step == nil {
+		return ""
+	}
+	done := 0
+	for _, s := range f.Steps {
+		if s.State == StepDone {
+			done++
+		}
+	}
+	desc := fmt.Sprintf("full send %s", step.Info.To)
+	if step.IsIncremental() {
+		desc = fmt.Sprintf("%s => %s", step.Info.From, step.Info.To)
+	}
+	errMsg := "unknown error"
+	if step.Error != nil {
+		errMsg = step.Error.Err
+	}
+	return fmt.Sprintf("%d of %d steps succeeded, step %d (%s) failed: %s", done, len(f.Steps), i+1, desc, errMsg)
+}
+
+// FilesystemResult is a flat summary of the replication of a filesystem in an attempt,
+// e.g., for machine-readable output or metrics.
+type FilesystemResult struct {
+	Filesystem string
+	State      FilesystemState
+	// StepsAttempted includes the step that is in progress or failed.
+	StepsPlanned, StepsAttempted, StepsCompleted int
+	BytesReplicated                              int64
+	// From is the `from` version of the first step (empty for a full send),
+	// To is the `to` version of the last completed step (empty if no step completed).
+	From, To string
+	// nil unless State is FilesystemPlanningErrored or FilesystemSteppingErrored
+	Error *TimedError
+}
+
+func (f *FilesystemReport) Result() *FilesystemResult {
+	r := &FilesystemResult{
+		State:        f.State,
+		StepsPlanned: len(f.Steps),
+		Error:        f.Error(),
+	}
+	if f.Info != nil {
+		r.Filesystem = f.Info.Name
+	}
+	switch f.State {
+	case FilesystemDone:
+		r.StepsAttempted = len(f.Steps)
+		r.StepsCompleted = len(f.Steps)
+	case FilesystemStepping, FilesystemSteppingErrored:
+		if len(f.Steps) > 0 {
+			r.StepsAttempted = f.CurrentStep + 1
+		}
+		r.StepsCompleted = f.CurrentStep
+	}
+	for i, step := range f.Steps[:r.StepsAttempted] {
+		if i == 0 {
+			r.From = step.Info.From
+		}
+		if i < r.StepsCompleted {
+			r.To = step.Info.To
+		}
+		r.BytesReplicated += step.Info.BytesReplicated
+	}
+	return r
+}
+
+// Results returns the results of all filesystems of the attempt, in the order of a.Filesystems.
+func (a *AttemptReport) Results() []*FilesystemResult {
+	rs := make([]*FilesystemResult, len(a.Filesystems))
+	for i, fs := range a.Filesystems {
+		rs[i] = fs.Result()
+	}
+	return rs
+}
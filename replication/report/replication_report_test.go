@@ -0,0 +1,91 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilesystemReportResult(t *testing.T) {
+	steps := func() []*StepReport {
+		return []*StepReport{
+			{Info: &StepInfo{From: "", To: "@a", BytesReplicated: 100}},
+			{Info: &StepInfo{From: "@a", To: "@b", BytesReplicated: 10}},
+			{Info: &StepInfo{From: "@b", To: "@c", BytesReplicated: 1}},
+		}
+	}
+	stepErr := NewTimedError("step failed", time.Unix(1, 0))
+
+	tcs := []struct {
+		name   string
+		report *FilesystemReport
+		expect *FilesystemResult
+	}{
+		{
+			"planning-error",
+			&FilesystemReport{Info: &FilesystemInfo{Name: "pool/fs"}, State: FilesystemPlanningErrored, PlanError: stepErr},
+			&FilesystemResult{Filesystem: "pool/fs", State: FilesystemPlanningErrored, Error: stepErr},
+		},
+		{
+			"done",
+			&FilesystemReport{Info: &FilesystemInfo{Name: "pool/fs"}, State: FilesystemDone, CurrentStep: 3, Steps: steps()},
+			&FilesystemResult{Filesystem: "pool/fs", State: FilesystemDone,
+				StepsPlanned: 3, StepsAttempted: 3, StepsCompleted: 3, BytesReplicated: 111, From: "", To: "@c"},
+		},
+		{
+			"done-without-steps",
+			&FilesystemReport{Info: &FilesystemInfo{Name: "pool/fs"}, State: FilesystemDone},
+			&FilesystemResult{Filesystem: "pool/fs", State: FilesystemDone},
+		},
+		{
+			"second-step-failed",
+			&FilesystemReport{Info: &FilesystemInfo{Name: "pool/fs"}, State: FilesystemSteppingErrored, StepError: stepErr, CurrentStep: 1, Steps: steps()},
+			&FilesystemResult{Filesystem: "pool/fs", State: FilesystemSteppingErrored,
+				StepsPlanned: 3, StepsAttempted: 2, StepsCompleted: 1, BytesReplicated: 110, From: "", To: "@a", Error: stepErr},
+		},
+		{
+			"first-step-in-progress",
+			&FilesystemReport{Info: &FilesystemInfo{Name: "pool/fs"}, State: FilesystemStepping, CurrentStep: 0, Steps: steps()[1:]},
+			&FilesystemResult{Filesystem: "pool/fs", State: FilesystemStepping,
+				StepsPlanned: 2, StepsAttempted: 1, StepsCompleted: 0, BytesReplicated: 10, From: "@a", To: ""},
+		},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expect, tc.report.Result())
+		})
+	}
+}
+
+func TestFilesystemReportStepFailureSummary(t *testing.T) {
+	stepErr := NewTimedError("network error", time.Unix(1, 0))
+	rep := &FilesystemReport{
+		Info:        &FilesystemInfo{Name: "pool/fs"},
+		State:       FilesystemSteppingErrored,
+		StepError:   stepErr,
+		CurrentStep: 3,
+		Steps: []*StepReport{
+			{Info: &StepInfo{From: "", To: "pool/fs@a"}, State: StepDone},
+			{Info: &StepInfo{From: "pool/fs@a", To: "pool/fs@b"}, State: StepDone},
+			{Info: &StepInfo{From: "pool/fs@b", To: "pool/fs@c"}, State: StepDone},
+			{Info: &StepInfo{From: "pool/fs@c", To: "pool/fs@d"}, State: StepFailed, Error: stepErr},
+			{Info: &StepInfo{From: "pool/fs@d", To: "pool/fs@e"}, State: StepPending},
+		},
+	}
+	i, step := rep.FailedStep()
+	assert.Equal(t, 3, i)
+	assert.Equal(t, rep.Steps[3], step)
+	assert.Equal(t, "3 of 5 steps succeeded, step 4 (pool/fs@c => pool/fs@d) failed: network error", rep.StepFailureSummary())
+
+	rep.Steps = rep.Steps[3:4]
+	rep.Steps[0].Info.From = ""
+	assert.Equal(t, "0 of 1 steps succeeded, step 1 (full send pool/fs@d) failed: network error", rep.StepFailureSummary())
+
+	// e.g., the filesystem failed while waiting for its parents, or the report is from an older daemon
+	rep.Steps = []*StepReport{{Info: &StepInfo{To: "pool/fs@a"}}}
+	i, step = rep.FailedStep()
+	assert.Equal(t, -1, i)
+	assert.Nil(t, step)
+	assert.Equal(t, "", rep.StepFailureSummary())
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 
 	"github.com/golang/protobuf/proto"
 
@@ -109,14 +110,55 @@ func (c *Client) putWire(conn *stream.Conn) {
 	}
 }
 
+// closeOnCancel closes conn if ctx is done before the returned stop function is called.
+// Closing the connection interrupts reads and writes that are blocked on it,
+// which in turn makes the peer abort the request and terminate its zfs processes.
+//
+// stop returns true if conn has been closed because ctx was done, the caller must not close it again in that case.
+// It may be called multiple times.
+func (c *Client) closeOnCancel(ctx context.Context, conn *stream.Conn) (stop func() (closed bool)) {
+	done, exited := make(chan struct{}), make(chan struct{})
+	var closed bool
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			c.log.WithError(ctx.Err()).Debug("context done, closing connection")
+			if err := conn.Close(); err != nil {
+				c.log.WithError(err).Error("error closing connection")
+			}
+			closed = true
+		case <-done:
+		}
+	}()
+	var once sync.Once
+	return func() bool {
+		once.Do(func() { close(done) })
+		<-exited
+		return closed
+	}
+}
+
+// cancelableStream stops the closeOnCancel watcher of the connection that the stream is read from when it is closed.
+type cancelableStream struct {
+	io.ReadCloser
+	stopCloseOnCancel func() bool
+}
+
+func (s *cancelableStream) Close() error {
+	s.stopCloseOnCancel()
+	return s.ReadCloser.Close()
+}
+
 func (c *Client) ReqSend(ctx context.Context, req *pdu.SendReq) (*pdu.SendRes, io.ReadCloser, error) {
 	conn, err := c.getWire(ctx)
 	if err != nil {
 		return nil, nil, err
 	}
+	stopCloseOnCancel := c.closeOnCancel(ctx, conn)
 	putWireOnReturn := true
 	defer func() {
-		if putWireOnReturn {
+		if putWireOnReturn && !stopCloseOnCancel() {
 			c.putWire(conn)
 		}
 	}()
@@ -133,10 +175,13 @@ func (c *Client) ReqSend(ctx context.Context, req *pdu.SendReq) (*pdu.SendRes, i
 	var stream io.ReadCloser
 	if !req.DryRun {
 		putWireOnReturn = false
-		stream, err = conn.ReadStream(ZFSStream, true) // no shadow
+		s, err := conn.ReadStream(ZFSStream, true) // no shadow
 		if err != nil {
+			stopCloseOnCancel()
 			return nil, nil, err
 		}
+		// the conn is closed when the stream is closed, the watcher must live as long as the stream
+		stream = &cancelableStream{s, stopCloseOnCancel}
 	}
 
 	return &res, stream, nil
@@ -149,6 +194,8 @@ func (c *Client) ReqRecv(ctx context.Context, req *pdu.ReceiveReq, stream io.Rea
 		return nil, err
 	}
 
+	stopCloseOnCancel := c.closeOnCancel(ctx, conn)
+
 	// send and recv response concurrently to catch early exists of remote handler
 	// (e.g. disk full, permission error, etc)
 
@@ -194,14 +241,16 @@ func (c *Client) ReqRecv(ctx context.Context, req *pdu.ReceiveReq, stream io.Rea
 		}
 		if !didTryClose && (res.err != nil || sendErr != nil) {
 			didTryClose = true
-			if err := conn.Close(); err != nil {
+			if stopCloseOnCancel() {
+				c.log.Debug("ReqRecv: connection was closed because context is done")
+			} else if err := conn.Close(); err != nil {
 				c.log.WithError(err).Error("ReqRecv: cannot close connection, will likely block indefinitely")
 			}
 			c.log.WithError(err).Debug("ReqRecv: closed connection, should trigger other goroutine error")
 		}
 	}
 
-	if !didTryClose {
+	if !didTryClose && !stopCloseOnCancel() {
 		// didn't close it in above loop, so we can give it back
 		c.putWire(conn)
 	}
@@ -222,7 +271,12 @@ func (c *Client) ReqPing(ctx context.Context, req *pdu.PingReq) (*pdu.PingRes, e
 	if err != nil {
 		return nil, err
 	}
-	defer c.putWire(conn)
+	stopCloseOnCancel := c.closeOnCancel(ctx, conn)
+	defer func() {
+		if !stopCloseOnCancel() {
+			c.putWire(conn)
+		}
+	}()
 
 	if err := c.send(ctx, conn, EndpointPing, req, nil); err != nil {
 		return nil, err
@@ -1 +1,56 @@
 package dataconn
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/logger"
+	"github.com/zrepl/zrepl/replication/logic/pdu"
+	"github.com/zrepl/zrepl/transport"
+)
+
+type tcpConnecter struct{ addr string }
+
+func (c tcpConnecter) Connect(ctx context.Context) (transport.Wire, error) {
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, err
+	}
+	return nc.(*net.TCPConn), nil
+}
+
+func TestClientRequestInterruptedByContext(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go func() {
+		// a server that hangs: it never responds, not even with heartbeats
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer nc.Close()
+				_, _ = io.Copy(ioutil.Discard, nc)
+			}()
+		}
+	}()
+
+	c := NewClient(tcpConnecter{l.Addr().String()}, logger.NewNullLogger())
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	begin := time.Now()
+	_, err = c.ReqPing(ctx, &pdu.PingReq{Message: "hello"})
+	assert.Error(t, err)
+	assert.True(t, time.Since(begin) < HeartbeatPeerTimeout/2, "request must be interrupted by the context, not by the heartbeat timeout")
+}
@@ -17,6 +17,7 @@ import (
 
 	"github.com/zrepl/zrepl/replication/logic"
 	"github.com/zrepl/zrepl/replication/logic/pdu"
+	"github.com/zrepl/zrepl/replication/report"
 	"github.com/zrepl/zrepl/rpc/dataconn"
 	"github.com/zrepl/zrepl/rpc/grpcclientidentity/grpchelper"
 	"github.com/zrepl/zrepl/rpc/versionhandshake"
@@ -32,6 +33,7 @@ type Client struct {
 	controlConn   *grpc.ClientConn
 	loggers       Loggers
 	closed        chan struct{}
+	stats         clientStats
 }
 
 var _ logic.Endpoint = &Client{}
@@ -81,11 +83,17 @@ func (c *Client) Close() {
 	// TODO c.dataClient should have Close()
 }
 
+// Stats returns the statistics of all requests made by c so far.
+func (c *Client) Stats() report.RPCStats {
+	return c.stats.report()
+}
+
 // callers must ensure that the returned io.ReadCloser is closed
 // TODO expose dataClient interface to the outside world
-func (c *Client) Send(ctx context.Context, r *pdu.SendReq) (*pdu.SendRes, io.ReadCloser, error) {
+func (c *Client) Send(ctx context.Context, r *pdu.SendReq) (_ *pdu.SendRes, _ io.ReadCloser, err error) {
 	ctx, endSpan := trace.WithSpan(ctx, "rpc.client.Send")
 	defer endSpan()
+	defer c.stats.observeRequest(time.Now(), &err)
 
 	// TODO the returned sendStream may return a read error created by the remote side
 	res, stream, err := c.dataClient.ReqSend(ctx, r)
@@ -96,52 +104,69 @@ func (c *Client) Send(ctx context.Context, r *pdu.SendReq) (*pdu.SendRes, io.Rea
 		return res, nil, nil
 	}
 
-	return res, stream, nil
+	return res, &statsSendStream{stream, &c.stats}, nil
 
 }
 
-func (c *Client) Receive(ctx context.Context, req *pdu.ReceiveReq, stream io.ReadCloser) (*pdu.ReceiveRes, error) {
+func (c *Client) Receive(ctx context.Context, req *pdu.ReceiveReq, stream io.ReadCloser) (_ *pdu.ReceiveRes, err error) {
 	ctx, endSpan := trace.WithSpan(ctx, "rpc.client.Receive")
 	defer endSpan()
+	defer c.stats.observeRequest(time.Now(), &err)
 
+	if stream != nil {
+		stream = &statsRecvStream{stream, &c.stats}
+	}
 	return c.dataClient.ReqRecv(ctx, req, stream)
 }
 
-func (c *Client) ListFilesystems(ctx context.Context, in *pdu.ListFilesystemReq) (*pdu.ListFilesystemRes, error) {
+func (c *Client) ListFilesystems(ctx context.Context, in *pdu.ListFilesystemReq) (_ *pdu.ListFilesystemRes, err error) {
 	ctx, endSpan := trace.WithSpan(ctx, "rpc.client.ListFilesystems")
 	defer endSpan()
+	defer c.stats.observeRequest(time.Now(), &err)
 
 	return c.controlClient.ListFilesystems(ctx, in)
 }
 
-func (c *Client) ListFilesystemVersions(ctx context.Context, in *pdu.ListFilesystemVersionsReq) (*pdu.ListFilesystemVersionsRes, error) {
+func (c *Client) ListFilesystemVersions(ctx context.Context, in *pdu.ListFilesystemVersionsReq) (_ *pdu.ListFilesystemVersionsRes, err error) {
 	ctx, endSpan := trace.WithSpan(ctx, "rpc.client.ListFilesystemVersions")
 	defer endSpan()
+	defer c.stats.observeRequest(time.Now(), &err)
 
 	return c.controlClient.ListFilesystemVersions(ctx, in)
 }
 
-func (c *Client) DestroySnapshots(ctx context.Context, in *pdu.DestroySnapshotsReq) (*pdu.DestroySnapshotsRes, error) {
+func (c *Client) DestroySnapshots(ctx context.Context, in *pdu.DestroySnapshotsReq) (_ *pdu.DestroySnapshotsRes, err error) {
 	ctx, endSpan := trace.WithSpan(ctx, "rpc.client.DestroySnapshots")
 	defer endSpan()
+	defer c.stats.observeRequest(time.Now(), &err)
 
 	return c.controlClient.DestroySnapshots(ctx, in)
 }
 
-func (c *Client) ReplicationCursor(ctx context.Context, in *pdu.ReplicationCursorReq) (*pdu.ReplicationCursorRes, error) {
+func (c *Client) ReplicationCursor(ctx context.Context, in *pdu.ReplicationCursorReq) (_ *pdu.ReplicationCursorRes, err error) {
 	ctx, endSpan := trace.WithSpan(ctx, "rpc.client.ReplicationCursor")
 	defer endSpan()
+	defer c.stats.observeRequest(time.Now(), &err)
 
 	return c.controlClient.ReplicationCursor(ctx, in)
 }
 
-func (c *Client) SendCompleted(ctx context.Context, in *pdu.SendCompletedReq) (*pdu.SendCompletedRes, error) {
+func (c *Client) SendCompleted(ctx context.Context, in *pdu.SendCompletedReq) (_ *pdu.SendCompletedRes, err error) {
 	ctx, endSpan := trace.WithSpan(ctx, "rpc.client.SendCompleted")
 	defer endSpan()
+	defer c.stats.observeRequest(time.Now(), &err)
 
 	return c.controlClient.SendCompleted(ctx, in)
 }
 
+func (c *Client) HoldPlannedVersions(ctx context.Context, in *pdu.HoldPlannedVersionsReq) (_ *pdu.HoldPlannedVersionsRes, err error) {
+	ctx, endSpan := trace.WithSpan(ctx, "rpc.client.HoldPlannedVersions")
+	defer endSpan()
+	defer c.stats.observeRequest(time.Now(), &err)
+
+	return c.controlClient.HoldPlannedVersions(ctx, in)
+}
+
 func (c *Client) WaitForConnectivity(ctx context.Context) error {
 	ctx, endSpan := trace.WithSpan(ctx, "rpc.client.WaitForConnectivity")
 	defer endSpan()
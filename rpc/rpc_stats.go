@@ -0,0 +1,64 @@
+package rpc
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/zrepl/zrepl/replication/report"
+)
+
+// clientStats accumulates the report.RPCStats of a Client.
+// Only atomic operations are used so that collecting them is negligible
+// compared to the cost of an RPC or of reading a stream chunk.
+type clientStats struct {
+	requests, errors                     int64
+	requestNanos, streamReadNanos        int64
+	streamBytesSent, streamBytesReceived int64
+}
+
+// observeRequest is intended to be deferred with the named error result of an RPC method.
+func (s *clientStats) observeRequest(begin time.Time, err *error) {
+	atomic.AddInt64(&s.requestNanos, int64(time.Since(begin)))
+	atomic.AddInt64(&s.requests, 1)
+	if *err != nil {
+		atomic.AddInt64(&s.errors, 1)
+	}
+}
+
+func (s *clientStats) report() report.RPCStats {
+	return report.RPCStats{
+		Requests:            atomic.LoadInt64(&s.requests),
+		Errors:              atomic.LoadInt64(&s.errors),
+		RequestTime:         time.Duration(atomic.LoadInt64(&s.requestNanos)),
+		StreamReadTime:      time.Duration(atomic.LoadInt64(&s.streamReadNanos)),
+		StreamBytesSent:     atomic.LoadInt64(&s.streamBytesSent),
+		StreamBytesReceived: atomic.LoadInt64(&s.streamBytesReceived),
+	}
+}
+
+// statsSendStream counts the bytes of a stream received from the remote and the time spent waiting for them.
+type statsSendStream struct {
+	io.ReadCloser
+	stats *clientStats
+}
+
+func (s *statsSendStream) Read(p []byte) (int, error) {
+	begin := time.Now()
+	n, err := s.ReadCloser.Read(p)
+	atomic.AddInt64(&s.stats.streamReadNanos, int64(time.Since(begin)))
+	atomic.AddInt64(&s.stats.streamBytesReceived, int64(n))
+	return n, err
+}
+
+// statsRecvStream counts the bytes of a stream sent to the remote.
+type statsRecvStream struct {
+	io.ReadCloser
+	stats *clientStats
+}
+
+func (s *statsRecvStream) Read(p []byte) (int, error) {
+	n, err := s.ReadCloser.Read(p)
+	atomic.AddInt64(&s.stats.streamBytesSent, int64(n))
+	return n, err
+}
@@ -0,0 +1,37 @@
+package rpc
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientStats(t *testing.T) {
+	var s clientStats
+
+	var err error
+	s.observeRequest(time.Now().Add(-time.Second), &err)
+	err = errors.New("failed")
+	s.observeRequest(time.Now(), &err)
+
+	send := &statsSendStream{ioutil.NopCloser(bytes.NewReader(make([]byte, 23))), &s}
+	n, err := ioutil.ReadAll(send)
+	require.NoError(t, err)
+	require.Len(t, n, 23)
+
+	recv := &statsRecvStream{ioutil.NopCloser(bytes.NewReader(make([]byte, 42))), &s}
+	_, err = ioutil.ReadAll(recv)
+	require.NoError(t, err)
+
+	r := s.report()
+	assert.Equal(t, int64(2), r.Requests)
+	assert.Equal(t, int64(1), r.Errors)
+	assert.True(t, r.RequestTime >= time.Second)
+	assert.Equal(t, int64(23), r.StreamBytesReceived)
+	assert.Equal(t, int64(42), r.StreamBytesSent)
+}
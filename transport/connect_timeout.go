@@ -0,0 +1,70 @@
+package transport
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ConnecterWithTimeout returns a Connecter whose Connect fails if c does not connect within timeout.
+//
+// The deadline is passed to c through the context, which the transports use to abort
+// dialing (e.g., the ssh transport kills the ssh process).
+// If c does not return in time regardless, Connect returns anyways,
+// and the Wire that c might eventually return is closed.
+func ConnecterWithTimeout(c Connecter, timeout time.Duration) Connecter {
+	return timeoutConnecter{c, timeout}
+}
+
+type timeoutConnecter struct {
+	c       Connecter
+	timeout time.Duration
+}
+
+// ConnectTimeoutError implements net.Error.
+type ConnectTimeoutError struct {
+	ConnectTimeout time.Duration
+}
+
+func (e *ConnectTimeoutError) Error() string {
+	return fmt.Sprintf("connect_timeout of %s exceeded", e.ConnectTimeout)
+}
+
+func (e *ConnectTimeoutError) Timeout() bool   { return true }
+func (e *ConnectTimeoutError) Temporary() bool { return true }
+
+func (c timeoutConnecter) Connect(ctx context.Context) (Wire, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	type result struct {
+		wire Wire
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		w, err := c.c.Connect(ctx)
+		done <- result{w, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil && ctx.Err() == context.DeadlineExceeded {
+			return nil, &ConnectTimeoutError{c.timeout}
+		}
+		return r.wire, r.err
+	case <-ctx.Done():
+		go func() {
+			r := <-done
+			if r.wire != nil {
+				if err := r.wire.Close(); err != nil {
+					GetLogger(ctx).WithError(err).Error("cannot close connection that was established after connect_timeout")
+				}
+			}
+		}()
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, &ConnectTimeoutError{c.timeout}
+		}
+		return nil, ctx.Err()
+	}
+}
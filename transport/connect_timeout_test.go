@@ -0,0 +1,65 @@
+package transport
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type closeRecordingWire struct {
+	net.Conn // nil, only Close is called
+	closed   chan struct{}
+}
+
+func (w *closeRecordingWire) CloseWrite() error { return nil }
+func (w *closeRecordingWire) Close() error {
+	close(w.closed)
+	return nil
+}
+
+type blockingConnecter struct {
+	ignoreCtx chan struct{} // if not nil, Connect waits for it to be closed instead of the ctx
+	wire      *closeRecordingWire
+}
+
+func (c *blockingConnecter) Connect(ctx context.Context) (Wire, error) {
+	if c.ignoreCtx != nil {
+		<-c.ignoreCtx
+		return c.wire, nil
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestConnecterWithTimeout(t *testing.T) {
+	cn := ConnecterWithTimeout(&blockingConnecter{}, 10*time.Millisecond)
+	_, err := cn.Connect(context.Background())
+	require.Error(t, err)
+	assert.IsType(t, &ConnectTimeoutError{}, err)
+
+	// cancellation of the parent context is not a timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = cn.Connect(ctx)
+	assert.Equal(t, context.Canceled, err)
+
+	// a connecter that does not respect the ctx must not block Connect,
+	// and the wire it eventually returns must be closed
+	bc := &blockingConnecter{
+		ignoreCtx: make(chan struct{}),
+		wire:      &closeRecordingWire{closed: make(chan struct{})},
+	}
+	cn = ConnecterWithTimeout(bc, 10*time.Millisecond)
+	_, err = cn.Connect(context.Background())
+	assert.IsType(t, &ConnectTimeoutError{}, err)
+	close(bc.ignoreCtx)
+	select {
+	case <-bc.wire.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("late wire was not closed")
+	}
+}
@@ -40,20 +40,31 @@ func ListenerFactoryFromConfig(g *config.Global, in config.ServeEnum) (transport
 func ConnecterFromConfig(g *config.Global, in config.ConnectEnum) (transport.Connecter, error) {
 	var (
 		connecter transport.Connecter
+		common    config.ConnectCommon
 		err       error
 	)
 	switch v := in.Ret.(type) {
 	case *config.SSHStdinserverConnect:
 		connecter, err = ssh.SSHStdinserverConnecterFromConfig(v)
+		common = v.ConnectCommon
 	case *config.TCPConnect:
 		connecter, err = tcp.TCPConnecterFromConfig(v)
+		common = v.ConnectCommon
 	case *config.TLSConnect:
 		connecter, err = tls.TLSConnecterFromConfig(v)
+		common = v.ConnectCommon
 	case *config.LocalConnect:
 		connecter, err = local.LocalConnecterFromConfig(v)
+		common = v.ConnectCommon
 	default:
 		panic(fmt.Sprintf("implementation error: unknown connecter type %T", v))
 	}
+	if err != nil {
+		return nil, err
+	}
 
-	return connecter, err
+	if common.ConnectTimeout > 0 {
+		connecter = transport.ConnecterWithTimeout(connecter, common.ConnectTimeout)
+	}
+	return connecter, nil
 }
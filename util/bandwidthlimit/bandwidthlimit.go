@@ -0,0 +1,136 @@
+// Package bandwidthlimit limits the throughput of readers to a rate that can be changed while they are read.
+package bandwidthlimit
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Reads of a limited reader are split into chunks of at most this size
+// so that the waits between them are short and rate changes take effect quickly.
+const maxChunkSize = 1 << 16
+
+// MinRate is the lowest limit in bytes per second, lower rates are raised to it.
+// At this rate, a reader waits about one second per chunk.
+const MinRate = maxChunkSize
+
+// Limiter is a token bucket shared by all readers created with Reader.
+// The bucket holds at most one second worth of bytes at the current rate.
+type Limiter struct {
+	bytes int64 // atomic, bytes read through the limiter
+
+	mtx   sync.Mutex
+	rate  int64   // bytes per second, <= 0 means unlimited
+	avail float64 // bytes that may be read without waiting, negative if readers must wait
+	last  time.Time
+
+	now   func() time.Time
+	sleep func(context.Context, time.Duration) error
+}
+
+// New returns a limiter with the given rate in bytes per second.
+// A rate <= 0 means unlimited, a rate below MinRate is raised to MinRate.
+func New(rate int64) *Limiter {
+	return &Limiter{rate: clampRate(rate), last: time.Now(), now: time.Now, sleep: sleep}
+}
+
+func clampRate(rate int64) int64 {
+	if rate > 0 && rate < MinRate {
+		return MinRate
+	}
+	return rate
+}
+
+// sleep waits for d or until ctx is done, whichever happens first.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SetRate changes the rate in bytes per second, effective for all subsequent reads.
+// A rate <= 0 means unlimited, a rate below MinRate is raised to MinRate.
+func (l *Limiter) SetRate(rate int64) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.refill(l.now())
+	rate = clampRate(rate)
+	l.rate = rate
+	if l.avail > float64(rate) {
+		l.avail = float64(rate)
+	}
+}
+
+// Rate returns the current rate in bytes per second.
+func (l *Limiter) Rate() int64 {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.rate
+}
+
+// Bytes returns the number of bytes that have been read through l.
+func (l *Limiter) Bytes() int64 { return atomic.LoadInt64(&l.bytes) }
+
+func (l *Limiter) refill(now time.Time) {
+	elapsed := now.Sub(l.last)
+	l.last = now
+	if l.rate <= 0 {
+		l.avail = 0
+		return
+	}
+	l.avail += elapsed.Seconds() * float64(l.rate)
+	if l.avail > float64(l.rate) {
+		l.avail = float64(l.rate)
+	}
+}
+
+// take accounts for n bytes that have been read and returns how long the reader must wait
+// before it may read again.
+func (l *Limiter) take(n int) time.Duration {
+	atomic.AddInt64(&l.bytes, int64(n))
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.refill(l.now())
+	if l.rate <= 0 {
+		return 0
+	}
+	l.avail -= float64(n)
+	if l.avail >= 0 {
+		return 0
+	}
+	return time.Duration(-l.avail / float64(l.rate) * float64(time.Second))
+}
+
+type reader struct {
+	io.ReadCloser
+	ctx context.Context
+	l   *Limiter
+}
+
+// Reader returns a reader whose reads are limited by l, together with all other readers of l.
+// Once ctx is done, waiting reads return ctx.Err().
+// Closing it closes rc.
+func (l *Limiter) Reader(ctx context.Context, rc io.ReadCloser) io.ReadCloser {
+	return &reader{rc, ctx, l}
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if len(p) > maxChunkSize {
+		p = p[:maxChunkSize]
+	}
+	n, err := r.ReadCloser.Read(p)
+	if wait := r.l.take(n); wait > 0 {
+		if sleepErr := r.l.sleep(r.ctx, wait); sleepErr != nil && err == nil {
+			err = sleepErr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,86 @@
+package bandwidthlimit
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func (c *fakeClock) sleep(ctx context.Context, d time.Duration) error {
+	c.advance(d)
+	return nil
+}
+
+func newTestLimiter(rate int64) (*Limiter, *fakeClock) {
+	c := &fakeClock{t: time.Unix(1600000000, 0)}
+	l := New(rate)
+	l.now, l.sleep, l.last = c.now, c.sleep, c.t
+	return l, c
+}
+
+func TestLimiterTake(t *testing.T) {
+	const rate = 2 * MinRate
+	l, c := newTestLimiter(rate)
+
+	assert.Equal(t, 500*time.Millisecond, l.take(rate/2))
+	c.advance(500 * time.Millisecond)
+	assert.Equal(t, time.Duration(0), l.take(0))
+
+	// the bucket holds at most one second worth of bytes
+	c.advance(10 * time.Second)
+	assert.Equal(t, time.Duration(0), l.take(rate))
+	assert.Equal(t, 1*time.Second, l.take(rate))
+
+	// a rate change applies to the deficit
+	l.SetRate(2 * rate)
+	assert.Equal(t, 500*time.Millisecond, l.take(0))
+
+	l.SetRate(0)
+	assert.Equal(t, time.Duration(0), l.take(1<<30))
+	assert.Equal(t, int64(5*rate/2+1<<30), l.Bytes())
+}
+
+func TestLimiterMinRate(t *testing.T) {
+	l := New(1)
+	assert.Equal(t, int64(MinRate), l.Rate())
+	l.SetRate(MinRate - 1)
+	assert.Equal(t, int64(MinRate), l.Rate())
+	l.SetRate(0)
+	assert.Equal(t, int64(0), l.Rate())
+}
+
+func TestLimiterReaderContextDone(t *testing.T) {
+	l := New(MinRate)
+	ctx, cancel := context.WithCancel(context.Background())
+	r := l.Reader(ctx, ioutil.NopCloser(bytes.NewReader(make([]byte, 10*MinRate))))
+	// the bucket starts empty, so the first read must wait about one second
+	cancel()
+	begin := time.Now()
+	n, err := r.Read(make([]byte, MinRate))
+	assert.Equal(t, MinRate, n)
+	assert.Equal(t, context.Canceled, err)
+	assert.True(t, time.Since(begin) < 500*time.Millisecond, "the wait must end when ctx is done")
+}
+
+func TestLimiterReader(t *testing.T) {
+	l, c := newTestLimiter(1 << 16)
+	begin := c.t
+
+	data := bytes.Repeat([]byte{0x23}, 1<<20)
+	r := l.Reader(context.Background(), ioutil.NopCloser(bytes.NewReader(data)))
+	read, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, data, read)
+	assert.Equal(t, 16*time.Second, c.t.Sub(begin))
+	assert.Equal(t, int64(len(data)), l.Bytes())
+}
@@ -70,3 +70,99 @@ func ZFSGetEncryptionEnabled(ctx context.Context, fs string) (enabled bool, err
 		return true, nil
 	}
 }
+
+// EncryptionState describes the native encryption state of a dataset.
+type EncryptionState struct {
+	Encrypted      bool
+	EncryptionRoot string // empty if !Encrypted
+	KeyStatus      string // `available` or `unavailable`, empty if !Encrypted
+	KeyLocation    string // only set for encryption roots
+}
+
+// KeyAvailable returns true if the dataset's key is loaded, which is required for ZFSChangeKey.
+func (s *EncryptionState) KeyAvailable() bool { return s.KeyStatus == "available" }
+
+// IsEncryptionRoot returns true if fs is its own encryption root.
+func (s *EncryptionState) IsEncryptionRoot(fs string) bool {
+	return s.Encrypted && s.EncryptionRoot == fs
+}
+
+// returns an unencrypted state if encryption is not supported
+func ZFSGetEncryptionState(ctx context.Context, fs string) (*EncryptionState, error) {
+	enabled, err := ZFSGetEncryptionEnabled(ctx, fs)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return &EncryptionState{Encrypted: false}, nil
+	}
+	props, err := zfsGet(ctx, fs, []string{"encryptionroot", "keystatus", "keylocation"}, sourceAny)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot get encryption properties of %q", fs)
+	}
+	s := &EncryptionState{
+		Encrypted:      true,
+		EncryptionRoot: props.Get("encryptionroot"),
+		KeyStatus:      props.Get("keystatus"),
+	}
+	if s.IsEncryptionRoot(fs) {
+		s.KeyLocation = props.Get("keylocation")
+	}
+	return s, nil
+}
+
+// ChangeKeyOptions determines the arguments to `zfs change-key`.
+// Inherit is mutually exclusive with KeyLocation and KeyFormat.
+type ChangeKeyOptions struct {
+	// Make the dataset inherit the encryption root of its parent (`-i`).
+	Inherit bool
+	// Passed as `-o keylocation=` and `-o keyformat=` if not empty.
+	// The keylocation must not be `prompt` because there is nobody to enter the key.
+	KeyLocation, KeyFormat string
+}
+
+func (o ChangeKeyOptions) args(fs string) ([]string, error) {
+	if err := validateZFSFilesystem(fs); err != nil {
+		return nil, err
+	}
+	args := []string{"change-key"}
+	if o.Inherit {
+		if o.KeyLocation != "" || o.KeyFormat != "" {
+			return nil, errors.New("inherit is mutually exclusive with keylocation and keyformat")
+		}
+		args = append(args, "-i")
+	}
+	if o.KeyLocation == "prompt" {
+		return nil, errors.New("keylocation must not be `prompt`")
+	}
+	if o.KeyLocation != "" {
+		args = append(args, "-o", "keylocation="+o.KeyLocation)
+	}
+	if o.KeyFormat != "" {
+		args = append(args, "-o", "keyformat="+o.KeyFormat)
+	}
+	return append(args, fs), nil
+}
+
+// ZFSChangeKey invokes `zfs change-key` on fs, which requires the keys of fs
+// (and, if opts.Inherit is set, of its parent) to be loaded.
+// Note that `zfs change-key` only changes the wrapping key: the data remains encrypted with the same master key.
+func ZFSChangeKey(ctx context.Context, fs string, opts ChangeKeyOptions) error {
+	if supp, err := EncryptionCLISupported(ctx); err != nil {
+		return err
+	} else if !supp {
+		return errors.New("zfs change-key: native encryption is not supported by the ZFS CLI")
+	}
+	args, err := opts.args(fs)
+	if err != nil {
+		return errors.Wrapf(err, "zfs change-key %q", fs)
+	}
+	cmd := zfscmd.CommandContext(ctx, ZFS_BINARY, args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return &ZFSError{
+			Stderr:  output,
+			WaitErr: err,
+		}
+	}
+	return nil
+}
@@ -6,8 +6,11 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/exec"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -82,6 +85,88 @@ func ZFSHolds(ctx context.Context, fs, snap string) ([]string, error) {
 	return tags, nil
 }
 
+// Hold is a user hold on a snapshot, as reported by `zfs holds`.
+type Hold struct {
+	Snapshot string // full path of the held snapshot
+	Tag      string
+	Created  time.Time
+}
+
+// ZFSListHolds returns the holds on the snapshots of fs and, if recursive is set, of its children.
+func ZFSListHolds(ctx context.Context, fs *DatasetPath, recursive bool) ([]Hold, error) {
+	if err := validateDatasetPath("zfs holds", fs); err != nil {
+		return nil, err
+	}
+	depth := []string{"-d", "1"}
+	if recursive {
+		depth = []string{"-r"}
+	}
+	args := append([]string{"-t", "snapshot"}, depth...)
+	args = append(args, fs.ToString())
+	snaps, err := ZFSList(ctx, []string{"name", "userrefs"}, args...)
+	if err != nil {
+		return nil, err
+	}
+	var held []string
+	for _, s := range snaps {
+		if s[1] != "0" {
+			held = append(held, s[0])
+		}
+	}
+	return ZFSListHoldsOfSnapshots(ctx, held...)
+}
+
+// ZFSListHoldsOfSnapshots returns the holds on snaps (full paths).
+func ZFSListHoldsOfSnapshots(ctx context.Context, snaps ...string) ([]Hold, error) {
+	const maxSnapsPerInvocation = 256
+	var holds []Hold
+	for len(snaps) > 0 {
+		n := len(snaps)
+		if n > maxSnapsPerInvocation {
+			n = maxSnapsPerInvocation
+		}
+		args := append([]string{"holds", "-H", "-p"}, snaps[:n]...)
+		snaps = snaps[n:]
+		output, err := zfscmd.CommandContext(ctx, ZFS_BINARY, args...).Output()
+		if err != nil {
+			ee, ok := err.(*exec.ExitError)
+			if !ok {
+				return nil, err
+			}
+			return nil, &ZFSError{ee.Stderr, errors.Wrap(err, "zfs holds failed")}
+		}
+		h, err := parseZFSHoldsOutput(output)
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, h...)
+	}
+	return holds, nil
+}
+
+// parses the output of `zfs holds -H -p`
+func parseZFSHoldsOutput(output []byte) ([]Hold, error) {
+	scan := bufio.NewScanner(bytes.NewReader(output))
+	var holds []Hold
+	for scan.Scan() {
+		// NAME              TAG  TIMESTAMP
+		comps := strings.SplitN(scan.Text(), "\t", 3)
+		if len(comps) != 3 {
+			return nil, fmt.Errorf("zfs holds: unexpected output line %q", scan.Text())
+		}
+		created, err := strconv.ParseInt(comps[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("zfs holds: cannot parse timestamp of line %q: %s", scan.Text(), err)
+		}
+		holds = append(holds, Hold{
+			Snapshot: comps[0],
+			Tag:      comps[1],
+			Created:  time.Unix(created, 0),
+		})
+	}
+	return holds, scan.Err()
+}
+
 // Idempotent: if the hold doesn't exist, this is not an error
 func ZFSRelease(ctx context.Context, tag string, snaps ...string) error {
 	cumLens := make([]int, len(snaps))
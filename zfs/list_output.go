@@ -0,0 +1,116 @@
+package zfs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/zrepl/zrepl/zfs/zfscmd"
+)
+
+// ZFSListUnexpectedOutputError is returned by ZFSList and ZFSListChan
+// if a line of `zfs list -H -o` output does not have exactly one tab-separated field per requested property.
+//
+// We always request the columns explicitly and parse them by position,
+// so this error indicates that the ZFS version behaves differently than all versions we know of,
+// or that the values of more than one requested user property contain tabs (see splitListLine).
+type ZFSListUnexpectedOutputError struct {
+	Properties []string
+	Line       string
+	Fields     int
+	// Output of `zfs version` (or a placeholder if it is not supported), filled in by ZFSList and ZFSListChan.
+	ZFSVersion string
+}
+
+func (e *ZFSListUnexpectedOutputError) Error() string {
+	version := e.ZFSVersion
+	if version == "" {
+		version = zfsVersionUnknown
+	}
+	return fmt.Sprintf("unexpected zfs list output (zfs version %s): expected %d tab-separated fields (%s), got %d: %q",
+		version, len(e.Properties), strings.Join(e.Properties, ","), e.Fields, e.Line)
+}
+
+// splitListLine splits a line of `zfs list -H -o properties` output into one field per property.
+// Unlike strings.SplitN, surplus columns are an error instead of being merged into the last field.
+//
+// `zfs list -H` does not escape tabs in values. Only the values of user properties can contain tabs,
+// so if exactly one of properties is a user property, surplus columns are merged into its value.
+// With more than one user property, the line is ambiguous and an error is returned.
+func splitListLine(line string, properties []string) ([]string, error) {
+	fields := strings.Split(line, "\t")
+	if surplus := len(fields) - len(properties); surplus > 0 {
+		if i := singleUserProperty(properties); i >= 0 {
+			value := strings.Join(fields[i:i+surplus+1], "\t")
+			fields = append(append(fields[:i:i], value), fields[i+surplus+1:]...)
+		}
+	}
+	if len(fields) != len(properties) {
+		return nil, &ZFSListUnexpectedOutputError{
+			Properties: properties,
+			Line:       line,
+			Fields:     len(fields),
+		}
+	}
+	return fields, nil
+}
+
+// singleUserProperty returns the index of the only user property (`module:property`) in properties,
+// or -1 if there is none or more than one.
+func singleUserProperty(properties []string) int {
+	idx := -1
+	for i, p := range properties {
+		if !strings.Contains(p, ":") {
+			continue
+		}
+		if idx >= 0 {
+			return -1
+		}
+		idx = i
+	}
+	return idx
+}
+
+// withZFSVersion fills in the ZFS version if err is a *ZFSListUnexpectedOutputError.
+func withZFSVersion(ctx context.Context, err error) error {
+	if e, ok := err.(*ZFSListUnexpectedOutputError); ok {
+		e.ZFSVersion = ZFSVersion(ctx)
+	}
+	return err
+}
+
+const zfsVersionUnknown = "unknown"
+
+var zfsVersion struct {
+	once    sync.Once
+	version string
+}
+
+// ZFSVersion returns the userland version reported by `zfs version` (OpenZFS 0.8 and later),
+// e.g. `zfs-2.0.0-1`, or "unknown" if the command is not supported or fails.
+// It is only meant for error messages and logging, and is determined only once.
+func ZFSVersion(ctx context.Context) string {
+	zfsVersion.once.Do(func() {
+		output, err := zfscmd.CommandContext(ctx, ZFS_BINARY, "version").Output()
+		if err != nil {
+			zfsVersion.version = zfsVersionUnknown
+			return
+		}
+		zfsVersion.version = parseZFSVersionOutput(output)
+	})
+	return zfsVersion.version
+}
+
+func parseZFSVersionOutput(output []byte) string {
+	s := bufio.NewScanner(bytes.NewReader(output))
+	for s.Scan() {
+		// the first line is the userland version, the second line the kernel module version
+		if line := strings.TrimSpace(s.Text()); line != "" {
+			return line
+		}
+	}
+	return zfsVersionUnknown
+}
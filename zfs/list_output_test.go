@@ -0,0 +1,100 @@
+package zfs
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Output of `zfs list -H -p -o name,guid,createtxg,creation,userrefs -r -d 1 -t snapshot,bookmark -s createtxg pool/fs`.
+// Bookmarks have no userrefs, which `zfs list -p` prints as `-`.
+const listFilesystemVersionsOutput = "" +
+	"pool/fs@zrepl_20200914_102630_000\t1523482385349862231\t5327\t1600079190\t0\n" +
+	"pool/fs#zrepl_20200914_102630_000\t1523482385349862231\t5327\t1600079190\t-\n" +
+	"pool/fs@manual\t9093289128947621385\t5330\t1600079254\t1\n"
+
+func TestSplitListLineListFilesystemVersionsOutput(t *testing.T) {
+	props := []string{"name", "guid", "createtxg", "creation", "userrefs"}
+	lines := strings.Split(strings.TrimSuffix(listFilesystemVersionsOutput, "\n"), "\n")
+	results := make(chan ZFSListResult, len(lines))
+	for _, line := range lines {
+		fields, err := splitListLine(line, props)
+		require.NoError(t, err, "%q", line)
+		results <- ZFSListResult{Fields: fields}
+	}
+	close(results)
+
+	versions, err := filesystemVersionsFromListResults(context.Background(), results, ListFilesystemVersionsOptions{})
+	require.NoError(t, err)
+	var names []string
+	for _, v := range versions {
+		names = append(names, v.RelName())
+	}
+	assert.Equal(t, []string{"@zrepl_20200914_102630_000", "#zrepl_20200914_102630_000", "@manual"}, names)
+	assert.Equal(t, uint64(1523482385349862231), versions[0].Guid)
+	assert.Equal(t, uint64(5330), versions[2].CreateTXG)
+}
+
+func TestSplitListLineUserPropertyWithTabs(t *testing.T) {
+	fields, err := splitListLine("pool/fs\ta\tb\t\t1", []string{"name", "zrepl:comment", "guid"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pool/fs", "a\tb\t", "1"}, fields)
+
+	fields, err = splitListLine("pool/fs\t1\ta\tb", []string{"name", "guid", "zrepl:comment"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pool/fs", "1", "a\tb"}, fields)
+
+	// ambiguous: which of the values contains the tab?
+	_, err = splitListLine("pool/fs\ta\tb\tc", []string{"name", "zrepl:a", "zrepl:b"})
+	require.Error(t, err)
+	_, ok := err.(*ZFSListUnexpectedOutputError)
+	assert.True(t, ok, "%T", err)
+
+	// missing columns are never merged
+	_, err = splitListLine("pool/fs", []string{"name", "zrepl:comment", "guid"})
+	assert.Error(t, err)
+}
+
+func TestSplitListLineUnexpectedOutput(t *testing.T) {
+	props := []string{"name", "guid", "createtxg"}
+
+	fields, err := splitListLine("pool/fs@a\t1\t10", props)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pool/fs@a", "1", "10"}, fields)
+
+	fields, err = splitListLine("pool/fs@a\t\t10", props)
+	require.NoError(t, err, "empty values are not our concern")
+	assert.Equal(t, []string{"pool/fs@a", "", "10"}, fields)
+
+	for _, line := range []string{
+		"pool/fs@a\t1\t10\t1600000000", // surplus column must not be merged into createtxg
+		"pool/fs@a\t1",
+		"pool/fs@a 1 10", // not tab-separated
+		"",
+	} {
+		_, err := splitListLine(line, props)
+		require.Error(t, err, "%q", line)
+		unexpected, ok := err.(*ZFSListUnexpectedOutputError)
+		require.True(t, ok, "%T", err)
+		assert.Equal(t, line, unexpected.Line)
+		assert.Contains(t, err.Error(), "zfs version unknown")
+		assert.Contains(t, err.Error(), "expected 3 tab-separated fields (name,guid,createtxg)")
+
+		unexpected.ZFSVersion = "zfs-2.1.5-1"
+		assert.Contains(t, err.Error(), "zfs version zfs-2.1.5-1")
+	}
+}
+
+func TestParseZFSVersionOutput(t *testing.T) {
+	for output, expect := range map[string]string{
+		"zfs-2.1.5-1ubuntu6~22.04.1\nzfs-kmod-2.1.5-1ubuntu6~22.04.1\n":     "zfs-2.1.5-1ubuntu6~22.04.1",
+		"zfs-0.8.3-1ubuntu12\nzfs-kmod-0.8.3-1ubuntu12\n":                   "zfs-0.8.3-1ubuntu12",
+		"zfs-2.1.4-FreeBSD_g52bad4f23\nzfs-kmod-2.1.4-FreeBSD_g52bad4f23\n": "zfs-2.1.4-FreeBSD_g52bad4f23",
+		"": zfsVersionUnknown,
+	} {
+		assert.Equal(t, expect, parseZFSVersionOutput([]byte(output)))
+	}
+}
@@ -20,6 +20,20 @@ var _ DatasetFilter = noFilter{}
 
 func (noFilter) Filter(p *DatasetPath) (pass bool, err error) { return true, nil }
 
+// DatasetPropertyFilter is implemented by DatasetFilters that (also) match datasets by ZFS property values.
+//
+// ZFSListMapping and ZFSListMappingProperties fetch the properties returned by FilterProperties
+// and call FilterWithProperties instead of Filter.
+// Fetching the properties requires an additional `zfs get` invocation,
+// which is why it is limited to filters that implement this interface.
+type DatasetPropertyFilter interface {
+	DatasetFilter
+	FilterProperties() []string
+	// props contains the values of FilterProperties, and is nil if they could not be determined
+	// (e.g., because p was created after they were fetched)
+	FilterWithProperties(p *DatasetPath, props map[string]PropertyValue) (pass bool, err error)
+}
+
 func ZFSListMapping(ctx context.Context, filter DatasetFilter) (datasets []*DatasetPath, err error) {
 	res, err := ZFSListMappingProperties(ctx, filter, nil)
 	if err != nil {
@@ -55,33 +69,42 @@ func ZFSListMappingProperties(ctx context.Context, filter DatasetFilter, propert
 	copy(newProps[1:], properties)
 	properties = newProps
 
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
-	rchan := make(chan ZFSListResult)
+	var filterProps map[string]map[string]PropertyValue
+	propFilter, filterByProps := filter.(DatasetPropertyFilter)
+	if filterByProps {
+		filterProps, err = ZFSGetPropertyValues(ctx, propFilter.FilterProperties())
+		if err != nil {
+			return nil, fmt.Errorf("cannot get properties for filter: %s", err)
+		}
+	}
 
-	go ZFSListChan(ctx, rchan, properties, nil, "-r", "-t", "filesystem,volume")
+	rows, err := datasetListCacheInstance.list(ctx, properties)
+	if err != nil {
+		return nil, err
+	}
 
 	datasets = make([]ZFSListMappingPropertiesResult, 0)
-	for r := range rchan {
-
-		if r.Err != nil {
-			err = r.Err
-			return
-		}
+	for _, fields := range rows {
 
 		var path *DatasetPath
-		if path, err = NewDatasetPath(r.Fields[0]); err != nil {
+		if path, err = NewDatasetPath(fields[0]); err != nil {
 			return
 		}
 
-		pass, filterErr := filter.Filter(path)
+		var pass bool
+		var filterErr error
+		if filterByProps {
+			pass, filterErr = propFilter.FilterWithProperties(path, filterProps[path.ToString()])
+		} else {
+			pass, filterErr = filter.Filter(path)
+		}
 		if filterErr != nil {
 			return nil, fmt.Errorf("error calling filter: %s", filterErr)
 		}
 		if pass {
 			datasets = append(datasets, ZFSListMappingPropertiesResult{
 				Path:   path,
-				Fields: r.Fields[1:],
+				Fields: append([]string(nil), fields[1:]...), // rows are shared by the cache
 			})
 		}
 
@@ -0,0 +1,95 @@
+package zfs
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// datasetListCache caches the output of `zfs list -r -t filesystem,volume` for ZFSListMappingProperties,
+// keyed by the listed properties. It is shared by all jobs of the daemon.
+//
+// Entries expire after ttl, and all entries are invalidated when zrepl creates or destroys datasets
+// or changes their properties. Changes made outside of zrepl become visible after at most ttl.
+type datasetListCache struct {
+	fetch func(ctx context.Context, properties []string) ([][]string, error)
+	now   func() time.Time
+
+	mtx sync.Mutex
+	ttl time.Duration // 0 disables the cache
+	// incremented by invalidate, results of lists that started before an invalidation are not cached
+	generation uint64
+	entries    map[string]datasetListCacheEntry
+}
+
+type datasetListCacheEntry struct {
+	fetchedAt time.Time
+	rows      [][]string
+}
+
+var datasetListCacheInstance = newDatasetListCache(func(ctx context.Context, properties []string) ([][]string, error) {
+	return ZFSList(ctx, properties, "-r", "-t", "filesystem,volume")
+})
+
+func newDatasetListCache(fetch func(ctx context.Context, properties []string) ([][]string, error)) *datasetListCache {
+	return &datasetListCache{
+		fetch:   fetch,
+		now:     time.Now,
+		entries: make(map[string]datasetListCacheEntry),
+	}
+}
+
+// SetDatasetListCacheTTL enables caching of the dataset list used by ZFSListMapping and ZFSListMappingProperties
+// for ttl. A ttl of 0 disables the cache.
+func SetDatasetListCacheTTL(ttl time.Duration) {
+	c := datasetListCacheInstance
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.ttl = ttl
+	c.invalidateLocked()
+}
+
+// InvalidateDatasetListCache must be called after creating or destroying datasets
+// or changing their properties by means other than the functions of this package.
+func InvalidateDatasetListCache() {
+	datasetListCacheInstance.invalidate()
+}
+
+func (c *datasetListCache) invalidate() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.invalidateLocked()
+}
+
+func (c *datasetListCache) invalidateLocked() {
+	c.generation++
+	c.entries = make(map[string]datasetListCacheEntry)
+}
+
+// list returns the rows of the dataset list with the given properties.
+// The returned rows are shared and must not be modified.
+func (c *datasetListCache) list(ctx context.Context, properties []string) ([][]string, error) {
+	key := strings.Join(properties, ",")
+
+	c.mtx.Lock()
+	ttl, generation := c.ttl, c.generation
+	if e, ok := c.entries[key]; ok && c.now().Sub(e.fetchedAt) < ttl {
+		c.mtx.Unlock()
+		return e.rows, nil
+	}
+	c.mtx.Unlock()
+
+	fetchedAt := c.now()
+	rows, err := c.fetch(ctx, properties)
+	if err != nil || ttl == 0 {
+		return rows, err
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.generation == generation {
+		c.entries[key] = datasetListCacheEntry{fetchedAt: fetchedAt, rows: rows}
+	}
+	return rows, nil
+}
@@ -0,0 +1,83 @@
+package zfs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatasetListCache(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(1600000000, 0)
+	fetches := 0
+	var fetchErr error
+	c := newDatasetListCache(func(ctx context.Context, properties []string) ([][]string, error) {
+		fetches++
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		return [][]string{append([]string{"pool"}, properties...)}, nil
+	})
+	c.now = func() time.Time { return now }
+
+	list := func(props ...string) [][]string {
+		rows, err := c.list(ctx, props)
+		require.NoError(t, err)
+		return rows
+	}
+
+	// disabled by default
+	list("name")
+	list("name")
+	assert.Equal(t, 2, fetches)
+
+	c.ttl = 10 * time.Second
+	assert.Equal(t, [][]string{{"pool", "name"}}, list("name"))
+	now = now.Add(9 * time.Second)
+	assert.Equal(t, [][]string{{"pool", "name"}}, list("name"))
+	assert.Equal(t, 3, fetches)
+
+	// keyed by properties
+	assert.Equal(t, [][]string{{"pool", "name", "used"}}, list("name", "used"))
+	assert.Equal(t, 4, fetches)
+
+	now = now.Add(time.Second)
+	list("name")
+	assert.Equal(t, 5, fetches, "expired")
+
+	c.invalidate()
+	list("name")
+	assert.Equal(t, 6, fetches, "invalidated")
+
+	// errors are not cached
+	c.invalidate()
+	fetchErr = errors.New("zfs list failed")
+	_, err := c.list(ctx, []string{"name"})
+	assert.Error(t, err)
+	fetchErr = nil
+	list("name")
+	assert.Equal(t, 8, fetches)
+}
+
+func TestDatasetListCacheInvalidationDuringFetch(t *testing.T) {
+	var c *datasetListCache
+	fetches := 0
+	c = newDatasetListCache(func(ctx context.Context, properties []string) ([][]string, error) {
+		fetches++
+		if fetches == 1 {
+			c.invalidate() // e.g., a filesystem was created while zfs list was running
+		}
+		return [][]string{{"pool"}}, nil
+	})
+	c.ttl = time.Hour
+
+	for i := 0; i < 3; i++ {
+		_, err := c.list(context.Background(), []string{"name"})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 2, fetches, "the result of the first fetch might be stale and must not be cached")
+}
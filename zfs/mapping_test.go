@@ -0,0 +1,28 @@
+package zfs
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZFSListMappingMaxLengthName(t *testing.T) {
+	// ZFS allows names of MaxDatasetNameLen bytes, which leave no room for snapshots
+	long := "pool/" + strings.Repeat("a", MaxDatasetNameLen-len("pool/"))
+	require.Len(t, long, MaxDatasetNameLen)
+
+	orig := datasetListCacheInstance
+	defer func() { datasetListCacheInstance = orig }()
+	datasetListCacheInstance = newDatasetListCache(func(ctx context.Context, properties []string) ([][]string, error) {
+		return [][]string{{"pool"}, {long}}, nil
+	})
+
+	datasets, err := ZFSListMapping(context.Background(), NoFilter())
+	require.NoError(t, err)
+	require.Len(t, datasets, 2)
+	assert.Equal(t, long, datasets[1].ToString())
+	assert.Error(t, datasets[1].ValidateSnapshotHeadroom())
+}
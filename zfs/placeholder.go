@@ -63,6 +63,9 @@ type FilesystemPlaceholderState struct {
 //
 // For nonexistent FS, err == nil and state.FSExists == false
 func ZFSGetFilesystemPlaceholderState(ctx context.Context, p *DatasetPath) (state *FilesystemPlaceholderState, err error) {
+	if err := validateDatasetPath("get placeholder state", p); err != nil {
+		return nil, err
+	}
 	state = &FilesystemPlaceholderState{FS: p.ToString()}
 	state.FS = p.ToString()
 	props, err := zfsGet(ctx, p.ToString(), []string{PlaceholderPropertyName}, sourceLocal)
@@ -79,9 +82,13 @@ func ZFSGetFilesystemPlaceholderState(ctx context.Context, p *DatasetPath) (stat
 }
 
 func ZFSCreatePlaceholderFilesystem(ctx context.Context, p *DatasetPath) (err error) {
+	if err := validateDatasetPath("zfs create", p); err != nil {
+		return err
+	}
 	if p.Length() == 1 {
 		return fmt.Errorf("cannot create %q: pools cannot be created with zfs create", p.ToString())
 	}
+	defer InvalidateDatasetListCache()
 	cmd := zfscmd.CommandContext(ctx, ZFS_BINARY, "create",
 		"-o", fmt.Sprintf("%s=%s", PlaceholderPropertyName, placeholderPropertyOn),
 		"-o", "mountpoint=none",
@@ -99,6 +106,9 @@ func ZFSCreatePlaceholderFilesystem(ctx context.Context, p *DatasetPath) (err er
 }
 
 func ZFSSetPlaceholder(ctx context.Context, p *DatasetPath, isPlaceholder bool) error {
+	if err := validateDatasetPath("set placeholder", p); err != nil {
+		return err
+	}
 	props := NewZFSProperties()
 	prop := placeholderPropertyOff
 	if isPlaceholder {
@@ -113,14 +123,14 @@ type MigrateHashBasedPlaceholderReport struct {
 	NeedsModification bool
 }
 
-// fs must exist, will panic otherwise
+// returns a *DatasetDoesNotExist error if fs does not exist (anymore)
 func ZFSMigrateHashBasedPlaceholderToCurrent(ctx context.Context, fs *DatasetPath, dryRun bool) (*MigrateHashBasedPlaceholderReport, error) {
 	st, err := ZFSGetFilesystemPlaceholderState(ctx, fs)
 	if err != nil {
 		return nil, fmt.Errorf("error getting placeholder state: %s", err)
 	}
 	if !st.FSExists {
-		panic("inconsistent placeholder state returned: fs must exist")
+		return nil, &DatasetDoesNotExist{Path: fs.ToString()}
 	}
 
 	report := MigrateHashBasedPlaceholderReport{
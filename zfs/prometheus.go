@@ -7,6 +7,7 @@ var prom struct {
 	ZFSSnapshotDuration              *prometheus.HistogramVec
 	ZFSBookmarkDuration              *prometheus.HistogramVec
 	ZFSDestroyDuration               *prometheus.HistogramVec
+	ZFSWrittenSinceLastSnapshot      *prometheus.GaugeVec
 }
 
 func init() {
@@ -34,6 +35,12 @@ func init() {
 		Name:      "destroy_duration",
 		Help:      "Duration it took to destroy a dataset",
 	}, []string{"dataset_type", "filesystem"})
+	prom.ZFSWrittenSinceLastSnapshot = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "zrepl",
+		Subsystem: "zfs",
+		Name:      "written_since_last_snapshot_bytes",
+		Help:      "Bytes written to a given filesystem since its most recent snapshot, as of the last time zrepl queried it",
+	}, []string{"filesystem"})
 }
 
 func PrometheusRegister(registry prometheus.Registerer) error {
@@ -49,5 +56,8 @@ func PrometheusRegister(registry prometheus.Registerer) error {
 	if err := registry.Register(prom.ZFSDestroyDuration); err != nil {
 		return err
 	}
+	if err := registry.Register(prom.ZFSWrittenSinceLastSnapshot); err != nil {
+		return err
+	}
 	return nil
 }
@@ -0,0 +1,156 @@
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ValidateRecvSetProperties checks the syntax of the keys and values of RecvOptions.SetProperties.
+// Whether ZFS accepts a property is only known when receiving.
+func ValidateRecvSetProperties(props map[string]string) error {
+	for k, v := range props {
+		if k == "" || strings.ContainsAny(k, "= \t\n") {
+			return fmt.Errorf("invalid property name %q", k)
+		}
+		if v == "" {
+			return fmt.Errorf("value of property %q must not be empty", k)
+		}
+		if strings.ContainsAny(v, "\t\n") {
+			return fmt.Errorf("value of property %q must not contain tabs or newlines", k)
+		}
+	}
+	return nil
+}
+
+// ValidateRecvExcludeProperties checks the syntax of RecvOptions.ExcludeProperties
+// and that none of them is also set using RecvOptions.SetProperties.
+func ValidateRecvExcludeProperties(exclude []string, set map[string]string) error {
+	seen := make(map[string]bool, len(exclude))
+	for _, k := range exclude {
+		if k == "" || strings.ContainsAny(k, "= \t\n") {
+			return fmt.Errorf("invalid property name %q", k)
+		}
+		if seen[k] {
+			return fmt.Errorf("property %q is excluded more than once", k)
+		}
+		seen[k] = true
+		if _, ok := set[k]; ok {
+			return fmt.Errorf("property %q cannot be both set and excluded", k)
+		}
+	}
+	return nil
+}
+
+func recvExcludePropertiesArgs(props []string) []string {
+	args := make([]string, 0, 2*len(props))
+	for _, k := range props {
+		args = append(args, "-x", k)
+	}
+	return args
+}
+
+func recvSetPropertiesArgs(props map[string]string) []string {
+	keys := make([]string, 0, len(props))
+	for k := range props {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys) // deterministic command line
+	args := make([]string, 0, 2*len(keys))
+	for _, k := range keys {
+		args = append(args, "-o", fmt.Sprintf("%s=%s", k, props[k]))
+	}
+	return args
+}
+
+// RecvPropertyMismatch is a property that was set using `zfs recv -o` but does not have the requested value.
+type RecvPropertyMismatch struct {
+	Property string
+	Expected string
+	Actual   string
+	IsLocal  bool // false if the value is not set locally, e.g., received from the stream or inherited
+}
+
+func (m RecvPropertyMismatch) String() string {
+	if !m.IsLocal {
+		return fmt.Sprintf("%s=%s was requested, but the effective value %q is not set locally", m.Property, m.Expected, m.Actual)
+	}
+	return fmt.Sprintf("%s=%s was requested, but the value is %q", m.Property, m.Expected, m.Actual)
+}
+
+// RecvPropertyMismatches returns the properties in expected that do not have the expected value on fs,
+// e.g. because the property was part of the stream and ZFS preferred the received value.
+func RecvPropertyMismatches(ctx context.Context, fs *DatasetPath, expected map[string]string) ([]RecvPropertyMismatch, error) {
+	if len(expected) == 0 {
+		return nil, nil
+	}
+	names := make([]string, 0, len(expected))
+	for k := range expected {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	effective, err := zfsGet(ctx, fs.ToString(), names, sourceAny)
+	if err != nil {
+		return nil, err
+	}
+	local, err := zfsGet(ctx, fs.ToString(), names, sourceLocal)
+	if err != nil {
+		return nil, err
+	}
+	return recvPropertyMismatches(expected, effective, local), nil
+}
+
+func recvPropertyMismatches(expected map[string]string, effective, local *ZFSProperties) (mismatches []RecvPropertyMismatch) {
+	names := make([]string, 0, len(expected))
+	for k := range expected {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		_, isLocal := local.m[name]
+		actual := effective.Get(name)
+		if isLocal && propertyValuesEqual(expected[name], actual) {
+			continue
+		}
+		mismatches = append(mismatches, RecvPropertyMismatch{
+			Property: name,
+			Expected: expected[name],
+			Actual:   actual,
+			IsLocal:  isLocal,
+		})
+	}
+	return mismatches
+}
+
+// propertyValuesEqual compares a value as set by the user with a value as returned by `zfs get -p`,
+// which prints sizes in bytes (e.g. recordsize=1M is returned as 1048576).
+func propertyValuesEqual(set, parsable string) bool {
+	if strings.EqualFold(set, parsable) {
+		return true
+	}
+	a, aErr := parseZFSSize(set)
+	b, bErr := parseZFSSize(parsable)
+	return aErr == nil && bErr == nil && a == b
+}
+
+// parseZFSSize parses sizes like 512, 128K, 1M or 1.5G (binary prefixes, an optional trailing B is ignored).
+func parseZFSSize(s string) (uint64, error) {
+	s = strings.TrimSuffix(strings.ToUpper(s), "B")
+	mult := uint64(1)
+	if n := len(s); n > 0 {
+		if i := strings.IndexByte("KMGTPE", s[n-1]); i >= 0 {
+			mult = 1 << (10 * uint(i+1))
+			s = s[:n-1]
+		}
+	}
+	if v, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return v * mult, nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("not a size: %q", s)
+	}
+	return uint64(f * float64(mult)), nil
+}
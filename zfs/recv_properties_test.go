@@ -0,0 +1,64 @@
+package zfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecvSetPropertiesArgs(t *testing.T) {
+	assert.Empty(t, recvSetPropertiesArgs(nil))
+	assert.Equal(t,
+		[]string{"-o", "compression=zstd", "-o", "recordsize=1M"},
+		recvSetPropertiesArgs(map[string]string{"recordsize": "1M", "compression": "zstd"}))
+
+	assert.NoError(t, ValidateRecvSetProperties(map[string]string{"recordsize": "1M", "user:prop": "a b"}))
+	assert.Error(t, ValidateRecvSetProperties(map[string]string{"a=b": "c"}))
+	assert.Error(t, ValidateRecvSetProperties(map[string]string{"": "c"}))
+	assert.Error(t, ValidateRecvSetProperties(map[string]string{"compression": ""}))
+}
+
+func TestRecvExcludePropertiesArgs(t *testing.T) {
+	assert.Empty(t, recvExcludePropertiesArgs(nil))
+	assert.Equal(t,
+		[]string{"-x", "mountpoint", "-x", "sharenfs"},
+		recvExcludePropertiesArgs([]string{"mountpoint", "sharenfs"}))
+
+	set := map[string]string{"canmount": "off"}
+	assert.NoError(t, ValidateRecvExcludeProperties([]string{"mountpoint", "user:prop"}, set))
+	assert.Error(t, ValidateRecvExcludeProperties([]string{"mountpoint", "mountpoint"}, set))
+	assert.Error(t, ValidateRecvExcludeProperties([]string{"canmount"}, set))
+	assert.Error(t, ValidateRecvExcludeProperties([]string{""}, set))
+	assert.Error(t, ValidateRecvExcludeProperties([]string{"a=b"}, set))
+}
+
+func TestRecvPropertyMismatches(t *testing.T) {
+	props := func(kv ...string) *ZFSProperties {
+		p := NewZFSProperties()
+		for i := 0; i < len(kv); i += 2 {
+			p.Set(kv[i], kv[i+1])
+		}
+		return p
+	}
+	expected := map[string]string{"recordsize": "1M", "compression": "zstd", "atime": "off"}
+
+	// zfs get -p prints sizes in bytes
+	effective := props("recordsize", "1048576", "compression", "zstd", "atime", "off")
+	assert.Empty(t, recvPropertyMismatches(expected, effective, effective))
+
+	effective = props("recordsize", "131072", "compression", "lz4", "atime", "off")
+	local := props("recordsize", "131072", "atime", "off")
+	assert.Equal(t, []RecvPropertyMismatch{
+		{Property: "compression", Expected: "zstd", Actual: "lz4", IsLocal: false},
+		{Property: "recordsize", Expected: "1M", Actual: "131072", IsLocal: true},
+	}, recvPropertyMismatches(expected, effective, local))
+}
+
+func TestPropertyValuesEqual(t *testing.T) {
+	assert.True(t, propertyValuesEqual("1M", "1048576"))
+	assert.True(t, propertyValuesEqual("128k", "131072"))
+	assert.True(t, propertyValuesEqual("1.5K", "1536"))
+	assert.True(t, propertyValuesEqual("OFF", "off"))
+	assert.False(t, propertyValuesEqual("1M", "131072"))
+	assert.False(t, propertyValuesEqual("zstd", "zstd-19"))
+}
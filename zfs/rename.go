@@ -0,0 +1,89 @@
+package zfs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/zrepl/zrepl/zfs/zfscmd"
+)
+
+type RenameOptions struct {
+	// create the missing ancestors of the destination (`zfs rename -p`)
+	CreateParents bool
+}
+
+// ZFSRename renames the filesystem or volume from to to, including its snapshots and descendants.
+// ZFS does not support renames across pools, from and to must be in the same pool.
+func ZFSRename(ctx context.Context, from, to *DatasetPath, opts RenameOptions) error {
+	if err := validateDatasetPath("zfs rename", from, to); err != nil {
+		return err
+	}
+	if err := validateRename(from, to); err != nil {
+		return err
+	}
+	args := []string{"rename"}
+	if opts.CreateParents {
+		args = append(args, "-p")
+	}
+	args = append(args, from.ToString(), to.ToString())
+	defer InvalidateDatasetListCache()
+	return zfsRename(ctx, from.ToString(), args)
+}
+
+func validateRename(from, to *DatasetPath) error {
+	for _, p := range []*DatasetPath{from, to} {
+		if err := EntityNamecheck(p.ToString(), EntityTypeFilesystem); err != nil {
+			return err
+		}
+		if p.Length() < 2 {
+			return fmt.Errorf("cannot rename %q to %q: pool root datasets cannot be renamed", from.ToString(), to.ToString())
+		}
+	}
+	if from.Pool() != to.Pool() {
+		return fmt.Errorf("cannot rename %q to %q: renames across pools are not supported", from.ToString(), to.ToString())
+	}
+	if to.HasPrefix(from) {
+		return fmt.Errorf("cannot rename %q to %q: destination must not be the dataset itself or one of its descendants", from.ToString(), to.ToString())
+	}
+	return nil
+}
+
+// ZFSRenameSnapshot renames the snapshot fs@oldName to fs@newName.
+func ZFSRenameSnapshot(ctx context.Context, fs *DatasetPath, oldName, newName string) error {
+	if err := validateDatasetPath("zfs rename", fs); err != nil {
+		return err
+	}
+	from := fmt.Sprintf("%s@%s", fs.ToString(), oldName)
+	to := fmt.Sprintf("%s@%s", fs.ToString(), newName)
+	if err := validateRenameSnapshot(from, to); err != nil {
+		return err
+	}
+	return zfsRename(ctx, from, []string{"rename", from, to})
+}
+
+func validateRenameSnapshot(from, to string) error {
+	for _, s := range []string{from, to} {
+		if err := EntityNamecheck(s, EntityTypeSnapshot); err != nil {
+			return err
+		}
+	}
+	if from == to {
+		return fmt.Errorf("cannot rename %q: old and new name are equal", from)
+	}
+	return nil
+}
+
+func zfsRename(ctx context.Context, from string, args []string) error {
+	cmd := zfscmd.CommandContext(ctx, ZFS_BINARY, args...)
+	stdio, err := cmd.CombinedOutput()
+	if err != nil {
+		if dsNotExistErr := tryDatasetDoesNotExist(from, stdio); dsNotExistErr != nil {
+			return dsNotExistErr
+		}
+		return &ZFSError{
+			Stderr:  stdio,
+			WaitErr: err,
+		}
+	}
+	return nil
+}
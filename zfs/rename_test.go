@@ -0,0 +1,38 @@
+package zfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRename(t *testing.T) {
+	tcs := []struct {
+		from, to string
+		valid    bool
+	}{
+		{"pool/a", "pool/b", true},
+		{"pool/a/b", "pool/c/d/e", true},
+		{"pool/a", "pool/ab", true},
+		{"pool/a", "other/a", false},
+		{"pool", "pool2", false},
+		{"pool/a", "pool", false},
+		{"pool/a", "pool/a", false},
+		{"pool/a", "pool/a/b", false},
+	}
+	for _, tc := range tcs {
+		err := validateRename(toDatasetPath(tc.from), toDatasetPath(tc.to))
+		if tc.valid {
+			assert.NoError(t, err, "%s => %s", tc.from, tc.to)
+		} else {
+			assert.Error(t, err, "%s => %s", tc.from, tc.to)
+		}
+	}
+}
+
+func TestValidateRenameSnapshot(t *testing.T) {
+	assert.NoError(t, validateRenameSnapshot("pool/a@old", "pool/a@new"))
+	assert.Error(t, validateRenameSnapshot("pool/a@old", "pool/a@old"))
+	assert.Error(t, validateRenameSnapshot("pool/a@old", "pool/a@new/x"))
+	assert.Error(t, validateRenameSnapshot("pool/a@", "pool/a@new"))
+}
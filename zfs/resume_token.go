@@ -23,6 +23,9 @@ type ResumeToken struct {
 	ToName                    string
 	HasCompressOK, CompressOK bool
 	HasRawOk, RawOK           bool
+	// bytes of the stream that had been received when the token was created
+	HasBytes bool
+	Bytes    uint64
 }
 
 var resumeTokenNVListRE = regexp.MustCompile(`\t(\S+) = (.*)`)
@@ -109,9 +112,9 @@ func ResumeRecvSupported(ctx context.Context, fs *DatasetPath) (bool, error) {
 	// Flag is supported and pool-support is request
 	// Now check for pool support
 
-	pool, err := fs.Pool()
-	if err != nil {
-		return false, errors.Wrap(err, "resume recv check requires pool of dataset")
+	pool := fs.Pool()
+	if pool == "" {
+		return false, errors.New("resume recv check requires pool of dataset")
 	}
 
 	if sup.poolSupported == nil {
@@ -234,6 +237,12 @@ func ParseResumeToken(ctx context.Context, token string) (*ResumeToken, error) {
 			if err != nil {
 				return nil, ResumeTokenParsingError
 			}
+		case "bytes":
+			rt.Bytes, err = strconv.ParseUint(val, 0, 64)
+			if err != nil {
+				return nil, ResumeTokenParsingError
+			}
+			rt.HasBytes = true
 		case "compressok":
 			rt.HasCompressOK = true
 			rt.CompressOK, err = strconv.ParseBool(val)
@@ -253,6 +262,9 @@ func ParseResumeToken(ctx context.Context, token string) (*ResumeToken, error) {
 
 // if string is empty and err == nil, the feature is not supported
 func ZFSGetReceiveResumeTokenOrEmptyStringIfNotSupported(ctx context.Context, fs *DatasetPath) (string, error) {
+	if err := validateDatasetPath("get resume token", fs); err != nil {
+		return "", err
+	}
 	if supported, err := ResumeRecvSupported(ctx, fs); err != nil {
 		return "", errors.Wrap(err, "cannot determine zfs recv resume support")
 	} else if !supported {
@@ -272,6 +284,29 @@ func ZFSGetReceiveResumeTokenOrEmptyStringIfNotSupported(ctx context.Context, fs
 	}
 }
 
+// ZFSGetReceiveResumeStateCreation returns the time at which the partially received state of fs was created.
+//
+// For incremental receives, the partial state is kept in the hidden clone `fs/%recv`.
+// For full receives, fs itself is the partial state.
+// It is the caller's responsibility to check that fs has a resume token.
+func ZFSGetReceiveResumeStateCreation(ctx context.Context, fs *DatasetPath) (time.Time, error) {
+	if err := validateDatasetPath("get resume state creation", fs); err != nil {
+		return time.Time{}, err
+	}
+	props, err := zfsGet(ctx, fs.ToString()+"/%recv", []string{"creation"}, sourceAny)
+	if _, ok := err.(*DatasetDoesNotExist); ok {
+		props, err = zfsGet(ctx, fs.ToString(), []string{"creation"}, sourceAny)
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	creation, err := strconv.ParseInt(props.Get("creation"), 10, 64)
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "cannot parse creation date %q", props.Get("creation"))
+	}
+	return time.Unix(creation, 0), nil
+}
+
 func (t *ResumeToken) ToNameSplit() (fs *DatasetPath, snapName string, err error) {
 	comps := strings.SplitN(t.ToName, "@", 2)
 	if len(comps) != 2 {
@@ -0,0 +1,77 @@
+package zfs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// SendStreamHeader is the information of the DRR_BEGIN record at the start of a `zfs send` stream.
+type SendStreamHeader struct {
+	// the full name of the sent snapshot, e.g. `pool/fs@snap`
+	ToName   string
+	ToGUID   uint64
+	FromGUID uint64 // 0 for full streams
+}
+
+func (h *SendStreamHeader) IsIncremental() bool { return h.FromGUID != 0 }
+
+// ToSnapshotName returns the snapshot part of ToName, e.g. `snap` for `pool/fs@snap`.
+func (h *SendStreamHeader) ToSnapshotName() (string, error) {
+	i := strings.LastIndex(h.ToName, "@")
+	if i < 0 || i == len(h.ToName)-1 {
+		return "", fmt.Errorf("stream header `toname` is not a snapshot: %q", h.ToName)
+	}
+	return h.ToName[i+1:], nil
+}
+
+// struct dmu_replay_record with drr_type DRR_BEGIN, see OpenZFS include/sys/zfs_ioctl.h
+const (
+	sendStreamDRRBegin            = 0
+	sendStreamBackupMagic         = 0x2F5bacbac
+	sendStreamBeginMagicOffset    = 8
+	sendStreamBeginToGUIDOffset   = 40
+	sendStreamBeginFromGUIDOffset = 48
+	sendStreamBeginToNameOffset   = 56
+	sendStreamBeginToNameLen      = 256 // MAXNAMELEN
+	sendStreamBeginLen            = sendStreamBeginToNameOffset + sendStreamBeginToNameLen
+)
+
+// PeekSendStreamHeader parses the header of the send stream read by r without consuming it.
+// The byte order of the stream is that of the sending host and detected using the magic number.
+func PeekSendStreamHeader(r *bufio.Reader) (*SendStreamHeader, error) {
+	buf, err := r.Peek(sendStreamBeginLen)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read send stream header: %s", err)
+	}
+	return parseSendStreamHeader(buf)
+}
+
+func parseSendStreamHeader(buf []byte) (*SendStreamHeader, error) {
+	if len(buf) < sendStreamBeginLen {
+		return nil, fmt.Errorf("send stream header too short")
+	}
+	var order binary.ByteOrder
+	switch {
+	case binary.LittleEndian.Uint64(buf[sendStreamBeginMagicOffset:]) == sendStreamBackupMagic:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint64(buf[sendStreamBeginMagicOffset:]) == sendStreamBackupMagic:
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("not a zfs send stream (invalid magic number)")
+	}
+	if drrType := order.Uint32(buf[0:]); drrType != sendStreamDRRBegin {
+		return nil, fmt.Errorf("zfs send stream does not start with a BEGIN record (record type %d)", drrType)
+	}
+	name := buf[sendStreamBeginToNameOffset:sendStreamBeginLen]
+	if i := bytes.IndexByte(name, 0); i >= 0 {
+		name = name[:i]
+	}
+	return &SendStreamHeader{
+		ToName:   string(name),
+		ToGUID:   order.Uint64(buf[sendStreamBeginToGUIDOffset:]),
+		FromGUID: order.Uint64(buf[sendStreamBeginFromGUIDOffset:]),
+	}, nil
+}
@@ -0,0 +1,53 @@
+package zfs
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSendStreamHeader(order binary.ByteOrder, toName string, toGUID, fromGUID uint64) []byte {
+	buf := make([]byte, sendStreamBeginLen+100) // followed by the next record
+	order.PutUint32(buf[0:], sendStreamDRRBegin)
+	order.PutUint64(buf[sendStreamBeginMagicOffset:], sendStreamBackupMagic)
+	order.PutUint64(buf[sendStreamBeginToGUIDOffset:], toGUID)
+	order.PutUint64(buf[sendStreamBeginFromGUIDOffset:], fromGUID)
+	copy(buf[sendStreamBeginToNameOffset:], toName)
+	return buf
+}
+
+func TestParseSendStreamHeader(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		h, err := parseSendStreamHeader(testSendStreamHeader(order, "pool/fs@snap", 23, 42))
+		require.NoError(t, err, "%s", order)
+		assert.Equal(t, &SendStreamHeader{ToName: "pool/fs@snap", ToGUID: 23, FromGUID: 42}, h)
+		assert.True(t, h.IsIncremental())
+		snap, err := h.ToSnapshotName()
+		require.NoError(t, err)
+		assert.Equal(t, "snap", snap)
+	}
+
+	h, err := parseSendStreamHeader(testSendStreamHeader(binary.LittleEndian, "pool/fs@snap", 23, 0))
+	require.NoError(t, err)
+	assert.False(t, h.IsIncremental())
+
+	invalidMagic := testSendStreamHeader(binary.LittleEndian, "pool/fs@snap", 23, 0)
+	invalidMagic[sendStreamBeginMagicOffset] = 0
+	_, err = parseSendStreamHeader(invalidMagic)
+	assert.Error(t, err)
+
+	notBegin := testSendStreamHeader(binary.LittleEndian, "pool/fs@snap", 23, 0)
+	notBegin[0] = 1
+	_, err = parseSendStreamHeader(notBegin)
+	assert.Error(t, err)
+
+	_, err = parseSendStreamHeader(testSendStreamHeader(binary.LittleEndian, "pool/fs", 23, 0)[:100])
+	assert.Error(t, err)
+
+	h, err = parseSendStreamHeader(testSendStreamHeader(binary.LittleEndian, "pool/fs", 23, 0))
+	require.NoError(t, err)
+	_, err = h.ToSnapshotName()
+	assert.Error(t, err)
+}
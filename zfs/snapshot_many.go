@@ -0,0 +1,257 @@
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/zrepl/zrepl/zfs/zfscmd"
+)
+
+type SnapshotSpec struct {
+	FS     *DatasetPath
+	Name   string
+	ErrOut *error
+}
+
+func (s *SnapshotSpec) String() string {
+	return fmt.Sprintf("%s@%s", s.FS.ToString(), s.Name)
+}
+
+type snapshotter interface {
+	// Snapshot invokes `zfs snapshot`, with `-r` if recursive is true, for all snapshots at once.
+	Snapshot(ctx context.Context, recursive bool, snapshots []string) error
+	// Descendants returns all filesystems and volumes below fs, excluding fs itself.
+	Descendants(ctx context.Context, fs string) ([]string, error)
+}
+
+type snapshotterImpl struct{}
+
+var snapshotterSingleton snapshotter = snapshotterImpl{}
+
+func (snapshotterImpl) Snapshot(ctx context.Context, recursive bool, snapshots []string) error {
+	args := []string{"snapshot"}
+	if recursive {
+		args = append(args, "-r")
+	}
+	args = append(args, snapshots...)
+	// labeled like ZFSSnapshot, by the filesystem of the first (or only) snapshot
+	fs := strings.SplitN(snapshots[0], "@", 2)[0]
+	defer prometheus.NewTimer(prom.ZFSSnapshotDuration.WithLabelValues(fs)).ObserveDuration()
+	cmd := zfscmd.CommandContext(ctx, ZFS_BINARY, args...)
+	stdio, err := cmd.CombinedOutput()
+	if err != nil {
+		return &ZFSError{
+			Stderr:  stdio,
+			WaitErr: err,
+		}
+	}
+	return nil
+}
+
+func (snapshotterImpl) Descendants(ctx context.Context, fs string) ([]string, error) {
+	lines, err := ZFSList(ctx, []string{"name"}, "-r", "-t", "filesystem,volume", fs)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]string, 0, len(lines))
+	for _, l := range lines {
+		if l[0] != fs {
+			res = append(res, l[0])
+		}
+	}
+	return res, nil
+}
+
+// ZFSSnapshotMany creates the snapshots described by specs, using as few `zfs snapshot` invocations as possible.
+//
+// Specs with the same snapshot name in the same pool are created by a single `zfs snapshot` invocation,
+// which ZFS executes atomically.
+// If such a group consists of complete subtrees, `zfs snapshot -r` is used for the subtree roots.
+// If a grouped invocation fails, the group's snapshots are created individually
+// so that the error of each spec is reported precisely.
+//
+// The result of each spec is stored in its ErrOut field.
+func ZFSSnapshotMany(ctx context.Context, specs []*SnapshotSpec) {
+	doSnapshotMany(ctx, specs, snapshotterSingleton)
+}
+
+func doSnapshotMany(ctx context.Context, specs []*SnapshotSpec, s snapshotter) {
+	var validated []*SnapshotSpec
+	for _, spec := range specs {
+		if spec.FS == nil || spec.FS.Empty() {
+			*spec.ErrOut = fmt.Errorf("FS must not be empty")
+		} else if err := EntityNamecheck(spec.String(), EntityTypeSnapshot); err != nil {
+			*spec.ErrOut = err
+		} else {
+			validated = append(validated, spec)
+		}
+	}
+
+	for _, group := range buildSnapshotGroups(validated) {
+		if len(group) == 1 {
+			*group[0].ErrOut = s.Snapshot(ctx, false, []string{group[0].String()})
+			continue
+		}
+		recursive, args := snapshotGroupArgs(ctx, group, s)
+		err := s.Snapshot(ctx, recursive, args)
+		if err == nil {
+			for _, spec := range group {
+				*spec.ErrOut = nil
+			}
+			continue
+		}
+		debug("snapshot: grouped invocation failed, falling back to individual invocations: %s", err)
+		for _, spec := range group {
+			*spec.ErrOut = s.Snapshot(ctx, false, []string{spec.String()})
+		}
+	}
+}
+
+// groups specs by (snapshot name, pool), each group sorted by filesystem
+func buildSnapshotGroups(specs []*SnapshotSpec) [][]*SnapshotSpec {
+	type key struct{ name, pool string }
+	groups := make(map[key][]*SnapshotSpec)
+	var keys []key
+	for _, spec := range specs {
+		k := key{spec.Name, spec.FS.Pool()} // validated to be non-empty
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], spec)
+	}
+	res := make([][]*SnapshotSpec, 0, len(keys))
+	for _, k := range keys {
+		g := groups[k]
+		sort.SliceStable(g, func(i, j int) bool {
+			return g[i].FS.ToString() < g[j].FS.ToString()
+		})
+		res = append(res, g)
+	}
+	return res
+}
+
+// snapshotGroupArgs returns the arguments for a `zfs snapshot` invocation that creates the snapshots of group.
+// If the group consists of complete subtrees (see SnapshotSubtrees), the arguments are the subtree roots and recursive is true.
+// Otherwise, the arguments are all snapshots of the group.
+func snapshotGroupArgs(ctx context.Context, group []*SnapshotSpec, s snapshotter) (recursive bool, args []string) {
+	all := make([]string, len(group))
+	fss := make([]*DatasetPath, len(group))
+	bySnapshot := make(map[string]*SnapshotSpec, len(group))
+	for i := range group {
+		all[i] = group[i].String()
+		fss[i] = group[i].FS
+		bySnapshot[group[i].FS.ToString()] = group[i]
+	}
+
+	var roots []*DatasetPath
+	for _, fs := range fss {
+		isRoot := true
+		for _, r := range roots {
+			if fs.HasPrefix(r) {
+				isRoot = false
+				break
+			}
+		}
+		if isRoot {
+			roots = append(roots, fs) // group is sorted => ancestors come first
+		}
+	}
+	if len(roots) == len(group) {
+		return false, all // no subtrees
+	}
+	// the subtrees below roots, listed right before the snapshot
+	var listed []*DatasetPath
+	for _, r := range roots {
+		descendants, err := s.Descendants(ctx, r.ToString())
+		if err != nil {
+			debug("snapshot: cannot list descendants of %q, not using recursive snapshot: %s", r.ToString(), err)
+			return false, all
+		}
+		listed = append(listed, r)
+		for _, d := range descendants {
+			p, err := NewDatasetPath(d)
+			if err != nil {
+				debug("snapshot: invalid descendant %q of %q, not using recursive snapshot: %s", d, r.ToString(), err)
+				return false, all
+			}
+			listed = append(listed, p)
+		}
+	}
+	subtrees := SnapshotSubtrees(fss, listed, nil)
+	for _, st := range subtrees {
+		if !st.Complete {
+			return false, all // -r would snapshot a dataset that is not part of the group
+		}
+	}
+	args = make([]string, len(subtrees))
+	for i, st := range subtrees {
+		args[i] = bySnapshot[st.Datasets[0].ToString()].String()
+	}
+	return true, args
+}
+
+// A SnapshotSubtree is a set of datasets that are snapshotted together, see SnapshotSubtrees.
+type SnapshotSubtree struct {
+	// sorted by name, Datasets[0] is the topmost dataset
+	Datasets []*DatasetPath
+	// `zfs snapshot -r` of Datasets[0] snapshots exactly Datasets
+	Complete bool
+}
+
+// SnapshotSubtrees partitions fss into subtrees that can be snapshotted atomically
+// using `zfs snapshot -r` on their topmost dataset, sorted by the name of the topmost dataset.
+//
+// `zfs snapshot -r` does not take a filter, thus a subtree only contains more than one dataset
+// if all of its datasets are in fss. Datasets of fss with descendants that are not in fss form their
+// own incomplete subtree, e.g., for pool/a, pool/a/b and pool/a/c/d in fss, and pool/a/c not in fss,
+// the subtrees are [pool/a] (incomplete), [pool/a/b] and [pool/a/c/d].
+//
+// If keys is not nil, a dataset is only in the same subtree as its parent if keys has the same value for both,
+// otherwise the parent is treated like a dataset with a descendant that is not in fss.
+//
+// all must contain all descendants of the datasets in fss, fss must be a subset of all.
+func SnapshotSubtrees(fss, all []*DatasetPath, keys map[string]string) []SnapshotSubtree {
+	inFSS := make(map[string]bool, len(fss))
+	for _, p := range fss {
+		inFSS[p.ToString()] = true
+	}
+
+	// complete[name] is true if the dataset and all of its descendants are in fss
+	complete := make(map[string]bool, len(all))
+	for _, ds := range all {
+		complete[ds.ToString()] = inFSS[ds.ToString()]
+	}
+	byDepth := make([]*DatasetPath, len(all))
+	copy(byDepth, all)
+	sort.SliceStable(byDepth, func(i, j int) bool { return byDepth[i].Length() > byDepth[j].Length() })
+	for _, ds := range byDepth {
+		parent := ds.Parent().ToString()
+		if !complete[ds.ToString()] || keys[ds.ToString()] != keys[parent] {
+			complete[parent] = false
+		}
+	}
+
+	// the subtree of a dataset is that of its topmost complete ancestor
+	subtrees := make(map[string][]*DatasetPath, len(fss))
+	for _, p := range fss {
+		root := p
+		if complete[p.ToString()] {
+			for parent := p.Parent(); complete[parent.ToString()]; parent = parent.Parent() {
+				root = parent
+			}
+		}
+		subtrees[root.ToString()] = append(subtrees[root.ToString()], p)
+	}
+
+	res := make([]SnapshotSubtree, 0, len(subtrees))
+	for root, ds := range subtrees {
+		sort.Slice(ds, func(i, j int) bool { return ds[i].ToString() < ds[j].ToString() })
+		res = append(res, SnapshotSubtree{Datasets: ds, Complete: complete[root]})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Datasets[0].ToString() < res[j].Datasets[0].ToString() })
+	return res
+}
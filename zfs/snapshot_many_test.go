@@ -0,0 +1,182 @@
+package zfs
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockSnapshotter struct {
+	calls       []string
+	tree        map[string][]string // fs => descendants
+	failBatches bool
+	failSnaps   map[string]bool
+}
+
+func (m *mockSnapshotter) Snapshot(ctx context.Context, recursive bool, snapshots []string) error {
+	call := strings.Join(snapshots, " ")
+	if recursive {
+		call = "-r " + call
+	}
+	m.calls = append(m.calls, call)
+	if m.failBatches && len(snapshots) > 1 {
+		return fmt.Errorf("batch failed")
+	}
+	for _, s := range snapshots {
+		if m.failSnaps[s] {
+			return fmt.Errorf("cannot snapshot %s", s)
+		}
+	}
+	return nil
+}
+
+func (m *mockSnapshotter) Descendants(ctx context.Context, fs string) ([]string, error) {
+	return m.tree[fs], nil
+}
+
+func makeSnapshotSpecs(snaps ...string) ([]*SnapshotSpec, []error) {
+	errs := make([]error, len(snaps))
+	specs := make([]*SnapshotSpec, len(snaps))
+	for i, s := range snaps {
+		comps := strings.SplitN(s, "@", 2)
+		specs[i] = &SnapshotSpec{FS: toDatasetPath(comps[0]), Name: comps[1], ErrOut: &errs[i]}
+	}
+	return specs, errs
+}
+
+func TestSnapshotMany(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("mixed-grouped-and-ungrouped", func(t *testing.T) {
+		m := &mockSnapshotter{
+			tree: map[string][]string{
+				"pool1/a": {"pool1/a/b", "pool1/a/b/c"},
+			},
+		}
+		specs, errs := makeSnapshotSpecs(
+			"pool1/a/b@snap1",
+			"pool1/a@snap1",
+			"pool1/a/b/c@snap1",
+			"pool2/x@snap1",
+			"pool2/y@snap1",
+			"pool3/z@snap1",
+			"pool1/a@snap2",
+		)
+		doSnapshotMany(ctx, specs, m)
+		for i := range errs {
+			assert.NoError(t, errs[i])
+		}
+		assert.Equal(t, []string{
+			"-r pool1/a@snap1",
+			"pool2/x@snap1 pool2/y@snap1",
+			"pool3/z@snap1",
+			"pool1/a@snap2",
+		}, m.calls)
+	})
+
+	t.Run("incomplete-subtree-is-not-recursive", func(t *testing.T) {
+		m := &mockSnapshotter{
+			tree: map[string][]string{
+				"pool/a": {"pool/a/b", "pool/a/excluded"},
+			},
+		}
+		specs, errs := makeSnapshotSpecs("pool/a@s", "pool/a/b@s")
+		doSnapshotMany(ctx, specs, m)
+		assert.NoError(t, errs[0])
+		assert.NoError(t, errs[1])
+		assert.Equal(t, []string{"pool/a@s pool/a/b@s"}, m.calls)
+	})
+
+	t.Run("failed-batch-falls-back-to-individual", func(t *testing.T) {
+		m := &mockSnapshotter{
+			failBatches: true,
+			failSnaps:   map[string]bool{"pool/b@s": true},
+		}
+		specs, errs := makeSnapshotSpecs("pool/a@s", "pool/b@s", "pool/c@s")
+		doSnapshotMany(ctx, specs, m)
+		assert.NoError(t, errs[0])
+		assert.Error(t, errs[1])
+		assert.NoError(t, errs[2])
+		assert.Equal(t, []string{
+			"pool/a@s pool/b@s pool/c@s",
+			"pool/a@s",
+			"pool/b@s",
+			"pool/c@s",
+		}, m.calls)
+	})
+
+	t.Run("invalid-specs", func(t *testing.T) {
+		m := &mockSnapshotter{}
+		specs, errs := makeSnapshotSpecs("pool/a@in valid#", "pool/b@s")
+		specs = append(specs, &SnapshotSpec{FS: toDatasetPath(""), Name: "s", ErrOut: new(error)})
+		doSnapshotMany(ctx, specs, m)
+		assert.Error(t, errs[0])
+		assert.NoError(t, errs[1])
+		assert.Error(t, *specs[2].ErrOut)
+		assert.Equal(t, []string{"pool/b@s"}, m.calls)
+	})
+}
+
+func TestSnapshotSubtrees(t *testing.T) {
+	paths := func(ps ...string) []*DatasetPath {
+		res := make([]*DatasetPath, len(ps))
+		for i, p := range ps {
+			res[i] = toDatasetPath(p)
+		}
+		return res
+	}
+	type subtree struct {
+		datasets []string
+		complete bool
+	}
+	all := paths("pool", "pool/a", "pool/a/b", "pool/a/c", "pool/a/c/d", "pool/e", "other", "other/f")
+
+	tcs := []struct {
+		fss    []string
+		keys   map[string]string
+		expect []subtree
+	}{
+		{
+			[]string{"pool", "pool/a", "pool/a/b", "pool/a/c", "pool/a/c/d", "pool/e", "other/f"},
+			nil,
+			[]subtree{{[]string{"other/f"}, true}, {[]string{"pool", "pool/a", "pool/a/b", "pool/a/c", "pool/a/c/d", "pool/e"}, true}},
+		},
+		{
+			// different keys for pool/a and pool/a/c, thus pool/a cannot be snapshotted recursively
+			[]string{"pool/a", "pool/a/b", "pool/a/c", "pool/a/c/d"},
+			map[string]string{"pool/a": "0", "pool/a/b": "0", "pool/a/c": "1", "pool/a/c/d": "1"},
+			[]subtree{{[]string{"pool/a"}, false}, {[]string{"pool/a/b"}, true}, {[]string{"pool/a/c", "pool/a/c/d"}, true}},
+		},
+		{
+			[]string{"pool/a", "pool/a/b", "pool/a/c", "pool/a/c/d", "pool/e"},
+			nil,
+			[]subtree{{[]string{"pool/a", "pool/a/b", "pool/a/c", "pool/a/c/d"}, true}, {[]string{"pool/e"}, true}},
+		},
+		{
+			// pool/a/c is not in fss, thus neither pool/a nor pool/a/c can be snapshotted recursively
+			[]string{"pool/a", "pool/a/b", "pool/a/c/d"},
+			nil,
+			[]subtree{{[]string{"pool/a"}, false}, {[]string{"pool/a/b"}, true}, {[]string{"pool/a/c/d"}, true}},
+		},
+		{
+			[]string{"pool", "pool/a/c", "pool/a/c/d"},
+			nil,
+			[]subtree{{[]string{"pool"}, false}, {[]string{"pool/a/c", "pool/a/c/d"}, true}},
+		},
+		{nil, nil, []subtree{}},
+	}
+	for _, tc := range tcs {
+		subtrees := SnapshotSubtrees(paths(tc.fss...), all, tc.keys)
+		res := make([]subtree, len(subtrees))
+		for i, st := range subtrees {
+			res[i].complete = st.Complete
+			for _, p := range st.Datasets {
+				res[i].datasets = append(res[i].datasets, p.ToString())
+			}
+		}
+		assert.Equal(t, tc.expect, res, "fss %v", tc.fss)
+	}
+}
@@ -0,0 +1,103 @@
+package zfs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// StreamCopierTeeError is returned by StreamCopier if writing to the tee target failed.
+// Errors of the primary stream are passed through unmodified,
+// so callers can distinguish the two using a type assertion.
+type StreamCopierTeeError struct {
+	Err error
+}
+
+func (e *StreamCopierTeeError) Error() string {
+	return fmt.Sprintf("stream tee target: %s", e.Err)
+}
+
+func (e *StreamCopierTeeError) Cause() error { return e.Err }
+
+// StreamCopier wraps a send stream and copies every byte read from it to an additional writer.
+//
+// Writes to the tee target are buffered so that small reads on the primary path
+// do not translate into small writes to the tee target.
+// A failure to write to the tee target fails the stream with a *StreamCopierTeeError:
+// a partial copy is useless for verification, and the consumer of the primary
+// stream must not be led to believe that the copy was complete.
+type StreamCopier struct {
+	stream io.ReadCloser
+
+	mtx    sync.Mutex
+	tee    io.WriteCloser
+	buf    *bufio.Writer
+	teeErr error
+	closed bool
+}
+
+var _ io.ReadCloser = (*StreamCopier)(nil)
+
+const streamCopierBufSize = 1 << 20
+
+// NewStreamCopier wraps stream.
+// tee is closed by StreamCopier.Close, after the buffered data has been flushed.
+func NewStreamCopier(stream io.ReadCloser, tee io.WriteCloser) *StreamCopier {
+	return &StreamCopier{
+		stream: stream,
+		tee:    tee,
+		buf:    bufio.NewWriterSize(tee, streamCopierBufSize),
+	}
+}
+
+func (c *StreamCopier) Read(p []byte) (n int, err error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.teeErr != nil {
+		return 0, c.teeErr
+	}
+
+	n, err = c.stream.Read(p)
+	if n > 0 {
+		if _, werr := c.buf.Write(p[:n]); werr != nil {
+			c.teeErr = &StreamCopierTeeError{werr}
+			return n, c.teeErr
+		}
+	}
+	if err == io.EOF {
+		if ferr := c.buf.Flush(); ferr != nil {
+			c.teeErr = &StreamCopierTeeError{ferr}
+			return n, c.teeErr
+		}
+	}
+	return n, err
+}
+
+// Close closes the primary stream and the tee target.
+// An error closing the primary stream takes precedence over errors of the tee target.
+func (c *StreamCopier) Close() error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+
+	streamErr := c.stream.Close()
+
+	teeErr := c.teeErr
+	if teeErr == nil {
+		if err := c.buf.Flush(); err != nil {
+			teeErr = &StreamCopierTeeError{err}
+		}
+	}
+	if err := c.tee.Close(); err != nil && teeErr == nil {
+		teeErr = &StreamCopierTeeError{err}
+	}
+
+	if streamErr != nil {
+		return streamErr
+	}
+	return teeErr
+}
@@ -0,0 +1,81 @@
+package zfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type streamCopierTestWriter struct {
+	bytes.Buffer
+	writeErr, closeErr error
+	closed             bool
+}
+
+func (w *streamCopierTestWriter) Write(p []byte) (int, error) {
+	if w.writeErr != nil {
+		return 0, w.writeErr
+	}
+	return w.Buffer.Write(p)
+}
+
+func (w *streamCopierTestWriter) Close() error {
+	w.closed = true
+	return w.closeErr
+}
+
+func TestStreamCopier(t *testing.T) {
+	data := bytes.Repeat([]byte("zrepl"), 3*streamCopierBufSize/5)
+
+	t.Run("copies", func(t *testing.T) {
+		tee := &streamCopierTestWriter{}
+		c := NewStreamCopier(ioutil.NopCloser(bytes.NewReader(data)), tee)
+		read, err := ioutil.ReadAll(c)
+		require.NoError(t, err)
+		require.NoError(t, c.Close())
+		assert.Equal(t, data, read)
+		assert.Equal(t, data, tee.Bytes())
+		assert.True(t, tee.closed)
+	})
+
+	t.Run("tee-write-error", func(t *testing.T) {
+		tee := &streamCopierTestWriter{writeErr: errors.New("disk full")}
+		c := NewStreamCopier(ioutil.NopCloser(bytes.NewReader(data)), tee)
+		_, err := ioutil.ReadAll(c)
+		require.Error(t, err)
+		teeErr, ok := err.(*StreamCopierTeeError)
+		require.True(t, ok, "%T", err)
+		assert.Equal(t, tee.writeErr, teeErr.Err)
+		assert.Equal(t, teeErr, c.Close())
+		assert.True(t, tee.closed)
+	})
+
+	t.Run("tee-close-error", func(t *testing.T) {
+		tee := &streamCopierTestWriter{closeErr: errors.New("close failed")}
+		c := NewStreamCopier(ioutil.NopCloser(bytes.NewReader(data)), tee)
+		_, err := ioutil.ReadAll(c)
+		require.NoError(t, err)
+		err = c.Close()
+		_, ok := err.(*StreamCopierTeeError)
+		assert.True(t, ok, "%T", err)
+	})
+
+	t.Run("primary-error-passed-through", func(t *testing.T) {
+		primaryErr := errors.New("zfs send failed")
+		r := io.MultiReader(bytes.NewReader(data[:100]), &errReader{primaryErr})
+		tee := &streamCopierTestWriter{}
+		c := NewStreamCopier(ioutil.NopCloser(r), tee)
+		_, err := ioutil.ReadAll(c)
+		assert.Equal(t, primaryErr, err)
+		assert.NoError(t, c.Close())
+	})
+}
+
+type errReader struct{ err error }
+
+func (r *errReader) Read([]byte) (int, error) { return 0, r.err }
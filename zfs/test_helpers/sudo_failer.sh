@@ -0,0 +1,4 @@
+#!/bin/sh
+# mocks a privilege wrapper like `sudo -n` that fails before executing the command
+echo "sudo: a password is required" 1>&2
+exit 1
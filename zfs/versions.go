@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -89,7 +90,9 @@ type FilesystemVersion struct {
 	Guid uint64
 
 	// The TXG in which the snapshot was created. For bookmarks,
-	// this is the GUID of the snapshot it was initially tied to.
+	// this is the createtxg of the snapshot the bookmark was created from
+	// (ZFS copies it into the bookmark), i.e., a bookmark and the snapshot
+	// it was created from have the same CreateTXG (and Guid).
 	CreateTXG uint64
 
 	// The time the dataset was created
@@ -141,6 +144,18 @@ func (v FilesystemVersion) ToSendArgVersion() ZFSSendArgVersion {
 	}
 }
 
+// FilesystemVersionCreationError is returned by ParseFilesystemVersion
+// if the creation property of a version is not a valid timestamp.
+type FilesystemVersionCreationError struct {
+	Version  string
+	Creation string
+	Err      error
+}
+
+func (e *FilesystemVersionCreationError) Error() string {
+	return fmt.Sprintf("cannot parse creation date %q of %q: %s", e.Creation, e.Version, e.Err)
+}
+
 type ParseFilesystemVersionArgs struct {
 	fullname                            string
 	guid, createtxg, creation, userrefs string
@@ -164,11 +179,13 @@ func ParseFilesystemVersion(args ParseFilesystemVersionArgs) (v FilesystemVersio
 
 	creationUnix, err := strconv.ParseInt(args.creation, 10, 64)
 	if err != nil {
-		err = errors.Wrapf(err, "cannot parse creation date %q", args.creation)
-		return v, err
-	} else {
-		v.Creation = time.Unix(creationUnix, 0)
+		return v, &FilesystemVersionCreationError{args.fullname, args.creation, err}
+	}
+	if creationUnix <= 0 {
+		// a zero creation time would make the version look ancient
+		return v, &FilesystemVersionCreationError{args.fullname, args.creation, errors.New("not a positive unix timestamp")}
 	}
+	v.Creation = time.Unix(creationUnix, 0)
 
 	switch v.Type {
 	case Bookmark:
@@ -211,8 +228,48 @@ func (o *ListFilesystemVersionsOptions) matches(v FilesystemVersion) bool {
 	return (len(o.Types) == 0 || o.Types[v.Type]) && strings.HasPrefix(v.Name, o.ShortnamePrefix)
 }
 
-// returned versions are sorted by createtxg FIXME drop sort by createtxg requirement
+// SortFilesystemVersionsByCreateTXG sorts vs by ascending CreateTXG.
+// Since a bookmark has the CreateTXG of the snapshot it was created from,
+// ties are broken by sorting snapshots before bookmarks, then by name.
+func SortFilesystemVersionsByCreateTXG(vs []FilesystemVersion) {
+	sort.SliceStable(vs, func(i, j int) bool {
+		if vs[i].CreateTXG != vs[j].CreateTXG {
+			return vs[i].CreateTXG < vs[j].CreateTXG
+		}
+		if vs[i].Type != vs[j].Type {
+			return vs[i].Type == Snapshot
+		}
+		return vs[i].Name < vs[j].Name
+	})
+}
+
+// FilterFilesystemVersions returns the versions in vs that match options, preserving their order.
+func FilterFilesystemVersions(vs []FilesystemVersion, options ListFilesystemVersionsOptions) []FilesystemVersion {
+	res := make([]FilesystemVersion, 0, len(vs))
+	for _, v := range vs {
+		if options.matches(v) {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// BookmarksWithPrefix returns the bookmarks in vs whose name starts with prefix, preserving their order.
+func BookmarksWithPrefix(vs []FilesystemVersion, prefix string) []FilesystemVersion {
+	return FilterFilesystemVersions(vs, ListFilesystemVersionsOptions{Types: Bookmarks, ShortnamePrefix: prefix})
+}
+
+// ZFSListBookmarks lists the bookmarks of fs whose name starts with prefix, sorted by createtxg.
+func ZFSListBookmarks(ctx context.Context, fs *DatasetPath, prefix string) ([]FilesystemVersion, error) {
+	return ZFSListFilesystemVersions(ctx, fs, ListFilesystemVersionsOptions{Types: Bookmarks, ShortnamePrefix: prefix})
+}
+
+// returned versions are sorted by createtxg (see SortFilesystemVersionsByCreateTXG)
+// FIXME drop sort by createtxg requirement
 func ZFSListFilesystemVersions(ctx context.Context, fs *DatasetPath, options ListFilesystemVersionsOptions) (res []FilesystemVersion, err error) {
+	if err := validateDatasetPath("zfs list", fs); err != nil {
+		return nil, err
+	}
 	listResults := make(chan ZFSListResult)
 
 	promTimer := prometheus.NewTimer(prom.ZFSListFilesystemVersionDuration.WithLabelValues(fs.ToString()))
@@ -227,6 +284,13 @@ func ZFSListFilesystemVersions(ctx context.Context, fs *DatasetPath, options Lis
 		"-t", options.typesFlagArgs(),
 		"-s", "createtxg", fs.ToString())
 
+	return filesystemVersionsFromListResults(ctx, listResults, options)
+}
+
+// Versions with an unparseable creation date are logged and skipped
+// so that a single odd version does not fail the entire filesystem.
+// All other parsing errors are returned.
+func filesystemVersionsFromListResults(ctx context.Context, listResults <-chan ZFSListResult, options ListFilesystemVersionsOptions) (res []FilesystemVersion, err error) {
 	res = make([]FilesystemVersion, 0)
 	for listResult := range listResults {
 		if listResult.Err != nil {
@@ -242,7 +306,10 @@ func ZFSListFilesystemVersions(ctx context.Context, fs *DatasetPath, options Lis
 			userrefs:  line[4],
 		}
 		v, err := ParseFilesystemVersion(args)
-		if err != nil {
+		if creationErr, ok := err.(*FilesystemVersionCreationError); ok {
+			getLogger(ctx).WithError(creationErr).Error("skipping filesystem version with unparseable creation date")
+			continue
+		} else if err != nil {
 			return nil, err
 		}
 
@@ -251,6 +318,8 @@ func ZFSListFilesystemVersions(ctx context.Context, fs *DatasetPath, options Lis
 		}
 
 	}
+	// zfs list -s createtxg does not define the order of a snapshot and its bookmarks
+	SortFilesystemVersionsByCreateTXG(res)
 	return
 }
 
@@ -15,7 +15,9 @@ import (
 )
 
 func ZFSDestroyFilesystemVersion(ctx context.Context, filesystem *DatasetPath, version *FilesystemVersion) (err error) {
-
+	if err := validateDatasetPath("zfs destroy", filesystem); err != nil {
+		return err
+	}
 	datasetPath := version.ToAbsPath(filesystem)
 
 	// Sanity check...
@@ -42,6 +44,46 @@ func ZFSDestroyFilesystemVersions(ctx context.Context, reqs []*DestroySnapOp) {
 	doDestroy(ctx, reqs, destroyerSingleton)
 }
 
+// SnapshotOrder maps a filesystem name to the names of all of its snapshots, ordered by createtxg.
+type SnapshotOrder map[string][]string
+
+// ZFSDestroyFilesystemVersionsRanged is like ZFSDestroyFilesystemVersions, but additionally uses
+// the range syntax `fs@first%last` for runs of snapshots that are consecutive in order.
+// This shortens the `zfs destroy` arguments considerably when destroying many snapshots, e.g. when pruning.
+//
+// order must have been listed shortly before the call (see ZFSListSnapshotOrder), and must contain all snapshots
+// of the filesystems in order, not only those of reqs: a range destroys all snapshots between its first and last snapshot, thus a snapshot
+// missing from order could be destroyed although it is not in reqs.
+// Snapshots that are created concurrently are safe because they are newer than any range.
+// Filesystems that are not in order are destroyed as with ZFSDestroyFilesystemVersions.
+func ZFSDestroyFilesystemVersionsRanged(ctx context.Context, reqs []*DestroySnapOp, order SnapshotOrder) {
+	doDestroyWithOrder(ctx, reqs, order, destroyerSingleton)
+}
+
+// ZFSListSnapshotOrder lists the names of all snapshots of fs, ordered by createtxg,
+// for use as the order of fs in ZFSDestroyFilesystemVersionsRanged.
+//
+// Unlike ZFSListFilesystemVersions, it only lists the snapshot names and thus never
+// skips a snapshot, e.g., because of an unparseable creation date.
+func ZFSListSnapshotOrder(ctx context.Context, fs *DatasetPath) ([]string, error) {
+	if err := validateDatasetPath("zfs list", fs); err != nil {
+		return nil, err
+	}
+	lines, err := ZFSList(ctx, []string{"name"}, "-r", "-d", "1", "-t", "snapshot", "-s", "createtxg", fs.ToString())
+	if err != nil {
+		return nil, err
+	}
+	prefix := fs.ToString() + "@"
+	names := make([]string, len(lines))
+	for i, l := range lines {
+		if !strings.HasPrefix(l[0], prefix) {
+			return nil, fmt.Errorf("unexpected snapshot %q in listing of filesystem %q", l[0], fs.ToString())
+		}
+		names[i] = strings.TrimPrefix(l[0], prefix)
+	}
+	return names, nil
+}
+
 func setDestroySnapOpErr(b []*DestroySnapOp, err error) {
 	for _, r := range b {
 		*r.ErrOut = err
@@ -54,6 +96,10 @@ type destroyer interface {
 }
 
 func doDestroy(ctx context.Context, reqs []*DestroySnapOp, e destroyer) {
+	doDestroyWithOrder(ctx, reqs, nil, e)
+}
+
+func doDestroyWithOrder(ctx context.Context, reqs []*DestroySnapOp, order SnapshotOrder, e destroyer) {
 
 	var validated []*DestroySnapOp
 	for _, req := range reqs {
@@ -80,7 +126,7 @@ func doDestroy(ctx context.Context, reqs []*DestroySnapOp, e destroyer) {
 	if !commaSupported {
 		doDestroySeq(ctx, reqs, e)
 	} else {
-		doDestroyBatched(ctx, reqs, e)
+		doDestroyBatched(ctx, reqs, order, e)
 	}
 }
 
@@ -90,13 +136,79 @@ func doDestroySeq(ctx context.Context, reqs []*DestroySnapOp, e destroyer) {
 	}
 }
 
-func doDestroyBatched(ctx context.Context, reqs []*DestroySnapOp, d destroyer) {
+// Upper bound for the length of the argument of a batched `zfs destroy`, excluding range syntax.
+// Batches that exceed it are split up before invoking zfs, batches that still fail with E2BIG are halved.
+var destroyBatchMaxArgLen = envconst.Int("ZREPL_ZFS_DESTROY_BATCH_MAX_ARG_LEN", 8192)
+
+func doDestroyBatched(ctx context.Context, reqs []*DestroySnapOp, order SnapshotOrder, d destroyer) {
 	perFS := buildBatches(reqs)
 	for _, fsbatch := range perFS {
-		doDestroyBatchedRec(ctx, fsbatch, d)
+		b := &destroyBatch{d: d, order: order[fsbatch[0].Filesystem]}
+		for _, chunk := range chunkBatch(fsbatch, destroyBatchMaxArgLen) {
+			b.doDestroyBatchedRec(ctx, chunk)
+		}
 	}
 }
 
+// chunkBatch splits fsbatch into consecutive chunks whose comma-list argument is at most maxArgLen long.
+// A single snapshot whose argument is longer forms its own chunk.
+func chunkBatch(fsbatch []*DestroySnapOp, maxArgLen int) [][]*DestroySnapOp {
+	var chunks [][]*DestroySnapOp
+	begin, argLen := 0, 0
+	for i, r := range fsbatch {
+		l := len(r.Name) + 1 // the separator, i.e., '@' or ','
+		if i > begin && argLen+l > maxArgLen {
+			chunks = append(chunks, fsbatch[begin:i])
+			begin, argLen = i, 0
+		}
+		if i == begin {
+			argLen = len(r.Filesystem)
+		}
+		argLen += l
+	}
+	if begin < len(fsbatch) {
+		chunks = append(chunks, fsbatch[begin:])
+	}
+	return chunks
+}
+
+// destroyBatchArgNames returns the comma-list elements that destroy exactly the snapshots names,
+// using the range syntax `first%last` for runs of at least three snapshots that are consecutive in order.
+// If order is nil or does not contain all of names, names are returned as is.
+func destroyBatchArgNames(names []string, order []string) []string {
+	if order == nil {
+		return names
+	}
+	pos := make(map[string]int, len(order))
+	for i, n := range order {
+		pos[n] = i
+	}
+	sorted := make([]string, len(names))
+	copy(sorted, names)
+	for _, n := range sorted {
+		if _, ok := pos[n]; !ok {
+			return names
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return pos[sorted[i]] < pos[sorted[j]] })
+
+	const minRangeLen = 3 // `a%b` is not shorter than `a,b`
+	res := make([]string, 0, len(sorted))
+	for begin := 0; begin < len(sorted); {
+		end := begin + 1 // exclusive
+		for end < len(sorted) && pos[sorted[end]] == pos[sorted[end-1]]+1 {
+			end++
+		}
+		if end-begin >= minRangeLen {
+			res = append(res, fmt.Sprintf("%s%%%s", sorted[begin], sorted[end-1]))
+		} else {
+			res = append(res, sorted[begin:end]...)
+		}
+		begin = end
+	}
+	return res
+}
+
 func buildBatches(reqs []*DestroySnapOp) [][]*DestroySnapOp {
 	if len(reqs) == 0 {
 		return nil
@@ -125,8 +237,14 @@ func buildBatches(reqs []*DestroySnapOp) [][]*DestroySnapOp {
 	return perFS
 }
 
+// destroyBatch destroys the snapshots of a single filesystem
+type destroyBatch struct {
+	d     destroyer
+	order []string // nil unless ranges may be used, see ZFSDestroyFilesystemVersionsRanged
+}
+
 // batch must be on same Filesystem, panics otherwise
-func tryBatch(ctx context.Context, batch []*DestroySnapOp, d destroyer) error {
+func (b *destroyBatch) tryBatch(ctx context.Context, batch []*DestroySnapOp) error {
 	if len(batch) == 0 {
 		return nil
 	}
@@ -139,18 +257,18 @@ func tryBatch(ctx context.Context, batch []*DestroySnapOp, d destroyer) error {
 			panic("inconsistent batch")
 		}
 	}
-	batchArg := fmt.Sprintf("%s@%s", batchFS, strings.Join(batchNames, ","))
-	return d.Destroy(ctx, []string{batchArg})
+	batchArg := fmt.Sprintf("%s@%s", batchFS, strings.Join(destroyBatchArgNames(batchNames, b.order), ","))
+	return b.d.Destroy(ctx, []string{batchArg})
 }
 
 // fsbatch must be on same filesystem
-func doDestroyBatchedRec(ctx context.Context, fsbatch []*DestroySnapOp, d destroyer) {
+func (b *destroyBatch) doDestroyBatchedRec(ctx context.Context, fsbatch []*DestroySnapOp) {
 	if len(fsbatch) <= 1 {
-		doDestroySeq(ctx, fsbatch, d)
+		doDestroySeq(ctx, fsbatch, b.d)
 		return
 	}
 
-	err := tryBatch(ctx, fsbatch, d)
+	err := b.tryBatch(ctx, fsbatch)
 	if err == nil {
 		setDestroySnapOpErr(fsbatch, nil)
 		return
@@ -160,8 +278,8 @@ func doDestroyBatchedRec(ctx context.Context, fsbatch []*DestroySnapOp, d destro
 		// see TestExcessiveArgumentsResultInE2BIG
 		// try halving batch size, assuming snapshots names are roughly the same length
 		debug("batch destroy: E2BIG encountered: %s", err)
-		doDestroyBatchedRec(ctx, fsbatch[0:len(fsbatch)/2], d)
-		doDestroyBatchedRec(ctx, fsbatch[len(fsbatch)/2:], d)
+		b.doDestroyBatchedRec(ctx, fsbatch[0:len(fsbatch)/2])
+		b.doDestroyBatchedRec(ctx, fsbatch[len(fsbatch)/2:])
 		return
 	}
 
@@ -186,7 +304,7 @@ func doDestroyBatchedRec(ctx context.Context, fsbatch []*DestroySnapOp, d destro
 			}
 		}
 
-		err := tryBatch(ctx, strippedBatch, d)
+		err := b.tryBatch(ctx, strippedBatch)
 		if err != nil {
 			// run entire batch sequentially if the stripped one fails
 			// (it shouldn't because we stripped erroneous datasets)
@@ -198,7 +316,7 @@ func doDestroyBatchedRec(ctx context.Context, fsbatch []*DestroySnapOp, d destro
 		// fallthrough
 	}
 
-	doDestroySeq(ctx, singleRun, d)
+	doDestroySeq(ctx, singleRun, b.d)
 
 }
 
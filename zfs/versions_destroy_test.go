@@ -294,3 +294,52 @@ func TestExcessiveArgumentsResultInE2BIG(t *testing.T) {
 		t.Logf("output:\n%s", output)
 	}
 }
+
+func TestBatchDestroySnapsRanged(t *testing.T) {
+	var errs [6]error
+	reqs := []*DestroySnapOp{
+		{Filesystem: "zroot/a", Name: "s1", ErrOut: &errs[0]},
+		{Filesystem: "zroot/a", Name: "s2", ErrOut: &errs[1]},
+		{Filesystem: "zroot/a", Name: "s3", ErrOut: &errs[2]},
+		{Filesystem: "zroot/a", Name: "s5", ErrOut: &errs[3]},
+		{Filesystem: "zroot/a", Name: "s6", ErrOut: &errs[4]},
+		{Filesystem: "zroot/b", Name: "x", ErrOut: &errs[5]},
+	}
+	order := SnapshotOrder{
+		// s4 is not requested, thus must not be part of a range
+		"zroot/a": {"s0", "s1", "s2", "s3", "s4", "s5", "s6", "s7"},
+	}
+	mock := &mockBatchDestroy{}
+	doDestroyWithOrder(context.TODO(), reqs, order, mock)
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	defer mock.mtx.Lock().Unlock()
+	assert.Equal(t, []string{"zroot/a@s1%s3,s5,s6", "zroot/b@x"}, mock.calls)
+}
+
+func TestDestroyBatchArgNames(t *testing.T) {
+	order := []string{"a", "b", "c", "d", "e", "f", "g"}
+	assert.Equal(t, []string{"b", "a"}, destroyBatchArgNames([]string{"b", "a"}, nil))
+	assert.Equal(t, []string{"a", "b"}, destroyBatchArgNames([]string{"b", "a"}, order), "ordered by order")
+	assert.Equal(t, []string{"a%g"}, destroyBatchArgNames(order, order))
+	assert.Equal(t, []string{"a%c", "e", "f"}, destroyBatchArgNames([]string{"f", "e", "c", "b", "a"}, order))
+	assert.Equal(t, []string{"a", "x"}, destroyBatchArgNames([]string{"a", "x"}, order), "names missing from order disable ranges")
+}
+
+func TestChunkBatch(t *testing.T) {
+	var dummy error
+	op := func(name string) *DestroySnapOp { return &DestroySnapOp{Filesystem: "fs", Name: name, ErrOut: &dummy} }
+	batch := []*DestroySnapOp{op("aa"), op("bb"), op("cc"), op("a-very-long-name"), op("dd")}
+	chunks := chunkBatch(batch, len("fs@aa,bb"))
+	var names [][]string
+	for _, c := range chunks {
+		var n []string
+		for _, r := range c {
+			n = append(n, r.Name)
+		}
+		names = append(names, n)
+	}
+	assert.Equal(t, [][]string{{"aa", "bb"}, {"cc"}, {"a-very-long-name"}, {"dd"}}, names)
+	assert.Len(t, chunkBatch(batch, 1<<20), 1)
+}
@@ -41,10 +41,22 @@ func (p *DatasetPath) Empty() bool {
 	return len(p.comps) == 0
 }
 
+// Extend appends the components of extend to p, modifying p in place.
+// All holders of p observe the change, which is usually not what is desired
+// for paths that are shared, e.g. stored in a map. Use Extended in that case.
 func (p *DatasetPath) Extend(extend *DatasetPath) {
 	p.comps = append(p.comps, extend.comps...)
 }
 
+// Extended returns a new path that consists of p's components followed by extend's components.
+// Neither p nor extend are modified.
+func (p *DatasetPath) Extended(extend *DatasetPath) *DatasetPath {
+	c := &DatasetPath{comps: make([]string, 0, len(p.comps)+len(extend.comps))}
+	c.comps = append(c.comps, p.comps...)
+	c.comps = append(c.comps, extend.comps...)
+	return c
+}
+
 func (p *DatasetPath) HasPrefix(prefix *DatasetPath) bool {
 	if len(prefix.comps) > len(p.comps) {
 		return false
@@ -70,6 +82,20 @@ func (p *DatasetPath) TrimPrefix(prefix *DatasetPath) {
 	}
 }
 
+// Relative returns a new path that consists of p's components below base,
+// and whether base is a prefix of p.
+// If p equals base, the returned path is empty.
+// If base is not a prefix of p, the returned path is a copy of p, like with TrimPrefix.
+// Neither p nor base are modified.
+func (p *DatasetPath) Relative(base *DatasetPath) (*DatasetPath, bool) {
+	if !p.HasPrefix(base) {
+		return p.Copy(), false
+	}
+	c := &DatasetPath{comps: make([]string, len(p.comps)-len(base.comps))}
+	copy(c.comps, p.comps[len(base.comps):])
+	return c, true
+}
+
 func (p *DatasetPath) TrimNPrefixComps(n int) {
 	if len(p.comps) < n {
 		n = len(p.comps)
@@ -113,12 +139,49 @@ func (p *DatasetPath) UnmarshalJSON(b []byte) error {
 	return json.Unmarshal(b, &p.comps)
 }
 
-func (p *DatasetPath) Pool() (string, error) {
+// ErrEmptyDatasetPath is the cause of the errors returned by the ZFS* functions
+// if a *DatasetPath argument is nil or the empty path (see NewDatasetPath),
+// which would otherwise be passed to zfs as an empty or missing argument.
+var ErrEmptyDatasetPath = errors.New("empty dataset path")
+
+// validateDatasetPath returns an error with cause ErrEmptyDatasetPath if any of ps is nil or empty.
+// op describes the operation for the error message.
+func validateDatasetPath(op string, ps ...*DatasetPath) error {
+	for _, p := range ps {
+		if p == nil || p.Empty() {
+			return errors.Wrap(ErrEmptyDatasetPath, op)
+		}
+	}
+	return nil
+}
+
+// validateListArgs rejects empty arguments, which are the result of empty dataset paths.
+func validateListArgs(zfsArgs []string) error {
+	for _, a := range zfsArgs {
+		if a == "" {
+			return errors.Wrap(ErrEmptyDatasetPath, "zfs list")
+		}
+	}
+	return nil
+}
+
+// Pool returns the first component of p, i.e., the name of the pool, or "" if p is empty.
+func (p *DatasetPath) Pool() string {
 	if len(p.comps) < 1 {
-		return "", fmt.Errorf("dataset path does not have a pool component")
+		return ""
 	}
-	return p.comps[0], nil
+	return p.comps[0]
+}
 
+// Parent returns a copy of p without its last component.
+// The parent of a pool's root filesystem and of the empty path is the empty path.
+func (p *DatasetPath) Parent() *DatasetPath {
+	if len(p.comps) < 1 {
+		return &DatasetPath{comps: make([]string, 0)}
+	}
+	c := &DatasetPath{comps: make([]string, len(p.comps)-1)}
+	copy(c.comps, p.comps)
+	return c
 }
 
 func NewDatasetPath(s string) (p *DatasetPath, err error) {
@@ -143,9 +206,63 @@ func NewDatasetPath(s string) (p *DatasetPath, err error) {
 		err = fmt.Errorf("must not end with a '/'")
 		return
 	}
+	for i, c := range p.comps {
+		if len(c) > MaxDatasetNameLen {
+			err = fmt.Errorf("path component %d (%q) is %d bytes long, must not be longer than %d bytes",
+				i+1, truncateName(c), len(c), MaxDatasetNameLen)
+			return
+		}
+	}
+	if len(s) > MaxDatasetNameLen {
+		err = fmt.Errorf("is %d bytes long, must not be longer than %d bytes", len(s), MaxDatasetNameLen)
+		return
+	}
 	return
 }
 
+// ValidateSnapshotHeadroom returns an error if p is too long to have snapshots.
+// It is meant for dataset paths from the config, which zrepl snapshots or receives into.
+// Existing datasets must not be checked with it because ZFS allows names up to MaxDatasetNameLen.
+func (p *DatasetPath) ValidateSnapshotHeadroom() error {
+	// the shortest suffix is @x, longer snapshot names are checked by EntityNamecheck when they are created
+	const minSnapshotSuffixLen = 2
+	if l := len(p.ToString()); l > MaxDatasetNameLen-minSnapshotSuffixLen {
+		return fmt.Errorf("is %d bytes long, must not be longer than %d bytes to leave room for snapshot names",
+			l, MaxDatasetNameLen-minSnapshotSuffixLen)
+	}
+	return nil
+}
+
+// truncateName shortens overlong names for use in error messages.
+func truncateName(s string) string {
+	const max = 32
+	if len(s) <= max {
+		return s
+	}
+	return s[:max] + "..."
+}
+
+// JoinDatasetPath returns a new path that consists of base's components followed by comps.
+// base is not modified.
+// Each element of comps must be a single, non-empty path component.
+func JoinDatasetPath(base *DatasetPath, comps ...string) (*DatasetPath, error) {
+	p := base.Copy()
+	for _, c := range comps {
+		if c == "" {
+			return nil, fmt.Errorf("path component must not be empty")
+		}
+		if strings.Contains(c, "/") {
+			return nil, fmt.Errorf("path component %q must not contain '/'", c)
+		}
+		cp, err := NewDatasetPath(c)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid path component %q", c)
+		}
+		p.Extend(cp)
+	}
+	return p, nil
+}
+
 func toDatasetPath(s string) *DatasetPath {
 	p, err := NewDatasetPath(s)
 	if err != nil {
@@ -166,6 +283,9 @@ func (e *ZFSError) Error() string {
 var ZFS_BINARY string = "zfs"
 
 func ZFSList(ctx context.Context, properties []string, zfsArgs ...string) (res [][]string, err error) {
+	if err := validateListArgs(zfsArgs); err != nil {
+		return nil, err
+	}
 
 	args := make([]string, 0, 4+len(zfsArgs))
 	args = append(args,
@@ -190,11 +310,9 @@ func ZFSList(ctx context.Context, properties []string, zfsArgs ...string) (res [
 	res = make([][]string, 0)
 
 	for s.Scan() {
-		fields := strings.SplitN(s.Text(), "\t", len(properties))
-
-		if len(fields) != len(properties) {
-			err = errors.New("unexpected output")
-			return
+		fields, splitErr := splitListLine(s.Text(), properties)
+		if splitErr != nil {
+			return nil, withZFSVersion(ctx, splitErr)
 		}
 
 		res = append(res, fields)
@@ -229,6 +347,14 @@ type ZFSListResult struct {
 func ZFSListChan(ctx context.Context, out chan ZFSListResult, properties []string, notExistHint *DatasetPath, zfsArgs ...string) {
 	defer close(out)
 
+	if err := validateListArgs(zfsArgs); err != nil {
+		select {
+		case <-ctx.Done():
+		case out <- ZFSListResult{nil, err}:
+		}
+		return
+	}
+
 	args := make([]string, 0, 4+len(zfsArgs))
 	args = append(args,
 		"list", "-H", "-p",
@@ -265,9 +391,9 @@ func ZFSListChan(ctx context.Context, out chan ZFSListResult, properties []strin
 	s.Buffer(buf, 0)
 
 	for s.Scan() {
-		fields := strings.SplitN(s.Text(), "\t", len(properties))
-		if len(fields) != len(properties) {
-			sendResult(nil, errors.New("unexpected output"))
+		fields, err := splitListLine(s.Text(), properties)
+		if err != nil {
+			sendResult(nil, withZFSVersion(ctx, err))
 			return
 		}
 		if sendResult(fields, nil) {
@@ -330,7 +456,9 @@ func (a ZFSSendArgsUnvalidated) buildCommonSendArgs() ([]string, error) {
 	}
 
 	if a.Encrypted.B {
-		args = append(args, "-w")
+		args = append(args, "-w") // implies the flags of a.Options
+	} else {
+		args = append(args, a.Options.args()...)
 	}
 
 	toV, err := absVersion(a.FS, a.To)
@@ -340,7 +468,7 @@ func (a ZFSSendArgsUnvalidated) buildCommonSendArgs() ([]string, error) {
 
 	fromV := ""
 	if a.From != nil {
-		fromV, err = absVersion(a.FS, a.From)
+		fromV, err = absVersion(a.fromFS(), a.From)
 		if err != nil {
 			return nil, err
 		}
@@ -348,6 +476,8 @@ func (a ZFSSendArgsUnvalidated) buildCommonSendArgs() ([]string, error) {
 
 	if fromV == "" { // Initial
 		args = append(args, toV)
+	} else if a.Intermediates {
+		args = append(args, "-I", fromV, toV)
 	} else {
 		args = append(args, "-i", fromV, toV)
 	}
@@ -575,10 +705,47 @@ type ZFSSendArgsUnvalidated struct {
 	From, To  *ZFSSendArgVersion // From may be nil
 	Encrypted *NilBool
 
+	// If not empty, From is a snapshot of FromOriginFS instead of FS,
+	// and it must be the origin of the clone FS.
+	// The resulting stream is a clone stream that creates FS as a clone on the receiving side.
+	FromOriginFS string
+
+	// Ignored for encrypted (raw) sends, which imply all of them.
+	Options SendOptions
+
+	// If true, the stream contains all snapshots between From and To (send -I instead of -i).
+	// From must be a snapshot, and ResumeToken must be empty.
+	Intermediates bool
+
 	// Preferred if not empty
 	ResumeToken string // if not nil, must match what is specified in From, To (covered by ValidateCorrespondsToResumeToken)
 }
 
+// SendOptions are `zfs send` flags that change the representation of the stream, but not the received data.
+// Raw sends are requested through ZFSSendArgsUnvalidated.Encrypted because they must be validated against
+// the filesystem's encryption.
+//
+// The same options must be used for ZFSSendDry and ZFSSend, otherwise the size estimate does not match the stream.
+type SendOptions struct {
+	Compressed   bool // -c: send compressed blocks as they are on disk
+	LargeBlocks  bool // -L: allow blocks larger than 128KiB
+	EmbeddedData bool // -e: send embedded_data blocks as such
+}
+
+func (o SendOptions) args() []string {
+	var args []string
+	if o.Compressed {
+		args = append(args, "-c")
+	}
+	if o.LargeBlocks {
+		args = append(args, "-L")
+	}
+	if o.EmbeddedData {
+		args = append(args, "-e")
+	}
+	return args
+}
+
 type ZFSSendArgsValidated struct {
 	ZFSSendArgsUnvalidated
 	FromVersion *FilesystemVersion
@@ -635,7 +802,7 @@ func (a ZFSSendArgsUnvalidated) Validate(ctx context.Context) (v ZFSSendArgsVali
 
 	var fromVersion *FilesystemVersion
 	if a.From != nil {
-		fromV, err := a.From.ValidateExistsAndGetVersion(ctx, a.FS)
+		fromV, err := a.From.ValidateExistsAndGetVersion(ctx, a.fromFS())
 		if err != nil {
 			return v, newGenericValidationError(a, errors.Wrap(err, "`From` invalid"))
 		}
@@ -643,6 +810,21 @@ func (a ZFSSendArgsUnvalidated) Validate(ctx context.Context) (v ZFSSendArgsVali
 		// fallthrough
 	}
 
+	if a.FromOriginFS != "" {
+		if err := a.validateFromIsOrigin(ctx, fromVersion); err != nil {
+			return v, newGenericValidationError(a, err)
+		}
+	}
+
+	if a.Intermediates {
+		if fromVersion == nil || !fromVersion.IsSnapshot() {
+			return v, newGenericValidationError(a, fmt.Errorf("`From` must be a snapshot if `Intermediates` is set"))
+		}
+		if a.ResumeToken != "" {
+			return v, newGenericValidationError(a, fmt.Errorf("`ResumeToken` must be empty if `Intermediates` is set"))
+		}
+	}
+
 	if err := a.Encrypted.Validate(); err != nil {
 		return v, newGenericValidationError(a, errors.Wrap(err, "`Raw` invalid"))
 	}
@@ -673,6 +855,38 @@ func (a ZFSSendArgsUnvalidated) Validate(ctx context.Context) (v ZFSSendArgsVali
 	}, nil
 }
 
+// the filesystem that a.From belongs to
+func (a ZFSSendArgsUnvalidated) fromFS() string {
+	if a.FromOriginFS != "" {
+		return a.FromOriginFS
+	}
+	return a.FS
+}
+
+func (a ZFSSendArgsUnvalidated) validateFromIsOrigin(ctx context.Context, fromVersion *FilesystemVersion) error {
+	if dp, err := NewDatasetPath(a.FromOriginFS); err != nil || dp.Length() == 0 {
+		return fmt.Errorf("`FromOriginFS` must be a valid non-zero dataset path")
+	}
+	if fromVersion == nil {
+		return fmt.Errorf("`From` must be set if `FromOriginFS` is set")
+	}
+	if !fromVersion.IsSnapshot() {
+		return fmt.Errorf("`From` must be a snapshot if `FromOriginFS` is set")
+	}
+	fsdp, err := NewDatasetPath(a.FS)
+	if err != nil {
+		return err
+	}
+	originFS, origin, err := ZFSGetOrigin(ctx, fsdp)
+	if err != nil {
+		return errors.Wrap(err, "cannot get origin of `FS`")
+	}
+	if origin == nil || originFS.ToString() != a.FromOriginFS || origin.Guid != fromVersion.Guid {
+		return fmt.Errorf("`From` %q is not the origin of %q", fromVersion.FullPath(a.FromOriginFS), a.FS)
+	}
+	return nil
+}
+
 type ZFSSendArgsResumeTokenMismatchError struct {
 	What ZFSSendArgsResumeTokenMismatchErrorCode
 	Err  error
@@ -754,10 +968,13 @@ func (a ZFSSendArgsUnvalidated) validateCorrespondsToResumeToken(ctx context.Con
 		}
 		// fallthrough
 	} else {
-		if t.RawOK || t.CompressOK {
+		if t.RawOK || (t.CompressOK && !a.Options.Compressed) {
 			return ZFSSendArgsResumeTokenMismatchEncryptionSet.fmt(
 				"resume token must not have `rawok` or `compressok` set but got %v %v", t.RawOK, t.CompressOK)
 		}
+		if a.Options.Compressed && !t.CompressOK {
+			return gen.fmt("resume token must have `compressok` = true for compressed send")
+		}
 		// fallthrough
 	}
 
@@ -866,20 +1083,37 @@ var (
 )
 
 // see test cases for example output
+//
+// The output of send -I has one info line per intermediate snapshot.
+// They are combined into a single DrySendInfo from the first line's From to the last line's To.
 func (s *DrySendInfo) unmarshalZFSOutput(output []byte) (err error) {
 	debug("DrySendInfo.unmarshalZFSOutput: output=%q", output)
 	lines := strings.Split(string(output), "\n")
+	matched := false
 	for _, l := range lines {
-		regexMatched, err := s.unmarshalInfoLine(l)
+		var line DrySendInfo
+		regexMatched, err := line.unmarshalInfoLine(l)
 		if err != nil {
 			return fmt.Errorf("line %q: %s", l, err)
 		}
 		if !regexMatched {
 			continue
 		}
-		return nil
+		if !matched {
+			*s = line
+			matched = true
+			continue
+		}
+		if line.Filesystem != s.Filesystem {
+			return fmt.Errorf("line %q: filesystem %q differs from previous lines' %q", l, line.Filesystem, s.Filesystem)
+		}
+		s.To = line.To
+		s.SizeEstimate += line.SizeEstimate
+	}
+	if !matched {
+		return fmt.Errorf("no match for info line (regex1 %s) (regex2 %s)", sendDryRunInfoLineRegexFull, sendDryRunInfoLineRegexIncremental)
 	}
-	return fmt.Errorf("no match for info line (regex1 %s) (regex2 %s)", sendDryRunInfoLineRegexFull, sendDryRunInfoLineRegexIncremental)
+	return nil
 }
 
 // unmarshal info line, looks like this:
@@ -974,6 +1208,10 @@ type RecvOptions struct {
 	RollbackAndForceRecv bool
 	// Set -s flag used for resumable send & recv
 	SavePartialRecvState bool
+	// Passed as `-o key=value`, see RecvPropertyMismatches for checking that they took effect.
+	SetProperties map[string]string
+	// Passed as `-x key`: the received filesystem inherits these properties instead of using the values from the stream.
+	ExcludeProperties []string
 }
 
 type ErrRecvResumeNotSupported struct {
@@ -1002,6 +1240,7 @@ func ZFSRecv(ctx context.Context, fs string, v *ZFSSendArgVersion, stream io.Rea
 	if !v.IsSnapshot() {
 		return errors.New("must receive into a snapshot")
 	}
+	defer InvalidateDatasetListCache() // the receive might create fs
 
 	fsdp, err := NewDatasetPath(fs)
 	if err != nil {
@@ -1051,6 +1290,8 @@ func ZFSRecv(ctx context.Context, fs string, v *ZFSSendArgVersion, stream io.Rea
 		}
 		args = append(args, "-s")
 	}
+	args = append(args, recvSetPropertiesArgs(opts.SetProperties)...)
+	args = append(args, recvExcludePropertiesArgs(opts.ExcludeProperties)...)
 	args = append(args, v.FullPath(fs))
 
 	ctx, cancelCmd := context.WithCancel(ctx)
@@ -1214,6 +1455,7 @@ func ZFSRecvClearResumeToken(ctx context.Context, fs string) (err error) {
 	if err := validateZFSFilesystem(fs); err != nil {
 		return err
 	}
+	defer InvalidateDatasetListCache() // aborting the receive of a new filesystem destroys it
 
 	cmd := zfscmd.CommandContext(ctx, ZFS_BINARY, "recv", "-A", fs)
 	o, err := cmd.CombinedOutput()
@@ -1226,6 +1468,14 @@ func ZFSRecvClearResumeToken(ctx context.Context, fs string) (err error) {
 	return nil
 }
 
+// ZFSRecvAbort aborts the interrupted receive into fs, discarding its partially received state (`zfs recv -A`).
+func ZFSRecvAbort(ctx context.Context, fs *DatasetPath) error {
+	if err := validateDatasetPath("zfs recv -A", fs); err != nil {
+		return err
+	}
+	return ZFSRecvClearResumeToken(ctx, fs.ToString())
+}
+
 type ZFSProperties struct {
 	m map[string]string
 }
@@ -1253,10 +1503,14 @@ func (p *ZFSProperties) appendArgs(args *[]string) (err error) {
 }
 
 func ZFSSet(ctx context.Context, fs *DatasetPath, props *ZFSProperties) (err error) {
+	if err := validateDatasetPath("zfs set", fs); err != nil {
+		return err
+	}
 	return zfsSet(ctx, fs.ToString(), props)
 }
 
 func zfsSet(ctx context.Context, path string, props *ZFSProperties) (err error) {
+	defer InvalidateDatasetListCache()
 	args := make([]string, 0)
 	args = append(args, "set")
 	err = props.appendArgs(&args)
@@ -1278,6 +1532,9 @@ func zfsSet(ctx context.Context, path string, props *ZFSProperties) (err error)
 }
 
 func ZFSGet(ctx context.Context, fs *DatasetPath, props []string) (*ZFSProperties, error) {
+	if err := validateDatasetPath("zfs get", fs); err != nil {
+		return nil, err
+	}
 	return zfsGet(ctx, fs.ToString(), props, sourceAny)
 }
 
@@ -1305,6 +1562,76 @@ func ZFSGetGUID(ctx context.Context, fs string, version string) (g uint64, err e
 	return strconv.ParseUint(props.Get("guid"), 10, 64)
 }
 
+// ZFSGetWrittenSince returns the number of bytes of referenced space written to fs since snapshot fs@sinceSnap,
+// i.e., the value of the `written@sinceSnap` property.
+//
+// If sinceSnap is empty, the `written` property is used, which is relative to the most recent snapshot of fs
+// or, if fs has no snapshots, the total referenced space.
+// In that case, the value is also exported as a Prometheus gauge.
+func ZFSGetWrittenSince(ctx context.Context, fs *DatasetPath, sinceSnap string) (written int64, err error) {
+	if err := validateDatasetPath("zfs get", fs); err != nil {
+		return 0, err
+	}
+	prop := "written"
+	if sinceSnap != "" {
+		if err := EntityNamecheck(fmt.Sprintf("%s@%s", fs.ToString(), sinceSnap), EntityTypeSnapshot); err != nil {
+			return 0, err
+		}
+		prop = fmt.Sprintf("written@%s", sinceSnap)
+	}
+	props, err := zfsGet(ctx, fs.ToString(), []string{prop}, sourceAny)
+	if err != nil {
+		return 0, err
+	}
+	written, err = strconv.ParseInt(props.Get(prop), 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "cannot parse %s property value %q", prop, props.Get(prop))
+	}
+	if sinceSnap == "" {
+		prom.ZFSWrittenSinceLastSnapshot.WithLabelValues(fs.ToString()).Set(float64(written))
+	}
+	return written, nil
+}
+
+// ZFSGetOrigin returns the snapshot that fs was cloned from (the `origin` property)
+// and the filesystem that snapshot belongs to.
+// If fs is not a clone, originFS and origin are nil.
+func ZFSGetOrigin(ctx context.Context, fs *DatasetPath) (originFS *DatasetPath, origin *FilesystemVersion, err error) {
+	if err := validateDatasetPath("zfs get", fs); err != nil {
+		return nil, nil, err
+	}
+	props, err := zfsGet(ctx, fs.ToString(), []string{"origin"}, sourceAny)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ZFSResolveOrigin(ctx, props.Get("origin"))
+}
+
+// ZFSResolveOrigin is like ZFSGetOrigin, but takes the value of the `origin` property,
+// e.g. as listed by ZFSListMappingProperties.
+// Only if prop is set (i.e. the filesystem is a clone), the origin snapshot is looked up using zfs.
+func ZFSResolveOrigin(ctx context.Context, prop string) (originFS *DatasetPath, origin *FilesystemVersion, err error) {
+	if prop == "" || prop == "-" {
+		return nil, nil, nil
+	}
+	if err := EntityNamecheck(prop, EntityTypeSnapshot); err != nil {
+		return nil, nil, errors.Wrapf(err, "invalid origin %q", prop)
+	}
+	fsName, _, _, err := DecomposeVersionString(prop)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "invalid origin %q", prop)
+	}
+	originFS, err = NewDatasetPath(fsName)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "invalid origin %q", prop)
+	}
+	v, err := ZFSGetFilesystemVersion(ctx, prop)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "cannot get origin %q", prop)
+	}
+	return originFS, &v, nil
+}
+
 type GetMountpointOutput struct {
 	Mounted    bool
 	Mountpoint string
@@ -1334,6 +1661,12 @@ func ZFSGetRawAnySource(ctx context.Context, path string, props []string) (*ZFSP
 	return zfsGet(ctx, path, props, sourceAny)
 }
 
+// ZFSGetRawLocalSource is like ZFSGetRawAnySource, but only returns the values of properties that are set locally on path.
+// Properties that are not set locally (e.g. inherited) are returned as empty strings.
+func ZFSGetRawLocalSource(ctx context.Context, path string, props []string) (*ZFSProperties, error) {
+	return zfsGet(ctx, path, props, sourceLocal)
+}
+
 var zfsGetDatasetDoesNotExistRegexp = regexp.MustCompile(`^cannot open '([^)]+)': (dataset does not exist|no such pool or dataset)`) // verified in platformtest
 
 type DatasetDoesNotExist struct {
@@ -1436,6 +1769,58 @@ func zfsGet(ctx context.Context, path string, props []string, allowedSources zfs
 	return res, nil
 }
 
+// PropertyValue is a property value with its source as reported by `zfs get`.
+type PropertyValue struct {
+	Value  string
+	Source string // e.g. "local", "default", "inherited from pool/a", "received", "-"
+}
+
+// IsLocal returns true if the value is set on the dataset itself, i.e., not inherited or a default.
+func (v PropertyValue) IsLocal() bool { return v.Source == "local" }
+
+// ZFSGetPropertyValues returns the values of props of the filesystems and volumes in datasets,
+// keyed by dataset name and property.
+// If datasets is empty, the values of all filesystems and volumes are returned.
+func ZFSGetPropertyValues(ctx context.Context, props []string, datasets ...string) (map[string]map[string]PropertyValue, error) {
+	args := []string{"get", "-Hp", "-t", "filesystem,volume", "-o", "name,property,value,source", strings.Join(props, ",")}
+	args = append(args, datasets...)
+	cmd := zfscmd.CommandContext(ctx, ZFS_BINARY, args...)
+	stdout, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			if exitErr.Exited() && len(datasets) == 1 {
+				if ddne := tryDatasetDoesNotExist(datasets[0], exitErr.Stderr); ddne != nil {
+					return nil, ddne
+				}
+			}
+			return nil, &ZFSError{
+				Stderr:  exitErr.Stderr,
+				WaitErr: exitErr,
+			}
+		}
+		return nil, err
+	}
+	return parsePropertyValues(stdout)
+}
+
+func parsePropertyValues(stdout []byte) (map[string]map[string]PropertyValue, error) {
+	res := make(map[string]map[string]PropertyValue)
+	for _, line := range strings.Split(string(stdout), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("zfs get did not return name,property,value,source tuples: %q", line)
+		}
+		if res[fields[0]] == nil {
+			res[fields[0]] = make(map[string]PropertyValue)
+		}
+		res[fields[0]][fields[1]] = PropertyValue{Value: fields[2], Source: fields[3]}
+	}
+	return res, nil
+}
+
 type DestroySnapshotsError struct {
 	RawLines      []string
 	Filesystem    string
@@ -1518,6 +1903,9 @@ func ZFSDestroy(ctx context.Context, arg string) (err error) {
 	}
 
 	defer prometheus.NewTimer(prom.ZFSDestroyDuration.WithLabelValues(dstype, filesystem))
+	if dstype == "filesystem" {
+		defer InvalidateDatasetListCache()
+	}
 
 	cmd := zfscmd.CommandContext(ctx, ZFS_BINARY, "destroy", arg)
 	stdio, err := cmd.CombinedOutput()
@@ -1551,8 +1939,43 @@ func ZFSDestroyIdempotent(ctx context.Context, path string) error {
 	return err
 }
 
-func ZFSSnapshot(ctx context.Context, fs *DatasetPath, name string, recursive bool) (err error) {
+// ZFSDestroyFilesystemRecursive destroys fs, its descendants and all their snapshots (`zfs destroy -r`).
+//
+// As a safety measure, fs must not be the root dataset of a pool.
+func ZFSDestroyFilesystemRecursive(ctx context.Context, fs *DatasetPath) error {
+	if err := validateDatasetPath("zfs destroy", fs); err != nil {
+		return err
+	}
+	if fs.Length() < 2 {
+		return fmt.Errorf("refusing to recursively destroy pool root dataset %q", fs.ToString())
+	}
+	if err := EntityNamecheck(fs.ToString(), EntityTypeFilesystem); err != nil {
+		return err
+	}
+
+	defer prometheus.NewTimer(prom.ZFSDestroyDuration.WithLabelValues("filesystem", fs.ToString())).ObserveDuration()
+	defer InvalidateDatasetListCache()
 
+	cmd := zfscmd.CommandContext(ctx, ZFS_BINARY, "destroy", "-r", fs.ToString())
+	stdio, err := cmd.CombinedOutput()
+	if err != nil {
+		if dsNotExistErr := tryDatasetDoesNotExist(fs.ToString(), stdio); dsNotExistErr != nil {
+			return dsNotExistErr
+		}
+		return &ZFSError{
+			Stderr:  stdio,
+			WaitErr: err,
+		}
+	}
+	return nil
+}
+
+// ZFSSnapshot creates the snapshot fs@name.
+// If recursive is true, fs and all of its descendants are snapshotted atomically (`zfs snapshot -r`).
+func ZFSSnapshot(ctx context.Context, fs *DatasetPath, name string, recursive bool) (err error) {
+	if err := validateDatasetPath("zfs snapshot", fs); err != nil {
+		return err
+	}
 	promTimer := prometheus.NewTimer(prom.ZFSSnapshotDuration.WithLabelValues(fs.ToString()))
 	defer promTimer.ObserveDuration()
 
@@ -1561,7 +1984,12 @@ func ZFSSnapshot(ctx context.Context, fs *DatasetPath, name string, recursive bo
 		return errors.Wrap(err, "zfs snapshot")
 	}
 
-	cmd := zfscmd.CommandContext(ctx, ZFS_BINARY, "snapshot", snapname)
+	args := []string{"snapshot"}
+	if recursive {
+		args = append(args, "-r")
+	}
+	args = append(args, snapname)
+	cmd := zfscmd.CommandContext(ctx, ZFS_BINARY, args...)
 	stdio, err := cmd.CombinedOutput()
 	if err != nil {
 		err = &ZFSError{
@@ -1663,7 +2091,9 @@ func ZFSBookmark(ctx context.Context, fs string, v FilesystemVersion, bookmark s
 }
 
 func ZFSRollback(ctx context.Context, fs *DatasetPath, snapshot FilesystemVersion, rollbackArgs ...string) (err error) {
-
+	if err := validateDatasetPath("zfs rollback", fs); err != nil {
+		return err
+	}
 	snapabs := snapshot.ToAbsPath(fs)
 	if snapshot.Type != Snapshot {
 		return fmt.Errorf("can only rollback to snapshots, got %s", snapabs)
@@ -2,11 +2,19 @@ package zfs
 
 import (
 	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+	"github.com/zrepl/zrepl/zfs/zfscmd"
 )
 
 // FIXME make this a platformtest
@@ -27,6 +35,25 @@ func TestZFSListHandlesProducesZFSErrorOnNonZeroExit(t *testing.T) {
 	assert.Equal(t, "error: this is a mock\n", string(zfsError.Stderr))
 }
 
+func TestZFSListProducesZFSErrorIfPrivilegeWrapperFails(t *testing.T) {
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+
+	wrapper, err := filepath.Abs("./test_helpers/sudo_failer.sh")
+	require.NoError(t, err)
+	require.NoError(t, zfscmd.SetPrivilegeWrapper([]string{wrapper, "-n"}))
+	defer func() { require.NoError(t, zfscmd.SetPrivilegeWrapper(nil)) }()
+
+	_, err = ZFSList(ctx, []string{"name"}, "pool/dataset")
+	require.Error(t, err)
+	zfsError, ok := err.(*ZFSError)
+	require.True(t, ok, "%T", err)
+	assert.Equal(t, "sudo: a password is required\n", string(zfsError.Stderr))
+	exitErr, ok := zfsError.WaitErr.(*exec.ExitError)
+	require.True(t, ok, "%T", zfsError.WaitErr)
+	assert.Equal(t, 1, exitErr.ExitCode())
+}
+
 func TestDatasetPathTrimNPrefixComps(t *testing.T) {
 	p, err := NewDatasetPath("foo/bar/a/b")
 	assert.Nil(t, err)
@@ -38,6 +65,145 @@ func TestDatasetPathTrimNPrefixComps(t *testing.T) {
 	assert.True(t, p.Empty(), "empty trimming shouldn't do harm")
 }
 
+func TestDatasetPathExtended(t *testing.T) {
+	base := toDatasetPath("pool/a")
+	base.comps = append(make([]string, 0, 10), base.comps...) // spare capacity must not be shared
+
+	b := base.Extended(toDatasetPath("b"))
+	c := base.Extended(toDatasetPath("c/d"))
+	assert.Equal(t, "pool/a", base.ToString())
+	assert.Equal(t, "pool/a/b", b.ToString())
+	assert.Equal(t, "pool/a/c/d", c.ToString())
+}
+
+func TestDatasetPathRelative(t *testing.T) {
+	type tc struct {
+		p, base  string
+		expect   string
+		isPrefix bool
+	}
+	tcs := []tc{
+		{"pool/a/b/c", "pool/a", "b/c", true},
+		{"pool/a", "pool/a", "", true},
+		{"pool/a", "", "pool/a", true},
+		{"", "", "", true},
+		{"pool/a", "pool/a/b", "pool/a", false},
+		{"pool/ab/c", "pool/a", "pool/ab/c", false},
+		{"other/a", "pool", "other/a", false},
+	}
+	for _, c := range tcs {
+		t.Run(fmt.Sprintf("%q-%q", c.p, c.base), func(t *testing.T) {
+			p, base := toDatasetPath(c.p), toDatasetPath(c.base)
+			rel, isPrefix := p.Relative(base)
+			assert.Equal(t, c.isPrefix, isPrefix)
+			assert.Equal(t, c.expect, rel.ToString())
+			assert.Equal(t, c.expect == "", rel.Empty())
+			assert.Equal(t, c.p, p.ToString(), "p must not be modified")
+			assert.Equal(t, c.base, base.ToString(), "base must not be modified")
+		})
+	}
+
+	// the result must not share memory with p
+	p := toDatasetPath("pool/a/b")
+	rel, _ := p.Relative(toDatasetPath("pool"))
+	rel.Extend(toDatasetPath("c"))
+	assert.Equal(t, "pool/a/b", p.ToString())
+}
+
+func TestDatasetPathPool(t *testing.T) {
+	pool := func(s string) string {
+		p, err := NewDatasetPath(s)
+		require.NoError(t, err)
+		return p.Pool()
+	}
+	assert.Equal(t, "pool", pool("pool/foo/bar"))
+	assert.Equal(t, "pool", pool("pool"))
+	assert.Equal(t, "", pool(""))
+}
+
+func TestNewDatasetPathLength(t *testing.T) {
+	long := strings.Repeat("a", MaxDatasetNameLen+1)
+	_, err := NewDatasetPath("pool/" + long + "/child")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "path component 2")
+	assert.Contains(t, err.Error(), strings.Repeat("a", 32)+"...")
+
+	maxLen := "pool/" + strings.Repeat("a", MaxDatasetNameLen-len("pool/"))
+	p, err := NewDatasetPath(maxLen)
+	require.NoError(t, err, "ZFS allows names of MaxDatasetNameLen bytes")
+	_, err = NewDatasetPath(maxLen + "a")
+	assert.Error(t, err)
+
+	assert.Error(t, p.ValidateSnapshotHeadroom(), "no room for a snapshot name")
+	p, err = NewDatasetPath("pool/" + strings.Repeat("a", MaxDatasetNameLen-len("pool/")-2))
+	require.NoError(t, err)
+	assert.NoError(t, p.ValidateSnapshotHeadroom())
+}
+
+func TestDatasetPathParent(t *testing.T) {
+	parent := func(s string) string {
+		p, err := NewDatasetPath(s)
+		require.NoError(t, err)
+		parent := p.Parent()
+		assert.Equal(t, s, p.ToString(), "p must not be modified")
+		return parent.ToString()
+	}
+	assert.Equal(t, "pool/foo", parent("pool/foo/bar"))
+	assert.Equal(t, "pool", parent("pool/foo"))
+	assert.Equal(t, "", parent("pool"))
+	assert.Equal(t, "", parent(""))
+
+	p := toDatasetPath("pool/foo/bar")
+	p.Parent().Extend(toDatasetPath("baz"))
+	assert.Equal(t, "pool/foo/bar", p.ToString(), "parent must not share comps with p")
+	assert.True(t, p.Parent().Parent().Equal(toDatasetPath("pool")))
+}
+
+func TestZFSFunctionsRejectEmptyDatasetPath(t *testing.T) {
+	ctx := context.Background()
+	empty, err := NewDatasetPath("")
+	require.NoError(t, err)
+	valid := toDatasetPath("pool/fs")
+
+	// none of these must invoke zfs
+	errs := map[string]error{
+		"nil":      ZFSSnapshot(ctx, nil, "snap", false),
+		"snapshot": ZFSSnapshot(ctx, empty, "snap", false),
+		"destroy":  ZFSDestroyFilesystemRecursive(ctx, empty),
+		"rename":   ZFSRename(ctx, valid, empty, RenameOptions{}),
+		"set":      ZFSSet(ctx, empty, NewZFSProperties()),
+	}
+	_, errs["get"] = ZFSGet(ctx, empty, []string{"name"})
+	_, errs["written"] = ZFSGetWrittenSince(ctx, empty, "")
+	_, errs["versions"] = ZFSListFilesystemVersions(ctx, empty, ListFilesystemVersionsOptions{})
+	_, errs["list"] = ZFSList(ctx, []string{"name"}, "-r", empty.ToString())
+	for op, err := range errs {
+		assert.Equal(t, ErrEmptyDatasetPath, errors.Cause(err), op)
+	}
+}
+
+func TestJoinDatasetPath(t *testing.T) {
+	base := toDatasetPath("pool/a")
+
+	p, err := JoinDatasetPath(base, "b", "c")
+	require.NoError(t, err)
+	assert.Equal(t, "pool/a/b/c", p.ToString())
+	assert.Equal(t, "pool/a", base.ToString(), "base must not be modified")
+
+	p, err = JoinDatasetPath(base)
+	require.NoError(t, err)
+	assert.True(t, p.Equal(base))
+
+	p, err = JoinDatasetPath(toDatasetPath(""), "pool")
+	require.NoError(t, err)
+	assert.Equal(t, "pool", p.ToString())
+
+	for _, invalid := range []string{"", "b/c", "/", "b@snap", "b#book"} {
+		_, err = JoinDatasetPath(base, invalid)
+		assert.Error(t, err, "%q", invalid)
+	}
+}
+
 func TestZFSPropertySource(t *testing.T) {
 
 	tcs := []struct {
@@ -136,6 +302,14 @@ size	10511856
 	fullNoToken := `
 full	zroot/test/a@3	10518512
 size	10518512
+`
+
+	// incremental send with intermediate snapshots
+	// $ sudo zfs send -nvP -I @1 zroot/test/a@3
+	incIntermediates := `
+incremental	1	zroot/test/a@2	10511856
+incremental	2	zroot/test/a@3	6656
+size	10518512
 `
 
 	fullWithSpaces := "\nfull\tpool1/otherjob/ds with spaces@blaffoo\t12912\nsize\t12912\n"
@@ -203,6 +377,16 @@ size	10518512
 				SizeEstimate: 10518512,
 			},
 		},
+		{
+			name: "incIntermediates", in: incIntermediates,
+			exp: &DrySendInfo{
+				Type:         DrySendTypeIncremental,
+				Filesystem:   "zroot/test/a",
+				From:         "1",
+				To:           "zroot/test/a@3",
+				SizeEstimate: 10518512,
+			},
+		},
 		{
 			name: "fullWithSpaces", in: fullWithSpaces,
 			exp: &DrySendInfo{
@@ -271,3 +455,182 @@ func TestTryRecvDestroyOrOverwriteEncryptedErr(t *testing.T) {
 	require.NotNil(t, err)
 	assert.EqualError(t, err, strings.TrimSpace(msg))
 }
+
+func TestFilesystemVersionsFromListResultsSkipsMalformedCreation(t *testing.T) {
+	lines := [][]string{
+		{"pool/fs@a", "1", "10", "1600000000", "0"},
+		{"pool/fs@empty", "2", "11", "", "0"},
+		{"pool/fs@garbage", "3", "12", "Mon Sep 14 12:26 2020", "0"},
+		{"pool/fs@zero", "4", "13", "0", "0"},
+		{"pool/fs@negative", "5", "14", "-1", "0"},
+		{"pool/fs#b", "6", "15", "1600000100", "-"},
+	}
+	results := make(chan ZFSListResult, len(lines))
+	for _, l := range lines {
+		results <- ZFSListResult{Fields: l}
+	}
+	close(results)
+
+	versions, err := filesystemVersionsFromListResults(context.Background(), results, ListFilesystemVersionsOptions{})
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, "a", versions[0].Name)
+	assert.Equal(t, int64(1600000000), versions[0].Creation.Unix())
+	assert.Equal(t, "b", versions[1].Name)
+
+	for _, l := range lines[1:5] {
+		_, err := ParseFilesystemVersion(ParseFilesystemVersionArgs{
+			fullname: l[0], guid: l[1], createtxg: l[2], creation: l[3], userrefs: l[4],
+		})
+		assert.IsType(t, &FilesystemVersionCreationError{}, err, "%s", l[0])
+	}
+}
+
+func TestFilesystemVersionsFromListResultsFailsOnOtherErrors(t *testing.T) {
+	results := make(chan ZFSListResult, 2)
+	results <- ZFSListResult{Fields: []string{"pool/fs@a", "1", "10", "1600000000", "0"}}
+	results <- ZFSListResult{Fields: []string{"pool/fs@b", "notaguid", "11", "1600000100", "0"}}
+	close(results)
+
+	_, err := filesystemVersionsFromListResults(context.Background(), results, ListFilesystemVersionsOptions{})
+	assert.Error(t, err)
+}
+
+func TestFilesystemVersionsFromListResultsSnapshotsAndBookmarks(t *testing.T) {
+	lines := [][]string{
+		{"pool/fs#zrepl_b", "1", "10", "1600000000", "-"},
+		{"pool/fs@zrepl_b", "1", "10", "1600000000", "2"},
+		{"pool/fs#other", "3", "5", "1590000000", "-"},
+		{"pool/fs@zrepl_a", "3", "5", "1590000000", "0"},
+		{"pool/fs#zrepl_a", "3", "5", "1590000000", "-"},
+	}
+	results := make(chan ZFSListResult, len(lines))
+	for _, l := range lines {
+		results <- ZFSListResult{Fields: l}
+	}
+	close(results)
+
+	versions, err := filesystemVersionsFromListResults(context.Background(), results, ListFilesystemVersionsOptions{})
+	require.NoError(t, err)
+	var names []string
+	for _, v := range versions {
+		names = append(names, v.RelName())
+	}
+	assert.Equal(t, []string{"@zrepl_a", "#other", "#zrepl_a", "@zrepl_b", "#zrepl_b"}, names)
+
+	for _, v := range versions {
+		if v.IsSnapshot() {
+			assert.Equal(t, Snapshot, v.Type)
+			assert.True(t, v.UserRefs.Valid, "%s", v)
+		} else {
+			assert.True(t, v.IsBookmark())
+			assert.Equal(t, Bookmark, v.Type)
+			assert.False(t, v.UserRefs.Valid, "%s", v)
+		}
+	}
+	// a bookmark shares guid and createtxg with the snapshot it was created from
+	assert.Equal(t, versions[3].Guid, versions[4].Guid)
+	assert.Equal(t, versions[3].CreateTXG, versions[4].CreateTXG)
+
+	bookmarks := BookmarksWithPrefix(versions, "zrepl_")
+	require.Len(t, bookmarks, 2)
+	assert.Equal(t, "#zrepl_a", bookmarks[0].RelName())
+	assert.Equal(t, "#zrepl_b", bookmarks[1].RelName())
+
+	snapshots := FilterFilesystemVersions(versions, ListFilesystemVersionsOptions{Types: Snapshots})
+	require.Len(t, snapshots, 2)
+	assert.Equal(t, "@zrepl_a", snapshots[0].RelName())
+}
+
+func TestParseZFSHoldsOutput(t *testing.T) {
+	output := "pool/fs@a\tzrepl_STEP_J_push\t1600000000\npool/fs@a\tkeep\t1600000001\npool/fs/child@b\tzrepl_last_received_J_pull\t1600000002\n"
+	holds, err := parseZFSHoldsOutput([]byte(output))
+	require.NoError(t, err)
+	require.Len(t, holds, 3)
+	assert.Equal(t, Hold{"pool/fs@a", "zrepl_STEP_J_push", time.Unix(1600000000, 0)}, holds[0])
+	assert.Equal(t, "keep", holds[1].Tag)
+	assert.Equal(t, "pool/fs/child@b", holds[2].Snapshot)
+
+	holds, err = parseZFSHoldsOutput(nil)
+	require.NoError(t, err)
+	assert.Len(t, holds, 0)
+
+	_, err = parseZFSHoldsOutput([]byte("pool/fs@a\tkeep\tMon Sep 14 12:26 2020\n"))
+	assert.Error(t, err)
+	_, err = parseZFSHoldsOutput([]byte("pool/fs@a\tkeep\n"))
+	assert.Error(t, err)
+}
+
+func TestZFSResolveOrigin(t *testing.T) {
+	ctx := context.Background()
+	for _, prop := range []string{"", "-"} {
+		originFS, origin, err := ZFSResolveOrigin(ctx, prop)
+		require.NoError(t, err, prop)
+		assert.Nil(t, originFS, prop)
+		assert.Nil(t, origin, prop)
+	}
+
+	for _, prop := range []string{"pool/fs", "pool/fs#bookmark", "pool/fs@a@b"} {
+		_, _, err := ZFSResolveOrigin(ctx, prop)
+		assert.Error(t, err, prop)
+	}
+}
+
+func TestParsePropertyValues(t *testing.T) {
+	out := "pool\tbackup:policy\t-\t-\npool/a\tbackup:policy\tgold\tlocal\npool/a/b\tbackup:policy\tgold\tinherited from pool/a\npool/a/b\tcompression\tlz4\tdefault\n"
+	vals, err := parsePropertyValues([]byte(out))
+	require.NoError(t, err)
+	assert.Len(t, vals, 3)
+	assert.Equal(t, PropertyValue{"gold", "local"}, vals["pool/a"]["backup:policy"])
+	assert.True(t, vals["pool/a"]["backup:policy"].IsLocal())
+	assert.False(t, vals["pool/a/b"]["backup:policy"].IsLocal())
+	assert.Equal(t, "lz4", vals["pool/a/b"]["compression"].Value)
+
+	_, err = parsePropertyValues([]byte("pool\tbackup:policy\t-\n"))
+	assert.Error(t, err)
+}
+
+func TestChangeKeyOptionsArgs(t *testing.T) {
+	args, err := ChangeKeyOptions{Inherit: true}.args("pool/fs")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"change-key", "-i", "pool/fs"}, args)
+
+	args, err = ChangeKeyOptions{KeyLocation: "file:///key", KeyFormat: "raw"}.args("pool/fs")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"change-key", "-o", "keylocation=file:///key", "-o", "keyformat=raw", "pool/fs"}, args)
+
+	_, err = ChangeKeyOptions{Inherit: true, KeyLocation: "file:///key"}.args("pool/fs")
+	assert.Error(t, err)
+	_, err = ChangeKeyOptions{KeyLocation: "prompt"}.args("pool/fs")
+	assert.Error(t, err)
+	_, err = ChangeKeyOptions{Inherit: true}.args("")
+	assert.Error(t, err)
+}
+
+func TestBuildCommonSendArgsOptions(t *testing.T) {
+	to := &ZFSSendArgVersion{RelName: "@b", GUID: 2}
+	from := &ZFSSendArgVersion{RelName: "@a", GUID: 1}
+	allOptions := SendOptions{Compressed: true, LargeBlocks: true, EmbeddedData: true}
+
+	args, err := ZFSSendArgsUnvalidated{FS: "pool/fs", To: to, Encrypted: &NilBool{false}}.buildCommonSendArgs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"pool/fs@b"}, args)
+
+	args, err = ZFSSendArgsUnvalidated{FS: "pool/fs", From: from, To: to, Encrypted: &NilBool{false}, Options: allOptions}.buildCommonSendArgs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-c", "-L", "-e", "-i", "pool/fs@a", "pool/fs@b"}, args)
+
+	args, err = ZFSSendArgsUnvalidated{FS: "pool/fs", To: to, Encrypted: &NilBool{false}, Options: SendOptions{LargeBlocks: true}}.buildCommonSendArgs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-L", "pool/fs@b"}, args)
+
+	// raw sends imply the options
+	args, err = ZFSSendArgsUnvalidated{FS: "pool/fs", To: to, Encrypted: &NilBool{true}, Options: allOptions}.buildCommonSendArgs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-w", "pool/fs@b"}, args)
+
+	// the resume token determines the flags
+	args, err = ZFSSendArgsUnvalidated{FS: "pool/fs", To: to, Encrypted: &NilBool{false}, Options: allOptions, ResumeToken: "1-abc"}.buildCommonSendArgs()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"-t", "1-abc"}, args)
+}
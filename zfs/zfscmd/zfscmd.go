@@ -6,29 +6,47 @@
 package zfscmd
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"os"
 	"os/exec"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/zrepl/zrepl/daemon/logging/trace"
 	"github.com/zrepl/zrepl/util/circlog"
+	"github.com/zrepl/zrepl/util/envconst"
 )
 
 type Cmd struct {
 	cmd                                      *exec.Cmd
+	args                                     []string // name and args as passed to CommandContext
 	ctx                                      context.Context
 	mtx                                      sync.RWMutex
 	startedAt, waitStartedAt, waitReturnedAt time.Time
 	waitReturnEndSpanCb                      trace.DoneFunc
+
+	// only set if the command runs under a privilege wrapper, see terminateWrapperOnDone
+	wrapperExited, wrapperTerminated chan struct{}
 }
 
+// CommandContext is like exec.CommandContext, but name is resolved using the paths set by SetBinaryPaths,
+// and the command is prefixed with the wrapper set by SetPrivilegeWrapper.
 func CommandContext(ctx context.Context, name string, arg ...string) *Cmd {
-	cmd := exec.CommandContext(ctx, name, arg...)
-	return &Cmd{cmd: cmd, ctx: ctx}
+	argv, wrapped := argv(name, arg)
+	c := &Cmd{args: append([]string{name}, arg...), ctx: ctx}
+	if wrapped {
+		// cancellation is handled by terminateWrapperOnDone
+		c.cmd = exec.Command(argv[0], argv[1:]...)
+		c.wrapperExited = make(chan struct{})
+		c.wrapperTerminated = make(chan struct{})
+	} else {
+		c.cmd = exec.CommandContext(ctx, argv[0], argv[1:]...)
+	}
+	return c
 }
 
 // err.(*exec.ExitError).Stderr will NOT be set
@@ -36,7 +54,14 @@ func (c *Cmd) CombinedOutput() (o []byte, err error) {
 	c.startPre(false)
 	c.startPost(nil)
 	c.waitPre()
-	o, err = c.cmd.CombinedOutput()
+	if c.wrapperExited == nil {
+		o, err = c.cmd.CombinedOutput()
+	} else {
+		var b bytes.Buffer
+		c.cmd.Stdout, c.cmd.Stderr = &b, &b
+		err = c.runWrapped()
+		o = b.Bytes()
+	}
 	c.waitPost(err)
 	return
 }
@@ -46,11 +71,64 @@ func (c *Cmd) Output() (o []byte, err error) {
 	c.startPre(false)
 	c.startPost(nil)
 	c.waitPre()
-	o, err = c.cmd.Output()
+	if c.wrapperExited == nil {
+		o, err = c.cmd.Output()
+	} else {
+		var stdout, stderr bytes.Buffer
+		c.cmd.Stdout, c.cmd.Stderr = &stdout, &stderr
+		err = c.runWrapped()
+		if ee, ok := err.(*exec.ExitError); ok {
+			ee.Stderr = stderr.Bytes()
+		}
+		o = stdout.Bytes()
+	}
 	c.waitPost(err)
 	return
 }
 
+func (c *Cmd) runWrapped() error {
+	if err := c.cmd.Start(); err != nil {
+		return err
+	}
+	go c.terminateWrapperOnDone()
+	err := c.cmd.Wait()
+	close(c.wrapperExited)
+	<-c.wrapperTerminated
+	return err
+}
+
+var privilegeWrapperGraceTimeout = envconst.Duration("ZREPL_ZFSCMD_PRIVILEGE_WRAPPER_GRACE_TIMEOUT", 10*time.Second)
+
+// terminateWrapperOnDone stops the privilege wrapper once c.ctx is done, unless it has exited before.
+//
+// exec.CommandContext would kill the wrapper with SIGKILL, which a wrapper like sudo cannot forward,
+// thus the wrapped command would keep running. Instead, the wrapper receives SIGTERM, which sudo forwards,
+// and is only killed if it does not exit within privilegeWrapperGraceTimeout.
+func (c *Cmd) terminateWrapperOnDone() {
+	defer close(c.wrapperTerminated)
+	select {
+	case <-c.wrapperExited:
+		return
+	case <-c.ctx.Done():
+	}
+	l := c.log()
+	l.Debug("context done, sending SIGTERM to privilege wrapper")
+	if err := c.cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		l.WithError(err).Warn("cannot send SIGTERM to privilege wrapper")
+	} else {
+		select {
+		case <-c.wrapperExited:
+			return
+		case <-time.After(privilegeWrapperGraceTimeout):
+		}
+		l.WithField("grace_timeout", privilegeWrapperGraceTimeout).Warn("privilege wrapper did not exit within grace timeout")
+	}
+	l.Warn("killing privilege wrapper, the wrapped command might keep running")
+	if err := c.cmd.Process.Kill(); err != nil {
+		l.WithError(err).Warn("cannot kill privilege wrapper")
+	}
+}
+
 // Careful: err.(*exec.ExitError).Stderr will not be set, even if you don't open an StderrPipe
 func (c *Cmd) StdoutPipeWithErrorBuf() (p io.ReadCloser, errBuf *circlog.CircularLog, err error) {
 	p, err = c.cmd.StdoutPipe()
@@ -83,6 +161,9 @@ func (c *Cmd) Start() (err error) {
 	c.startPre(true)
 	err = c.cmd.Start()
 	c.startPost(err)
+	if err == nil && c.wrapperExited != nil {
+		go c.terminateWrapperOnDone()
+	}
 	return err
 }
 
@@ -97,6 +178,10 @@ func (c *Cmd) Process() *os.Process {
 func (c *Cmd) Wait() (err error) {
 	c.waitPre()
 	err = c.cmd.Wait()
+	if c.wrapperExited != nil {
+		close(c.wrapperExited)
+		<-c.wrapperTerminated
+	}
 	c.waitPost(err)
 	return err
 }
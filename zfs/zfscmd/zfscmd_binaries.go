@@ -0,0 +1,97 @@
+package zfscmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+)
+
+var binaryPaths struct {
+	mtx     sync.RWMutex
+	paths   map[string]string
+	wrapper []string
+}
+
+// SetBinaryPaths configures the paths of the binaries that CommandContext executes.
+// The keys of paths are the binary names as passed to CommandContext (e.g. "zfs" or "zpool").
+// An empty path means that the binary is looked up in PATH, which is also the default.
+//
+// Each non-empty path must be absolute and refer to an executable file.
+// If any path is invalid, an error is returned and the configured paths remain unchanged.
+func SetBinaryPaths(paths map[string]string) error {
+	validated := make(map[string]string, len(paths))
+	for name, path := range paths {
+		if path == "" {
+			continue
+		}
+		if err := validateBinaryPath(path); err != nil {
+			return fmt.Errorf("invalid path for %q binary: %s", name, err)
+		}
+		validated[name] = path
+	}
+	binaryPaths.mtx.Lock()
+	defer binaryPaths.mtx.Unlock()
+	binaryPaths.paths = validated
+	return nil
+}
+
+// SetPrivilegeWrapper configures a command that is prepended to the argv of every command
+// executed by CommandContext, e.g. []string{"sudo", "-n"}.
+// An empty argv disables the wrapper, which is also the default.
+//
+// argv[0] must be an absolute path to an executable file or be found in PATH.
+// If it is invalid, an error is returned and the configured wrapper remains unchanged.
+func SetPrivilegeWrapper(argv []string) error {
+	if len(argv) > 0 {
+		var err error
+		if filepath.IsAbs(argv[0]) {
+			err = validateBinaryPath(argv[0])
+		} else {
+			_, err = exec.LookPath(argv[0])
+		}
+		if err != nil {
+			return fmt.Errorf("invalid privilege wrapper %q: %s", argv[0], err)
+		}
+	}
+	wrapper := make([]string, len(argv))
+	copy(wrapper, argv)
+	binaryPaths.mtx.Lock()
+	defer binaryPaths.mtx.Unlock()
+	binaryPaths.wrapper = wrapper
+	return nil
+}
+
+func validateBinaryPath(path string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("path %q must be absolute", path)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !fi.Mode().IsRegular() {
+		return fmt.Errorf("%q is not a regular file", path)
+	}
+	if fi.Mode().Perm()&0111 == 0 {
+		return fmt.Errorf("%q is not executable", path)
+	}
+	return nil
+}
+
+// argv returns the argv to execute for binary name and its args,
+// i.e., the privilege wrapper followed by the path of name and args,
+// and whether the privilege wrapper is used.
+func argv(name string, args []string) (argv []string, wrapped bool) {
+	binaryPaths.mtx.RLock()
+	defer binaryPaths.mtx.RUnlock()
+	path, ok := binaryPaths.paths[name]
+	if !ok {
+		path = name
+	}
+	argv = make([]string, 0, len(binaryPaths.wrapper)+1+len(args))
+	argv = append(argv, binaryPaths.wrapper...)
+	argv = append(argv, path)
+	return append(argv, args...), len(binaryPaths.wrapper) > 0
+}
@@ -0,0 +1,119 @@
+package zfscmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/zrepl/zrepl/daemon/logging/trace"
+)
+
+func argv0(name string) string {
+	argv, _ := argv(name, nil)
+	return argv[0]
+}
+
+func TestSetBinaryPaths(t *testing.T) {
+	defer func() { require.NoError(t, SetBinaryPaths(nil)) }()
+
+	absTestBin, err := filepath.Abs(testBin)
+	require.NoError(t, err)
+
+	require.NoError(t, SetBinaryPaths(map[string]string{"zfs": absTestBin, "zpool": ""}))
+	assert.Equal(t, absTestBin, argv0("zfs"))
+	assert.Equal(t, "zpool", argv0("zpool"))
+
+	assert.Equal(t, absTestBin, CommandContext(context.Background(), "zfs", "list").cmd.Path)
+
+	invalid := []string{
+		testBin,                      // relative
+		filepath.Dir(absTestBin),     // directory
+		absTestBin + ".doesnotexist", // missing
+		filepath.Join(filepath.Dir(absTestBin), "zfscmd.go"), // not executable
+	}
+	for _, p := range invalid {
+		assert.Error(t, SetBinaryPaths(map[string]string{"zfs": p}), "%s", p)
+		assert.Equal(t, absTestBin, argv0("zfs"), "failed SetBinaryPaths must not change paths")
+	}
+}
+
+func TestSetPrivilegeWrapper(t *testing.T) {
+	defer func() { require.NoError(t, SetPrivilegeWrapper(nil)) }()
+
+	absTestBin, err := filepath.Abs(testBin)
+	require.NoError(t, err)
+
+	require.NoError(t, SetPrivilegeWrapper([]string{absTestBin, "-n"}))
+	wrapped, ok := argv("zfs", []string{"list"})
+	assert.Equal(t, []string{absTestBin, "-n", "zfs", "list"}, wrapped)
+	assert.True(t, ok)
+
+	cmd := CommandContext(context.Background(), "zfs", "list")
+	assert.Equal(t, absTestBin, cmd.cmd.Path)
+	assert.Equal(t, []string{"zfs", "list"}, cmd.args, "metrics must not be labeled with the wrapper")
+
+	assert.Error(t, SetPrivilegeWrapper([]string{"zrepl-test-wrapper-does-not-exist"}))
+	wrapped, _ = argv("zfs", nil)
+	assert.Equal(t, []string{absTestBin, "-n", "zfs"}, wrapped, "failed SetPrivilegeWrapper must not change wrapper")
+
+	require.NoError(t, SetPrivilegeWrapper(nil))
+	wrapped, ok = argv("zfs", []string{"list"})
+	assert.Equal(t, []string{"zfs", "list"}, wrapped)
+	assert.False(t, ok)
+}
+
+func TestPrivilegeWrapperTerminatedOnCancel(t *testing.T) {
+	defer func() { require.NoError(t, SetPrivilegeWrapper(nil)) }()
+
+	dir, err := ioutil.TempDir("", "zrepl-zfscmd-wrapper")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// like sudo, the wrapper forwards SIGTERM to the wrapped command
+	wrapper := filepath.Join(dir, "wrapper")
+	logfile := filepath.Join(dir, "log")
+	script := fmt.Sprintf(`#!/bin/sh
+sleep 60 &
+child=$!
+trap 'echo term >> %[1]s; kill $child; exit 143' TERM
+echo started >> %[1]s
+wait $child
+`, logfile)
+	require.NoError(t, ioutil.WriteFile(wrapper, []byte(script), 0755))
+	require.NoError(t, SetPrivilegeWrapper([]string{wrapper}))
+
+	ctx, end := trace.WithTaskFromStack(context.Background())
+	defer end()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := CommandContext(ctx, "zfs", "list").Output()
+		done <- err
+	}()
+
+	require.Eventually(t, func() bool {
+		log, _ := ioutil.ReadFile(logfile)
+		return strings.Contains(string(log), "started")
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(privilegeWrapperGraceTimeout / 2):
+		t.Fatal("wrapper did not exit after SIGTERM")
+	}
+	log, err := ioutil.ReadFile(logfile)
+	require.NoError(t, err)
+	assert.Equal(t, "started\nterm\n", string(log))
+}
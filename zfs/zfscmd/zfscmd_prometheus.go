@@ -48,8 +48,8 @@ func RegisterMetrics(r prometheus.Registerer) {
 
 func waitPostPrometheus(c *Cmd, u usage, err error, now time.Time) {
 
-	if len(c.cmd.Args) < 2 {
-		getLogger(c.ctx).WithField("args", c.cmd.Args).
+	if len(c.args) < 2 {
+		getLogger(c.ctx).WithField("args", c.args).
 			Warn("prometheus: cannot turn zfs command into metric")
 		return
 	}
@@ -60,7 +60,7 @@ func waitPostPrometheus(c *Cmd, u usage, err error, now time.Time) {
 
 	jobid := getJobIDOrDefault(c.ctx, "_nojobid")
 
-	labelValues := []string{jobid, c.cmd.Args[0], c.cmd.Args[1]}
+	labelValues := []string{jobid, c.args[0], c.args[1]}
 
 	metrics.totaltime.
 		WithLabelValues(labelValues...).
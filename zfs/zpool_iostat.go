@@ -0,0 +1,92 @@
+package zfs
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zrepl/zrepl/zfs/zfscmd"
+)
+
+// ZpoolIOStat is the IO of a pool in operations / bytes per second, as reported by zpool iostat.
+type ZpoolIOStat struct {
+	Pool                  string
+	ReadOps, WriteOps     uint64
+	ReadBytes, WriteBytes uint64
+}
+
+// Bytes returns the pool's bandwidth in bytes per second, reads and writes combined.
+func (s ZpoolIOStat) Bytes() uint64 { return s.ReadBytes + s.WriteBytes }
+
+// ZpoolIOStatSample returns the average IO of pools over the next interval,
+// which is rounded up to full seconds.
+// It blocks for the duration of the interval.
+func ZpoolIOStatSample(ctx context.Context, pools []string, interval time.Duration) (map[string]ZpoolIOStat, error) {
+	if len(pools) == 0 {
+		return nil, fmt.Errorf("must specify at least one pool")
+	}
+	secs := int64((interval + time.Second - 1) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	// the first report shows the averages since the pool was imported, the second one those of the interval
+	args := []string{"iostat", "-H", "-p"}
+	args = append(args, pools...)
+	args = append(args, strconv.FormatInt(secs, 10), "2")
+	cmd := zfscmd.CommandContext(ctx, "zpool", args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, &ZFSError{Stderr: output, WaitErr: err}
+	}
+	stats, err := parseZpoolIOStatOutput(output)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pools {
+		if _, ok := stats[p]; !ok {
+			return nil, fmt.Errorf("zpool iostat output does not contain pool %q", p)
+		}
+	}
+	return stats, nil
+}
+
+// parseZpoolIOStatOutput parses the output of `zpool iostat -H -p`.
+// If a pool is reported multiple times, the last report wins.
+func parseZpoolIOStatOutput(output []byte) (map[string]ZpoolIOStat, error) {
+	stats := make(map[string]ZpoolIOStat)
+	s := bufio.NewScanner(bytes.NewReader(output))
+	for s.Scan() {
+		line := s.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		// name, alloc, free, read ops, write ops, read bandwidth, write bandwidth
+		fields := strings.Fields(line)
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("unexpected zpool iostat output line: %q", line)
+		}
+		var nums [4]uint64
+		for i := range nums {
+			n, err := strconv.ParseUint(fields[3+i], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse zpool iostat output line %q: %s", line, err)
+			}
+			nums[i] = n
+		}
+		stats[fields[0]] = ZpoolIOStat{
+			Pool:       fields[0],
+			ReadOps:    nums[0],
+			WriteOps:   nums[1],
+			ReadBytes:  nums[2],
+			WriteBytes: nums[3],
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,30 @@
+package zfs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseZpoolIOStatOutput(t *testing.T) {
+	// `zpool iostat -H -p tank backup 1 2`
+	output := "" +
+		"tank\t1099511627776\t2199023255552\t12\t34\t567890\t1234567\n" +
+		"backup\t42\t43\t0\t1\t0\t4096\n" +
+		"tank\t1099511627776\t2199023255552\t100\t200\t10485760\t20971520\n" +
+		"backup\t42\t43\t0\t0\t0\t0\n"
+
+	stats, err := parseZpoolIOStatOutput([]byte(output))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]ZpoolIOStat{
+		"tank":   {Pool: "tank", ReadOps: 100, WriteOps: 200, ReadBytes: 10485760, WriteBytes: 20971520},
+		"backup": {Pool: "backup"},
+	}, stats)
+	assert.Equal(t, uint64(31457280), stats["tank"].Bytes())
+
+	_, err = parseZpoolIOStatOutput([]byte("tank\t1\t2\t3\n"))
+	assert.Error(t, err)
+	_, err = parseZpoolIOStatOutput([]byte("tank\t1\t2\t3\t4\t5.5K\t6\n"))
+	assert.Error(t, err)
+}